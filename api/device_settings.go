@@ -0,0 +1,61 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"strconv"
+
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc/metadata"
+)
+
+// The keys in the metadata of a SetDevice request that carry the network settings of a device, which are not part
+// of the LoRaWAN device message. The Handler sets them from the attributes of the device, the Broker forwards them
+// and the Network Server stores them with the device.
+const (
+	frequencyPlanKey = "frequency-plan"
+	adrMarginKey     = "adr-margin"
+	abpKey           = "abp"
+)
+
+// DeviceSettings are the network settings of a device
+type DeviceSettings struct {
+	FrequencyPlan string // learned from the uplinks of the device if empty
+	ADRMargin     int    // the SNR margin for ADR, the default margin is used if zero
+	ABP           bool   // the device has no AppKey, so it can only be personalized
+}
+
+// OutgoingContextWithDeviceSettings adds the network settings of a device to the outgoing context
+func OutgoingContextWithDeviceSettings(ctx context.Context, settings DeviceSettings) context.Context {
+	pairs := make([]string, 0, 6)
+	if settings.FrequencyPlan != "" {
+		pairs = append(pairs, frequencyPlanKey, settings.FrequencyPlan)
+	}
+	if settings.ADRMargin != 0 {
+		pairs = append(pairs, adrMarginKey, strconv.Itoa(settings.ADRMargin))
+	}
+	if settings.ABP {
+		pairs = append(pairs, abpKey, "true")
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(pairs...)))
+}
+
+// DeviceSettingsFromIncomingContext returns the network settings of a device from the incoming context
+func DeviceSettingsFromIncomingContext(ctx context.Context) (settings DeviceSettings) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md[frequencyPlanKey]; len(values) > 0 {
+		settings.FrequencyPlan = values[0]
+	}
+	if values := md[adrMarginKey]; len(values) > 0 {
+		settings.ADRMargin, _ = strconv.Atoi(values[0])
+	}
+	if values := md[abpKey]; len(values) > 0 {
+		settings.ABP, _ = strconv.ParseBool(values[0])
+	}
+	return settings
+}
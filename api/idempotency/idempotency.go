@@ -0,0 +1,140 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package idempotency correlates retried requests between components with an idempotency key in the gRPC metadata.
+// Servers remember the responses to the keys for a short while, so that a request that is sent again with Retry after
+// the connection failed is handled only once. Uplinks and downlinks are sent on streams, which are not sent again, so
+// only unary requests such as activations carry keys.
+package idempotency
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const keyKey = "idempotency-key"
+
+// NewKey returns a new random idempotency key
+func NewKey() string {
+	key := make([]byte, 16)
+	rand.Read(key)
+	return hex.EncodeToString(key)
+}
+
+// OutgoingContextWithKey adds the idempotency key to the outgoing context. Requests that are sent again must use
+// the same key.
+func OutgoingContextWithKey(ctx context.Context, key string) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(keyKey, key)))
+}
+
+// KeyFromIncomingContext returns the idempotency key of the incoming context, or "" if there is none
+func KeyFromIncomingContext(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md[keyKey]; len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// Attempts is the number of times Retry sends a request
+var Attempts = 2
+
+// Retry calls f with the same idempotency key until it succeeds, it fails with an error other than Unavailable or
+// DeadlineExceeded, or it was called Attempts times. If the connection failed, the server may have handled the request, so f must add the
+// key to the outgoing context with OutgoingContextWithKey.
+func Retry(f func(key string) error) (err error) {
+	key := NewKey()
+	for attempt := 0; attempt < Attempts; attempt++ {
+		err = f(key)
+		if err == nil {
+			return nil
+		}
+		switch grpc.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded:
+		default:
+			return err
+		}
+	}
+	return err
+}
+
+type entry struct {
+	done chan struct{}
+	res  interface{}
+	err  error
+}
+
+// Cache remembers the responses to idempotency keys
+type Cache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// NewCache returns a new Cache that remembers the responses for the ttl
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Do calls f and remembers its response for the key. If f was already called for the key, Do waits for that call
+// to complete and returns its response instead. Errors are not remembered, so that a failed request can be sent
+// again. If the key is empty, f is always called.
+func (c *Cache) Do(key string, f func() (interface{}, error)) (interface{}, error) {
+	if key == "" {
+		return f()
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-e.done
+		return e.res, e.err
+	}
+	e := &entry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	e.res, e.err = f()
+
+	if e.err != nil {
+		c.forget(key)
+	} else {
+		time.AfterFunc(c.ttl, func() { c.forget(key) })
+	}
+	close(e.done)
+
+	return e.res, e.err
+}
+
+func (c *Cache) forget(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// UnaryServerInterceptor returns a server interceptor that returns the remembered response to requests with an
+// idempotency key that was already handled. Keys are scoped to the method and the ID of the calling component.
+func UnaryServerInterceptor(c *Cache) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key := KeyFromIncomingContext(ctx)
+		if key == "" {
+			return handler(ctx, req)
+		}
+		id, _ := ttnctx.IDFromIncomingContext(ctx)
+		return c.Do(info.FullMethod+":"+id+":"+key, func() (interface{}, error) {
+			return handler(ctx, req)
+		})
+	}
+}
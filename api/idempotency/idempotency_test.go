@@ -0,0 +1,119 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package idempotency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCache(t *testing.T) {
+	a := New(t)
+	c := NewCache(20 * time.Millisecond)
+
+	var calls int
+	f := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	res, err := c.Do("key", f)
+	a.So(err, ShouldBeNil)
+	a.So(res, ShouldEqual, 1)
+
+	// The remembered response is returned
+	res, _ = c.Do("key", f)
+	a.So(res, ShouldEqual, 1)
+	a.So(calls, ShouldEqual, 1)
+
+	// Other keys and empty keys are handled
+	res, _ = c.Do("other", f)
+	a.So(res, ShouldEqual, 2)
+	res, _ = c.Do("", f)
+	a.So(res, ShouldEqual, 3)
+	res, _ = c.Do("", f)
+	a.So(res, ShouldEqual, 4)
+
+	// The response is forgotten after the ttl
+	time.Sleep(30 * time.Millisecond)
+	res, _ = c.Do("key", f)
+	a.So(res, ShouldEqual, 5)
+
+	// Errors are not remembered
+	_, err = c.Do("failing", func() (interface{}, error) { return nil, errors.New("failed") })
+	a.So(err, ShouldNotBeNil)
+	res, err = c.Do("failing", f)
+	a.So(err, ShouldBeNil)
+	a.So(res, ShouldEqual, 6)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	a := New(t)
+	interceptor := UnaryServerInterceptor(NewCache(time.Minute))
+	info := &grpc.UnaryServerInfo{FullMethod: "/broker.Broker/Activate"}
+
+	var calls int
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	key := NewKey()
+	a.So(key, ShouldHaveLength, 32)
+	ctx := OutgoingContextWithKey(context.Background(), key)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	ctx = metadata.NewIncomingContext(context.Background(), md)
+	a.So(KeyFromIncomingContext(ctx), ShouldEqual, key)
+
+	res, _ := interceptor(ctx, nil, info, handler)
+	a.So(res, ShouldEqual, 1)
+	res, _ = interceptor(ctx, nil, info, handler)
+	a.So(res, ShouldEqual, 1)
+
+	// Requests without key are always handled
+	res, _ = interceptor(context.Background(), nil, info, handler)
+	a.So(res, ShouldEqual, 2)
+}
+
+func TestRetry(t *testing.T) {
+	a := New(t)
+
+	var keys []string
+	err := Retry(func(key string) error {
+		keys = append(keys, key)
+		return grpc.Errorf(codes.Unavailable, "connection failed")
+	})
+	a.So(grpc.Code(err), ShouldEqual, codes.Unavailable)
+	a.So(keys, ShouldHaveLength, Attempts)
+	a.So(keys[1], ShouldEqual, keys[0])
+
+	// Timeouts are retried
+	keys = nil
+	err = Retry(func(key string) error {
+		keys = append(keys, key)
+		if len(keys) == 1 {
+			return grpc.Errorf(codes.DeadlineExceeded, "timeout")
+		}
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	a.So(keys, ShouldHaveLength, 2)
+	a.So(keys[1], ShouldEqual, keys[0])
+
+	// Other errors are not retried
+	keys = nil
+	err = Retry(func(key string) error {
+		keys = append(keys, key)
+		return grpc.Errorf(codes.InvalidArgument, "invalid")
+	})
+	a.So(err, ShouldNotBeNil)
+	a.So(keys, ShouldHaveLength, 1)
+}
@@ -84,6 +84,7 @@ var DefaultDialOptions = []grpc.DialOption{
 		rpclog.StreamClientInterceptor(nil),
 	)),
 	grpc.WithDialer(KeepAliveDialer),
+	grpc.WithDecompressor(grpc.NewGZIPDecompressor()),
 	grpc.WithBlock(),
 }
 
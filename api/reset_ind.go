@@ -0,0 +1,35 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/hex"
+
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc/metadata"
+)
+
+// resetIndKey is the key in the metadata of a GetDevices request that carries the MIC of an uplink that contains a
+// ResetInd. The frame counter of an ABP device starts at zero again after a reset, so the Network Server must also
+// return the devices with a higher frame counter than the uplink, unless it already accepted the uplink before.
+const resetIndKey = "reset-ind"
+
+// OutgoingContextWithResetInd indicates in the outgoing context that the uplink with the MIC contains a ResetInd
+func OutgoingContextWithResetInd(ctx context.Context, mic []byte) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, metadata.Pairs(resetIndKey, hex.EncodeToString(mic))))
+}
+
+// ResetIndFromIncomingContext returns the MIC of the uplink if the incoming context indicates that it contains a
+// ResetInd, or nil otherwise
+func ResetIndFromIncomingContext(ctx context.Context) (mic []byte) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md[resetIndKey]; len(values) > 0 {
+		mic, _ = hex.DecodeString(values[0])
+		if len(mic) != 4 {
+			return nil
+		}
+	}
+	return mic
+}
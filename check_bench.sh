@@ -0,0 +1,36 @@
+#!/bin/bash
+# Runs the packet pipeline benchmarks and compares them with a baseline.
+# Without a baseline file, the results are saved as the new baseline.
+#
+# Usage: ./check_bench.sh [baseline]
+# BENCH_THRESHOLD is the allowed slowdown in percent (default 20)
+baseline=${1:-.bench/baseline.txt}
+threshold=${BENCH_THRESHOLD:-20}
+packages=${BENCH_PACKAGES:-./utils/frame ./core/broker}
+current=$(mktemp)
+trap 'rm -f $current' EXIT
+
+go test -run=NONE -bench=. -benchmem $packages | tee $current
+(( !${PIPESTATUS[0]} )) || exit 1
+
+if [[ ! -f $baseline ]]; then
+	mkdir -p "$(dirname "$baseline")"
+	grep '^Benchmark' $current > "$baseline"
+	echo "Saved baseline to $baseline"
+	exit 0
+fi
+
+awk -v threshold="$threshold" '
+	FNR == NR { if ($1 ~ /^Benchmark/) base[$1] = $3; next }
+	$1 ~ /^Benchmark/ && ($1 in base) && base[$1] > 0 {
+		change = ($3 - base[$1]) / base[$1] * 100
+		if (change > threshold) {
+			printf "%s: %.0f ns/op -> %.0f ns/op (+%.1f%%)\n", $1, base[$1], $3, change
+			regressed++
+		}
+	}
+	END {
+		if (regressed) { printf "%d benchmarks regressed more than %d%%\n", regressed, threshold; exit 1 }
+		print "No regressions"
+	}
+' "$baseline" $current
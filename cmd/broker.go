@@ -7,18 +7,30 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"google.golang.org/grpc"
 
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/core/broker"
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/broker/joinlimit"
+	"github.com/TheThingsNetwork/ttn/core/broker/peering"
+	"github.com/TheThingsNetwork/ttn/core/broker/quarantine"
+	"github.com/TheThingsNetwork/ttn/core/broker/tap"
 	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
 )
 
 // brokerCmd represents the broker command
@@ -57,6 +69,69 @@ var brokerCmd = &cobra.Command{
 			time.Duration(viper.GetInt("broker.deduplication-delay")) * time.Millisecond,
 		)
 		broker.SetNetworkServer(viper.GetString("broker.networkserver-address"), nsCert, viper.GetString("broker.networkserver-token"))
+		if target := viper.GetString("broker.tap"); target != "" {
+			sink, err := tap.NewSink(target)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not initialize tap")
+			}
+			broker.WithTap(tap.NewTap(sink, viper.GetFloat64("broker.tap-sample-rate")))
+		}
+		if window := viper.GetDuration("broker.replay-window"); window > 0 {
+			broker.WithReplayDetection(window)
+		}
+		if server := viper.GetString("broker.peering-server"); server != "" {
+			ctx.WithField("Server", server).Info("Exporting uplinks of other networks")
+			broker.WithPeering(peering.NewExchange(peering.Config{
+				Server:   server,
+				Username: viper.GetString("broker.peering-username"),
+				Password: viper.GetString("broker.peering-password"),
+				Topic:    viper.GetString("broker.peering-topic"),
+			}))
+		}
+		if delays := viper.GetStringSlice("broker.deduplication-delays"); len(delays) > 0 || viper.GetBool("broker.deduplication-adaptive") {
+			broker.WithDeduplicationWindow(brokerDeduplicationWindow())
+		}
+		if address := viper.GetString("broker.deduplication-redis-address"); address != "" {
+			client := redis.NewClient(chaos.RedisOptions(&redis.Options{
+				Addr:     address,
+				Password: viper.GetString("broker.deduplication-redis-password"),
+				DB:       viper.GetInt("broker.deduplication-redis-db"),
+			}))
+			if err := connectRedis(client); err != nil {
+				ctx.WithError(err).Fatal("Could not initialize deduplication database connection")
+			}
+			broker.WithRedisDeduplication(client)
+		}
+		if size := viper.GetInt("broker.quarantine-size"); size > 0 {
+			q := quarantine.NewQuarantine(size)
+			brokerQuarantine(q)
+			var hook *quarantine.Hook
+			if address := viper.GetString("broker.auto-provisioning-webhook"); address != "" {
+				hook = quarantine.NewHook(address)
+			}
+			broker.WithQuarantine(q, hook)
+		}
+		if threshold := viper.GetInt("broker.blacklist-threshold"); threshold > 0 {
+			bl := blacklist.NewBlacklist(threshold, viper.GetDuration("broker.blacklist-window"), viper.GetDuration("broker.blacklist-ttl"))
+			bl.Allow(brokerBlacklistAllowed()...)
+			brokerBlacklist(bl)
+			broker.WithBlacklist(bl)
+		}
+		if rate, backoff := viper.GetInt("broker.join-rate"), viper.GetDuration("broker.join-backoff"); rate > 0 || backoff > 0 {
+			ctx.WithFields(ttnlog.Fields{
+				"Rate":       rate,
+				"Backoff":    backoff,
+				"MaxBackoff": viper.GetDuration("broker.join-backoff-max"),
+			}).Info("Limiting join requests")
+			broker.WithJoinLimiter(joinlimit.NewLimiter(rate, backoff, viper.GetDuration("broker.join-backoff-max")))
+		}
+		if private := privateGateways("broker"); len(private) > 0 {
+			ctx.WithField("Gateways", len(private)).Info("Using private gateways")
+			broker.WithPrivateGateways(private)
+		}
+		if secureElement := secureElement("broker"); secureElement != nil {
+			broker.WithSecureElement(secureElement)
+		}
 		err = broker.Init(component)
 		if err != nil {
 			ctx.WithError(err).Fatal("Could not initialize broker")
@@ -81,10 +156,57 @@ var brokerCmd = &cobra.Command{
 		ctx.WithField("signal", <-sigChan).Info("signal received")
 
 		grpc.Stop()
+		component.Cancel()
 		broker.Shutdown()
 	},
 }
 
+func brokerDeduplicationWindow() broker.DeduplicationWindow {
+	window := broker.DeduplicationWindow{
+		Default:        time.Duration(viper.GetInt("broker.deduplication-delay")) * time.Millisecond,
+		FrequencyPlans: make(map[string]time.Duration),
+		DataRates:      make(map[string]time.Duration),
+		Adaptive:       viper.GetBool("broker.deduplication-adaptive"),
+	}
+	for _, delayStr := range viper.GetStringSlice("broker.deduplication-delays") {
+		parts := strings.SplitN(delayStr, "=", 2)
+		if len(parts) != 2 {
+			ctx.WithField("Delay", delayStr).Fatal("Deduplication delay should be formatted as FrequencyPlan=ms or DataRate=ms")
+		}
+		delay, err := strconv.Atoi(parts[1])
+		if err != nil || delay < 0 {
+			ctx.WithField("Delay", delayStr).Fatal("Deduplication delay should be a number of milliseconds")
+		}
+		if _, ok := pb_lorawan.FrequencyPlan_value[parts[0]]; ok {
+			window.FrequencyPlans[parts[0]] = time.Duration(delay) * time.Millisecond
+		} else {
+			window.DataRates[parts[0]] = time.Duration(delay) * time.Millisecond
+		}
+	}
+	return window
+}
+
+// brokerQuarantine serves the quarantine on the health port. Listing and clearing the quarantine requires the admin token.
+func brokerQuarantine(q *quarantine.Quarantine) {
+	http.Handle(quarantine.Path, component.RequireAdmin(q, "GET", "HEAD", "DELETE"))
+}
+
+// brokerBlacklist serves the blacklist on the health port. Unblocking DevAddrs requires the admin token.
+func brokerBlacklist(bl *blacklist.Blacklist) {
+	http.Handle(blacklist.Path, component.RequireAdmin(bl, "DELETE"))
+}
+
+func brokerBlacklistAllowed() (devAddrs []types.DevAddr) {
+	for _, devAddrStr := range viper.GetStringSlice("broker.blacklist-allow") {
+		devAddr, err := types.ParseDevAddr(devAddrStr)
+		if err != nil {
+			ctx.WithField("DevAddr", devAddrStr).Fatal("Could not parse DevAddr that should never be blacklisted")
+		}
+		devAddrs = append(devAddrs, devAddr)
+	}
+	return
+}
+
 func init() {
 	RootCmd.AddCommand(brokerCmd)
 
@@ -95,8 +217,64 @@ func init() {
 	brokerCmd.Flags().String("networkserver-token", "", "Networkserver token to use")
 	viper.BindPFlag("broker.networkserver-token", brokerCmd.Flags().Lookup("networkserver-token"))
 
+	brokerCmd.Flags().String("secure-element-address", "", "Secure element service that validates the MIC of devices without NwkSKey")
+	viper.BindPFlag("broker.secure-element-address", brokerCmd.Flags().Lookup("secure-element-address"))
+	brokerCmd.Flags().String("secure-element-cert", "", "Secure element certificate to use")
+	viper.BindPFlag("broker.secure-element-cert", brokerCmd.Flags().Lookup("secure-element-cert"))
+
 	brokerCmd.Flags().Int("deduplication-delay", 200, "Deduplication delay (in ms)")
 	viper.BindPFlag("broker.deduplication-delay", brokerCmd.Flags().Lookup("deduplication-delay"))
+	brokerCmd.Flags().StringSlice("deduplication-delays", []string{}, "Deduplication delay per frequency plan or data rate (EU_863_870=300,SF12BW125=400, in ms)")
+	viper.BindPFlag("broker.deduplication-delays", brokerCmd.Flags().Lookup("deduplication-delays"))
+	brokerCmd.Flags().Bool("deduplication-adaptive", false, "Grow the deduplication delay for slow data rates and shrink it to fit the RX1 window")
+	viper.BindPFlag("broker.deduplication-adaptive", brokerCmd.Flags().Lookup("deduplication-adaptive"))
+	brokerCmd.Flags().String("deduplication-redis-address", "", "Redis host and port to deduplicate uplinks across brokers. Empty deduplicates in memory")
+	viper.BindPFlag("broker.deduplication-redis-address", brokerCmd.Flags().Lookup("deduplication-redis-address"))
+	brokerCmd.Flags().String("deduplication-redis-password", "", "Redis password")
+	viper.BindPFlag("broker.deduplication-redis-password", brokerCmd.Flags().Lookup("deduplication-redis-password"))
+	brokerCmd.Flags().Int("deduplication-redis-db", 0, "Redis database")
+	viper.BindPFlag("broker.deduplication-redis-db", brokerCmd.Flags().Lookup("deduplication-redis-db"))
+
+	brokerCmd.Flags().Int("quarantine-size", 0, "Number of unknown devices to keep in the quarantine, which is served on /quarantine of the health port. Listing and clearing the quarantine requires the admin token. Zero disables the quarantine")
+	viper.BindPFlag("broker.quarantine-size", brokerCmd.Flags().Lookup("quarantine-size"))
+	brokerCmd.Flags().String("auto-provisioning-webhook", "", "Post unknown devices that enter the quarantine to this URL")
+	viper.BindPFlag("broker.auto-provisioning-webhook", brokerCmd.Flags().Lookup("auto-provisioning-webhook"))
+
+	brokerCmd.Flags().Int("blacklist-threshold", 0, "Blacklist a DevAddr that no device has after this many uplinks with the DevAddr within the blacklist window. Zero disables the blacklist")
+	viper.BindPFlag("broker.blacklist-threshold", brokerCmd.Flags().Lookup("blacklist-threshold"))
+	brokerCmd.Flags().Duration("blacklist-window", 10*time.Minute, "Window in which failed uplinks of a DevAddr are counted")
+	viper.BindPFlag("broker.blacklist-window", brokerCmd.Flags().Lookup("blacklist-window"))
+	brokerCmd.Flags().Duration("blacklist-ttl", time.Hour, "Time that a DevAddr stays on the blacklist, which is served on /blacklist of the health port. Unblocking DevAddrs requires the admin token")
+	viper.BindPFlag("broker.blacklist-ttl", brokerCmd.Flags().Lookup("blacklist-ttl"))
+	brokerCmd.Flags().StringSlice("blacklist-allow", []string{}, "DevAddrs that are never blacklisted")
+	viper.BindPFlag("broker.blacklist-allow", brokerCmd.Flags().Lookup("blacklist-allow"))
+
+	brokerCmd.Flags().Int("join-rate", 0, "Maximum number of join requests per second, shared fairly between AppEUIs. Zero disables the limit")
+	viper.BindPFlag("broker.join-rate", brokerCmd.Flags().Lookup("join-rate"))
+	brokerCmd.Flags().Duration("join-backoff", 0, "Time that a device has to wait before its next join request is handled, which doubles with every join. Zero disables the backoff")
+	viper.BindPFlag("broker.join-backoff", brokerCmd.Flags().Lookup("join-backoff"))
+	brokerCmd.Flags().Duration("join-backoff-max", 10*time.Minute, "Maximum time that a device has to wait before its next join request is handled")
+	viper.BindPFlag("broker.join-backoff-max", brokerCmd.Flags().Lookup("join-backoff-max"))
+
+	brokerCmd.Flags().String("tap", "", "Mirror uplinks to a file:///path or udp://host:port target")
+	viper.BindPFlag("broker.tap", brokerCmd.Flags().Lookup("tap"))
+	brokerCmd.Flags().Float64("tap-sample-rate", 1, "Fraction of the uplinks to mirror to the tap (between 0 and 1)")
+	viper.BindPFlag("broker.tap-sample-rate", brokerCmd.Flags().Lookup("tap-sample-rate"))
+
+	brokerCmd.Flags().String("peering-server", "", "Export uplinks with unknown DevAddrs to the packet exchange on this MQTT server (tcp://host:port) and accept downlinks back")
+	brokerCmd.Flags().String("peering-username", "", "Username for the packet exchange")
+	brokerCmd.Flags().String("peering-password", "", "Password for the packet exchange")
+	brokerCmd.Flags().String("peering-topic", "peering", "Topic prefix on the packet exchange")
+	viper.BindPFlag("broker.peering-server", brokerCmd.Flags().Lookup("peering-server"))
+	viper.BindPFlag("broker.peering-username", brokerCmd.Flags().Lookup("peering-username"))
+	viper.BindPFlag("broker.peering-password", brokerCmd.Flags().Lookup("peering-password"))
+	viper.BindPFlag("broker.peering-topic", brokerCmd.Flags().Lookup("peering-topic"))
+
+	brokerCmd.Flags().StringSlice("private-gateways", []string{}, "Only forward the uplinks and join requests that these gateways received for these applications (GatewayID=AppID)")
+	viper.BindPFlag("broker.private-gateways", brokerCmd.Flags().Lookup("private-gateways"))
+
+	brokerCmd.Flags().Duration("replay-window", 0, "Report frames that are received again within this window as possible replays. Set a window such as 30m to enable the replay detection")
+	viper.BindPFlag("broker.replay-window", brokerCmd.Flags().Lookup("replay-window"))
 
 	brokerCmd.Flags().String("server-address", "0.0.0.0", "The IP address to listen for communication")
 	brokerCmd.Flags().String("server-address-announce", "localhost", "The public IP address to announce")
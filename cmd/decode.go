@@ -0,0 +1,180 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/frame"
+	"github.com/TheThingsNetwork/ttn/utils/otaa"
+	"github.com/spf13/cobra"
+)
+
+// decodeCmd represents the decode command
+var decodeCmd = &cobra.Command{
+	Use:   "decode [PHYPayload]",
+	Short: "Decode a LoRaWAN frame",
+	Long: `ttn decode prints the fields of a base64 encoded LoRaWAN PHYPayload.
+
+With the NwkSKey, the MIC of data messages is validated and the MAC commands on
+FPort 0 are decrypted. With the AppSKey, the FRMPayload is decrypted. With the
+AppKey, the MIC of join requests is validated and join accepts are decrypted; if
+the DevNonce of the join request is given as well, the session keys are derived.`,
+	Example: `$ ttn decode QAQDAgGAAQABqrsRIjNE
+MType:       UnconfirmedDataUp
+Major:       0
+DevAddr:     01020304
+FCtrl:       ADR
+FCnt:        1
+FOpts:       
+FPort:       1
+FRMPayload:  AABB (encrypted)
+MIC:         11223344
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		payload, err := base64.StdEncoding.DecodeString(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid PHYPayload")
+		}
+
+		f, err := frame.Decode(payload)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not decode PHYPayload")
+		}
+
+		var keys frame.Keys
+		if str, _ := cmd.Flags().GetString("nwk-s-key"); str != "" {
+			key, err := types.ParseNwkSKey(str)
+			if err != nil {
+				ctx.WithError(err).Fatal("Invalid NwkSKey")
+			}
+			keys.NwkSKey = &key
+		}
+		if str, _ := cmd.Flags().GetString("app-s-key"); str != "" {
+			key, err := types.ParseAppSKey(str)
+			if err != nil {
+				ctx.WithError(err).Fatal("Invalid AppSKey")
+			}
+			keys.AppSKey = &key
+		}
+		if str, _ := cmd.Flags().GetString("app-key"); str != "" {
+			key, err := types.ParseAppKey(str)
+			if err != nil {
+				ctx.WithError(err).Fatal("Invalid AppKey")
+			}
+			keys.AppKey = &key
+		}
+		if err := f.ApplyKeys(keys); err != nil {
+			ctx.WithError(err).Fatal("Could not apply keys")
+		}
+
+		printDecoded("MType", f.MType)
+		printDecoded("Major", f.Major)
+		switch {
+		case f.IsData():
+			printDecoded("DevAddr", f.DevAddr)
+			var fCtrl []string
+			if f.ADR {
+				fCtrl = append(fCtrl, "ADR")
+			}
+			if f.ADRAckReq {
+				fCtrl = append(fCtrl, "ADRAckReq")
+			}
+			if f.Ack {
+				fCtrl = append(fCtrl, "ACK")
+			}
+			if f.FPending {
+				fCtrl = append(fCtrl, "FPending")
+			}
+			printDecoded("FCtrl", strings.Join(fCtrl, " "))
+			printDecoded("FCnt", f.FCnt)
+			printDecoded("FOpts", macCommandsString(f.FOpts))
+			if f.FPort != nil {
+				printDecoded("FPort", *f.FPort)
+				printDecoded("FRMPayload", fmt.Sprintf("%X%s", f.FRMPayload, decryptedString(f.Decrypted)))
+				if *f.FPort == 0 && f.Decrypted {
+					printDecoded("MAC Commands", macCommandsString(f.FRMPayloadMAC))
+				}
+			}
+		case f.IsJoinRequest():
+			printDecoded("AppEUI", f.AppEUI)
+			printDecoded("DevEUI", f.DevEUI)
+			printDecoded("DevNonce", f.DevNonce)
+		case f.IsJoinAccept():
+			if !f.Decrypted {
+				printDecoded("Payload", "encrypted (use --app-key)")
+				break
+			}
+			printDecoded("AppNonce", f.AppNonce)
+			printDecoded("NetID", f.NetID)
+			printDecoded("DevAddr", f.DevAddr)
+			printDecoded("RX1DROffset", f.RX1DROffset)
+			printDecoded("RX2DataRate", f.RX2DataRate)
+			printDecoded("RXDelay", f.RXDelay)
+			if len(f.CFList) > 0 {
+				printDecoded("CFList", f.CFList)
+			}
+			if str, _ := cmd.Flags().GetString("dev-nonce"); str != "" {
+				devNonce, err := types.ParseHEX(str, 2)
+				if err != nil {
+					ctx.WithError(err).Fatal("Invalid DevNonce")
+				}
+				var nonce [2]byte
+				copy(nonce[:], devNonce)
+				appSKey, nwkSKey, err := otaa.CalculateSessionKeys(*keys.AppKey, f.AppNonce, f.NetID, nonce)
+				if err != nil {
+					ctx.WithError(err).Fatal("Could not derive session keys")
+				}
+				printDecoded("NwkSKey", nwkSKey)
+				printDecoded("AppSKey", appSKey)
+			}
+		}
+
+		mic := fmt.Sprintf("%X", f.MIC)
+		if f.MICValid != nil {
+			if *f.MICValid {
+				mic += " (valid)"
+			} else {
+				mic += " (invalid)"
+			}
+		}
+		printDecoded("MIC", mic)
+	},
+}
+
+func printDecoded(key string, value interface{}) {
+	fmt.Printf("%-13s%v\n", key+":", value)
+}
+
+func macCommandsString(commands []frame.MACCommand) string {
+	strs := make([]string, 0, len(commands))
+	for _, command := range commands {
+		strs = append(strs, command.String())
+	}
+	return strings.Join(strs, " ")
+}
+
+func decryptedString(decrypted bool) string {
+	if decrypted {
+		return " (decrypted)"
+	}
+	return " (encrypted)"
+}
+
+func init() {
+	RootCmd.AddCommand(decodeCmd)
+
+	decodeCmd.Flags().String("nwk-s-key", "", "The NwkSKey of the device")
+	decodeCmd.Flags().String("app-s-key", "", "The AppSKey of the device")
+	decodeCmd.Flags().String("app-key", "", "The AppKey of the device")
+	decodeCmd.Flags().String("dev-nonce", "", "The DevNonce of the join request, to derive the session keys from a join accept")
+}
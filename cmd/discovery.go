@@ -20,6 +20,8 @@ import (
 	"github.com/TheThingsNetwork/ttn/core/discovery/announcement"
 	"github.com/TheThingsNetwork/ttn/core/proxy"
 	"github.com/TheThingsNetwork/ttn/core/proxy/jsonpb"
+	"github.com/TheThingsNetwork/ttn/core/proxy/openapi"
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -43,11 +45,11 @@ var discoveryCmd = &cobra.Command{
 		ctx.Info("Starting")
 
 		// Redis Client
-		client := redis.NewClient(&redis.Options{
+		client := redis.NewClient(chaos.RedisOptions(&redis.Options{
 			Addr:     viper.GetString("discovery.redis-address"),
 			Password: viper.GetString("discovery.redis-password"),
 			DB:       viper.GetInt("discovery.redis-db"),
-		})
+		}))
 
 		if err := connectRedis(client); err != nil {
 			ctx.WithError(err).Fatal("Could not initialize database connection")
@@ -97,6 +99,7 @@ var discoveryCmd = &cobra.Command{
 
 			prxy := proxy.WithLogger(mux, ctx)
 			prxy = proxy.WithPagination(prxy)
+			prxy = openapi.WithDocument(prxy, openapi.NewDocument("The Things Network Discovery API", viper.GetString("version"), openapi.DiscoveryRoutes))
 
 			go func() {
 				err := http.ListenAndServe(
@@ -0,0 +1,136 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
+	nsdevice "github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the consistency of the storage of the Handler and Network Server",
+	Long: `ttn fsck checks the storage of the Handler and Network Server for:
+
+- orphaned sessions: DevAddrs that are indexed for devices that do not exist
+- devices without keys
+- dangling downlink queues of devices that do not exist
+- duplicate DevAddr assignments: devices with the same DevAddr and NwkSKey, and
+  DevAddrs that are reserved for more than one device
+
+With --repair, orphaned sessions and dangling downlink queues are removed and
+missing DevAddr index entries are added. The other problems are reported, as
+they can only be repaired by the owners of the devices.
+
+Run ttn fsck before starting the components, and stop the components before
+running it with --repair.`,
+	Example: `$ ttn fsck --repair
+  WARN Inconsistency                            Key=0000000000000001:0000000000000004 Problem=DevAddr 00000004 is indexed, but the device does not exist Repaired=true Store=ns
+  WARN Inconsistency                            Key=app-id:dev-id Problem=Device has no AppKey and no session keys Repaired=false Store=handler
+  INFO Checked storage                          Inconsistencies=2 Repaired=1
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		repair, _ := cmd.Flags().GetBool("repair")
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("fsck.redis-address"),
+			Password: viper.GetString("fsck.redis-password"),
+			DB:       viper.GetInt("fsck.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		var inconsistencies, repaired int
+		report := func(store string, found []storage.Inconsistency) {
+			for _, inconsistency := range found {
+				ctx.WithFields(ttnlog.Fields{
+					"Store":    store,
+					"Key":      inconsistency.Key,
+					"Problem":  inconsistency.Problem,
+					"Repaired": inconsistency.Repaired,
+				}).Warn("Inconsistency")
+				inconsistencies++
+				if inconsistency.Repaired {
+					repaired++
+				}
+			}
+		}
+
+		nsPrefix := viper.GetString("fsck.networkserver-prefix")
+		found, err := nsdevice.NewRedisDeviceStore(client, nsPrefix).(*nsdevice.RedisDeviceStore).Check(repair)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not check Network Server devices")
+		}
+		report(nsPrefix, found)
+
+		reservations, err := devaddr.NewRedisReservationStore(client, nsPrefix).List()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not check DevAddr reservations")
+		}
+		report(nsPrefix, duplicateReservations(reservations))
+
+		handlerPrefix := viper.GetString("fsck.handler-prefix")
+		found, err = device.NewRedisDeviceStore(client, handlerPrefix).Check(repair)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not check Handler devices")
+		}
+		report(handlerPrefix, found)
+
+		ctx.WithFields(ttnlog.Fields{
+			"Inconsistencies": inconsistencies,
+			"Repaired":        repaired,
+		}).Info("Checked storage")
+
+		if inconsistencies > repaired {
+			os.Exit(1)
+		}
+	},
+}
+
+// duplicateReservations returns the reservations of DevAddrs that are reserved for more than one device
+func duplicateReservations(reservations []devaddr.Reservation) (inconsistencies []storage.Inconsistency) {
+	count := make(map[string]int)
+	for _, reservation := range reservations {
+		count[reservation.DevAddr.String()]++
+	}
+	for _, reservation := range reservations {
+		if n := count[reservation.DevAddr.String()]; n > 1 {
+			inconsistencies = append(inconsistencies, storage.Inconsistency{
+				Key:     fmt.Sprintf("%s:%s", reservation.AppEUI, reservation.DevEUI),
+				Problem: fmt.Sprintf("DevAddr %s is reserved for %d other devices", reservation.DevAddr, n-1),
+			})
+		}
+	}
+	return
+}
+
+func init() {
+	RootCmd.AddCommand(fsckCmd)
+
+	fsckCmd.Flags().String("redis-address", "localhost:6379", "Redis host and port")
+	viper.BindPFlag("fsck.redis-address", fsckCmd.Flags().Lookup("redis-address"))
+	fsckCmd.Flags().String("redis-password", "", "Redis password")
+	viper.BindPFlag("fsck.redis-password", fsckCmd.Flags().Lookup("redis-password"))
+	fsckCmd.Flags().Int("redis-db", 0, "Redis database")
+	viper.BindPFlag("fsck.redis-db", fsckCmd.Flags().Lookup("redis-db"))
+
+	fsckCmd.Flags().String("handler-prefix", "handler", "Key prefix of the Handler")
+	viper.BindPFlag("fsck.handler-prefix", fsckCmd.Flags().Lookup("handler-prefix"))
+	fsckCmd.Flags().String("networkserver-prefix", "ns", "Key prefix of the Network Server")
+	viper.BindPFlag("fsck.networkserver-prefix", fsckCmd.Flags().Lookup("networkserver-prefix"))
+
+	fsckCmd.Flags().Bool("repair", false, "Repair the inconsistencies that can be repaired")
+}
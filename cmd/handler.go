@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	pb "github.com/TheThingsNetwork/api/handler"
@@ -16,8 +17,12 @@ import (
 	"github.com/TheThingsNetwork/ttn/api/pool"
 	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
+	"github.com/TheThingsNetwork/ttn/core/handler/console"
 	"github.com/TheThingsNetwork/ttn/core/proxy"
 	"github.com/TheThingsNetwork/ttn/core/proxy/jsonpb"
+	"github.com/TheThingsNetwork/ttn/core/proxy/openapi"
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/TheThingsNetwork/ttn/utils/parse"
 	"github.com/grpc-ecosystem/grpc-gateway/runtime"
 	"github.com/spf13/cobra"
@@ -47,11 +52,11 @@ var handlerCmd = &cobra.Command{
 		ctx.Info("Starting")
 
 		// Redis Client
-		client := redis.NewClient(&redis.Options{
+		client := redis.NewClient(chaos.RedisOptions(&redis.Options{
 			Addr:     viper.GetString("handler.redis-address"),
 			Password: viper.GetString("handler.redis-password"),
 			DB:       viper.GetInt("handler.redis-db"),
-		})
+		}))
 
 		if err := connectRedis(client); err != nil {
 			ctx.WithError(err).Fatal("Could not initialize database connection")
@@ -113,17 +118,69 @@ var handlerCmd = &cobra.Command{
 			ctx.Warn("AMQP is not enabled in your configuration")
 		}
 
+		if viper.GetBool("salvage-storage") {
+			handler = handler.WithSalvageStorage()
+		}
+
 		if extraDeviceAttributes := viper.GetStringSlice("handler.extra-device-attributes"); len(extraDeviceAttributes) != 0 {
 			handler = handler.WithDeviceAttributes(extraDeviceAttributes...)
 		} else {
 			ctx.Debug("No extra device attribute set in your configuration")
 		}
 
+		if heartbeat := viper.GetDuration("handler.device-heartbeat-interval"); heartbeat > 0 {
+			handler = handler.WithDeviceHeartbeat(heartbeat)
+		}
+
+		if factor := viper.GetFloat64("handler.device-silence-factor"); factor > 0 {
+			handler = handler.WithSilentEvents(factor)
+		}
+
+		if threshold := viper.GetFloat64("handler.device-anomaly-threshold"); threshold > 0 {
+			handler = handler.WithAnomalyDetection(threshold)
+		}
+
+		handler = handler.WithJoinRetransmissionWindow(viper.GetDuration("handler.join-retransmission-window"))
+
+		if quota := viper.GetInt("handler.downlink-quota"); quota > 0 {
+			handler = handler.WithDownlinkQuota(uint(quota))
+		}
+
+		if workers := viper.GetInt("handler.uplink-workers"); workers > 0 {
+			handler = handler.WithUplinkWorkers(workers)
+		}
+
+		handler = handler.WithUplinkQueue(viper.GetInt("handler.uplink-queue-per-device"), viper.GetInt("handler.uplink-queue-size"))
+
+		if apps := viper.GetStringSlice("handler.secure-element-apps"); len(apps) > 0 {
+			handler = handler.WithSecureElementApps(apps...)
+		}
+
+		if routes := handlerSlackRoutes(); len(routes) > 0 {
+			handler = handler.WithAlertNotifier("slack", alert.NewSlackNotifier(routes))
+		}
+
+		if server := viper.GetString("handler.alert-smtp-address"); server != "" {
+			email := alert.NewEmailNotifier(
+				server,
+				viper.GetString("handler.alert-smtp-username"),
+				viper.GetString("handler.alert-smtp-password"),
+				viper.GetString("handler.alert-email-from"),
+			)
+			email.RateLimit = viper.GetInt("handler.alert-email-rate-limit")
+			if err := email.SetTemplates(viper.GetString("handler.alert-email-subject"), viper.GetString("handler.alert-email-body")); err != nil {
+				ctx.WithError(err).Fatal("Invalid alert email template")
+			}
+			handler = handler.WithAlertNotifier("email", email)
+		}
+
 		err = handler.Init(component)
 		if err != nil {
 			ctx.WithError(err).Fatal("Could not initialize handler")
 		}
+		handlerProvisioning(handler)
 		defer handler.Shutdown()
+		defer component.Cancel()
 
 		// gRPC Server
 		lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", viper.GetString("handler.server-address"), viper.GetInt("handler.server-port")))
@@ -154,6 +211,10 @@ var handlerCmd = &cobra.Command{
 
 			prxy := proxy.WithToken(mux)
 			prxy = proxy.WithPagination(prxy)
+			prxy = openapi.WithDocument(prxy, openapi.NewDocument("The Things Network Handler API", viper.GetString("version"), openapi.HandlerRoutes))
+			if viper.GetBool("handler.console") {
+				prxy = console.WithConsole(prxy)
+			}
 			prxy = proxy.WithLogger(prxy, ctx)
 
 			go func() {
@@ -174,6 +235,32 @@ var handlerCmd = &cobra.Command{
 	},
 }
 
+// handlerProvisioning serves the provisioning of claimable devices on the health port. Provisioning devices requires
+// the admin token.
+func handlerProvisioning(h handler.Handler) {
+	http.Handle(handler.ProvisionPath, component.RequireAdmin(handler.NewProvisioningHandler(h), "POST"))
+}
+
+func handlerSlackRoutes() map[alert.Severity]string {
+	routes := make(map[alert.Severity]string)
+	for _, routeStr := range viper.GetStringSlice("handler.alert-slack-routes") {
+		parts := strings.SplitN(routeStr, "=", 2)
+		if len(parts) != 2 {
+			ctx.WithField("Route", routeStr).Fatal("Slack route should be formatted as severity=url")
+		}
+		severity := alert.Severity(parts[0])
+		switch severity {
+		case "default":
+			severity = ""
+		case alert.SeverityInfo, alert.SeverityWarning, alert.SeverityCritical:
+		default:
+			ctx.WithField("Route", routeStr).Fatal("Slack route should have severity info, warning, critical or default")
+		}
+		routes[severity] = parts[1]
+	}
+	return routes
+}
+
 func init() {
 	RootCmd.AddCommand(handlerCmd)
 
@@ -218,7 +305,48 @@ func init() {
 	handlerCmd.Flags().Int("http-port", 8084, "The port where the gRPC proxy should listen")
 	viper.BindPFlag("handler.http-address", handlerCmd.Flags().Lookup("http-address"))
 	viper.BindPFlag("handler.http-port", handlerCmd.Flags().Lookup("http-port"))
+	handlerCmd.Flags().Bool("console", false, "Serve the web console on /console/ of the gRPC proxy")
+	viper.BindPFlag("handler.console", handlerCmd.Flags().Lookup("console"))
 
 	handlerCmd.Flags().StringSlice("extra-device-attributes", nil, "Extra device attributes to be whitelisted")
 	viper.BindPFlag("handler.extra-device-attributes", handlerCmd.Flags().Lookup("extra-device-attributes"))
+
+	handlerCmd.Flags().Duration("device-heartbeat-interval", 0, "Emit offline events for devices that were not seen within this interval. Zero disables the offline events")
+	viper.BindPFlag("handler.device-heartbeat-interval", handlerCmd.Flags().Lookup("device-heartbeat-interval"))
+	handlerCmd.Flags().Float64("device-silence-factor", 0, "Emit silent events for devices that were not seen within this multiple of their uplink period (the ttn-uplink-period attribute, or learned from their uplinks). Zero disables the silent events")
+	viper.BindPFlag("handler.device-silence-factor", handlerCmd.Flags().Lookup("device-silence-factor"))
+	handlerCmd.Flags().Float64("device-anomaly-threshold", 0, "Emit anomaly events for uplinks with an interval, RSSI, SNR or payload size that deviates this many standard deviations from the typical behavior of the device. Zero disables anomaly detection")
+	viper.BindPFlag("handler.device-anomaly-threshold", handlerCmd.Flags().Lookup("device-anomaly-threshold"))
+
+	handlerCmd.Flags().Duration("join-retransmission-window", handler.DefaultJoinRetransmissionWindow, "Send the same join accept for join requests with the same DevNonce within this window, until the device sends an uplink in the new session. Zero disables retransmissions")
+	viper.BindPFlag("handler.join-retransmission-window", handlerCmd.Flags().Lookup("join-retransmission-window"))
+
+	handlerCmd.Flags().Int("downlink-quota", 0, "Maximum number of downlinks that can be enqueued per device per day. Zero disables the quota")
+	viper.BindPFlag("handler.downlink-quota", handlerCmd.Flags().Lookup("downlink-quota"))
+	handlerCmd.Flags().Int("uplink-workers", 0, "Maximum number of devices of which uplinks are handled at the same time. Zero does not limit the number of devices")
+	viper.BindPFlag("handler.uplink-workers", handlerCmd.Flags().Lookup("uplink-workers"))
+	handlerCmd.Flags().Int("uplink-queue-per-device", handler.DefaultUplinkQueuePerDevice, "Maximum number of uplinks of a device that wait to be handled. Uplinks that do not fit are dropped. Zero does not limit the number of uplinks")
+	viper.BindPFlag("handler.uplink-queue-per-device", handlerCmd.Flags().Lookup("uplink-queue-per-device"))
+	handlerCmd.Flags().Int("uplink-queue-size", handler.DefaultUplinkQueueSize, "Maximum number of uplinks that wait to be handled. Uplinks that do not fit are dropped. Zero does not limit the number of uplinks")
+	viper.BindPFlag("handler.uplink-queue-size", handlerCmd.Flags().Lookup("uplink-queue-size"))
+	handlerCmd.Flags().StringSlice("secure-element-apps", []string{}, "Applications of which the devices without NwkSKey use a secure element. The Handler leaves the MIC check of those devices to the Broker")
+	viper.BindPFlag("handler.secure-element-apps", handlerCmd.Flags().Lookup("secure-element-apps"))
+
+	handlerCmd.Flags().StringSlice("alert-slack-routes", []string{}, "Slack webhooks of slack:operators alert targets per severity (severity=url, or default=url for all other severities)")
+	viper.BindPFlag("handler.alert-slack-routes", handlerCmd.Flags().Lookup("alert-slack-routes"))
+
+	handlerCmd.Flags().String("alert-smtp-address", "", "SMTP host and port to send alerts to email:address targets. Leave empty to disable email alerts")
+	handlerCmd.Flags().String("alert-smtp-username", "", "SMTP username")
+	handlerCmd.Flags().String("alert-smtp-password", "", "SMTP password")
+	handlerCmd.Flags().String("alert-email-from", "alerts@localhost", "Sender address of alert emails")
+	handlerCmd.Flags().String("alert-email-subject", "", "Template of the subject of alert emails (Go text/template, executed with the alert)")
+	handlerCmd.Flags().String("alert-email-body", "", "Template of the body of alert emails (Go text/template, executed with the alert)")
+	handlerCmd.Flags().Int("alert-email-rate-limit", 10, "Maximum number of alert emails per hour per recipient. Zero does not limit the number of emails")
+	viper.BindPFlag("handler.alert-smtp-address", handlerCmd.Flags().Lookup("alert-smtp-address"))
+	viper.BindPFlag("handler.alert-smtp-username", handlerCmd.Flags().Lookup("alert-smtp-username"))
+	viper.BindPFlag("handler.alert-smtp-password", handlerCmd.Flags().Lookup("alert-smtp-password"))
+	viper.BindPFlag("handler.alert-email-from", handlerCmd.Flags().Lookup("alert-email-from"))
+	viper.BindPFlag("handler.alert-email-subject", handlerCmd.Flags().Lookup("alert-email-subject"))
+	viper.BindPFlag("handler.alert-email-body", handlerCmd.Flags().Lookup("alert-email-body"))
+	viper.BindPFlag("handler.alert-email-rate-limit", handlerCmd.Flags().Lookup("alert-email-rate-limit"))
 }
@@ -0,0 +1,94 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerAlertsCmd represents the alerts command
+var handlerAlertsCmd = &cobra.Command{
+	Use:   "alerts [AppID] [file]",
+	Short: "Show or set the alert rules of an application",
+	Long: `ttn handler alerts shows or sets the alert rules of an application.
+
+Without a file, the current rules are printed as JSON. With a file, the rules of
+the application are replaced by the JSON array of rules in the file. Rules have
+an id, a metric (field, missed_uplinks, device_offline or gateway_offline), an
+optional field, operator, threshold and severity (info, warning or critical),
+and a list of notification targets ("event", "webhook:<url>", "slack:<url>" or
+"email:<address>"). The threshold of gateway_offline rules is in seconds. The
+"slack:operators" target posts to the Slack webhook that the operator of the
+handler configured for the severity of the rule. Email targets require the
+handler to be started with an SMTP server.
+
+Applications set the same rules with the alert-rules metadata of SetApplication,
+which requires the settings right to the application.`,
+	Example: `$ cat rules.json
+[
+  {"id": "battery", "metric": "field", "field": "battery", "operator": "<", "threshold": 10, "notify": ["event"]},
+  {"id": "gateway", "metric": "gateway_offline", "threshold": 600, "notify": ["webhook:https://example.com/alerts"]}
+]
+$ ttn handler alerts test rules.json
+  INFO Set alert rules                          AppID=test Rules=2
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || len(args) > 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := application.NewRedisApplicationStore(client, "handler")
+
+		app, err := store.Get(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get application")
+		}
+
+		if len(args) == 1 {
+			rules, _ := json.MarshalIndent(app.AlertRules, "", "  ")
+			fmt.Println(string(rules))
+			return
+		}
+
+		file, err := os.Open(args[1])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open file")
+		}
+		defer file.Close()
+
+		var rules []alert.Rule
+		if err := json.NewDecoder(file).Decode(&rules); err != nil {
+			ctx.WithError(err).Fatal("Could not read rules")
+		}
+		if err := handler.NewRedisHandler(client, "").SetAlertRules(app.AppID, rules); err != nil {
+			ctx.WithError(err).Fatal("Could not set rules")
+		}
+
+		ctx.WithField("AppID", app.AppID).WithField("Rules", len(rules)).Info("Set alert rules")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerAlertsCmd)
+}
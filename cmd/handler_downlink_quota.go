@@ -0,0 +1,97 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerDownlinkQuotaCmd represents the downlink-quota command
+var handlerDownlinkQuotaCmd = &cobra.Command{
+	Use:   "downlink-quota [AppID]",
+	Short: "Show or set the downlink quota of an application",
+	Long: `ttn handler downlink-quota shows or sets the maximum number of downlinks that
+can be enqueued per day (UTC) for each device of an application and for the
+application as a whole.
+
+Without flags, the current quota is printed as JSON. The quota per device
+overrides the --downlink-quota of the handler. Downlinks that exceed the quota
+are rejected with a downlink error event that contains the time when the next
+downlink is allowed. Use --reset to remove the quota of the application.
+
+Applications set the same quota with the downlink-quota metadata of
+SetApplication, which requires the settings right to the application.`,
+	Example: `$ ttn handler downlink-quota test --per-device 10 --per-application 1000
+  INFO Set downlink quota                       AppID=test
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := application.NewRedisApplicationStore(client, "handler")
+
+		app, err := store.Get(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get application")
+		}
+
+		flags := cmd.Flags()
+		reset, _ := flags.GetBool("reset")
+		if !reset && !flags.Changed("per-device") && !flags.Changed("per-application") {
+			quota, _ := json.MarshalIndent(app.DownlinkQuota, "", "  ")
+			fmt.Println(string(quota))
+			return
+		}
+
+		var quota application.DownlinkQuota
+		if app.DownlinkQuota != nil && !reset {
+			quota = *app.DownlinkQuota
+		}
+		for flag, limit := range map[string]*uint{
+			"per-device":      &quota.PerDevice,
+			"per-application": &quota.PerApplication,
+		} {
+			if !flags.Changed(flag) {
+				continue
+			}
+			value, _ := flags.GetInt(flag)
+			if value < 0 {
+				ctx.WithField("Flag", flag).Fatal("Invalid value")
+			}
+			*limit = uint(value)
+		}
+
+		if err := handler.NewRedisHandler(client, "").SetDownlinkQuota(app.AppID, &quota); err != nil {
+			ctx.WithError(err).Fatal("Could not set downlink quota")
+		}
+
+		ctx.WithField("AppID", app.AppID).Info("Set downlink quota")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerDownlinkQuotaCmd)
+	handlerDownlinkQuotaCmd.Flags().Int("per-device", 0, "Maximum number of downlinks per device per day")
+	handlerDownlinkQuotaCmd.Flags().Int("per-application", 0, "Maximum number of downlinks per application per day")
+	handlerDownlinkQuotaCmd.Flags().Bool("reset", false, "Remove the downlink quota of the application")
+}
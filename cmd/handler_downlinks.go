@@ -0,0 +1,121 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/mqtt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerDownlinksCmd represents the downlinks command
+var handlerDownlinksCmd = &cobra.Command{
+	Use:   "downlinks [AppID] [DevID]",
+	Short: "Show or cancel the queued downlinks of a device",
+	Long: `ttn handler downlinks shows or cancels the queued downlinks of a device.
+
+Without flags, the downlink queue of the device is printed as JSON, including
+the downlink that was sent but not yet acknowledged. Use --cancel to remove a
+single downlink from the queue, or --flush to remove all downlinks.
+
+Applications can do the same by publishing to the down/queue, down/cancel and
+down/flush MQTT topics of the device. Like the MQTT topics, this command
+publishes the down/cancelled and down/status events of cancelled downlinks on
+the MQTT broker of the Handler (handler.mqtt-address in the configuration).`,
+	Example: `$ ttn handler downlinks test dev --cancel XxgudALrK7rxp0kC
+  INFO Cancelled downlink                       AppID=test DevID=dev DownlinkID=XxgudALrK7rxp0kC
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+		appID, devID := args[0], args[1]
+		ctx := ctx.WithFields(ttnlog.Fields{"AppID": appID, "DevID": devID})
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		devices := device.NewRedisDeviceStore(client, "handler")
+
+		flags := cmd.Flags()
+		if flush, _ := flags.GetBool("flush"); flush {
+			flushed, err := devices.FlushDownlinks(appID, devID)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not flush downlink queue")
+			}
+			publishCancelledDownlinks(ctx, appID, devID, flushed...)
+			ctx.WithField("Cancelled", len(flushed)).Info("Flushed downlink queue")
+			return
+		}
+
+		if id, _ := flags.GetString("cancel"); id != "" {
+			ctx := ctx.WithField("DownlinkID", id)
+			cancelled, err := devices.CancelDownlink(appID, devID, id)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not cancel downlink")
+			}
+			if cancelled == nil {
+				ctx.Fatal("Downlink not found")
+			}
+			publishCancelledDownlinks(ctx, appID, devID, cancelled)
+			ctx.Info("Cancelled downlink")
+			return
+		}
+
+		items, err := handler.NewRedisHandler(client, "").DownlinkQueue(appID, devID)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get downlink queue")
+		}
+		out, _ := json.MarshalIndent(items, "", "  ")
+		fmt.Println(string(out))
+	},
+}
+
+// publishCancelledDownlinks publishes the events of cancelled downlinks on the MQTT broker of the Handler
+func publishCancelledDownlinks(ctx ttnlog.Interface, appID, devID string, cancelled ...*types.DownlinkMessage) {
+	if len(cancelled) == 0 {
+		return
+	}
+	address := viper.GetString("handler.mqtt-address")
+	if address == "" {
+		ctx.Warn("MQTT is not enabled in your configuration, not publishing events")
+		return
+	}
+	client := mqtt.NewClient(ctx, "ttnhdl", viper.GetString("handler.mqtt-username"), viper.GetString("handler.mqtt-password"), fmt.Sprintf("tcp://%s", address))
+	if err := client.Connect(); err != nil {
+		ctx.WithError(err).Warn("Could not connect to MQTT, not publishing events")
+		return
+	}
+	defer client.Disconnect()
+	for _, event := range handler.CancelledDownlinkEvents(appID, devID, cancelled...) {
+		token := client.PublishDeviceEvent(event.AppID, event.DevID, event.Event, event.Data)
+		if !token.WaitTimeout(handler.MQTTTimeout) {
+			ctx.WithField("Event", event.Event).Warn("Event publish timeout")
+		} else if token.Error() != nil {
+			ctx.WithField("Event", event.Event).WithError(token.Error()).Warn("Could not publish event")
+		}
+	}
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerDownlinksCmd)
+	handlerDownlinksCmd.Flags().String("cancel", "", "ID of the downlink to cancel")
+	handlerDownlinksCmd.Flags().Bool("flush", false, "Cancel all downlinks of the device")
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/export"
+	nsdevice "github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerExportCmd represents the export command
+var handlerExportCmd = &cobra.Command{
+	Use:   "export [AppID] [DevID] [file]",
+	Short: "Export the state of a device to an encrypted bundle",
+	Long: `ttn handler export writes the state of a device to a bundle that can be
+imported in another handler with ttn handler import, so that the device can be
+migrated without joining again.
+
+The bundle contains the session keys, frame counters, attributes and downlink
+queue of the device. With --networkserver-redis-address, it also contains the
+state of the device in the NetworkServer. The bundle is encrypted with the key
+in --key, or with a new key that is printed if --key is empty.
+
+Stop sending uplinks and downlinks of the device before the export, and delete
+the device from this handler after it was imported in the other handler.`,
+	Example: `$ ttn handler export test dev dev.bundle --networkserver-redis-address localhost:6379
+  INFO Generated bundle key                     Key=6C1F1CE4C2B2E5F3A1F0B63BB80E8E5F3A1F0B63BB80E8E5F3A1F0B63BB80E8E
+  INFO Exported device                          AppID=test DevID=dev Downlinks=1 File=dev.bundle NetworkServer=true
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 3 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+		appID, devID, file := args[0], args[1], args[2]
+		ctx := ctx.WithFields(ttnlog.Fields{"AppID": appID, "DevID": devID})
+
+		key := handlerBundleKey(cmd)
+		if key == nil {
+			var err error
+			if key, err = export.GenerateKey(); err != nil {
+				ctx.WithError(err).Fatal("Could not generate key")
+			}
+			ctx.WithField("Key", fmt.Sprintf("%X", key)).Info("Generated bundle key")
+		}
+
+		store, nsStore := handlerBundleStores(cmd)
+		bundle, err := export.Export(store, nsStore, appID, devID)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not export device")
+		}
+		sealed, err := export.Seal(bundle, key)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not encrypt bundle")
+		}
+		if err := ioutil.WriteFile(file, sealed, 0600); err != nil {
+			ctx.WithError(err).Fatal("Could not write bundle")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"File":          file,
+			"Downlinks":     len(bundle.Downlinks),
+			"NetworkServer": bundle.NetworkServer != nil,
+		}).Info("Exported device")
+	},
+}
+
+// handlerImportCmd represents the import command
+var handlerImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "Import the state of a device from an encrypted bundle",
+	Long: `ttn handler import restores the state of a device from a bundle that was
+written by ttn handler export.
+
+The bundle is decrypted with the key in --key. With
+--networkserver-redis-address, the state of the device in the NetworkServer is
+imported as well; the bundle must then contain that state. Devices that already
+exist are not overwritten, unless --replace is set.`,
+	Example: `$ ttn handler import dev.bundle --key 6C1F1CE4C2B2E5F3A1F0B63BB80E8E5F3A1F0B63BB80E8E5F3A1F0B63BB80E8E --networkserver-redis-address localhost:6379
+  INFO Imported device                          AppID=test DevID=dev Downlinks=1 NetworkServer=true
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		key := handlerBundleKey(cmd)
+		if key == nil {
+			ctx.Fatal("The key of the bundle is required")
+		}
+		sealed, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read bundle")
+		}
+		bundle, err := export.Open(sealed, key)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open bundle")
+		}
+		if bundle.Device == nil {
+			ctx.Fatal("Bundle does not contain a device")
+		}
+		ctx := ctx.WithFields(ttnlog.Fields{"AppID": bundle.Device.AppID, "DevID": bundle.Device.DevID})
+
+		store, nsStore := handlerBundleStores(cmd)
+		replace, _ := cmd.Flags().GetBool("replace")
+		if err := export.Import(bundle, store, nsStore, replace); err != nil {
+			ctx.WithError(err).Fatal("Could not import device")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"Downlinks":     len(bundle.Downlinks),
+			"NetworkServer": nsStore != nil,
+		}).Info("Imported device")
+	},
+}
+
+// handlerBundleKey returns the key in the --key flag, or nil if it is empty
+func handlerBundleKey(cmd *cobra.Command) []byte {
+	keyStr, _ := cmd.Flags().GetString("key")
+	if keyStr == "" {
+		return nil
+	}
+	key, err := hex.DecodeString(keyStr)
+	if err != nil || len(key) != export.KeySize {
+		ctx.WithField("Size", export.KeySize).Fatal("The key must be hex-encoded and have the right size")
+	}
+	return key
+}
+
+// handlerBundleStores returns the device store of the handler, and the device store of the NetworkServer if
+// --networkserver-redis-address is set
+func handlerBundleStores(cmd *cobra.Command) (device.Store, nsdevice.Store) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     viper.GetString("handler.redis-address"),
+		Password: viper.GetString("handler.redis-password"),
+		DB:       viper.GetInt("handler.redis-db"),
+	})
+	if err := connectRedis(client); err != nil {
+		ctx.WithError(err).Fatal("Could not initialize database connection")
+	}
+	store := device.NewRedisDeviceStore(client, "handler")
+
+	nsAddress, _ := cmd.Flags().GetString("networkserver-redis-address")
+	if nsAddress == "" {
+		return store, nil
+	}
+	nsPassword, _ := cmd.Flags().GetString("networkserver-redis-password")
+	nsDB, _ := cmd.Flags().GetInt("networkserver-redis-db")
+	nsClient := redis.NewClient(&redis.Options{
+		Addr:     nsAddress,
+		Password: nsPassword,
+		DB:       nsDB,
+	})
+	if err := connectRedis(nsClient); err != nil {
+		ctx.WithError(err).Fatal("Could not initialize NetworkServer database connection")
+	}
+	return store, nsdevice.NewRedisDeviceStore(nsClient, "ns")
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{handlerExportCmd, handlerImportCmd} {
+		cmd.Flags().String("key", "", "Hex-encoded 32-byte key of the bundle")
+		cmd.Flags().String("networkserver-redis-address", "", "Redis host and port of the NetworkServer. Leave empty to skip the NetworkServer state")
+		cmd.Flags().String("networkserver-redis-password", "", "Redis password of the NetworkServer")
+		cmd.Flags().Int("networkserver-redis-db", 0, "Redis database of the NetworkServer")
+		handlerCmd.AddCommand(cmd)
+	}
+	handlerImportCmd.Flags().Bool("replace", false, "Replace the device if it already exists")
+}
@@ -0,0 +1,94 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerFiltersCmd represents the filters command
+var handlerFiltersCmd = &cobra.Command{
+	Use:   "filters [AppID] [file]",
+	Short: "Show or set the uplink filter rules of an application",
+	Long: `ttn handler filters shows or sets the uplink filter rules of an application.
+
+Without a file, the current rules are printed as JSON. With a file, the rules of
+the application are replaced by the JSON array of rules in the file. Rules have
+an id, an action and optional f_ports that the rule applies to. The drop action
+drops all uplinks on the f_ports, the collapse action drops uplinks with the same
+port and payload as the previous uplink of the device and the throttle action
+drops uplinks within interval seconds after the last published uplink of the
+device. The rules are evaluated in order before uplinks are published; filtered
+uplinks are still handled, so their downlinks are sent.
+
+Applications set the same rules with the uplink-filters metadata of
+SetApplication, which requires the settings right to the application.`,
+	Example: `$ cat filters.json
+[
+  {"id": "debug", "action": "drop", "f_ports": [100]},
+  {"id": "collapse", "action": "collapse"},
+  {"id": "throttle", "action": "throttle", "interval": 60}
+]
+$ ttn handler filters test filters.json
+  INFO Set uplink filters                       AppID=test Rules=3
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) < 1 || len(args) > 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := application.NewRedisApplicationStore(client, "handler")
+
+		app, err := store.Get(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get application")
+		}
+
+		if len(args) == 1 {
+			rules, _ := json.MarshalIndent(app.UplinkFilters, "", "  ")
+			fmt.Println(string(rules))
+			return
+		}
+
+		file, err := os.Open(args[1])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open file")
+		}
+		defer file.Close()
+
+		var rules []filter.Rule
+		if err := json.NewDecoder(file).Decode(&rules); err != nil {
+			ctx.WithError(err).Fatal("Could not read rules")
+		}
+		if err := handler.NewRedisHandler(client, "").SetUplinkFilters(app.AppID, rules); err != nil {
+			ctx.WithError(err).Fatal("Could not set rules")
+		}
+
+		ctx.WithField("AppID", app.AppID).WithField("Rules", len(rules)).Info("Set uplink filters")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerFiltersCmd)
+}
@@ -0,0 +1,113 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerJoinAcceptCmd represents the join-accept command
+var handlerJoinAcceptCmd = &cobra.Command{
+	Use:   "join-accept [AppID]",
+	Short: "Show or set the join accept settings of an application",
+	Long: `ttn handler join-accept shows or sets the RX settings that are used in the
+join accepts of the devices in an application, instead of the defaults of the
+frequency plan.
+
+Without flags, the current settings are printed as JSON. Settings are validated
+against the frequency plans that are given with --frequency-plan, and again
+against the frequency plan of each join request. Use --reset to use the
+defaults of the frequency plan again.
+
+Applications set the same settings with the join-accept-settings metadata of
+SetApplication, which requires the settings right to the application.`,
+	Example: `$ ttn handler join-accept test --rx2-data-rate 0 --rx-delay 5
+  INFO Set join accept settings                 AppID=test
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := application.NewRedisApplicationStore(client, "handler")
+
+		app, err := store.Get(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get application")
+		}
+
+		flags := cmd.Flags()
+		reset, _ := flags.GetBool("reset")
+		if !reset && !flags.Changed("rx1-dr-offset") && !flags.Changed("rx2-data-rate") && !flags.Changed("rx-delay") {
+			settings, _ := json.MarshalIndent(app.JoinAccept, "", "  ")
+			fmt.Println(string(settings))
+			return
+		}
+
+		var settings application.JoinAcceptSettings
+		if app.JoinAccept != nil && !reset {
+			settings = *app.JoinAccept
+		}
+		for flag, setting := range map[string]**uint8{
+			"rx1-dr-offset": &settings.RX1DROffset,
+			"rx2-data-rate": &settings.RX2DataRate,
+			"rx-delay":      &settings.RXDelay,
+		} {
+			if !flags.Changed(flag) {
+				continue
+			}
+			value, _ := flags.GetInt(flag)
+			if value < 0 || value > 255 {
+				ctx.WithField("Flag", flag).Fatal("Invalid value")
+			}
+			v := uint8(value)
+			*setting = &v
+		}
+
+		frequencyPlans, _ := flags.GetStringSlice("frequency-plan")
+		for _, region := range frequencyPlans {
+			fp, err := band.Get(region)
+			if err != nil {
+				ctx.WithError(err).WithField("FrequencyPlan", region).Fatal("Invalid frequency plan")
+			}
+			if err := settings.Validate(&fp); err != nil {
+				ctx.WithError(err).WithField("FrequencyPlan", region).Fatal("Invalid join accept settings")
+			}
+		}
+
+		if err := handler.NewRedisHandler(client, "").SetJoinAcceptSettings(app.AppID, &settings); err != nil {
+			ctx.WithError(err).Fatal("Could not set join accept settings")
+		}
+
+		ctx.WithField("AppID", app.AppID).Info("Set join accept settings")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerJoinAcceptCmd)
+	handlerJoinAcceptCmd.Flags().Int("rx1-dr-offset", 0, "Data rate offset of RX1")
+	handlerJoinAcceptCmd.Flags().Int("rx2-data-rate", 0, "Data rate index of RX2")
+	handlerJoinAcceptCmd.Flags().Int("rx-delay", 0, "Delay of RX1 in seconds")
+	handlerJoinAcceptCmd.Flags().StringSlice("frequency-plan", []string{"EU_863_870"}, "Frequency plans to validate the settings against")
+	handlerJoinAcceptCmd.Flags().Bool("reset", false, "Use the defaults of the frequency plan")
+}
@@ -0,0 +1,86 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerJoinWebhookCmd represents the join-webhook command
+var handlerJoinWebhookCmd = &cobra.Command{
+	Use:   "join-webhook [AppID] [URL]",
+	Short: "Show or set the join webhook of an application",
+	Long: `ttn handler join-webhook shows or sets the join webhook of an application.
+
+Before a join request of a device in the application is accepted, the Handler
+posts the app_id, dev_id, app_eui, dev_eui, dev_nonce and allocated dev_addr as
+JSON to the webhook. The webhook responds with {"allow": true} to accept the
+join or {"allow": false, "reason": "..."} to reject it. An accepting response
+can override the dev_addr, rx1_dr_offset, rx2_data_rate and rx_delay of the
+join accept. Joins are rejected if the webhook does not respond in time.
+
+Without a URL, the current webhook is printed. Use --remove to remove it.`,
+	Example: `$ ttn handler join-webhook test https://example.com/joins
+  INFO Set join webhook                         AppID=test URL=https://example.com/joins
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		remove, _ := cmd.Flags().GetBool("remove")
+		if len(args) < 1 || len(args) > 2 || (remove && len(args) != 1) {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := application.NewRedisApplicationStore(client, "handler")
+
+		app, err := store.Get(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get application")
+		}
+
+		if len(args) == 1 && !remove {
+			fmt.Println(app.JoinWebhook)
+			return
+		}
+
+		var address string
+		if !remove {
+			address = args[1]
+			if u, err := url.Parse(address); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				ctx.WithField("URL", address).Fatal("Invalid webhook URL")
+			}
+		}
+
+		if err := handler.NewRedisHandler(client, "").SetJoinWebhook(app.AppID, address); err != nil {
+			ctx.WithError(err).Fatal("Could not set join webhook")
+		}
+
+		if remove {
+			ctx.WithField("AppID", app.AppID).Info("Removed join webhook")
+			return
+		}
+		ctx.WithField("AppID", app.AppID).WithField("URL", address).Info("Set join webhook")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerJoinWebhookCmd)
+	handlerJoinWebhookCmd.Flags().Bool("remove", false, "Remove the join webhook")
+}
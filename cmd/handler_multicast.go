@@ -0,0 +1,110 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerMulticastCmd represents the multicast command
+var handlerMulticastCmd = &cobra.Command{
+	Use:   "multicast [AppID] [DevID]",
+	Short: "Show or set up the multicast groups of a device",
+	Long: `ttn handler multicast shows or sets up the multicast groups of a device.
+
+Multicast groups are set up over the air with the Remote Multicast Setup
+protocol on FPort 200. The Handler derives the McKEKey of the device from its
+AppKey, enqueues a McGroupSetupReq with the encrypted McKey of the group and
+confirms the group when the device answers. The McAppSKey and McNwkSKey of the
+group are printed, so that they can be used to send multicast downlinks.
+
+Without flags, the multicast groups of the device are printed as JSON. Use
+--delete to enqueue a McGroupDeleteReq for the group.`,
+	Example: `$ ttn handler multicast test dev --group-id 0 --mc-addr 26000001 --mc-key 01020304050607080102030405060708
+  INFO Enqueued multicast group setup           AppID=test DevID=dev GroupID=0 McAppSKey=... McNwkSKey=...
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+		appID, devID := args[0], args[1]
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		flags := cmd.Flags()
+		if !flags.Changed("group-id") {
+			dev, err := device.NewRedisDeviceStore(client, "handler").Get(appID, devID)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not get device")
+			}
+			groups, _ := json.MarshalIndent(dev.MulticastGroups, "", "  ")
+			fmt.Println(string(groups))
+			return
+		}
+
+		id, _ := flags.GetUint8("group-id")
+		h := handler.NewRedisHandler(client, "")
+		ctx := ctx.WithFields(ttnlog.Fields{"AppID": appID, "DevID": devID, "GroupID": id})
+
+		if remove, _ := flags.GetBool("delete"); remove {
+			if err := h.DeleteMulticastGroup(appID, devID, id); err != nil {
+				ctx.WithError(err).Fatal("Could not delete multicast group")
+			}
+			ctx.Info("Enqueued multicast group deletion")
+			return
+		}
+
+		group := multicast.Group{ID: id}
+		var err error
+		mcAddr, _ := flags.GetString("mc-addr")
+		if group.McAddr, err = types.ParseDevAddr(mcAddr); err != nil {
+			ctx.WithError(err).Fatal("Invalid McAddr")
+		}
+		mcKey, _ := flags.GetString("mc-key")
+		if group.McKey, err = types.ParseAES128Key(mcKey); err != nil {
+			ctx.WithError(err).Fatal("Invalid McKey")
+		}
+		group.MinFCnt, _ = flags.GetUint32("min-fcnt")
+		group.MaxFCnt, _ = flags.GetUint32("max-fcnt")
+
+		if err := h.SetupMulticastGroup(appID, devID, group); err != nil {
+			ctx.WithError(err).Fatal("Could not set up multicast group")
+		}
+
+		mcAppSKey, mcNwkSKey := group.SessionKeys()
+		ctx.WithFields(ttnlog.Fields{
+			"McAppSKey": mcAppSKey,
+			"McNwkSKey": mcNwkSKey,
+		}).Info("Enqueued multicast group setup")
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerMulticastCmd)
+	handlerMulticastCmd.Flags().Uint8("group-id", 0, "ID of the multicast group (0-3)")
+	handlerMulticastCmd.Flags().String("mc-addr", "", "Address of the multicast group")
+	handlerMulticastCmd.Flags().String("mc-key", "", "McKey of the multicast group")
+	handlerMulticastCmd.Flags().Uint32("min-fcnt", 0, "First frame counter of the multicast group")
+	handlerMulticastCmd.Flags().Uint32("max-fcnt", 0, "Last frame counter of the multicast group")
+	handlerMulticastCmd.Flags().Bool("delete", false, "Delete the multicast group from the device")
+}
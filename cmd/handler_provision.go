@@ -0,0 +1,124 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/claim"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerProvisionCmd represents the provision command
+var handlerProvisionCmd = &cobra.Command{
+	Use:   "provision [file]",
+	Short: "Provision devices that can be claimed by applications",
+	Long: `ttn handler provision adds factory-provisioned devices to the Handler.
+
+The file should be a CSV file with the columns dev_eui, app_eui, app_key and claim_code.
+Applications can claim a provisioned device by presenting its DevEUI and claim code.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open file")
+		}
+		defer file.Close()
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		store := claim.NewRedisClaimStore(client, "handler")
+
+		reader := csv.NewReader(file)
+		reader.TrimLeadingSpace = true
+		header, err := reader.Read()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read header")
+		}
+		columns := make(map[string]int)
+		for i, column := range header {
+			columns[strings.ToLower(strings.TrimSpace(column))] = i
+		}
+		for _, column := range []string{"dev_eui", "app_key", "claim_code"} {
+			if _, ok := columns[column]; !ok {
+				ctx.WithField("Column", column).Fatal("Missing column")
+			}
+		}
+
+		var provisioned, failed int
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not read file")
+			}
+			get := func(column string) string {
+				if i, ok := columns[column]; ok && i < len(row) {
+					return strings.TrimSpace(row[i])
+				}
+				return ""
+			}
+			ctx := ctx.WithField("DevEUI", get("dev_eui"))
+			dev := new(claim.Device)
+			if dev.DevEUI, err = types.ParseDevEUI(get("dev_eui")); err != nil {
+				ctx.WithError(err).Warn("Invalid DevEUI")
+				failed++
+				continue
+			}
+			if dev.AppEUI, err = types.ParseAppEUI(get("app_eui")); err != nil {
+				ctx.WithError(err).Warn("Invalid AppEUI")
+				failed++
+				continue
+			}
+			if dev.AppKey, err = types.ParseAppKey(get("app_key")); err != nil {
+				ctx.WithError(err).Warn("Invalid AppKey")
+				failed++
+				continue
+			}
+			if code := get("claim_code"); code != "" {
+				dev.SetClaimCode(code)
+			}
+			if err := store.Provision(dev); err != nil {
+				ctx.WithError(err).Warn("Could not provision device")
+				failed++
+				continue
+			}
+			provisioned++
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"Provisioned": provisioned,
+			"Failed":      failed,
+		}).Info("Provisioned devices")
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerProvisionCmd)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// handlerSessionCmd represents the session command
+var handlerSessionCmd = &cobra.Command{
+	Use:   "session [AppID] [DevID]",
+	Short: "Show the session state of a device",
+	Long: `ttn handler session prints the session state of a device as JSON, for
+debugging devices that do not behave.
+
+The session state contains the DevAddr, fingerprints of the keys, the last
+uplink, the multicast groups and the downlink queue of the device. Keys are
+never printed. Use ttn networkserver session for the frame counters, ADR
+state and pending MAC commands of the device.`,
+	Example: `$ ttn handler session test dev
+{
+  "app_id": "test",
+  "dev_id": "dev",
+  "app_eui": "70B3D57EF0000001",
+  "dev_eui": "0004A30B001C0530",
+  "dev_addr": "26000001",
+  "app_key_fingerprint": "9A1E30C7",
+  "nwk_s_key_fingerprint": "D28DFCBF",
+  "app_s_key_fingerprint": "5B0C7E12",
+  "f_cnt_up": 42,
+  ...
+  "downlinks": []
+}
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+		appID, devID := args[0], args[1]
+		ctx := ctx.WithFields(ttnlog.Fields{"AppID": appID, "DevID": devID})
+
+		client := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("handler.redis-address"),
+			Password: viper.GetString("handler.redis-password"),
+			DB:       viper.GetInt("handler.redis-db"),
+		})
+		if err := connectRedis(client); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize database connection")
+		}
+		defer client.Close()
+
+		session, err := handler.NewRedisHandler(client, "").GetSession(appID, devID)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get session")
+		}
+		out, _ := json.MarshalIndent(session, "", "  ")
+		fmt.Println(string(out))
+	},
+}
+
+func init() {
+	handlerCmd.AddCommand(handlerSessionCmd)
+}
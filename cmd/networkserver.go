@@ -14,7 +14,10 @@ import (
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/networkserver"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -37,11 +40,11 @@ var networkserverCmd = &cobra.Command{
 		ctx.Info("Starting")
 
 		// Redis Client
-		client := redis.NewClient(&redis.Options{
+		client := redis.NewClient(chaos.RedisOptions(&redis.Options{
 			Addr:     viper.GetString("networkserver.redis-address"),
 			Password: viper.GetString("networkserver.redis-password"),
 			DB:       viper.GetInt("networkserver.redis-db"),
-		})
+		}))
 
 		if err := connectRedis(client); err != nil {
 			ctx.WithError(err).Fatal("Could not initialize database connection")
@@ -56,19 +59,26 @@ var networkserverCmd = &cobra.Command{
 		// networkserver Server
 		networkserver := networkserver.NewRedisNetworkServer(client, viper.GetInt("networkserver.net-id"))
 
+		if viper.GetBool("salvage-storage") {
+			networkserver.WithSalvageStorage()
+		}
+
+		if size := viper.GetInt("networkserver.device-cache-size"); size > 0 {
+			options := device.DefaultCacheOptions
+			options.DeviceCacheSize = size
+			options.AddressCacheSize = size
+			networkserver.WithCache(options)
+		}
+
+		if secureElement := secureElement("networkserver"); secureElement != nil {
+			networkserver.WithSecureElement(secureElement)
+		}
+
 		// Register Prefixes
-		for prefix, usage := range viper.GetStringMapString("networkserver.prefixes") {
-			prefix, err := types.ParseDevAddrPrefix(prefix)
-			if err != nil {
-				ctx.WithError(err).Warn("Could not use DevAddr Prefix. Skipping.")
-				continue
-			}
-			err = networkserver.UsePrefix(prefix, strings.Split(usage, ","))
-			if err != nil {
-				ctx.WithError(err).Fatal("Could not initialize networkserver")
-				continue
-			}
-			ctx.Infof("Using DevAddr prefix %s (%v)", prefix, usage)
+		usePrefixes(networkserver)
+
+		if err := networkserver.UseDevAddrStrategy(viper.GetString("networkserver.devaddr-strategy")); err != nil {
+			ctx.WithError(err).Fatal("Could not initialize networkserver")
 		}
 
 		err = networkserver.Init(component)
@@ -95,10 +105,27 @@ var networkserverCmd = &cobra.Command{
 		ctx.WithField("signal", <-sigChan).Info("signal received")
 
 		grpc.Stop()
+		component.Cancel()
 		networkserver.Shutdown()
 	},
 }
 
+func usePrefixes(networkserver networkserver.NetworkServer) {
+	for prefix, usage := range viper.GetStringMapString("networkserver.prefixes") {
+		prefix, err := types.ParseDevAddrPrefix(prefix)
+		if err != nil {
+			ctx.WithError(err).Warn("Could not use DevAddr Prefix. Skipping.")
+			continue
+		}
+		err = networkserver.UsePrefix(prefix, strings.Split(usage, ","))
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not initialize networkserver")
+			continue
+		}
+		ctx.Infof("Using DevAddr prefix %s (%v)", prefix, usage)
+	}
+}
+
 func init() {
 	RootCmd.AddCommand(networkserverCmd)
 
@@ -112,10 +139,21 @@ func init() {
 	networkserverCmd.Flags().Int("net-id", 19, "LoRaWAN NetID")
 	viper.BindPFlag("networkserver.net-id", networkserverCmd.Flags().Lookup("net-id"))
 
+	networkserverCmd.Flags().String("secure-element-address", "", "Secure element service that sets the MIC of downlinks to devices without NwkSKey")
+	viper.BindPFlag("networkserver.secure-element-address", networkserverCmd.Flags().Lookup("secure-element-address"))
+	networkserverCmd.Flags().String("secure-element-cert", "", "Secure element certificate to use")
+	viper.BindPFlag("networkserver.secure-element-cert", networkserverCmd.Flags().Lookup("secure-element-cert"))
+
+	networkserverCmd.Flags().Int("device-cache-size", 0, "Number of devices to cache. Only enable when this is the only Network Server that uses the database")
+	viper.BindPFlag("networkserver.device-cache-size", networkserverCmd.Flags().Lookup("device-cache-size"))
+
 	viper.SetDefault("networkserver.prefixes", map[string]string{
 		"26000000/20": "otaa,abp,world,local,private,testing",
 	})
 
+	networkserverCmd.Flags().String("devaddr-strategy", devaddr.StrategyRandom, "Strategy to allocate DevAddrs in the prefixes (random or sequential)")
+	viper.BindPFlag("networkserver.devaddr-strategy", networkserverCmd.Flags().Lookup("devaddr-strategy"))
+
 	networkserverCmd.Flags().String("server-address", "0.0.0.0", "The IP address to listen for communication")
 	networkserverCmd.Flags().String("server-address-announce", "localhost", "The public IP address to announce")
 	networkserverCmd.Flags().Int("server-port", 1903, "The port for communication")
@@ -0,0 +1,87 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/networkserver"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// networkserverChannelsCmd represents the channels command
+var networkserverChannelsCmd = &cobra.Command{
+	Use:   "channels [AppID]",
+	Short: "Set the extra channels of the devices of an application",
+	Long: `ttn networkserver channels sets the extra channels that the Network Server
+provisions on the devices of an application with NewChannelReq and DlChannelReq
+MAC commands. Channels are formatted as index:frequency:minDR-maxDR, optionally
+followed by :downlinkFrequency. Channels that are removed from the plan are
+disabled on the devices. Without --channel, the current channels are shown.
+Only frequency plans with dynamic channels are supported.`,
+	Example: `$ ttn networkserver channels my-app --channel 3:867100000:0-5 --channel 4:867300000:0-5
+  INFO Set channel plan                         AppID=my-app Channels=[3:867100000:0-5 4:867300000:0-5]
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+		appID := args[0]
+		ctx := ctx.WithField("AppID", appID)
+
+		client := networkserverRedisClient()
+		defer client.Close()
+		networkserver := networkserver.NewRedisNetworkServer(client, viper.GetInt("networkserver.net-id"))
+
+		if remove, _ := cmd.Flags().GetBool("remove"); remove {
+			if err := networkserver.SetChannelPlan(appID, nil); err != nil {
+				ctx.WithError(err).Fatal("Could not remove channel plan")
+			}
+			ctx.Info("Removed channel plan")
+			return
+		}
+
+		channels, _ := cmd.Flags().GetStringSlice("channel")
+		if len(channels) == 0 {
+			plan, err := networkserver.GetChannelPlan(appID)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not get channel plan")
+			}
+			ctx.WithField("Channels", plan).Info("Channel plan")
+			return
+		}
+
+		var plan channelplan.Plan
+		for _, str := range channels {
+			channel, err := channelplan.ParseChannel(str)
+			if err != nil {
+				ctx.WithError(err).Fatal("Invalid channel")
+			}
+			plan = append(plan, channel)
+		}
+
+		frequencyPlan, _ := cmd.Flags().GetString("frequency-plan")
+		fp, err := band.Get(frequencyPlan)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid frequency plan")
+		}
+		if err := plan.Validate(&fp); err != nil {
+			ctx.WithError(err).Fatal("Invalid channel plan")
+		}
+
+		if err := networkserver.SetChannelPlan(appID, plan); err != nil {
+			ctx.WithError(err).Fatal("Could not set channel plan")
+		}
+		ctx.WithField("Channels", plan).Info("Set channel plan")
+	},
+}
+
+func init() {
+	networkserverCmd.AddCommand(networkserverChannelsCmd)
+	networkserverChannelsCmd.Flags().StringSlice("channel", []string{}, "Channel formatted as index:frequency:minDR-maxDR[:downlinkFrequency]")
+	networkserverChannelsCmd.Flags().String("frequency-plan", "EU_863_870", "Frequency plan to validate the channels against")
+	networkserverChannelsCmd.Flags().Bool("remove", false, "Remove the channel plan")
+}
@@ -0,0 +1,131 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/networkserver"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+func networkserverRedisClient() *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     viper.GetString("networkserver.redis-address"),
+		Password: viper.GetString("networkserver.redis-password"),
+		DB:       viper.GetInt("networkserver.redis-db"),
+	})
+	if err := connectRedis(client); err != nil {
+		ctx.WithError(err).Fatal("Could not initialize database connection")
+	}
+	return client
+}
+
+// networkserverDevAddrsCmd represents the devaddrs command
+var networkserverDevAddrsCmd = &cobra.Command{
+	Use:   "devaddrs",
+	Short: "Show the utilization of the DevAddr prefixes",
+	Long: `ttn networkserver devaddrs shows how many addresses of each DevAddr prefix
+are used by devices, and which devices have a reserved DevAddr.`,
+	Example: `$ ttn networkserver devaddrs
+  INFO Using DevAddr prefix 26000000/20 (otaa,abp,world,local,private,testing)
+  INFO DevAddr prefix                           Prefix=26000000/20 Ratio=0.0105 Size=4096 Used=43
+  INFO Reserved DevAddr                         AppEUI=70B3D57EF0000001 DevAddr=26000001 DevEUI=0004A30B001C0530
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := networkserverRedisClient()
+		defer client.Close()
+
+		networkserver := networkserver.NewRedisNetworkServer(client, viper.GetInt("networkserver.net-id"))
+		usePrefixes(networkserver)
+
+		utilization, err := networkserver.GetDevAddrUtilization()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get DevAddr utilization")
+		}
+		for _, u := range utilization {
+			ctx.WithFields(ttnlog.Fields{
+				"Prefix": u.Prefix,
+				"Size":   u.Size,
+				"Used":   u.Used,
+				"Ratio":  fmt.Sprintf("%.4f", u.Ratio()),
+			}).Info("DevAddr prefix")
+		}
+
+		reservations, err := devaddr.NewRedisReservationStore(client, "ns").List()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not list DevAddr reservations")
+		}
+		for _, reservation := range reservations {
+			ctx.WithFields(ttnlog.Fields{
+				"AppEUI":  reservation.AppEUI,
+				"DevEUI":  reservation.DevEUI,
+				"DevAddr": reservation.DevAddr,
+			}).Info("Reserved DevAddr")
+		}
+	},
+}
+
+// networkserverReserveCmd represents the reserve command
+var networkserverReserveCmd = &cobra.Command{
+	Use:   "reserve [AppEUI] [DevEUI] [DevAddr]",
+	Short: "Reserve a static DevAddr for a device",
+	Long: `ttn networkserver reserve reserves a static DevAddr for a device. The Network
+Server assigns the reserved DevAddr when the device joins, instead of
+allocating a DevAddr in one of the prefixes. Use --remove to remove the
+reservation.`,
+	Example: `$ ttn networkserver reserve 70B3D57EF0000001 0004A30B001C0530 26000001
+  INFO Reserved DevAddr                         AppEUI=70B3D57EF0000001 DevAddr=26000001 DevEUI=0004A30B001C0530
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		remove, _ := cmd.Flags().GetBool("remove")
+		if (remove && len(args) != 2) || (!remove && len(args) != 3) {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		appEUI, err := types.ParseAppEUI(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid AppEUI")
+		}
+		devEUI, err := types.ParseDevEUI(args[1])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid DevEUI")
+		}
+
+		client := networkserverRedisClient()
+		defer client.Close()
+		store := devaddr.NewRedisReservationStore(client, "ns")
+
+		ctx := ctx.WithFields(ttnlog.Fields{"AppEUI": appEUI, "DevEUI": devEUI})
+
+		if remove {
+			if err := store.Delete(appEUI, devEUI); err != nil {
+				ctx.WithError(err).Fatal("Could not remove reservation")
+			}
+			ctx.Info("Removed DevAddr reservation")
+			return
+		}
+
+		devAddr, err := types.ParseDevAddr(args[2])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid DevAddr")
+		}
+		if err := store.Set(devaddr.Reservation{AppEUI: appEUI, DevEUI: devEUI, DevAddr: devAddr}); err != nil {
+			ctx.WithError(err).Fatal("Could not reserve DevAddr")
+		}
+		ctx.WithField("DevAddr", devAddr).Info("Reserved DevAddr")
+	},
+}
+
+func init() {
+	networkserverCmd.AddCommand(networkserverDevAddrsCmd)
+	networkserverCmd.AddCommand(networkserverReserveCmd)
+	networkserverReserveCmd.Flags().Bool("remove", false, "Remove the reservation")
+}
@@ -0,0 +1,80 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/networkserver"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// networkserverSessionCmd represents the session command
+var networkserverSessionCmd = &cobra.Command{
+	Use:   "session [AppEUI] [DevEUI]",
+	Short: "Show the session state of a device",
+	Long: `ttn networkserver session prints the session state of a device as JSON, for
+debugging devices that do not behave.
+
+The session state contains the DevAddr, a fingerprint of the NwkSKey, the
+frame counters, the ADR state, the receive windows and the MAC commands that
+were sent to the device but not yet answered. MAC commands are sent again in
+the next downlinks, and are listed in unanswered_mac when they expire without
+an answer. Keys are never printed.`,
+	Example: `$ ttn networkserver session 70B3D57EF0000001 0004A30B001C0530
+{
+  "app_eui": "70B3D57EF0000001",
+  "dev_eui": "0004A30B001C0530",
+  "app_id": "test",
+  "dev_id": "dev",
+  "dev_addr": "26000001",
+  "nwk_s_key_fingerprint": "D28DFCBF",
+  "f_cnt_up": 42,
+  "f_cnt_down": 3,
+  ...
+  "pending_mac": [
+    {
+      "command": "LinkADRReq",
+      "attempts": 1,
+      "first_sent": "2017-06-12T09:41:02.891Z",
+      "last_sent": "2017-06-12T09:41:02.891Z"
+    }
+  ]
+}
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 2 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		appEUI, err := types.ParseAppEUI(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid AppEUI")
+		}
+		devEUI, err := types.ParseDevEUI(args[1])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid DevEUI")
+		}
+		ctx := ctx.WithFields(ttnlog.Fields{"AppEUI": appEUI, "DevEUI": devEUI})
+
+		client := networkserverRedisClient()
+		defer client.Close()
+
+		session, err := networkserver.NewRedisNetworkServer(client, viper.GetInt("networkserver.net-id")).GetSession(appEUI, devEUI)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get session")
+		}
+		out, _ := json.MarshalIndent(session, "", "  ")
+		fmt.Println(string(out))
+	},
+}
+
+func init() {
+	networkserverCmd.AddCommand(networkserverSessionCmd)
+}
@@ -0,0 +1,18 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/spf13/viper"
+)
+
+// privateGateways returns the private gateways of the component, which the Router and Broker are configured with
+func privateGateways(component string) types.PrivateGateways {
+	gateways, err := types.ParsePrivateGateways(viper.GetStringSlice(component + ".private-gateways")...)
+	if err != nil {
+		ctx.WithError(err).Fatal("Invalid private gateways")
+	}
+	return gateways
+}
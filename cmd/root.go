@@ -146,9 +146,15 @@ func init() {
 	RootCmd.PersistentFlags().String("auth-token", "", "The JWT token to be used for the discovery server")
 
 	RootCmd.PersistentFlags().Int("health-port", 0, "The port number where the health server should be started")
+	RootCmd.PersistentFlags().String("admin-token", "", "The bearer token of operators for the admin actions on the health server. Leave empty to disable the admin actions")
+
+	RootCmd.PersistentFlags().String("grpc-compression", "", "Compress the gRPC messages that this component sends (gzip)")
+	RootCmd.PersistentFlags().Duration("request-timeout", 10*time.Second, "The timeout of requests to other components")
 
 	RootCmd.PersistentFlags().Duration("monitor-interval", 6*time.Second, "The interval between sending component statuses to the monitor servers")
 
+	RootCmd.PersistentFlags().Bool("salvage-storage", false, "Quarantine corrupted storage entries instead of failing on them")
+
 	viper.SetDefault("auth-servers", map[string]string{
 		"ttn-account-v2": "https://account.thethingsnetwork.org",
 	})
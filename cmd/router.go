@@ -6,13 +6,20 @@ package cmd
 import (
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/router"
+	"github.com/TheThingsNetwork/ttn/core/router/capture"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -44,11 +51,110 @@ var routerCmd = &cobra.Command{
 
 		// Router
 		router := router.NewRouter()
+
+		filter := routerForwardingFilter()
+		if len(filter.HomeBrokers) > 0 || len(filter.FrequencyPlans) > 0 || len(filter.NetIDs) > 0 {
+			ctx.WithFields(ttnlog.Fields{
+				"HomeBrokers":    filter.HomeBrokers,
+				"FrequencyPlans": filter.FrequencyPlans,
+				"NetIDs":         filter.NetIDs,
+			}).Info("Using forwarding filter")
+			router.WithForwardingFilter(filter)
+		}
+
+		if private := privateGateways("router"); len(private) > 0 {
+			ctx.WithField("Gateways", len(private)).Info("Using private gateways")
+			router.WithPrivateGateways(private)
+		}
+
+		if viper.GetBool("router.signal-filter") {
+			signalFilter := routerSignalFilter()
+			ctx.WithFields(ttnlog.Fields{
+				"MinSNR": signalFilter.MinSNR,
+				"Margin": signalFilter.Margin,
+			}).Info("Using signal filter")
+			router.WithSignalFilter(signalFilter)
+		}
+
+		if viper.GetBool("router.validate-metadata") {
+			validation := routerMetadataValidation()
+			ctx.WithField("MaxTimeOffset", validation.MaxTimeOffset).Info("Validating uplink metadata")
+			router.WithMetadataValidation(validation)
+		}
+
+		if path := viper.GetString("router.capture"); path != "" {
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not open capture file")
+			}
+			ctx.WithField("File", path).Info("Capturing gateway uplinks")
+			router.WithCapture(capture.NewWriter(file))
+		}
+
+		if caps := routerDownlinkPriorityCaps(); len(caps) > 0 {
+			ctx.WithField("Caps", viper.GetStringSlice("router.downlink-priority-caps")).Info("Limiting downlink priorities")
+			router.WithDownlinkPriorityCaps(caps)
+		}
+
+		if weights := routerAirtimeWeights(); len(weights) > 0 {
+			ctx.WithField("Weights", viper.GetStringSlice("router.airtime-weights")).Info("Sharing downlink airtime with weights")
+			router.WithAirtimeWeights(weights)
+		}
+
+		if routes := routerJoinEUIRoutes(); len(routes) > 0 {
+			ctx.WithField("Routes", viper.GetStringSlice("router.join-eui-routes")).Info("Routing join requests by JoinEUI")
+			router.WithJoinEUIRoutes(routes...)
+		}
+
+		if size := viper.GetInt("router.ingest-buffer"); size > 0 {
+			workers := viper.GetInt("router.ingest-workers")
+			ctx.WithFields(ttnlog.Fields{
+				"Size":    size,
+				"Workers": workers,
+			}).Info("Using ingest buffer")
+			router.WithIngestBuffer(size, workers)
+		}
+
+		if uploads := viper.GetInt("router.gateway-logs-max-uploads"); uploads > 0 {
+			retention := gateway.LogRetention{
+				MaxUploads:   uploads,
+				MaxSize:      viper.GetInt("router.gateway-logs-max-size"),
+				MaxAge:       viper.GetDuration("router.gateway-logs-max-age"),
+				MaxTotalSize: viper.GetInt("router.gateway-logs-max-total-size"),
+			}
+			ctx.WithFields(ttnlog.Fields{
+				"MaxUploads":   retention.MaxUploads,
+				"MaxSize":      retention.MaxSize,
+				"MaxAge":       retention.MaxAge,
+				"MaxTotalSize": retention.MaxTotalSize,
+			}).Info("Accepting gateway logs")
+			router.WithGatewayLogs(retention)
+		}
+
+		if gateways := viper.GetStringSlice("router.lbt-gateways"); len(gateways) > 0 {
+			ctx.WithField("Gateways", len(gateways)).Info("Only sending downlinks that require listen-before-talk through LBT gateways")
+			router.WithLBTGateways(gateways...)
+		}
+
+		if urls := viper.GetStringSlice("router.broker-blacklists"); len(urls) > 0 {
+			ctx.WithField("URLs", urls).Info("Dropping uplinks of DevAddrs on broker blacklists")
+			router.WithBrokerBlacklists(routerBrokerBlacklists())
+		}
+
+		if server := viper.GetString("router.chirpstack-bridge-server"); server != "" {
+			ctx.WithField("Server", server).Info("Connecting ChirpStack gateway bridges")
+			router.WithChirpStackBridge(routerChirpStackBridge())
+		}
+
 		err = router.Init(component)
 		if err != nil {
 			ctx.WithError(err).Fatal("Could not initialize router")
 		}
 
+		routerDryRun(router)
+		routerInventory(router)
+		routerGatewayLogs(router)
+
 		// gRPC Server
 		lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", viper.GetString("router.server-address"), viper.GetInt("router.server-port")))
 		if err != nil {
@@ -68,10 +174,110 @@ var routerCmd = &cobra.Command{
 		ctx.WithField("signal", <-sigChan).Info("signal received")
 
 		grpc.Stop()
+		component.Cancel()
 		router.Shutdown()
 	},
 }
 
+func routerChirpStackBridge() router.ChirpStackBridge {
+	return router.ChirpStackBridge{
+		Server:   viper.GetString("router.chirpstack-bridge-server"),
+		Username: viper.GetString("router.chirpstack-bridge-username"),
+		Password: viper.GetString("router.chirpstack-bridge-password"),
+	}
+}
+
+func routerBrokerBlacklists() router.BrokerBlacklists {
+	return router.BrokerBlacklists{
+		URLs:     viper.GetStringSlice("router.broker-blacklists"),
+		Interval: viper.GetDuration("router.broker-blacklist-interval"),
+	}
+}
+
+func routerForwardingFilter() (filter router.ForwardingFilter) {
+	filter.HomeBrokers = viper.GetStringSlice("router.home-brokers")
+	filter.FrequencyPlans = viper.GetStringSlice("router.frequency-plans")
+	for _, netIDStr := range viper.GetStringSlice("router.net-ids") {
+		var netID types.NetID
+		if err := netID.UnmarshalText([]byte(netIDStr)); err != nil {
+			ctx.WithError(err).WithField("NetID", netIDStr).Fatal("Invalid NetID")
+		}
+		filter.NetIDs = append(filter.NetIDs, netID)
+	}
+	return
+}
+
+func routerSignalFilter() router.SignalFilter {
+	return router.SignalFilter{
+		MinSNR: viper.GetFloat64("router.min-snr"),
+		Margin: viper.GetFloat64("router.snr-margin"),
+	}
+}
+
+func routerMetadataValidation() router.MetadataValidation {
+	return router.MetadataValidation{
+		MaxTimeOffset: viper.GetDuration("router.max-gateway-time-offset"),
+	}
+}
+
+func routerDownlinkPriorityCaps() map[string]gateway.Priority {
+	caps := make(map[string]gateway.Priority)
+	for _, capStr := range viper.GetStringSlice("router.downlink-priority-caps") {
+		parts := strings.SplitN(capStr, "=", 2)
+		if len(parts) != 2 {
+			ctx.WithField("Cap", capStr).Fatal("Downlink priority cap should be formatted as AppID=priority")
+		}
+		priority, err := gateway.ParsePriority(parts[1])
+		if err != nil {
+			ctx.WithError(err).WithField("Cap", capStr).Fatal("Invalid downlink priority cap")
+		}
+		caps[parts[0]] = priority
+	}
+	return caps
+}
+
+func routerAirtimeWeights() map[string]float64 {
+	weights := make(map[string]float64)
+	for _, weightStr := range viper.GetStringSlice("router.airtime-weights") {
+		parts := strings.SplitN(weightStr, "=", 2)
+		if len(parts) != 2 {
+			ctx.WithField("Weight", weightStr).Fatal("Airtime weight should be formatted as AppID=weight")
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight <= 0 {
+			ctx.WithField("Weight", weightStr).Fatal("Airtime weight should be a positive number")
+		}
+		weights[parts[0]] = weight
+	}
+	return weights
+}
+
+// routerDryRun serves the downlink dry run of the router on the health port to admins
+func routerDryRun(r router.Router) {
+	http.Handle(router.DryRunPath, component.RequireAdmin(router.NewDryRunHandler(r), "GET", "HEAD"))
+}
+
+// routerInventory serves the inventory of the packet forwarders of the gateways on the health port to admins
+func routerInventory(r router.Router) {
+	http.Handle(router.InventoryPath, component.RequireAdmin(router.NewInventoryHandler(r), "GET", "HEAD"))
+}
+
+// routerGatewayLogs serves the diagnostic uploads of gateways on the health port
+func routerGatewayLogs(r router.Router) {
+	http.Handle(router.GatewayLogsPath, router.NewGatewayLogsHandler(r))
+}
+
+func routerJoinEUIRoutes() (routes []router.JoinEUIRoute) {
+	for _, routeStr := range viper.GetStringSlice("router.join-eui-routes") {
+		route, err := router.ParseJoinEUIRoute(routeStr)
+		if err != nil {
+			ctx.WithError(err).WithField("Route", routeStr).Fatal("Invalid JoinEUI route")
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
 func init() {
 	RootCmd.AddCommand(routerCmd)
 	routerCmd.Flags().String("server-address", "0.0.0.0", "The IP address to listen for communication")
@@ -84,4 +290,66 @@ func init() {
 	viper.BindPFlag("router.server-port", routerCmd.Flags().Lookup("server-port"))
 	viper.BindPFlag("router.mqtt-address-announce", routerCmd.Flags().Lookup("mqtt-address-announce"))
 	viper.BindPFlag("router.skip-verify-gateway-token", routerCmd.Flags().Lookup("skip-verify-gateway-token"))
+
+	routerCmd.Flags().StringSlice("home-brokers", []string{}, "Only forward traffic to the Brokers with these IDs")
+	routerCmd.Flags().StringSlice("frequency-plans", []string{}, "Only forward traffic of gateways with these frequency plans")
+	routerCmd.Flags().StringSlice("net-ids", []string{}, "Only forward uplink traffic of devices with DevAddrs of these NetIDs")
+	viper.BindPFlag("router.home-brokers", routerCmd.Flags().Lookup("home-brokers"))
+	viper.BindPFlag("router.frequency-plans", routerCmd.Flags().Lookup("frequency-plans"))
+	viper.BindPFlag("router.net-ids", routerCmd.Flags().Lookup("net-ids"))
+
+	routerCmd.Flags().StringSlice("private-gateways", []string{}, "Only send the downlinks of these applications through these gateways (GatewayID=AppID). Give the same gateways to the Broker")
+	viper.BindPFlag("router.private-gateways", routerCmd.Flags().Lookup("private-gateways"))
+
+	routerCmd.Flags().StringSlice("join-eui-routes", []string{}, "Forward join requests with a JoinEUI in these ranges to these Brokers (first-last=BrokerID)")
+	viper.BindPFlag("router.join-eui-routes", routerCmd.Flags().Lookup("join-eui-routes"))
+
+	routerCmd.Flags().StringSlice("lbt-gateways", []string{}, "Gateways that listen before talk. If set, other gateways get no downlinks in frequency plans that require listen-before-talk")
+	viper.BindPFlag("router.lbt-gateways", routerCmd.Flags().Lookup("lbt-gateways"))
+
+	routerCmd.Flags().StringSlice("broker-blacklists", []string{}, "Drop the uplinks of DevAddrs on the blacklists of Brokers at these URLs (http://broker:port/blacklist on the health port)")
+	routerCmd.Flags().Duration("broker-blacklist-interval", time.Minute, "Interval at which the broker blacklists are fetched")
+	viper.BindPFlag("router.broker-blacklists", routerCmd.Flags().Lookup("broker-blacklists"))
+	viper.BindPFlag("router.broker-blacklist-interval", routerCmd.Flags().Lookup("broker-blacklist-interval"))
+
+	routerCmd.Flags().Bool("signal-filter", false, "Drop uplinks with a signal that is too weak to have been demodulated")
+	routerCmd.Flags().Float64("min-snr", -25, "Minimum SNR (in dB) of uplinks if the signal filter is enabled")
+	routerCmd.Flags().Float64("snr-margin", 2.5, "Margin (in dB) below the demodulation floor and the noise floor of gateways if the signal filter is enabled")
+	viper.BindPFlag("router.signal-filter", routerCmd.Flags().Lookup("signal-filter"))
+	viper.BindPFlag("router.min-snr", routerCmd.Flags().Lookup("min-snr"))
+	viper.BindPFlag("router.snr-margin", routerCmd.Flags().Lookup("snr-margin"))
+
+	routerCmd.Flags().Bool("validate-metadata", false, "Reject uplinks with an impossible frequency, data rate, coding rate, RSSI, SNR or time in their gateway metadata")
+	routerCmd.Flags().Duration("max-gateway-time-offset", 0, "Maximum difference between the gateway time and the server time if the metadata is validated (0 disables)")
+	viper.BindPFlag("router.validate-metadata", routerCmd.Flags().Lookup("validate-metadata"))
+	viper.BindPFlag("router.max-gateway-time-offset", routerCmd.Flags().Lookup("max-gateway-time-offset"))
+
+	routerCmd.Flags().String("capture", "", "Capture the uplinks of gateways to this file")
+	viper.BindPFlag("router.capture", routerCmd.Flags().Lookup("capture"))
+
+	routerCmd.Flags().StringSlice("downlink-priority-caps", []string{}, "Limit the downlink priority of applications (AppID=unconfirmed|confirmed|mac)")
+	viper.BindPFlag("router.downlink-priority-caps", routerCmd.Flags().Lookup("downlink-priority-caps"))
+	routerCmd.Flags().StringSlice("airtime-weights", []string{}, "Share the downlink airtime of gateways between applications with these weights (AppID=weight, default 1)")
+	viper.BindPFlag("router.airtime-weights", routerCmd.Flags().Lookup("airtime-weights"))
+
+	routerCmd.Flags().Int("ingest-buffer", 0, "Absorb bursts of uplinks in a buffer of this size, dropping the oldest uplinks when it is full (0 disables)")
+	routerCmd.Flags().Int("ingest-workers", 32, "Number of workers that handle the uplinks in the ingest buffer")
+	viper.BindPFlag("router.ingest-buffer", routerCmd.Flags().Lookup("ingest-buffer"))
+	viper.BindPFlag("router.ingest-workers", routerCmd.Flags().Lookup("ingest-workers"))
+
+	routerCmd.Flags().Int("gateway-logs-max-uploads", 0, "Accept diagnostic uploads of gateways and keep this many uploads per gateway (0 disables)")
+	routerCmd.Flags().Int("gateway-logs-max-size", 4<<20, "Maximum total size in bytes of the uploads of a gateway")
+	routerCmd.Flags().Duration("gateway-logs-max-age", 7*24*time.Hour, "Maximum age of the uploads of a gateway")
+	routerCmd.Flags().Int("gateway-logs-max-total-size", 256<<20, "Maximum total size in bytes of the uploads of all gateways")
+	viper.BindPFlag("router.gateway-logs-max-uploads", routerCmd.Flags().Lookup("gateway-logs-max-uploads"))
+	viper.BindPFlag("router.gateway-logs-max-size", routerCmd.Flags().Lookup("gateway-logs-max-size"))
+	viper.BindPFlag("router.gateway-logs-max-age", routerCmd.Flags().Lookup("gateway-logs-max-age"))
+	viper.BindPFlag("router.gateway-logs-max-total-size", routerCmd.Flags().Lookup("gateway-logs-max-total-size"))
+
+	routerCmd.Flags().String("chirpstack-bridge-server", "", "Connect the gateways of ChirpStack gateway bridges that publish to this MQTT server (tcp://host:port)")
+	routerCmd.Flags().String("chirpstack-bridge-username", "", "Username for the MQTT server of the ChirpStack gateway bridges")
+	routerCmd.Flags().String("chirpstack-bridge-password", "", "Password for the MQTT server of the ChirpStack gateway bridges")
+	viper.BindPFlag("router.chirpstack-bridge-server", routerCmd.Flags().Lookup("chirpstack-bridge-server"))
+	viper.BindPFlag("router.chirpstack-bridge-username", routerCmd.Flags().Lookup("chirpstack-bridge-username"))
+	viper.BindPFlag("router.chirpstack-bridge-password", routerCmd.Flags().Lookup("chirpstack-bridge-password"))
 }
@@ -0,0 +1,40 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"io/ioutil"
+
+	"github.com/TheThingsNetwork/ttn/api"
+	"github.com/TheThingsNetwork/ttn/core/secureelement"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+)
+
+// secureElement connects to the secure element service that is configured for the component, if any
+func secureElement(component string) secureelement.Service {
+	addr := viper.GetString(component + ".secure-element-address")
+	if addr == "" {
+		return nil
+	}
+	var conn *grpc.ClientConn
+	var err error
+	if certFile := viper.GetString(component + ".secure-element-cert"); certFile != "" {
+		contents, err := ioutil.ReadFile(certFile)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get secure element certificate")
+		}
+		conn, err = api.DialWithCert(addr, string(contents))
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not connect to secure element")
+		}
+	} else {
+		conn, err = api.Dial(addr)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not connect to secure element")
+		}
+	}
+	ctx.WithField("Address", addr).Info("Using secure element for MIC calculation")
+	return secureelement.NewClient(conn)
+}
@@ -0,0 +1,255 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/broker/tap"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/frame"
+	"github.com/spf13/cobra"
+)
+
+// sniffCmd represents the sniff command
+var sniffCmd = &cobra.Command{
+	Use:   "sniff",
+	Short: "Inspect the uplinks that are mirrored by a Broker tap",
+	Long: `ttn sniff prints the uplinks that a Broker mirrors to its tap in real time.
+
+Start the Broker with --tap udp://host:port and run ttn sniff with --listen on the
+same address, or use --file to read a file that was written with --tap file://path.
+The frames are decoded and printed with the metadata of all gateways that received
+them. Only the MAC commands in FOpts are decoded, as the FRMPayload is encrypted.`,
+	Example: `$ ttn sniff --listen 0.0.0.0:1910 --fport 1
+2017-06-30T14:00:00Z UnconfirmedDataUp DevAddr=26012345 FCnt=12 FPort=1 Payload=0102 DevEUI=0004A30B001B7AD2 AppID=test (2 gateways)
+    eui-0000024b08060112  868.1 MHz  RSSI  -57  SNR  9.0
+    eui-b827ebfffe87bd22  868.1 MHz  RSSI -109  SNR -4.2
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		filter, err := newSniffFilter(cmd)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid filter")
+		}
+
+		messages := make(chan *tap.Message)
+		if path, _ := cmd.Flags().GetString("file"); path != "" {
+			go sniffFile(path, messages)
+		} else {
+			listen, _ := cmd.Flags().GetString("listen")
+			go sniffUDP(listen, messages)
+		}
+
+		for msg := range messages {
+			f, err := frame.Decode(msg.Payload)
+			if err != nil {
+				ctx.WithError(err).Warn("Could not decode frame")
+				continue
+			}
+			if !filter.matches(msg, f) {
+				continue
+			}
+			printSniffed(msg, f)
+		}
+	},
+}
+
+func sniffUDP(addr string, messages chan<- *tap.Message) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		ctx.WithError(err).Fatal("Could not listen for tap messages")
+	}
+	ctx.WithField("Address", conn.LocalAddr()).Info("Listening for tap messages")
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read tap message")
+		}
+		msg := new(tap.Message)
+		if err := json.Unmarshal(buf[:n], msg); err != nil {
+			ctx.WithError(err).Warn("Could not unmarshal tap message")
+			continue
+		}
+		messages <- msg
+	}
+}
+
+func sniffFile(path string, messages chan<- *tap.Message) {
+	file, err := os.Open(path)
+	if err != nil {
+		ctx.WithError(err).Fatal("Could not open tap file")
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 65535), 1024*1024)
+	for scanner.Scan() {
+		msg := new(tap.Message)
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			ctx.WithError(err).Warn("Could not unmarshal tap message")
+			continue
+		}
+		messages <- msg
+	}
+	if err := scanner.Err(); err != nil {
+		ctx.WithError(err).Fatal("Could not read tap file")
+	}
+	close(messages)
+}
+
+type sniffFilter struct {
+	devAddr *types.DevAddr
+	devEUI  *types.DevEUI
+	appEUI  *types.AppEUI
+	appID   string
+	mTypes  []string
+	fPort   int
+}
+
+func newSniffFilter(cmd *cobra.Command) (*sniffFilter, error) {
+	filter := new(sniffFilter)
+	if str, _ := cmd.Flags().GetString("dev-addr"); str != "" {
+		devAddr, err := types.ParseDevAddr(str)
+		if err != nil {
+			return nil, err
+		}
+		filter.devAddr = &devAddr
+	}
+	if str, _ := cmd.Flags().GetString("dev-eui"); str != "" {
+		devEUI, err := types.ParseDevEUI(str)
+		if err != nil {
+			return nil, err
+		}
+		filter.devEUI = &devEUI
+	}
+	if str, _ := cmd.Flags().GetString("app-eui"); str != "" {
+		appEUI, err := types.ParseAppEUI(str)
+		if err != nil {
+			return nil, err
+		}
+		filter.appEUI = &appEUI
+	}
+	filter.appID, _ = cmd.Flags().GetString("app-id")
+	filter.mTypes, _ = cmd.Flags().GetStringSlice("mtype")
+	filter.fPort, _ = cmd.Flags().GetInt("fport")
+	return filter, nil
+}
+
+func (s *sniffFilter) matches(msg *tap.Message, f *frame.Frame) bool {
+	if s.devAddr != nil && (!f.IsData() || f.DevAddr != *s.devAddr) {
+		return false
+	}
+	if s.devEUI != nil {
+		switch {
+		case msg.DevEUI != nil && *msg.DevEUI == *s.devEUI:
+		case f.IsJoinRequest() && f.DevEUI == *s.devEUI:
+		default:
+			return false
+		}
+	}
+	if s.appEUI != nil {
+		switch {
+		case msg.AppEUI != nil && *msg.AppEUI == *s.appEUI:
+		case f.IsJoinRequest() && f.AppEUI == *s.appEUI:
+		default:
+			return false
+		}
+	}
+	if s.appID != "" && msg.AppID != s.appID {
+		return false
+	}
+	if len(s.mTypes) > 0 {
+		var found bool
+		for _, mType := range s.mTypes {
+			if strings.EqualFold(mType, f.MType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if s.fPort >= 0 && (f.FPort == nil || int(*f.FPort) != s.fPort) {
+		return false
+	}
+	return true
+}
+
+func printSniffed(msg *tap.Message, f *frame.Frame) {
+	parts := []string{time.Unix(0, msg.ServerTime).UTC().Format(time.RFC3339), f.MType}
+	if f.IsData() {
+		parts = append(parts, fmt.Sprintf("DevAddr=%s", f.DevAddr), fmt.Sprintf("FCnt=%d", f.FCnt))
+		if f.FPort != nil {
+			parts = append(parts, fmt.Sprintf("FPort=%d", *f.FPort))
+		}
+		if f.ADR {
+			parts = append(parts, "ADR")
+		}
+		if f.ADRAckReq {
+			parts = append(parts, "ADRAckReq")
+		}
+		if f.Ack {
+			parts = append(parts, "Ack")
+		}
+		if len(f.FOpts) > 0 {
+			commands := make([]string, 0, len(f.FOpts))
+			for _, command := range f.FOpts {
+				commands = append(commands, command.String())
+			}
+			parts = append(parts, fmt.Sprintf("FOpts=[%s]", strings.Join(commands, " ")))
+		}
+		if len(f.FRMPayload) > 0 {
+			parts = append(parts, fmt.Sprintf("Payload=%X", f.FRMPayload))
+		}
+	}
+	if f.IsJoinRequest() {
+		parts = append(parts, fmt.Sprintf("AppEUI=%s", f.AppEUI), fmt.Sprintf("DevEUI=%s", f.DevEUI), fmt.Sprintf("DevNonce=%s", f.DevNonce))
+	} else if msg.DevEUI != nil {
+		parts = append(parts, fmt.Sprintf("DevEUI=%s", msg.DevEUI))
+	}
+	if msg.AppID != "" {
+		parts = append(parts, fmt.Sprintf("AppID=%s", msg.AppID))
+	}
+	if msg.DevID != "" {
+		parts = append(parts, fmt.Sprintf("DevID=%s", msg.DevID))
+	}
+	if lorawan := msg.ProtocolMetadata.GetLoRaWAN(); lorawan != nil && lorawan.DataRate != "" {
+		parts = append(parts, lorawan.DataRate)
+	}
+	parts = append(parts, fmt.Sprintf("(%d gateways)", len(msg.GatewayMetadata)))
+	if msg.Suspicion != "" {
+		parts = append(parts, fmt.Sprintf("Suspicion=%s", msg.Suspicion))
+	}
+	if msg.RejectReason != "" {
+		parts = append(parts, fmt.Sprintf("Rejected (%s): %s", msg.RejectReason, msg.Error))
+	} else if msg.Error != "" {
+		parts = append(parts, fmt.Sprintf("Dropped: %s", msg.Error))
+	}
+	fmt.Println(strings.Join(parts, " "))
+
+	for _, gtw := range msg.GatewayMetadata {
+		fmt.Printf("    %s  %.1f MHz  RSSI %4.0f  SNR %4.1f\n", gtw.GatewayID, float64(gtw.Frequency)/1000000, gtw.RSSI, gtw.SNR)
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(sniffCmd)
+
+	sniffCmd.Flags().String("listen", "0.0.0.0:1910", "The UDP address to listen for tap messages")
+	sniffCmd.Flags().String("file", "", "Read tap messages from a file instead")
+
+	sniffCmd.Flags().String("dev-addr", "", "Only show frames of this DevAddr")
+	sniffCmd.Flags().String("dev-eui", "", "Only show frames of this DevEUI")
+	sniffCmd.Flags().String("app-eui", "", "Only show frames of this AppEUI")
+	sniffCmd.Flags().String("app-id", "", "Only show frames of this application")
+	sniffCmd.Flags().StringSlice("mtype", []string{}, "Only show frames of these MTypes")
+	sniffCmd.Flags().Int("fport", -1, "Only show frames with this FPort")
+}
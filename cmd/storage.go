@@ -0,0 +1,44 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// storageCmd represents the storage command
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage the storage of The Things Network components",
+	Long:  `ttn storage can be used to manage the databases of the Handler, Network Server and Discovery`,
+}
+
+// storageRedisClient connects to the Redis database that is configured for the storage commands
+func storageRedisClient() *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     viper.GetString("storage.redis-address"),
+		Password: viper.GetString("storage.redis-password"),
+		DB:       viper.GetInt("storage.redis-db"),
+	})
+	if err := connectRedis(client); err != nil {
+		ctx.WithError(err).Fatal("Could not initialize database connection")
+	}
+	return client
+}
+
+func init() {
+	RootCmd.AddCommand(storageCmd)
+
+	storageCmd.PersistentFlags().String("redis-address", "localhost:6379", "Redis host and port")
+	viper.BindPFlag("storage.redis-address", storageCmd.PersistentFlags().Lookup("redis-address"))
+	storageCmd.PersistentFlags().String("redis-password", "", "Redis password")
+	viper.BindPFlag("storage.redis-password", storageCmd.PersistentFlags().Lookup("redis-password"))
+	storageCmd.PersistentFlags().Int("redis-db", 0, "Redis database")
+	viper.BindPFlag("storage.redis-db", storageCmd.PersistentFlags().Lookup("redis-db"))
+
+	storageCmd.PersistentFlags().StringSlice("prefix", []string{"handler", "ns", "discovery"}, "Key prefixes of the components")
+	viper.BindPFlag("storage.prefix", storageCmd.PersistentFlags().Lookup("prefix"))
+}
@@ -0,0 +1,61 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// storageBackupCmd represents the storage backup command
+var storageBackupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "Write a snapshot of all data to a file",
+	Long: `ttn storage backup writes the registries, queues and counters of the components
+to a single snapshot file that can be restored with ttn storage restore.
+
+Stop the components before making a backup to get a consistent snapshot.`,
+	Example: `$ ttn storage backup ttn-backup.gz
+  INFO Wrote snapshot                           File=ttn-backup.gz Keys=1382
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		client := storageRedisClient()
+		defer client.Close()
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not create file")
+		}
+		defer file.Close()
+
+		keys, err := storage.WriteSnapshot(file, client, &storage.SnapshotHeader{
+			Version:    storage.SnapshotVersion,
+			TTNVersion: viper.GetString("version"),
+			CreatedAt:  time.Now().UTC(),
+			Prefixes:   viper.GetStringSlice("storage.prefix"),
+		})
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not write snapshot")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"File": args[0],
+			"Keys": keys,
+		}).Info("Wrote snapshot")
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageBackupCmd)
+}
@@ -0,0 +1,41 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// storageIndexAttributesCmd represents the storage index-attributes command
+var storageIndexAttributesCmd = &cobra.Command{
+	Use:   "index-attributes",
+	Short: "Add all devices of the Handler to the attribute index",
+	Long: `ttn storage index-attributes adds all devices of the Handler to the index that
+is used to search devices by attribute.
+
+The Handler keeps the index up to date when devices are changed, so this is only
+needed for devices that were stored before there was an attribute index.`,
+	Example: `$ ttn storage index-attributes
+  INFO Indexed device attributes                Devices=1337
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		client := storageRedisClient()
+		defer client.Close()
+
+		indexed, err := device.NewRedisDeviceStore(client, viper.GetString("storage.handler-prefix")).IndexAttributes()
+		if err != nil {
+			ctx.WithError(err).WithField("Devices", indexed).Fatal("Could not index device attributes")
+		}
+		ctx.WithField("Devices", indexed).Info("Indexed device attributes")
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageIndexAttributesCmd)
+
+	storageIndexAttributesCmd.Flags().String("handler-prefix", "handler", "Key prefix of the Handler")
+	viper.BindPFlag("storage.handler-prefix", storageIndexAttributesCmd.Flags().Lookup("handler-prefix"))
+}
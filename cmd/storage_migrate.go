@@ -0,0 +1,96 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/redis.v5"
+)
+
+// storageMigrateCmd represents the storage migrate command
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy all data to another Redis database",
+	Long: `ttn storage migrate copies all devices, applications, queues and announcements
+to another Redis database and verifies that every key was copied correctly.
+
+Stop the components before running the migration, so that no data is written
+to the source database while the keys are copied.`,
+	Example: `$ ttn storage migrate --redis-address old:6379 --to-redis-address new:6379
+  INFO Migrated keys                            Failed=0 Keys=1337 Prefix=handler
+  INFO Migrated keys                            Failed=0 Keys=42 Prefix=ns
+  INFO Migrated keys                            Failed=0 Keys=3 Prefix=discovery
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		from := storageRedisClient()
+		defer from.Close()
+
+		to := redis.NewClient(&redis.Options{
+			Addr:     viper.GetString("storage.to-redis-address"),
+			Password: viper.GetString("storage.to-redis-password"),
+			DB:       viper.GetInt("storage.to-redis-db"),
+		})
+		if err := connectRedis(to); err != nil {
+			ctx.WithError(err).Fatal("Could not connect to target database")
+		}
+		defer to.Close()
+
+		replace, _ := cmd.Flags().GetBool("replace")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		var failed int
+		for _, prefix := range viper.GetStringSlice("storage.prefix") {
+			var keys, prefixFailed int
+			err := storage.DumpRedis(from, prefix+":*", func(entry *storage.RedisEntry) error {
+				keys++
+				if dryRun {
+					return nil
+				}
+				ctx := ctx.WithField("Key", entry.Key)
+				if err := storage.RestoreRedis(to, entry, replace); err != nil {
+					ctx.WithError(err).Warn("Could not copy key")
+					prefixFailed++
+					return nil
+				}
+				if err := storage.VerifyRedis(to, entry); err != nil {
+					ctx.WithError(err).Warn("Could not verify key")
+					prefixFailed++
+				}
+				return nil
+			})
+			if err != nil {
+				ctx.WithError(err).WithField("Prefix", prefix).Fatal("Could not read keys")
+			}
+			ctx.WithFields(ttnlog.Fields{
+				"Prefix": prefix,
+				"Keys":   keys,
+				"Failed": prefixFailed,
+			}).Info("Migrated keys")
+			failed += prefixFailed
+		}
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageMigrateCmd)
+
+	storageMigrateCmd.Flags().String("to-redis-address", "localhost:6379", "Redis host and port of the target database")
+	viper.BindPFlag("storage.to-redis-address", storageMigrateCmd.Flags().Lookup("to-redis-address"))
+	storageMigrateCmd.Flags().String("to-redis-password", "", "Redis password of the target database")
+	viper.BindPFlag("storage.to-redis-password", storageMigrateCmd.Flags().Lookup("to-redis-password"))
+	storageMigrateCmd.Flags().Int("to-redis-db", 0, "Redis database of the target database")
+	viper.BindPFlag("storage.to-redis-db", storageMigrateCmd.Flags().Lookup("to-redis-db"))
+
+	storageMigrateCmd.Flags().Bool("replace", false, "Replace keys that already exist in the target database")
+	storageMigrateCmd.Flags().Bool("dry-run", false, "Count the keys without copying them")
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/spf13/cobra"
+)
+
+// storageQuarantineCmd represents the storage quarantine command
+var storageQuarantineCmd = &cobra.Command{
+	Use:   "quarantine [key ...]",
+	Short: "List or release the corrupted entries that were quarantined",
+	Long: `ttn storage quarantine lists the corrupted entries that components that run
+with --salvage-storage moved to the quarantine, with the reason that they could
+not be read.
+
+With --release, the entries with the given keys are restored. Repair the data
+that caused the corruption before releasing an entry, or it is quarantined again.`,
+	Example: `$ ttn storage quarantine
+  INFO Quarantined entry                        Error=strconv.ParseUint: parsing "": invalid syntax Key=ns:device:0102030405060708:0102030405060708 QuarantinedAt=2017-06-01 12:00:00 +0000 UTC
+$ ttn storage quarantine --release ns:device:0102030405060708:0102030405060708
+  INFO Released entry                           Key=ns:device:0102030405060708:0102030405060708
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		release, _ := cmd.Flags().GetBool("release")
+		replace, _ := cmd.Flags().GetBool("replace")
+
+		client := storageRedisClient()
+		defer client.Close()
+
+		if !release {
+			entries, err := storage.ListQuarantine(client)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not list quarantined entries")
+			}
+			for _, entry := range entries {
+				ctx.WithFields(ttnlog.Fields{
+					"Key":           entry.Key,
+					"Error":         entry.Error,
+					"QuarantinedAt": entry.QuarantinedAt,
+				}).Info("Quarantined entry")
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		var failed int
+		for _, key := range args {
+			ctx := ctx.WithField("Key", key)
+			if err := storage.ReleaseQuarantine(client, key, replace); err != nil {
+				ctx.WithError(err).Warn("Could not release entry")
+				failed++
+				continue
+			}
+			ctx.Info("Released entry")
+		}
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageQuarantineCmd)
+
+	storageQuarantineCmd.Flags().Bool("release", false, "Restore the quarantined entries with the given keys")
+	storageQuarantineCmd.Flags().Bool("replace", false, "Replace entries that were created again since their quarantine")
+}
@@ -0,0 +1,98 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/spf13/cobra"
+)
+
+// storageRestoreCmd represents the storage restore command
+var storageRestoreCmd = &cobra.Command{
+	Use:   "restore [file]",
+	Short: "Restore a snapshot that was written with ttn storage backup",
+	Long: `ttn storage restore restores the keys in a snapshot file.
+
+The snapshot is validated before any key is restored. Keys that already exist
+are not overwritten, unless --replace is set.`,
+	Example: `$ ttn storage restore ttn-backup.gz
+  INFO Validated snapshot                       CreatedAt=2017-06-01 12:00:00 +0000 UTC Keys=1382 TTNVersion=v2.6.0
+  INFO Restored snapshot                        Failed=0 Keys=1382
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.UsageFunc()(cmd)
+			return
+		}
+
+		replace, _ := cmd.Flags().GetBool("replace")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open file")
+		}
+		defer file.Close()
+
+		// Read the entire snapshot once to validate it
+		var keys int
+		header, err := storage.ReadSnapshot(file, func(_ *storage.RedisEntry) error {
+			keys++
+			return nil
+		})
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid snapshot")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"CreatedAt":  header.CreatedAt,
+			"TTNVersion": header.TTNVersion,
+			"Keys":       keys,
+		}).Info("Validated snapshot")
+
+		if dryRun {
+			return
+		}
+
+		if _, err := file.Seek(0, 0); err != nil {
+			ctx.WithError(err).Fatal("Could not read file")
+		}
+
+		client := storageRedisClient()
+		defer client.Close()
+
+		var restored, failed int
+		_, err = storage.ReadSnapshot(file, func(entry *storage.RedisEntry) error {
+			if err := storage.RestoreRedis(client, entry, replace); err != nil {
+				ctx.WithError(err).WithField("Key", entry.Key).Warn("Could not restore key")
+				failed++
+				return nil
+			}
+			restored++
+			return nil
+		})
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not restore snapshot")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"Keys":   restored,
+			"Failed": failed,
+		}).Info("Restored snapshot")
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	storageCmd.AddCommand(storageRestoreCmd)
+
+	storageRestoreCmd.Flags().Bool("replace", false, "Replace keys that already exist")
+	storageRestoreCmd.Flags().Bool("dry-run", false, "Validate the snapshot without restoring it")
+}
@@ -4,6 +4,9 @@
 package band
 
 import (
+	"fmt"
+	"time"
+
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
@@ -14,8 +17,33 @@ import (
 // FrequencyPlan includes band configuration and CFList
 type FrequencyPlan struct {
 	lora.Band
-	ADR    *ADRConfig
-	CFList *lorawan.CFList
+	ADR      *ADRConfig
+	CFList   *lorawan.CFList
+	TxParams *TxParams
+
+	MaxEIRP  int        // Maximum EIRP of downlinks in dBm
+	SubBands []SubBand  // Sub-bands with another maximum EIRP
+	LBT      *LBTConfig // Listen-before-talk that gateways have to do before downlinks, nil if not required
+}
+
+// SubBand is a frequency range with its own maximum EIRP
+type SubBand struct {
+	MinFrequency uint64
+	MaxFrequency uint64
+	MaxEIRP      int // dBm
+}
+
+// LBTConfig is the listen-before-talk that gateways have to do before they transmit
+type LBTConfig struct {
+	RSSITarget int           // The channel is free if the RSSI is below this target (in dBm)
+	ScanTime   time.Duration // The time that the channel has to be free before the transmission
+}
+
+// TxParams are the transmit parameters that the NetworkServer sends to devices with TxParamSetupReq
+type TxParams struct {
+	UplinkDwellTime   bool // Limit the dwell time of uplinks to 400ms
+	DownlinkDwellTime bool // Limit the dwell time of downlinks to 400ms
+	MaxEIRP           int  // Maximum EIRP in dBm
 }
 
 func (f *FrequencyPlan) GetDataRateStringForIndex(drIdx int) (string, error) {
@@ -43,6 +71,75 @@ func (f *FrequencyPlan) GetTxPowerIndexFor(txPower int) (int, error) {
 	return 0, errors.New("core/band: the given tx-power does not exist")
 }
 
+// GetMaxEIRP returns the maximum EIRP in dBm of downlinks on the frequency, or zero if it is not limited
+func (f *FrequencyPlan) GetMaxEIRP(frequency uint64) int {
+	for _, subBand := range f.SubBands {
+		if frequency >= subBand.MinFrequency && frequency < subBand.MaxFrequency {
+			return subBand.MaxEIRP
+		}
+	}
+	return f.MaxEIRP
+}
+
+// ValidateRX1DROffset returns an error if the RX1 data rate offset can not be used with all uplink data rates of the frequency plan
+func (f *FrequencyPlan) ValidateRX1DROffset(offset int) error {
+	for _, channel := range f.UplinkChannels {
+		for _, dr := range channel.DataRates {
+			if _, err := f.GetRX1DataRate(dr, offset); err != nil {
+				return errors.NewErrInvalidArgument("RX1DROffset", fmt.Sprintf("%d is not valid in this frequency plan", offset))
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateRX2DataRate returns an error if the data rate index can not be used in RX2 of the frequency plan
+func (f *FrequencyPlan) ValidateRX2DataRate(drIdx int) error {
+	if drIdx < 0 || drIdx >= len(f.DataRates) {
+		return errors.NewErrInvalidArgument("RX2DataRate", fmt.Sprintf("%d is not valid in this frequency plan", drIdx))
+	}
+	if _, err := types.ConvertDataRate(f.DataRates[drIdx]); err != nil {
+		return errors.NewErrInvalidArgument("RX2DataRate", fmt.Sprintf("%d is not a LoRa data rate", drIdx))
+	}
+	return nil
+}
+
+// GetMaxPayloadSizeFor returns the maximum size of the FRMPayload and FOpts at the given data rate, taking
+// the dwell time of the frequency plan into account
+func (f *FrequencyPlan) GetMaxPayloadSizeFor(dataRate string) (int, error) {
+	drIdx, err := f.GetDataRateIndexFor(dataRate)
+	if err != nil {
+		return 0, err
+	}
+	if drIdx >= len(f.MaxPayloadSize) {
+		return 0, errors.NewErrInvalidArgument("DataRate", fmt.Sprintf("%s has no maximum payload size", dataRate))
+	}
+	return f.MaxPayloadSize[drIdx].N, nil
+}
+
+// GetSubBandChannels returns the uplink channels of the sub-band that contains the frequency: eight
+// 125 kHz channels and one 500 kHz channel. Only frequency plans with 72 fixed channels have sub-bands.
+func (f *FrequencyPlan) GetSubBandChannels(frequency int) ([]int, error) {
+	if len(f.UplinkChannels) != 72 {
+		return nil, errors.NewErrInvalidArgument("Frequency Plan", "does not have sub-bands")
+	}
+	for i, channel := range f.UplinkChannels {
+		if channel.Frequency != frequency {
+			continue
+		}
+		subBand := i / 8
+		if i >= 64 {
+			subBand = i - 64
+		}
+		channels := make([]int, 0, 9)
+		for c := subBand * 8; c < (subBand+1)*8; c++ {
+			channels = append(channels, c)
+		}
+		return append(channels, 64+subBand), nil
+	}
+	return nil, errors.NewErrInvalidArgument("Frequency", fmt.Sprintf("%d is not an uplink channel", frequency))
+}
+
 // Guess the region based on frequency
 func Guess(frequency uint64) string {
 	// Join frequencies
@@ -71,6 +168,8 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 	switch region {
 	case pb_lorawan.FrequencyPlan_EU_863_870.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.EU_863_870, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 16
+		frequencyPlan.SubBands = []SubBand{{MinFrequency: 869400000, MaxFrequency: 869650000, MaxEIRP: 27}}
 		// TTN uses SF9BW125 in RX2
 		frequencyPlan.RX2DataRate = 3
 		// TTN frequency plan includes extra channels next to the default channels:
@@ -90,6 +189,7 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 		frequencyPlan.ADR = &ADRConfig{MinDataRate: 0, MaxDataRate: 5, MinTXPower: 2, MaxTXPower: 14, StepTXPower: 3}
 	case pb_lorawan.FrequencyPlan_US_902_928.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.US_902_928, false, lorawan.DwellTime400ms)
+		frequencyPlan.MaxEIRP = 30
 		fsb := 1 // Enable 903.9-905.3/200 kHz, 904.6/500kHz channels
 		for channel := 0; channel < 72; channel++ {
 			if (channel < fsb*8 || channel >= (fsb+1)*8) && channel != fsb+64 {
@@ -99,10 +199,13 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 		frequencyPlan.ADR = &ADRConfig{MinDataRate: 0, MaxDataRate: 3, MinTXPower: 10, MaxTXPower: 20, StepTXPower: 2}
 	case pb_lorawan.FrequencyPlan_CN_779_787.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.CN_779_787, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 12
 	case pb_lorawan.FrequencyPlan_EU_433.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.EU_433, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 12
 	case pb_lorawan.FrequencyPlan_AU_915_928.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.AU_915_928, false, lorawan.DwellTime400ms)
+		frequencyPlan.MaxEIRP = 30
 		fsb := 1 // Enable 916.8-918.2/200 kHz, 917.5/500kHz channels
 		for channel := 0; channel < 72; channel++ {
 			if (channel < fsb*8 || channel >= (fsb+1)*8) && channel != fsb+64 {
@@ -110,12 +213,19 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 			}
 		}
 		frequencyPlan.ADR = &ADRConfig{MinDataRate: 0, MaxDataRate: 3, MinTXPower: 10, MaxTXPower: 20, StepTXPower: 2}
+		frequencyPlan.TxParams = &TxParams{UplinkDwellTime: true, MaxEIRP: 30}
 	case pb_lorawan.FrequencyPlan_CN_470_510.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.CN_470_510, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 19
 	case pb_lorawan.FrequencyPlan_AS_923.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.AS_923, false, lorawan.DwellTime400ms)
+		frequencyPlan.MaxEIRP = 16
+		frequencyPlan.TxParams = &TxParams{UplinkDwellTime: true, DownlinkDwellTime: true, MaxEIRP: 16}
 	case pb_lorawan.FrequencyPlan_AS_920_923.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.AS_923, false, lorawan.DwellTime400ms)
+		frequencyPlan.MaxEIRP = 16
+		frequencyPlan.LBT = &LBTConfig{RSSITarget: -80, ScanTime: 5 * time.Millisecond} // Required in Japan
+		frequencyPlan.TxParams = &TxParams{UplinkDwellTime: true, DownlinkDwellTime: true, MaxEIRP: 16}
 		frequencyPlan.UplinkChannels = []lora.Channel{
 			lora.Channel{Frequency: 923200000, DataRates: []int{0, 1, 2, 3, 4, 5}},
 			lora.Channel{Frequency: 923400000, DataRates: []int{0, 1, 2, 3, 4, 5}},
@@ -132,6 +242,8 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 		frequencyPlan.CFList = &lorawan.CFList{922200000, 922400000, 922600000, 922800000, 923000000}
 	case pb_lorawan.FrequencyPlan_AS_923_925.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.AS_923, false, lorawan.DwellTime400ms)
+		frequencyPlan.MaxEIRP = 16
+		frequencyPlan.TxParams = &TxParams{UplinkDwellTime: true, DownlinkDwellTime: true, MaxEIRP: 16}
 		frequencyPlan.UplinkChannels = []lora.Channel{
 			lora.Channel{Frequency: 923200000, DataRates: []int{0, 1, 2, 3, 4, 5}},
 			lora.Channel{Frequency: 923400000, DataRates: []int{0, 1, 2, 3, 4, 5}},
@@ -148,6 +260,8 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 		frequencyPlan.CFList = &lorawan.CFList{923600000, 923800000, 924000000, 924200000, 924400000}
 	case pb_lorawan.FrequencyPlan_KR_920_923.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.KR_920_923, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 23
+		frequencyPlan.LBT = &LBTConfig{RSSITarget: -65, ScanTime: 5 * time.Millisecond} // Required in Korea
 		// TTN frequency plan includes extra channels next to the default channels:
 		frequencyPlan.UplinkChannels = []lora.Channel{
 			lora.Channel{Frequency: 922100000, DataRates: []int{0, 1, 2, 3, 4, 5}},
@@ -162,6 +276,7 @@ func Get(region string) (frequencyPlan FrequencyPlan, err error) {
 		frequencyPlan.CFList = &lorawan.CFList{922700000, 922900000, 923100000, 923300000, 0}
 	case pb_lorawan.FrequencyPlan_IN_865_867.String():
 		frequencyPlan.Band, err = lora.GetConfig(lora.IN_865_867, false, lorawan.DwellTimeNoLimit)
+		frequencyPlan.MaxEIRP = 30
 	default:
 		err = errors.NewErrInvalidArgument("Frequency Band", "unknown")
 	}
@@ -136,3 +136,78 @@ func TestGetTxPower(t *testing.T) {
 		a.So(idx, ShouldEqual, expIdx)
 	}
 }
+
+func TestGetMaxEIRP(t *testing.T) {
+	a := New(t)
+
+	eu, _ := Get("EU_863_870")
+	a.So(eu.GetMaxEIRP(868100000), ShouldEqual, 16)
+	a.So(eu.GetMaxEIRP(869525000), ShouldEqual, 27)
+	a.So(eu.LBT, ShouldBeNil)
+
+	kr, _ := Get("KR_920_923")
+	a.So(kr.GetMaxEIRP(922100000), ShouldEqual, 23)
+	a.So(kr.LBT, ShouldNotBeNil)
+
+	jp, _ := Get("AS_920_923")
+	a.So(jp.LBT, ShouldNotBeNil)
+	a.So(jp.LBT.RSSITarget, ShouldEqual, -80)
+}
+
+func TestValidateRXSettings(t *testing.T) {
+	a := New(t)
+
+	eu, _ := Get("EU_863_870")
+	a.So(eu.ValidateRX1DROffset(0), ShouldBeNil)
+	a.So(eu.ValidateRX1DROffset(5), ShouldBeNil)
+	a.So(eu.ValidateRX1DROffset(6), ShouldNotBeNil)
+	a.So(eu.ValidateRX2DataRate(3), ShouldBeNil)
+	a.So(eu.ValidateRX2DataRate(7), ShouldNotBeNil) // FSK
+	a.So(eu.ValidateRX2DataRate(16), ShouldNotBeNil)
+
+	us, _ := Get("US_902_928")
+	a.So(us.ValidateRX1DROffset(3), ShouldBeNil)
+	a.So(us.ValidateRX1DROffset(4), ShouldNotBeNil)
+	a.So(us.ValidateRX2DataRate(8), ShouldBeNil)
+}
+
+func TestGetSubBandChannels(t *testing.T) {
+	a := New(t)
+
+	us, _ := Get("US_902_928")
+	channels, err := us.GetSubBandChannels(903900000)
+	a.So(err, ShouldBeNil)
+	a.So(channels, ShouldResemble, []int{8, 9, 10, 11, 12, 13, 14, 15, 65})
+	channels, err = us.GetSubBandChannels(902300000)
+	a.So(err, ShouldBeNil)
+	a.So(channels, ShouldResemble, []int{0, 1, 2, 3, 4, 5, 6, 7, 64})
+	channels, err = us.GetSubBandChannels(904600000) // 500 kHz channel
+	a.So(err, ShouldBeNil)
+	a.So(channels, ShouldResemble, []int{8, 9, 10, 11, 12, 13, 14, 15, 65})
+	_, err = us.GetSubBandChannels(868100000)
+	a.So(err, ShouldNotBeNil)
+
+	eu, _ := Get("EU_863_870")
+	_, err = eu.GetSubBandChannels(868100000)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestGetMaxPayloadSize(t *testing.T) {
+	a := New(t)
+
+	eu, _ := Get("EU_863_870")
+	size, err := eu.GetMaxPayloadSizeFor("SF12BW125")
+	a.So(err, ShouldBeNil)
+	a.So(size, ShouldEqual, 51)
+	a.So(eu.TxParams, ShouldBeNil)
+
+	as, _ := Get("AS_923")
+	size, err = as.GetMaxPayloadSizeFor("SF10BW125") // limited by the 400ms dwell time
+	a.So(err, ShouldBeNil)
+	a.So(size, ShouldEqual, 11)
+	a.So(as.TxParams, ShouldNotBeNil)
+	a.So(as.TxParams.DownlinkDwellTime, ShouldBeTrue)
+
+	_, err = eu.GetMaxPayloadSizeFor("SF13BW125")
+	a.So(err, ShouldNotBeNil)
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/TheThingsNetwork/api/logfields"
 	"github.com/TheThingsNetwork/api/trace"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/api/idempotency"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/brocaar/lorawan"
 )
@@ -63,6 +64,13 @@ func (b *broker) HandleActivation(activation *pb.DeviceActivationRequest) (res *
 
 	b.status.activationsUnique.Mark(1)
 
+	if b.joinLimiter != nil {
+		if reason := b.joinLimiter.Allow(duplicates[0].AppEUI, duplicates[0].DevEUI); reason != "" {
+			deferredActivationsCounter.WithLabelValues(reason).Inc()
+			return nil, errors.NewErrFailedPrecondition(fmt.Sprintf("Activation deferred (%s)", reason))
+		}
+	}
+
 	deduplicatedActivationRequest.Payload = duplicates[0].Payload
 	deduplicatedActivationRequest.DevEUI = duplicates[0].DevEUI
 	deduplicatedActivationRequest.AppEUI = duplicates[0].AppEUI
@@ -78,21 +86,27 @@ func (b *broker) HandleActivation(activation *pb.DeviceActivationRequest) (res *
 	}
 
 	// Collect GatewayMetadata and DownlinkOptions
-	var downlinkOptions []*pb.DownlinkOption
+	var downlinkCandidates []downlinkCandidate
 	for _, duplicate := range duplicates {
 		deduplicatedActivationRequest.GatewayMetadata = append(deduplicatedActivationRequest.GatewayMetadata, &duplicate.GatewayMetadata)
-		downlinkOptions = append(downlinkOptions, duplicate.DownlinkOptions...)
+		downlinkCandidates = appendDownlinkCandidates(downlinkCandidates, start, duplicate.GatewayMetadata.Timestamp, duplicate.DownlinkOptions)
 	}
 
-	// Select best DownlinkOption
-	if len(downlinkOptions) > 0 {
-		deduplicatedActivationRequest.ResponseTemplate = &pb.DeviceActivationResponse{
-			DownlinkOption: selectBestDownlink(downlinkOptions),
+	// Select best DownlinkOption that can still make its deadline
+	if len(downlinkCandidates) > 0 {
+		if option := selectDownlink("deduplicate", downlinkCandidates); option != nil {
+			deduplicatedActivationRequest.ResponseTemplate = &pb.DeviceActivationResponse{
+				DownlinkOption: option,
+			}
+		} else {
+			ctx.Warn("Deadline exceeded for all downlink options")
 		}
 	}
 
 	// Send Activate to NS
-	deduplicatedActivationRequest, err = b.ns.PrepareActivation(b.Component.GetContext(b.nsToken), deduplicatedActivationRequest)
+	reqCtx, cancel := b.Component.GetRequestContext(b.nsToken)
+	deduplicatedActivationRequest, err = b.ns.PrepareActivation(reqCtx, deduplicatedActivationRequest)
+	cancel()
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "NetworkServer refused to prepare activation")
 	}
@@ -102,6 +116,15 @@ func (b *broker) HandleActivation(activation *pb.DeviceActivationRequest) (res *
 		"DevID": deduplicatedActivationRequest.DevID,
 	})
 
+	// Private gateways only forward the activations of the applications of their owner. The DownlinkOption is
+	// replaced by one of the remaining candidates after the challenge.
+	if b.privateGateways != nil {
+		deduplicatedActivationRequest.GatewayMetadata, downlinkCandidates = b.filterPrivateActivation(deduplicatedActivationRequest.AppID, deduplicatedActivationRequest.GatewayMetadata, downlinkCandidates)
+		if len(deduplicatedActivationRequest.GatewayMetadata) == 0 {
+			return nil, errors.NewErrPermissionDenied(fmt.Sprintf("Activation only received by private gateways of other owners than %s", deduplicatedActivationRequest.AppID))
+		}
+	}
+
 	// Find Handler (based on AppEUI)
 	var announcements []*pb_discovery.Announcement
 	announcements, err = b.Discovery.GetAllHandlersForAppID(deduplicatedActivationRequest.AppID)
@@ -150,7 +173,9 @@ func (b *broker) HandleActivation(activation *pb.DeviceActivationRequest) (res *
 		// Do async request
 		wg.Add(1)
 		go func(announcement *pb_discovery.Announcement) {
-			res, err := client.ActivationChallenge(b.Component.GetContext(""), challenge)
+			reqCtx, cancel := b.Component.GetRequestContext("")
+			defer cancel()
+			res, err := client.ActivationChallenge(reqCtx, challenge)
 			if err == nil && res != nil {
 				responses <- &challengeResponseWithHandler{
 					handler:  announcement,
@@ -193,25 +218,62 @@ func (b *broker) HandleActivation(activation *pb.DeviceActivationRequest) (res *
 	// Activation not accepted by any broker
 	if !gotFirst {
 		ctx.Debug("Activation not accepted by any Handler")
+		b.quarantineActivation(ctx, deduplicatedActivationRequest)
 		return nil, errors.New("Activation not accepted by any Handler")
 	}
 
+	// Fall back to another DownlinkOption if the activation challenges took too long
+	if template := deduplicatedActivationRequest.ResponseTemplate; template != nil && template.DownlinkOption != nil {
+		template.DownlinkOption = checkDownlink("challenge", template.DownlinkOption, downlinkCandidates)
+		if template.DownlinkOption == nil {
+			return nil, errors.New("Deadline exceeded for all downlink options")
+		}
+	}
+
 	ctx.WithField("HandlerID", joinHandler.ID).Debug("Forward Activation")
 	deduplicatedActivationRequest.Trace = deduplicatedActivationRequest.Trace.WithEvent(trace.ForwardEvent,
 		"handler", joinHandler.ID,
 	)
 
-	handlerResponse, err := joinHandlerClient.Activate(b.Component.GetContext(""), deduplicatedActivationRequest)
+	// The Handler and NetworkServer handle the activation only once if it is sent again
+	var handlerResponse *pb_handler.DeviceActivationResponse
+	err = idempotency.Retry(func(key string) (err error) {
+		reqCtx, cancel := b.Component.GetRequestContext("")
+		defer cancel()
+		handlerResponse, err = joinHandlerClient.Activate(idempotency.OutgoingContextWithKey(reqCtx, key), deduplicatedActivationRequest)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "Handler refused activation")
 	}
 
 	handlerResponse.Trace = handlerResponse.Trace.WithEvent(trace.ReceiveEvent)
 
-	handlerResponse, err = b.ns.Activate(b.Component.GetContext(b.nsToken), handlerResponse)
+	var nsResponse *pb_handler.DeviceActivationResponse
+	err = idempotency.Retry(func(key string) error {
+		reqCtx, cancel := b.Component.GetRequestContext(b.nsToken)
+		defer cancel()
+		res, err := b.ns.Activate(idempotency.OutgoingContextWithKey(reqCtx, key), handlerResponse)
+		if err != nil {
+			return err
+		}
+		nsResponse = res
+		return nil
+	})
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "NetworkServer refused activation")
 	}
+	handlerResponse = nsResponse
+
+	// The join request was authenticated by the Handler, so the backoff of the device starts
+	if b.joinLimiter != nil {
+		b.joinLimiter.Joined(deduplicatedActivationRequest.AppEUI, deduplicatedActivationRequest.DevEUI)
+	}
+
+	// The DevAddr is now in use by a device with a session
+	if lorawan := handlerResponse.ActivationMetadata.GetLoRaWAN(); b.blacklist != nil && lorawan != nil && lorawan.DevAddr != nil {
+		b.blacklist.Succeed(*lorawan.DevAddr)
+	}
 
 	handlerResponse.Trace = handlerResponse.Trace.WithEvent(trace.ForwardEvent)
 
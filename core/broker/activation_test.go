@@ -12,7 +12,9 @@ import (
 	pb_discovery "github.com/TheThingsNetwork/api/discovery"
 	"github.com/TheThingsNetwork/api/gateway"
 	"github.com/TheThingsNetwork/api/protocol"
+	"github.com/TheThingsNetwork/ttn/core/broker/joinlimit"
 	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/golang/mock/gomock"
 	. "github.com/smartystreets/assertions"
 )
@@ -53,6 +55,32 @@ func TestHandleActivation(t *testing.T) {
 	// TODO: Integration test with Handler
 }
 
+func TestHandleActivationWithJoinLimiter(t *testing.T) {
+	a := New(t)
+
+	devEUI := types.DevEUI([8]byte{0, 1, 2, 3, 4, 5, 6, 7})
+	appEUI := types.AppEUI([8]byte{0, 1, 2, 3, 4, 5, 6, 7})
+
+	b := getTestBroker(t)
+	b.WithJoinLimiter(joinlimit.NewLimiter(0, time.Minute, time.Hour))
+	b.joinLimiter.Joined(appEUI, devEUI) // the device just joined
+
+	// The NetworkServer is not called
+	res, err := b.HandleActivation(&pb_broker.DeviceActivationRequest{
+		Payload:          []byte{},
+		DevEUI:           devEUI,
+		AppEUI:           appEUI,
+		GatewayMetadata:  gateway.RxMetadata{SNR: 1.2, GatewayID: "eui-0102030405060708"},
+		ProtocolMetadata: protocol.RxMetadata{},
+	})
+	a.So(err, ShouldNotBeNil)
+	a.So(errors.IsFailedPrecondition(err), ShouldBeTrue)
+	a.So(err.Error(), ShouldContainSubstring, joinlimit.DeferDeviceBackoff)
+	a.So(res, ShouldBeNil)
+
+	b.ctrl.Finish()
+}
+
 func TestDeduplicateActivation(t *testing.T) {
 	a := New(t)
 
@@ -0,0 +1,44 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"math"
+
+	"github.com/TheThingsNetwork/api/networkserver"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// WithBlacklist drops the uplinks of DevAddrs that are blacklisted because their uplinks keep
+// coming in while no device has the DevAddr, before asking the NetworkServer for devices and checking the MIC
+func (b *broker) WithBlacklist(bl *blacklist.Blacklist) Broker {
+	b.blacklist = bl
+	return b
+}
+
+// hasDevices returns true if the NetworkServer has a device with the DevAddr, regardless of its frame counter
+func (b *broker) hasDevices(devAddr types.DevAddr) bool {
+	reqCtx, cancel := b.Component.GetRequestContext(b.nsToken)
+	defer cancel()
+	res, err := b.ns.GetDevices(reqCtx, &networkserver.DevicesRequest{
+		DevAddr: devAddr,
+		FCnt:    math.MaxUint32,
+	})
+	if err != nil {
+		return true // Rather not blacklist than block a device
+	}
+	return len(res.Results) > 0
+}
+
+func (b *broker) blacklistFailure(ctx ttnlog.Interface, devAddr types.DevAddr, reason string) {
+	if b.blacklist == nil {
+		return
+	}
+	if b.blacklist.Fail(devAddr, reason) {
+		blacklistedCounter.WithLabelValues(reason).Inc()
+		ctx.WithField("Reason", reason).Warn("Blacklisted DevAddr")
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package blacklist temporarily blocks the DevAddrs of devices whose uplinks keep failing validation
+package blacklist
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Path is the path where the blacklist is served on the status server
+const Path = "/blacklist"
+
+// MaxTracked is the maximum number of DevAddrs of which failures are counted, and of DevAddrs of which valid
+// uplinks are remembered. When more DevAddrs fail, the DevAddrs of which the failures are outside the window are
+// forgotten. When more DevAddrs are valid, the DevAddr of which the last valid uplink is the oldest is forgotten.
+var MaxTracked = 100000
+
+// Entry is a blocked DevAddr
+type Entry struct {
+	DevAddr  types.DevAddr `json:"dev_addr"`
+	Reason   string        `json:"reason"` // reason of the last failure
+	Failures int           `json:"failures"`
+	Since    time.Time     `json:"since"`
+	Until    time.Time     `json:"until"`
+}
+
+type failures struct {
+	count  int
+	since  time.Time
+	reason string
+}
+
+// Blacklist blocks a DevAddr for the TTL when its uplinks fail validation threshold times within the window.
+// Anyone can send uplinks with any DevAddr, so DevAddrs that had a valid uplink or an activation are never blocked;
+// the blacklist only blocks DevAddrs of devices that never got through, such as devices that are not registered.
+type Blacklist struct {
+	mu         sync.Mutex
+	threshold  int
+	window     time.Duration
+	ttl        time.Duration
+	failures   map[types.DevAddr]*failures
+	valid      map[types.DevAddr]*list.Element
+	validOrder *list.List // DevAddrs with a valid uplink, the least recently valid first
+	blocked    map[types.DevAddr]*Entry
+	allowed    map[types.DevAddr]bool
+}
+
+// NewBlacklist returns a new Blacklist
+func NewBlacklist(threshold int, window, ttl time.Duration) *Blacklist {
+	return &Blacklist{
+		threshold:  threshold,
+		window:     window,
+		ttl:        ttl,
+		failures:   make(map[types.DevAddr]*failures),
+		valid:      make(map[types.DevAddr]*list.Element),
+		validOrder: list.New(),
+		blocked:    make(map[types.DevAddr]*Entry),
+		allowed:    make(map[types.DevAddr]bool),
+	}
+}
+
+// Allow makes sure that the DevAddrs are never blocked
+func (b *Blacklist) Allow(devAddrs ...types.DevAddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, devAddr := range devAddrs {
+		b.allowed[devAddr] = true
+		delete(b.blocked, devAddr)
+		delete(b.failures, devAddr)
+	}
+}
+
+// Fail records an uplink of the DevAddr that failed validation. It returns true if the DevAddr is blocked as a result.
+func (b *Blacklist) Fail(devAddr types.DevAddr, reason string) bool {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, valid := b.valid[devAddr]; valid || b.allowed[devAddr] {
+		return false
+	}
+	f, ok := b.failures[devAddr]
+	if !ok || now.Sub(f.since) > b.window {
+		if !ok && len(b.failures) >= MaxTracked {
+			b.forget(now)
+		}
+		f = &failures{since: now}
+		b.failures[devAddr] = f
+	}
+	f.count++
+	f.reason = reason
+	if f.count < b.threshold {
+		return false
+	}
+	delete(b.failures, devAddr)
+	b.blocked[devAddr] = &Entry{
+		DevAddr:  devAddr,
+		Reason:   reason,
+		Failures: f.count,
+		Since:    now,
+		Until:    now.Add(b.ttl),
+	}
+	return true
+}
+
+// forget removes the failures that are outside the window. The mutex must be held.
+func (b *Blacklist) forget(now time.Time) {
+	for devAddr, f := range b.failures {
+		if now.Sub(f.since) > b.window {
+			delete(b.failures, devAddr)
+		}
+	}
+}
+
+// Succeed records a valid uplink or an activation of the DevAddr, after which the DevAddr is no longer blocked
+func (b *Blacklist) Succeed(devAddr types.DevAddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, devAddr)
+	delete(b.blocked, devAddr)
+	if element, ok := b.valid[devAddr]; ok {
+		b.validOrder.MoveToBack(element)
+		return
+	}
+	if len(b.valid) >= MaxTracked {
+		oldest := b.validOrder.Front()
+		b.validOrder.Remove(oldest)
+		delete(b.valid, oldest.Value.(types.DevAddr))
+	}
+	b.valid[devAddr] = b.validOrder.PushBack(devAddr)
+}
+
+// Blocked returns true if the DevAddr is blocked
+func (b *Blacklist) Blocked(devAddr types.DevAddr) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entry, ok := b.blocked[devAddr]
+	if !ok {
+		return false
+	}
+	if time.Now().After(entry.Until) {
+		delete(b.blocked, devAddr)
+		return false
+	}
+	return true
+}
+
+// Unblock removes the DevAddr from the blacklist
+func (b *Blacklist) Unblock(devAddr types.DevAddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blocked, devAddr)
+}
+
+// Clear removes all DevAddrs from the blacklist
+func (b *Blacklist) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked = make(map[types.DevAddr]*Entry)
+}
+
+// Entries returns the blocked DevAddrs, the most recently blocked first
+func (b *Blacklist) Entries() []Entry {
+	now := time.Now()
+	b.mu.Lock()
+	entries := make([]Entry, 0, len(b.blocked))
+	for devAddr, entry := range b.blocked {
+		if now.After(entry.Until) {
+			delete(b.blocked, devAddr)
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	b.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Since.After(entries[j].Since) })
+	return entries
+}
+
+// ServeHTTP lists the blocked DevAddrs on GET. On DELETE, it unblocks the DevAddr in the dev_addr query
+// parameter, or all DevAddrs if there is no such parameter.
+func (b *Blacklist) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(b.Entries())
+	case "DELETE":
+		if devAddrStr := req.URL.Query().Get("dev_addr"); devAddrStr != "" {
+			devAddr, err := types.ParseDevAddr(devAddrStr)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b.Unblock(devAddr)
+		} else {
+			b.Clear()
+		}
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
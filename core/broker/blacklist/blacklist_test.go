@@ -0,0 +1,112 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package blacklist
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestBlacklist(t *testing.T) {
+	a := New(t)
+
+	b := NewBlacklist(3, time.Minute, 50*time.Millisecond)
+
+	devAddr1, devAddr2 := types.DevAddr{1, 2, 3, 4}, types.DevAddr{5, 6, 7, 8}
+
+	a.So(b.Fail(devAddr1, "invalid_mic"), ShouldBeFalse)
+	a.So(b.Fail(devAddr1, "invalid_mic"), ShouldBeFalse)
+	a.So(b.Blocked(devAddr1), ShouldBeFalse)
+	a.So(b.Fail(devAddr1, "fcnt_too_low"), ShouldBeTrue)
+	a.So(b.Blocked(devAddr1), ShouldBeTrue)
+	a.So(b.Blocked(devAddr2), ShouldBeFalse)
+
+	// DevAddrs with a valid uplink are never blocked
+	b.Succeed(devAddr2)
+	for i := 0; i < 3; i++ {
+		a.So(b.Fail(devAddr2, "invalid_mic"), ShouldBeFalse)
+	}
+	a.So(b.Blocked(devAddr2), ShouldBeFalse)
+
+	entries := b.Entries()
+	a.So(entries, ShouldHaveLength, 1)
+	a.So(entries[0].DevAddr, ShouldEqual, devAddr1)
+	a.So(entries[0].Reason, ShouldEqual, "fcnt_too_low")
+	a.So(entries[0].Failures, ShouldEqual, 3)
+
+	// An activation of the DevAddr unblocks it
+	devAddr4 := types.DevAddr{13, 14, 15, 16}
+	for i := 0; i < 3; i++ {
+		b.Fail(devAddr4, "unknown_dev_addr")
+	}
+	a.So(b.Blocked(devAddr4), ShouldBeTrue)
+	b.Succeed(devAddr4)
+	a.So(b.Blocked(devAddr4), ShouldBeFalse)
+
+	// DevAddrs are unblocked after the TTL
+	time.Sleep(60 * time.Millisecond)
+	a.So(b.Blocked(devAddr1), ShouldBeFalse)
+
+	// Allowed DevAddrs are never blocked
+	devAddr3 := types.DevAddr{9, 10, 11, 12}
+	b.Allow(devAddr3)
+	for i := 0; i < 3; i++ {
+		a.So(b.Fail(devAddr3, "invalid_mic"), ShouldBeFalse)
+	}
+	a.So(b.Blocked(devAddr3), ShouldBeFalse)
+}
+
+func TestBlacklistMaxTracked(t *testing.T) {
+	a := New(t)
+
+	defer func(maxTracked int) { MaxTracked = maxTracked }(MaxTracked)
+	MaxTracked = 2
+
+	b := NewBlacklist(1, time.Minute, time.Minute)
+	devAddr1, devAddr2, devAddr3 := types.DevAddr{1, 2, 3, 4}, types.DevAddr{5, 6, 7, 8}, types.DevAddr{9, 10, 11, 12}
+	b.Succeed(devAddr1)
+	b.Succeed(devAddr2)
+	b.Succeed(devAddr1)
+
+	// The least recently valid DevAddr is forgotten
+	b.Succeed(devAddr3)
+	a.So(b.valid, ShouldHaveLength, 2)
+	a.So(b.Fail(devAddr1, "unknown_dev_addr"), ShouldBeFalse)
+	a.So(b.Fail(devAddr2, "unknown_dev_addr"), ShouldBeTrue)
+}
+
+func TestBlacklistHTTP(t *testing.T) {
+	a := New(t)
+
+	b := NewBlacklist(1, time.Minute, time.Minute)
+	b.Fail(types.DevAddr{1, 2, 3, 4}, "invalid_mic")
+	b.Fail(types.DevAddr{5, 6, 7, 8}, "invalid_mic")
+
+	res := httptest.NewRecorder()
+	b.ServeHTTP(res, httptest.NewRequest("GET", Path, nil))
+	var listed []Entry
+	a.So(json.NewDecoder(res.Body).Decode(&listed), ShouldBeNil)
+	a.So(listed, ShouldHaveLength, 2)
+
+	res = httptest.NewRecorder()
+	b.ServeHTTP(res, httptest.NewRequest("DELETE", Path+"?dev_addr=invalid", nil))
+	a.So(res.Code, ShouldEqual, http.StatusBadRequest)
+
+	res = httptest.NewRecorder()
+	b.ServeHTTP(res, httptest.NewRequest("DELETE", Path+"?dev_addr=01020304", nil))
+	a.So(res.Code, ShouldEqual, http.StatusNoContent)
+	a.So(b.Blocked(types.DevAddr{1, 2, 3, 4}), ShouldBeFalse)
+	a.So(b.Blocked(types.DevAddr{5, 6, 7, 8}), ShouldBeTrue)
+
+	res = httptest.NewRecorder()
+	b.ServeHTTP(res, httptest.NewRequest("DELETE", Path, nil))
+	a.So(res.Code, ShouldEqual, http.StatusNoContent)
+	a.So(b.Entries(), ShouldBeEmpty)
+}
@@ -15,10 +15,17 @@ import (
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/go-utils/grpc/auth"
 	"github.com/TheThingsNetwork/ttn/api"
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/broker/joinlimit"
+	"github.com/TheThingsNetwork/ttn/core/broker/peering"
+	"github.com/TheThingsNetwork/ttn/core/broker/quarantine"
+	"github.com/TheThingsNetwork/ttn/core/broker/tap"
 	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/secureelement"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"google.golang.org/grpc"
+	"gopkg.in/redis.v5"
 )
 
 type Broker interface {
@@ -26,6 +33,16 @@ type Broker interface {
 	component.ManagementInterface
 
 	SetNetworkServer(addr, cert, token string)
+	WithTap(t *tap.Tap) Broker
+	WithReplayDetection(window time.Duration) Broker
+	WithSecureElement(service secureelement.Service) Broker
+	WithDeduplicationWindow(window DeduplicationWindow) Broker
+	WithRedisDeduplication(client *redis.Client) Broker
+	WithQuarantine(q *quarantine.Quarantine, hook *quarantine.Hook) Broker
+	WithBlacklist(bl *blacklist.Blacklist) Broker
+	WithJoinLimiter(l *joinlimit.Limiter) Broker
+	WithPeering(e *peering.Exchange) Broker
+	WithPrivateGateways(gateways types.PrivateGateways) Broker
 
 	HandleUplink(uplink *pb.UplinkMessage) error
 	HandleDownlink(downlink *pb.DownlinkMessage) error
@@ -43,6 +60,7 @@ func NewBroker(timeout time.Duration) Broker {
 		handlers:               make(map[string]*handler),
 		uplinkDeduplicator:     NewDeduplicator(timeout),
 		activationDeduplicator: NewDeduplicator(timeout),
+		deduplicationWindow:    DeduplicationWindow{Default: timeout},
 	}
 }
 
@@ -52,6 +70,24 @@ func (b *broker) SetNetworkServer(addr, cert, token string) {
 	b.nsToken = token
 }
 
+// WithTap mirrors a sample of the deduplicated uplink messages to the tap
+func (b *broker) WithTap(t *tap.Tap) Broker {
+	b.tap = t
+	return b
+}
+
+// WithReplayDetection remembers uplinks during the window to detect frames that are received again
+func (b *broker) WithReplayDetection(window time.Duration) Broker {
+	b.replays = newReplayDetector(window)
+	return b
+}
+
+// WithSecureElement validates the MIC of devices without NwkSKey with the secure element service
+func (b *broker) WithSecureElement(service secureelement.Service) Broker {
+	b.secureElement = service
+	return b
+}
+
 type broker struct {
 	*component.Component
 	routers                map[string]chan *pb.DownlinkMessage
@@ -65,25 +101,43 @@ type broker struct {
 	ns                     networkserver.NetworkServerClient
 	uplinkDeduplicator     Deduplicator
 	activationDeduplicator Deduplicator
+	deduplicationWindow    DeduplicationWindow
+	deduplicationClient    *redis.Client
 	status                 *status
 	monitorStream          monitorclient.Stream
+	tap                    *tap.Tap
+	replays                *replayDetector
+	secureElement          secureelement.Service
+	quarantine             *quarantine.Quarantine
+	provisionHook          *quarantine.Hook
+	provisionQueue         chan quarantine.Entry
+	blacklist              *blacklist.Blacklist
+	joinLimiter            *joinlimit.Limiter
+	peering                *peering.Exchange
+	privateGateways        types.PrivateGateways
+	ownPrefixes            []types.DevAddrPrefix // DevAddr prefixes of the NetworkServer
 }
 
 func (b *broker) checkPrefixAnnouncements() error {
 	// Get prefixes from NS
 	nsPrefixes := map[types.DevAddrPrefix]string{}
 	devAddrClient := pb_lorawan.NewDevAddrManagerClient(b.nsConn)
-	resp, err := devAddrClient.GetPrefixes(b.GetContext(""), &pb_lorawan.PrefixesRequest{})
+	reqCtx, cancel := b.GetRequestContext("")
+	defer cancel()
+	resp, err := devAddrClient.GetPrefixes(reqCtx, &pb_lorawan.PrefixesRequest{})
 	if err != nil {
 		return errors.Wrap(errors.FromGRPCError(err), "NetworkServer did not return prefixes")
 	}
+	ownPrefixes := make([]types.DevAddrPrefix, 0, len(resp.Prefixes))
 	for _, mapping := range resp.Prefixes {
 		prefix, err := types.ParseDevAddrPrefix(mapping.Prefix)
 		if err != nil {
 			continue
 		}
 		nsPrefixes[prefix] = strings.Join(mapping.Usage, ",")
+		ownPrefixes = append(ownPrefixes, prefix)
 	}
+	b.ownPrefixes = ownPrefixes
 
 	// Get self from Discovery
 	self, err := b.Component.Discover("broker", b.Component.Identity.ID)
@@ -113,6 +167,9 @@ func (b *broker) Init(c *component.Component) error {
 	b.Component = c
 	initMetrics()
 	b.InitStatus()
+	if b.tap != nil {
+		go b.tap.Run(b.Ctx.WithField("Tap", true))
+	}
 	err := b.Component.UpdateTokenKey()
 	if err != nil {
 		return err
@@ -134,6 +191,11 @@ func (b *broker) Init(c *component.Component) error {
 	b.nsConn = conn
 	b.ns = networkserver.NewNetworkServerClient(conn)
 	b.checkPrefixAnnouncements()
+	if b.peering != nil {
+		if err := b.peering.Connect(b.Ctx.WithField("Peering", true), b.Identity.ID, b.handlePeeringDownlink); err != nil {
+			return err
+		}
+	}
 	b.Component.SetStatus(component.StatusHealthy)
 	if b.Component.Monitor != nil {
 		b.monitorStream = b.Component.Monitor.BrokerClient(b.Context, grpc.PerRPCCredentials(auth.WithStaticToken(b.AccessToken)))
@@ -146,7 +208,14 @@ func (b *broker) Init(c *component.Component) error {
 	return nil
 }
 
-func (b *broker) Shutdown() {}
+func (b *broker) Shutdown() {
+	if b.tap != nil {
+		b.tap.Close()
+	}
+	if b.peering != nil {
+		b.peering.Close()
+	}
+}
 
 func (b *broker) ActivateRouter(id string) (<-chan *pb.DownlinkMessage, error) {
 	b.routersLock.Lock()
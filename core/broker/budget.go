@@ -0,0 +1,66 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"sort"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/utils/budget"
+)
+
+// downlinkCandidate is a DownlinkOption together with the time at which the downlink must be ready
+type downlinkCandidate struct {
+	option   *pb.DownlinkOption
+	deadline time.Time
+}
+
+// appendDownlinkCandidates appends the DownlinkOptions of an uplink with the given gateway timestamp
+func appendDownlinkCandidates(candidates []downlinkCandidate, receivedAt time.Time, uplinkTimestamp uint32, options []*pb.DownlinkOption) []downlinkCandidate {
+	for _, option := range options {
+		candidates = append(candidates, downlinkCandidate{
+			option:   option,
+			deadline: budget.Deadline(receivedAt, uplinkTimestamp, option.GatewayConfiguration.Timestamp),
+		})
+	}
+	return candidates
+}
+
+// selectDownlink returns the best DownlinkOption that can still make its deadline, or nil if the
+// deadlines of all options have passed. If the budget for RX1 is blown, this falls back to RX2.
+func selectDownlink(stage string, candidates []downlinkCandidate) *pb.DownlinkOption {
+	sort.Stable(byCandidateScore(candidates))
+	for _, candidate := range candidates {
+		if !budget.Expired(candidate.deadline) {
+			return candidate.option
+		}
+		deadlineExceededCounter.WithLabelValues(stage).Inc()
+	}
+	return nil
+}
+
+// checkDownlink returns the selected DownlinkOption if it can still make its deadline, or the best
+// other option otherwise. The frame counter of the protocol configuration is kept.
+func checkDownlink(stage string, selected *pb.DownlinkOption, candidates []downlinkCandidate) *pb.DownlinkOption {
+	for _, candidate := range candidates {
+		if candidate.option == selected && !budget.Expired(candidate.deadline) {
+			return selected
+		}
+	}
+	replacement := selectDownlink(stage, candidates)
+	if replacement == nil {
+		return nil
+	}
+	if selected, replacement := selected.ProtocolConfiguration.GetLoRaWAN(), replacement.ProtocolConfiguration.GetLoRaWAN(); selected != nil && replacement != nil {
+		replacement.FCnt = selected.FCnt
+	}
+	return replacement
+}
+
+type byCandidateScore []downlinkCandidate
+
+func (a byCandidateScore) Len() int           { return len(a) }
+func (a byCandidateScore) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCandidateScore) Less(i, j int) bool { return a[i].option.Score < a[j].option.Score }
@@ -0,0 +1,60 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+func buildDownlinkOption(score uint32, timestamp uint32) *pb.DownlinkOption {
+	return &pb.DownlinkOption{
+		Score:                 score,
+		GatewayConfiguration:  pb_gateway.TxConfiguration{Timestamp: timestamp},
+		ProtocolConfiguration: pb_protocol.TxConfiguration{Protocol: &pb_protocol.TxConfiguration_LoRaWAN{LoRaWAN: &pb_lorawan.TxConfiguration{}}},
+	}
+}
+
+func TestSelectDownlink(t *testing.T) {
+	a := New(t)
+
+	rx1 := buildDownlinkOption(10, 1001000)
+	rx2 := buildDownlinkOption(20, 2001000)
+
+	// Within the RX1 budget
+	candidates := appendDownlinkCandidates(nil, time.Now(), 1000, []*pb.DownlinkOption{rx2, rx1})
+	a.So(selectDownlink("test", candidates), ShouldEqual, rx1)
+
+	// RX1 budget blown, fall back to RX2
+	candidates = appendDownlinkCandidates(nil, time.Now().Add(-500*time.Millisecond), 1000, []*pb.DownlinkOption{rx1, rx2})
+	a.So(selectDownlink("test", candidates), ShouldEqual, rx2)
+
+	// All budgets blown
+	candidates = appendDownlinkCandidates(nil, time.Now().Add(-2*time.Second), 1000, []*pb.DownlinkOption{rx1, rx2})
+	a.So(selectDownlink("test", candidates), ShouldBeNil)
+}
+
+func TestCheckDownlink(t *testing.T) {
+	a := New(t)
+
+	rx1 := buildDownlinkOption(10, 1001000)
+	rx2 := buildDownlinkOption(20, 2001000)
+
+	candidates := appendDownlinkCandidates(nil, time.Now(), 1000, []*pb.DownlinkOption{rx1, rx2})
+	a.So(checkDownlink("test", rx1, candidates), ShouldEqual, rx1)
+
+	rx1.ProtocolConfiguration.GetLoRaWAN().FCnt = 42
+	candidates = appendDownlinkCandidates(nil, time.Now().Add(-500*time.Millisecond), 1000, []*pb.DownlinkOption{rx1, rx2})
+	a.So(checkDownlink("test", rx1, candidates), ShouldEqual, rx2)
+	a.So(rx2.ProtocolConfiguration.GetLoRaWAN().FCnt, ShouldEqual, 42)
+
+	candidates = appendDownlinkCandidates(nil, time.Now().Add(-2*time.Second), 1000, []*pb.DownlinkOption{rx1, rx2})
+	a.So(checkDownlink("test", rx1, candidates), ShouldBeNil)
+}
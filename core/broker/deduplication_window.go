@@ -0,0 +1,114 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"strings"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/utils/budget"
+	"gopkg.in/redis.v5"
+)
+
+// MinDeduplicationWindow is the shortest window that adaptive sizing will use
+var MinDeduplicationWindow = 20 * time.Millisecond
+
+// DeduplicationShare is the part of the remaining RX1 budget that adaptive sizing may spend on waiting for
+// duplicates. The rest is reserved for processing after deduplication.
+var DeduplicationShare = 0.5
+
+// DeduplicationWindow decides how long the broker waits for duplicates of a message
+type DeduplicationWindow struct {
+	// Default is used when no other window applies
+	Default time.Duration
+	// FrequencyPlans overrides the default per frequency plan (such as EU_863_870)
+	FrequencyPlans map[string]time.Duration
+	// DataRates overrides the default and frequency plan per data rate (such as SF12BW125)
+	DataRates map[string]time.Duration
+	// Adaptive grows the window for slow data rates and shrinks it to fit the RX1 budget of the message
+	Adaptive bool
+}
+
+// Uplink returns the window for an *pb.UplinkMessage
+func (w DeduplicationWindow) Uplink(value interface{}) time.Duration {
+	uplink := value.(*pb.UplinkMessage)
+	return w.get(uplink.ProtocolMetadata.GetLoRaWAN(), uplink.GatewayMetadata.Timestamp, uplink.DownlinkOptions)
+}
+
+// Activation returns the window for an *pb.DeviceActivationRequest
+func (w DeduplicationWindow) Activation(value interface{}) time.Duration {
+	activation := value.(*pb.DeviceActivationRequest)
+	return w.get(activation.ProtocolMetadata.GetLoRaWAN(), activation.GatewayMetadata.Timestamp, activation.DownlinkOptions)
+}
+
+func (w DeduplicationWindow) get(lorawan *pb_lorawan.Metadata, uplinkTimestamp uint32, options []*pb.DownlinkOption) time.Duration {
+	window := w.Default
+	if lorawan != nil {
+		if fpWindow, ok := w.FrequencyPlans[lorawan.FrequencyPlan.String()]; ok {
+			window = fpWindow
+		}
+		if drWindow, ok := w.DataRates[lorawan.DataRate]; ok {
+			window = drWindow
+		}
+	}
+	if !w.Adaptive {
+		return window
+	}
+
+	// Duplicates of slow data rates are spread out more
+	if lorawan != nil && (strings.HasPrefix(lorawan.DataRate, "SF11") || strings.HasPrefix(lorawan.DataRate, "SF12")) {
+		window *= 2
+	}
+
+	// Leave enough of the RX1 budget for the rest of the processing
+	now := time.Now()
+	var rx1Deadline time.Time
+	for _, option := range options {
+		deadline := budget.Deadline(now, uplinkTimestamp, option.GatewayConfiguration.Timestamp)
+		if !deadline.IsZero() && (rx1Deadline.IsZero() || deadline.Before(rx1Deadline)) {
+			rx1Deadline = deadline
+		}
+	}
+	if !rx1Deadline.IsZero() {
+		if max := time.Duration(float64(rx1Deadline.Sub(now)) * DeduplicationShare); window > max {
+			window = max
+		}
+	}
+	if window < MinDeduplicationWindow {
+		window = MinDeduplicationWindow
+	}
+	return window
+}
+
+// WithDeduplicationWindow replaces the fixed deduplication delay of the broker
+func (b *broker) WithDeduplicationWindow(window DeduplicationWindow) Broker {
+	b.deduplicationWindow = window
+	b.initDeduplicators()
+	return b
+}
+
+// WithRedisDeduplication deduplicates the uplinks and activations of all brokers that use the same Redis database,
+// so that duplicates that reach different replicas of the broker are still deduplicated
+func (b *broker) WithRedisDeduplication(client *redis.Client) Broker {
+	b.deduplicationClient = client
+	b.initDeduplicators()
+	return b
+}
+
+func (b *broker) initDeduplicators() {
+	window := b.deduplicationWindow
+	if b.deduplicationClient == nil {
+		b.uplinkDeduplicator = NewWindowDeduplicator(window.Uplink)
+		b.activationDeduplicator = NewWindowDeduplicator(window.Activation)
+		return
+	}
+	b.uplinkDeduplicator = NewRedisDeduplicator(b.deduplicationClient, "broker:deduplication:uplink", window.Uplink, func() interface{} {
+		return new(pb.UplinkMessage)
+	})
+	b.activationDeduplicator = NewRedisDeduplicator(b.deduplicationClient, "broker:deduplication:activation", window.Activation, func() interface{} {
+		return new(pb.DeviceActivationRequest)
+	})
+}
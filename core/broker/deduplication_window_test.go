@@ -0,0 +1,63 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/utils/budget"
+	. "github.com/smartystreets/assertions"
+)
+
+func buildWindowUplink(dataRate string, rx1Delay time.Duration) *pb.UplinkMessage {
+	uplink := &pb.UplinkMessage{
+		ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{
+			FrequencyPlan: pb_lorawan.FrequencyPlan_EU_863_870,
+			DataRate:      dataRate,
+		}}},
+		GatewayMetadata: pb_gateway.RxMetadata{Timestamp: 1000},
+	}
+	if rx1Delay != 0 {
+		uplink.DownlinkOptions = []*pb.DownlinkOption{
+			{GatewayConfiguration: pb_gateway.TxConfiguration{Timestamp: 1000 + uint32(rx1Delay/time.Microsecond)}},
+		}
+	}
+	return uplink
+}
+
+func TestDeduplicationWindow(t *testing.T) {
+	a := New(t)
+
+	window := DeduplicationWindow{
+		Default:        200 * time.Millisecond,
+		FrequencyPlans: map[string]time.Duration{"EU_863_870": 300 * time.Millisecond},
+		DataRates:      map[string]time.Duration{"SF10BW125": 400 * time.Millisecond},
+	}
+	a.So(window.Uplink(&pb.UplinkMessage{}), ShouldEqual, 200*time.Millisecond)
+	a.So(window.Uplink(buildWindowUplink("SF7BW125", 0)), ShouldEqual, 300*time.Millisecond)
+	a.So(window.Uplink(buildWindowUplink("SF10BW125", 0)), ShouldEqual, 400*time.Millisecond)
+	a.So(window.Uplink(buildWindowUplink("SF12BW125", time.Second)), ShouldEqual, 300*time.Millisecond)
+
+	window.Adaptive = true
+
+	// Grow for slow data rates
+	a.So(window.Uplink(buildWindowUplink("SF12BW125", 0)), ShouldEqual, 600*time.Millisecond)
+	a.So(window.Uplink(buildWindowUplink("SF12BW125", 5*time.Second)), ShouldEqual, 600*time.Millisecond)
+
+	// Shrink to fit the RX1 budget
+	shrunk := window.Uplink(buildWindowUplink("SF12BW125", time.Second))
+	a.So(shrunk, ShouldBeGreaterThan, 300*time.Millisecond)
+	a.So(shrunk, ShouldBeLessThan, time.Duration(float64(time.Second-budget.Margin)*DeduplicationShare)+1)
+	shrunk = window.Uplink(buildWindowUplink("SF7BW125", budget.Margin+400*time.Millisecond))
+	a.So(shrunk, ShouldBeLessThan, 200*time.Millisecond+1)
+	a.So(shrunk, ShouldBeGreaterThan, 150*time.Millisecond)
+
+	// Never shorter than the minimum
+	a.So(window.Uplink(buildWindowUplink("SF7BW125", budget.Margin)), ShouldEqual, MinDeduplicationWindow)
+}
@@ -12,19 +12,27 @@ type collection struct {
 	sync.Mutex
 	ready  chan bool
 	values []interface{}
+	first  time.Time
+	closed bool
 }
 
 func newCollection() *collection {
 	return &collection{
 		ready:  make(chan bool, 1),
 		values: []interface{}{},
+		first:  time.Now(),
 	}
 }
 
-func (c *collection) Add(value interface{}) {
+// Add adds the value to the collection and returns false if the collection was already closed
+func (c *collection) Add(value interface{}) bool {
 	c.Lock()
 	defer c.Unlock()
+	if c.closed {
+		return false
+	}
 	c.values = append(c.values, value)
+	return true
 }
 
 func (c *collection) GetAndClear() []interface{} {
@@ -36,6 +44,9 @@ func (c *collection) GetAndClear() []interface{} {
 }
 
 func (c *collection) done() {
+	c.Lock()
+	c.closed = true
+	c.Unlock()
 	c.ready <- true
 }
 
@@ -47,9 +58,12 @@ type Deduplicator interface {
 	Deduplicate(key string, value interface{}) []interface{}
 }
 
+// WindowFunc returns how long to wait for duplicates of the first value of a collection
+type WindowFunc func(value interface{}) time.Duration
+
 type deduplicator struct {
 	sync.Mutex
-	timeout     time.Duration
+	window      WindowFunc
 	collections map[string]*collection
 }
 
@@ -58,7 +72,10 @@ func (d *deduplicator) add(key string, value interface{}) (c *collection, isFirs
 	defer d.Unlock()
 	var ok bool
 	if c, ok = d.collections[key]; ok {
-		c.Add(value)
+		if !c.Add(value) {
+			lateDuplicatesCounter.Inc()
+			lateDuplicatesHistogram.Observe(time.Since(c.first).Seconds())
+		}
 	} else {
 		isFirst = true
 		c = newCollection()
@@ -71,23 +88,34 @@ func (d *deduplicator) add(key string, value interface{}) (c *collection, isFirs
 func (d *deduplicator) Deduplicate(key string, value interface{}) (values []interface{}) {
 	collection, isFirst := d.add(key, value)
 	if isFirst {
-		go func() {
-			<-time.After(d.timeout)
-			collection.done()
-			<-time.After(d.timeout)
-			d.Lock()
-			defer d.Unlock()
-			delete(d.collections, key)
-		}()
-		collection.wait()
-		values = collection.GetAndClear()
+		values = d.collect(key, collection, d.window(value))
 	}
 	return
 }
 
+// collect waits for the duplicates in the collection during the timeout and removes the collection after another
+// timeout, so that late duplicates are still detected
+func (d *deduplicator) collect(key string, collection *collection, timeout time.Duration) []interface{} {
+	go func() {
+		<-time.After(timeout)
+		collection.done()
+		<-time.After(timeout)
+		d.Lock()
+		defer d.Unlock()
+		delete(d.collections, key)
+	}()
+	collection.wait()
+	return collection.GetAndClear()
+}
+
 func NewDeduplicator(timeout time.Duration) Deduplicator {
+	return NewWindowDeduplicator(func(interface{}) time.Duration { return timeout })
+}
+
+// NewWindowDeduplicator returns a Deduplicator that decides the window per collection
+func NewWindowDeduplicator(window WindowFunc) Deduplicator {
 	return &deduplicator{
-		timeout:     timeout,
+		window:      window,
 		collections: map[string]*collection{},
 	}
 }
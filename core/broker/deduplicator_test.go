@@ -68,3 +68,34 @@ func TestDeduplicatorDeduplicate(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestDeduplicatorLateDuplicate(t *testing.T) {
+	a := New(t)
+	d := NewWindowDeduplicator(func(value interface{}) time.Duration {
+		return time.Duration(value.(int)) * time.Millisecond
+	}).(*deduplicator)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		a.So(d.Deduplicate("key", 50), ShouldResemble, []interface{}{50})
+		wg.Done()
+	}()
+	wg.Wait()
+
+	// The collection is closed but not cleaned up yet
+	c, isFirst := d.add("key", 20)
+	a.So(isFirst, ShouldBeFalse)
+	a.So(c.GetAndClear(), ShouldBeEmpty)
+}
+
+// BenchmarkDeduplicate measures the cost of adding a duplicate to an open collection, which
+// is what happens for every gateway after the first one that receives an uplink
+func BenchmarkDeduplicate(b *testing.B) {
+	d := NewDeduplicator(time.Hour).(*deduplicator)
+	d.add("key", 0)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		d.Deduplicate("key", n)
+	}
+}
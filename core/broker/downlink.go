@@ -42,7 +42,9 @@ func (b *broker) HandleDownlink(downlink *pb.DownlinkMessage) error {
 
 	downlink.Trace = downlink.Trace.WithEvent(trace.ReceiveEvent)
 
-	downlink, err = b.ns.Downlink(b.Component.GetContext(b.nsToken), downlink)
+	reqCtx, cancel := b.Component.GetRequestContext(b.nsToken)
+	downlink, err = b.ns.Downlink(reqCtx, downlink)
+	cancel()
 	if err != nil {
 		return errors.Wrap(errors.FromGRPCError(err), "NetworkServer did not handle downlink")
 	}
@@ -0,0 +1,38 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	pb "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/handshake"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// brokerHello is what the Broker tells the Handlers that register applications
+var brokerHello = handshake.Local(handshake.FeatureMulticast)
+
+// handshake checks the Hello of a Handler that registers an application and answers with the Hello of the Broker.
+// Handlers that do not send a Hello run an older version and are accepted.
+func (b *brokerManager) handshake(ctx context.Context, in *pb.ApplicationHandlerRegistration) error {
+	log := b.broker.Ctx.WithField("AppID", in.AppID).WithField("HandlerID", in.HandlerID)
+	md, _ := metadata.FromIncomingContext(ctx)
+	hello, ok := handshake.FromMetadata(md)
+	if !ok {
+		log.Warn("Handler did not send a handshake, it may run an older version")
+		return nil
+	}
+	agreement, err := handshake.Negotiate(brokerHello, hello)
+	if err != nil {
+		log.WithError(err).Error("Rejected incompatible Handler")
+		return errors.Wrap(err, "Incompatible Handler")
+	}
+	if err := grpc.SendHeader(ctx, brokerHello.Metadata()); err != nil {
+		log.WithError(err).Debug("Could not send handshake")
+	}
+	log.WithField("ProtocolVersion", hello.ProtocolVersion).WithField("PacketFormat", agreement.PacketFormat).WithField("Features", agreement.Features).Debug("Completed handshake with Handler")
+	return nil
+}
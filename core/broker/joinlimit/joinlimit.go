@@ -0,0 +1,187 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package joinlimit defers join requests during join storms, for example when all devices of a region
+// join again after a power outage
+package joinlimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Reasons for deferring a join request. They are counted in the deferred activations metric.
+const (
+	DeferDeviceBackoff = "device_backoff"
+	DeferRateLimit     = "rate_limit"
+	DeferFairShare     = "fair_share"
+)
+
+// Window is the time in which join requests are counted against the rate
+var Window = time.Second
+
+// MaxTracked is the maximum number of devices of which the backoff is tracked. When more devices join,
+// the devices of which the backoff has been reset are forgotten.
+var MaxTracked = 100000
+
+type device struct {
+	AppEUI types.AppEUI
+	DevEUI types.DevEUI
+}
+
+type backoff struct {
+	last  time.Time     // last join request that was not deferred
+	delay time.Duration // delay until the next join request is accepted
+}
+
+// Limiter defers join requests.
+//
+// A device that keeps joining has to wait before its next join request is accepted. The wait starts at
+// the minimum backoff, doubles with every join of the device, up to the maximum backoff, and is reset when
+// the device has not joined for twice the maximum backoff. The DevEUI of a join request is not authenticated,
+// so the backoff only starts when the join request is accepted by the Handler of the device, which checks its
+// MIC and DevNonce. Join requests with a spoofed DevEUI can therefore not defer the joins of the device.
+//
+// The join requests of all devices are limited to the rate per Window. The rate is shared fairly between
+// the AppEUIs that send join requests, so that a storm of one application does not defer the join requests
+// of the others.
+type Limiter struct {
+	mu         sync.Mutex
+	rate       int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	backoff map[device]*backoff
+
+	windowStart time.Time
+	total       int
+	requested   map[types.AppEUI]int // join requests per AppEUI in the current window
+	accepted    map[types.AppEUI]int // join requests per AppEUI in the current window that were not deferred
+	previous    map[types.AppEUI]int // join requests per AppEUI in the previous window
+}
+
+// NewLimiter returns a new Limiter. A zero rate does not limit the rate and a zero minimum backoff disables
+// the backoff of devices.
+func NewLimiter(rate int, minBackoff, maxBackoff time.Duration) *Limiter {
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+	return &Limiter{
+		rate:       rate,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+		backoff:    make(map[device]*backoff),
+		requested:  make(map[types.AppEUI]int),
+		accepted:   make(map[types.AppEUI]int),
+	}
+}
+
+// Allow returns the reason why the join request of the device should be deferred, or an empty string if the join
+// request should be handled now
+func (l *Limiter) Allow(appEUI types.AppEUI, devEUI types.DevEUI) string {
+	return l.allow(appEUI, devEUI, time.Now())
+}
+
+func (l *Limiter) allow(appEUI types.AppEUI, devEUI types.DevEUI, now time.Time) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.backoff[device{appEUI, devEUI}]; ok && now.Sub(b.last) <= 2*l.maxBackoff && now.Before(b.last.Add(b.delay)) {
+		return DeferDeviceBackoff
+	}
+
+	if l.rate > 0 {
+		if elapsed := now.Sub(l.windowStart); elapsed >= Window {
+			l.previous = l.requested
+			if elapsed >= 2*Window {
+				l.previous = nil
+			}
+			l.windowStart = now
+			l.total = 0
+			l.requested = make(map[types.AppEUI]int)
+			l.accepted = make(map[types.AppEUI]int)
+		}
+		l.requested[appEUI]++
+		if l.total >= l.rate {
+			return DeferRateLimit
+		}
+		if !l.fair(appEUI) {
+			return DeferFairShare
+		}
+		l.accepted[appEUI]++
+		l.total++
+	}
+
+	return ""
+}
+
+// Joined starts or extends the backoff of the device, after its join request was accepted by its Handler
+func (l *Limiter) Joined(appEUI types.AppEUI, devEUI types.DevEUI) {
+	l.joined(appEUI, devEUI, time.Now())
+}
+
+func (l *Limiter) joined(appEUI types.AppEUI, devEUI types.DevEUI, now time.Time) {
+	if l.minBackoff == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	dev := device{appEUI, devEUI}
+	b, tracked := l.backoff[dev]
+	if !tracked || now.Sub(b.last) > 2*l.maxBackoff {
+		if !tracked && len(l.backoff) >= MaxTracked {
+			l.forget(now)
+		}
+		b = &backoff{delay: l.minBackoff}
+		l.backoff[dev] = b
+	} else if b.delay *= 2; b.delay > l.maxBackoff {
+		b.delay = l.maxBackoff
+	}
+	b.last = now
+}
+
+// fair returns true if the AppEUI may take a join request of the rate in the current window. All AppEUIs
+// that send join requests in the current or the previous window get an equal share of the rate. An AppEUI
+// can exceed its share if the rest of the rate is not needed for the shares of the other AppEUIs, as far
+// as they sent join requests in the previous window. The mutex must be held.
+func (l *Limiter) fair(appEUI types.AppEUI) bool {
+	active := len(l.requested)
+	for other := range l.previous {
+		if _, ok := l.requested[other]; !ok {
+			active++
+		}
+	}
+	share := l.rate / active
+	if share < 1 {
+		share = 1
+	}
+	if l.accepted[appEUI] < share {
+		return true
+	}
+	var reserved int
+	for other, requested := range l.previous {
+		if other == appEUI {
+			continue
+		}
+		if requested > share {
+			requested = share
+		}
+		if want := requested - l.accepted[other]; want > 0 {
+			reserved += want
+		}
+	}
+	return l.rate-l.total > reserved
+}
+
+// forget removes the devices of which the backoff has been reset. The mutex must be held.
+func (l *Limiter) forget(now time.Time) {
+	for dev, b := range l.backoff {
+		if now.Sub(b.last) > 2*l.maxBackoff {
+			delete(l.backoff, dev)
+		}
+	}
+}
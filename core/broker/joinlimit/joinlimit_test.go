@@ -0,0 +1,89 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package joinlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestLimiterBackoff(t *testing.T) {
+	a := New(t)
+
+	l := NewLimiter(0, 10*time.Second, 40*time.Second)
+	appEUI, devEUI := types.AppEUI{1}, types.DevEUI{1}
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	join := func(devEUI types.DevEUI, seconds int) string {
+		reason := l.allow(appEUI, devEUI, at(seconds))
+		if reason == "" {
+			l.joined(appEUI, devEUI, at(seconds))
+		}
+		return reason
+	}
+
+	a.So(join(devEUI, 0), ShouldEqual, "")
+	a.So(join(devEUI, 5), ShouldEqual, DeferDeviceBackoff)
+	a.So(join(types.DevEUI{2}, 5), ShouldEqual, "")
+
+	// The backoff doubles up to the maximum
+	a.So(join(devEUI, 10), ShouldEqual, "")
+	a.So(join(devEUI, 25), ShouldEqual, DeferDeviceBackoff)
+	a.So(join(devEUI, 30), ShouldEqual, "")
+	a.So(join(devEUI, 69), ShouldEqual, DeferDeviceBackoff)
+	a.So(join(devEUI, 70), ShouldEqual, "")
+	a.So(join(devEUI, 109), ShouldEqual, DeferDeviceBackoff)
+	a.So(join(devEUI, 110), ShouldEqual, "")
+
+	// The backoff is reset after twice the maximum backoff
+	a.So(join(devEUI, 200), ShouldEqual, "")
+	a.So(join(devEUI, 205), ShouldEqual, DeferDeviceBackoff)
+	a.So(join(devEUI, 210), ShouldEqual, "")
+}
+
+func TestLimiterBackoffNotAccepted(t *testing.T) {
+	a := New(t)
+
+	l := NewLimiter(0, 10*time.Second, 40*time.Second)
+	appEUI, devEUI := types.AppEUI{1}, types.DevEUI{1}
+	start := time.Now()
+
+	// Join requests with a spoofed DevEUI are not accepted by the Handler, and do not start the backoff
+	for i := 0; i < 5; i++ {
+		a.So(l.allow(appEUI, devEUI, start.Add(time.Duration(i)*time.Second)), ShouldEqual, "")
+	}
+	a.So(l.backoff, ShouldBeEmpty)
+
+	l.joined(appEUI, devEUI, start.Add(5*time.Second))
+	a.So(l.allow(appEUI, devEUI, start.Add(6*time.Second)), ShouldEqual, DeferDeviceBackoff)
+}
+
+func TestLimiterRate(t *testing.T) {
+	a := New(t)
+
+	l := NewLimiter(4, 0, 0)
+	storm, other := types.AppEUI{1}, types.AppEUI{2}
+	start := time.Now()
+	join := func(appEUI types.AppEUI, n int, at time.Time) (reasons []string) {
+		for i := 0; i < n; i++ {
+			reasons = append(reasons, l.allow(appEUI, types.DevEUI{byte(i)}, at))
+		}
+		return
+	}
+
+	// The storm starts, and takes the whole rate
+	a.So(join(storm, 6, start), ShouldResemble, []string{"", "", "", "", DeferRateLimit, DeferRateLimit})
+	a.So(join(other, 1, start), ShouldResemble, []string{DeferRateLimit})
+
+	// In the next window, the storm gets the rest of the rate that the other AppEUI does not need
+	next := start.Add(Window)
+	a.So(join(storm, 6, next), ShouldResemble, []string{"", "", "", DeferFairShare, DeferFairShare, DeferFairShare})
+	a.So(join(other, 2, next), ShouldResemble, []string{"", DeferRateLimit})
+
+	// Without the storm, the other AppEUI gets the whole rate
+	a.So(join(other, 5, next.Add(3*Window)), ShouldResemble, []string{"", "", "", "", DeferRateLimit})
+}
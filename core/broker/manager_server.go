@@ -14,6 +14,7 @@ import (
 	"github.com/TheThingsNetwork/go-account-lib/claims"
 	"github.com/TheThingsNetwork/go-account-lib/rights"
 	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
+	"github.com/TheThingsNetwork/ttn/api"
 	"github.com/TheThingsNetwork/ttn/api/ratelimit"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/gogo/protobuf/types"
@@ -57,7 +58,8 @@ func (b *brokerManager) SetDevice(ctx context.Context, in *lorawan.Device) (*typ
 		return nil, err
 	}
 	token, _ := ttnctx.TokenFromIncomingContext(ctx)
-	res, err := b.deviceManager.SetDevice(ttnctx.OutgoingContextWithToken(ctx, token), in)
+	ctx = api.OutgoingContextWithDeviceSettings(ttnctx.OutgoingContextWithToken(ctx, token), api.DeviceSettingsFromIncomingContext(ctx))
+	res, err := b.deviceManager.SetDevice(ctx, in)
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "NetworkServer did not set device")
 	}
@@ -87,6 +89,9 @@ func (b *brokerManager) RegisterApplicationHandler(ctx context.Context, in *pb.A
 	if !claims.AppRight(in.AppID, rights.AppSettings) {
 		return nil, errors.NewErrPermissionDenied("No access to this application")
 	}
+	if err := b.handshake(ctx, in); err != nil {
+		return nil, err
+	}
 	// Add Handler in local cache
 	handler, err := b.broker.Discovery.Get("handler", in.HandlerID)
 	if err != nil {
@@ -17,6 +17,25 @@ var duplicatesHistogram = prometheus.NewHistogram(
 	},
 )
 
+var lateDuplicatesCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "late_duplicates_total",
+		Help:      "Number of duplicates that arrived after the deduplication window.",
+	},
+)
+
+var lateDuplicatesHistogram = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "late_duplicate_delay_seconds",
+		Help:      "Histogram of the time between the first message and duplicates that arrived after the deduplication window.",
+		Buckets:   []float64{0.05, 0.1, 0.2, 0.4, 0.8, 1.6, 3.2},
+	},
+)
+
 var micChecksHistogram = prometheus.NewHistogram(
 	prometheus.HistogramOpts{
 		Namespace: "ttn",
@@ -27,6 +46,51 @@ var micChecksHistogram = prometheus.NewHistogram(
 	},
 )
 
+var rejectedUplinksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "rejected_uplinks_total",
+		Help:      "Number of uplinks that were rejected, by reason.",
+	}, []string{"reason"},
+)
+
+var suspectedReplaysCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "suspected_replays_total",
+		Help:      "Number of uplinks that are suspected to be replayed or relayed.",
+	}, []string{"suspicion"},
+)
+
+var deadlineExceededCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "downlink_deadline_exceeded_total",
+		Help:      "Number of downlink options that were skipped because their deadline passed.",
+	}, []string{"stage"},
+)
+
+var blacklistedCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "blacklisted_devaddrs_total",
+		Help:      "Number of DevAddrs that were blacklisted, by the reason of the last failure.",
+	}, []string{"reason"},
+)
+
+var deferredActivationsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "broker",
+		Name:      "deferred_activations_total",
+		Help:      "Number of activations that were deferred during join storms.",
+	}, []string{"reason"},
+)
+
 var connectedRouters = prometheus.NewGauge(
 	prometheus.GaugeOpts{
 		Namespace: "ttn",
@@ -53,7 +117,14 @@ func initMetrics() {
 	}
 	initialized = true
 	prometheus.MustRegister(duplicatesHistogram)
+	prometheus.MustRegister(lateDuplicatesCounter)
+	prometheus.MustRegister(lateDuplicatesHistogram)
 	prometheus.MustRegister(micChecksHistogram)
+	prometheus.MustRegister(rejectedUplinksCounter)
+	prometheus.MustRegister(suspectedReplaysCounter)
+	prometheus.MustRegister(deadlineExceededCounter)
+	prometheus.MustRegister(blacklistedCounter)
+	prometheus.MustRegister(deferredActivationsCounter)
 	prometheus.MustRegister(connectedRouters)
 	prometheus.MustRegister(connectedHandlers)
 }
@@ -0,0 +1,56 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"strings"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/api/logfields"
+	"github.com/TheThingsNetwork/api/trace"
+	"github.com/TheThingsNetwork/ttn/core/broker/peering"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// WithPeering exports the uplinks with a DevAddr outside the prefixes of the NetworkServer to the packet exchange, and
+// sends the downlinks that other networks send back
+func (b *broker) WithPeering(e *peering.Exchange) Broker {
+	b.peering = e
+	return b
+}
+
+// isForeignDevAddr returns true if the DevAddr is not in one of the prefixes of our NetworkServer, so it belongs to
+// another network. If the prefixes are unknown, no DevAddr is considered foreign.
+func (b *broker) isForeignDevAddr(devAddr types.DevAddr) bool {
+	if len(b.ownPrefixes) == 0 {
+		return false
+	}
+	for _, prefix := range b.ownPrefixes {
+		if devAddr.HasPrefix(prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// handlePeeringDownlink sends a downlink of another network to the Router of its downlink option. It does not pass
+// through the NetworkServer, as the device is not in this network.
+func (b *broker) handlePeeringDownlink(downlink *pb.DownlinkMessage) error {
+	ctx := b.Ctx.WithFields(logfields.ForMessage(downlink))
+	var routerID string
+	if id := strings.Split(downlink.DownlinkOption.Identifier, ":"); len(id) == 2 {
+		routerID = id[0]
+	} else {
+		return errors.NewErrInvalidArgument("DownlinkOption Identifier", "invalid format")
+	}
+	router, err := b.getRouter(routerID)
+	if err != nil {
+		return err
+	}
+	downlink.Trace = downlink.Trace.WithEvent(trace.ForwardEvent, "router", routerID, "peering", true)
+	router <- downlink
+	ctx.WithField("RouterID", routerID).Debug("Forwarded peering downlink")
+	return nil
+}
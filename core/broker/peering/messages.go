@@ -0,0 +1,70 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package peering
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// UplinkMessage is an uplink that a gateway of the forwarding network received for a device of another network
+type UplinkMessage struct {
+	ForwarderID string             `protobuf:"bytes,1,opt,name=forwarder_id,json=forwarderId,proto3" json:"forwarder_id,omitempty"`
+	ServerTime  int64              `protobuf:"varint,2,opt,name=server_time,json=serverTime,proto3" json:"server_time,omitempty"`
+	PHYPayload  []byte             `protobuf:"bytes,3,opt,name=phy_payload,json=phyPayload,proto3" json:"phy_payload,omitempty"`
+	Modulation  string             `protobuf:"bytes,4,opt,name=modulation,proto3" json:"modulation,omitempty"`
+	DataRate    string             `protobuf:"bytes,5,opt,name=data_rate,json=dataRate,proto3" json:"data_rate,omitempty"`
+	BitRate     uint32             `protobuf:"varint,6,opt,name=bit_rate,json=bitRate,proto3" json:"bit_rate,omitempty"`
+	CodingRate  string             `protobuf:"bytes,7,opt,name=coding_rate,json=codingRate,proto3" json:"coding_rate,omitempty"`
+	Frequency   uint64             `protobuf:"varint,8,opt,name=frequency,proto3" json:"frequency,omitempty"`
+	Gateways    []*GatewayMetadata `protobuf:"bytes,9,rep,name=gateways" json:"gateways,omitempty"`
+}
+
+func (m *UplinkMessage) Reset()         { *m = UplinkMessage{} }
+func (m *UplinkMessage) String() string { return proto.CompactTextString(m) }
+func (*UplinkMessage) ProtoMessage()    {}
+
+// GatewayMetadata is the reception of an uplink by a gateway
+type GatewayMetadata struct {
+	GatewayID       string            `protobuf:"bytes,1,opt,name=gateway_id,json=gatewayId,proto3" json:"gateway_id,omitempty"`
+	Timestamp       uint32            `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Time            int64             `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"`
+	Channel         uint32            `protobuf:"varint,4,opt,name=channel,proto3" json:"channel,omitempty"`
+	RFChain         uint32            `protobuf:"varint,5,opt,name=rf_chain,json=rfChain,proto3" json:"rf_chain,omitempty"`
+	RSSI            float32           `protobuf:"fixed32,6,opt,name=rssi,proto3" json:"rssi,omitempty"`
+	SNR             float32           `protobuf:"fixed32,7,opt,name=snr,proto3" json:"snr,omitempty"`
+	Latitude        float32           `protobuf:"fixed32,8,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude       float32           `protobuf:"fixed32,9,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Altitude        int32             `protobuf:"varint,10,opt,name=altitude,proto3" json:"altitude,omitempty"`
+	DownlinkOptions []*DownlinkOption `protobuf:"bytes,11,rep,name=downlink_options,json=downlinkOptions" json:"downlink_options,omitempty"`
+}
+
+func (m *GatewayMetadata) Reset()         { *m = GatewayMetadata{} }
+func (m *GatewayMetadata) String() string { return proto.CompactTextString(m) }
+func (*GatewayMetadata) ProtoMessage()    {}
+
+// DownlinkOption is a transmission that the gateway can make in reply to the uplink
+type DownlinkOption struct {
+	Token      string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	Timestamp  uint32 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Frequency  uint64 `protobuf:"varint,3,opt,name=frequency,proto3" json:"frequency,omitempty"`
+	Modulation string `protobuf:"bytes,4,opt,name=modulation,proto3" json:"modulation,omitempty"`
+	DataRate   string `protobuf:"bytes,5,opt,name=data_rate,json=dataRate,proto3" json:"data_rate,omitempty"`
+	BitRate    uint32 `protobuf:"varint,6,opt,name=bit_rate,json=bitRate,proto3" json:"bit_rate,omitempty"`
+	CodingRate string `protobuf:"bytes,7,opt,name=coding_rate,json=codingRate,proto3" json:"coding_rate,omitempty"`
+	Power      int32  `protobuf:"varint,8,opt,name=power,proto3" json:"power,omitempty"`
+}
+
+func (m *DownlinkOption) Reset()         { *m = DownlinkOption{} }
+func (m *DownlinkOption) String() string { return proto.CompactTextString(m) }
+func (*DownlinkOption) ProtoMessage()    {}
+
+// DownlinkMessage is a downlink that another network sends with one of the downlink options of an uplink
+type DownlinkMessage struct {
+	Token      string `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	PHYPayload []byte `protobuf:"bytes,2,opt,name=phy_payload,json=phyPayload,proto3" json:"phy_payload,omitempty"`
+}
+
+func (m *DownlinkMessage) Reset()         { *m = DownlinkMessage{} }
+func (m *DownlinkMessage) String() string { return proto.CompactTextString(m) }
+func (*DownlinkMessage) ProtoMessage()    {}
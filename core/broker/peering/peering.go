@@ -0,0 +1,228 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package peering exports the uplinks of devices of other networks to a packet exchange, and sends the downlinks that
+// the other networks send back through the gateways that received the uplinks
+package peering
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/TheThingsNetwork/ttn/utils/random"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gogo/protobuf/proto"
+)
+
+// BufferSize is the number of uplinks that are buffered before uplinks are dropped
+var BufferSize = 1024
+
+// OptionTTL is the time that the downlink options of an exported uplink can be used
+var OptionTTL = 10 * time.Second
+
+// Config is the packet exchange that the uplinks are exported to
+type Config struct {
+	Server   string
+	Username string
+	Password string
+	Topic    string // uplinks are published to Topic/ForwarderID/uplink, downlinks are received on Topic/ForwarderID/downlink
+}
+
+// DownlinkHandler sends a downlink of another network
+type DownlinkHandler func(downlink *pb_broker.DownlinkMessage) error
+
+// Exchange exports uplinks to a packet exchange without blocking the caller
+type Exchange struct {
+	config      Config
+	forwarderID string
+	client      MQTT.Client
+	queue       chan *UplinkMessage
+	done        chan struct{}
+	closeOnce   sync.Once
+
+	mu      sync.Mutex
+	options map[string]*pb_broker.DownlinkOption
+}
+
+// NewExchange returns a new Exchange
+func NewExchange(config Config) *Exchange {
+	return &Exchange{
+		config:  config,
+		queue:   make(chan *UplinkMessage, BufferSize),
+		done:    make(chan struct{}),
+		options: make(map[string]*pb_broker.DownlinkOption),
+	}
+}
+
+func (e *Exchange) uplinkTopic() string {
+	return fmt.Sprintf("%s/%s/uplink", e.config.Topic, e.forwarderID)
+}
+
+func (e *Exchange) downlinkTopic() string {
+	return fmt.Sprintf("%s/%s/downlink", e.config.Topic, e.forwarderID)
+}
+
+// Connect connects to the packet exchange as the forwarder with the ID, and exports the queued uplinks until Close is
+// called. The downlinks that other networks send back are passed to the handler.
+func (e *Exchange) Connect(ctx ttnlog.Interface, forwarderID string, handler DownlinkHandler) error {
+	e.forwarderID = forwarderID
+
+	opts := MQTT.NewClientOptions()
+	opts.AddBroker(e.config.Server)
+	opts.SetClientID(fmt.Sprintf("%s-%s", forwarderID, random.String(16)))
+	opts.SetUsername(e.config.Username)
+	opts.SetPassword(e.config.Password)
+	opts.SetCleanSession(true)
+	opts.SetOnConnectHandler(func(client MQTT.Client) {
+		// Also subscribes again after a reconnect
+		token := client.Subscribe(e.downlinkTopic(), 0, func(_ MQTT.Client, msg MQTT.Message) {
+			downlink, err := e.Downlink(msg.Payload())
+			if err == nil {
+				err = handler(downlink)
+			}
+			if err != nil {
+				ctx.WithError(err).Warn("Could not handle peering downlink")
+			}
+		})
+		if token.Wait() && token.Error() != nil {
+			ctx.WithError(token.Error()).Warn("Could not subscribe to peering downlinks")
+		}
+	})
+	e.client = MQTT.NewClient(opts)
+	if token := e.client.Connect(); token.Wait() && token.Error() != nil {
+		return errors.Wrap(token.Error(), "Could not connect to packet exchange")
+	}
+
+	go func() {
+		defer e.client.Disconnect(250)
+		for {
+			select {
+			case uplink := <-e.queue:
+				uplink.ForwarderID = forwarderID
+				data, err := proto.Marshal(uplink)
+				if err != nil {
+					ctx.WithError(err).Warn("Could not encode peering uplink")
+					continue
+				}
+				if token := e.client.Publish(e.uplinkTopic(), 0, false, data); token.Wait() && token.Error() != nil {
+					ctx.WithError(token.Error()).Debug("Could not export uplink")
+				}
+			case <-e.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Export queues the duplicates of an uplink for the packet exchange. If the queue is full or the exchange is closed,
+// the uplink is dropped.
+func (e *Exchange) Export(serverTime int64, duplicates []*pb_broker.UplinkMessage) {
+	select {
+	case <-e.done:
+		return
+	default:
+	}
+	select {
+	case e.queue <- e.newUplink(serverTime, duplicates):
+	default:
+	}
+}
+
+// newUplink converts the duplicates of an uplink. It only contains the metadata that other networks need to handle
+// the uplink, so it leaves out the trust of gateways, the encrypted fine timestamps and the internal identifiers of the
+// downlink options.
+func (e *Exchange) newUplink(serverTime int64, duplicates []*pb_broker.UplinkMessage) *UplinkMessage {
+	uplink := &UplinkMessage{ServerTime: serverTime}
+	if len(duplicates) > 0 {
+		uplink.PHYPayload = duplicates[0].Payload
+		if lorawan := duplicates[0].ProtocolMetadata.GetLoRaWAN(); lorawan != nil {
+			uplink.Modulation = lorawan.Modulation.String()
+			uplink.DataRate = lorawan.DataRate
+			uplink.BitRate = lorawan.BitRate
+			uplink.CodingRate = lorawan.CodingRate
+		}
+		uplink.Frequency = duplicates[0].GatewayMetadata.Frequency
+	}
+	for _, duplicate := range duplicates {
+		in := duplicate.GatewayMetadata
+		gateway := &GatewayMetadata{
+			GatewayID: in.GatewayID,
+			Timestamp: in.Timestamp,
+			Time:      in.Time,
+			Channel:   in.Channel,
+			RFChain:   in.RfChain,
+			RSSI:      in.RSSI,
+			SNR:       in.SNR,
+		}
+		if location := in.GetLocation(); location != nil {
+			gateway.Latitude = location.Latitude
+			gateway.Longitude = location.Longitude
+			gateway.Altitude = location.Altitude
+		}
+		for _, option := range duplicate.DownlinkOptions {
+			gateway.DownlinkOptions = append(gateway.DownlinkOptions, e.newDownlinkOption(option))
+		}
+		uplink.Gateways = append(uplink.Gateways, gateway)
+	}
+	return uplink
+}
+
+// newDownlinkOption converts the downlink option and remembers it under a random token during the OptionTTL
+func (e *Exchange) newDownlinkOption(in *pb_broker.DownlinkOption) *DownlinkOption {
+	token := random.String(16)
+	e.mu.Lock()
+	e.options[token] = in
+	e.mu.Unlock()
+	time.AfterFunc(OptionTTL, func() {
+		e.mu.Lock()
+		delete(e.options, token)
+		e.mu.Unlock()
+	})
+
+	option := &DownlinkOption{
+		Token:     token,
+		Timestamp: in.GatewayConfiguration.Timestamp,
+		Frequency: in.GatewayConfiguration.Frequency,
+		Power:     in.GatewayConfiguration.Power,
+	}
+	if lorawan := in.ProtocolConfiguration.GetLoRaWAN(); lorawan != nil {
+		option.Modulation = lorawan.Modulation.String()
+		option.DataRate = lorawan.DataRate
+		option.BitRate = lorawan.BitRate
+		option.CodingRate = lorawan.CodingRate
+	}
+	return option
+}
+
+// Downlink decodes a downlink of another network and returns it with the downlink option of its token. A token can be
+// used once.
+func (e *Exchange) Downlink(data []byte) (*pb_broker.DownlinkMessage, error) {
+	in := new(DownlinkMessage)
+	if err := proto.Unmarshal(data, in); err != nil {
+		return nil, errors.NewErrInvalidArgument("Peering downlink", err.Error())
+	}
+	if len(in.PHYPayload) == 0 {
+		return nil, errors.NewErrInvalidArgument("Peering downlink", "does not contain a PHYPayload")
+	}
+	e.mu.Lock()
+	option, ok := e.options[in.Token]
+	delete(e.options, in.Token)
+	e.mu.Unlock()
+	if !ok {
+		return nil, errors.NewErrNotFound(fmt.Sprintf("Downlink option %s", in.Token))
+	}
+	return &pb_broker.DownlinkMessage{
+		Payload:        in.PHYPayload,
+		DownlinkOption: option,
+	}, nil
+}
+
+// Close the exchange. The queue is not closed, so that Export can be called concurrently.
+func (e *Exchange) Close() {
+	e.closeOnce.Do(func() { close(e.done) })
+}
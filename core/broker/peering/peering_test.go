@@ -0,0 +1,104 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package peering
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestExchange(t *testing.T) {
+	a := New(t)
+
+	e := NewExchange(Config{Topic: "peering"})
+	option := &pb_broker.DownlinkOption{
+		Identifier: "router:1",
+		GatewayID:  "gtw",
+		ProtocolConfiguration: pb_protocol.TxConfiguration{Protocol: &pb_protocol.TxConfiguration_LoRaWAN{LoRaWAN: &pb_lorawan.TxConfiguration{
+			Modulation: pb_lorawan.Modulation_LORA,
+			DataRate:   "SF7BW125",
+			CodingRate: "4/5",
+		}}},
+		GatewayConfiguration: pb_gateway.TxConfiguration{
+			Timestamp: 1001000,
+			Frequency: 868100000,
+			Power:     14,
+		},
+	}
+	duplicates := []*pb_broker.UplinkMessage{
+		{
+			Payload: []byte{1, 2, 3},
+			ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{
+				Modulation: pb_lorawan.Modulation_LORA,
+				DataRate:   "SF7BW125",
+				CodingRate: "4/5",
+			}}},
+			GatewayMetadata: pb_gateway.RxMetadata{
+				GatewayID:      "gtw",
+				GatewayTrusted: true,
+				Timestamp:      1000,
+				Frequency:      868100000,
+				RSSI:           -42,
+				SNR:            7.5,
+				Antennas:       []*pb_gateway.RxMetadata_Antenna{{EncryptedTime: []byte{1, 2, 3, 4}}},
+				Location:       &pb_gateway.LocationMetadata{Latitude: 52.37, Longitude: 4.89},
+			},
+			DownlinkOptions: []*pb_broker.DownlinkOption{option},
+		},
+		{
+			Payload:         []byte{1, 2, 3},
+			GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "other-gtw", Timestamp: 2000},
+		},
+	}
+
+	uplink := e.newUplink(1497367736000000000, duplicates)
+	data, err := proto.Marshal(uplink)
+	a.So(err, ShouldBeNil)
+	decoded := new(UplinkMessage)
+	a.So(proto.Unmarshal(data, decoded), ShouldBeNil)
+	a.So(decoded, ShouldResemble, uplink)
+
+	a.So(decoded.PHYPayload, ShouldResemble, []byte{1, 2, 3})
+	a.So(decoded.DataRate, ShouldEqual, "SF7BW125")
+	a.So(decoded.Frequency, ShouldEqual, 868100000)
+	a.So(decoded.Gateways, ShouldHaveLength, 2)
+	a.So(decoded.Gateways[0].RSSI, ShouldEqual, -42)
+	a.So(decoded.Gateways[0].Latitude, ShouldEqual, float32(52.37))
+	a.So(decoded.Gateways[0].DownlinkOptions, ShouldHaveLength, 1)
+	a.So(decoded.Gateways[1].DownlinkOptions, ShouldBeEmpty)
+
+	exported := decoded.Gateways[0].DownlinkOptions[0]
+	a.So(exported.Token, ShouldNotEqual, option.Identifier)
+	a.So(exported.Timestamp, ShouldEqual, 1001000)
+	a.So(exported.DataRate, ShouldEqual, "SF7BW125")
+	a.So(exported.Power, ShouldEqual, 14)
+
+	// Downlinks without payload are rejected
+	data, _ = proto.Marshal(&DownlinkMessage{Token: exported.Token})
+	_, err = e.Downlink(data)
+	a.So(errors.GetErrType(err), ShouldEqual, errors.InvalidArgument)
+
+	data, _ = proto.Marshal(&DownlinkMessage{Token: exported.Token, PHYPayload: []byte{4, 5, 6}})
+	downlink, err := e.Downlink(data)
+	a.So(err, ShouldBeNil)
+	a.So(downlink.Payload, ShouldResemble, []byte{4, 5, 6})
+	a.So(downlink.DownlinkOption, ShouldEqual, option)
+
+	// Tokens can be used once
+	_, err = e.Downlink(data)
+	a.So(errors.GetErrType(err), ShouldEqual, errors.NotFound)
+
+	// Uplinks are dropped after Close
+	e.Close()
+	e.Export(1497367736000000000, duplicates)
+	a.So(e.queue, ShouldBeEmpty)
+	e.Close()
+}
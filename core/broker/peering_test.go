@@ -0,0 +1,25 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestIsForeignDevAddr(t *testing.T) {
+	a := New(t)
+
+	b := &broker{}
+
+	// Unknown prefixes
+	a.So(b.isForeignDevAddr(types.DevAddr{0x26, 0x01, 0x02, 0x03}), ShouldBeFalse)
+
+	prefix, _ := types.ParseDevAddrPrefix("26000000/7")
+	b.ownPrefixes = []types.DevAddrPrefix{prefix}
+	a.So(b.isForeignDevAddr(types.DevAddr{0x26, 0x01, 0x02, 0x03}), ShouldBeFalse)
+	a.So(b.isForeignDevAddr(types.DevAddr{0x00, 0x01, 0x02, 0x03}), ShouldBeTrue)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// WithPrivateGateways only forwards the uplinks and activations that private gateways received if the device belongs
+// to an application of their owner. The Broker filters them after it found the device, because the Router only
+// knows the DevAddr or JoinEUI.
+func (b *broker) WithPrivateGateways(gateways types.PrivateGateways) Broker {
+	b.privateGateways = gateways
+	return b
+}
+
+// filterPrivateUplinks returns the duplicates of an uplink of the application that were not received by private
+// gateways of other owners
+func (b *broker) filterPrivateUplinks(appID string, duplicates []*pb.UplinkMessage) []*pb.UplinkMessage {
+	if b.privateGateways == nil {
+		return duplicates
+	}
+	allowed := make([]*pb.UplinkMessage, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		if b.privateGateways.Allows(duplicate.GatewayMetadata.GatewayID, appID) {
+			allowed = append(allowed, duplicate)
+		}
+	}
+	return allowed
+}
+
+// filterPrivateActivation returns the gateway metadata and downlink candidates of an activation of the application
+// that do not belong to private gateways of other owners
+func (b *broker) filterPrivateActivation(appID string, gatewayMetadata []*pb_gateway.RxMetadata, candidates []downlinkCandidate) ([]*pb_gateway.RxMetadata, []downlinkCandidate) {
+	allowedMetadata := make([]*pb_gateway.RxMetadata, 0, len(gatewayMetadata))
+	for _, metadata := range gatewayMetadata {
+		if b.privateGateways.Allows(metadata.GatewayID, appID) {
+			allowedMetadata = append(allowedMetadata, metadata)
+		}
+	}
+	allowedCandidates := make([]downlinkCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if b.privateGateways.Allows(candidate.option.GatewayID, appID) {
+			allowedCandidates = append(allowedCandidates, candidate)
+		}
+	}
+	return allowedMetadata, allowedCandidates
+}
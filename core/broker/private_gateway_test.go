@@ -0,0 +1,43 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestFilterPrivateGateways(t *testing.T) {
+	a := New(t)
+
+	public := &pb.UplinkMessage{GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "public"}}
+	private := &pb.UplinkMessage{GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "private"}}
+	duplicates := []*pb.UplinkMessage{public, private}
+
+	b := &broker{}
+	a.So(b.filterPrivateUplinks("other-app", duplicates), ShouldResemble, duplicates)
+
+	gateways, _ := types.ParsePrivateGateways("private=owner-app")
+	b.WithPrivateGateways(gateways)
+	a.So(b.filterPrivateUplinks("owner-app", duplicates), ShouldResemble, duplicates)
+	a.So(b.filterPrivateUplinks("other-app", duplicates), ShouldResemble, []*pb.UplinkMessage{public})
+	a.So(b.filterPrivateUplinks("other-app", []*pb.UplinkMessage{private}), ShouldBeEmpty)
+
+	metadata := []*pb_gateway.RxMetadata{&public.GatewayMetadata, &private.GatewayMetadata}
+	candidates := []downlinkCandidate{
+		{option: &pb.DownlinkOption{GatewayID: "public"}},
+		{option: &pb.DownlinkOption{GatewayID: "private"}},
+	}
+	filteredMetadata, filteredCandidates := b.filterPrivateActivation("other-app", metadata, candidates)
+	a.So(filteredMetadata, ShouldResemble, []*pb_gateway.RxMetadata{&public.GatewayMetadata})
+	a.So(filteredCandidates, ShouldHaveLength, 1)
+	a.So(filteredCandidates[0].option.GatewayID, ShouldEqual, "public")
+	filteredMetadata, filteredCandidates = b.filterPrivateActivation("owner-app", metadata, candidates)
+	a.So(filteredMetadata, ShouldHaveLength, 2)
+	a.So(filteredCandidates, ShouldHaveLength, 2)
+}
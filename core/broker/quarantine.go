@@ -0,0 +1,85 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/broker/quarantine"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// ProvisionQueueSize is the number of devices that wait to be posted to the auto-provisioning webhook. Devices
+// are posted one at a time; when the queue is full, devices that enter the quarantine are not posted.
+var ProvisionQueueSize = 100
+
+// WithQuarantine collects the messages of unknown devices in the quarantine. If the hook is not nil,
+// devices that enter the quarantine are posted to it, so that they can be provisioned on the fly.
+func (b *broker) WithQuarantine(q *quarantine.Quarantine, hook *quarantine.Hook) Broker {
+	b.quarantine = q
+	b.provisionHook = hook
+	if hook != nil {
+		b.provisionQueue = make(chan quarantine.Entry, ProvisionQueueSize)
+		go b.notifyProvisionHook()
+	}
+	return b
+}
+
+func (b *broker) notifyProvisionHook() {
+	for entry := range b.provisionQueue {
+		if err := b.provisionHook.Notify(&entry); err != nil {
+			b.Ctx.WithError(err).Warn("Could not call auto-provisioning webhook")
+		}
+	}
+}
+
+func (b *broker) quarantineUplink(ctx ttnlog.Interface, reason string, devAddr types.DevAddr, duplicates []*pb.UplinkMessage) {
+	if b.quarantine == nil || len(duplicates) == 0 {
+		return
+	}
+	gateways := make([]pb_gateway.RxMetadata, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		gateways = append(gateways, duplicate.GatewayMetadata)
+	}
+	b.quarantineDevice(ctx, quarantine.Entry{
+		Reason:           reason,
+		DevAddr:          &devAddr,
+		ProtocolMetadata: duplicates[0].ProtocolMetadata,
+		GatewayMetadata:  quarantine.BestGateway(gateways),
+	})
+}
+
+func (b *broker) quarantineActivation(ctx ttnlog.Interface, activation *pb.DeduplicatedDeviceActivationRequest) {
+	if b.quarantine == nil {
+		return
+	}
+	gateways := make([]pb_gateway.RxMetadata, 0, len(activation.GatewayMetadata))
+	for _, gateway := range activation.GatewayMetadata {
+		gateways = append(gateways, *gateway)
+	}
+	appEUI, devEUI := activation.AppEUI, activation.DevEUI
+	b.quarantineDevice(ctx, quarantine.Entry{
+		Reason:           RejectUnknownDevEUI,
+		AppEUI:           &appEUI,
+		DevEUI:           &devEUI,
+		ProtocolMetadata: activation.ProtocolMetadata,
+		GatewayMetadata:  quarantine.BestGateway(gateways),
+	})
+}
+
+func (b *broker) quarantineDevice(ctx ttnlog.Interface, msg quarantine.Entry) {
+	entry, isNew := b.quarantine.Add(msg)
+	if !isNew {
+		return
+	}
+	ctx.WithField("Reason", entry.Reason).Debug("Unknown device entered quarantine")
+	if b.provisionQueue != nil {
+		select {
+		case b.provisionQueue <- entry:
+		default:
+			ctx.Warn("Auto-provisioning webhook queue is full, not posting device")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package quarantine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout of the auto-provisioning webhook
+const DefaultTimeout = 5 * time.Second
+
+// Hook posts devices that enter the quarantine to an auto-provisioning webhook. The webhook can register
+// the device, so that its next message is accepted.
+type Hook struct {
+	Address string
+	Client  *http.Client
+}
+
+// NewHook returns a new Hook that posts to the address
+func NewHook(address string) *Hook {
+	return &Hook{
+		Address: address,
+		Client:  &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Notify posts the entry to the webhook
+func (h *Hook) Notify(entry *Entry) error {
+	body, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	res, err := h.Client.Post(h.Address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Auto-provisioning webhook returned status %s", res.Status)
+	}
+	return nil
+}
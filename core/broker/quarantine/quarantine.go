@@ -0,0 +1,149 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package quarantine keeps track of the devices that send messages to the Broker, but are not known to the network
+package quarantine
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Path is the path where the quarantine is served on the status server
+const Path = "/quarantine"
+
+// Entry is a device in the quarantine. Uplinks are identified by DevAddr and activations by AppEUI and DevEUI.
+// The metadata is that of the last message of the device, as received by the gateway with the best signal.
+type Entry struct {
+	Reason           string                 `json:"reason"`
+	DevAddr          *types.DevAddr         `json:"dev_addr,omitempty"`
+	AppEUI           *types.AppEUI          `json:"app_eui,omitempty"`
+	DevEUI           *types.DevEUI          `json:"dev_eui,omitempty"`
+	Count            uint64                 `json:"count"`
+	FirstSeen        time.Time              `json:"first_seen"`
+	LastSeen         time.Time              `json:"last_seen"`
+	ProtocolMetadata pb_protocol.RxMetadata `json:"protocol_metadata"`
+	GatewayMetadata  pb_gateway.RxMetadata  `json:"gateway_metadata"`
+}
+
+func (e *Entry) key() string {
+	key := e.Reason
+	if e.DevAddr != nil {
+		key += "/" + e.DevAddr.String()
+	}
+	if e.AppEUI != nil {
+		key += "/" + e.AppEUI.String()
+	}
+	if e.DevEUI != nil {
+		key += "/" + e.DevEUI.String()
+	}
+	return key
+}
+
+// BestGateway returns the metadata of the gateway that received the message with the best signal
+func BestGateway(gateways []pb_gateway.RxMetadata) (best pb_gateway.RxMetadata) {
+	for i, gateway := range gateways {
+		if i == 0 || gateway.SNR > best.SNR || (gateway.SNR == best.SNR && gateway.RSSI > best.RSSI) {
+			best = gateway
+		}
+	}
+	return
+}
+
+// Quarantine is a bounded list of unknown devices. When it is full, the device that was seen least recently is removed.
+type Quarantine struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // entries, the least recently seen first
+}
+
+// NewQuarantine returns a new Quarantine that holds at most size devices
+func NewQuarantine(size int) *Quarantine {
+	return &Quarantine{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Add records a message of an unknown device. It returns the updated entry, and true if the device was not in the quarantine yet.
+func (q *Quarantine) Add(msg Entry) (Entry, bool) {
+	now := time.Now()
+	key := msg.key()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var entry *Entry
+	element, ok := q.entries[key]
+	if ok {
+		entry = element.Value.(*Entry)
+		q.order.MoveToBack(element)
+	} else {
+		if len(q.entries) >= q.size {
+			q.evict()
+		}
+		entry = &Entry{
+			Reason:    msg.Reason,
+			DevAddr:   msg.DevAddr,
+			AppEUI:    msg.AppEUI,
+			DevEUI:    msg.DevEUI,
+			FirstSeen: now,
+		}
+		q.entries[key] = q.order.PushBack(entry)
+	}
+	entry.Count++
+	entry.LastSeen = now
+	entry.ProtocolMetadata = msg.ProtocolMetadata
+	entry.GatewayMetadata = msg.GatewayMetadata
+	return *entry, !ok
+}
+
+// evict removes the device that was seen least recently. The mutex must be held.
+func (q *Quarantine) evict() {
+	oldest := q.order.Front()
+	if oldest == nil {
+		return
+	}
+	q.order.Remove(oldest)
+	delete(q.entries, oldest.Value.(*Entry).key())
+}
+
+// Entries returns the devices in the quarantine, the most recently seen first
+func (q *Quarantine) Entries() []Entry {
+	q.mu.Lock()
+	entries := make([]Entry, 0, len(q.entries))
+	for element := q.order.Back(); element != nil; element = element.Prev() {
+		entries = append(entries, *element.Value.(*Entry))
+	}
+	q.mu.Unlock()
+	return entries
+}
+
+// Clear removes all devices from the quarantine
+func (q *Quarantine) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = make(map[string]*list.Element)
+	q.order.Init()
+}
+
+// ServeHTTP lists the devices in the quarantine on GET, and clears the quarantine on DELETE
+func (q *Quarantine) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(q.Entries())
+	case "DELETE":
+		q.Clear()
+		res.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
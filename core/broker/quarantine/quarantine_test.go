@@ -0,0 +1,91 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package quarantine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestQuarantine(t *testing.T) {
+	a := New(t)
+
+	q := NewQuarantine(2)
+
+	devAddr1, devAddr2 := types.DevAddr{1, 2, 3, 4}, types.DevAddr{5, 6, 7, 8}
+	devEUI, appEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}, types.AppEUI{8, 7, 6, 5, 4, 3, 2, 1}
+
+	entry, isNew := q.Add(Entry{Reason: "unknown_devaddr", DevAddr: &devAddr1})
+	a.So(isNew, ShouldBeTrue)
+	a.So(entry.Count, ShouldEqual, 1)
+
+	entry, isNew = q.Add(Entry{Reason: "unknown_devaddr", DevAddr: &devAddr1, GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "gtw", RSSI: -42}})
+	a.So(isNew, ShouldBeFalse)
+	a.So(entry.Count, ShouldEqual, 2)
+	a.So(entry.GatewayMetadata.GatewayID, ShouldEqual, "gtw")
+
+	q.Add(Entry{Reason: "unknown_deveui", AppEUI: &appEUI, DevEUI: &devEUI})
+	entries := q.Entries()
+	a.So(entries, ShouldHaveLength, 2)
+	a.So(*entries[0].DevEUI, ShouldEqual, devEUI)
+
+	// The least recently seen device is evicted
+	q.Add(Entry{Reason: "unknown_devaddr", DevAddr: &devAddr2})
+	entries = q.Entries()
+	a.So(entries, ShouldHaveLength, 2)
+	a.So(*entries[0].DevAddr, ShouldEqual, devAddr2)
+	a.So(*entries[1].DevEUI, ShouldEqual, devEUI)
+
+	// HTTP
+	res := httptest.NewRecorder()
+	q.ServeHTTP(res, httptest.NewRequest("GET", Path, nil))
+	var listed []Entry
+	a.So(json.NewDecoder(res.Body).Decode(&listed), ShouldBeNil)
+	a.So(listed, ShouldHaveLength, 2)
+
+	res = httptest.NewRecorder()
+	q.ServeHTTP(res, httptest.NewRequest("DELETE", Path, nil))
+	a.So(res.Code, ShouldEqual, http.StatusNoContent)
+	a.So(q.Entries(), ShouldBeEmpty)
+}
+
+func TestBestGateway(t *testing.T) {
+	a := New(t)
+
+	a.So(BestGateway(nil).GatewayID, ShouldEqual, "")
+	a.So(BestGateway([]pb_gateway.RxMetadata{
+		{GatewayID: "weak", SNR: -10, RSSI: -120},
+		{GatewayID: "strong", SNR: 7, RSSI: -60},
+		{GatewayID: "loud", SNR: 7, RSSI: -80},
+	}).GatewayID, ShouldEqual, "strong")
+}
+
+func TestHook(t *testing.T) {
+	a := New(t)
+
+	var received Entry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	devAddr := types.DevAddr{1, 2, 3, 4}
+	err := NewHook(server.URL).Notify(&Entry{Reason: "unknown_devaddr", DevAddr: &devAddr, Count: 1})
+	a.So(err, ShouldBeNil)
+	a.So(received.Reason, ShouldEqual, "unknown_devaddr")
+	a.So(*received.DevAddr, ShouldEqual, devAddr)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	a.So(NewHook(failing.URL).Notify(&Entry{}), ShouldNotBeNil)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/broker/quarantine"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestQuarantineProvisionHook(t *testing.T) {
+	a := New(t)
+
+	release := make(chan struct{})
+	var calls, concurrent, maxConcurrent int32
+	server := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if n := atomic.AddInt32(&concurrent, 1); n > atomic.LoadInt32(&maxConcurrent) {
+			atomic.StoreInt32(&maxConcurrent, n)
+		}
+		<-release
+		atomic.AddInt32(&concurrent, -1)
+	}))
+	defer server.Close()
+
+	defer func(size int) { ProvisionQueueSize = size }(ProvisionQueueSize)
+	ProvisionQueueSize = 2
+
+	b := &broker{Component: &component.Component{Ctx: GetLogger(t, "TestQuarantineProvisionHook")}}
+	b.WithQuarantine(quarantine.NewQuarantine(100), quarantine.NewHook(server.URL))
+
+	// A flood of unknown devices does not become a flood of webhook calls
+	for i := 0; i < 10; i++ {
+		devAddr := types.DevAddr{0, 0, 0, byte(i)}
+		b.quarantineDevice(b.Ctx, quarantine.Entry{Reason: RejectUnknownDevAddr, DevAddr: &devAddr})
+	}
+	close(release)
+	close(b.provisionQueue)
+	server.Close() // Waits for the outstanding requests
+
+	a.So(atomic.LoadInt32(&maxConcurrent), ShouldEqual, 1)
+	a.So(atomic.LoadInt32(&calls), ShouldBeLessThanOrEqualTo, ProvisionQueueSize+1)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// redisDeduplicator deduplicates values of all processes that use the same Redis database. The process that first
+// gets a key claims it with SET NX and collects the values during the window. The other processes hand their values
+// over to that process through a Redis list.
+type redisDeduplicator struct {
+	*deduplicator
+	client   *redis.Client
+	prefix   string
+	newValue func() interface{}
+
+	// keys that were claimed by another process
+	elsewhere map[string]bool
+}
+
+// NewRedisDeduplicator returns a Deduplicator that deduplicates values across the processes that use the same Redis
+// database, such as the replicas of a broker. The values must be protocol buffers; newValue returns an empty value
+// to unmarshal the values of other processes into. If Redis is unavailable, values are deduplicated per process.
+func NewRedisDeduplicator(client *redis.Client, prefix string, window WindowFunc, newValue func() interface{}) Deduplicator {
+	return &redisDeduplicator{
+		deduplicator: &deduplicator{
+			window:      window,
+			collections: map[string]*collection{},
+		},
+		client:    client,
+		prefix:    prefix,
+		newValue:  newValue,
+		elsewhere: map[string]bool{},
+	}
+}
+
+func (d *redisDeduplicator) claimKey(key string) string  { return d.prefix + ":" + key }
+func (d *redisDeduplicator) valuesKey(key string) string { return d.prefix + ":" + key + ":values" }
+
+func (d *redisDeduplicator) Deduplicate(key string, value interface{}) (values []interface{}) {
+	timeout := d.window(value)
+
+	d.Lock()
+	_, collecting := d.collections[key]
+	elsewhere := d.elsewhere[key]
+	d.Unlock()
+
+	if !collecting && !elsewhere {
+		claimed, err := d.client.SetNX(d.claimKey(key), 1, 2*timeout).Result()
+		if err == nil && !claimed {
+			elsewhere = true
+			d.Lock()
+			d.elsewhere[key] = true
+			d.Unlock()
+			time.AfterFunc(2*timeout, func() {
+				d.Lock()
+				defer d.Unlock()
+				delete(d.elsewhere, key)
+			})
+		}
+	}
+
+	if elsewhere {
+		d.handOver(key, value, 2*timeout)
+		return nil
+	}
+
+	collection, isFirst := d.add(key, value)
+	if !isFirst {
+		return nil
+	}
+	values = d.collect(key, collection, timeout)
+	return append(values, d.takeOver(key)...)
+}
+
+// handOver pushes the value to the process that claimed the key
+func (d *redisDeduplicator) handOver(key string, value interface{}, ttl time.Duration) {
+	m, ok := value.(marshaler)
+	if !ok {
+		return
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		return
+	}
+	d.client.Pipelined(func(pipe *redis.Pipeline) error {
+		pipe.RPush(d.valuesKey(key), data)
+		pipe.PExpire(d.valuesKey(key), ttl)
+		return nil
+	})
+}
+
+// takeOver returns the values that other processes handed over for the key
+func (d *redisDeduplicator) takeOver(key string) (values []interface{}) {
+	res, err := d.client.LRange(d.valuesKey(key), 0, -1).Result()
+	if err != nil || len(res) == 0 {
+		return nil
+	}
+	d.client.Del(d.valuesKey(key))
+	for _, data := range res {
+		value := d.newValue()
+		if u, ok := value.(unmarshaler); ok && u.Unmarshal([]byte(data)) == nil {
+			values = append(values, value)
+		}
+	}
+	return
+}
@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRedisDeduplicator(t *testing.T) {
+	a := New(t)
+	client := GetRedisClient()
+	window := func(interface{}) time.Duration { return 20 * time.Millisecond }
+	newUplink := func() interface{} { return new(pb.UplinkMessage) }
+
+	// Two replicas of the broker
+	d1 := NewRedisDeduplicator(client, "test-redis-deduplicator", window, newUplink)
+	d2 := NewRedisDeduplicator(client, "test-redis-deduplicator", window, newUplink)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		res := d1.Deduplicate("key", &pb.UplinkMessage{Payload: []byte{1}})
+		a.So(res, ShouldHaveLength, 3)
+		a.So(res[0].(*pb.UplinkMessage).Payload, ShouldResemble, []byte{1})
+		a.So(res[2].(*pb.UplinkMessage).Payload, ShouldResemble, []byte{3})
+		wg.Done()
+	}()
+
+	<-time.After(5 * time.Millisecond)
+
+	a.So(d1.Deduplicate("key", &pb.UplinkMessage{Payload: []byte{2}}), ShouldBeNil)
+	a.So(d2.Deduplicate("key", &pb.UplinkMessage{Payload: []byte{3}}), ShouldBeNil)
+
+	wg.Wait()
+
+	// After the window, the key can be claimed again
+	<-time.After(50 * time.Millisecond)
+	res := d2.Deduplicate("key", &pb.UplinkMessage{Payload: []byte{4}})
+	a.So(res, ShouldHaveLength, 1)
+}
@@ -0,0 +1,132 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"crypto/md5"
+	"math"
+	"sync"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+)
+
+// RetransmissionWindow is the time in which devices may transmit the same frame again (confirmed retries and NbTrans)
+var RetransmissionWindow = 5 * time.Minute
+
+// MaxGatewayDistance is the maximum plausible distance (in km) between two gateways that receive the same transmission
+var MaxGatewayDistance = 150.0
+
+// Suspicions of replay attacks
+const (
+	SuspicionReplay   = "replay"
+	SuspicionDistance = "distance"
+)
+
+// MaxReplayFrames is the maximum number of frames that the replay detection remembers. If more frames are received
+// in the window, the oldest frames are forgotten first.
+var MaxReplayFrames = 500000
+
+// replayDetector remembers uplink frames to detect frames that are received again long after the first reception
+type replayDetector struct {
+	mu     sync.Mutex
+	window time.Duration
+	seen   map[[md5.Size]byte]time.Time
+	order  []replayFrame // in order of first reception
+}
+
+type replayFrame struct {
+	key   [md5.Size]byte
+	first time.Time
+}
+
+func newReplayDetector(window time.Duration) *replayDetector {
+	return &replayDetector{
+		window: window,
+		seen:   make(map[[md5.Size]byte]time.Time),
+	}
+}
+
+// forget removes the frames that are older than the window, and the oldest frames if there are too many
+func (d *replayDetector) forget(now time.Time) {
+	for len(d.order) > 0 {
+		oldest := d.order[0]
+		if now.Sub(oldest.first) <= d.window && len(d.order) <= MaxReplayFrames {
+			return
+		}
+		// The frame may have been received again after the window, in which case it is later in the order
+		if first, ok := d.seen[oldest.key]; ok && first.Equal(oldest.first) {
+			delete(d.seen, oldest.key)
+		}
+		d.order = d.order[1:]
+	}
+}
+
+// Check remembers the frame and returns how long ago it was first received, if it was received before
+// in the window. The frame is suspicious if that is longer ago than the RetransmissionWindow.
+func (d *replayDetector) Check(payload []byte, now time.Time) (since time.Duration, suspicious bool) {
+	key := md5.Sum(payload)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.forget(now)
+	first, ok := d.seen[key]
+	if !ok || now.Sub(first) > d.window {
+		d.seen[key] = now
+		d.order = append(d.order, replayFrame{key: key, first: now})
+		return 0, false
+	}
+	since = now.Sub(first)
+	return since, since > RetransmissionWindow
+}
+
+// checkReplay reports the uplink if it is suspected to be replayed or relayed, and returns the suspicion
+func (b *broker) checkReplay(ctx ttnlog.Interface, uplink *pb.DeduplicatedUplinkMessage, duplicates []*pb.UplinkMessage) (suspicion string) {
+	if b.replays != nil {
+		if since, suspicious := b.replays.Check(uplink.Payload, time.Unix(0, uplink.ServerTime)); suspicious {
+			ctx.WithField("Since", since).Warn("Possible replay attack: frame was received before")
+			suspicion = SuspicionReplay
+			suspectedReplaysCounter.WithLabelValues(suspicion).Inc()
+		}
+	}
+	if distance := maxGatewayDistance(duplicates); distance > MaxGatewayDistance {
+		ctx.WithField("Distance", distance).Warn("Possible relay attack: frame was received by distant gateways")
+		suspicion = SuspicionDistance
+		suspectedReplaysCounter.WithLabelValues(suspicion).Inc()
+	}
+	if suspicion != "" {
+		uplink.Trace = uplink.Trace.WithEvent("suspected replay", "suspicion", suspicion)
+	}
+	return
+}
+
+// maxGatewayDistance returns the largest distance (in km) between the gateways that received the duplicates
+func maxGatewayDistance(duplicates []*pb.UplinkMessage) (distance float64) {
+	var locations []*pb_gateway.LocationMetadata
+	for _, duplicate := range duplicates {
+		if location := duplicate.GatewayMetadata.GetLocation(); location != nil && (location.Latitude != 0 || location.Longitude != 0) {
+			locations = append(locations, location)
+		}
+	}
+	for i := range locations {
+		for j := i + 1; j < len(locations); j++ {
+			if d := haversine(locations[i], locations[j]); d > distance {
+				distance = d
+			}
+		}
+	}
+	return
+}
+
+const earthRadius = 6371.0 // km
+
+// haversine returns the great-circle distance (in km) between two locations
+func haversine(a, b *pb_gateway.LocationMetadata) float64 {
+	lat1, lat2 := float64(a.Latitude)*math.Pi/180, float64(b.Latitude)*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := float64(b.Longitude-a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,81 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestReplayDetector(t *testing.T) {
+	a := New(t)
+
+	d := newReplayDetector(time.Hour)
+	now := time.Now()
+
+	_, suspicious := d.Check([]byte{1, 2, 3}, now)
+	a.So(suspicious, ShouldBeFalse)
+
+	// Retransmissions are not suspicious
+	since, suspicious := d.Check([]byte{1, 2, 3}, now.Add(10*time.Second))
+	a.So(since, ShouldEqual, 10*time.Second)
+	a.So(suspicious, ShouldBeFalse)
+
+	_, suspicious = d.Check([]byte{1, 2, 4}, now.Add(10*time.Minute))
+	a.So(suspicious, ShouldBeFalse)
+
+	_, suspicious = d.Check([]byte{1, 2, 3}, now.Add(10*time.Minute))
+	a.So(suspicious, ShouldBeTrue)
+
+	// Frames are forgotten after the window
+	_, suspicious = d.Check([]byte{1, 2, 3}, now.Add(2*time.Hour))
+	a.So(suspicious, ShouldBeFalse)
+	a.So(d.seen, ShouldHaveLength, 1)
+	a.So(d.order, ShouldHaveLength, 1)
+}
+
+func TestReplayDetectorLimit(t *testing.T) {
+	a := New(t)
+
+	defer func(max int) { MaxReplayFrames = max }(MaxReplayFrames)
+	MaxReplayFrames = 2
+
+	d := newReplayDetector(time.Hour)
+	now := time.Now()
+
+	d.Check([]byte{1}, now)
+	d.Check([]byte{2}, now.Add(time.Minute))
+	d.Check([]byte{3}, now.Add(2*time.Minute))
+	a.So(d.seen, ShouldHaveLength, 2)
+	a.So(d.order, ShouldHaveLength, 2)
+
+	// The oldest frame was forgotten
+	_, suspicious := d.Check([]byte{1}, now.Add(10*time.Minute))
+	a.So(suspicious, ShouldBeFalse)
+	_, suspicious = d.Check([]byte{3}, now.Add(10*time.Minute))
+	a.So(suspicious, ShouldBeTrue)
+}
+
+func TestMaxGatewayDistance(t *testing.T) {
+	a := New(t)
+
+	amsterdam := &pb_gateway.LocationMetadata{Latitude: 52.3702, Longitude: 4.8952}
+	utrecht := &pb_gateway.LocationMetadata{Latitude: 52.0907, Longitude: 5.1214}
+	berlin := &pb_gateway.LocationMetadata{Latitude: 52.5200, Longitude: 13.4050}
+
+	duplicates := []*pb.UplinkMessage{
+		{GatewayMetadata: pb_gateway.RxMetadata{Location: amsterdam}},
+		{GatewayMetadata: pb_gateway.RxMetadata{Location: utrecht}},
+		{GatewayMetadata: pb_gateway.RxMetadata{}},
+	}
+	a.So(maxGatewayDistance(duplicates), ShouldAlmostEqual, 35, 2)
+	a.So(maxGatewayDistance(duplicates), ShouldBeLessThan, MaxGatewayDistance)
+
+	duplicates = append(duplicates, &pb.UplinkMessage{GatewayMetadata: pb_gateway.RxMetadata{Location: berlin}})
+	a.So(maxGatewayDistance(duplicates), ShouldAlmostEqual, 577, 5)
+}
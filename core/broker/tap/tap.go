@@ -0,0 +1,195 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package tap mirrors uplink messages that are received by the Broker to an analytics sink
+package tap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// BufferSize is the number of messages that are buffered before messages are dropped
+var BufferSize = 1024
+
+// Message is a mirrored uplink message. It contains the (encrypted) payload and the metadata of all gateways that received it.
+// If the Broker found the device of the message, its identifiers are set. If the Broker dropped the message, Error is set,
+// and RejectReason is set to a reason code if the message was rejected (such as "invalid_mic" or "unknown_devaddr").
+// Suspicion is set if the Broker suspects that the message was replayed or relayed.
+type Message struct {
+	ServerTime       int64                   `json:"server_time"`
+	Payload          []byte                  `json:"payload"`
+	ProtocolMetadata pb_protocol.RxMetadata  `json:"protocol_metadata"`
+	GatewayMetadata  []pb_gateway.RxMetadata `json:"gateway_metadata"`
+	AppEUI           *types.AppEUI           `json:"app_eui,omitempty"`
+	DevEUI           *types.DevEUI           `json:"dev_eui,omitempty"`
+	AppID            string                  `json:"app_id,omitempty"`
+	DevID            string                  `json:"dev_id,omitempty"`
+	Error            string                  `json:"error,omitempty"`
+	RejectReason     string                  `json:"reject_reason,omitempty"`
+	Suspicion        string                  `json:"suspicion,omitempty"`
+}
+
+// NewMessage builds a Message from the duplicates of an uplink message
+func NewMessage(serverTime int64, duplicates []*pb_broker.UplinkMessage) *Message {
+	msg := &Message{ServerTime: serverTime}
+	if len(duplicates) > 0 {
+		msg.Payload = duplicates[0].Payload
+		msg.ProtocolMetadata = duplicates[0].ProtocolMetadata
+	}
+	for _, duplicate := range duplicates {
+		msg.GatewayMetadata = append(msg.GatewayMetadata, duplicate.GatewayMetadata)
+	}
+	return msg
+}
+
+// Sink receives mirrored messages
+type Sink interface {
+	Send(msg *Message) error
+	Close() error
+}
+
+// Tap samples messages and sends them to a Sink without blocking the caller
+type Tap struct {
+	sink       Sink
+	sampleRate float64
+	queue      chan *Message
+	done       chan struct{}
+	closeOnce  sync.Once
+	dropped    uint64
+}
+
+// NewTap returns a new Tap that mirrors a fraction (between 0 and 1) of the messages to the sink
+func NewTap(sink Sink, sampleRate float64) *Tap {
+	return &Tap{
+		sink:       sink,
+		sampleRate: sampleRate,
+		queue:      make(chan *Message, BufferSize),
+		done:       make(chan struct{}),
+	}
+}
+
+// Sample returns true if the next message should be mirrored
+func (t *Tap) Sample() bool {
+	return t.sampleRate >= 1 || rand.Float64() < t.sampleRate
+}
+
+// Mirror queues the message for the sink. If the queue is full or the tap is closed, the message is dropped.
+func (t *Tap) Mirror(msg *Message) {
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.queue <- msg:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+// Dropped returns the number of messages that were dropped because the queue was full
+func (t *Tap) Dropped() uint64 {
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Run sends the queued messages to the sink until Close is called
+func (t *Tap) Run(ctx ttnlog.Interface) {
+	defer t.sink.Close()
+	for {
+		select {
+		case msg := <-t.queue:
+			if err := t.sink.Send(msg); err != nil {
+				ctx.WithError(err).Debug("Could not mirror message")
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Close the tap. The queue is not closed, so that Mirror can be called concurrently.
+func (t *Tap) Close() {
+	t.closeOnce.Do(func() { close(t.done) })
+}
+
+// NewSink returns a Sink for the target. Supported targets are file:///path/to/file (JSON lines) and udp://host:port (JSON datagrams).
+func NewSink(target string) (Sink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileSink(u.Path)
+	case "udp":
+		return NewUDPSink(u.Host)
+	}
+	return nil, fmt.Errorf("Unsupported tap target: %s", target)
+}
+
+// FileSink appends messages as JSON lines to a file
+type FileSink struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileSink returns a new FileSink
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Send implements the Sink interface
+func (s *FileSink) Send(msg *Message) error {
+	return s.enc.Encode(msg)
+}
+
+// Close implements the Sink interface
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// UDPSink sends every message as a JSON datagram
+type UDPSink struct {
+	conn net.Conn
+}
+
+// NewUDPSink returns a new UDPSink
+func NewUDPSink(addr string) (*UDPSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPSink{conn: conn}, nil
+}
+
+// Send implements the Sink interface
+func (s *UDPSink) Send(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn.Write(data)
+	return err
+}
+
+// Close implements the Sink interface
+func (s *UDPSink) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,116 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package tap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func testDuplicates() []*pb_broker.UplinkMessage {
+	return []*pb_broker.UplinkMessage{
+		{Payload: []byte{1, 2, 3}, GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "gtw-1"}},
+		{Payload: []byte{1, 2, 3}, GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "gtw-2"}},
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	a := New(t)
+	msg := NewMessage(42, testDuplicates())
+	a.So(msg.ServerTime, ShouldEqual, 42)
+	a.So(msg.Payload, ShouldResemble, []byte{1, 2, 3})
+	a.So(msg.GatewayMetadata, ShouldHaveLength, 2)
+	a.So(msg.GatewayMetadata[1].GatewayID, ShouldEqual, "gtw-2")
+}
+
+type chanSink chan *Message
+
+func (s chanSink) Send(msg *Message) error { s <- msg; return nil }
+func (s chanSink) Close() error            { close(s); return nil }
+
+func TestTap(t *testing.T) {
+	a := New(t)
+
+	a.So(NewTap(nil, 1).Sample(), ShouldBeTrue)
+	a.So(NewTap(nil, 0).Sample(), ShouldBeFalse)
+
+	sink := make(chanSink, 10)
+	tap := NewTap(sink, 1)
+	go tap.Run(GetLogger(t, "TestTap"))
+	tap.Mirror(NewMessage(1, testDuplicates()))
+	msg := <-sink
+	a.So(msg.ServerTime, ShouldEqual, 1)
+	tap.Close()
+	_, open := <-sink
+	a.So(open, ShouldBeFalse)
+
+	// Messages are dropped after Close
+	tap.Mirror(&Message{})
+	tap.Close()
+
+	// Full queue drops messages
+	full := NewTap(sink, 1)
+	for i := 0; i < BufferSize+1; i++ {
+		full.Mirror(&Message{})
+	}
+	a.So(full.Dropped(), ShouldEqual, 1)
+}
+
+func TestFileSink(t *testing.T) {
+	a := New(t)
+
+	dir, _ := ioutil.TempDir("", "ttn-tap")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tap.json")
+
+	sink, err := NewSink("file://" + path)
+	a.So(err, ShouldBeNil)
+	a.So(sink.Send(NewMessage(1, testDuplicates())), ShouldBeNil)
+	a.So(sink.Send(NewMessage(2, testDuplicates())), ShouldBeNil)
+	a.So(sink.Close(), ShouldBeNil)
+
+	file, _ := os.Open(path)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var lines int
+	for scanner.Scan() {
+		var msg Message
+		a.So(json.Unmarshal(scanner.Bytes(), &msg), ShouldBeNil)
+		lines++
+	}
+	a.So(lines, ShouldEqual, 2)
+}
+
+func TestUDPSink(t *testing.T) {
+	a := New(t)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	a.So(err, ShouldBeNil)
+	defer conn.Close()
+
+	sink, err := NewSink("udp://" + conn.LocalAddr().String())
+	a.So(err, ShouldBeNil)
+	defer sink.Close()
+	a.So(sink.Send(NewMessage(1, testDuplicates())), ShouldBeNil)
+
+	buf := make([]byte, 65535)
+	n, _, err := conn.ReadFrom(buf)
+	a.So(err, ShouldBeNil)
+	var msg Message
+	a.So(json.Unmarshal(buf[:n], &msg), ShouldBeNil)
+	a.So(msg.GatewayMetadata, ShouldHaveLength, 2)
+
+	_, err = NewSink("kafka://localhost:9092")
+	a.So(err, ShouldNotBeNil)
+}
@@ -17,6 +17,9 @@ import (
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/api/trace"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/api"
+	"github.com/TheThingsNetwork/ttn/core/broker/tap"
+	"github.com/TheThingsNetwork/ttn/core/secureelement"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/TheThingsNetwork/ttn/utils/fcnt"
@@ -31,11 +34,18 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 	deduplicatedUplink := new(pb.DeduplicatedUplinkMessage)
 	deduplicatedUplink.ServerTime = start.UnixNano()
 
+	var duplicates []*pb.UplinkMessage
+	var rejectReason, suspicion string
+
 	b.RegisterReceived(uplink)
 	defer func() {
 		if err != nil {
+			if rejectReason != "" {
+				rejectedUplinksCounter.WithLabelValues(rejectReason).Inc()
+				ctx = ctx.WithField("RejectReason", rejectReason)
+			}
 			if deduplicatedUplink != nil {
-				deduplicatedUplink.Trace = deduplicatedUplink.Trace.WithEvent(trace.DropEvent, "reason", err)
+				deduplicatedUplink.Trace = deduplicatedUplink.Trace.WithEvent(trace.DropEvent, "reason", err, "code", rejectReason)
 			}
 			ctx.WithError(err).Warn("Could not handle uplink")
 		} else {
@@ -45,6 +55,9 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 		if deduplicatedUplink != nil && b.monitorStream != nil {
 			b.monitorStream.Send(deduplicatedUplink)
 		}
+		if deduplicatedUplink != nil && len(duplicates) > 0 && b.tap != nil && b.tap.Sample() {
+			b.mirrorUplink(deduplicatedUplink, duplicates, rejectReason, suspicion, err)
+		}
 	}()
 
 	b.status.uplink.Mark(1)
@@ -52,7 +65,7 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 	uplink.Trace = uplink.Trace.WithEvent(trace.ReceiveEvent)
 
 	// De-duplicate uplink messages
-	duplicates := b.deduplicateUplink(uplink)
+	duplicates = b.deduplicateUplink(uplink)
 	if len(duplicates) == 0 {
 		return nil
 	}
@@ -72,17 +85,25 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 	}
 
 	if deduplicatedUplink.ProtocolMetadata.GetLoRaWAN() == nil {
+		rejectReason = RejectNoLoRaWANMetadata
 		return errors.NewErrInvalidArgument("Uplink", "does not contain LoRaWAN metadata")
 	}
 
+	// LoRaWAN: Validate
+	if rejectReason, err = validateUplink(deduplicatedUplink.Payload); err != nil {
+		return err
+	}
+
 	// LoRaWAN: Unmarshal
 	var phyPayload lorawan.PHYPayload
 	err = phyPayload.UnmarshalBinary(deduplicatedUplink.Payload)
 	if err != nil {
+		rejectReason = RejectInvalidFrame
 		return err
 	}
 	macPayload, ok := phyPayload.MACPayload.(*lorawan.MACPayload)
 	if !ok {
+		rejectReason = RejectInvalidFrame
 		return errors.NewErrInvalidArgument("Uplink", "does not contain a MAC payload")
 	}
 
@@ -92,17 +113,44 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 		"DevAddr": devAddr,
 		"FCnt":    macPayload.FHDR.FCnt,
 	})
+
+	if b.blacklist != nil && b.blacklist.Blocked(devAddr) {
+		rejectReason = RejectBlacklisted
+		return errors.NewErrPermissionDenied(fmt.Sprintf("DevAddr %s is blacklisted", devAddr))
+	}
+
+	// Detect replayed and relayed frames before the FCnt check hides them
+	suspicion = b.checkReplay(ctx, deduplicatedUplink, duplicates)
+
+	// ABP devices that were reset start counting at zero again
+	resetInd := types.HasResetInd(deduplicatedUplink.Payload)
+
 	var getDevicesResp *networkserver.DevicesResponse
-	getDevicesResp, err = b.ns.GetDevices(b.Component.GetContext(b.nsToken), &networkserver.DevicesRequest{
+	reqCtx, cancel := b.Component.GetRequestContext(b.nsToken)
+	if resetInd {
+		reqCtx = api.OutgoingContextWithResetInd(reqCtx, phyPayload.MIC[:])
+	}
+	getDevicesResp, err = b.ns.GetDevices(reqCtx, &networkserver.DevicesRequest{
 		DevAddr: devAddr,
 		FCnt:    macPayload.FHDR.FCnt,
 	})
+	cancel()
 	if err != nil {
 		return errors.Wrap(errors.FromGRPCError(err), "NetworkServer did not return devices")
 	}
 	b.status.deduplication.Update(int64(len(getDevicesResp.Results)))
 	duplicatesHistogram.Observe(float64(len(getDevicesResp.Results)))
 	if len(getDevicesResp.Results) == 0 {
+		rejectReason = RejectUnknownDevAddr
+		// Anyone can send uplinks with the DevAddr of a device, so only DevAddrs without devices are quarantined and
+		// blacklisted
+		if (b.quarantine != nil || b.blacklist != nil) && !b.hasDevices(devAddr) {
+			b.quarantineUplink(ctx, rejectReason, devAddr, duplicates)
+			b.blacklistFailure(ctx, devAddr, rejectReason)
+		}
+		if b.peering != nil && b.isForeignDevAddr(devAddr) {
+			b.peering.Export(deduplicatedUplink.ServerTime, duplicates)
+		}
 		return errors.NewErrNotFound(fmt.Sprintf("Device with DevAddr %s and FCnt <= %d", devAddr, macPayload.FHDR.FCnt))
 	}
 	ctx = ctx.WithField("DevAddrResults", len(getDevicesResp.Results))
@@ -118,11 +166,20 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 	var micChecks int
 	originalFCnt := macPayload.FHDR.FCnt
 	for _, candidate := range getDevicesResp.Results {
-		nwkSKey := lorawan.AES128Key(*candidate.NwkSKey)
+		validateMIC := func() (bool, error) {
+			if candidate.NwkSKey == nil || candidate.NwkSKey.IsEmpty() {
+				if b.secureElement == nil {
+					return false, nil
+				}
+				// The NwkSKey of the device is kept in a secure element
+				return secureelement.ValidateMIC(b.secureElement, candidate.AppEUI, candidate.DevEUI, phyPayload)
+			}
+			return phyPayload.ValidateMIC(lorawan.AES128Key(*candidate.NwkSKey))
+		}
 
 		// First check with the 16 bit counter
 		micChecks++
-		ok, err = phyPayload.ValidateMIC(nwkSKey)
+		ok, err = validateMIC()
 		if err != nil {
 			return err
 		}
@@ -137,7 +194,7 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 			// Then check again with the 32 bit counter
 			if macPayload.FHDR.FCnt != originalFCnt {
 				micChecks++
-				ok, err = phyPayload.ValidateMIC(nwkSKey)
+				ok, err = validateMIC()
 				if err != nil {
 					return err
 				}
@@ -151,11 +208,17 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 		}
 	}
 	if device == nil {
+		rejectReason = RejectInvalidMIC
 		return errors.NewErrNotFound("device that validates MIC")
 	}
 
 	micChecksHistogram.Observe(float64(micChecks))
 
+	// The device has a valid session, so its DevAddr is never blacklisted
+	if b.blacklist != nil {
+		b.blacklist.Succeed(devAddr)
+	}
+
 	ctx = ctx.WithFields(ttnlog.Fields{
 		"MICChecks": micChecks,
 		"DevEUI":    device.DevEUI,
@@ -180,6 +243,9 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 		// FCnt Check disabled. Rely on MIC check only
 	case device.FCntUp == 0:
 		// FCntUp is reset. We don't know where the device will start sending.
+	case resetInd && macPayload.FHDR.FCnt <= types.MaxResetIndFCnt && macPayload.FHDR.FCnt < device.FCntUp:
+		// The device was reset and indicates it with a ResetInd. The Network Server only returns ABP devices for
+		// uplinks that did not reset their session before, and resets the session.
 	case macPayload.FHDR.FCnt == device.FCntUp:
 		if phyPayload.MHDR.MType == lorawan.ConfirmedDataUp {
 			// Retry of confirmed uplink
@@ -187,46 +253,70 @@ func (b *broker) HandleUplink(uplink *pb.UplinkMessage) (err error) {
 		}
 		fallthrough
 	case macPayload.FHDR.FCnt <= device.FCntUp:
+		rejectReason = RejectFCntTooLow
 		return errors.NewErrInvalidArgument("FCnt", "not high enough")
 	case macPayload.FHDR.FCnt-device.FCntUp > maxFCntGap:
+		rejectReason = RejectFCntTooHigh
 		return errors.NewErrInvalidArgument("FCnt", "too high")
 	default:
 		return errors.NewErrInternal("FCnt check failed")
 	}
 
+	// Private gateways only forward the uplinks of the applications of their owner
+	if duplicates = b.filterPrivateUplinks(device.AppID, duplicates); len(duplicates) == 0 {
+		rejectReason = RejectPrivateGateway
+		return errors.NewErrPermissionDenied(fmt.Sprintf("Uplink only received by private gateways of other owners than %s", device.AppID))
+	}
+
 	// Add FCnt to Metadata (because it's not marshaled in lorawan payload)
 	deduplicatedUplink.ProtocolMetadata.GetLoRaWAN().FCnt = macPayload.FHDR.FCnt
 
 	// Collect GatewayMetadata and DownlinkOptions
-	var downlinkOptions []*pb.DownlinkOption
+	var downlinkCandidates []downlinkCandidate
 	for _, duplicate := range duplicates {
 		deduplicatedUplink.GatewayMetadata = append(deduplicatedUplink.GatewayMetadata, &duplicate.GatewayMetadata)
-		downlinkOptions = append(downlinkOptions, duplicate.DownlinkOptions...)
+		downlinkCandidates = appendDownlinkCandidates(downlinkCandidates, start, duplicate.GatewayMetadata.Timestamp, duplicate.DownlinkOptions)
 	}
 
-	// Select best DownlinkOption
-	if len(downlinkOptions) > 0 {
-		deduplicatedUplink.ResponseTemplate = &pb.DownlinkMessage{
-			DevEUI:         device.DevEUI,
-			AppEUI:         device.AppEUI,
-			AppID:          device.AppID,
-			DevID:          device.DevID,
-			DownlinkOption: selectBestDownlink(downlinkOptions),
+	// Select best DownlinkOption that can still make its deadline
+	if len(downlinkCandidates) > 0 {
+		if option := selectDownlink("deduplicate", downlinkCandidates); option != nil {
+			deduplicatedUplink.ResponseTemplate = &pb.DownlinkMessage{
+				DevEUI:         device.DevEUI,
+				AppEUI:         device.AppEUI,
+				AppID:          device.AppID,
+				DevID:          device.DevID,
+				DownlinkOption: option,
+			}
+		} else {
+			ctx.Warn("Deadline exceeded for all downlink options")
 		}
 	}
 
 	// Pass Uplink through NS
-	deduplicatedUplink, err = b.ns.Uplink(b.Component.GetContext(b.nsToken), deduplicatedUplink)
+	reqCtx, cancel = b.Component.GetRequestContext(b.nsToken)
+	deduplicatedUplink, err = b.ns.Uplink(reqCtx, deduplicatedUplink)
+	cancel()
 	if err != nil {
 		return errors.Wrap(errors.FromGRPCError(err), "NetworkServer did not handle uplink")
 	}
 
+	// Fall back to another DownlinkOption if the NS took too long
+	if template := deduplicatedUplink.ResponseTemplate; template != nil && template.DownlinkOption != nil {
+		template.DownlinkOption = checkDownlink("networkserver", template.DownlinkOption, downlinkCandidates)
+		if template.DownlinkOption == nil {
+			ctx.Warn("Deadline exceeded for all downlink options")
+			deduplicatedUplink.ResponseTemplate = nil
+		}
+	}
+
 	var announcements []*pb_discovery.Announcement
 	announcements, err = b.Discovery.GetAllHandlersForAppID(device.AppID)
 	if err != nil {
 		return err
 	}
 	if len(announcements) == 0 {
+		rejectReason = RejectNoHandler
 		return errors.NewErrNotFound(fmt.Sprintf("Handler for AppID %s", device.AppID))
 	}
 	if len(announcements) > 1 {
@@ -261,11 +351,6 @@ func (b *broker) deduplicateUplink(duplicate *pb.UplinkMessage) (uplinks []*pb.U
 	return
 }
 
-func selectBestDownlink(options []*pb.DownlinkOption) *pb.DownlinkOption {
-	sort.Sort(ByScore(options))
-	return options[0]
-}
-
 // ByFCntUp implements sort.Interface for []*pb_lorawan.Device based on FCnt
 type ByFCntUp []*pb_lorawan.Device
 
@@ -278,3 +363,18 @@ func (a ByFCntUp) Less(i, j int) bool {
 	}
 	return int(a[i].FCntUp) < int(a[j].FCntUp)
 }
+
+// mirrorUplink sends the uplink to the tap, with the device that the Broker found for it
+func (b *broker) mirrorUplink(deduplicatedUplink *pb.DeduplicatedUplinkMessage, duplicates []*pb.UplinkMessage, rejectReason, suspicion string, err error) {
+	msg := tap.NewMessage(deduplicatedUplink.ServerTime, duplicates)
+	msg.AppEUI = deduplicatedUplink.AppEUI
+	msg.DevEUI = deduplicatedUplink.DevEUI
+	msg.AppID = deduplicatedUplink.AppID
+	msg.DevID = deduplicatedUplink.DevID
+	msg.Suspicion = suspicion
+	if err != nil {
+		msg.Error = err.Error()
+		msg.RejectReason = rejectReason
+	}
+	b.tap.Mirror(msg)
+}
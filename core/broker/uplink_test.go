@@ -10,10 +10,16 @@ import (
 
 	pb "github.com/TheThingsNetwork/api/broker"
 	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/api/discovery/discoveryclient"
 	"github.com/TheThingsNetwork/api/gateway"
+	"github.com/TheThingsNetwork/api/monitor/monitorclient"
 	pb_networkserver "github.com/TheThingsNetwork/api/networkserver"
 	"github.com/TheThingsNetwork/api/protocol"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/broker/quarantine"
+	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/brocaar/lorawan"
@@ -150,6 +156,59 @@ func TestHandleUplink(t *testing.T) {
 	a.So(err, ShouldBeNil)
 }
 
+func TestHandleUplinkUnknownDevAddr(t *testing.T) {
+	a := New(t)
+
+	b := getTestBroker(t)
+	b.blacklist = blacklist.NewBlacklist(1, time.Minute, time.Minute)
+	b.quarantine = quarantine.NewQuarantine(10)
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{
+			MType: lorawan.UnconfirmedDataUp,
+			Major: lorawan.LoRaWANR1,
+		},
+		MACPayload: &lorawan.MACPayload{
+			FHDR: lorawan.FHDR{
+				DevAddr: lorawan.DevAddr([4]byte{1, 2, 3, 4}),
+				FCnt:    1,
+			},
+		},
+	}
+	bytes, _ := phy.MarshalBinary()
+	uplink := func() *pb.UplinkMessage {
+		return &pb.UplinkMessage{
+			Payload:          bytes,
+			GatewayMetadata:  gateway.RxMetadata{SNR: 1.2, GatewayID: "eui-0102030405060708"},
+			ProtocolMetadata: protocol.RxMetadata{Protocol: &protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{}}},
+		}
+	}
+	noDevices := &pb_networkserver.DevicesResponse{}
+	device := &pb_networkserver.DevicesResponse{Results: []*pb_lorawan.Device{{FCntUp: 10}}}
+
+	// A device with the DevAddr has a higher FCnt, so the DevAddr is not quarantined or blacklisted
+	b.ns.EXPECT().GetDevices(gomock.Any(), gomock.Any()).Return(noDevices, nil)
+	b.ns.EXPECT().GetDevices(gomock.Any(), gomock.Any()).Return(device, nil)
+	err := b.HandleUplink(uplink())
+	a.So(err, ShouldHaveSameTypeAs, &errors.ErrNotFound{})
+	a.So(b.quarantine.Entries(), ShouldBeEmpty)
+	a.So(b.blacklist.Blocked(types.DevAddr{1, 2, 3, 4}), ShouldBeFalse)
+
+	// No device has the DevAddr
+	b.uplinkDeduplicator = NewDeduplicator(10 * time.Millisecond)
+	b.ns.EXPECT().GetDevices(gomock.Any(), gomock.Any()).Return(noDevices, nil)
+	b.ns.EXPECT().GetDevices(gomock.Any(), gomock.Any()).Return(noDevices, nil)
+	err = b.HandleUplink(uplink())
+	a.So(err, ShouldHaveSameTypeAs, &errors.ErrNotFound{})
+	a.So(b.quarantine.Entries(), ShouldHaveLength, 1)
+	a.So(b.blacklist.Blocked(types.DevAddr{1, 2, 3, 4}), ShouldBeTrue)
+
+	// Uplinks of blacklisted DevAddrs are rejected before asking the NetworkServer
+	b.uplinkDeduplicator = NewDeduplicator(10 * time.Millisecond)
+	err = b.HandleUplink(uplink())
+	a.So(err, ShouldHaveSameTypeAs, &errors.ErrPermissionDenied{})
+}
+
 func TestDeduplicateUplink(t *testing.T) {
 	a := New(t)
 
@@ -183,3 +242,93 @@ func TestDeduplicateUplink(t *testing.T) {
 
 	wg.Wait()
 }
+
+func BenchmarkValidateMIC(b *testing.B) {
+	key := lorawan.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.UnconfirmedDataUp, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.MACPayload{
+			FHDR: lorawan.FHDR{DevAddr: lorawan.DevAddr{1, 2, 3, 4}, FCnt: 1},
+		},
+	}
+	phy.SetMIC(key)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if ok, _ := phy.ValidateMIC(key); !ok {
+			b.Fatal("MIC not valid")
+		}
+	}
+}
+
+// BenchmarkHandleUplink measures the latency of an uplink through the broker, with the
+// NetworkServer and Discovery mocked in-memory and the Handler stream drained by a goroutine
+func BenchmarkHandleUplink(b *testing.B) {
+	ctrl := gomock.NewController(b)
+	defer ctrl.Finish()
+	discovery := discoveryclient.NewMockClient(ctrl)
+	ns := pb_networkserver.NewMockNetworkServerClient(ctrl)
+	br := &broker{
+		Component: &component.Component{
+			Discovery: discovery,
+			Ctx:       ttnlog.Get(),
+			Monitor:   monitorclient.NewMonitorClient(),
+		},
+		handlers:               make(map[string]*handler),
+		activationDeduplicator: NewDeduplicator(0),
+		uplinkDeduplicator:     NewDeduplicator(0),
+		ns:                     ns,
+	}
+	br.InitStatus()
+
+	uplinks := make(chan *pb.DeduplicatedUplinkMessage, 10)
+	br.handlers["handlerID"] = &handler{uplink: uplinks}
+	go func() {
+		for range uplinks {
+		}
+	}()
+	defer close(uplinks)
+
+	nwkSKey := types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	ns.EXPECT().GetDevices(gomock.Any(), gomock.Any()).Return(&pb_networkserver.DevicesResponse{
+		Results: []*pb_lorawan.Device{
+			&pb_lorawan.Device{
+				DevEUI:           types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8},
+				AppEUI:           types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8},
+				AppID:            "appid-1",
+				NwkSKey:          &nwkSKey,
+				DisableFCntCheck: true,
+			},
+		},
+	}, nil).AnyTimes()
+	ns.EXPECT().Uplink(gomock.Any(), gomock.Any()).Return(&pb.DeduplicatedUplinkMessage{AppID: "appid-1"}, nil).AnyTimes()
+	discovery.EXPECT().GetAllHandlersForAppID("appid-1").Return([]*pb_discovery.Announcement{
+		&pb_discovery.Announcement{ID: "handlerID"},
+	}, nil).AnyTimes()
+
+	// Every uplink gets its own FCnt, so that it is not deduplicated with the previous one
+	payloads := make([][]byte, b.N)
+	for n := range payloads {
+		phy := lorawan.PHYPayload{
+			MHDR: lorawan.MHDR{MType: lorawan.UnconfirmedDataUp, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.MACPayload{
+				FHDR: lorawan.FHDR{DevAddr: lorawan.DevAddr{1, 2, 3, 4}, FCnt: uint32(n + 1)},
+			},
+		}
+		phy.SetMIC(lorawan.AES128Key(nwkSKey))
+		payloads[n], _ = phy.MarshalBinary()
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		err := br.HandleUplink(&pb.UplinkMessage{
+			Payload:          payloads[n],
+			GatewayMetadata:  gateway.RxMetadata{SNR: 1.2, GatewayID: "eui-0102030405060708"},
+			ProtocolMetadata: protocol.RxMetadata{Protocol: &protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{}}},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
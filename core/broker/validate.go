@@ -0,0 +1,72 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+)
+
+// Reasons for rejecting uplink messages that are not valid LoRaWAN frames
+const (
+	RejectTooShort       = "too_short"
+	RejectInvalidMHDR    = "invalid_mhdr"
+	RejectWrongDirection = "wrong_direction"
+	RejectFOptsTooLong   = "fopts_too_long"
+	RejectFOptsAndPort0  = "fopts_and_port_0"
+	RejectInvalidFrame   = "invalid_frame"
+)
+
+// Reasons for rejecting valid uplink messages
+const (
+	RejectNoLoRaWANMetadata = "no_lorawan_metadata"
+	RejectUnknownDevAddr    = "unknown_devaddr"
+	RejectInvalidMIC        = "invalid_mic"
+	RejectFCntTooLow        = "fcnt_too_low"
+	RejectFCntTooHigh       = "fcnt_too_high"
+	RejectNoHandler         = "no_handler"
+	RejectUnknownDevEUI     = "unknown_deveui"
+	RejectBlacklisted       = "blacklisted"
+	RejectPrivateGateway    = "private_gateway"
+)
+
+// minDataFrameLen is the length of the MHDR, FHDR without FOpts and MIC
+const minDataFrameLen = 1 + 7 + 4
+
+// validateUplink checks the structure of a LoRaWAN data uplink before it is unmarshaled.
+// If the uplink is not valid, it returns the reason for rejecting it.
+func validateUplink(payload []byte) (reason string, err error) {
+	if len(payload) < minDataFrameLen {
+		return RejectTooShort, errors.NewErrInvalidArgument("Uplink", fmt.Sprintf("%d bytes is too short for a data frame", len(payload)))
+	}
+
+	mhdr := payload[0]
+	if major := lorawan.Major(mhdr & 0x03); major != lorawan.LoRaWANR1 {
+		return RejectInvalidMHDR, errors.NewErrInvalidArgument("Uplink MHDR", fmt.Sprintf("unsupported major version %d", major))
+	}
+	if mhdr&0x1C != 0 {
+		return RejectInvalidMHDR, errors.NewErrInvalidArgument("Uplink MHDR", "RFU bits are set")
+	}
+	switch mType := lorawan.MType(mhdr >> 5); mType {
+	case lorawan.UnconfirmedDataUp, lorawan.ConfirmedDataUp:
+	case lorawan.UnconfirmedDataDown, lorawan.ConfirmedDataDown, lorawan.JoinAccept:
+		return RejectWrongDirection, errors.NewErrInvalidArgument("Uplink MType", fmt.Sprintf("%s is a downlink message type", mType))
+	default:
+		return RejectInvalidMHDR, errors.NewErrInvalidArgument("Uplink MType", fmt.Sprintf("%s is not a data message type", mType))
+	}
+
+	fOptsLen := int(payload[5] & 0x0F)
+	if minDataFrameLen+fOptsLen > len(payload) {
+		return RejectFOptsTooLong, errors.NewErrInvalidArgument("Uplink FOpts", fmt.Sprintf("%d bytes do not fit in the frame", fOptsLen))
+	}
+
+	// FPort 0 means that the FRMPayload contains MAC commands, which is not allowed if FOpts are also present
+	if portIdx := 8 + fOptsLen; fOptsLen > 0 && portIdx < len(payload)-4 && payload[portIdx] == 0 {
+		return RejectFOptsAndPort0, errors.NewErrInvalidArgument("Uplink FOpts", "can not be combined with MAC commands on FPort 0")
+	}
+
+	return "", nil
+}
@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package broker
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestValidateUplink(t *testing.T) {
+	a := New(t)
+
+	mic := []byte{0x01, 0x02, 0x03, 0x04}
+	frame := func(mhdr, fCtrl byte, rest ...byte) []byte {
+		payload := append([]byte{mhdr, 0x04, 0x03, 0x02, 0x01, fCtrl, 0x01, 0x00}, rest...)
+		return append(payload, mic...)
+	}
+
+	for _, tt := range []struct {
+		Payload []byte
+		Reason  string
+	}{
+		{frame(0x40, 0x00), ""},                                    // Unconfirmed up without FPort
+		{frame(0x80, 0x00, 0x01, 0xAA), ""},                        // Confirmed up on FPort 1
+		{frame(0x40, 0x00, 0x00, 0x02), ""},                        // MAC commands on FPort 0
+		{frame(0x40, 0x01, 0x02, 0x01, 0xAA), ""},                  // FOpts and FPort 1
+		{frame(0x40, 0x01, 0x02), ""},                              // FOpts without FPort
+		{[]byte{0x40, 0x04, 0x03}, RejectTooShort},                 // Too short
+		{frame(0x41, 0x00), RejectInvalidMHDR},                     // Major 1
+		{frame(0x44, 0x00), RejectInvalidMHDR},                     // RFU bits
+		{frame(0x00, 0x00), RejectInvalidMHDR},                     // Join request
+		{frame(0x60, 0x00), RejectWrongDirection},                  // Unconfirmed down
+		{frame(0xA0, 0x00), RejectWrongDirection},                  // Confirmed down
+		{frame(0x40, 0x0F, 0x02), RejectFOptsTooLong},              // FOpts longer than frame
+		{frame(0x40, 0x01, 0x02, 0x00, 0x02), RejectFOptsAndPort0}, // FOpts and FPort 0
+	} {
+		reason, err := validateUplink(tt.Payload)
+		a.So(reason, ShouldEqual, tt.Reason)
+		if tt.Reason == "" {
+			a.So(err, ShouldBeNil)
+		} else {
+			a.So(err, ShouldNotBeNil)
+		}
+	}
+}
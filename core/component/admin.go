@@ -0,0 +1,37 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package component
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// IsAdmin returns true if the request has the admin token of the component as bearer token in the Authorization
+// header. Without an admin token, no request is an admin request.
+func IsAdmin(req *http.Request) bool {
+	token := viper.GetString("admin-token")
+	if token == "" {
+		return false
+	}
+	bearer := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1
+}
+
+// RequireAdmin protects the requests with the given methods to a handler on the health port with the admin token
+// of the component. Requests with other methods are passed to the handler as they are.
+func RequireAdmin(handler http.Handler, methods ...string) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		for _, method := range methods {
+			if req.Method == method && !IsAdmin(req) {
+				http.Error(res, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		handler.ServeHTTP(res, req)
+	})
+}
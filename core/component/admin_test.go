@@ -0,0 +1,41 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package component
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+	"github.com/spf13/viper"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	a := New(t)
+
+	handler := RequireAdmin(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNoContent)
+	}), "DELETE")
+
+	serve := func(method, token string) int {
+		req := httptest.NewRequest(method, "/admin", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	// Without an admin token, admin actions are not possible
+	a.So(serve("GET", ""), ShouldEqual, http.StatusNoContent)
+	a.So(serve("DELETE", ""), ShouldEqual, http.StatusForbidden)
+
+	viper.Set("admin-token", "secret")
+	defer viper.Set("admin-token", "")
+	a.So(serve("DELETE", ""), ShouldEqual, http.StatusForbidden)
+	a.So(serve("DELETE", "wrong"), ShouldEqual, http.StatusForbidden)
+	a.So(serve("DELETE", "secret"), ShouldEqual, http.StatusNoContent)
+}
@@ -170,12 +170,13 @@ func (c *Component) initRoots() error {
 }
 
 func (c *Component) initBgCtx() error {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
 	if c.Identity != nil {
 		ctx = ttnctx.OutgoingContextWithID(ctx, c.Identity.ID)
 		ctx = ttnctx.OutgoingContextWithServiceInfo(ctx, c.Identity.ServiceName, c.Identity.ServiceVersion, c.Identity.NetAddress)
 	}
-	c.Context = ctx
+	ctx = outgoingContextWithHello(ctx)
+	c.Context, c.cancel = ctx, cancel
 	if c.Pool != nil {
 		c.Pool.SetContext(c.Context)
 	}
@@ -210,6 +211,23 @@ func (c *Component) GetContext(token string) context.Context {
 	return ctx
 }
 
+// GetRequestContext returns a context for an outgoing unary RPC request. The context is cancelled after
+// the request timeout of the component, or when the component shuts down.
+func (c *Component) GetRequestContext(token string) (context.Context, context.CancelFunc) {
+	ctx := c.GetContext(token)
+	if c.Config.RequestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.Config.RequestTimeout)
+}
+
+// Cancel cancels the context of the component, aborting all outgoing requests
+func (c *Component) Cancel() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
 var oauthCache = cache.MemoryCache()
 
 // ExchangeAppKeyForToken enables authentication with the App Access Key
@@ -274,6 +292,10 @@ func (c *Component) ValidateNetworkContext(ctx context.Context) (component *pb_d
 		return nil, errors.NewErrInvalidArgument("Metadata", "service-name missing")
 	}
 
+	if err := c.validateHello(ctx, serviceName, id); err != nil {
+		return nil, err
+	}
+
 	announcement, err := c.Discover(serviceName, id)
 	if err != nil {
 		return nil, err
@@ -321,4 +321,32 @@ func TestGetAndVerifyContext(t *testing.T) {
 	_, err := c.ValidateNetworkContext(ctx)
 	a.So(err, assertions.ShouldBeNil)
 
+	// Components with an incompatible protocol version are rejected
+	ctx = c.GetContext("")
+	md := metadata.Join(ttnctx.MetadataFromOutgoingContext(ctx))
+	md["ttn-protocol-version"] = []string{"3.0"}
+	_, err = c.ValidateNetworkContext(metadata.NewIncomingContext(ctx, md))
+	a.So(err, assertions.ShouldNotBeNil)
+}
+
+func TestGetRequestContext(t *testing.T) {
+	a := assertions.New(t)
+
+	c := &Component{Config: Config{RequestTimeout: 10 * time.Millisecond}}
+	ctx, cancel := c.GetRequestContext("token")
+	defer cancel()
+	_, ok := ctx.Deadline()
+	a.So(ok, assertions.ShouldBeTrue)
+	<-ctx.Done()
+	a.So(ctx.Err(), assertions.ShouldEqual, context.DeadlineExceeded)
+
+	// Without timeout, the request is cancelled when the component shuts down
+	c.Config.RequestTimeout = 0
+	ctx, cancel = c.GetRequestContext("token")
+	defer cancel()
+	_, ok = ctx.Deadline()
+	a.So(ok, assertions.ShouldBeFalse)
+	c.Cancel()
+	<-ctx.Done()
+	a.So(ctx.Err(), assertions.ShouldEqual, context.Canceled)
 }
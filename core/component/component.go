@@ -31,6 +31,7 @@ type Component struct {
 	Monitor          *monitorclient.MonitorClient
 	Ctx              ttnlog.Interface
 	Context          context.Context
+	cancel           context.CancelFunc
 	AccessToken      string
 	privateKey       *ecdsa.PrivateKey
 	tlsConfig        *tls.Config
@@ -68,6 +69,10 @@ func New(ctx ttnlog.Interface, serviceName string, announcedAddress string) (*Co
 		Pool:        pool.NewPool(context.Background(), pool.DefaultDialOptions...),
 	}
 
+	if err := component.initCompression(); err != nil {
+		return nil, err
+	}
+
 	if err := component.initialize(); err != nil {
 		return nil, err
 	}
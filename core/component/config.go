@@ -15,6 +15,8 @@ type Config struct {
 	KeyDir         string
 	StatusInterval time.Duration
 	UseTLS         bool
+	Compression    string
+	RequestTimeout time.Duration
 }
 
 // ConfigFromViper imports configuration from Viper
@@ -24,5 +26,7 @@ func ConfigFromViper() Config {
 		KeyDir:         viper.GetString("key-dir"),
 		StatusInterval: viper.GetDuration("monitor-interval"),
 		UseTLS:         viper.GetBool("tls"),
+		Compression:    viper.GetString("grpc-compression"),
+		RequestTimeout: viper.GetDuration("request-timeout"),
 	}
 }
@@ -4,11 +4,15 @@
 package component
 
 import (
+	"fmt"
 	"math"
+	"time"
 
 	"github.com/TheThingsNetwork/api/trace"
 	"github.com/TheThingsNetwork/go-utils/grpc/rpcerror"
 	"github.com/TheThingsNetwork/go-utils/grpc/rpclog"
+	"github.com/TheThingsNetwork/ttn/api/idempotency"
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/mwitkow/go-grpc-middleware" // See https://github.com/grpc/grpc-go/issues/711"
@@ -16,6 +20,9 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
+// IdempotencyWindow is the time in which the responses to requests with an idempotency key are remembered
+const IdempotencyWindow = time.Minute
+
 func (c *Component) ServerOptions() []grpc.ServerOption {
 	opts := []grpc.ServerOption{
 		grpc.MaxConcurrentStreams(math.MaxUint16),
@@ -23,12 +30,19 @@ func (c *Component) ServerOptions() []grpc.ServerOption {
 			grpc_prometheus.UnaryServerInterceptor,
 			rpcerror.UnaryServerInterceptor(errors.BuildGRPCError),
 			rpclog.UnaryServerInterceptor(c.Ctx),
+			idempotency.UnaryServerInterceptor(idempotency.NewCache(IdempotencyWindow)),
+			chaos.UnaryServerInterceptor,
 		)),
 		grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
 			grpc_prometheus.StreamServerInterceptor,
 			rpcerror.StreamServerInterceptor(errors.BuildGRPCError),
 			rpclog.StreamServerInterceptor(c.Ctx),
+			chaos.StreamServerInterceptor,
 		)),
+		grpc.RPCDecompressor(grpc.NewGZIPDecompressor()),
+	}
+	if c.Config.Compression == CompressionGZIP {
+		opts = append(opts, grpc.RPCCompressor(grpc.NewGZIPCompressor()))
 	}
 	if c.tlsConfig != nil {
 		opts = append(opts, grpc.Creds(credentials.NewTLS(c.tlsConfig)))
@@ -36,6 +50,22 @@ func (c *Component) ServerOptions() []grpc.ServerOption {
 	return opts
 }
 
+// CompressionGZIP compresses the gRPC messages that a component sends with gzip. Messages that are received
+// are always decompressed, so that this can be enabled one component at a time.
+const CompressionGZIP = "gzip"
+
+// initCompression validates the compression and enables it for the connections to other components
+func (c *Component) initCompression() error {
+	switch c.Config.Compression {
+	case "":
+	case CompressionGZIP:
+		c.Pool.AddDialOption(grpc.WithCompressor(grpc.NewGZIPCompressor()))
+	default:
+		return errors.NewErrInvalidArgument("Compression", fmt.Sprintf("unknown compression %s", c.Config.Compression))
+	}
+	return nil
+}
+
 func init() {
 	// Disable gRPC tracing
 	// SEE: https://github.com/grpc/grpc-go/issues/695
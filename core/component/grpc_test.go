@@ -0,0 +1,34 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package component
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/api/pool"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestInitCompression(t *testing.T) {
+	a := New(t)
+
+	for compression, valid := range map[string]bool{
+		"":              true,
+		CompressionGZIP: true,
+		"zstd":          false,
+	} {
+		c := &Component{
+			Config: Config{Compression: compression},
+			Pool:   pool.NewPool(context.Background()),
+		}
+		err := c.initCompression()
+		if valid {
+			a.So(err, ShouldBeNil)
+		} else {
+			a.So(err, ShouldNotBeNil)
+		}
+		a.So(c.ServerOptions(), ShouldNotBeEmpty)
+	}
+}
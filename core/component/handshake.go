@@ -0,0 +1,36 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package component
+
+import (
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/handshake"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// outgoingContextWithHello adds the Hello of this component to the metadata of outgoing requests
+func outgoingContextWithHello(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewOutgoingContext(ctx, metadata.Join(md, handshake.Local().Metadata()))
+}
+
+// validateHello checks that the component that sent a network request uses a compatible protocol version and packet
+// format. Components that do not send a Hello run an older version and are accepted.
+func (c *Component) validateHello(ctx context.Context, serviceName, id string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	hello, ok := handshake.FromMetadata(md)
+	if !ok {
+		if c.Ctx != nil {
+			c.Ctx.WithField("ServiceName", serviceName).WithField("ID", id).Debug("Component did not send a handshake")
+		}
+		return nil
+	}
+	if _, err := handshake.Negotiate(handshake.Local(), hello); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("%s %s is incompatible with %s %s", serviceName, id, c.Identity.ServiceName, c.Identity.ID))
+	}
+	return nil
+}
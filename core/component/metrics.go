@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	"github.com/TheThingsNetwork/ttn/core/invariant"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -34,10 +35,19 @@ var handledBytes = prometheus.NewCounterVec(
 	}, []string{"message_type"},
 )
 
+var invariantViolationsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Name:      "packet_invariant_violations_total",
+		Help:      "Total number of violated invariants of received packets, by packet and violation.",
+	}, []string{"packet", "violation"},
+)
+
 func init() {
 	prometheus.MustRegister(receivedCounter)
 	prometheus.MustRegister(handledCounter)
 	prometheus.MustRegister(handledBytes)
+	prometheus.MustRegister(invariantViolationsCounter)
 }
 
 type message interface {
@@ -63,8 +73,21 @@ func registerHandled(msg message) {
 	handledBytes.WithLabelValues(mType).Add(float64(len(msg.GetPayload())))
 }
 
-// RegisterReceived registers a received message
-func (c *Component) RegisterReceived(msg message) { registerReceived(msg) }
+// checkInvariants counts and logs the violated invariants of a received message
+func (c *Component) checkInvariants(msg message) {
+	for _, violation := range invariant.Check(msg) {
+		invariantViolationsCounter.WithLabelValues(violation.Packet, violation.Type).Inc()
+		if c.Ctx != nil {
+			c.Ctx.WithField("Packet", violation.Packet).WithField("Violation", violation.Type).WithField("Field", violation.Field).Debug("Received packet that violates an invariant")
+		}
+	}
+}
+
+// RegisterReceived registers a received message and checks its invariants
+func (c *Component) RegisterReceived(msg message) {
+	registerReceived(msg)
+	c.checkInvariants(msg)
+}
 
 // RegisterHandled registers a handled message
 func (c *Component) RegisterHandled(msg message) { registerHandled(msg) }
@@ -9,6 +9,7 @@ import (
 	_ "net/http/pprof"
 	"sync"
 
+	"github.com/TheThingsNetwork/ttn/utils/chaos"
 	"github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/viper"
@@ -93,6 +94,10 @@ func initStatus(c *Component) error {
 	if healthPort := viper.GetInt("health-port"); healthPort > 0 {
 		http.Handle("/metrics", promhttp.Handler())
 		http.HandleFunc("/healthz", getStatusPage(c))
+		if chaos.Enabled {
+			c.Ctx.Warn("This build injects faults that are configured on the status server")
+			http.Handle(chaos.Path, RequireAdmin(chaos.Handler, "PUT", "DELETE"))
+		}
 		go func() {
 			if err := http.ListenAndServe(fmt.Sprintf(":%d", healthPort), nil); err != nil {
 				c.Ctx.WithError(err).Error("Status server exited")
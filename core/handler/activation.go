@@ -4,7 +4,6 @@
 package handler
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -13,7 +12,6 @@ import (
 	pb "github.com/TheThingsNetwork/api/handler"
 	"github.com/TheThingsNetwork/api/logfields"
 	"github.com/TheThingsNetwork/api/trace"
-	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/go-utils/random"
 	"github.com/TheThingsNetwork/ttn/core/handler/device"
@@ -165,13 +163,13 @@ func (h *handler) HandleActivation(activation *pb_broker.DeduplicatedDeviceActiv
 		}
 	}
 	if alreadyUsed {
+		if h.isJoinRetransmission(dev, device.DevNonce(reqMAC.DevNonce)) {
+			return h.retransmitJoinAccept(ctx, activation, dev, metadata), nil
+		}
 		err = errors.NewErrInvalidArgument("Activation DevNonce", "already used")
 		return nil, err
 	}
 
-	ctx.Debug("Accepting Join Request")
-	activation.Trace = activation.Trace.WithEvent(trace.AcceptEvent)
-
 	// Prepare Device Activation Response
 	var resPHY lorawan.PHYPayload
 	if err = resPHY.UnmarshalBinary(activation.ResponseTemplate.Payload); err != nil {
@@ -188,6 +186,14 @@ func (h *handler) HandleActivation(activation *pb_broker.DeduplicatedDeviceActiv
 	}
 	resPHY.MACPayload = joinAccept
 
+	// Apply the join accept settings and join webhook of the application
+	if err = h.prepareJoinAccept(activation, dev, device.DevNonce(reqMAC.DevNonce), joinAccept, metadata); err != nil {
+		return nil, err
+	}
+
+	ctx.Debug("Accepting Join Request")
+	activation.Trace = activation.Trace.WithEvent(trace.AcceptEvent)
+
 	// Publish Activation
 	mqttMetadata, _ := h.getActivationMetadata(ctx, activation, dev)
 	h.qEvent <- &types.DeviceEvent{
@@ -227,18 +233,6 @@ func (h *handler) HandleActivation(activation *pb_broker.DeduplicatedDeviceActiv
 		return nil, err
 	}
 
-	// Update Device
-	dev.StartUpdate()
-	dev.DevAddr = types.DevAddr(joinAccept.DevAddr)
-	dev.AppSKey = appSKey
-	dev.NwkSKey = nwkSKey
-	dev.UsedAppNonces = append(dev.UsedAppNonces, appNonce)
-	dev.UsedDevNonces = append(dev.UsedDevNonces, device.DevNonce(reqMAC.DevNonce))
-	err = h.devices.Set(dev)
-	if err != nil {
-		return nil, err
-	}
-
 	if err = resPHY.SetMIC(lorawan.AES128Key(dev.AppKey)); err != nil {
 		return nil, err
 	}
@@ -252,6 +246,26 @@ func (h *handler) HandleActivation(activation *pb_broker.DeduplicatedDeviceActiv
 		return nil, err
 	}
 
+	// Update Device
+	dev.StartUpdate()
+	dev.DevAddr = types.DevAddr(joinAccept.DevAddr)
+	dev.AppSKey = appSKey
+	dev.NwkSKey = nwkSKey
+	dev.UsedAppNonces = append(dev.UsedAppNonces, appNonce)
+	dev.UsedDevNonces = append(dev.UsedDevNonces, device.DevNonce(reqMAC.DevNonce))
+	dev.LastJoinAccept = &device.JoinAccept{
+		DevNonce:    device.DevNonce(reqMAC.DevNonce),
+		Payload:     resBytes,
+		Rx1DROffset: metadata.Rx1DROffset,
+		Rx2DR:       metadata.Rx2DR,
+		RxDelay:     metadata.RxDelay,
+		Time:        time.Now(),
+	}
+	err = h.devices.Set(dev)
+	if err != nil {
+		return nil, err
+	}
+
 	metadata.NwkSKey = &dev.NwkSKey
 	metadata.DevAddr = &dev.DevAddr
 	res = &pb.DeviceActivationResponse{
@@ -287,7 +301,9 @@ func (h *handler) registerDeviceOnJoin(base *device.Device, activation *pb_broke
 	lorawanPb := clone.ToLoRaWANPb()
 	lorawanPb.AppKey = nil
 	lorawanPb.AppSKey = nil
-	_, err = h.ttnDeviceManager.SetDevice(ttnctx.OutgoingContextWithToken(context.Background(), token), lorawanPb)
+	reqCtx, cancel := h.Component.GetRequestContext(token)
+	defer cancel()
+	_, err = h.ttnDeviceManager.SetDevice(reqCtx, lorawanPb)
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "Broker did not set device")
 	}
@@ -0,0 +1,189 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package alert contains the rules that applications can configure to be notified of device and gateway problems
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Metric is the value that is checked by a rule
+type Metric string
+
+const (
+	// MetricField checks a numeric field of the decoded payload, for example a battery level
+	MetricField Metric = "field"
+	// MetricMissedUplinks checks the number of uplinks that were missed before the last uplink
+	MetricMissedUplinks Metric = "missed_uplinks"
+	// MetricDeviceOffline fires when a device was not seen within the heartbeat interval
+	MetricDeviceOffline Metric = "device_offline"
+	// MetricGatewayOffline fires when a gateway that forwarded uplinks of the application was silent for Threshold seconds
+	MetricGatewayOffline Metric = "gateway_offline"
+)
+
+// Operator compares the value of a metric with the threshold of a rule
+type Operator string
+
+// Operators
+const (
+	OperatorLessThan       Operator = "<"
+	OperatorLessOrEqual    Operator = "<="
+	OperatorGreaterThan    Operator = ">"
+	OperatorGreaterOrEqual Operator = ">="
+	OperatorEqual          Operator = "=="
+)
+
+// Severity is the importance of an alert
+type Severity string
+
+// Severities
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// TargetEvent is the notification target that publishes the alert as an event over MQTT and AMQP
+const TargetEvent = "event"
+
+// Rule fires an alert when its condition is met
+type Rule struct {
+	ID        string   `json:"id"`
+	Metric    Metric   `json:"metric"`
+	Field     string   `json:"field,omitempty"`
+	Operator  Operator `json:"operator,omitempty"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Severity  Severity `json:"severity,omitempty"` // defaults to warning
+
+	// Notify contains the targets of the alert. A target is either "event" or
+	// formatted as scheme:address, for example webhook:https://example.com/alerts or
+	// email:ops@example.com
+	Notify []string `json:"notify"`
+}
+
+// Validate the rule
+func (r Rule) Validate() error {
+	if r.ID == "" {
+		return errors.NewErrInvalidArgument("Rule ID", "can not be empty")
+	}
+	switch r.Metric {
+	case MetricField:
+		if r.Field == "" {
+			return errors.NewErrInvalidArgument("Rule Field", "can not be empty")
+		}
+		fallthrough
+	case MetricMissedUplinks:
+		switch r.Operator {
+		case OperatorLessThan, OperatorLessOrEqual, OperatorGreaterThan, OperatorGreaterOrEqual, OperatorEqual:
+		default:
+			return errors.NewErrInvalidArgument("Rule Operator", fmt.Sprintf("unknown operator %s", r.Operator))
+		}
+	case MetricDeviceOffline:
+	case MetricGatewayOffline:
+		if r.Threshold <= 0 {
+			return errors.NewErrInvalidArgument("Rule Threshold", "must be a positive number of seconds")
+		}
+	default:
+		return errors.NewErrInvalidArgument("Rule Metric", fmt.Sprintf("unknown metric %s", r.Metric))
+	}
+	switch r.Severity {
+	case "", SeverityInfo, SeverityWarning, SeverityCritical:
+	default:
+		return errors.NewErrInvalidArgument("Rule Severity", fmt.Sprintf("unknown severity %s", r.Severity))
+	}
+	if len(r.Notify) == 0 {
+		return errors.NewErrInvalidArgument("Rule Notify", "can not be empty")
+	}
+	for _, target := range r.Notify {
+		if target == TargetEvent {
+			continue
+		}
+		if scheme, address := ParseTarget(target); scheme == "" || address == "" {
+			return errors.NewErrInvalidArgument("Rule Notify", fmt.Sprintf("invalid target %s", target))
+		}
+	}
+	return nil
+}
+
+// GetSeverity returns the severity of the alerts of the rule
+func (r Rule) GetSeverity() Severity {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+// Matches returns true if the value meets the condition of the rule
+func (r Rule) Matches(value float64) bool {
+	switch r.Operator {
+	case OperatorLessThan:
+		return value < r.Threshold
+	case OperatorLessOrEqual:
+		return value <= r.Threshold
+	case OperatorGreaterThan:
+		return value > r.Threshold
+	case OperatorGreaterOrEqual:
+		return value >= r.Threshold
+	case OperatorEqual:
+		return value == r.Threshold
+	}
+	return false
+}
+
+// ValidateRules validates the rules and checks that their IDs are unique
+func ValidateRules(rules []Rule) error {
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if ids[rule.ID] {
+			return errors.NewErrInvalidArgument("Rule ID", fmt.Sprintf("%s is not unique", rule.ID))
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+// ParseTarget splits a notification target in its scheme and address
+func ParseTarget(target string) (scheme, address string) {
+	parts := strings.SplitN(target, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// Alert is fired when the condition of a rule is met
+type Alert struct {
+	AppID     string    `json:"app_id"`
+	DevID     string    `json:"dev_id,omitempty"`
+	GtwID     string    `json:"gtw_id,omitempty"`
+	RuleID    string    `json:"rule_id"`
+	Metric    Metric    `json:"metric"`
+	Field     string    `json:"field,omitempty"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold,omitempty"`
+	Severity  Severity  `json:"severity"`
+	Time      time.Time `json:"time"`
+}
+
+// Message returns a human-readable description of the alert
+func (a *Alert) Message() string {
+	switch a.Metric {
+	case MetricField:
+		return fmt.Sprintf("Device %s: %s is %v (threshold %v)", a.DevID, a.Field, a.Value, a.Threshold)
+	case MetricMissedUplinks:
+		return fmt.Sprintf("Device %s: missed %v uplinks (threshold %v)", a.DevID, a.Value, a.Threshold)
+	case MetricDeviceOffline:
+		return fmt.Sprintf("Device %s is offline", a.DevID)
+	case MetricGatewayOffline:
+		return fmt.Sprintf("Gateway %s was not seen for %v seconds", a.GtwID, a.Value)
+	}
+	return fmt.Sprintf("Rule %s fired", a.RuleID)
+}
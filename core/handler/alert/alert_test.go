@@ -0,0 +1,171 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRuleValidate(t *testing.T) {
+	a := New(t)
+
+	a.So(Rule{}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "battery", Metric: MetricField, Operator: OperatorLessThan, Notify: []string{TargetEvent}}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "battery", Metric: MetricField, Field: "battery", Operator: "~", Notify: []string{TargetEvent}}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "battery", Metric: MetricField, Field: "battery", Operator: OperatorLessThan}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "battery", Metric: MetricField, Field: "battery", Operator: OperatorLessThan, Notify: []string{"webhook"}}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "battery", Metric: MetricField, Field: "battery", Operator: OperatorLessThan, Notify: []string{TargetEvent, "webhook:http://localhost"}}.Validate(), ShouldBeNil)
+	a.So(Rule{ID: "offline", Metric: MetricDeviceOffline, Notify: []string{TargetEvent}}.Validate(), ShouldBeNil)
+	a.So(Rule{ID: "gateway", Metric: MetricGatewayOffline, Notify: []string{TargetEvent}}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "gateway", Metric: MetricGatewayOffline, Threshold: 600, Notify: []string{TargetEvent}}.Validate(), ShouldBeNil)
+	a.So(Rule{ID: "gateway", Metric: MetricGatewayOffline, Threshold: 600, Severity: "fatal", Notify: []string{TargetEvent}}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "gateway", Metric: MetricGatewayOffline, Threshold: 600, Severity: SeverityCritical, Notify: []string{TargetEvent}}.Validate(), ShouldBeNil)
+	a.So(Rule{}.GetSeverity(), ShouldEqual, SeverityWarning)
+
+	offline := Rule{ID: "offline", Metric: MetricDeviceOffline, Notify: []string{TargetEvent}}
+	a.So(ValidateRules([]Rule{offline}), ShouldBeNil)
+	a.So(ValidateRules([]Rule{offline, offline}), ShouldNotBeNil)
+}
+
+func TestRuleMatches(t *testing.T) {
+	a := New(t)
+
+	rule := Rule{Operator: OperatorLessThan, Threshold: 10}
+	a.So(rule.Matches(5), ShouldBeTrue)
+	a.So(rule.Matches(10), ShouldBeFalse)
+
+	rule.Operator = OperatorGreaterOrEqual
+	a.So(rule.Matches(10), ShouldBeTrue)
+	a.So(rule.Matches(5), ShouldBeFalse)
+}
+
+func TestState(t *testing.T) {
+	a := New(t)
+
+	s := NewState()
+	a.So(s.Update("app", "dev", "battery", false), ShouldBeFalse)
+	a.So(s.Update("app", "dev", "battery", true), ShouldBeTrue)
+	a.So(s.Update("app", "dev", "battery", true), ShouldBeFalse)
+	a.So(s.Update("app", "other-dev", "battery", true), ShouldBeTrue)
+	a.So(s.Update("app", "dev", "battery", false), ShouldBeFalse)
+	a.So(s.Update("app", "dev", "battery", true), ShouldBeTrue)
+
+	now := time.Now()
+	s.SeenGateway("app", "gtw", now)
+	s.SeenGateway("app", "gtw", now.Add(-time.Minute))
+	a.So(s.Applications(), ShouldResemble, []string{"app"})
+	a.So(s.Gateways("app")["gtw"], ShouldEqual, now)
+
+	// Expired alerts fire again, and expired gateways are removed
+	s.Expire(now.Add(StateTTL + time.Minute))
+	a.So(s.Update("app", "dev", "battery", true), ShouldBeTrue)
+	a.So(s.Applications(), ShouldBeEmpty)
+
+	s.SeenGateway("app", "gtw", now)
+	s.Forget("app")
+	a.So(s.Update("app", "dev", "battery", true), ShouldBeTrue)
+	a.So(s.Applications(), ShouldBeEmpty)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	a := New(t)
+
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := NewWebhookNotifier().Notify(server.URL, &Alert{AppID: "app", DevID: "dev", RuleID: "battery"})
+	a.So(err, ShouldBeNil)
+	a.So(received.RuleID, ShouldEqual, "battery")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	err = NewWebhookNotifier().Notify(failing.URL, &Alert{})
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestEmailNotifier(t *testing.T) {
+	a := New(t)
+
+	var sent []string
+	n := NewEmailNotifier("localhost:25", "", "", "alerts@example.com")
+	n.RateLimit = 2
+	n.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		a.So(addr, ShouldEqual, "localhost:25")
+		a.So(from, ShouldEqual, "alerts@example.com")
+		a.So(to, ShouldResemble, []string{"ops@example.com"})
+		sent = append(sent, string(msg))
+		return nil
+	}
+
+	alert := &Alert{AppID: "app", DevID: "dev", RuleID: "battery", Metric: MetricField, Field: "battery", Value: 5, Threshold: 10, Time: time.Now()}
+	err := n.Notify("ops@example.com", alert)
+	a.So(err, ShouldBeNil)
+	a.So(sent, ShouldHaveLength, 1)
+	a.So(sent[0], ShouldContainSubstring, "Subject: [app] Alert battery\r\n")
+	a.So(sent[0], ShouldContainSubstring, "Device dev: battery is 5 (threshold 10)\r\n")
+	a.So(sent[0], ShouldContainSubstring, "Device:      dev\r\n")
+	a.So(sent[0], ShouldNotContainSubstring, "Gateway:")
+
+	a.So(n.SetTemplates("{{.RuleID", ""), ShouldNotBeNil)
+	a.So(n.SetTemplates("{{.RuleID}}\nfired", "{{.Message}}"), ShouldBeNil)
+	err = n.Notify("ops@example.com", alert)
+	a.So(err, ShouldBeNil)
+	a.So(sent, ShouldHaveLength, 2)
+	a.So(sent[1], ShouldContainSubstring, "Subject: battery fired\r\n")
+	a.So(strings.HasSuffix(sent[1], "\r\n\r\nDevice dev: battery is 5 (threshold 10)"), ShouldBeTrue)
+
+	// Rate limited
+	err = n.Notify("ops@example.com", alert)
+	a.So(err, ShouldNotBeNil)
+	a.So(sent, ShouldHaveLength, 2)
+
+	err = n.Notify("ops@example.com\r\nBcc: other@example.com", alert)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestSlackNotifier(t *testing.T) {
+	a := New(t)
+
+	received := make(map[string]slackMessage)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message slackMessage
+		json.NewDecoder(r.Body).Decode(&message)
+		received[r.URL.Path] = message
+	}))
+	defer server.Close()
+
+	n := NewSlackNotifier(map[Severity]string{
+		SeverityCritical: server.URL + "/critical",
+		"":               server.URL + "/default",
+	})
+
+	alert := &Alert{AppID: "app", GtwID: "gtw", RuleID: "gateway", Metric: MetricGatewayOffline, Value: 600, Severity: SeverityCritical}
+	a.So(n.Notify(SlackOperators, alert), ShouldBeNil)
+	a.So(received, ShouldContainKey, "/critical")
+	a.So(received["/critical"].Text, ShouldEqual, "[app] Gateway gtw was not seen for 600 seconds")
+	a.So(received["/critical"].Attachments[0].Color, ShouldEqual, "danger")
+
+	alert.Severity = SeverityInfo
+	a.So(n.Notify(SlackOperators, alert), ShouldBeNil)
+	a.So(received, ShouldContainKey, "/default")
+
+	a.So(n.Notify(server.URL+"/app", alert), ShouldBeNil)
+	a.So(received, ShouldContainKey, "/app")
+
+	a.So(NewSlackNotifier(nil).Notify(SlackOperators, alert), ShouldNotBeNil)
+}
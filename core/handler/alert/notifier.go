@@ -0,0 +1,274 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers alerts to the address of a notification target
+type Notifier interface {
+	Notify(address string, alert *Alert) error
+}
+
+// WebhookNotifier posts alerts as JSON to an HTTP endpoint
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+// NewWebhookNotifier returns a new WebhookNotifier
+func NewWebhookNotifier() *WebhookNotifier {
+	return &WebhookNotifier{
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify implements the Notifier interface
+func (n *WebhookNotifier) Notify(address string, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	res, err := n.Client.Post(address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Webhook returned status %s", res.Status)
+	}
+	return nil
+}
+
+// Default templates of the EmailNotifier. The templates are executed with the Alert.
+const (
+	DefaultEmailSubject = `[{{.AppID}}] Alert {{.RuleID}}`
+	DefaultEmailBody    = `{{.Message}}
+
+Application: {{.AppID}}
+{{if .DevID}}Device:      {{.DevID}}
+{{end}}{{if .GtwID}}Gateway:     {{.GtwID}}
+{{end}}Rule:        {{.RuleID}}
+Time:        {{.Time.UTC.Format "2006-01-02 15:04:05 MST"}}
+`
+)
+
+// EmailNotifier sends alerts as email through an SMTP server. It sends at most RateLimit emails per hour to a
+// recipient; alerts that exceed the limit are dropped.
+type EmailNotifier struct {
+	Server    string // host:port
+	Auth      smtp.Auth
+	From      string
+	RateLimit int // zero disables rate limiting
+
+	subject *template.Template
+	body    *template.Template
+
+	mu   sync.Mutex
+	sent map[string][]time.Time
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewEmailNotifier returns a new EmailNotifier with the default templates. If username is not empty, it
+// authenticates with the SMTP server using PLAIN authentication.
+func NewEmailNotifier(server, username, password, from string) *EmailNotifier {
+	n := &EmailNotifier{
+		Server:    server,
+		From:      from,
+		RateLimit: 10,
+		subject:   template.Must(template.New("subject").Parse(DefaultEmailSubject)),
+		body:      template.Must(template.New("body").Parse(DefaultEmailBody)),
+		sent:      make(map[string][]time.Time),
+		sendMail:  smtp.SendMail,
+	}
+	if username != "" {
+		host := server
+		if i := strings.LastIndex(server, ":"); i >= 0 {
+			host = server[:i]
+		}
+		n.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n
+}
+
+// SetTemplates sets the templates of the subject and body of the emails. Empty templates are not changed.
+func (n *EmailNotifier) SetTemplates(subject, body string) error {
+	if subject != "" {
+		tmpl, err := template.New("subject").Parse(subject)
+		if err != nil {
+			return err
+		}
+		n.subject = tmpl
+	}
+	if body != "" {
+		tmpl, err := template.New("body").Parse(body)
+		if err != nil {
+			return err
+		}
+		n.body = tmpl
+	}
+	return nil
+}
+
+// allow returns true if an email can be sent to the recipient without exceeding the rate limit
+func (n *EmailNotifier) allow(to string, now time.Time) bool {
+	if n.RateLimit <= 0 {
+		return true
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var recent []time.Time
+	for _, t := range n.sent[to] {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= n.RateLimit {
+		n.sent[to] = recent
+		return false
+	}
+	n.sent[to] = append(recent, now)
+	return true
+}
+
+// Notify implements the Notifier interface
+func (n *EmailNotifier) Notify(address string, alert *Alert) error {
+	if strings.ContainsAny(address, "\r\n") {
+		return fmt.Errorf("Invalid email address %q", address)
+	}
+	var subject, body bytes.Buffer
+	if err := n.subject.Execute(&subject, alert); err != nil {
+		return err
+	}
+	if err := n.body.Execute(&body, alert); err != nil {
+		return err
+	}
+	if !n.allow(address, time.Now()) {
+		return fmt.Errorf("Rate limit of %d emails per hour to %s exceeded", n.RateLimit, address)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", address)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.Join(strings.Fields(subject.String()), " "))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+
+	return n.sendMail(n.Server, n.Auth, n.From, []string{address}, msg.Bytes())
+}
+
+// SlackOperators is the address of a Slack target that posts to the route of the operators for the severity of the
+// alert, for example slack:operators
+const SlackOperators = "operators"
+
+var slackColors = map[Severity]string{
+	SeverityInfo:     "good",
+	SeverityWarning:  "warning",
+	SeverityCritical: "danger",
+}
+
+// SlackNotifier posts alerts to Slack-compatible incoming webhooks. The address of a target is either the URL of a
+// webhook or SlackOperators, which posts to the webhook in Routes for the severity of the alert, or to the webhook
+// of the empty severity if there is no route for that severity.
+type SlackNotifier struct {
+	Client *http.Client
+	Routes map[Severity]string
+}
+
+// NewSlackNotifier returns a new SlackNotifier
+func NewSlackNotifier(routes map[Severity]string) *SlackNotifier {
+	return &SlackNotifier{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Routes: routes,
+	}
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Fallback string       `json:"fallback"`
+	Color    string       `json:"color,omitempty"`
+	Title    string       `json:"title"`
+	Fields   []slackField `json:"fields,omitempty"`
+	Ts       int64        `json:"ts,omitempty"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (n *SlackNotifier) webhook(address string, severity Severity) (string, error) {
+	if address != SlackOperators {
+		return address, nil
+	}
+	if url, ok := n.Routes[severity]; ok {
+		return url, nil
+	}
+	if url, ok := n.Routes[""]; ok {
+		return url, nil
+	}
+	return "", fmt.Errorf("No Slack route for %s alerts", severity)
+}
+
+// Notify implements the Notifier interface
+func (n *SlackNotifier) Notify(address string, alert *Alert) error {
+	url, err := n.webhook(address, alert.Severity)
+	if err != nil {
+		return err
+	}
+
+	fields := []slackField{
+		{Title: "Application", Value: alert.AppID, Short: true},
+		{Title: "Rule", Value: alert.RuleID, Short: true},
+	}
+	if alert.DevID != "" {
+		fields = append(fields, slackField{Title: "Device", Value: alert.DevID, Short: true})
+	}
+	if alert.GtwID != "" {
+		fields = append(fields, slackField{Title: "Gateway", Value: alert.GtwID, Short: true})
+	}
+	fields = append(fields, slackField{Title: "Severity", Value: string(alert.Severity), Short: true})
+	message := slackMessage{
+		Text: fmt.Sprintf("[%s] %s", alert.AppID, alert.Message()),
+		Attachments: []slackAttachment{{
+			Fallback: alert.Message(),
+			Color:    slackColors[alert.Severity],
+			Title:    alert.Message(),
+			Fields:   fields,
+		}},
+	}
+	if !alert.Time.IsZero() {
+		message.Attachments[0].Ts = alert.Time.Unix()
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	res, err := n.Client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Slack webhook returned status %s", res.Status)
+	}
+	return nil
+}
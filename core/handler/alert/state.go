@@ -0,0 +1,117 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package alert
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// StateTTL is the time after which active alerts and gateways that were not updated are removed from the State. An
+// alert of which the condition is still met after it is removed fires again.
+var StateTTL = 7 * 24 * time.Hour
+
+// State keeps track of the active alerts, so that a rule only fires when its condition starts to be met
+type State struct {
+	mu       sync.Mutex
+	active   map[string]time.Time // since
+	gateways map[string]map[string]time.Time
+}
+
+// NewState returns a new State
+func NewState() *State {
+	return &State{
+		active:   make(map[string]time.Time),
+		gateways: make(map[string]map[string]time.Time),
+	}
+}
+
+// Update sets the condition of the rule for the given subject and returns true if the rule should fire
+func (s *State) Update(appID, subject, ruleID string, matches bool) (fire bool) {
+	key := appID + "/" + subject + "/" + ruleID
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !matches {
+		delete(s.active, key)
+		return false
+	}
+	if _, ok := s.active[key]; ok {
+		return false
+	}
+	s.active[key] = time.Now()
+	return true
+}
+
+// SeenGateway records that a gateway forwarded an uplink of the application
+func (s *State) SeenGateway(appID, gtwID string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gateways, ok := s.gateways[appID]
+	if !ok {
+		gateways = make(map[string]time.Time)
+		s.gateways[appID] = gateways
+	}
+	if at.After(gateways[gtwID]) {
+		gateways[gtwID] = at
+	}
+}
+
+// Gateways returns when the gateways of the application were last seen
+func (s *State) Gateways(appID string) map[string]time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gateways := make(map[string]time.Time, len(s.gateways[appID]))
+	for gtwID, lastSeen := range s.gateways[appID] {
+		gateways[gtwID] = lastSeen
+	}
+	return gateways
+}
+
+// Applications returns the IDs of the applications that have gateways in the state
+func (s *State) Applications() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	appIDs := make([]string, 0, len(s.gateways))
+	for appID := range s.gateways {
+		appIDs = append(appIDs, appID)
+	}
+	return appIDs
+}
+
+// Expire removes the alerts that are active for longer than the StateTTL and the gateways that were not seen within
+// the StateTTL
+func (s *State) Expire(now time.Time) {
+	before := now.Add(-StateTTL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, since := range s.active {
+		if since.Before(before) {
+			delete(s.active, key)
+		}
+	}
+	for appID, gateways := range s.gateways {
+		for gtwID, lastSeen := range gateways {
+			if lastSeen.Before(before) {
+				delete(gateways, gtwID)
+			}
+		}
+		if len(gateways) == 0 {
+			delete(s.gateways, appID)
+		}
+	}
+}
+
+// Forget removes the active alerts and the gateways of the application
+func (s *State) Forget(appID string) {
+	prefix := appID + "/"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.active {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.active, key)
+		}
+	}
+	delete(s.gateways, appID)
+}
@@ -0,0 +1,227 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strings"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// AlertCheckInterval is the interval in which gateway offline rules are checked
+var AlertCheckInterval = time.Minute
+
+func (h *handler) WithAlertNotifier(scheme string, notifier alert.Notifier) Handler {
+	h.alertNotifiers[scheme] = notifier
+	return h
+}
+
+// SetAlertRules replaces the alert rules of the application
+func (h *handler) SetAlertRules(appID string, rules []alert.Rule) error {
+	if err := alert.ValidateRules(rules); err != nil {
+		return err
+	}
+	app, err := h.applications.Get(appID)
+	if err != nil {
+		return err
+	}
+	app.StartUpdate()
+	app.AlertRules = rules
+	return h.applications.Set(app)
+}
+
+// EvaluateAlerts evaluates the alert rules of the application on the uplink
+func (h *handler) EvaluateAlerts(ctx ttnlog.Interface, _ *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) error {
+	app, err := h.applications.Get(appUp.AppID)
+	if err != nil || len(app.AlertRules) == 0 {
+		return nil // Do not process if application not found or has no rules
+	}
+
+	for _, gtw := range appUp.Metadata.Gateways {
+		h.alerts.SeenGateway(appUp.AppID, gtw.GtwID, dev.LastSeen)
+	}
+
+	for _, rule := range app.AlertRules {
+		var value float64
+		var matches bool
+		switch rule.Metric {
+		case alert.MetricField:
+			value, matches = numericField(appUp.PayloadFields, rule.Field)
+			if !matches {
+				continue // The field is not in this uplink
+			}
+			matches = rule.Matches(value)
+		case alert.MetricMissedUplinks:
+			value = float64(dev.MissedUplinks)
+			matches = rule.Matches(value)
+		case alert.MetricDeviceOffline:
+			h.alerts.Update(appUp.AppID, appUp.DevID, rule.ID, false) // The device is online again
+			continue
+		default:
+			continue
+		}
+		if h.alerts.Update(appUp.AppID, appUp.DevID, rule.ID, matches) {
+			h.fireAlert(rule, &alert.Alert{
+				AppID:     appUp.AppID,
+				DevID:     appUp.DevID,
+				RuleID:    rule.ID,
+				Metric:    rule.Metric,
+				Field:     rule.Field,
+				Value:     value,
+				Threshold: rule.Threshold,
+				Time:      dev.LastSeen,
+			})
+		}
+	}
+
+	return nil
+}
+
+// evaluateOfflineAlerts evaluates the device offline rules of the application of the device
+func (h *handler) evaluateOfflineAlerts(dev *device.Device) {
+	app, err := h.applications.Get(dev.AppID)
+	if err != nil {
+		return
+	}
+	for _, rule := range app.AlertRules {
+		if rule.Metric != alert.MetricDeviceOffline {
+			continue
+		}
+		if h.alerts.Update(dev.AppID, dev.DevID, rule.ID, true) {
+			h.fireAlert(rule, &alert.Alert{
+				AppID:  dev.AppID,
+				DevID:  dev.DevID,
+				RuleID: rule.ID,
+				Metric: rule.Metric,
+				Time:   time.Now(),
+			})
+		}
+	}
+}
+
+// checkGatewayAlerts evaluates the gateway offline rules of all applications, and removes the state of applications
+// that no longer have alert rules
+func (h *handler) checkGatewayAlerts() {
+	h.alerts.Expire(time.Now())
+	for _, appID := range h.alerts.Applications() {
+		app, err := h.applications.Get(appID)
+		if errors.GetErrType(err) == errors.NotFound || (err == nil && len(app.AlertRules) == 0) {
+			h.alerts.Forget(appID)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		gateways := h.alerts.Gateways(appID)
+		for _, rule := range app.AlertRules {
+			if rule.Metric != alert.MetricGatewayOffline {
+				continue
+			}
+			for gtwID, lastSeen := range gateways {
+				silence := time.Since(lastSeen).Seconds()
+				if h.alerts.Update(appID, "gateways/"+gtwID, rule.ID, silence >= rule.Threshold) {
+					h.fireAlert(rule, &alert.Alert{
+						AppID:     appID,
+						GtwID:     gtwID,
+						RuleID:    rule.ID,
+						Metric:    rule.Metric,
+						Value:     silence,
+						Threshold: rule.Threshold,
+						Time:      time.Now(),
+					})
+				}
+			}
+		}
+	}
+}
+
+func (h *handler) monitorAlerts() {
+	for range time.Tick(AlertCheckInterval) {
+		h.checkGatewayAlerts()
+	}
+}
+
+// fireAlert sends the alert to the notification targets of the rule
+func (h *handler) fireAlert(rule alert.Rule, a *alert.Alert) {
+	a.Severity = rule.GetSeverity()
+	ctx := h.Ctx.WithFields(ttnlog.Fields{
+		"AppID":  a.AppID,
+		"RuleID": a.RuleID,
+	})
+	ctx.Info("Alert fired")
+	for _, target := range rule.Notify {
+		if target == alert.TargetEvent {
+			h.qEvent <- &types.DeviceEvent{
+				AppID: a.AppID,
+				DevID: a.DevID,
+				Event: types.AlertEvent,
+				Data: types.AlertEventData{
+					RuleID:    a.RuleID,
+					Metric:    string(a.Metric),
+					Field:     a.Field,
+					GtwID:     a.GtwID,
+					Value:     a.Value,
+					Threshold: a.Threshold,
+					Severity:  string(a.Severity),
+					Message:   a.Message(),
+					Time:      types.JSONTime(a.Time),
+				},
+			}
+			continue
+		}
+		scheme, address := alert.ParseTarget(target)
+		notifier, ok := h.alertNotifiers[scheme]
+		if !ok {
+			ctx.WithField("Scheme", scheme).Warn("No notifier for alert target")
+			continue
+		}
+		go func() {
+			if err := notifier.Notify(address, a); err != nil {
+				ctx.WithError(err).WithField("Scheme", scheme).Warn("Could not send alert")
+			}
+		}()
+	}
+}
+
+// numericField returns the numeric value of a (dot-separated) field in the payload fields
+func numericField(fields map[string]interface{}, name string) (float64, bool) {
+	var value interface{} = fields
+	for _, part := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		if value, ok = m[part]; !ok {
+			return 0, false
+		}
+	}
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
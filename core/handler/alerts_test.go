@@ -0,0 +1,115 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestEvaluateAlerts(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component:      &component.Component{Ctx: GetLogger(t, "TestEvaluateAlerts")},
+		applications:   application.NewRedisApplicationStore(GetRedisClient(), "handler-test-evaluate-alerts"),
+		qEvent:         make(chan *types.DeviceEvent, 10),
+		alerts:         alert.NewState(),
+		alertNotifiers: map[string]alert.Notifier{},
+	}
+
+	appID, devID := "app", "dev"
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	err := h.SetAlertRules(appID, []alert.Rule{{ID: "invalid"}})
+	a.So(err, ShouldNotBeNil)
+
+	err = h.SetAlertRules(appID, []alert.Rule{
+		{ID: "battery", Metric: alert.MetricField, Field: "battery.level", Operator: alert.OperatorLessThan, Threshold: 10, Notify: []string{alert.TargetEvent}},
+		{ID: "missed", Metric: alert.MetricMissedUplinks, Operator: alert.OperatorGreaterOrEqual, Threshold: 5, Notify: []string{alert.TargetEvent}},
+		{ID: "offline", Metric: alert.MetricDeviceOffline, Notify: []string{alert.TargetEvent}},
+		{ID: "gateway", Metric: alert.MetricGatewayOffline, Threshold: 60, Notify: []string{alert.TargetEvent}},
+	})
+	a.So(err, ShouldBeNil)
+
+	dev := &device.Device{AppID: appID, DevID: devID, LastSeen: time.Now().Add(-2 * time.Minute)}
+	appUp := &types.UplinkMessage{
+		AppID:         appID,
+		DevID:         devID,
+		PayloadFields: map[string]interface{}{"battery": map[string]interface{}{"level": 50.0}},
+	}
+	appUp.Metadata.Gateways = []types.GatewayMetadata{{GtwID: "gtw"}}
+
+	// No alerts
+	err = h.EvaluateAlerts(GetLogger(t, "TestEvaluateAlerts"), nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	// Low battery and missed uplinks
+	appUp.PayloadFields = map[string]interface{}{"battery": map[string]interface{}{"level": 5.0}}
+	dev.MissedUplinks = 7
+	err = h.EvaluateAlerts(GetLogger(t, "TestEvaluateAlerts"), nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 2)
+	event := <-h.qEvent
+	a.So(event.Event, ShouldEqual, types.AlertEvent)
+	a.So(event.Data.(types.AlertEventData).RuleID, ShouldEqual, "battery")
+	a.So(event.Data.(types.AlertEventData).Value, ShouldEqual, 5)
+	a.So(event.Data.(types.AlertEventData).Severity, ShouldEqual, "warning")
+	event = <-h.qEvent
+	a.So(event.Data.(types.AlertEventData).RuleID, ShouldEqual, "missed")
+
+	// Alerts only fire once
+	err = h.EvaluateAlerts(GetLogger(t, "TestEvaluateAlerts"), nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	// Device offline
+	h.evaluateOfflineAlerts(dev)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	event = <-h.qEvent
+	a.So(event.Data.(types.AlertEventData).RuleID, ShouldEqual, "offline")
+
+	// Gateway offline
+	h.checkGatewayAlerts()
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	event = <-h.qEvent
+	a.So(event.DevID, ShouldBeEmpty)
+	a.So(event.Data.(types.AlertEventData).GtwID, ShouldEqual, "gtw")
+}
+
+func TestNumericField(t *testing.T) {
+	a := New(t)
+
+	fields := map[string]interface{}{
+		"battery": 3.3,
+		"status":  map[string]interface{}{"charging": true},
+		"name":    "test",
+	}
+
+	value, ok := numericField(fields, "battery")
+	a.So(ok, ShouldBeTrue)
+	a.So(value, ShouldEqual, 3.3)
+
+	value, ok = numericField(fields, "status.charging")
+	a.So(ok, ShouldBeTrue)
+	a.So(value, ShouldEqual, 1)
+
+	_, ok = numericField(fields, "name")
+	a.So(ok, ShouldBeFalse)
+
+	_, ok = numericField(fields, "battery.level")
+	a.So(ok, ShouldBeFalse)
+
+	_, ok = numericField(fields, "unknown")
+	a.So(ok, ShouldBeFalse)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/anomaly"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+func (h *handler) WithAnomalyDetection(threshold float64) Handler {
+	h.anomalyThreshold = threshold
+	return h
+}
+
+// DetectAnomalies scores the uplink against the profile of the device and emits an anomaly event for each metric
+// that deviates at least the anomaly threshold in standard deviations. It must run before UpdateConnectivity, which
+// updates the last seen time of the device.
+func (h *handler) DetectAnomalies(ctx ttnlog.Interface, ttnUp *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) error {
+	if h.anomalyThreshold <= 0 || appUp.IsRetry {
+		return nil
+	}
+
+	up := anomaly.Uplink{PayloadSize: len(appUp.PayloadRaw)}
+	now := time.Now()
+	if ttnUp.ServerTime != 0 {
+		now = time.Unix(0, ttnUp.ServerTime)
+	}
+	if !dev.LastSeen.IsZero() && now.After(dev.LastSeen) {
+		up.Interval = now.Sub(dev.LastSeen)
+	}
+	for i, gtw := range appUp.Metadata.Gateways {
+		if i == 0 || gtw.SNR > up.SNR || (gtw.SNR == up.SNR && gtw.RSSI > up.RSSI) {
+			up.RSSI, up.SNR, up.HasSignal = gtw.RSSI, gtw.SNR, true
+		}
+	}
+
+	for _, a := range dev.Profile.Update(up, h.anomalyThreshold) {
+		ctx.WithField("Metric", a.Metric).WithField("Score", a.Score).Debug("Detected anomaly")
+		h.qEvent <- &types.DeviceEvent{
+			AppID: appUp.AppID,
+			DevID: appUp.DevID,
+			Event: types.AnomalyEvent,
+			Data: types.AnomalyEventData{
+				Metric: a.Metric,
+				Value:  a.Value,
+				Mean:   a.Mean,
+				StdDev: a.StdDev,
+				Score:  a.Score,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package anomaly learns the typical behavior of devices and scores how much an uplink deviates from it
+package anomaly
+
+import (
+	"math"
+	"time"
+)
+
+// alpha is the weight of a new sample in the moving averages
+const alpha = 0.1
+
+// minSamples is the number of samples that is needed before a metric is scored
+const minSamples = 10
+
+// Metrics
+const (
+	MetricInterval    = "interval"     // seconds since the previous uplink
+	MetricRSSI        = "rssi"         // dBm, of the gateway with the best SNR
+	MetricSNR         = "snr"          // dB, of the gateway with the best SNR
+	MetricPayloadSize = "payload_size" // bytes
+)
+
+// Stat is an exponentially weighted moving mean and variance of a metric
+type Stat struct {
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  uint32  `json:"samples"`
+}
+
+// Add a sample
+func (s *Stat) Add(value float64) {
+	if s.Samples == 0 {
+		s.Mean = value
+	} else {
+		diff := value - s.Mean
+		incr := alpha * diff
+		s.Mean += incr
+		s.Variance = (1 - alpha) * (s.Variance + diff*incr)
+	}
+	s.Samples++
+}
+
+// StdDev returns the standard deviation, but at least minStdDev
+func (s *Stat) StdDev(minStdDev float64) float64 {
+	return math.Max(math.Sqrt(s.Variance), minStdDev)
+}
+
+// Score returns the number of standard deviations that the value is away from the mean, or zero if there are not
+// enough samples yet
+func (s *Stat) Score(value, minStdDev float64) float64 {
+	if s.Samples < minSamples {
+		return 0
+	}
+	return math.Abs(value-s.Mean) / s.StdDev(minStdDev)
+}
+
+// Profile is the typical behavior of a device
+type Profile struct {
+	Interval    Stat `json:"interval"`
+	RSSI        Stat `json:"rssi"`
+	SNR         Stat `json:"snr"`
+	PayloadSize Stat `json:"payload_size"`
+}
+
+// Uplink contains the metrics of an uplink
+type Uplink struct {
+	Interval    time.Duration // zero if there is no previous uplink
+	RSSI        float32
+	SNR         float32
+	HasSignal   bool // RSSI and SNR are known
+	PayloadSize int
+}
+
+// Anomaly is a metric of an uplink that deviates from the profile of the device
+type Anomaly struct {
+	Metric string
+	Value  float64
+	Mean   float64
+	StdDev float64
+	Score  float64
+}
+
+// Update scores the uplink against the profile and returns the metrics with a score of at least threshold. The
+// uplink is then added to the profile, so that the profile follows lasting changes, for example when a device is
+// moved.
+func (p *Profile) Update(up Uplink, threshold float64) (anomalies []Anomaly) {
+	check := func(metric string, stat *Stat, value, minStdDev float64) {
+		if score := stat.Score(value, minStdDev); score >= threshold {
+			anomalies = append(anomalies, Anomaly{
+				Metric: metric,
+				Value:  value,
+				Mean:   stat.Mean,
+				StdDev: stat.StdDev(minStdDev),
+				Score:  score,
+			})
+		}
+		stat.Add(value)
+	}
+	if up.Interval > 0 {
+		interval := up.Interval.Seconds()
+		check(MetricInterval, &p.Interval, interval, math.Max(0.05*p.Interval.Mean, 1))
+	}
+	if up.HasSignal {
+		check(MetricRSSI, &p.RSSI, float64(up.RSSI), 2)
+		check(MetricSNR, &p.SNR, float64(up.SNR), 1)
+	}
+	check(MetricPayloadSize, &p.PayloadSize, float64(up.PayloadSize), 1)
+	return
+}
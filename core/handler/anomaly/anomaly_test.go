@@ -0,0 +1,55 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestStat(t *testing.T) {
+	a := New(t)
+
+	var s Stat
+	for i := 0; i < minSamples; i++ {
+		a.So(s.Score(100, 1), ShouldEqual, 0)
+		s.Add(float64(10 + i%2))
+	}
+	a.So(s.Mean, ShouldBeBetween, 10, 11)
+	a.So(s.StdDev(0), ShouldBeBetween, 0, 1)
+	a.So(s.StdDev(2), ShouldEqual, 2)
+	a.So(s.Score(10.5, 1), ShouldBeLessThan, 1)
+	a.So(s.Score(20, 1), ShouldBeGreaterThan, 5)
+}
+
+func TestProfile(t *testing.T) {
+	a := New(t)
+
+	var p Profile
+	up := Uplink{Interval: 10 * time.Minute, RSSI: -100, SNR: 5, HasSignal: true, PayloadSize: 12}
+	for i := 0; i < minSamples; i++ {
+		a.So(p.Update(up, 4), ShouldBeEmpty)
+	}
+
+	// Small deviations are normal
+	up.RSSI, up.Interval = -102, 10*time.Minute+20*time.Second
+	a.So(p.Update(up, 4), ShouldBeEmpty)
+
+	// The device was moved
+	up.RSSI, up.SNR = -120, -8
+	anomalies := p.Update(up, 4)
+	a.So(anomalies, ShouldHaveLength, 2)
+	a.So(anomalies[0].Metric, ShouldEqual, MetricRSSI)
+	a.So(anomalies[0].Value, ShouldEqual, -120)
+	a.So(anomalies[1].Metric, ShouldEqual, MetricSNR)
+
+	// Signal is unknown
+	up = Uplink{Interval: time.Minute, PayloadSize: 40}
+	anomalies = p.Update(up, 4)
+	a.So(anomalies, ShouldHaveLength, 2)
+	a.So(anomalies[0].Metric, ShouldEqual, MetricInterval)
+	a.So(anomalies[1].Metric, ShouldEqual, MetricPayloadSize)
+}
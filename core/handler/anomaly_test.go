@@ -0,0 +1,60 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestDetectAnomalies")},
+		qEvent:    make(chan *types.DeviceEvent, 10),
+	}
+
+	dev := &device.Device{AppID: "app", DevID: "dev"}
+	appUp := &types.UplinkMessage{AppID: "app", DevID: "dev", PayloadRaw: []byte{1, 2, 3, 4}}
+	appUp.Metadata.Gateways = []types.GatewayMetadata{{GtwID: "gtw", RSSI: -90, SNR: 7}}
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Disabled
+	err := h.DetectAnomalies(h.Ctx, &pb_broker.DeduplicatedUplinkMessage{ServerTime: start.UnixNano()}, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.Profile.PayloadSize.Samples, ShouldEqual, 0)
+
+	h.WithAnomalyDetection(4)
+	for i := 0; i < 20; i++ {
+		now := start.Add(time.Duration(i) * time.Hour)
+		err := h.DetectAnomalies(h.Ctx, &pb_broker.DeduplicatedUplinkMessage{ServerTime: now.UnixNano()}, appUp, dev)
+		a.So(err, ShouldBeNil)
+		dev.LastSeen = now
+	}
+	a.So(h.qEvent, ShouldHaveLength, 0)
+	a.So(dev.Profile.Interval.Mean, ShouldEqual, 3600)
+
+	// Retries are not scored
+	appUp.IsRetry = true
+	appUp.Metadata.Gateways[0].RSSI = -130
+	err = h.DetectAnomalies(h.Ctx, &pb_broker.DeduplicatedUplinkMessage{ServerTime: dev.LastSeen.Add(time.Second).UnixNano()}, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	appUp.IsRetry = false
+	err = h.DetectAnomalies(h.Ctx, &pb_broker.DeduplicatedUplinkMessage{ServerTime: dev.LastSeen.Add(time.Hour).UnixNano()}, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	event := <-h.qEvent
+	a.So(event.Event, ShouldEqual, types.AnomalyEvent)
+	a.So(event.Data.(types.AnomalyEventData).Metric, ShouldEqual, "rssi")
+	a.So(event.Data.(types.AnomalyEventData).Value, ShouldEqual, -130)
+}
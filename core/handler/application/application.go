@@ -7,6 +7,8 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
 	"github.com/fatih/structs"
 )
 
@@ -48,6 +50,20 @@ type Application struct {
 
 	RegisterOnJoinAccessKey string `redis:"register_on_join_access_key"`
 
+	// AlertRules are evaluated on the uplinks and events of the devices in the application
+	AlertRules []alert.Rule `redis:"alert_rules"`
+
+	// UplinkFilters drop uplinks of the devices in the application before they are published
+	UplinkFilters []filter.Rule `redis:"uplink_filters"`
+
+	// JoinWebhook is called before a join request of a device in the application is accepted
+	JoinWebhook string `redis:"join_webhook"`
+	// JoinAccept overrides the network-wide RX settings in the join accepts of the devices in the application
+	JoinAccept *JoinAcceptSettings `redis:"join_accept"`
+
+	// DownlinkQuota limits the number of downlinks that are enqueued per day for the application and its devices
+	DownlinkQuota *DownlinkQuota `redis:"downlink_quota"`
+
 	CreatedAt time.Time `redis:"created_at"`
 	UpdatedAt time.Time `redis:"updated_at"`
 }
@@ -6,6 +6,7 @@ package application
 import (
 	"testing"
 
+	"github.com/TheThingsNetwork/ttn/core/band"
 	. "github.com/smartystreets/assertions"
 )
 
@@ -29,3 +30,22 @@ func TestApplicationChangedFields(t *testing.T) {
 	a.So(application.ChangedFields(), ShouldHaveLength, 1)
 	a.So(application.ChangedFields(), ShouldContain, "AppID")
 }
+
+func TestJoinAcceptSettingsValidate(t *testing.T) {
+	a := New(t)
+
+	eu, _ := band.Get("EU_863_870")
+	u8 := func(v uint8) *uint8 { return &v }
+
+	var settings *JoinAcceptSettings
+	a.So(settings.IsEmpty(), ShouldBeTrue)
+	a.So(settings.Validate(&eu), ShouldBeNil)
+
+	settings = &JoinAcceptSettings{RX1DROffset: u8(2), RX2DataRate: u8(0), RXDelay: u8(5)}
+	a.So(settings.IsEmpty(), ShouldBeFalse)
+	a.So(settings.Validate(&eu), ShouldBeNil)
+
+	a.So((&JoinAcceptSettings{RX1DROffset: u8(6)}).Validate(&eu), ShouldNotBeNil)
+	a.So((&JoinAcceptSettings{RX2DataRate: u8(7)}).Validate(&eu), ShouldNotBeNil)
+	a.So((&JoinAcceptSettings{RXDelay: u8(16)}).Validate(&eu), ShouldNotBeNil)
+}
@@ -0,0 +1,15 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package application
+
+// DownlinkQuota limits the number of downlinks that can be enqueued per day. Zero means no limit.
+type DownlinkQuota struct {
+	PerDevice      uint `json:"per_device,omitempty"`
+	PerApplication uint `json:"per_application,omitempty"`
+}
+
+// IsEmpty returns true if the quota does not limit anything
+func (q *DownlinkQuota) IsEmpty() bool {
+	return q == nil || (q.PerDevice == 0 && q.PerApplication == 0)
+}
@@ -0,0 +1,52 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package application
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// JoinAcceptSettings contain the RX settings of join accepts. Settings that are nil are not overridden.
+type JoinAcceptSettings struct {
+	RX1DROffset *uint8 `json:"rx1_dr_offset,omitempty"`
+	RX2DataRate *uint8 `json:"rx2_data_rate,omitempty"`
+	RXDelay     *uint8 `json:"rx_delay,omitempty"`
+}
+
+// IsEmpty returns true if the settings do not override anything
+func (s *JoinAcceptSettings) IsEmpty() bool {
+	return s == nil || (s.RX1DROffset == nil && s.RX2DataRate == nil && s.RXDelay == nil)
+}
+
+// Validate the settings against the frequency plan. If the frequency plan is nil, only the ranges of the settings
+// are validated.
+func (s *JoinAcceptSettings) Validate(fp *band.FrequencyPlan) error {
+	if s == nil {
+		return nil
+	}
+	if s.RX1DROffset != nil && *s.RX1DROffset > 7 {
+		return errors.NewErrInvalidArgument("RX1DROffset", "must be at most 7")
+	}
+	if s.RX2DataRate != nil && *s.RX2DataRate > 15 {
+		return errors.NewErrInvalidArgument("RX2DataRate", "must be at most 15")
+	}
+	if s.RXDelay != nil && *s.RXDelay > 15 {
+		return errors.NewErrInvalidArgument("RXDelay", "must be at most 15 seconds")
+	}
+	if fp == nil {
+		return nil
+	}
+	if s.RX1DROffset != nil {
+		if err := fp.ValidateRX1DROffset(int(*s.RX1DROffset)); err != nil {
+			return err
+		}
+	}
+	if s.RX2DataRate != nil {
+		if err := fp.ValidateRX2DataRate(int(*s.RX2DataRate)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -46,6 +46,11 @@ type RedisApplicationStore struct {
 	store *storage.RedisMapStore
 }
 
+// SetSalvage makes the store quarantine corrupted applications instead of failing on them
+func (s *RedisApplicationStore) SetSalvage(salvage bool) {
+	s.store.SetSalvage(salvage)
+}
+
 // Count all applications in the store
 func (s *RedisApplicationStore) Count() (int, error) {
 	return s.store.Count("")
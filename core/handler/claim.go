@@ -0,0 +1,190 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/claim"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"golang.org/x/net/context"
+)
+
+// ClaimCodeKey is the key in the request metadata of SetDevice with the claim code of a factory-provisioned device.
+// With a claim code, SetDevice claims the device with the DevEUI for the application instead of registering it.
+const ClaimCodeKey = "claim-code"
+
+// claimCodeFromIncomingContext returns the claim code of the request metadata, or "" if there is none
+func claimCodeFromIncomingContext(ctx context.Context) string {
+	md := ttnctx.MetadataFromIncomingContext(ctx)
+	if values := md[ClaimCodeKey]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ClaimDevice transfers a factory-provisioned device to an application. The device is registered to the Broker
+// first, which can safely be repeated. Then the claim code is checked, the device is removed from the provisioning
+// store and stored for the application in one transaction, so that it can not be claimed twice. If the claim fails
+// after the device was registered, it is removed from the Broker again.
+func (h *handler) ClaimDevice(token, appID, devID string, devEUI types.DevEUI, claimCode string) (*device.Device, error) {
+	ctx := h.Ctx.WithFields(ttnlog.Fields{
+		"AppID":  appID,
+		"DevID":  devID,
+		"DevEUI": devEUI,
+	})
+
+	if _, err := h.applications.Get(appID); err != nil {
+		return nil, errors.Wrap(err, "Application not registered to this Handler")
+	}
+
+	if _, err := h.devices.Get(appID, devID); err == nil {
+		return nil, errors.NewErrAlreadyExists(fmt.Sprintf("Device %s", devID))
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	provisioned, err := h.provisioned.Get(devEUI)
+	if err != nil {
+		return nil, err
+	}
+	if !provisioned.CheckClaimCode(claimCode) {
+		return nil, errors.NewErrPermissionDenied("invalid claim code")
+	}
+
+	dev := &device.Device{
+		AppID:  appID,
+		DevID:  devID,
+		AppEUI: provisioned.AppEUI,
+		DevEUI: provisioned.DevEUI,
+		AppKey: provisioned.AppKey,
+		Options: device.Options{
+			ActivationConstraints: "local",
+			Uses32BitFCnt:         true,
+		},
+	}
+
+	if err := h.registerClaimedDevice(token, dev); err != nil {
+		return nil, err
+	}
+
+	err = h.devices.Create(dev, func(tx *storage.RedisTx) error {
+		claimed, err := h.provisioned.ClaimTx(tx, devEUI, claimCode)
+		if err != nil {
+			return err
+		}
+		if claimed.AppEUI != dev.AppEUI || claimed.AppKey != dev.AppKey {
+			return errors.NewErrFailedPrecondition("device was provisioned again during the claim")
+		}
+		return nil
+	}, h.provisioned.Key(devEUI))
+	if err != nil {
+		// A device that already exists was registered to the Broker by its own application
+		if errors.GetErrType(err) != errors.AlreadyExists {
+			if deleteErr := h.deleteClaimedDevice(token, dev); deleteErr != nil {
+				ctx.WithError(deleteErr).Warn("Could not remove unclaimed device from Broker")
+			}
+		}
+		return nil, err
+	}
+
+	h.qEvent <- &types.DeviceEvent{
+		AppID: dev.AppID,
+		DevID: dev.DevID,
+		Event: types.CreateEvent,
+		Data:  nil, // Don't send potentially sensitive details over MQTT
+	}
+
+	return dev, nil
+}
+
+func (h *handler) registerClaimedDevice(token string, dev *device.Device) error {
+	lorawanPb := dev.ToLoRaWANPb()
+	lorawanPb.AppKey = nil
+	lorawanPb.AppSKey = nil
+	reqCtx, cancel := h.Component.GetRequestContext(token)
+	defer cancel()
+	_, err := h.ttnDeviceManager.SetDevice(reqCtx, lorawanPb)
+	if err != nil {
+		return errors.Wrap(errors.FromGRPCError(err), "Broker did not set device")
+	}
+	return nil
+}
+
+func (h *handler) deleteClaimedDevice(token string, dev *device.Device) error {
+	reqCtx, cancel := h.Component.GetRequestContext(token)
+	defer cancel()
+	_, err := h.ttnDeviceManager.DeleteDevice(reqCtx, &pb_lorawan.DeviceIdentifier{
+		AppEUI: dev.AppEUI,
+		DevEUI: dev.DevEUI,
+	})
+	if err != nil {
+		return errors.Wrap(errors.FromGRPCError(err), "Broker did not delete device")
+	}
+	return nil
+}
+
+// ProvisionDevice adds a factory-provisioned device that can later be claimed with the given claim code
+func (h *handler) ProvisionDevice(dev *claim.Device, claimCode string) error {
+	if claimCode == "" {
+		return errors.NewErrInvalidArgument("Claim Code", "can not be empty")
+	}
+	dev.SetClaimCode(claimCode)
+	return h.provisioned.Provision(dev)
+}
+
+// ProvisionPath is the path on the health port where operators provision factory-provisioned devices
+const ProvisionPath = "/provisioned-devices"
+
+// provisionRequest is a factory-provisioned device with its claim code
+type provisionRequest struct {
+	DevEUI    types.DevEUI `json:"dev_eui"`
+	AppEUI    types.AppEUI `json:"app_eui"`
+	AppKey    types.AppKey `json:"app_key"`
+	ClaimCode string       `json:"claim_code"`
+}
+
+type provisioningHandler struct {
+	handler Handler
+}
+
+// NewProvisioningHandler returns a handler to which operators POST factory-provisioned devices as JSON with their
+// dev_eui, app_eui, app_key and claim_code, which are all required. It must be protected with the admin token.
+func NewProvisioningHandler(handler Handler) http.Handler {
+	return &provisioningHandler{handler: handler}
+}
+
+func (h *provisioningHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var in provisionRequest
+	if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dev := &claim.Device{
+		DevEUI: in.DevEUI,
+		AppEUI: in.AppEUI,
+		AppKey: in.AppKey,
+	}
+	if err := h.handler.ProvisionDevice(dev, in.ClaimCode); err != nil {
+		status := http.StatusInternalServerError
+		if errors.GetErrType(err) == errors.InvalidArgument {
+			status = http.StatusBadRequest
+		}
+		http.Error(res, err.Error(), status)
+		return
+	}
+	res.WriteHeader(http.StatusCreated)
+}
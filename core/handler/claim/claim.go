@@ -0,0 +1,63 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package claim contains the provisioning store for devices that are claimed by applications
+package claim
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Device is a factory-provisioned device that can be claimed by an application
+type Device struct {
+	DevEUI types.DevEUI `redis:"dev_eui"`
+	AppEUI types.AppEUI `redis:"app_eui"`
+	AppKey types.AppKey `redis:"app_key"`
+
+	// ClaimCodeHash is the hex-encoded SHA-256 hash of the claim code. The claim code itself is never stored.
+	ClaimCodeHash string `redis:"claim_code_hash"`
+
+	CreatedAt time.Time `redis:"created_at"`
+}
+
+// HashClaimCode returns the hex-encoded SHA-256 hash of a claim code
+func HashClaimCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// SetClaimCode sets the hash of the claim code
+func (d *Device) SetClaimCode(code string) {
+	d.ClaimCodeHash = HashClaimCode(code)
+}
+
+// CheckClaimCode returns true if the code matches the claim code of the device
+func (d *Device) CheckClaimCode(code string) bool {
+	if d.ClaimCodeHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(d.ClaimCodeHash), []byte(HashClaimCode(code))) == 1
+}
+
+// Validate the device
+func (d *Device) Validate() error {
+	if d.DevEUI.IsEmpty() {
+		return errors.NewErrInvalidArgument("DevEUI", "can not be empty")
+	}
+	if d.AppEUI.IsEmpty() {
+		return errors.NewErrInvalidArgument("AppEUI", "can not be empty")
+	}
+	if d.AppKey.IsEmpty() {
+		return errors.NewErrInvalidArgument("AppKey", "can not be empty")
+	}
+	if d.ClaimCodeHash == "" {
+		return errors.NewErrInvalidArgument("Claim Code", "can not be empty")
+	}
+	return nil
+}
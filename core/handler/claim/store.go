@@ -0,0 +1,131 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package claim
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"gopkg.in/redis.v5"
+)
+
+// Store interface for claimable Devices
+type Store interface {
+	Count() (int, error)
+	List(opts *storage.ListOptions) ([]*Device, error)
+	Get(devEUI types.DevEUI) (*Device, error)
+	Provision(dev *Device) error
+	Claim(devEUI types.DevEUI, code string) (*Device, error)
+	ClaimTx(tx *storage.RedisTx, devEUI types.DevEUI, code string) (*Device, error)
+	Key(devEUI types.DevEUI) string
+	Delete(devEUI types.DevEUI) error
+}
+
+const defaultRedisPrefix = "handler"
+const redisClaimPrefix = "claim"
+
+// NewRedisClaimStore creates a new Redis-based claim store
+// if an empty prefix is passed, a default prefix will be used.
+func NewRedisClaimStore(client *redis.Client, prefix string) Store {
+	if prefix == "" {
+		prefix = defaultRedisPrefix
+	}
+	store := storage.NewRedisMapStore(client, prefix+":"+redisClaimPrefix)
+	store.SetBase(Device{}, "")
+	return &RedisClaimStore{
+		client: client,
+		store:  store,
+	}
+}
+
+// RedisClaimStore stores claimable Devices in Redis.
+// - Devices are stored as a Hash, indexed by DevEUI
+type RedisClaimStore struct {
+	client *redis.Client
+	store  *storage.RedisMapStore
+}
+
+// Count all claimable devices in the store
+func (s *RedisClaimStore) Count() (int, error) {
+	return s.store.Count("")
+}
+
+// List all claimable Devices
+func (s *RedisClaimStore) List(opts *storage.ListOptions) ([]*Device, error) {
+	devicesI, err := s.store.List("", opts)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]*Device, len(devicesI))
+	for i, deviceI := range devicesI {
+		if device, ok := deviceI.(Device); ok {
+			devices[i] = &device
+		}
+	}
+	return devices, nil
+}
+
+// Get a specific claimable Device
+func (s *RedisClaimStore) Get(devEUI types.DevEUI) (*Device, error) {
+	deviceI, err := s.store.Get(devEUI.String())
+	if err != nil {
+		return nil, err
+	}
+	if device, ok := deviceI.(Device); ok {
+		return &device, nil
+	}
+	return nil, errors.New("Database did not return a Device")
+}
+
+// Provision a new claimable Device. This function returns an error if the device was already provisioned.
+func (s *RedisClaimStore) Provision(dev *Device) error {
+	if err := dev.Validate(); err != nil {
+		return err
+	}
+	dev.CreatedAt = time.Now()
+	return s.store.Create(dev.DevEUI.String(), *dev)
+}
+
+// Claim a Device using its claim code. The device is atomically removed from the store when the claim code
+// is correct, so that a device can only be claimed once.
+func (s *RedisClaimStore) Claim(devEUI types.DevEUI, code string) (claimed *Device, err error) {
+	err = s.store.Transaction(func(tx *storage.RedisTx) (err error) {
+		claimed, err = s.ClaimTx(tx, devEUI, code)
+		return err
+	}, s.Key(devEUI))
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// ClaimTx checks the claim code and removes the Device in a transaction that watches the Key of the Device, so that
+// the device can be claimed in the same transaction that stores it elsewhere.
+func (s *RedisClaimStore) ClaimTx(tx *storage.RedisTx, devEUI types.DevEUI, code string) (*Device, error) {
+	deviceI, err := s.store.GetTx(tx, devEUI.String())
+	if err != nil {
+		return nil, err
+	}
+	dev, ok := deviceI.(Device)
+	if !ok {
+		return nil, errors.New("Database did not return a Device")
+	}
+	if !dev.CheckClaimCode(code) {
+		return nil, errors.NewErrPermissionDenied("invalid claim code")
+	}
+	s.store.DeleteTx(tx, devEUI.String())
+	return &dev, nil
+}
+
+// Key returns the full key of a claimable Device, which transactions that claim the device must watch
+func (s *RedisClaimStore) Key(devEUI types.DevEUI) string {
+	return s.store.Key(devEUI.String())
+}
+
+// Delete a claimable Device
+func (s *RedisClaimStore) Delete(devEUI types.DevEUI) error {
+	return s.store.Delete(devEUI.String())
+}
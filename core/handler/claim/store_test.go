@@ -0,0 +1,73 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package claim
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestClaimStore(t *testing.T) {
+	a := New(t)
+
+	NewRedisClaimStore(GetRedisClient(), "")
+
+	s := NewRedisClaimStore(GetRedisClient(), "handler-test-claim-store")
+
+	devEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}
+
+	// Get non-existing
+	dev, err := s.Get(devEUI)
+	a.So(err, ShouldNotBeNil)
+	a.So(dev, ShouldBeNil)
+
+	// Provision without claim code
+	err = s.Provision(&Device{DevEUI: devEUI})
+	a.So(err, ShouldNotBeNil)
+
+	// Provision
+	dev = &Device{
+		DevEUI: devEUI,
+		AppEUI: types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8},
+		AppKey: types.AppKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	dev.SetClaimCode("secret")
+	err = s.Provision(dev)
+	defer func() {
+		s.Delete(devEUI)
+	}()
+	a.So(err, ShouldBeNil)
+
+	// Provision again
+	err = s.Provision(dev)
+	a.So(errors.IsAlreadyExists(err), ShouldBeTrue)
+
+	// Get existing
+	dev, err = s.Get(devEUI)
+	a.So(err, ShouldBeNil)
+	a.So(dev.CheckClaimCode("secret"), ShouldBeTrue)
+	a.So(dev.CheckClaimCode("wrong"), ShouldBeFalse)
+
+	// List
+	devs, err := s.List(nil)
+	a.So(err, ShouldBeNil)
+	a.So(devs, ShouldHaveLength, 1)
+
+	// Claim with wrong code
+	_, err = s.Claim(devEUI, "wrong")
+	a.So(errors.IsPermissionDenied(err), ShouldBeTrue)
+
+	// Claim
+	claimed, err := s.Claim(devEUI, "secret")
+	a.So(err, ShouldBeNil)
+	a.So(claimed.AppKey, ShouldEqual, dev.AppKey)
+
+	// Claim again
+	_, err = s.Claim(devEUI, "secret")
+	a.So(errors.IsNotFound(err), ShouldBeTrue)
+}
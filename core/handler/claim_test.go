@@ -0,0 +1,229 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/handler"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/go-account-lib/claims"
+	"github.com/TheThingsNetwork/go-account-lib/rights"
+	"github.com/TheThingsNetwork/go-account-lib/tokenkey"
+	"github.com/TheThingsNetwork/ttn/api/ratelimit"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/claim"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/security"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	jwt "github.com/dgrijalva/jwt-go"
+	gogo "github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+// testAppToken returns a token key provider and a token that it validates, with the rights to the application
+func testAppToken(t *testing.T, appID string, appRights ...types.Right) (tokenkey.Provider, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM, err := security.PublicPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := tokenkey.FuncProvider(map[string]tokenkey.TokenFunc{
+		"test": func(renew bool) (*tokenkey.TokenKey, error) {
+			return &tokenkey.TokenKey{Algorithm: "ES256", Key: string(pubPEM)}, nil
+		},
+	})
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims.Claims{
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    "test",
+			Subject:   "tester",
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+		Apps: map[string][]types.Right{appID: appRights},
+	}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider, token
+}
+
+func TestClaimDevice(t *testing.T) {
+	a := New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ttnDeviceManager := pb_lorawan.NewMockDeviceManagerClient(ctrl)
+
+	h := &handler{
+		Component:        &component.Component{Ctx: GetLogger(t, "TestClaimDevice")},
+		applications:     application.NewRedisApplicationStore(GetRedisClient(), "handler-test-claim-device"),
+		devices:          device.NewRedisDeviceStore(GetRedisClient(), "handler-test-claim-device"),
+		provisioned:      claim.NewRedisClaimStore(GetRedisClient(), "handler-test-claim-device"),
+		ttnDeviceManager: ttnDeviceManager,
+		qEvent:           make(chan *types.DeviceEvent, 10),
+	}
+
+	appID, devID := "claim-app", "claim-dev"
+	devEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}
+	appEUI := types.AppEUI{8, 7, 6, 5, 4, 3, 2, 1}
+	appKey := types.AppKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	// Application not registered
+	_, err := h.ClaimDevice("token", appID, devID, devEUI, "secret")
+	a.So(err, ShouldNotBeNil)
+
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	// Device not provisioned
+	_, err = h.ClaimDevice("token", appID, devID, devEUI, "secret")
+	a.So(err, ShouldNotBeNil)
+
+	a.So(h.ProvisionDevice(&claim.Device{DevEUI: devEUI, AppEUI: appEUI}, "secret"), ShouldNotBeNil)
+	a.So(h.ProvisionDevice(&claim.Device{DevEUI: devEUI, AppKey: appKey}, "secret"), ShouldNotBeNil)
+	a.So(h.ProvisionDevice(&claim.Device{DevEUI: devEUI, AppEUI: appEUI, AppKey: appKey}, ""), ShouldNotBeNil)
+	a.So(h.ProvisionDevice(&claim.Device{DevEUI: devEUI, AppEUI: appEUI, AppKey: appKey}, "secret"), ShouldBeNil)
+	defer h.provisioned.Delete(devEUI)
+
+	// Wrong claim code
+	_, err = h.ClaimDevice("token", appID, devID, devEUI, "wrong")
+	a.So(err, ShouldNotBeNil)
+
+	// Broker fails: device stays in the provisioning store
+	ttnDeviceManager.EXPECT().SetDevice(gomock.Any(), gomock.Any()).Return(nil, errors.New("broker failed"))
+	_, err = h.ClaimDevice("token", appID, devID, devEUI, "secret")
+	a.So(err, ShouldNotBeNil)
+	_, err = h.provisioned.Get(devEUI)
+	a.So(err, ShouldBeNil)
+
+	// Claim
+	ttnDeviceManager.EXPECT().SetDevice(gomock.Any(), gomock.Any()).Return(new(gogo.Empty), nil)
+	dev, err := h.ClaimDevice("token", appID, devID, devEUI, "secret")
+	a.So(err, ShouldBeNil)
+	defer h.devices.Delete(appID, devID)
+	a.So(dev.AppKey, ShouldEqual, appKey)
+
+	stored, err := h.devices.Get(appID, devID)
+	a.So(err, ShouldBeNil)
+	a.So(stored.AppEUI, ShouldEqual, appEUI)
+	a.So(stored.DevEUI, ShouldEqual, devEUI)
+	a.So(stored.AppKey, ShouldEqual, appKey)
+
+	_, err = h.provisioned.Get(devEUI)
+	a.So(err, ShouldNotBeNil)
+
+	// Second claim fails
+	_, err = h.ClaimDevice("token", appID, "other-dev", devEUI, "secret")
+	a.So(err, ShouldNotBeNil)
+
+	// The claim fails after the Broker registration: the device is removed from the Broker
+	a.So(h.ProvisionDevice(&claim.Device{DevEUI: devEUI, AppEUI: appEUI, AppKey: appKey}, "secret"), ShouldBeNil)
+	ttnDeviceManager.EXPECT().SetDevice(gomock.Any(), gomock.Any()).Do(func(interface{}, interface{}) {
+		h.provisioned.Delete(devEUI)
+	}).Return(new(gogo.Empty), nil)
+	ttnDeviceManager.EXPECT().DeleteDevice(gomock.Any(), &pb_lorawan.DeviceIdentifier{AppEUI: appEUI, DevEUI: devEUI}).Return(new(gogo.Empty), nil)
+	_, err = h.ClaimDevice("token", appID, "other-dev", devEUI, "secret")
+	a.So(err, ShouldNotBeNil)
+	_, err = h.devices.Get(appID, "other-dev")
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestClaimDeviceWithSetDevice(t *testing.T) {
+	a := New(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ttnDeviceManager := pb_lorawan.NewMockDeviceManagerClient(ctrl)
+
+	appID, devID := "claim-set-app", "claim-set-dev"
+	devEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 9}
+	appKey := types.AppKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 9}
+
+	provider, token := testAppToken(t, appID, rights.Devices)
+	h := &handler{
+		Component:        &component.Component{Ctx: GetLogger(t, "TestClaimDeviceWithSetDevice"), TokenKeyProvider: provider},
+		applications:     application.NewRedisApplicationStore(GetRedisClient(), "handler-test-claim-set-device"),
+		devices:          device.NewRedisDeviceStore(GetRedisClient(), "handler-test-claim-set-device"),
+		provisioned:      claim.NewRedisClaimStore(GetRedisClient(), "handler-test-claim-set-device"),
+		ttnDeviceManager: ttnDeviceManager,
+		qEvent:           make(chan *types.DeviceEvent, 10),
+	}
+	m := &handlerManager{
+		handler:         h,
+		applicationRate: ratelimit.NewRegistry(5000, time.Hour),
+		clientRate:      ratelimit.NewRegistry(5000, time.Hour),
+	}
+
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	// Operators provision the device on the health port with the admin token
+	provisioning := component.RequireAdmin(NewProvisioningHandler(h), "POST")
+	body := `{"dev_eui":"0102030405060709","app_eui":"0807060504030201","app_key":"01020304050607080102030405060709","claim_code":"secret"}`
+	rec := httptest.NewRecorder()
+	provisioning.ServeHTTP(rec, httptest.NewRequest("POST", ProvisionPath, bytes.NewBufferString(body)))
+	a.So(rec.Code, ShouldEqual, http.StatusForbidden)
+	_, err := h.provisioned.Get(devEUI)
+	a.So(err, ShouldNotBeNil)
+
+	// Without AppEUI
+	rec = httptest.NewRecorder()
+	noAppEUI := `{"dev_eui":"0102030405060709","app_key":"01020304050607080102030405060709","claim_code":"secret"}`
+	NewProvisioningHandler(h).ServeHTTP(rec, httptest.NewRequest("POST", ProvisionPath, bytes.NewBufferString(noAppEUI)))
+	a.So(rec.Code, ShouldEqual, http.StatusBadRequest)
+
+	rec = httptest.NewRecorder()
+	NewProvisioningHandler(h).ServeHTTP(rec, httptest.NewRequest("POST", ProvisionPath, bytes.NewBufferString(body)))
+	a.So(rec.Code, ShouldEqual, http.StatusCreated)
+	defer h.provisioned.Delete(devEUI)
+
+	in := &pb.Device{
+		AppID: appID,
+		DevID: devID,
+		Device: &pb.Device_LoRaWANDevice{LoRaWANDevice: &pb_lorawan.Device{
+			AppID:  appID,
+			DevID:  devID,
+			DevEUI: devEUI,
+		}},
+	}
+
+	// Without rights to the application
+	_, otherToken := testAppToken(t, "other-app", rights.Devices)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("token", otherToken, ClaimCodeKey, "secret"))
+	_, err = m.SetDevice(ctx, in)
+	a.So(err, ShouldNotBeNil)
+
+	// Wrong claim code
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("token", token, ClaimCodeKey, "wrong"))
+	_, err = m.SetDevice(ctx, in)
+	a.So(err, ShouldNotBeNil)
+
+	// Claim
+	ttnDeviceManager.EXPECT().SetDevice(gomock.Any(), gomock.Any()).Return(new(gogo.Empty), nil)
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("token", token, ClaimCodeKey, "secret"))
+	_, err = m.SetDevice(ctx, in)
+	a.So(err, ShouldBeNil)
+	defer h.devices.Delete(appID, devID)
+
+	stored, err := h.devices.Get(appID, devID)
+	a.So(err, ShouldBeNil)
+	a.So(stored.AppEUI, ShouldEqual, types.AppEUI{8, 7, 6, 5, 4, 3, 2, 1})
+	a.So(stored.AppKey, ShouldEqual, appKey)
+}
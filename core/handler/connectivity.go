@@ -0,0 +1,82 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// UpdateConnectivity updates the connectivity status of the device with the metadata of the uplink
+func (h *handler) UpdateConnectivity(ctx ttnlog.Interface, ttnUp *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) error {
+	now := time.Now()
+	if ttnUp.ServerTime != 0 {
+		now = time.Unix(0, ttnUp.ServerTime)
+	}
+	if !appUp.IsRetry {
+		dev.LearnUplinkPeriod(now)
+	}
+	dev.LastSeen = now
+	dev.Offline = false
+
+	if h.silenceFactor > 0 {
+		if err := h.scheduleSilenceCheck(dev, now); err != nil {
+			ctx.WithError(err).Warn("Could not schedule silence check")
+		}
+	}
+
+	// Take the gateway with the best SNR, then the best RSSI
+	for i, gtw := range appUp.Metadata.Gateways {
+		if i == 0 || gtw.SNR > dev.LastSNR || (gtw.SNR == dev.LastSNR && gtw.RSSI > dev.LastRSSI) {
+			dev.LastGatewayID = gtw.GtwID
+			dev.LastRSSI = gtw.RSSI
+			dev.LastSNR = gtw.SNR
+		}
+	}
+
+	return nil
+}
+
+// checkConnectivity emits an offline event for devices that were not seen within the heartbeat interval
+func (h *handler) checkConnectivity() error {
+	devices, err := h.devices.List(nil)
+	if err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		if dev == nil || dev.Offline || dev.LastSeen.IsZero() || time.Since(dev.LastSeen) < h.heartbeatInterval {
+			continue
+		}
+		dev.StartUpdate()
+		dev.Offline = true
+		if err := h.devices.Set(dev); err != nil {
+			h.Ctx.WithError(err).WithField("AppID", dev.AppID).WithField("DevID", dev.DevID).Warn("Could not mark device offline")
+			continue
+		}
+		h.qEvent <- &types.DeviceEvent{
+			AppID: dev.AppID,
+			DevID: dev.DevID,
+			Event: types.OfflineEvent,
+			Data: types.OfflineEventData{
+				LastSeen:          types.JSONTime(dev.LastSeen),
+				LastGatewayID:     dev.LastGatewayID,
+				HeartbeatInterval: h.heartbeatInterval.String(),
+			},
+		}
+		h.evaluateOfflineAlerts(dev)
+	}
+	return nil
+}
+
+func (h *handler) monitorConnectivity() {
+	for range time.Tick(h.heartbeatInterval / 2) {
+		if err := h.checkConnectivity(); err != nil {
+			h.Ctx.WithError(err).Warn("Could not check device connectivity")
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestUpdateConnectivity(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestUpdateConnectivity")},
+	}
+
+	ttnUp := &pb_broker.DeduplicatedUplinkMessage{ServerTime: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()}
+	appUp := &types.UplinkMessage{}
+	appUp.Metadata.Gateways = []types.GatewayMetadata{
+		{GtwID: "gtw-1", RSSI: -100, SNR: 2},
+		{GtwID: "gtw-2", RSSI: -80, SNR: 7.5},
+		{GtwID: "gtw-3", RSSI: -90, SNR: 7.5},
+	}
+	dev := &device.Device{Offline: true}
+
+	err := h.UpdateConnectivity(h.Ctx, ttnUp, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.LastSeen.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+	a.So(dev.LastGatewayID, ShouldEqual, "gtw-2")
+	a.So(dev.LastRSSI, ShouldEqual, -80)
+	a.So(dev.LastSNR, ShouldEqual, 7.5)
+	a.So(dev.Offline, ShouldBeFalse)
+}
+
+func TestCheckConnectivity(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component:         &component.Component{Ctx: GetLogger(t, "TestCheckConnectivity")},
+		devices:           device.NewRedisDeviceStore(GetRedisClient(), "handler-test-check-connectivity"),
+		applications:      application.NewRedisApplicationStore(GetRedisClient(), "handler-test-check-connectivity"),
+		qEvent:            make(chan *types.DeviceEvent, 10),
+		heartbeatInterval: time.Hour,
+	}
+
+	appID := "app"
+	h.devices.Set(&device.Device{AppID: appID, DevID: "never-seen"})
+	defer h.devices.Delete(appID, "never-seen")
+	h.devices.Set(&device.Device{AppID: appID, DevID: "online", LastSeen: time.Now()})
+	defer h.devices.Delete(appID, "online")
+	h.devices.Set(&device.Device{AppID: appID, DevID: "offline", LastSeen: time.Now().Add(-2 * time.Hour), LastGatewayID: "gtw"})
+	defer h.devices.Delete(appID, "offline")
+
+	err := h.checkConnectivity()
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	event := <-h.qEvent
+	a.So(event.DevID, ShouldEqual, "offline")
+	a.So(event.Event, ShouldEqual, types.OfflineEvent)
+	a.So(event.Data.(types.OfflineEventData).LastGatewayID, ShouldEqual, "gtw")
+
+	dev, err := h.devices.Get(appID, "offline")
+	a.So(err, ShouldBeNil)
+	a.So(dev.Offline, ShouldBeTrue)
+
+	// The event is only emitted once
+	err = h.checkConnectivity()
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+}
@@ -0,0 +1,38 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package console serves a web console for the HTTP API of the Handler
+package console
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Path is the path where the console is served by the HTTP API of the Handler
+const Path = "/console/"
+
+// Handler returns the http.Handler for the console
+func Handler() http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "HEAD" {
+			http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if strings.TrimPrefix(req.URL.Path, Path) != "" {
+			http.NotFound(res, req)
+			return
+		}
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+		res.Header().Set("X-Frame-Options", "DENY")
+		res.Write([]byte(index))
+	})
+}
+
+// WithConsole wraps the handler of the HTTP API so that the console is served on Path
+func WithConsole(api http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	mux.Handle("/", api)
+	return mux
+}
@@ -0,0 +1,39 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package console
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestConsole(t *testing.T) {
+	a := New(t)
+
+	api := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusTeapot)
+	})
+	h := WithConsole(api)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/console/", nil))
+	a.So(rec.Code, ShouldEqual, http.StatusOK)
+	a.So(rec.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+	a.So(rec.Body.String(), ShouldContainSubstring, "Handler Console")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/console/other", nil))
+	a.So(rec.Code, ShouldEqual, http.StatusNotFound)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/console/", nil))
+	a.So(rec.Code, ShouldEqual, http.StatusMethodNotAllowed)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/applications/test", nil))
+	a.So(rec.Code, ShouldEqual, http.StatusTeapot)
+}
@@ -0,0 +1,160 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package console
+
+// index is the console page. It uses the same HTTP API as other clients, with
+// the Application ID and access key that are entered by the user.
+const index = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>The Things Network Handler Console</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.3em 0.8em; text-align: left; font-size: 0.9em; }
+th { background: #f4f4f4; }
+input { margin: 0.2em 0.5em 0.2em 0; padding: 0.2em; }
+code { font-size: 0.9em; }
+.error { color: #c00; }
+fieldset { margin: 1em 0; border: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>Handler Console</h1>
+
+<form id="login">
+<input id="app-id" placeholder="Application ID" required>
+<input id="access-key" placeholder="Access Key" type="password" required>
+<button type="submit">Open</button>
+</form>
+<p class="error" id="error"></p>
+
+<div id="application" hidden>
+<h2>Application <code id="title"></code></h2>
+<p>Payload format: <code id="payload-format"></code></p>
+
+<h3>Devices <button id="refresh">Refresh</button></h3>
+<table>
+<thead><tr><th>Device ID</th><th>DevEUI</th><th>DevAddr</th><th>FCnt Up</th><th>FCnt Down</th><th>Last Seen</th><th>Location</th><th></th></tr></thead>
+<tbody id="devices"></tbody>
+</table>
+
+<form id="register">
+<fieldset>
+<legend>Register device</legend>
+<input id="dev-id" placeholder="Device ID" required>
+<input id="dev-eui" placeholder="DevEUI" pattern="[0-9A-Fa-f]{16}" required>
+<input id="app-eui" placeholder="AppEUI" pattern="[0-9A-Fa-f]{16}" required>
+<input id="app-key" placeholder="AppKey" pattern="[0-9A-Fa-f]{32}" required>
+<button type="submit">Register</button>
+</fieldset>
+</form>
+</div>
+
+<script>
+(function () {
+  var appID, accessKey;
+
+  function $(id) { return document.getElementById(id); }
+
+  function showError(err) { $("error").textContent = err ? err.toString() : ""; }
+
+  function request(method, path, body) {
+    return fetch("/applications/" + encodeURIComponent(appID) + path, {
+      method: method,
+      headers: { "Authorization": "Key " + accessKey, "Content-Type": "application/json" },
+      body: body ? JSON.stringify(body) : undefined
+    }).then(function (res) {
+      return res.json().then(function (json) {
+        if (!res.ok) { throw new Error(json.error || res.statusText); }
+        return json;
+      });
+    });
+  }
+
+  function cell(row, text) {
+    var td = document.createElement("td");
+    td.textContent = text === undefined || text === null ? "" : text;
+    row.appendChild(td);
+    return td;
+  }
+
+  function lastSeen(ns) {
+    if (!ns || ns === "0") { return "never"; }
+    return new Date(Number(ns) / 1e6).toLocaleString();
+  }
+
+  function loadDevices() {
+    return request("GET", "/devices").then(function (res) {
+      var tbody = $("devices");
+      tbody.innerHTML = "";
+      (res.devices || []).forEach(function (dev) {
+        var lorawan = dev.lorawan_device || {};
+        var row = document.createElement("tr");
+        cell(row, dev.dev_id);
+        cell(row, lorawan.dev_eui);
+        cell(row, lorawan.dev_addr);
+        cell(row, lorawan.f_cnt_up);
+        cell(row, lorawan.f_cnt_down);
+        cell(row, lastSeen(lorawan.last_seen));
+        var location = cell(row, "");
+        if (dev.latitude || dev.longitude) {
+          var link = document.createElement("a");
+          link.href = "https://www.openstreetmap.org/?mlat=" + dev.latitude + "&mlon=" + dev.longitude;
+          link.textContent = dev.latitude.toFixed(5) + ", " + dev.longitude.toFixed(5);
+          link.target = "_blank";
+          location.appendChild(link);
+        }
+        var actions = cell(row, "");
+        var del = document.createElement("button");
+        del.textContent = "Delete";
+        del.onclick = function () {
+          if (!confirm("Delete device " + dev.dev_id + "?")) { return; }
+          request("DELETE", "/devices/" + encodeURIComponent(dev.dev_id)).then(loadDevices).catch(showError);
+        };
+        actions.appendChild(del);
+        tbody.appendChild(row);
+      });
+    });
+  }
+
+  $("login").onsubmit = function (e) {
+    e.preventDefault();
+    appID = $("app-id").value;
+    accessKey = $("access-key").value;
+    showError();
+    request("GET", "").then(function (app) {
+      $("title").textContent = app.app_id;
+      $("payload-format").textContent = app.payload_format || "custom";
+      $("application").hidden = false;
+      return loadDevices();
+    }).catch(showError);
+  };
+
+  $("refresh").onclick = function () { loadDevices().catch(showError); };
+
+  $("register").onsubmit = function (e) {
+    e.preventDefault();
+    var devID = $("dev-id").value;
+    request("POST", "/devices/" + encodeURIComponent(devID), {
+      app_id: appID,
+      dev_id: devID,
+      lorawan_device: {
+        app_id: appID,
+        dev_id: devID,
+        dev_eui: $("dev-eui").value.toUpperCase(),
+        app_eui: $("app-eui").value.toUpperCase(),
+        app_key: $("app-key").value.toUpperCase()
+      }
+    }).then(function () {
+      $("register").reset();
+      return loadDevices();
+    }).catch(showError);
+  };
+})();
+</script>
+</body>
+</html>
+`
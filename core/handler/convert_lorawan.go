@@ -27,10 +27,13 @@ func (h *handler) ConvertFromLoRaWAN(ctx ttnlog.Interface, ttnUp *pb_broker.Dedu
 		return errors.NewErrInvalidArgument("Uplink", "does not contain a MAC payload")
 	}
 
-	ttnUp.Trace = ttnUp.Trace.WithEvent(trace.CheckMICEvent)
-	err = phyPayload.ValidateMIC(dev.NwkSKey)
-	if err != nil {
-		return err
+	// The Broker already validated the MIC of devices of which the NwkSKey is in a secure element
+	if !dev.NwkSKey.IsEmpty() || !h.secureElementApps[dev.AppID] {
+		ttnUp.Trace = ttnUp.Trace.WithEvent(trace.CheckMICEvent)
+		err = phyPayload.ValidateMIC(dev.NwkSKey)
+		if err != nil {
+			return err
+		}
 	}
 
 	appUp.HardwareSerial = dev.DevEUI.String()
@@ -38,6 +41,8 @@ func (h *handler) ConvertFromLoRaWAN(ctx ttnlog.Interface, ttnUp *pb_broker.Dedu
 	appUp.FCnt = macPayload.FCnt
 	if dev.FCntUp == appUp.FCnt {
 		appUp.IsRetry = true
+	} else if dev.FCntUp > 0 && appUp.FCnt > dev.FCntUp {
+		dev.MissedUplinks = appUp.FCnt - dev.FCntUp - 1
 	}
 	dev.FCntUp = appUp.FCnt
 
@@ -67,6 +72,10 @@ func (h *handler) ConvertFromLoRaWAN(ctx ttnlog.Interface, ttnUp *pb_broker.Dedu
 						Message: dev.CurrentDownlink,
 					},
 				}
+				h.publishDownlinkStatus(appUp.AppID, appUp.DevID, types.DownlinkStatusEventData{
+					ID:     dev.CurrentDownlink.ID,
+					Status: types.DownlinkStatusAcked,
+				})
 				dev.CurrentDownlink = nil
 			}
 		} else {
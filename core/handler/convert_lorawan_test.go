@@ -89,6 +89,17 @@ func TestConvertFromLoRaWAN(t *testing.T) {
 	a.So(appUp.Confirmed, ShouldBeTrue)
 
 	wg.Wait()
+
+	// The MIC of devices without NwkSKey is only left to the Broker in secure element applications
+	ttnUp.UnmarshalPayload()
+	ttnUp.Message.GetLoRaWAN().SetMIC(types.NwkSKey{1})
+	ttnUp.Payload = ttnUp.Message.GetLoRaWAN().PHYPayloadBytes()
+	err = h.ConvertFromLoRaWAN(h.Ctx, ttnUp, appUp, device)
+	a.So(err, ShouldNotBeNil)
+
+	h.WithSecureElementApps("appid")
+	err = h.ConvertFromLoRaWAN(h.Ctx, ttnUp, appUp, device)
+	a.So(err, ShouldBeNil)
 }
 
 func buildLoRaWANDownlink(payload []byte) (*types.DownlinkMessage, *pb_broker.DownlinkMessage) {
@@ -0,0 +1,66 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+)
+
+// Check the consistency of the devices and their downlink queues. With repair, the queues of devices that do not
+// exist are deleted; devices without keys can only be repaired by their owner.
+func (s *RedisDeviceStore) Check(repair bool) (inconsistencies []storage.Inconsistency, err error) {
+	keys, err := s.store.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		exists[strings.TrimPrefix(key, s.prefix+":"+redisDevicePrefix+":")] = true
+	}
+
+	devices, err := s.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, dev := range devices {
+		if dev == nil {
+			continue
+		}
+		if dev.AppKey.IsEmpty() && (dev.DevAddr.IsEmpty() || dev.NwkSKey.IsEmpty() || dev.AppSKey.IsEmpty()) {
+			inconsistencies = append(inconsistencies, storage.Inconsistency{
+				Key:     fmt.Sprintf("%s:%s", dev.AppID, dev.DevID),
+				Problem: "Device has no AppKey and no session keys",
+			})
+		}
+	}
+
+	// Dangling downlink queues: queues of devices that do not exist
+	queues, err := s.queues.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	for _, queue := range queues {
+		key := strings.TrimPrefix(queue, s.prefix+":"+redisDownlinkQueuePrefix+":")
+		if exists[key] {
+			continue
+		}
+		inconsistency := storage.Inconsistency{
+			Key:     key,
+			Problem: "Downlink queue exists, but the device does not exist",
+		}
+		if repair {
+			if err := s.queues.Delete(key); err != nil {
+				return inconsistencies, err
+			}
+			inconsistency.Repaired = true
+		}
+		inconsistencies = append(inconsistencies, inconsistency)
+	}
+
+	storage.SortInconsistencies(inconsistencies)
+	return inconsistencies, nil
+}
@@ -0,0 +1,46 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeviceStoreCheck(t *testing.T) {
+	a := New(t)
+
+	s := NewRedisDeviceStore(GetRedisClient(), "handler-test-device-check")
+
+	for _, dev := range []*Device{
+		{AppID: "AppID-1", DevID: "DevID-1", AppKey: types.AppKey{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{AppID: "AppID-1", DevID: "DevID-2"},
+	} {
+		a.So(s.Set(dev), ShouldBeNil)
+		defer s.Delete(dev.AppID, dev.DevID)
+	}
+	s.queues.AddEnd("AppID-1:DevID-3", "{}")
+	defer s.queues.Delete("AppID-1:DevID-3")
+
+	inconsistencies, err := s.Check(false)
+	a.So(err, ShouldBeNil)
+	a.So(inconsistencies, ShouldHaveLength, 2)
+	a.So(inconsistencies[0].Key, ShouldEqual, "AppID-1:DevID-2")
+	a.So(inconsistencies[0].Problem, ShouldContainSubstring, "no AppKey")
+	a.So(inconsistencies[1].Key, ShouldEqual, "AppID-1:DevID-3")
+	a.So(inconsistencies[1].Problem, ShouldContainSubstring, "Downlink queue")
+	a.So(inconsistencies[1].Repaired, ShouldBeFalse)
+
+	inconsistencies, err = s.Check(true)
+	a.So(err, ShouldBeNil)
+	a.So(inconsistencies, ShouldHaveLength, 2)
+	a.So(inconsistencies[1].Repaired, ShouldBeTrue)
+
+	length, err := s.queues.Length("AppID-1:DevID-3")
+	a.So(err, ShouldBeNil)
+	a.So(length, ShouldEqual, 0)
+}
@@ -5,8 +5,13 @@ package device
 
 import (
 	"reflect"
+	"strconv"
 	"time"
 
+	"github.com/TheThingsNetwork/ttn/api"
+	"github.com/TheThingsNetwork/ttn/core/handler/anomaly"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/fatih/structs"
 )
@@ -23,6 +28,31 @@ type Options struct {
 	Uses32BitFCnt         bool   `json:"uses_32_bit_fcnt,omitemtpy"`       // Use 32-bit Frame counters
 }
 
+// JoinAccept is the join accept that was sent in response to a join request
+type JoinAccept struct {
+	DevNonce    DevNonce  `json:"dev_nonce"`
+	Payload     []byte    `json:"payload"`
+	Rx1DROffset uint32    `json:"rx1_dr_offset,omitempty"`
+	Rx2DR       uint32    `json:"rx2_dr,omitempty"`
+	RxDelay     uint32    `json:"rx_delay,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// UplinkPeriodAttribute is the device attribute that configures the expected time between the uplinks of the
+// device, for example 15m. It overrides the period that is learned from the uplinks.
+const UplinkPeriodAttribute = "ttn-uplink-period"
+
+// The device attributes that configure the network settings of the device. The frequency plan and the ADR margin
+// are passed to the Network Server when the device is set. The class of the device is informational.
+const (
+	FrequencyPlanAttribute = "ttn-frequency-plan" // for example EU_863_870
+	ClassAttribute         = "ttn-class"          // A, B or C
+	ADRMarginAttribute     = "ttn-adr-margin"     // the SNR margin for ADR in dB
+)
+
+// minUplinkPeriodSamples is the number of intervals that is needed before the learned uplink period is used
+const minUplinkPeriodSamples = 3
+
 // Device contains the state of a device
 type Device struct {
 	old *Device
@@ -51,12 +81,76 @@ type Device struct {
 
 	CurrentDownlink *types.DownlinkMessage `redis:"current_downlink"`
 
+	// MulticastGroups were set up on the device with the Remote Multicast Setup protocol
+	MulticastGroups []multicast.Group `redis:"multicast_groups"`
+
+	// LastJoinAccept is sent again if the device retransmits its join request
+	LastJoinAccept *JoinAccept `redis:"last_join_accept"`
+
+	LastSeen      time.Time `redis:"last_seen"`
+	LastGatewayID string    `redis:"last_gateway_id"`
+	LastRSSI      float32   `redis:"last_rssi"`
+	LastSNR       float32   `redis:"last_snr"`
+	MissedUplinks uint32    `redis:"missed_uplinks"` // Number of uplinks missed before the last uplink
+	Offline       bool      `redis:"offline"`
+
+	// UplinkPeriod is the average time between the uplinks of the device, learned from UplinkPeriodSamples intervals
+	UplinkPeriod        time.Duration `redis:"uplink_period"`
+	UplinkPeriodSamples uint32        `redis:"uplink_period_samples"`
+	LastSilentEvent     time.Time     `redis:"last_silent_event"`
+
+	// Profile is the typical behavior of the device, for anomaly detection
+	Profile anomaly.Profile `redis:"profile"`
+
+	// UplinkFilter is the state of the uplink filter rules of the application for the device
+	UplinkFilter filter.State `redis:"uplink_filter"`
+
 	CreatedAt time.Time `redis:"created_at"`
 	UpdatedAt time.Time `redis:"updated_at"`
 
 	Attributes map[string]string `redis:"attributes"`
 }
 
+// LearnUplinkPeriod updates the learned uplink period with the interval between the last uplink and this uplink
+func (d *Device) LearnUplinkPeriod(now time.Time) {
+	if d.LastSeen.IsZero() || !now.After(d.LastSeen) {
+		return
+	}
+	interval := now.Sub(d.LastSeen)
+	if d.UplinkPeriodSamples == 0 {
+		d.UplinkPeriod = interval
+	} else {
+		// Exponentially weighted moving average, so that the period follows changes of the device
+		d.UplinkPeriod = (3*d.UplinkPeriod + interval) / 4
+	}
+	d.UplinkPeriodSamples++
+}
+
+// ExpectedUplinkPeriod returns the configured uplink period of the device, or the learned period if it is not
+// configured. It returns zero if the period is not configured and was not learned yet.
+func (d *Device) ExpectedUplinkPeriod() (period time.Duration, learned bool) {
+	if configured, err := time.ParseDuration(d.Attributes[UplinkPeriodAttribute]); err == nil && configured > 0 {
+		return configured, false
+	}
+	if d.UplinkPeriodSamples >= minUplinkPeriodSamples {
+		return d.UplinkPeriod, true
+	}
+	return 0, false
+}
+
+// NetworkSettings returns the network settings that are configured in the attributes of the device, and whether the
+// device can only be personalized
+func (d *Device) NetworkSettings() api.DeviceSettings {
+	settings := api.DeviceSettings{
+		FrequencyPlan: d.Attributes[FrequencyPlanAttribute],
+		ABP:           d.AppKey.IsEmpty(),
+	}
+	if margin, err := strconv.Atoi(d.Attributes[ADRMarginAttribute]); err == nil {
+		settings.ADRMargin = margin
+	}
+	return settings
+}
+
 // StartUpdate stores the state of the device
 func (d *Device) StartUpdate() {
 	old := *d
@@ -72,9 +166,48 @@ func (d *Device) Clone() *Device {
 		n.CurrentDownlink = new(types.DownlinkMessage)
 		*n.CurrentDownlink = *d.CurrentDownlink
 	}
+	if d.LastJoinAccept != nil {
+		n.LastJoinAccept = new(JoinAccept)
+		*n.LastJoinAccept = *d.LastJoinAccept
+	}
+	if d.MulticastGroups != nil {
+		n.MulticastGroups = append([]multicast.Group(nil), d.MulticastGroups...)
+	}
 	return n
 }
 
+// SetMulticastGroup adds the multicast group to the device or replaces the group with the same ID
+func (d *Device) SetMulticastGroup(group multicast.Group) {
+	groups := make([]multicast.Group, 0, len(d.MulticastGroups)+1)
+	for _, existing := range d.MulticastGroups {
+		if existing.ID != group.ID {
+			groups = append(groups, existing)
+		}
+	}
+	d.MulticastGroups = append(groups, group)
+}
+
+// GetMulticastGroup returns the multicast group with the given ID
+func (d *Device) GetMulticastGroup(id uint8) (multicast.Group, bool) {
+	for _, group := range d.MulticastGroups {
+		if group.ID == id {
+			return group, true
+		}
+	}
+	return multicast.Group{}, false
+}
+
+// DeleteMulticastGroup removes the multicast group with the given ID from the device
+func (d *Device) DeleteMulticastGroup(id uint8) {
+	groups := make([]multicast.Group, 0, len(d.MulticastGroups))
+	for _, existing := range d.MulticastGroups {
+		if existing.ID != id {
+			groups = append(groups, existing)
+		}
+	}
+	d.MulticastGroups = groups
+}
+
 // DBVersion of the model
 func (d *Device) DBVersion() string {
 	return currentDBVersion
@@ -115,3 +248,25 @@ func (d Device) ChangedFields() (changed []string) {
 
 	return
 }
+
+// Connectivity contains the connectivity status of a device
+type Connectivity struct {
+	LastSeen      time.Time `json:"last_seen,omitempty"`
+	LastGatewayID string    `json:"last_gateway_id,omitempty"`
+	LastRSSI      float32   `json:"last_rssi,omitempty"`
+	LastSNR       float32   `json:"last_snr,omitempty"`
+	MissedUplinks uint32    `json:"missed_uplinks,omitempty"`
+	Offline       bool      `json:"offline,omitempty"`
+}
+
+// Connectivity returns the connectivity status of the device
+func (d *Device) Connectivity() Connectivity {
+	return Connectivity{
+		LastSeen:      d.LastSeen,
+		LastGatewayID: d.LastGatewayID,
+		LastRSSI:      d.LastRSSI,
+		LastSNR:       d.LastSNR,
+		MissedUplinks: d.MissedUplinks,
+		Offline:       d.Offline,
+	}
+}
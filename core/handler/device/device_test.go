@@ -5,7 +5,9 @@ package device
 
 import (
 	"testing"
+	"time"
 
+	"github.com/TheThingsNetwork/ttn/api"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	. "github.com/smartystreets/assertions"
 )
@@ -48,3 +50,42 @@ func TestDeviceChangedFields(t *testing.T) {
 	a.So(device.ChangedFields(), ShouldContain, "Options")
 	a.So(device.ChangedFields(), ShouldContain, "Options.DisableFCntCheck")
 }
+
+func TestDeviceUplinkPeriod(t *testing.T) {
+	a := New(t)
+	device := &Device{}
+	now := time.Now()
+
+	device.LearnUplinkPeriod(now)
+	a.So(device.UplinkPeriodSamples, ShouldEqual, 0)
+
+	for i := 0; i < 3; i++ {
+		device.LastSeen = now
+		now = now.Add(10 * time.Minute)
+		device.LearnUplinkPeriod(now)
+	}
+	a.So(device.UplinkPeriod, ShouldEqual, 10*time.Minute)
+	period, learned := device.ExpectedUplinkPeriod()
+	a.So(period, ShouldEqual, 10*time.Minute)
+	a.So(learned, ShouldBeTrue)
+
+	device.LastSeen = now
+	device.LearnUplinkPeriod(now.Add(30 * time.Minute))
+	a.So(device.UplinkPeriod, ShouldEqual, 15*time.Minute)
+
+	device.Attributes = map[string]string{UplinkPeriodAttribute: "1h"}
+	period, learned = device.ExpectedUplinkPeriod()
+	a.So(period, ShouldEqual, time.Hour)
+	a.So(learned, ShouldBeFalse)
+}
+
+func TestDeviceNetworkSettings(t *testing.T) {
+	a := New(t)
+	device := &Device{}
+	a.So(device.NetworkSettings(), ShouldResemble, api.DeviceSettings{ABP: true})
+
+	device.AppKey = types.AppKey{1}
+
+	device.Attributes = map[string]string{FrequencyPlanAttribute: "EU_863_870", ADRMarginAttribute: "10", ClassAttribute: "C"}
+	a.So(device.NetworkSettings(), ShouldResemble, api.DeviceSettings{FrequencyPlan: "EU_863_870", ADRMargin: 10})
+}
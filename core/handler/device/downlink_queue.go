@@ -18,6 +18,9 @@ type DownlinkQueue interface {
 	Replace(msg *types.DownlinkMessage) error
 	PushFirst(msg *types.DownlinkMessage) error
 	PushLast(msg *types.DownlinkMessage) error
+	List() ([]*types.DownlinkMessage, error)
+	Remove(id string) (*types.DownlinkMessage, error)
+	Clear() error
 }
 
 // RedisDownlinkQueue implements the downlink queue in Redis
@@ -77,3 +80,48 @@ func (s *RedisDownlinkQueue) PushLast(msg *types.DownlinkMessage) error {
 	}
 	return s.queues.AddEnd(s.key(), string(qd))
 }
+
+// List the messages in the downlink queue, without removing them
+func (s *RedisDownlinkQueue) List() ([]*types.DownlinkMessage, error) {
+	queued, err := s.queues.Get(s.key())
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*types.DownlinkMessage, 0, len(queued))
+	for _, qd := range queued {
+		msg := new(types.DownlinkMessage)
+		if err := json.Unmarshal([]byte(qd), msg); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// Remove the message with the given ID from the downlink queue, returns nil if there is no such message
+func (s *RedisDownlinkQueue) Remove(id string) (*types.DownlinkMessage, error) {
+	queued, err := s.queues.Get(s.key())
+	if err != nil {
+		return nil, err
+	}
+	for _, qd := range queued {
+		msg := new(types.DownlinkMessage)
+		if err := json.Unmarshal([]byte(qd), msg); err != nil {
+			return nil, err
+		}
+		if msg.ID != id {
+			continue
+		}
+		removed, err := s.queues.Remove(s.key(), qd)
+		if err != nil || !removed {
+			return nil, err
+		}
+		return msg, nil
+	}
+	return nil, nil
+}
+
+// Clear the downlink queue
+func (s *RedisDownlinkQueue) Clear() error {
+	return s.queues.Delete(s.key())
+}
@@ -80,4 +80,34 @@ func TestDownlinkQueue(t *testing.T) {
 		a.So(next.PayloadRaw, ShouldResemble, []byte{0xaa, 0xbc})
 	}
 
+	{
+		s.PushLast(&types.DownlinkMessage{ID: "first", PayloadRaw: []byte{0x01}})
+		s.PushLast(&types.DownlinkMessage{ID: "second", PayloadRaw: []byte{0x02}})
+		list, err := s.List()
+		a.So(err, ShouldBeNil)
+		a.So(list, ShouldHaveLength, 2)
+		a.So(list[0].ID, ShouldEqual, "first")
+	}
+
+	{
+		removed, err := s.Remove("first")
+		a.So(err, ShouldBeNil)
+		a.So(removed, ShouldNotBeNil)
+		a.So(removed.PayloadRaw, ShouldResemble, []byte{0x01})
+
+		removed, err = s.Remove("first")
+		a.So(err, ShouldBeNil)
+		a.So(removed, ShouldBeNil)
+
+		length, _ := s.Length()
+		a.So(length, ShouldEqual, 1)
+	}
+
+	{
+		err := s.Clear()
+		a.So(err, ShouldBeNil)
+		length, _ := s.Length()
+		a.So(length, ShouldEqual, 0)
+	}
+
 }
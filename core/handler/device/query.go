@@ -0,0 +1,100 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Fields that devices can be sorted by
+const (
+	SortByDevID     = "dev_id"
+	SortByDevEUI    = "dev_eui"
+	SortByLastSeen  = "last_seen"
+	SortByCreatedAt = "created_at"
+)
+
+// Query selects and sorts the devices of an application. The zero value selects all devices, sorted by DevID.
+type Query struct {
+	// Attributes that the devices must all have, with these values
+	Attributes map[string]string
+
+	// The devices were last seen in [LastSeenAfter, LastSeenBefore). The zero values are open ends.
+	LastSeenAfter  time.Time
+	LastSeenBefore time.Time
+
+	// The hex-encoded DevEUIs of the devices start with DevEUIPrefix
+	DevEUIPrefix string
+
+	SortBy     string
+	Descending bool
+}
+
+// Validate the query
+func (q Query) Validate() error {
+	switch q.SortBy {
+	case "", SortByDevID, SortByDevEUI, SortByLastSeen, SortByCreatedAt:
+	default:
+		return errors.NewErrInvalidArgument("Sort", fmt.Sprintf("can not sort by %s", q.SortBy))
+	}
+	if strings.Trim(q.DevEUIPrefix, "0123456789abcdefABCDEF") != "" || len(q.DevEUIPrefix) > 16 {
+		return errors.NewErrInvalidArgument("DevEUI prefix", "must be hex")
+	}
+	if !q.LastSeenAfter.IsZero() && !q.LastSeenBefore.IsZero() && !q.LastSeenAfter.Before(q.LastSeenBefore) {
+		return errors.NewErrInvalidArgument("Last seen", "range is empty")
+	}
+	return nil
+}
+
+// isAll returns whether the query selects all devices in the order of the store
+func (q Query) isAll() bool {
+	return len(q.Attributes) == 0 && q.LastSeenAfter.IsZero() && q.LastSeenBefore.IsZero() && q.DevEUIPrefix == "" &&
+		(q.SortBy == "" || q.SortBy == SortByDevID) && !q.Descending
+}
+
+// Matches returns whether the device is selected by the query
+func (q Query) Matches(dev *Device) bool {
+	for k, v := range q.Attributes {
+		if value, ok := dev.Attributes[k]; !ok || value != v {
+			return false
+		}
+	}
+	if !q.LastSeenAfter.IsZero() && dev.LastSeen.Before(q.LastSeenAfter) {
+		return false
+	}
+	if !q.LastSeenBefore.IsZero() && !dev.LastSeen.Before(q.LastSeenBefore) {
+		return false
+	}
+	if q.DevEUIPrefix != "" && !strings.HasPrefix(dev.DevEUI.String(), strings.ToUpper(q.DevEUIPrefix)) {
+		return false
+	}
+	return true
+}
+
+// Sort the devices according to the query
+func (q Query) Sort(devices []*Device) {
+	var less func(a, b *Device) bool
+	switch q.SortBy {
+	case SortByDevEUI:
+		less = func(a, b *Device) bool { return bytes.Compare(a.DevEUI.Bytes(), b.DevEUI.Bytes()) < 0 }
+	case SortByLastSeen:
+		less = func(a, b *Device) bool { return a.LastSeen.Before(b.LastSeen) }
+	case SortByCreatedAt:
+		less = func(a, b *Device) bool { return a.CreatedAt.Before(b.CreatedAt) }
+	default:
+		less = func(a, b *Device) bool { return a.DevID < b.DevID }
+	}
+	sort.SliceStable(devices, func(i, j int) bool {
+		if q.Descending {
+			return less(devices[j], devices[i])
+		}
+		return less(devices[i], devices[j])
+	})
+}
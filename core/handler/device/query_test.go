@@ -0,0 +1,57 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestQuery(t *testing.T) {
+	a := New(t)
+
+	now := time.Now()
+	devices := []*Device{
+		{DevID: "b", DevEUI: types.DevEUI{0x70, 0xb3, 0, 0, 0, 0, 0, 2}, LastSeen: now.Add(-time.Hour), Attributes: map[string]string{"floor": "1"}},
+		{DevID: "c", DevEUI: types.DevEUI{0x00, 0x04, 0, 0, 0, 0, 0, 1}, LastSeen: now, Attributes: map[string]string{"floor": "1", "room": "101"}},
+		{DevID: "a", DevEUI: types.DevEUI{0x70, 0xb3, 0, 0, 0, 0, 0, 3}},
+	}
+	match := func(q Query) (ids []string) {
+		for _, dev := range devices {
+			if q.Matches(dev) {
+				ids = append(ids, dev.DevID)
+			}
+		}
+		return
+	}
+
+	a.So(Query{}.isAll(), ShouldBeTrue)
+	a.So(match(Query{}), ShouldResemble, []string{"b", "c", "a"})
+	a.So(match(Query{Attributes: map[string]string{"floor": "1"}}), ShouldResemble, []string{"b", "c"})
+	a.So(match(Query{Attributes: map[string]string{"floor": "1", "room": "101"}}), ShouldResemble, []string{"c"})
+	a.So(match(Query{Attributes: map[string]string{"floor": "2"}}), ShouldBeEmpty)
+	a.So(match(Query{LastSeenAfter: now.Add(-time.Minute)}), ShouldResemble, []string{"c"})
+	a.So(match(Query{LastSeenBefore: now}), ShouldResemble, []string{"b", "a"})
+	a.So(match(Query{DevEUIPrefix: "70b3"}), ShouldResemble, []string{"b", "a"})
+
+	sorted := func(q Query) (ids []string) {
+		q.Sort(devices)
+		for _, dev := range devices {
+			ids = append(ids, dev.DevID)
+		}
+		return
+	}
+	a.So(sorted(Query{}), ShouldResemble, []string{"a", "b", "c"})
+	a.So(sorted(Query{Descending: true}), ShouldResemble, []string{"c", "b", "a"})
+	a.So(sorted(Query{SortBy: SortByDevEUI}), ShouldResemble, []string{"c", "b", "a"})
+	a.So(sorted(Query{SortBy: SortByLastSeen, Descending: true}), ShouldResemble, []string{"c", "b", "a"})
+
+	a.So(Query{SortBy: "description"}.Validate(), ShouldNotBeNil)
+	a.So(Query{DevEUIPrefix: "70-b3"}.Validate(), ShouldNotBeNil)
+	a.So(Query{LastSeenAfter: now, LastSeenBefore: now}.Validate(), ShouldNotBeNil)
+	a.So(Query{SortBy: SortByCreatedAt, DevEUIPrefix: "70B3"}.Validate(), ShouldBeNil)
+}
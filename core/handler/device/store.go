@@ -4,12 +4,14 @@
 package device
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"time"
 
 	"github.com/TheThingsNetwork/ttn/core/handler/device/migrate"
 	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"gopkg.in/redis.v5"
 )
@@ -20,16 +22,26 @@ type Store interface {
 	CountForApp(appID string) (int, error)
 	List(opts *storage.ListOptions) ([]*Device, error)
 	ListForApp(appID string, opts *storage.ListOptions) ([]*Device, error)
+	Search(appID string, query Query, opts *storage.ListOptions) ([]*Device, error)
+	IndexAttributes() (int, error)
 	Get(appID, devID string) (*Device, error)
 	DownlinkQueue(appID, devID string) (DownlinkQueue, error)
 	Set(new *Device, properties ...string) (err error)
+	Create(new *Device, fn func(tx *storage.RedisTx) error, keys ...string) (err error)
+	SetNextDownlink(dev *Device) (expired []*types.DownlinkMessage, err error)
+	FlushDownlinks(appID, devID string) (flushed []*types.DownlinkMessage, err error)
+	CancelDownlink(appID, devID, downlinkID string) (cancelled *types.DownlinkMessage, err error)
 	Delete(appID, devID string) error
+	ScheduleSilenceCheck(appID, devID string, at time.Time) error
+	ClaimSilenceChecks(until time.Time) ([]*Device, error)
 	AddBuiltinAttribute(attr ...string)
 }
 
 const defaultRedisPrefix = "handler"
 const redisDevicePrefix = "device"
 const redisDownlinkQueuePrefix = "downlink"
+const redisAttributePrefix = "attribute"
+const redisSilenceCheckKey = "silence-checks"
 
 var defaultDeviceAttributes = []string{
 	"ttn-brand",
@@ -37,6 +49,10 @@ var defaultDeviceAttributes = []string{
 	"ttn-version",
 	"ttn-antenna",
 	"ttn-module-type",
+	UplinkPeriodAttribute,
+	FrequencyPlanAttribute,
+	ClassAttribute,
+	ADRMarginAttribute,
 }
 
 const (
@@ -57,8 +73,11 @@ func NewRedisDeviceStore(client *redis.Client, prefix string) *RedisDeviceStore
 	}
 	queues := storage.NewRedisQueueStore(client, prefix+":"+redisDownlinkQueuePrefix)
 	s := &RedisDeviceStore{
-		store:  store,
-		queues: queues,
+		prefix:         prefix,
+		store:          store,
+		queues:         queues,
+		attributeIndex: storage.NewRedisSetStore(client, prefix+":"+redisAttributePrefix),
+		silenceChecks:  storage.NewRedisSortedSetStore(client, prefix),
 	}
 	s.AddBuiltinAttribute(defaultDeviceAttributes...)
 	return s
@@ -66,12 +85,27 @@ func NewRedisDeviceStore(client *redis.Client, prefix string) *RedisDeviceStore
 
 // RedisDeviceStore stores Devices in Redis.
 // - Devices are stored as a Hash
+// - Attributes are indexed in a Set per application, attribute and value
+// - The times at which devices are checked for silence are stored in a Sorted Set
 type RedisDeviceStore struct {
+	prefix           string
 	store            *storage.RedisMapStore
 	queues           *storage.RedisQueueStore
+	attributeIndex   *storage.RedisSetStore
+	silenceChecks    *storage.RedisSortedSetStore
 	builtinAttibutes []string // sorted
 }
 
+// SetSalvage makes the store quarantine corrupted devices and downlink queues instead of failing on them
+func (s *RedisDeviceStore) SetSalvage(salvage bool) {
+	s.store.SetSalvage(salvage)
+	s.queues.SetSalvage(salvage)
+}
+
+func attributeKey(appID, key, value string) string {
+	return fmt.Sprintf("%s:%s=%s", appID, key, value)
+}
+
 // Count all devices in the store
 func (s *RedisDeviceStore) Count() (int, error) {
 	return s.store.Count("")
@@ -112,6 +146,138 @@ func (s *RedisDeviceStore) ListForApp(appID string, opts *storage.ListOptions) (
 	return devices, nil
 }
 
+// Search the devices of an Application. Devices with the attributes of the query are looked up in the
+// attribute index; the other conditions are checked on the devices.
+func (s *RedisDeviceStore) Search(appID string, query Query, opts *storage.ListOptions) ([]*Device, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	if query.isAll() {
+		return s.ListForApp(appID, opts)
+	}
+
+	var devicesI []interface{}
+	var err error
+	if len(query.Attributes) == 0 {
+		devicesI, err = s.store.List(fmt.Sprintf("%s:*", appID), nil)
+	} else {
+		var keys []string
+		keys, err = s.keysWithAttributes(appID, query.Attributes)
+		if err == nil {
+			devicesI, err = s.store.GetAll(keys, nil)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]*Device, 0, len(devicesI))
+	for _, deviceI := range devicesI {
+		if device, ok := deviceI.(Device); ok && query.Matches(&device) {
+			devices = append(devices, &device)
+		}
+	}
+	query.Sort(devices)
+	start, end := opts.Select(len(devices))
+	return devices[start:end], nil
+}
+
+// keysWithAttributes returns the keys of the devices that have all attributes
+func (s *RedisDeviceStore) keysWithAttributes(appID string, attributes map[string]string) ([]string, error) {
+	var keys map[string]bool
+	for k, v := range attributes {
+		withAttribute, err := s.attributeIndex.Get(attributeKey(appID, k, v))
+		if errors.GetErrType(err) == errors.NotFound {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		matching := make(map[string]bool, len(withAttribute))
+		for _, key := range withAttribute {
+			if keys == nil || keys[key] {
+				matching[key] = true
+			}
+		}
+		keys = matching
+	}
+	res := make([]string, 0, len(keys))
+	for key := range keys {
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+// updateAttributeIndexTx removes the device from the index of the old attributes and adds it to the index of the
+// new attributes in the transaction
+func (s *RedisDeviceStore) updateAttributeIndexTx(tx *storage.RedisTx, appID, key string, old, new map[string]string) {
+	for k, v := range old {
+		if newV, ok := new[k]; ok && newV == v {
+			continue
+		}
+		s.attributeIndex.RemoveTx(tx, attributeKey(appID, k, v), key)
+	}
+	for k, v := range new {
+		if oldV, ok := old[k]; ok && oldV == v {
+			continue
+		}
+		s.attributeIndex.AddTx(tx, attributeKey(appID, k, v), key)
+	}
+}
+
+func hasProperty(properties []string, property string) bool {
+	for _, p := range properties {
+		if p == property {
+			return true
+		}
+	}
+	return false
+}
+
+// getAttributesTx returns the attributes of a device in the store in the transaction
+func (s *RedisDeviceStore) getAttributesTx(tx *storage.RedisTx, key string) (map[string]string, error) {
+	deviceI, err := s.store.GetFieldsTx(tx, key, "attributes")
+	if errors.GetErrType(err) == errors.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if device, ok := deviceI.(Device); ok {
+		return device.Attributes, nil
+	}
+	return nil, errors.New("Database did not return a Device")
+}
+
+// IndexAttributes adds all devices to the attribute index, and returns the number of devices. This indexes
+// devices that were stored before there was an attribute index.
+func (s *RedisDeviceStore) IndexAttributes() (int, error) {
+	devices, err := s.List(nil)
+	if err != nil {
+		return 0, err
+	}
+	var indexed int
+	for _, dev := range devices {
+		if dev == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s", dev.AppID, dev.DevID)
+		err := s.store.Transaction(func(tx *storage.RedisTx) error {
+			attributes, err := s.getAttributesTx(tx, key)
+			if err != nil {
+				return err
+			}
+			s.updateAttributeIndexTx(tx, dev.AppID, key, nil, attributes)
+			return nil
+		}, s.store.Key(key))
+		if err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
 // Get a specific Device
 func (s *RedisDeviceStore) Get(appID, devID string) (*Device, error) {
 	deviceI, err := s.store.Get(fmt.Sprintf("%s:%s", appID, devID))
@@ -141,8 +307,61 @@ func (s *RedisDeviceStore) Set(new *Device, properties ...string) (err error) {
 	if new.old == nil {
 		new.CreatedAt = now
 	}
+	if err := s.checkAttributes(new); err != nil {
+		return err
+	}
+
+	// The attribute index is updated in the same transaction as the device
+	return s.store.Transaction(func(tx *storage.RedisTx) error {
+		if err := s.store.SetTx(tx, key, *new, properties...); err != nil {
+			return err
+		}
+		if len(properties) == 0 || hasProperty(properties, "Attributes") {
+			oldAttributes, err := s.getAttributesTx(tx, key)
+			if err != nil {
+				return err
+			}
+			s.updateAttributeIndexTx(tx, new.AppID, key, oldAttributes, new.Attributes)
+		}
+		return nil
+	}, s.store.Key(key))
+}
+
+// Create a new Device. The device is stored in a transaction that also runs fn and watches the given (full) keys, so
+// that the device can be created together with changes to other stores that use the same Redis client. An
+// AlreadyExists error is returned if the Device already exists.
+func (s *RedisDeviceStore) Create(new *Device, fn func(tx *storage.RedisTx) error, keys ...string) (err error) {
+	now := time.Now()
+	new.CreatedAt, new.UpdatedAt = now, now
+	key := fmt.Sprintf("%s:%s", new.AppID, new.DevID)
+	if err := s.checkAttributes(new); err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx *storage.RedisTx) error {
+		exists, err := s.store.ExistsTx(tx, key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errors.NewErrAlreadyExists(fmt.Sprintf("Device %s", new.DevID))
+		}
+		if fn != nil {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		if err := s.store.SetTx(tx, key, *new); err != nil {
+			return err
+		}
+		s.updateAttributeIndexTx(tx, new.AppID, key, nil, new.Attributes)
+		return nil
+	}, append(keys, s.store.Key(key))...)
+}
+
+// checkAttributes checks the number and length of the custom attributes of the Device
+func (s *RedisDeviceStore) checkAttributes(dev *Device) error {
 	customAttributeSlots := maxDeviceAttributes
-	for k, v := range new.Attributes {
+	for k, v := range dev.Attributes {
 		if idx := sort.SearchStrings(s.builtinAttibutes, k); idx < len(s.builtinAttibutes) && s.builtinAttibutes[idx] == k {
 			continue
 		}
@@ -157,20 +376,187 @@ func (s *RedisDeviceStore) Set(new *Device, properties ...string) (err error) {
 		}
 		customAttributeSlots--
 	}
-	err = s.store.Set(key, *new, properties...)
-	if err != nil {
-		return
-	}
 	return nil
 }
 
+// SetNextDownlink atomically takes the next message from the downlink queue,
+// sets it as the CurrentDownlink of the Device and saves the Device. Messages that
+// should not be sent yet stay in the queue, and expired messages are removed and
+// returned. Of the other messages, the first one with the highest priority is taken.
+func (s *RedisDeviceStore) SetNextDownlink(dev *Device) (expired []*types.DownlinkMessage, err error) {
+	key := fmt.Sprintf("%s:%s", dev.AppID, dev.DevID)
+	err = s.store.Transaction(func(tx *storage.RedisTx) error {
+		expired = nil
+		queued, err := s.queues.GetTx(tx, key)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		var next *types.DownlinkMessage
+		var nextQd string
+		for _, qd := range queued {
+			msg := new(types.DownlinkMessage)
+			if err := json.Unmarshal([]byte(qd), msg); err != nil {
+				return err
+			}
+			switch {
+			case msg.Expired(now):
+				expired = append(expired, msg)
+				s.queues.RemoveTx(tx, key, qd)
+			case msg.Pending(now):
+			case next == nil || msg.Priority.Level() > next.Priority.Level():
+				next, nextQd = msg, qd
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		// The device was read before the transaction, so only the fields of the downlink are written, and a device
+		// that was deleted in the meantime is not stored again
+		exists, err := s.store.ExistsTx(tx, key)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.NewErrNotFound(key)
+		}
+		s.queues.RemoveTx(tx, key, nextQd)
+		dev.CurrentDownlink = next
+		dev.UpdatedAt = now
+		return s.store.SetTx(tx, key, *dev, "CurrentDownlink", "UpdatedAt")
+	}, s.store.Key(key), s.queues.Key(key))
+	return
+}
+
+// FlushDownlinks atomically removes the CurrentDownlink of the Device and all messages in the downlink queue, and
+// returns the removed messages, starting with the CurrentDownlink.
+func (s *RedisDeviceStore) FlushDownlinks(appID, devID string) (flushed []*types.DownlinkMessage, err error) {
+	key := fmt.Sprintf("%s:%s", appID, devID)
+	err = s.store.Transaction(func(tx *storage.RedisTx) error {
+		flushed = nil
+		deviceI, err := s.store.GetTx(tx, key)
+		if err != nil {
+			return err
+		}
+		dev, ok := deviceI.(Device)
+		if !ok {
+			return errors.New("Database did not return a Device")
+		}
+		if dev.CurrentDownlink != nil {
+			flushed = append(flushed, dev.CurrentDownlink)
+			dev.CurrentDownlink = nil
+			dev.UpdatedAt = time.Now()
+			if err := s.store.SetTx(tx, key, dev, "CurrentDownlink", "UpdatedAt"); err != nil {
+				return err
+			}
+		}
+		queued, err := s.queues.GetTx(tx, key)
+		if err != nil {
+			return err
+		}
+		for _, qd := range queued {
+			msg := new(types.DownlinkMessage)
+			if err := json.Unmarshal([]byte(qd), msg); err != nil {
+				return err
+			}
+			flushed = append(flushed, msg)
+		}
+		s.queues.DeleteTx(tx, key)
+		return nil
+	}, s.store.Key(key), s.queues.Key(key))
+	return
+}
+
+// CancelDownlink atomically removes the downlink with the given ID, which is either the CurrentDownlink of the Device
+// or a message in the downlink queue, and returns it. It returns nil if the Device has no downlink with this ID.
+func (s *RedisDeviceStore) CancelDownlink(appID, devID, downlinkID string) (cancelled *types.DownlinkMessage, err error) {
+	key := fmt.Sprintf("%s:%s", appID, devID)
+	err = s.store.Transaction(func(tx *storage.RedisTx) error {
+		cancelled = nil
+		deviceI, err := s.store.GetTx(tx, key)
+		if err != nil {
+			return err
+		}
+		dev, ok := deviceI.(Device)
+		if !ok {
+			return errors.New("Database did not return a Device")
+		}
+		if dev.CurrentDownlink != nil && dev.CurrentDownlink.ID == downlinkID {
+			cancelled = dev.CurrentDownlink
+			dev.CurrentDownlink = nil
+			dev.UpdatedAt = time.Now()
+			return s.store.SetTx(tx, key, dev, "CurrentDownlink", "UpdatedAt")
+		}
+		queued, err := s.queues.GetTx(tx, key)
+		if err != nil {
+			return err
+		}
+		for _, qd := range queued {
+			msg := new(types.DownlinkMessage)
+			if err := json.Unmarshal([]byte(qd), msg); err != nil {
+				return err
+			}
+			if msg.ID == downlinkID {
+				cancelled = msg
+				s.queues.RemoveTx(tx, key, qd)
+				return nil
+			}
+		}
+		return nil
+	}, s.store.Key(key), s.queues.Key(key))
+	return
+}
+
 // Delete a Device
 func (s *RedisDeviceStore) Delete(appID, devID string) error {
 	key := fmt.Sprintf("%s:%s", appID, devID)
-	if err := s.queues.Delete(key); err != nil {
-		return err
+	return s.store.Transaction(func(tx *storage.RedisTx) error {
+		attributes, err := s.getAttributesTx(tx, key)
+		if err != nil {
+			return err
+		}
+		s.updateAttributeIndexTx(tx, appID, key, attributes, nil)
+		s.queues.DeleteTx(tx, key)
+		s.silenceChecks.RemoveTx(tx, redisSilenceCheckKey, key)
+		s.store.DeleteTx(tx, key)
+		return nil
+	}, s.store.Key(key))
+}
+
+// ScheduleSilenceCheck schedules a check of the device for silence at the given time, replacing the check that was
+// scheduled before
+func (s *RedisDeviceStore) ScheduleSilenceCheck(appID, devID string, at time.Time) error {
+	return s.silenceChecks.Add(redisSilenceCheckKey, float64(at.Unix()), fmt.Sprintf("%s:%s", appID, devID))
+}
+
+// ClaimSilenceChecks removes the silence checks that are due until the given time and returns their devices. Each
+// check is claimed by one caller, so that Handlers that share the store do not check the same device.
+func (s *RedisDeviceStore) ClaimSilenceChecks(until time.Time) ([]*Device, error) {
+	keys, err := s.silenceChecks.GetUntil(redisSilenceCheckKey, float64(until.Unix()))
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]*Device, 0, len(keys))
+	for _, key := range keys {
+		claimed, err := s.silenceChecks.Remove(redisSilenceCheckKey, key)
+		if err != nil {
+			return devices, err
+		}
+		if !claimed {
+			continue
+		}
+		deviceI, err := s.store.Get(key)
+		if errors.GetErrType(err) == errors.NotFound {
+			continue
+		}
+		if err != nil {
+			return devices, err
+		}
+		if device, ok := deviceI.(Device); ok {
+			devices = append(devices, &device)
+		}
 	}
-	return s.store.Delete(key)
+	return devices, nil
 }
 
 // AddBuiltinAttribute adds builtin device attributes to the list.
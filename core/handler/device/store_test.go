@@ -6,8 +6,11 @@ package device
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/TheThingsNetwork/ttn/core/storage"
 	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
 	. "github.com/TheThingsNetwork/ttn/utils/testing"
 	. "github.com/smartystreets/assertions"
 )
@@ -187,3 +190,242 @@ func TestRedisDeviceStoreAttributes(t *testing.T) {
 	err = store.Set(dev)
 	a.So(err, ShouldNotBeNil)
 }
+
+func TestRedisDeviceStoreSetNextDownlink(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisDeviceStore(GetRedisClient(), "handler-test-set-next-downlink")
+	dev := &Device{AppID: "test", DevID: "test"}
+	store.Set(dev)
+	defer store.Delete("test", "test")
+	dev.StartUpdate()
+
+	// Empty queue
+	_, err := store.SetNextDownlink(dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.CurrentDownlink, ShouldBeNil)
+
+	queue, _ := store.DownlinkQueue("test", "test")
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{1}})
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{2}})
+
+	_, err = store.SetNextDownlink(dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.CurrentDownlink, ShouldNotBeNil)
+	a.So(dev.CurrentDownlink.PayloadRaw, ShouldResemble, []byte{1})
+
+	stored, err := store.Get("test", "test")
+	a.So(err, ShouldBeNil)
+	a.So(stored.CurrentDownlink, ShouldNotBeNil)
+	a.So(stored.CurrentDownlink.PayloadRaw, ShouldResemble, []byte{1})
+
+	length, _ := queue.Length()
+	a.So(length, ShouldEqual, 1)
+
+	// Delivery times and priorities
+	past, future := types.JSONTime(time.Now().Add(-1*time.Minute)), types.JSONTime(time.Now().Add(time.Minute))
+	queue.Replace(&types.DownlinkMessage{PayloadRaw: []byte{1}, LatestAt: &past})
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{2}, EarliestAt: &future})
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{3}, Priority: types.DownlinkPriorityLow})
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{4}, Priority: types.DownlinkPriorityHigh})
+
+	expired, err := store.SetNextDownlink(dev)
+	a.So(err, ShouldBeNil)
+	a.So(expired, ShouldHaveLength, 1)
+	a.So(expired[0].PayloadRaw, ShouldResemble, []byte{1})
+	a.So(dev.CurrentDownlink.PayloadRaw, ShouldResemble, []byte{4})
+
+	_, err = store.SetNextDownlink(dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.CurrentDownlink.PayloadRaw, ShouldResemble, []byte{3})
+
+	length, _ = queue.Length()
+	a.So(length, ShouldEqual, 1)
+
+	// Concurrent updates of other fields are kept
+	other, _ := store.Get("test", "test")
+	other.StartUpdate()
+	other.Description = "updated"
+	a.So(store.Set(other), ShouldBeNil)
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{5}})
+	_, err = store.SetNextDownlink(dev)
+	a.So(err, ShouldBeNil)
+	stored, _ = store.Get("test", "test")
+	a.So(stored.Description, ShouldEqual, "updated")
+
+	// Deleted devices are not stored again
+	store.Delete("test", "test")
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{6}})
+	_, err = store.SetNextDownlink(dev)
+	a.So(err, ShouldNotBeNil)
+	_, err = store.Get("test", "test")
+	a.So(err, ShouldNotBeNil)
+	queue.Clear()
+}
+
+func TestRedisDeviceStoreSearch(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisDeviceStore(GetRedisClient(), "handler-test-search")
+	now := time.Now()
+	for _, dev := range []*Device{
+		{AppID: "test", DevID: "dev-1", DevEUI: types.DevEUI{0x70, 0xb3, 0, 0, 0, 0, 0, 1}, LastSeen: now.Add(-time.Hour), Attributes: map[string]string{"floor": "1"}},
+		{AppID: "test", DevID: "dev-2", DevEUI: types.DevEUI{0x00, 0x04, 0, 0, 0, 0, 0, 2}, LastSeen: now, Attributes: map[string]string{"floor": "1", "room": "101"}},
+		{AppID: "test", DevID: "dev-3", DevEUI: types.DevEUI{0x70, 0xb3, 0, 0, 0, 0, 0, 3}, Attributes: map[string]string{"floor": "2"}},
+		{AppID: "other", DevID: "dev-1", Attributes: map[string]string{"floor": "1"}},
+	} {
+		a.So(store.Set(dev), ShouldBeNil)
+		defer store.Delete(dev.AppID, dev.DevID)
+	}
+	ids := func(devices []*Device) (ids []string) {
+		for _, dev := range devices {
+			ids = append(ids, dev.DevID)
+		}
+		return
+	}
+
+	devices, err := store.Search("test", Query{}, nil)
+	a.So(err, ShouldBeNil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-1", "dev-2", "dev-3"})
+
+	devices, err = store.Search("test", Query{Attributes: map[string]string{"floor": "1"}, SortBy: SortByLastSeen, Descending: true}, nil)
+	a.So(err, ShouldBeNil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-2", "dev-1"})
+
+	devices, err = store.Search("test", Query{Attributes: map[string]string{"floor": "3"}}, nil)
+	a.So(err, ShouldBeNil)
+	a.So(devices, ShouldBeEmpty)
+
+	opts := &storage.ListOptions{Limit: 1, Offset: 1}
+	devices, err = store.Search("test", Query{DevEUIPrefix: "70B3"}, opts)
+	a.So(err, ShouldBeNil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-3"})
+	total, selected := opts.GetTotalAndSelected()
+	a.So(total, ShouldEqual, 2)
+	a.So(selected, ShouldEqual, 1)
+
+	_, err = store.Search("test", Query{SortBy: "description"}, nil)
+	a.So(err, ShouldNotBeNil)
+
+	// The index follows changes of the attributes
+	dev, _ := store.Get("test", "dev-3")
+	dev.StartUpdate()
+	dev.Attributes = map[string]string{"floor": "1"}
+	a.So(store.Set(dev), ShouldBeNil)
+	devices, _ = store.Search("test", Query{Attributes: map[string]string{"floor": "1"}}, nil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-1", "dev-2", "dev-3"})
+	devices, _ = store.Search("test", Query{Attributes: map[string]string{"floor": "2"}}, nil)
+	a.So(devices, ShouldBeEmpty)
+
+	a.So(store.Delete("test", "dev-1"), ShouldBeNil)
+	devices, _ = store.Search("test", Query{Attributes: map[string]string{"floor": "1"}}, nil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-2", "dev-3"})
+
+	// Devices that were stored before the index
+	store.attributeIndex.Delete("test:floor=1")
+	indexed, err := store.IndexAttributes()
+	a.So(err, ShouldBeNil)
+	a.So(indexed, ShouldBeGreaterThanOrEqualTo, 3)
+	devices, _ = store.Search("test", Query{Attributes: map[string]string{"floor": "1"}}, nil)
+	a.So(ids(devices), ShouldResemble, []string{"dev-2", "dev-3"})
+}
+
+func TestRedisDeviceStoreFlushDownlinks(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisDeviceStore(GetRedisClient(), "handler-test-flush-downlinks")
+
+	// Unknown device
+	_, err := store.FlushDownlinks("test", "test")
+	a.So(err, ShouldNotBeNil)
+
+	dev := &Device{AppID: "test", DevID: "test", CurrentDownlink: &types.DownlinkMessage{PayloadRaw: []byte{1}}}
+	store.Set(dev)
+	defer store.Delete("test", "test")
+
+	queue, _ := store.DownlinkQueue("test", "test")
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{2}})
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{3}})
+
+	flushed, err := store.FlushDownlinks("test", "test")
+	a.So(err, ShouldBeNil)
+	a.So(flushed, ShouldHaveLength, 3)
+	a.So(flushed[0].PayloadRaw, ShouldResemble, []byte{1})
+	a.So(flushed[2].PayloadRaw, ShouldResemble, []byte{3})
+
+	dev, _ = store.Get("test", "test")
+	a.So(dev.CurrentDownlink, ShouldBeNil)
+	length, _ := queue.Length()
+	a.So(length, ShouldEqual, 0)
+
+	// Nothing left to flush
+	flushed, err = store.FlushDownlinks("test", "test")
+	a.So(err, ShouldBeNil)
+	a.So(flushed, ShouldBeEmpty)
+}
+
+func TestRedisDeviceStoreCancelDownlink(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisDeviceStore(GetRedisClient(), "handler-test-cancel-downlink")
+
+	// Unknown device
+	_, err := store.CancelDownlink("test", "test", "current")
+	a.So(err, ShouldNotBeNil)
+
+	dev := &Device{AppID: "test", DevID: "test", CurrentDownlink: &types.DownlinkMessage{ID: "current"}}
+	store.Set(dev)
+	defer store.Delete("test", "test")
+
+	queue, _ := store.DownlinkQueue("test", "test")
+	queue.PushLast(&types.DownlinkMessage{ID: "queued"})
+
+	cancelled, err := store.CancelDownlink("test", "test", "queued")
+	a.So(err, ShouldBeNil)
+	a.So(cancelled.ID, ShouldEqual, "queued")
+	length, _ := queue.Length()
+	a.So(length, ShouldEqual, 0)
+
+	cancelled, err = store.CancelDownlink("test", "test", "current")
+	a.So(err, ShouldBeNil)
+	a.So(cancelled.ID, ShouldEqual, "current")
+	dev, _ = store.Get("test", "test")
+	a.So(dev.CurrentDownlink, ShouldBeNil)
+
+	// Unknown downlink
+	cancelled, err = store.CancelDownlink("test", "test", "current")
+	a.So(err, ShouldBeNil)
+	a.So(cancelled, ShouldBeNil)
+}
+
+func TestRedisDeviceStoreCreate(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisDeviceStore(GetRedisClient(), "handler-test-create")
+
+	// The transaction fails: the device is not created
+	dev := &Device{AppID: "test", DevID: "test", Attributes: map[string]string{"foo": "bar"}}
+	err := store.Create(dev, func(tx *storage.RedisTx) error {
+		return errors.NewErrPermissionDenied("denied")
+	})
+	a.So(errors.IsPermissionDenied(err), ShouldBeTrue)
+	_, err = store.Get("test", "test")
+	a.So(err, ShouldNotBeNil)
+
+	var called bool
+	err = store.Create(dev, func(tx *storage.RedisTx) error {
+		called = true
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	defer store.Delete("test", "test")
+	a.So(called, ShouldBeTrue)
+
+	res, err := store.Search("test", Query{Attributes: map[string]string{"foo": "bar"}}, nil)
+	a.So(err, ShouldBeNil)
+	a.So(res, ShouldHaveLength, 1)
+
+	// The device already exists
+	err = store.Create(&Device{AppID: "test", DevID: "test"}, nil)
+	a.So(errors.IsAlreadyExists(err), ShouldBeTrue)
+}
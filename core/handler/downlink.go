@@ -9,8 +9,10 @@ import (
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	"github.com/TheThingsNetwork/api/trace"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/quota"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/TheThingsNetwork/ttn/utils/random"
 )
 
 func (h *handler) EnqueueDownlink(appDownlink *types.DownlinkMessage) (err error) {
@@ -38,14 +40,19 @@ func (h *handler) EnqueueDownlink(appDownlink *types.DownlinkMessage) (err error
 
 	defer func() {
 		if err != nil {
+			data := types.DownlinkEventData{
+				ErrorEventData: types.ErrorEventData{Error: err.Error()},
+				Message:        appDownlink,
+			}
+			if exceeded, ok := err.(*quota.ErrExceeded); ok {
+				next := types.JSONTime(exceeded.Next)
+				data.NextDownlinkAt = &next
+			}
 			h.qEvent <- &types.DeviceEvent{
 				AppID: appID,
 				DevID: devID,
 				Event: types.DownlinkErrorEvent,
-				Data: types.DownlinkEventData{
-					ErrorEventData: types.ErrorEventData{Error: err.Error()},
-					Message:        appDownlink,
-				},
+				Data:  data,
 			}
 		}
 	}()
@@ -54,6 +61,31 @@ func (h *handler) EnqueueDownlink(appDownlink *types.DownlinkMessage) (err error
 		return errors.NewErrInvalidArgument("Downlink Payload", "empty")
 	}
 
+	if appDownlink.Priority.Level() < 0 {
+		return errors.NewErrInvalidArgument("Downlink Priority", "unknown")
+	}
+	if appDownlink.Expired(time.Now()) {
+		return errors.NewErrInvalidArgument("Downlink LatestAt", "in the past")
+	}
+	if appDownlink.EarliestAt != nil && appDownlink.Expired(time.Time(*appDownlink.EarliestAt)) {
+		return errors.NewErrInvalidArgument("Downlink LatestAt", "before EarliestAt")
+	}
+
+	release, err := h.takeDownlinkQuota(appID, devID, time.Now())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			release()
+		}
+	}()
+
+	// The ID can be used to refer to the downlink later
+	appDownlink.ID = random.String(16)
+	enqueuedAt := types.JSONTime(time.Now())
+	appDownlink.EnqueuedAt = &enqueuedAt
+
 	// Clear redundant fields
 	appDownlink.AppID = ""
 	appDownlink.DevID = ""
@@ -94,6 +126,10 @@ func (h *handler) EnqueueDownlink(appDownlink *types.DownlinkMessage) (err error
 			Message: appDownlink,
 		},
 	}
+	h.publishDownlinkStatus(appID, devID, types.DownlinkStatusEventData{
+		ID:     appDownlink.ID,
+		Status: types.DownlinkStatusQueued,
+	})
 	return nil
 }
 
@@ -119,6 +155,11 @@ func (h *handler) HandleDownlink(appDownlink *types.DownlinkMessage, downlink *p
 					Message:        appDownlink,
 				},
 			}
+			h.publishDownlinkStatus(appID, devID, types.DownlinkStatusEventData{
+				ErrorEventData: types.ErrorEventData{Error: err.Error()},
+				ID:             appDownlink.ID,
+				Status:         types.DownlinkStatusFailed,
+			})
 			ctx.WithError(err).Warn("Could not handle downlink")
 			downlink.Trace = downlink.Trace.WithEvent(trace.DropEvent, "reason", err)
 		} else {
@@ -194,5 +235,25 @@ func (h *handler) HandleDownlink(appDownlink *types.DownlinkMessage, downlink *p
 			Config:    downlinkConfig,
 		},
 	}
+	h.publishDownlinkStatus(appID, devID, types.DownlinkStatusEventData{
+		ID:        appDownlink.ID,
+		Status:    types.DownlinkStatusSent,
+		GatewayID: downlink.DownlinkOption.GatewayID,
+	})
 	return nil
 }
+
+// publishDownlinkStatus publishes a down/status event for the downlink, if it has an ID. Downlinks that
+// are not enqueued by the application (such as empty downlinks for MAC commands) do not have an ID.
+func (h *handler) publishDownlinkStatus(appID, devID string, status types.DownlinkStatusEventData) {
+	if status.ID == "" {
+		return
+	}
+	status.Time = types.JSONTime(time.Now())
+	h.qEvent <- &types.DeviceEvent{
+		AppID: appID,
+		DevID: devID,
+		Event: types.DownlinkStatusEvent,
+		Data:  status,
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// DownlinkQueue returns the downlinks of the device that are queued, or that are sent but not yet acknowledged
+func (h *handler) DownlinkQueue(appID, devID string) ([]types.DownlinkQueueItem, error) {
+	dev, err := h.devices.Get(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := h.devices.DownlinkQueue(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	queued, err := queue.List()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]types.DownlinkQueueItem, 0, len(queued)+1)
+	if dev.CurrentDownlink != nil {
+		items = append(items, dev.CurrentDownlink.QueueItem(types.DownlinkStateSent))
+	}
+	now := time.Now()
+	for _, msg := range queued {
+		state := types.DownlinkStateQueued
+		switch {
+		case msg.Expired(now):
+			state = types.DownlinkStateExpired
+		case msg.Pending(now):
+			state = types.DownlinkStatePending
+		}
+		items = append(items, msg.QueueItem(state))
+	}
+	return items, nil
+}
+
+// CancelDownlink removes the downlink with the given ID from the queue of the device. If the downlink was
+// already sent, the Handler stops waiting for its acknowledgement.
+func (h *handler) CancelDownlink(appID, devID, downlinkID string) error {
+	if downlinkID == "" {
+		return errors.NewErrInvalidArgument("Downlink ID", "empty")
+	}
+	cancelled, err := h.devices.CancelDownlink(appID, devID, downlinkID)
+	if err != nil {
+		return err
+	}
+	if cancelled == nil {
+		return errors.NewErrNotFound(fmt.Sprintf("Downlink %s", downlinkID))
+	}
+
+	h.Ctx.WithFields(ttnlog.Fields{
+		"AppID":      appID,
+		"DevID":      devID,
+		"DownlinkID": downlinkID,
+	}).Debug("Cancelled downlink")
+	for _, event := range CancelledDownlinkEvents(appID, devID, cancelled) {
+		h.qEvent <- event
+	}
+	return nil
+}
+
+// FlushDownlinkQueue removes all downlinks from the queue of the device, including the downlink that was
+// sent but not yet acknowledged
+func (h *handler) FlushDownlinkQueue(appID, devID string) error {
+	queued, err := h.devices.FlushDownlinks(appID, devID)
+	if err != nil {
+		return err
+	}
+
+	h.Ctx.WithFields(ttnlog.Fields{
+		"AppID":     appID,
+		"DevID":     devID,
+		"Cancelled": len(queued),
+	}).Debug("Flushed downlink queue")
+	for _, event := range CancelledDownlinkEvents(appID, devID, queued...) {
+		h.qEvent <- event
+	}
+	return nil
+}
+
+// CancelledDownlinkEvents returns the down/cancelled events and the down/status events of cancelled downlinks
+func CancelledDownlinkEvents(appID, devID string, cancelled ...*types.DownlinkMessage) []*types.DeviceEvent {
+	now := types.JSONTime(time.Now())
+	events := make([]*types.DeviceEvent, 0, 2*len(cancelled))
+	for _, msg := range cancelled {
+		events = append(events, &types.DeviceEvent{
+			AppID: appID,
+			DevID: devID,
+			Event: types.DownlinkCancelledEvent,
+			Data:  types.DownlinkEventData{Message: msg},
+		})
+		if msg.ID == "" {
+			continue
+		}
+		events = append(events, &types.DeviceEvent{
+			AppID: appID,
+			DevID: devID,
+			Event: types.DownlinkStatusEvent,
+			Data: types.DownlinkStatusEventData{
+				ID:     msg.ID,
+				Status: types.DownlinkStatusCancelled,
+				Time:   now,
+			},
+		})
+	}
+	return events
+}
+
+// publishDownlinkQueue publishes the downlink queue of the device as an event
+func (h *handler) publishDownlinkQueue(appID, devID string) error {
+	items, err := h.DownlinkQueue(appID, devID)
+	if err != nil {
+		return err
+	}
+	h.qEvent <- &types.DeviceEvent{
+		AppID: appID,
+		DevID: devID,
+		Event: types.DownlinkQueueEvent,
+		Data:  types.DownlinkQueueEventData{Queue: items},
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDownlinkQueue(t *testing.T) {
+	a := New(t)
+	appID := "app1"
+	devID := "dev1"
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestDownlinkQueue")},
+		devices:   device.NewRedisDeviceStore(GetRedisClient(), "handler-test-downlink-queue"),
+		qEvent:    make(chan *types.DeviceEvent, 10),
+	}
+
+	_, err := h.DownlinkQueue(appID, devID)
+	a.So(err, ShouldNotBeNil)
+
+	h.devices.Set(&device.Device{
+		AppID:           appID,
+		DevID:           devID,
+		CurrentDownlink: &types.DownlinkMessage{ID: "sent", FPort: 1, PayloadRaw: []byte{1, 2, 3, 4}},
+	})
+	defer func() {
+		h.devices.Delete(appID, devID)
+	}()
+
+	earliestAt := types.JSONTime(time.Now().Add(time.Hour))
+	for _, msg := range []*types.DownlinkMessage{
+		{AppID: appID, DevID: devID, FPort: 2, PayloadRaw: []byte{1, 2}, Schedule: types.ScheduleLast},
+		{AppID: appID, DevID: devID, FPort: 3, PayloadRaw: []byte{1}, Schedule: types.ScheduleLast, EarliestAt: &earliestAt},
+	} {
+		a.So(h.EnqueueDownlink(msg), ShouldBeNil)
+	}
+	for len(h.qEvent) > 0 {
+		<-h.qEvent
+	}
+
+	items, err := h.DownlinkQueue(appID, devID)
+	a.So(err, ShouldBeNil)
+	a.So(items, ShouldHaveLength, 3)
+	a.So(items[0].ID, ShouldEqual, "sent")
+	a.So(items[0].State, ShouldEqual, types.DownlinkStateSent)
+	a.So(items[0].Size, ShouldEqual, 4)
+	a.So(items[1].ID, ShouldNotBeEmpty)
+	a.So(items[1].FPort, ShouldEqual, 2)
+	a.So(items[1].EnqueuedAt, ShouldNotBeNil)
+	a.So(items[1].State, ShouldEqual, types.DownlinkStateQueued)
+	a.So(items[2].State, ShouldEqual, types.DownlinkStatePending)
+
+	// Cancel a queued downlink
+	a.So(h.CancelDownlink(appID, devID, items[1].ID), ShouldBeNil)
+	event := <-h.qEvent
+	a.So(event.Event, ShouldEqual, types.DownlinkCancelledEvent)
+	event = <-h.qEvent
+	a.So(event.Event, ShouldEqual, types.DownlinkStatusEvent)
+	a.So(event.Data.(types.DownlinkStatusEventData).ID, ShouldEqual, items[1].ID)
+	a.So(event.Data.(types.DownlinkStatusEventData).Status, ShouldEqual, types.DownlinkStatusCancelled)
+	a.So(h.CancelDownlink(appID, devID, items[1].ID), ShouldNotBeNil)
+
+	// Cancel the downlink that was sent
+	a.So(h.CancelDownlink(appID, devID, "sent"), ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 2)
+	for len(h.qEvent) > 0 {
+		<-h.qEvent
+	}
+	dev, _ := h.devices.Get(appID, devID)
+	a.So(dev.CurrentDownlink, ShouldBeNil)
+
+	items, _ = h.DownlinkQueue(appID, devID)
+	a.So(items, ShouldHaveLength, 1)
+
+	// Flush the queue
+	a.So(h.FlushDownlinkQueue(appID, devID), ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 2)
+	for len(h.qEvent) > 0 {
+		<-h.qEvent
+	}
+	items, _ = h.DownlinkQueue(appID, devID)
+	a.So(items, ShouldBeEmpty)
+
+	// Publish the queue as event
+	a.So(h.publishDownlinkQueue(appID, devID), ShouldBeNil)
+	event = <-h.qEvent
+	a.So(event.Event, ShouldEqual, types.DownlinkQueueEvent)
+}
@@ -0,0 +1,69 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+)
+
+func (h *handler) WithDownlinkQuota(perDevice uint) Handler {
+	h.downlinkQuota = perDevice
+	return h
+}
+
+// SetDownlinkQuota replaces the downlink quota of the application
+func (h *handler) SetDownlinkQuota(appID string, quota *application.DownlinkQuota) error {
+	if quota.IsEmpty() {
+		quota = nil
+	}
+	app, err := h.applications.Get(appID)
+	if err != nil {
+		return err
+	}
+	app.StartUpdate()
+	app.DownlinkQuota = quota
+	return h.applications.Set(app)
+}
+
+// takeDownlinkQuota takes a downlink from the daily quotas of the device and its application. The
+// returned function gives the downlink back, for when it could not be enqueued after all.
+func (h *handler) takeDownlinkQuota(appID, devID string, now time.Time) (release func(), err error) {
+	release = func() {}
+	if h.downlinkQuotas == nil {
+		return release, nil
+	}
+
+	perDevice, perApplication := h.downlinkQuota, uint(0)
+	if app, err := h.applications.Get(appID); err == nil && app.DownlinkQuota != nil {
+		if app.DownlinkQuota.PerDevice != 0 {
+			perDevice = app.DownlinkQuota.PerDevice
+		}
+		perApplication = app.DownlinkQuota.PerApplication
+	}
+
+	var taken []string
+	for subject, limit := range map[string]uint{
+		"devices/" + appID + "/" + devID: perDevice,
+		"applications/" + appID:          perApplication,
+	} {
+		if limit == 0 {
+			continue
+		}
+		if err := h.downlinkQuotas.Take(subject, limit, now); err != nil {
+			for _, subject := range taken {
+				h.downlinkQuotas.Release(subject, now)
+			}
+			return release, err
+		}
+		taken = append(taken, subject)
+	}
+
+	return func() {
+		for _, subject := range taken {
+			h.downlinkQuotas.Release(subject, now)
+		}
+	}, nil
+}
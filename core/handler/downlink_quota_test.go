@@ -0,0 +1,89 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/quota"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDownlinkQuota(t *testing.T) {
+	a := New(t)
+	client := GetRedisClient()
+	h := &handler{
+		Component:      &component.Component{Ctx: GetLogger(t, "TestDownlinkQuota")},
+		devices:        device.NewRedisDeviceStore(client, "handler-test-downlink-quota"),
+		applications:   application.NewRedisApplicationStore(client, "handler-test-downlink-quota"),
+		qEvent:         make(chan *types.DeviceEvent, 10),
+		downlinkQuotas: quota.NewRedisCounter(client, "handler-test-downlink-quota"),
+	}
+	defer func() {
+		keys, _ := client.Keys("handler-test-downlink-quota:downlink-quota:*").Result()
+		for _, key := range keys {
+			client.Del(key)
+		}
+	}()
+
+	appID := "app"
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+	for _, devID := range []string{"dev1", "dev2"} {
+		h.devices.Set(&device.Device{AppID: appID, DevID: devID})
+		defer h.devices.Delete(appID, devID)
+	}
+
+	enqueue := func(devID string) error {
+		return h.EnqueueDownlink(&types.DownlinkMessage{
+			AppID:      appID,
+			DevID:      devID,
+			PayloadRaw: []byte{0x01},
+			Schedule:   types.ScheduleLast,
+		})
+	}
+
+	// Without quota
+	a.So(enqueue("dev1"), ShouldBeNil)
+	a.So(enqueue("dev1"), ShouldBeNil)
+
+	// Handler-wide quota per device
+	h.WithDownlinkQuota(3)
+	a.So(enqueue("dev1"), ShouldBeNil)
+	a.So(enqueue("dev1"), ShouldBeNil)
+	a.So(enqueue("dev1"), ShouldBeNil)
+	err := enqueue("dev1")
+	a.So(err, ShouldHaveSameTypeAs, &quota.ErrExceeded{})
+
+	for len(h.qEvent) > 0 {
+		evt := <-h.qEvent
+		if evt.Event == types.DownlinkErrorEvent {
+			data := evt.Data.(types.DownlinkEventData)
+			a.So(data.NextDownlinkAt, ShouldNotBeNil)
+			a.So(time.Time(*data.NextDownlinkAt), ShouldResemble, quota.NextDay(time.Now()))
+		}
+	}
+
+	// Application quota
+	err = h.SetDownlinkQuota(appID, &application.DownlinkQuota{PerDevice: 5, PerApplication: 2})
+	a.So(err, ShouldBeNil)
+	a.So(enqueue("dev1"), ShouldBeNil)
+	a.So(enqueue("dev2"), ShouldBeNil)
+	a.So(enqueue("dev2"), ShouldNotBeNil) // The application quota is reached
+
+	// The device quota is not taken by downlinks that the application quota rejected
+	h.SetDownlinkQuota(appID, &application.DownlinkQuota{PerDevice: 2})
+	a.So(enqueue("dev2"), ShouldBeNil)
+	a.So(enqueue("dev2"), ShouldNotBeNil)
+
+	a.So(h.SetDownlinkQuota(appID, &application.DownlinkQuota{}), ShouldBeNil)
+	app, _ := h.applications.Get(appID)
+	a.So(app.DownlinkQuota, ShouldBeNil)
+}
@@ -91,6 +91,33 @@ func TestEnqueueDownlink(t *testing.T) {
 	downlink, _ := queue.Next()
 	a.So(downlink, ShouldNotBeNil)
 	a.So(downlink.PayloadFields, ShouldHaveLength, 3)
+	a.So(downlink.ID, ShouldNotBeEmpty)
+
+	var queued int
+	for len(h.qEvent) > 0 {
+		if evt := <-h.qEvent; evt.Event == types.DownlinkStatusEvent {
+			a.So(evt.Data.(types.DownlinkStatusEventData).Status, ShouldEqual, types.DownlinkStatusQueued)
+			queued++
+		}
+	}
+	a.So(queued, ShouldEqual, 3)
+
+	past := types.JSONTime(time.Now().Add(-1 * time.Minute))
+	err = h.EnqueueDownlink(&types.DownlinkMessage{
+		AppID:      appID,
+		DevID:      devID,
+		PayloadRaw: []byte{0x01},
+		LatestAt:   &past,
+	})
+	a.So(err, ShouldNotBeNil)
+
+	err = h.EnqueueDownlink(&types.DownlinkMessage{
+		AppID:      appID,
+		DevID:      devID,
+		PayloadRaw: []byte{0x01},
+		Priority:   "urgent",
+	})
+	a.So(err, ShouldNotBeNil)
 }
 
 func TestHandleDownlink(t *testing.T) {
@@ -0,0 +1,188 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package export moves the state of devices between handler instances in encrypted bundles, so that devices can be
+// migrated without joining again
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	nsdevice "github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// BundleVersion is the version of the bundle format
+const BundleVersion = 1
+
+// KeySize is the size of the key that bundles are encrypted with (AES-256)
+const KeySize = 32
+
+var magic = []byte("TTNDEV")
+
+// Bundle contains the state of a device
+type Bundle struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+
+	// Device is the device in the handler, with its session keys, counters and attributes
+	Device *device.Device `json:"device"`
+	// Downlinks are the downlinks in the queue of the device
+	Downlinks []*types.DownlinkMessage `json:"downlinks,omitempty"`
+	// NetworkServer is the device in the NetworkServer, with its frame counters and MAC state
+	NetworkServer *nsdevice.Device `json:"network_server,omitempty"`
+}
+
+// Export the state of the device. The NetworkServer state is only exported if nsStore is not nil.
+func Export(store device.Store, nsStore nsdevice.Store, appID, devID string) (*Bundle, error) {
+	dev, err := store.Get(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &Bundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Device:     dev,
+	}
+	queue, err := store.DownlinkQueue(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Downlinks, err = queue.List(); err != nil {
+		return nil, err
+	}
+	if nsStore != nil {
+		if bundle.NetworkServer, err = nsStore.Get(dev.AppEUI, dev.DevEUI); err != nil {
+			return nil, err
+		}
+	}
+	return bundle, nil
+}
+
+// Import the state of the device. Existing devices are only replaced if replace is true. The NetworkServer state
+// is only imported if nsStore is not nil.
+func Import(bundle *Bundle, store device.Store, nsStore nsdevice.Store, replace bool) error {
+	if bundle.Version != BundleVersion {
+		return errors.NewErrInvalidArgument("Bundle", fmt.Sprintf("unsupported version %d (expected %d)", bundle.Version, BundleVersion))
+	}
+	dev := bundle.Device
+	if dev == nil {
+		return errors.NewErrInvalidArgument("Bundle", "does not contain a device")
+	}
+	if nsStore != nil && bundle.NetworkServer == nil {
+		return errors.NewErrInvalidArgument("Bundle", "does not contain the NetworkServer state")
+	}
+
+	if _, err := store.Get(dev.AppID, dev.DevID); err == nil {
+		if !replace {
+			return errors.NewErrAlreadyExists(fmt.Sprintf("Device %s/%s", dev.AppID, dev.DevID))
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	if nsStore != nil {
+		if err := nsStore.Set(bundle.NetworkServer); err != nil {
+			return err
+		}
+	}
+	if err := store.Set(dev); err != nil {
+		return err
+	}
+	queue, err := store.DownlinkQueue(dev.AppID, dev.DevID)
+	if err != nil {
+		return err
+	}
+	if err := queue.Clear(); err != nil {
+		return err
+	}
+	for _, msg := range bundle.Downlinks {
+		if err := queue.PushLast(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateKey generates a random key to encrypt bundles with
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, errors.NewErrInvalidArgument("Key", fmt.Sprintf("must be %d bytes", KeySize))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal compresses the bundle and encrypts it with AES-GCM
+func Seal(bundle *Bundle, key []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	var plaintext bytes.Buffer
+	gz := gzip.NewWriter(&plaintext)
+	if err := json.NewEncoder(gz).Encode(bundle); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, magic...), nonce...)
+	return gcm.Seal(out, nonce, plaintext.Bytes(), magic), nil
+}
+
+// Open decrypts a sealed bundle
+func Open(data, key []byte) (*Bundle, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < len(magic)+gcm.NonceSize() || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, errors.NewErrInvalidArgument("Bundle", "is not a device bundle")
+	}
+	nonce := data[len(magic) : len(magic)+gcm.NonceSize()]
+	plaintext, err := gcm.Open(nil, nonce, data[len(magic)+gcm.NonceSize():], magic)
+	if err != nil {
+		return nil, errors.NewErrInvalidArgument("Bundle", "could not be decrypted with this key")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	uncompressed, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	var bundle Bundle
+	if err := json.Unmarshal(uncompressed, &bundle); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
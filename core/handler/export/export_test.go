@@ -0,0 +1,89 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package export
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	nsdevice "github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestExportImport(t *testing.T) {
+	a := New(t)
+
+	appEUI := types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8}
+	devEUI := types.DevEUI{8, 7, 6, 5, 4, 3, 2, 1}
+
+	from := device.NewRedisDeviceStore(GetRedisClient(), "handler-test-export-from")
+	fromNS := nsdevice.NewRedisDeviceStore(GetRedisClient(), "ns-test-export-from")
+	from.Set(&device.Device{
+		AppID:      "app",
+		DevID:      "dev",
+		AppEUI:     appEUI,
+		DevEUI:     devEUI,
+		DevAddr:    types.DevAddr{1, 2, 3, 4},
+		AppSKey:    types.AppSKey{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1},
+		FCntUp:     42,
+		Attributes: map[string]string{"room": "kitchen"},
+	})
+	defer from.Delete("app", "dev")
+	queue, _ := from.DownlinkQueue("app", "dev")
+	queue.PushLast(&types.DownlinkMessage{PayloadRaw: []byte{1, 2, 3}})
+	fromNS.Set(&nsdevice.Device{AppEUI: appEUI, DevEUI: devEUI, AppID: "app", DevID: "dev", DevAddr: types.DevAddr{1, 2, 3, 4}, FCntUp: 42, FCntDown: 7})
+	defer fromNS.Delete(appEUI, devEUI)
+
+	bundle, err := Export(from, fromNS, "app", "dev")
+	a.So(err, ShouldBeNil)
+	a.So(bundle.Downlinks, ShouldHaveLength, 1)
+	a.So(bundle.NetworkServer.FCntDown, ShouldEqual, 7)
+
+	key, err := GenerateKey()
+	a.So(err, ShouldBeNil)
+	sealed, err := Seal(bundle, key)
+	a.So(err, ShouldBeNil)
+
+	otherKey, _ := GenerateKey()
+	_, err = Open(sealed, otherKey)
+	a.So(err, ShouldNotBeNil)
+	_, err = Open([]byte("not a bundle"), key)
+	a.So(err, ShouldNotBeNil)
+	_, err = Seal(bundle, key[:16])
+	a.So(err, ShouldNotBeNil)
+
+	opened, err := Open(sealed, key)
+	a.So(err, ShouldBeNil)
+
+	to := device.NewRedisDeviceStore(GetRedisClient(), "handler-test-export-to")
+	toNS := nsdevice.NewRedisDeviceStore(GetRedisClient(), "ns-test-export-to")
+	err = Import(opened, to, toNS, false)
+	a.So(err, ShouldBeNil)
+	defer to.Delete("app", "dev")
+	defer toNS.Delete(appEUI, devEUI)
+
+	dev, err := to.Get("app", "dev")
+	a.So(err, ShouldBeNil)
+	a.So(dev.AppSKey, ShouldEqual, types.AppSKey{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	a.So(dev.FCntUp, ShouldEqual, 42)
+	a.So(dev.Attributes, ShouldResemble, map[string]string{"room": "kitchen"})
+	queue, _ = to.DownlinkQueue("app", "dev")
+	downlinks, _ := queue.List()
+	a.So(downlinks, ShouldHaveLength, 1)
+	a.So(downlinks[0].PayloadRaw, ShouldResemble, []byte{1, 2, 3})
+	nsDev, err := toNS.Get(appEUI, devEUI)
+	a.So(err, ShouldBeNil)
+	a.So(nsDev.FCntDown, ShouldEqual, 7)
+
+	// The device already exists
+	err = Import(opened, to, toNS, false)
+	a.So(errors.GetErrType(err), ShouldEqual, errors.AlreadyExists)
+	err = Import(opened, to, toNS, true)
+	a.So(err, ShouldBeNil)
+	downlinks, _ = queue.List()
+	a.So(downlinks, ShouldHaveLength, 1)
+}
@@ -0,0 +1,73 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"golang.org/x/net/context"
+)
+
+// ConfirmFCntResetKey is the key in the request metadata that confirms that SetDevice may lower the frame counters
+// of the session of a device, for example after an ABP device was reflashed
+const ConfirmFCntResetKey = "confirm-fcnt-reset"
+
+// fCntResetConfirmedFromIncomingContext returns whether the request metadata confirms a reset of the frame counters
+func fCntResetConfirmedFromIncomingContext(ctx context.Context) bool {
+	md := ttnctx.MetadataFromIncomingContext(ctx)
+	if values := md[ConfirmFCntResetKey]; len(values) > 0 {
+		confirmed, _ := strconv.ParseBool(values[0])
+		return confirmed
+	}
+	return false
+}
+
+// guardFCntReset protects the frame counters of the session of a device against being lowered by an update, which
+// would allow replays of earlier frames of the session. Clients that update a device with the counters that they
+// got earlier would otherwise reset the counters without knowing. Updates without counters do not change them, so
+// the current counters are kept. If the reset is confirmed, it emits a counter reset event. Otherwise, it returns an
+// ErrFailedPrecondition and the update must not be applied.
+func (h *handler) guardFCntReset(dev *device.Device, current, update *pb_lorawan.Device, confirmed bool, by string) error {
+	if update.FCntUp >= current.FCntUp && update.FCntDown >= current.FCntDown {
+		return nil
+	}
+	if !confirmed && update.FCntUp == 0 && update.FCntDown == 0 {
+		update.FCntUp, update.FCntDown = current.FCntUp, current.FCntDown
+		return nil
+	}
+	ctx := h.Ctx.WithFields(ttnlog.Fields{
+		"AppID":       dev.AppID,
+		"DevID":       dev.DevID,
+		"FCntUp":      current.FCntUp,
+		"FCntDown":    current.FCntDown,
+		"NewFCntUp":   update.FCntUp,
+		"NewFCntDown": update.FCntDown,
+	})
+	if !confirmed {
+		ctx.Warn("Refusing to lower frame counters of device: reset not confirmed")
+		return errors.NewErrFailedPrecondition("lowering the frame counters of the session must be confirmed with " + ConfirmFCntResetKey)
+	}
+	ctx.WithField("By", by).Warn("Reset frame counters of device")
+	h.qEvent <- &types.DeviceEvent{
+		AppID: dev.AppID,
+		DevID: dev.DevID,
+		Event: types.FCntResetEvent,
+		Data: types.FCntResetEventData{
+			FCntUp:      current.FCntUp,
+			FCntDown:    current.FCntDown,
+			NewFCntUp:   update.FCntUp,
+			NewFCntDown: update.FCntDown,
+			By:          by,
+			Time:        types.JSONTime(time.Now().UTC()),
+		},
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFCntResetConfirmed(t *testing.T) {
+	a := New(t)
+	a.So(fCntResetConfirmedFromIncomingContext(context.Background()), ShouldBeFalse)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ConfirmFCntResetKey, "true"))
+	a.So(fCntResetConfirmedFromIncomingContext(ctx), ShouldBeTrue)
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(ConfirmFCntResetKey, "nope"))
+	a.So(fCntResetConfirmedFromIncomingContext(ctx), ShouldBeFalse)
+}
+
+func TestGuardFCntReset(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestGuardFCntReset")},
+		qEvent:    make(chan *types.DeviceEvent, 10),
+	}
+	dev := &device.Device{AppID: "app", DevID: "dev"}
+	current := &pb_lorawan.Device{FCntUp: 1234, FCntDown: 56}
+
+	// Counters that are not lowered are not a reset
+	update := &pb_lorawan.Device{FCntUp: 1300, FCntDown: 56}
+	a.So(h.guardFCntReset(dev, current, update, false, "user"), ShouldBeNil)
+	a.So(update.FCntUp, ShouldEqual, 1300)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	// Updates without counters keep the current counters
+	update = &pb_lorawan.Device{}
+	a.So(h.guardFCntReset(dev, current, update, false, "user"), ShouldBeNil)
+	a.So(update.FCntUp, ShouldEqual, 1234)
+	a.So(update.FCntDown, ShouldEqual, 56)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	// Without confirmation, the update is refused
+	update = &pb_lorawan.Device{FCntUp: 0, FCntDown: 60}
+	err := h.guardFCntReset(dev, current, update, false, "user")
+	a.So(errors.IsFailedPrecondition(err), ShouldBeTrue)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	// A confirmed reset is reported
+	update = &pb_lorawan.Device{FCntUp: 0, FCntDown: 0}
+	a.So(h.guardFCntReset(dev, current, update, true, "user"), ShouldBeNil)
+	a.So(update.FCntUp, ShouldEqual, 0)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	evt := <-h.qEvent
+	a.So(evt.Event, ShouldEqual, types.FCntResetEvent)
+	data := evt.Data.(types.FCntResetEventData)
+	a.So(data.FCntUp, ShouldEqual, 1234)
+	a.So(data.NewFCntUp, ShouldEqual, 0)
+	a.So(data.By, ShouldEqual, "user")
+}
@@ -0,0 +1,114 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package filter contains the rules that applications can configure to reduce the uplinks that are published
+package filter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Action is what a rule does with the uplinks it applies to
+type Action string
+
+const (
+	// ActionDrop drops all uplinks
+	ActionDrop Action = "drop"
+	// ActionCollapse drops uplinks with the same FPort and payload as the previous uplink of the device
+	ActionCollapse Action = "collapse"
+	// ActionThrottle drops uplinks that arrive within Interval seconds after the last published uplink of the device
+	ActionThrottle Action = "throttle"
+)
+
+// Rule drops uplinks before they are published
+type Rule struct {
+	ID       string  `json:"id"`
+	Action   Action  `json:"action"`
+	FPorts   []uint8 `json:"f_ports,omitempty"`  // the rule applies to all FPorts if empty
+	Interval float64 `json:"interval,omitempty"` // seconds
+}
+
+// Validate the rule
+func (r Rule) Validate() error {
+	if r.ID == "" {
+		return errors.NewErrInvalidArgument("Rule ID", "can not be empty")
+	}
+	switch r.Action {
+	case ActionDrop:
+		if len(r.FPorts) == 0 {
+			return errors.NewErrInvalidArgument("Rule FPorts", "can not be empty for drop rules")
+		}
+	case ActionCollapse:
+	case ActionThrottle:
+		if r.Interval <= 0 {
+			return errors.NewErrInvalidArgument("Rule Interval", "must be a positive number of seconds")
+		}
+	default:
+		return errors.NewErrInvalidArgument("Rule Action", fmt.Sprintf("unknown action %s", r.Action))
+	}
+	return nil
+}
+
+// AppliesTo returns true if the rule applies to uplinks on the FPort
+func (r Rule) AppliesTo(fPort uint8) bool {
+	if len(r.FPorts) == 0 {
+		return true
+	}
+	for _, p := range r.FPorts {
+		if p == fPort {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRules validates the rules and checks that their IDs are unique
+func ValidateRules(rules []Rule) error {
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if ids[rule.ID] {
+			return errors.NewErrInvalidArgument("Rule ID", fmt.Sprintf("%s is not unique", rule.ID))
+		}
+		ids[rule.ID] = true
+	}
+	return nil
+}
+
+// State is the state of the rules for a device
+type State struct {
+	LastPayload   string    `json:"last_payload,omitempty"` // FPort and payload of the last uplink
+	LastPublished time.Time `json:"last_published,omitempty"`
+}
+
+// Evaluate evaluates the rules in order on an uplink of the device and updates the state. It returns the rule that
+// drops the uplink, or nil if the uplink should be published.
+func Evaluate(rules []Rule, state *State, fPort uint8, payload []byte, now time.Time) *Rule {
+	payloadStr := fmt.Sprintf("%d:%X", fPort, payload)
+	defer func() { state.LastPayload = payloadStr }()
+
+	for i, rule := range rules {
+		if !rule.AppliesTo(fPort) {
+			continue
+		}
+		switch rule.Action {
+		case ActionDrop:
+			return &rules[i]
+		case ActionCollapse:
+			if state.LastPayload == payloadStr {
+				return &rules[i]
+			}
+		case ActionThrottle:
+			if !state.LastPublished.IsZero() && now.Sub(state.LastPublished) < time.Duration(rule.Interval*float64(time.Second)) {
+				return &rules[i]
+			}
+		}
+	}
+	state.LastPublished = now
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package filter
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRuleValidate(t *testing.T) {
+	a := New(t)
+
+	a.So(Rule{}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "drop", Action: "ignore"}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "drop", Action: ActionDrop}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "drop", Action: ActionDrop, FPorts: []uint8{100}}.Validate(), ShouldBeNil)
+	a.So(Rule{ID: "collapse", Action: ActionCollapse}.Validate(), ShouldBeNil)
+	a.So(Rule{ID: "throttle", Action: ActionThrottle}.Validate(), ShouldNotBeNil)
+	a.So(Rule{ID: "throttle", Action: ActionThrottle, Interval: 60}.Validate(), ShouldBeNil)
+
+	collapse := Rule{ID: "collapse", Action: ActionCollapse}
+	a.So(ValidateRules([]Rule{collapse}), ShouldBeNil)
+	a.So(ValidateRules([]Rule{collapse, collapse}), ShouldNotBeNil)
+}
+
+func TestEvaluate(t *testing.T) {
+	a := New(t)
+
+	rules := []Rule{
+		{ID: "debug", Action: ActionDrop, FPorts: []uint8{100}},
+		{ID: "collapse", Action: ActionCollapse, FPorts: []uint8{1}},
+		{ID: "throttle", Action: ActionThrottle, Interval: 60},
+	}
+	var state State
+	now := time.Now()
+
+	a.So(Evaluate(rules, &state, 100, []byte{1}, now), ShouldResemble, &rules[0])
+
+	a.So(Evaluate(rules, &state, 1, []byte{1, 2}, now), ShouldBeNil)
+	a.So(state.LastPublished, ShouldEqual, now)
+
+	// Same payload
+	a.So(Evaluate(rules, &state, 1, []byte{1, 2}, now.Add(2*time.Minute)), ShouldResemble, &rules[1])
+
+	// Within the throttle interval
+	a.So(Evaluate(rules, &state, 1, []byte{1, 3}, now.Add(30*time.Second)), ShouldResemble, &rules[2])
+	a.So(Evaluate(rules, &state, 2, []byte{1, 3}, now.Add(30*time.Second)), ShouldResemble, &rules[2])
+
+	// The collapse rule does not apply to FPort 2
+	a.So(Evaluate(rules, &state, 2, []byte{1, 3}, now.Add(2*time.Minute)), ShouldBeNil)
+	a.So(state.LastPublished, ShouldEqual, now.Add(2*time.Minute))
+}
@@ -13,10 +13,17 @@ import (
 	"github.com/TheThingsNetwork/api/monitor/monitorclient"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/go-utils/grpc/auth"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/amqp"
 	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
 	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/claim"
 	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
+	"github.com/TheThingsNetwork/ttn/core/handler/joinhook"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
+	"github.com/TheThingsNetwork/ttn/core/handler/quota"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/mqtt"
 	"google.golang.org/grpc"
@@ -31,11 +38,37 @@ type Handler interface {
 	WithMQTT(username, password string, brokers ...string) Handler
 	WithAMQP(username, password, host, exchange string) Handler
 	WithDeviceAttributes(attribute ...string) Handler
+	WithDeviceHeartbeat(interval time.Duration) Handler
+	WithSilentEvents(factor float64) Handler
+	WithAnomalyDetection(threshold float64) Handler
+	WithJoinRetransmissionWindow(window time.Duration) Handler
+	WithAlertNotifier(scheme string, notifier alert.Notifier) Handler
+	WithDownlinkQuota(perDevice uint) Handler
+	WithUplinkWorkers(workers int) Handler
+	WithUplinkQueue(perDevice, total int) Handler
+	WithSecureElementApps(appIDs ...string) Handler
+	WithSalvageStorage() Handler
 
 	HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) error
 	HandleActivationChallenge(challenge *pb_broker.ActivationChallengeRequest) (*pb_broker.ActivationChallengeResponse, error)
 	HandleActivation(activation *pb_broker.DeduplicatedDeviceActivationRequest) (*pb.DeviceActivationResponse, error)
 	EnqueueDownlink(appDownlink *types.DownlinkMessage) error
+	DownlinkQueue(appID, devID string) ([]types.DownlinkQueueItem, error)
+	CancelDownlink(appID, devID, downlinkID string) error
+	FlushDownlinkQueue(appID, devID string) error
+	GetSession(appID, devID string) (*Session, error)
+
+	ProvisionDevice(dev *claim.Device, claimCode string) error
+	ClaimDevice(token, appID, devID string, devEUI types.DevEUI, claimCode string) (*device.Device, error)
+
+	SetAlertRules(appID string, rules []alert.Rule) error
+	SetUplinkFilters(appID string, rules []filter.Rule) error
+	SetJoinWebhook(appID, address string) error
+	SetJoinAcceptSettings(appID string, settings *application.JoinAcceptSettings) error
+	SetDownlinkQuota(appID string, quota *application.DownlinkQuota) error
+
+	SetupMulticastGroup(appID, devID string, group multicast.Group) error
+	DeleteMulticastGroup(appID, devID string, id uint8) error
 }
 
 // NewRedisHandler creates a new Redis-backed Handler
@@ -43,9 +76,19 @@ func NewRedisHandler(client *redis.Client, ttnBrokerID string) Handler {
 	return &handler{
 		devices:      device.NewRedisDeviceStore(client, "handler"),
 		applications: application.NewRedisApplicationStore(client, "handler"),
+		provisioned:  claim.NewRedisClaimStore(client, "handler"),
 		ttnBrokerID:  ttnBrokerID,
 		qUp:          make(chan *types.UplinkMessage),
 		qEvent:       make(chan *types.DeviceEvent),
+		alerts:       alert.NewState(),
+		alertNotifiers: map[string]alert.Notifier{
+			"webhook": alert.NewWebhookNotifier(),
+			"slack":   alert.NewSlackNotifier(nil),
+		},
+		joinHook:                 joinhook.NewClient(),
+		joinRetransmissionWindow: DefaultJoinRetransmissionWindow,
+		downlinkQuotas:           quota.NewRedisCounter(client, "handler"),
+		mailboxes:                newMailboxes(0, DefaultUplinkQueuePerDevice, DefaultUplinkQueueSize),
 	}
 }
 
@@ -54,12 +97,14 @@ type handler struct {
 
 	devices      device.Store
 	applications application.Store
+	provisioned  claim.Store
 
-	ttnBrokerID      string
-	ttnBrokerConn    *grpc.ClientConn
-	ttnBroker        pb_broker.BrokerClient
-	ttnBrokerManager pb_broker.BrokerManagerClient
-	ttnDeviceManager pb_lorawan.DeviceManagerClient
+	ttnBrokerID       string
+	ttnBrokerConn     *grpc.ClientConn
+	ttnBroker         pb_broker.BrokerClient
+	ttnBrokerManager  pb_broker.BrokerManagerClient
+	ttnDeviceManager  pb_lorawan.DeviceManagerClient
+	ttnDevAddrManager pb_lorawan.DevAddrManagerClient
 
 	downlink chan *pb_broker.DownlinkMessage
 
@@ -83,6 +128,23 @@ type handler struct {
 	qUp    chan *types.UplinkMessage
 	qEvent chan *types.DeviceEvent
 
+	heartbeatInterval time.Duration
+	silenceFactor     float64
+	anomalyThreshold  float64
+
+	alerts         *alert.State
+	alertNotifiers map[string]alert.Notifier
+
+	joinHook                 *joinhook.Client
+	joinRetransmissionWindow time.Duration
+
+	downlinkQuota  uint
+	downlinkQuotas quota.Counter
+
+	mailboxes *mailboxes
+
+	secureElementApps map[string]bool
+
 	status        *status
 	monitorStream monitorclient.Stream
 }
@@ -114,6 +176,37 @@ func (h *handler) WithDeviceAttributes(a ...string) Handler {
 	return h
 }
 
+func (h *handler) WithDeviceHeartbeat(interval time.Duration) Handler {
+	h.heartbeatInterval = interval
+	return h
+}
+
+// WithSecureElementApps leaves the MIC check of the devices without NwkSKey in these applications to the Broker,
+// which validates it with the secure element that holds their NwkSKey
+func (h *handler) WithSecureElementApps(appIDs ...string) Handler {
+	h.secureElementApps = make(map[string]bool, len(appIDs))
+	for _, appID := range appIDs {
+		h.secureElementApps[appID] = true
+	}
+	return h
+}
+
+// WithSalvageStorage quarantines corrupted devices, downlink queues and applications instead of failing on them
+func (h *handler) WithSalvageStorage() Handler {
+	if devices, ok := h.devices.(*device.RedisDeviceStore); ok {
+		devices.SetSalvage(true)
+	}
+	if applications, ok := h.applications.(*application.RedisApplicationStore); ok {
+		applications.SetSalvage(true)
+	}
+	return h
+}
+
+func (h *handler) WithJoinRetransmissionWindow(window time.Duration) Handler {
+	h.joinRetransmissionWindow = window
+	return h
+}
+
 func (h *handler) Init(c *component.Component) error {
 	h.Component = c
 	h.InitStatus()
@@ -150,7 +243,12 @@ func (h *handler) Init(c *component.Component) error {
 			select {
 			case up := <-h.qUp:
 				if h.mqttEnabled {
-					h.mqttUp <- up
+					if h.amqpEnabled {
+						// Both adapters run in their own goroutines, so each gets its own copy
+						h.mqttUp <- up.Clone()
+					} else {
+						h.mqttUp <- up
+					}
 				}
 				if h.amqpEnabled {
 					h.amqpUp <- up
@@ -171,6 +269,14 @@ func (h *handler) Init(c *component.Component) error {
 		return err
 	}
 
+	if h.heartbeatInterval > 0 {
+		go h.monitorConnectivity()
+	}
+	if h.silenceFactor > 0 {
+		go h.monitorSilence()
+	}
+	go h.monitorAlerts()
+
 	h.Component.SetStatus(component.StatusHealthy)
 	if h.Component.Monitor != nil {
 		h.monitorStream = h.Component.Monitor.HandlerClient(h.Context, grpc.PerRPCCredentials(auth.WithStaticToken(h.AccessToken)))
@@ -206,6 +312,7 @@ func (h *handler) associateBroker() error {
 	h.ttnBroker = pb_broker.NewBrokerClient(conn)
 	h.ttnBrokerManager = pb_broker.NewBrokerManagerClient(conn)
 	h.ttnDeviceManager = pb_lorawan.NewDeviceManagerClient(conn)
+	h.ttnDevAddrManager = pb_lorawan.NewDevAddrManagerClient(conn)
 
 	h.downlink = make(chan *pb_broker.DownlinkMessage)
 
@@ -216,14 +323,19 @@ func (h *handler) associateBroker() error {
 	association := cli.NewHandlerStreams(h.Identity.ID, "")
 
 	go func() {
-		for {
-			select {
-			case message := <-h.downlink:
-				association.Downlink(message)
-			case message, ok := <-association.Uplink():
-				if ok {
-					go h.HandleUplink(message)
-				}
+		for message := range h.downlink {
+			association.Downlink(message)
+		}
+	}()
+
+	// Uplinks of the same device are handled in order, so that they do not race on the device state
+	go func() {
+		for message := range association.Uplink() {
+			message := message
+			if !h.mailboxes.Post(message.AppID, message.DevID, func() {
+				h.HandleUplink(message)
+			}) {
+				h.Ctx.WithFields(ttnlog.Fields{"AppID": message.AppID, "DevID": message.DevID}).Warn("Uplink queue full, dropping uplink")
 			}
 		}
 	}()
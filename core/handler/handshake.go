@@ -0,0 +1,30 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/handshake"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// handlerHello is what the Handler tells the Broker when it registers an application
+var handlerHello = handshake.Local(handshake.FeatureMulticast)
+
+// handshake checks the Hello that the Broker returned when the Handler registered an application
+func (h *handlerManager) handshake(appID string, md metadata.MD) error {
+	ctx := h.handler.Ctx.WithField("AppID", appID)
+	hello, ok := handshake.FromMetadata(md)
+	if !ok {
+		ctx.Warn("Broker did not answer the handshake, it may run an older version")
+		return nil
+	}
+	agreement, err := handshake.Negotiate(handlerHello, hello)
+	if err != nil {
+		ctx.WithError(err).Error("Broker is incompatible")
+		return errors.Wrap(err, "Broker is incompatible")
+	}
+	ctx.WithField("ProtocolVersion", hello.ProtocolVersion).WithField("PacketFormat", agreement.PacketFormat).WithField("Features", agreement.Features).Debug("Completed handshake with Broker")
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+)
+
+// SetJoinAcceptSettings replaces the join accept settings of the application
+func (h *handler) SetJoinAcceptSettings(appID string, settings *application.JoinAcceptSettings) error {
+	if settings.IsEmpty() {
+		settings = nil
+	}
+	app, err := h.applications.Get(appID)
+	if err != nil {
+		return err
+	}
+	app.StartUpdate()
+	app.JoinAccept = settings
+	return h.applications.Set(app)
+}
+
+// prepareJoinAccept applies the join accept settings of the application and the overrides of its join webhook
+func (h *handler) prepareJoinAccept(activation *pb_broker.DeduplicatedDeviceActivationRequest, dev *device.Device, devNonce device.DevNonce, joinAccept *lorawan.JoinAcceptPayload, metadata *pb_lorawan.ActivationMetadata) error {
+	app, err := h.applications.Get(dev.AppID)
+	if err != nil {
+		return nil // Use the network defaults if application not found
+	}
+
+	settings := []*application.JoinAcceptSettings{app.JoinAccept}
+	if app.JoinWebhook != "" {
+		res, err := h.authorizeJoin(app.JoinWebhook, activation, dev, devNonce, joinAccept)
+		if err != nil {
+			return err
+		}
+		if res.DevAddr != nil && lorawan.DevAddr(*res.DevAddr) != joinAccept.DevAddr {
+			if err := h.checkNetworkDevAddr(*res.DevAddr); err != nil {
+				return err
+			}
+			joinAccept.DevAddr = lorawan.DevAddr(*res.DevAddr)
+		}
+		settings = append(settings, &res.JoinAcceptSettings)
+	}
+
+	fp, err := band.Get(metadata.FrequencyPlan.String())
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		if s.IsEmpty() {
+			continue
+		}
+		if err := s.Validate(&fp); err != nil {
+			return errors.Wrap(err, "Invalid join accept settings")
+		}
+		setJoinAcceptSettings(s, joinAccept, metadata)
+	}
+
+	return nil
+}
+
+// setJoinAcceptSettings overrides the RX settings of the join accept and the activation metadata
+func setJoinAcceptSettings(settings *application.JoinAcceptSettings, joinAccept *lorawan.JoinAcceptPayload, metadata *pb_lorawan.ActivationMetadata) {
+	if settings.RX1DROffset != nil {
+		joinAccept.DLSettings.RX1DROffset = *settings.RX1DROffset
+		metadata.Rx1DROffset = uint32(*settings.RX1DROffset)
+	}
+	if settings.RX2DataRate != nil {
+		joinAccept.DLSettings.RX2DataRate = *settings.RX2DataRate
+		metadata.Rx2DR = uint32(*settings.RX2DataRate)
+	}
+	if settings.RXDelay != nil {
+		joinAccept.RXDelay = *settings.RXDelay
+		metadata.RxDelay = uint32(*settings.RXDelay)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/joinhook"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+type testDevAddrManager struct {
+	prefixes []string
+}
+
+func (m *testDevAddrManager) GetPrefixes(ctx context.Context, in *pb_lorawan.PrefixesRequest, opts ...grpc.CallOption) (*pb_lorawan.PrefixesResponse, error) {
+	res := new(pb_lorawan.PrefixesResponse)
+	for _, prefix := range m.prefixes {
+		res.Prefixes = append(res.Prefixes, &pb_lorawan.PrefixesResponse_PrefixMapping{Prefix: prefix, Usage: []string{"otaa"}})
+	}
+	return res, nil
+}
+
+func (m *testDevAddrManager) GetDevAddr(ctx context.Context, in *pb_lorawan.DevAddrRequest, opts ...grpc.CallOption) (*pb_lorawan.DevAddrResponse, error) {
+	return nil, grpc.Errorf(codes.Unimplemented, "not implemented")
+}
+
+func TestPrepareJoinAccept(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component:    &component.Component{Ctx: GetLogger(t, "TestPrepareJoinAccept")},
+		applications: application.NewRedisApplicationStore(GetRedisClient(), "handler-test-prepare-join-accept"),
+		joinHook:     joinhook.NewClient(),
+
+		ttnDevAddrManager: &testDevAddrManager{prefixes: []string{"26000000/20"}},
+	}
+
+	appID, devID := "app", "dev"
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	dev := &device.Device{AppID: appID, DevID: devID}
+	activation := &pb_broker.DeduplicatedDeviceActivationRequest{}
+	u8 := func(v uint8) *uint8 { return &v }
+	prepare := func() (*lorawan.JoinAcceptPayload, *pb_lorawan.ActivationMetadata, error) {
+		joinAccept := &lorawan.JoinAcceptPayload{DevAddr: lorawan.DevAddr{0x26, 0x01, 0x02, 0x03}, RXDelay: 1}
+		metadata := &pb_lorawan.ActivationMetadata{FrequencyPlan: pb_lorawan.FrequencyPlan_EU_863_870, RxDelay: 1, Rx2DR: 3}
+		err := h.prepareJoinAccept(activation, dev, device.DevNonce{1, 2}, joinAccept, metadata)
+		return joinAccept, metadata, err
+	}
+
+	// Network defaults
+	joinAccept, metadata, err := prepare()
+	a.So(err, ShouldBeNil)
+	a.So(joinAccept.RXDelay, ShouldEqual, 1)
+	a.So(metadata.Rx2DR, ShouldEqual, 3)
+
+	// Application settings
+	err = h.SetJoinAcceptSettings(appID, &application.JoinAcceptSettings{RX2DataRate: u8(0), RXDelay: u8(5)})
+	a.So(err, ShouldBeNil)
+	joinAccept, metadata, err = prepare()
+	a.So(err, ShouldBeNil)
+	a.So(joinAccept.RXDelay, ShouldEqual, 5)
+	a.So(joinAccept.DLSettings.RX2DataRate, ShouldEqual, 0)
+	a.So(metadata.RxDelay, ShouldEqual, 5)
+	a.So(metadata.Rx2DR, ShouldEqual, 0)
+
+	// Settings that are not valid in the frequency plan
+	h.SetJoinAcceptSettings(appID, &application.JoinAcceptSettings{RX2DataRate: u8(7)})
+	_, _, err = prepare()
+	a.So(err, ShouldNotBeNil)
+	h.SetJoinAcceptSettings(appID, nil)
+
+	// Join webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"allow":true,"dev_addr":"26000001","rx1_dr_offset":1}`))
+	}))
+	defer server.Close()
+	h.SetJoinWebhook(appID, server.URL)
+	joinAccept, metadata, err = prepare()
+	a.So(err, ShouldBeNil)
+	a.So(joinAccept.DevAddr, ShouldEqual, lorawan.DevAddr{0x26, 0, 0, 1})
+	a.So(joinAccept.DLSettings.RX1DROffset, ShouldEqual, 1)
+	a.So(metadata.Rx1DROffset, ShouldEqual, 1)
+
+	// DevAddrs outside the prefixes of the Network Server are rejected
+	outside := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"allow":true,"dev_addr":"27000001"}`))
+	}))
+	defer outside.Close()
+	h.SetJoinWebhook(appID, outside.URL)
+	_, _, err = prepare()
+	a.So(err, ShouldNotBeNil)
+
+	denying := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"allow":false}`))
+	}))
+	defer denying.Close()
+	h.SetJoinWebhook(appID, denying.URL)
+	_, _, err = prepare()
+	a.So(err, ShouldNotBeNil)
+}
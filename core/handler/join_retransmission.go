@@ -0,0 +1,52 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb "github.com/TheThingsNetwork/api/handler"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+)
+
+// DefaultJoinRetransmissionWindow is the default window in which a join request with the same DevNonce
+// is considered a retransmission, and gets the same join accept as the original join request. Retransmissions
+// are disabled by default, as a replayed join request then also gets the join accept.
+var DefaultJoinRetransmissionWindow time.Duration
+
+// isJoinRetransmission returns true if the join request is a retransmission of the last join request of the device.
+// Once the device sent an uplink in the new session, it received the join accept, and the join request is a replay.
+func (h *handler) isJoinRetransmission(dev *device.Device, devNonce device.DevNonce) bool {
+	last := dev.LastJoinAccept
+	if h.joinRetransmissionWindow <= 0 || last == nil || last.DevNonce != devNonce {
+		return false
+	}
+	if !dev.LastSeen.Before(last.Time) {
+		return false
+	}
+	return time.Since(last.Time) < h.joinRetransmissionWindow
+}
+
+// retransmitJoinAccept returns the last join accept of the device, so that the device keeps the session that it
+// did not receive yet, and no DevAddr and nonces are used for the retransmitted join request
+func (h *handler) retransmitJoinAccept(ctx ttnlog.Interface, activation *pb_broker.DeduplicatedDeviceActivationRequest, dev *device.Device, metadata *pb_lorawan.ActivationMetadata) *pb.DeviceActivationResponse {
+	ctx.Debug("Retransmitting Join Accept")
+	activation.Trace = activation.Trace.WithEvent("retransmit join accept")
+
+	metadata.DevAddr = &dev.DevAddr
+	metadata.NwkSKey = &dev.NwkSKey
+	metadata.Rx1DROffset = dev.LastJoinAccept.Rx1DROffset
+	metadata.Rx2DR = dev.LastJoinAccept.Rx2DR
+	metadata.RxDelay = dev.LastJoinAccept.RxDelay
+
+	return &pb.DeviceActivationResponse{
+		Payload:            dev.LastJoinAccept.Payload,
+		DownlinkOption:     *activation.ResponseTemplate.DownlinkOption,
+		ActivationMetadata: *activation.ActivationMetadata,
+		Trace:              activation.Trace,
+	}
+}
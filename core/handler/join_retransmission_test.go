@@ -0,0 +1,55 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestJoinRetransmission(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component:                &component.Component{Ctx: GetLogger(t, "TestJoinRetransmission")},
+		joinRetransmissionWindow: time.Minute,
+	}
+
+	dev := &device.Device{AppID: "app", DevID: "dev", DevAddr: types.DevAddr{1, 2, 3, 4}, NwkSKey: types.NwkSKey{1, 2, 3, 4}}
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{1, 2}), ShouldBeFalse)
+
+	dev.LastJoinAccept = &device.JoinAccept{DevNonce: device.DevNonce{1, 2}, Payload: []byte{1, 2, 3}, RxDelay: 5, Time: time.Now()}
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{1, 2}), ShouldBeTrue)
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{2, 3}), ShouldBeFalse)
+
+	dev.LastSeen = time.Now()
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{1, 2}), ShouldBeFalse)
+	dev.LastSeen = time.Time{}
+
+	dev.LastJoinAccept.Time = time.Now().Add(-2 * time.Minute)
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{1, 2}), ShouldBeFalse)
+
+	h.joinRetransmissionWindow = 0
+	dev.LastJoinAccept.Time = time.Now()
+	a.So(h.isJoinRetransmission(dev, device.DevNonce{1, 2}), ShouldBeFalse)
+
+	metadata := &pb_lorawan.ActivationMetadata{DevAddr: &types.DevAddr{5, 6, 7, 8}, RxDelay: 1}
+	activation := &pb_broker.DeduplicatedDeviceActivationRequest{
+		ResponseTemplate:   &pb_broker.DeviceActivationResponse{DownlinkOption: &pb_broker.DownlinkOption{Identifier: "option"}},
+		ActivationMetadata: &pb_protocol.ActivationMetadata{Protocol: &pb_protocol.ActivationMetadata_LoRaWAN{LoRaWAN: metadata}},
+	}
+	res := h.retransmitJoinAccept(h.Ctx, activation, dev, metadata)
+	a.So(res.Payload, ShouldResemble, []byte{1, 2, 3})
+	a.So(res.DownlinkOption.Identifier, ShouldEqual, "option")
+	a.So(*res.ActivationMetadata.GetLoRaWAN().DevAddr, ShouldEqual, dev.DevAddr)
+	a.So(res.ActivationMetadata.GetLoRaWAN().RxDelay, ShouldEqual, 5)
+}
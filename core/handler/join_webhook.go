@@ -0,0 +1,72 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/joinhook"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+)
+
+// SetJoinWebhook sets the join webhook of the application. An empty address removes the webhook.
+func (h *handler) SetJoinWebhook(appID, address string) error {
+	app, err := h.applications.Get(appID)
+	if err != nil {
+		return err
+	}
+	app.StartUpdate()
+	app.JoinWebhook = address
+	return h.applications.Set(app)
+}
+
+// authorizeJoin calls the join webhook and returns its response if the join is allowed
+func (h *handler) authorizeJoin(address string, activation *pb_broker.DeduplicatedDeviceActivationRequest, dev *device.Device, devNonce device.DevNonce, joinAccept *lorawan.JoinAcceptPayload) (*joinhook.Response, error) {
+	activation.Trace = activation.Trace.WithEvent("join webhook")
+	res, err := h.joinHook.Authorize(address, &joinhook.Request{
+		AppID:    dev.AppID,
+		DevID:    dev.DevID,
+		AppEUI:   activation.AppEUI,
+		DevEUI:   activation.DevEUI,
+		DevNonce: types.DevNonce(devNonce),
+		DevAddr:  types.DevAddr(joinAccept.DevAddr),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not call join webhook")
+	}
+	if !res.Allow {
+		reason := res.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		return nil, errors.NewErrPermissionDenied(fmt.Sprintf("Join webhook denied activation: %s", reason))
+	}
+	return res, nil
+}
+
+// checkNetworkDevAddr checks that a DevAddr of the join webhook is in one of the prefixes of the Network Server. The
+// Network Server also checks that it is not used by another device before it activates the device.
+func (h *handler) checkNetworkDevAddr(devAddr types.DevAddr) error {
+	reqCtx, cancel := h.Component.GetRequestContext("")
+	defer cancel()
+	res, err := h.ttnDevAddrManager.GetPrefixes(reqCtx, &pb_lorawan.PrefixesRequest{})
+	if err != nil {
+		return errors.Wrap(errors.FromGRPCError(err), "Broker did not return prefixes")
+	}
+	for _, mapping := range res.Prefixes {
+		prefix, err := types.ParseDevAddrPrefix(mapping.Prefix)
+		if err != nil {
+			continue
+		}
+		if devAddr.HasPrefix(prefix) {
+			return nil
+		}
+	}
+	return errors.NewErrInvalidArgument("DevAddr", fmt.Sprintf("%s of join webhook is not in the prefixes of the Network Server", devAddr))
+}
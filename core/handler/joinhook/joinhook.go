@@ -0,0 +1,74 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package joinhook asks an external service whether an OTAA join should be accepted
+package joinhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// DefaultTimeout of the join webhook. Join accepts are sent 5 seconds after the
+// join request, so the external service has to respond quickly.
+const DefaultTimeout = 2 * time.Second
+
+// Request is posted to the join webhook
+type Request struct {
+	AppID    string         `json:"app_id"`
+	DevID    string         `json:"dev_id"`
+	AppEUI   types.AppEUI   `json:"app_eui"`
+	DevEUI   types.DevEUI   `json:"dev_eui"`
+	DevNonce types.DevNonce `json:"dev_nonce"`
+	DevAddr  types.DevAddr  `json:"dev_addr"`
+}
+
+// Response of the join webhook
+type Response struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"`
+
+	// DevAddr overrides the DevAddr that was allocated by the Network Server
+	DevAddr *types.DevAddr `json:"dev_addr,omitempty"`
+	// JoinAcceptSettings override the RX settings of the join accept
+	application.JoinAcceptSettings
+}
+
+// Client calls join webhooks
+type Client struct {
+	Client *http.Client
+}
+
+// NewClient returns a new Client
+func NewClient() *Client {
+	return &Client{
+		Client: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Authorize posts the request to the webhook at the address and returns its decision
+func (c *Client) Authorize(address string, req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.Client.Post(address, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("Join webhook returned status %s", res.Status)
+	}
+	var decision Response
+	if err := json.NewDecoder(res.Body).Decode(&decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package joinhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestAuthorize(t *testing.T) {
+	a := New(t)
+
+	var received Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		if received.DevEUI == (types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}) {
+			w.Write([]byte(`{"allow":true,"dev_addr":"26000001","rx_delay":5}`))
+			return
+		}
+		w.Write([]byte(`{"allow":false,"reason":"unknown device"}`))
+	}))
+	defer server.Close()
+
+	res, err := NewClient().Authorize(server.URL, &Request{AppID: "app", DevID: "dev", DevEUI: types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}, DevNonce: types.DevNonce{1, 2}})
+	a.So(err, ShouldBeNil)
+	a.So(received.AppID, ShouldEqual, "app")
+	a.So(received.DevNonce, ShouldEqual, types.DevNonce{1, 2})
+	a.So(res.Allow, ShouldBeTrue)
+	a.So(*res.DevAddr, ShouldEqual, types.DevAddr{0x26, 0, 0, 1})
+	a.So(*res.RXDelay, ShouldEqual, 5)
+	a.So(res.RX1DROffset, ShouldBeNil)
+
+	res, err = NewClient().Authorize(server.URL, &Request{AppID: "app", DevID: "other"})
+	a.So(err, ShouldBeNil)
+	a.So(res.Allow, ShouldBeFalse)
+	a.So(res.Reason, ShouldEqual, "unknown device")
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	_, err = NewClient().Authorize(failing.URL, &Request{})
+	a.So(err, ShouldNotBeNil)
+}
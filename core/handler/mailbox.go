@@ -0,0 +1,133 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Default limits of the uplinks that are waiting to be handled
+const (
+	DefaultUplinkQueuePerDevice = 32
+	DefaultUplinkQueueSize      = 10000
+)
+
+var droppedUplinksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "handler",
+		Name:      "dropped_uplinks_total",
+		Help:      "Number of uplinks that were dropped because the uplink queue was full, by queue.",
+	}, []string{"queue"},
+)
+
+func init() {
+	prometheus.MustRegister(droppedUplinksCounter)
+}
+
+// mailbox holds the work that is waiting for a device
+type mailbox struct {
+	work []func()
+}
+
+// mailboxes process the work for each device strictly in the order in which it was posted, while
+// the work for different devices runs concurrently on at most a limited number of workers
+type mailboxes struct {
+	mu           sync.Mutex
+	boxes        map[string]*mailbox
+	waiting      int
+	maxPerDevice int
+	maxTotal     int
+	workers      chan struct{}
+}
+
+// newMailboxes returns mailboxes that run on the given number of workers and hold at most maxPerDevice work items
+// per device and maxTotal work items in total. Zero does not limit the number of workers or work items.
+func newMailboxes(workers, maxPerDevice, maxTotal int) *mailboxes {
+	m := &mailboxes{
+		boxes:        make(map[string]*mailbox),
+		maxPerDevice: maxPerDevice,
+		maxTotal:     maxTotal,
+	}
+	if workers > 0 {
+		m.workers = make(chan struct{}, workers)
+	}
+	return m
+}
+
+// Post adds work to the mailbox of the device. It does not wait for the work to be done. If the mailbox of the device
+// or all mailboxes together are full, the work is dropped and counted, and Post returns false.
+func (m *mailboxes) Post(appID, devID string, work func()) bool {
+	key := appID + "/" + devID
+	m.mu.Lock()
+	if m.maxTotal > 0 && m.waiting >= m.maxTotal {
+		m.mu.Unlock()
+		droppedUplinksCounter.WithLabelValues("total").Inc()
+		return false
+	}
+	if box, ok := m.boxes[key]; ok {
+		if m.maxPerDevice > 0 && len(box.work) >= m.maxPerDevice {
+			m.mu.Unlock()
+			droppedUplinksCounter.WithLabelValues("device").Inc()
+			return false
+		}
+		box.work = append(box.work, work)
+		m.waiting++
+		m.mu.Unlock()
+		return true
+	}
+	box := &mailbox{work: []func(){work}}
+	m.boxes[key] = box
+	m.waiting++
+	m.mu.Unlock()
+	go m.run(key, box)
+	return true
+}
+
+// run processes the mailbox until it is empty
+func (m *mailboxes) run(key string, box *mailbox) {
+	for {
+		m.mu.Lock()
+		if len(box.work) == 0 {
+			delete(m.boxes, key)
+			m.mu.Unlock()
+			return
+		}
+		work := box.work[0]
+		box.work[0] = nil
+		box.work = box.work[1:]
+		m.waiting--
+		m.mu.Unlock()
+
+		if m.workers != nil {
+			m.workers <- struct{}{}
+		}
+		work()
+		if m.workers != nil {
+			<-m.workers
+		}
+	}
+}
+
+// Len returns the number of devices that have work waiting or running
+func (m *mailboxes) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.boxes)
+}
+
+// WithUplinkWorkers limits the number of devices of which uplinks are handled at the same time
+func (h *handler) WithUplinkWorkers(workers int) Handler {
+	h.mailboxes = newMailboxes(workers, h.mailboxes.maxPerDevice, h.mailboxes.maxTotal)
+	return h
+}
+
+// WithUplinkQueue limits the number of uplinks that are waiting to be handled per device and in total. Uplinks that
+// do not fit are dropped. Zero does not limit the number of uplinks.
+func (h *handler) WithUplinkQueue(perDevice, total int) Handler {
+	h.mailboxes.maxPerDevice, h.mailboxes.maxTotal = perDevice, total
+	return h
+}
@@ -0,0 +1,105 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestMailboxes(t *testing.T) {
+	a := New(t)
+
+	m := newMailboxes(4, 0, 0)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var running, maxRunning int32
+	handled := make(map[string][]int)
+	for i := 0; i < 50; i++ {
+		for dev := 0; dev < 10; dev++ {
+			i, devID := i, fmt.Sprintf("dev-%d", dev)
+			wg.Add(1)
+			m.Post("app", devID, func() {
+				defer wg.Done()
+				if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&maxRunning) {
+					atomic.StoreInt32(&maxRunning, n)
+				}
+				time.Sleep(100 * time.Microsecond)
+				mu.Lock()
+				handled[devID] = append(handled[devID], i)
+				mu.Unlock()
+				atomic.AddInt32(&running, -1)
+			})
+		}
+	}
+	wg.Wait()
+
+	a.So(atomic.LoadInt32(&maxRunning), ShouldBeLessThan, 5)
+	a.So(handled, ShouldHaveLength, 10)
+	for _, order := range handled {
+		a.So(order, ShouldHaveLength, 50)
+		for i, n := range order {
+			a.So(n, ShouldEqual, i)
+		}
+	}
+
+	// Empty mailboxes are removed
+	time.Sleep(10 * time.Millisecond)
+	a.So(m.Len(), ShouldEqual, 0)
+}
+
+func TestMailboxesConcurrency(t *testing.T) {
+	a := New(t)
+
+	m := newMailboxes(0, 0, 0)
+
+	// A slow device does not block other devices
+	slow := make(chan struct{})
+	done := make(chan string, 2)
+	m.Post("app", "slow", func() {
+		<-slow
+		done <- "slow"
+	})
+	m.Post("app", "slow", func() {
+		done <- "slow-2"
+	})
+	m.Post("app", "fast", func() {
+		done <- "fast"
+	})
+	a.So(<-done, ShouldEqual, "fast")
+	close(slow)
+	a.So(<-done, ShouldEqual, "slow")
+	a.So(<-done, ShouldEqual, "slow-2")
+}
+
+func TestMailboxesLimits(t *testing.T) {
+	a := New(t)
+
+	m := newMailboxes(0, 2, 3)
+
+	block := make(chan struct{})
+	defer close(block)
+	work := func() { <-block }
+
+	// The first work item of a device runs, the rest waits
+	a.So(m.Post("app", "dev-1", work), ShouldBeTrue)
+	time.Sleep(10 * time.Millisecond)
+	a.So(m.Post("app", "dev-1", work), ShouldBeTrue)
+	a.So(m.Post("app", "dev-1", work), ShouldBeTrue)
+
+	// The mailbox of the device is full
+	a.So(m.Post("app", "dev-1", work), ShouldBeFalse)
+
+	// All mailboxes together are full
+	a.So(m.Post("app", "dev-2", work), ShouldBeTrue)
+	time.Sleep(10 * time.Millisecond)
+	a.So(m.Post("app", "dev-2", work), ShouldBeTrue)
+	a.So(m.Post("app", "dev-3", work), ShouldBeFalse)
+}
@@ -4,11 +4,13 @@
 package handler
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/TheThingsNetwork/api"
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	pb_handler "github.com/TheThingsNetwork/api/handler"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
@@ -16,9 +18,12 @@ import (
 	"github.com/TheThingsNetwork/go-account-lib/rights"
 	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	ttnapi "github.com/TheThingsNetwork/ttn/api"
 	"github.com/TheThingsNetwork/ttn/api/ratelimit"
+	"github.com/TheThingsNetwork/ttn/core/handler/alert"
 	"github.com/TheThingsNetwork/ttn/core/handler/application"
 	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
 	"github.com/TheThingsNetwork/ttn/core/storage"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
@@ -29,6 +34,16 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
+// The keys in the request metadata of SetApplication with the application settings that are not part of the
+// Application message. The values are JSON, and an empty value removes the setting. GetApplication returns the
+// settings in the response header with the same keys.
+const (
+	AlertRulesKey         = "alert-rules"
+	UplinkFiltersKey      = "uplink-filters"
+	JoinAcceptSettingsKey = "join-accept-settings"
+	DownlinkQuotaKey      = "downlink-quota"
+)
+
 type handlerManager struct {
 	handler         *handler
 	devAddrManager  pb_lorawan.DevAddrManagerClient
@@ -36,6 +51,42 @@ type handlerManager struct {
 	clientRate      *ratelimit.Registry
 }
 
+// deviceQueryFromIncomingContext reads a device query from the request metadata:
+// - attribute: key=value, can be given multiple times
+// - last-seen-after and last-seen-before: RFC3339 timestamps
+// - dev-eui-prefix: hex
+// - sort: a field to sort by, prefixed with "-" to sort in descending order
+func deviceQueryFromIncomingContext(ctx context.Context) (query device.Query, err error) {
+	md := ttnctx.MetadataFromIncomingContext(ctx)
+	for _, attribute := range md["attribute"] {
+		kv := strings.SplitN(attribute, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return query, errors.NewErrInvalidArgument("Attribute", "must be key=value")
+		}
+		if query.Attributes == nil {
+			query.Attributes = make(map[string]string)
+		}
+		query.Attributes[kv[0]] = kv[1]
+	}
+	for key, t := range map[string]*time.Time{"last-seen-after": &query.LastSeenAfter, "last-seen-before": &query.LastSeenBefore} {
+		values := md[key]
+		if len(values) == 0 {
+			continue
+		}
+		if *t, err = time.Parse(time.RFC3339, values[0]); err != nil {
+			return query, errors.NewErrInvalidArgument(key, "must be a RFC3339 timestamp")
+		}
+	}
+	if values := md["dev-eui-prefix"]; len(values) > 0 {
+		query.DevEUIPrefix = values[0]
+	}
+	if values := md["sort"]; len(values) > 0 {
+		query.SortBy = strings.TrimPrefix(values[0], "-")
+		query.Descending = strings.HasPrefix(values[0], "-")
+	}
+	return query, query.Validate()
+}
+
 func checkAppRights(claims *claims.Claims, appID string, right types.Right) error {
 	if !claims.AppRight(appID, right) {
 		return errors.NewErrPermissionDenied(fmt.Sprintf(`No "%s" rights to Application "%s"`, right, appID))
@@ -43,6 +94,80 @@ func checkAppRights(claims *claims.Claims, appID string, right types.Right) erro
 	return nil
 }
 
+// setApplicationSettingsFromIncomingContext sets the application settings from the request metadata. The join accept
+// settings are validated against the frequency plan of each join.
+func setApplicationSettingsFromIncomingContext(ctx context.Context, app *application.Application) (err error) {
+	md := ttnctx.MetadataFromIncomingContext(ctx)
+	if values := md[AlertRulesKey]; len(values) > 0 {
+		var rules []alert.Rule
+		if values[0] != "" {
+			if err = json.Unmarshal([]byte(values[0]), &rules); err != nil {
+				return errors.NewErrInvalidArgument("Alert rules", err.Error())
+			}
+		}
+		if err = alert.ValidateRules(rules); err != nil {
+			return err
+		}
+		app.AlertRules = rules
+	}
+	if values := md[UplinkFiltersKey]; len(values) > 0 {
+		var rules []filter.Rule
+		if values[0] != "" {
+			if err = json.Unmarshal([]byte(values[0]), &rules); err != nil {
+				return errors.NewErrInvalidArgument("Uplink filters", err.Error())
+			}
+		}
+		if err = filter.ValidateRules(rules); err != nil {
+			return err
+		}
+		app.UplinkFilters = rules
+	}
+	if values := md[JoinAcceptSettingsKey]; len(values) > 0 {
+		settings := new(application.JoinAcceptSettings)
+		if values[0] != "" {
+			if err = json.Unmarshal([]byte(values[0]), settings); err != nil {
+				return errors.NewErrInvalidArgument("Join accept settings", err.Error())
+			}
+		}
+		if err = settings.Validate(nil); err != nil {
+			return err
+		}
+		if settings.IsEmpty() {
+			settings = nil
+		}
+		app.JoinAccept = settings
+	}
+	if values := md[DownlinkQuotaKey]; len(values) > 0 {
+		quota := new(application.DownlinkQuota)
+		if values[0] != "" {
+			if err = json.Unmarshal([]byte(values[0]), quota); err != nil {
+				return errors.NewErrInvalidArgument("Downlink quota", err.Error())
+			}
+		}
+		if quota.IsEmpty() {
+			quota = nil
+		}
+		app.DownlinkQuota = quota
+	}
+	return nil
+}
+
+// applicationSettingsHeader returns the application settings that are not part of the Application message
+func applicationSettingsHeader(app *application.Application) metadata.MD {
+	header := metadata.MD{}
+	for key, setting := range map[string]interface{}{
+		AlertRulesKey:         app.AlertRules,
+		UplinkFiltersKey:      app.UplinkFilters,
+		JoinAcceptSettingsKey: app.JoinAccept,
+		DownlinkQuotaKey:      app.DownlinkQuota,
+	} {
+		if value, err := json.Marshal(setting); err == nil && string(value) != "null" {
+			header[key] = []string{string(value)}
+		}
+	}
+	return header
+}
+
 func (h *handlerManager) validateTTNAuthAppContext(ctx context.Context, appID string) (context.Context, *claims.Claims, error) {
 	md := ttnctx.MetadataFromIncomingContext(ctx)
 
@@ -114,7 +239,7 @@ func (h *handlerManager) GetDevice(ctx context.Context, in *pb_handler.DeviceIde
 		nsDev = dev.ToLoRaWANPb()
 		nsDev.AppKey = nil
 		nsDev.AppSKey = nil
-		_, err = h.handler.ttnDeviceManager.SetDevice(ttnctx.OutgoingContextWithToken(ctx, token), nsDev)
+		_, err = h.handler.ttnDeviceManager.SetDevice(ttnapi.OutgoingContextWithDeviceSettings(ttnctx.OutgoingContextWithToken(ctx, token), dev.NetworkSettings()), nsDev)
 		if err != nil {
 			return nil, errors.Wrap(errors.FromGRPCError(err), "Could not re-register missing device to Broker")
 		}
@@ -130,6 +255,10 @@ func (h *handlerManager) GetDevice(ctx context.Context, in *pb_handler.DeviceIde
 }
 
 func (h *handlerManager) SetDevice(ctx context.Context, in *pb_handler.Device) (*gogo.Empty, error) {
+	if claimCode := claimCodeFromIncomingContext(ctx); claimCode != "" {
+		return h.claimDevice(ctx, in, claimCode)
+	}
+
 	if err := in.Validate(); err != nil {
 		return nil, errors.Wrap(err, "Invalid Device")
 	}
@@ -159,8 +288,10 @@ func (h *handlerManager) SetDevice(ctx context.Context, in *pb_handler.Device) (
 	}
 
 	var eventType types.EventType
+	var oldSession device.Device
 	if dev != nil {
 		eventType = types.UpdateEvent
+		oldSession = *dev
 
 		// Not allowed to update join nonces after device is created
 		lorawan.UsedDevNonces, lorawan.UsedAppNonces = nil, nil
@@ -211,7 +342,26 @@ func (h *handlerManager) SetDevice(ctx context.Context, in *pb_handler.Device) (
 	lorawanPb.FCntUp = lorawan.FCntUp
 	lorawanPb.FCntDown = lorawan.FCntDown
 
-	_, err = h.handler.ttnDeviceManager.SetDevice(ttnctx.OutgoingContextWithToken(ctx, token), lorawanPb)
+	// Lowering the frame counters of the same session must be confirmed
+	if eventType == types.UpdateEvent && dev.AppEUI == oldSession.AppEUI && dev.DevEUI == oldSession.DevEUI &&
+		dev.DevAddr == oldSession.DevAddr && dev.NwkSKey == oldSession.NwkSKey {
+		current, err := h.handler.ttnDeviceManager.GetDevice(ttnctx.OutgoingContextWithToken(ctx, token), &pb_lorawan.DeviceIdentifier{
+			AppEUI: dev.AppEUI,
+			DevEUI: dev.DevEUI,
+		})
+		switch {
+		case err == nil:
+			if err := h.handler.guardFCntReset(dev, current, lorawanPb, fCntResetConfirmedFromIncomingContext(ctx), claims.Subject); err != nil {
+				return nil, err
+			}
+		case errors.IsNotFound(errors.FromGRPCError(err)):
+			// The session is not known to the Broker, so there are no frame counters to protect
+		default:
+			return nil, errors.Wrap(errors.FromGRPCError(err), "Broker did not return frame counters of device")
+		}
+	}
+
+	_, err = h.handler.ttnDeviceManager.SetDevice(ttnapi.OutgoingContextWithDeviceSettings(ttnctx.OutgoingContextWithToken(ctx, token), dev.NetworkSettings()), lorawanPb)
 	if err != nil {
 		return nil, errors.Wrap(errors.FromGRPCError(err), "Broker did not set device")
 	}
@@ -231,6 +381,36 @@ func (h *handlerManager) SetDevice(ctx context.Context, in *pb_handler.Device) (
 	return &gogo.Empty{}, nil
 }
 
+// claimDevice claims the factory-provisioned device with the DevEUI of the LoRaWAN device for the application. The
+// keys of the device are taken from the provisioning store, so that the client only needs the claim code.
+func (h *handlerManager) claimDevice(ctx context.Context, in *pb_handler.Device, claimCode string) (*gogo.Empty, error) {
+	if err := api.NotEmptyAndValidID(in.AppID, "Application ID"); err != nil {
+		return nil, errors.Wrap(err, "Invalid Device")
+	}
+	if err := api.NotEmptyAndValidID(in.DevID, "Device ID"); err != nil {
+		return nil, errors.Wrap(err, "Invalid Device")
+	}
+	lorawan := in.GetLoRaWANDevice()
+	if lorawan == nil || lorawan.DevEUI.IsEmpty() {
+		return nil, errors.NewErrInvalidArgument("Device", "No DevEUI to claim")
+	}
+
+	ctx, claims, err := h.validateTTNAuthAppContext(ctx, in.AppID)
+	if err != nil {
+		return nil, err
+	}
+	token, _ := ttnctx.TokenFromIncomingContext(ctx)
+	err = checkAppRights(claims, in.AppID, rights.Devices)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := h.handler.ClaimDevice(token, in.AppID, in.DevID, lorawan.DevEUI, claimCode); err != nil {
+		return nil, err
+	}
+	return &gogo.Empty{}, nil
+}
+
 func (h *handlerManager) DeleteDevice(ctx context.Context, in *pb_handler.DeviceIdentifier) (*gogo.Empty, error) {
 	if err := in.Validate(); err != nil {
 		return nil, errors.Wrap(err, "Invalid Device Identifier")
@@ -291,8 +471,13 @@ func (h *handlerManager) GetDevicesForApplication(ctx context.Context, in *pb_ha
 		return nil, err
 	}
 
+	query, err := deviceQueryFromIncomingContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	opts := &storage.ListOptions{Limit: limit, Offset: offset}
-	devices, err := h.handler.devices.ListForApp(in.AppID, opts)
+	devices, err := h.handler.devices.Search(in.AppID, query, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -339,6 +524,7 @@ func (h *handlerManager) GetApplication(ctx context.Context, in *pb_handler.Appl
 		Validator:     app.CustomValidator,
 		Encoder:       app.CustomEncoder,
 	}
+	grpc.SendHeader(ctx, applicationSettingsHeader(app))
 	if err := checkAppRights(claims, in.AppID, rights.Devices); err == nil {
 		res.RegisterOnJoinAccessKey = app.RegisterOnJoinAccessKey
 	} else if app.RegisterOnJoinAccessKey != "" {
@@ -385,12 +571,23 @@ func (h *handlerManager) RegisterApplication(ctx context.Context, in *pb_handler
 		h.handler.Ctx.WithField("AppID", in.AppID).WithError(err).Warn("Could not register Application with Discovery")
 	}
 
-	_, err = h.handler.ttnBrokerManager.RegisterApplicationHandler(ttnctx.OutgoingContextWithToken(ctx, token), &pb_broker.ApplicationHandlerRegistration{
+	brokerCtx := ttnctx.OutgoingContextWithToken(ctx, token)
+	md, _ := metadata.FromOutgoingContext(brokerCtx)
+	brokerCtx = metadata.NewOutgoingContext(brokerCtx, metadata.Join(md, handlerHello.Metadata()))
+	var brokerMD metadata.MD
+	_, err = h.handler.ttnBrokerManager.RegisterApplicationHandler(brokerCtx, &pb_broker.ApplicationHandlerRegistration{
 		AppID:     in.AppID,
 		HandlerID: h.handler.Identity.ID,
-	})
+	}, grpc.Header(&brokerMD))
 	if err != nil {
+		// The Broker rejects incompatible Handlers with an invalid argument error
+		if err := errors.FromGRPCError(err); errors.GetErrType(err) == errors.InvalidArgument {
+			h.handler.Ctx.WithField("AppID", in.AppID).WithError(err).Error("Broker rejected Application registration")
+			return nil, errors.Wrap(err, "Broker rejected Application registration")
+		}
 		h.handler.Ctx.WithField("AppID", in.AppID).WithError(err).Warn("Could not register Application with Broker")
+	} else if err := h.handshake(in.AppID, brokerMD); err != nil {
+		return nil, err
 	}
 
 	return &gogo.Empty{}, nil
@@ -430,6 +627,10 @@ func (h *handlerManager) SetApplication(ctx context.Context, in *pb_handler.Appl
 		app.PayloadFormat = application.PayloadFormatCustom
 	}
 
+	if err := setApplicationSettingsFromIncomingContext(ctx, app); err != nil {
+		return nil, err
+	}
+
 	err = h.handler.applications.Set(app)
 	if err != nil {
 		return nil, err
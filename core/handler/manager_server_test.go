@@ -0,0 +1,84 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/handler"
+	"github.com/TheThingsNetwork/go-account-lib/rights"
+	"github.com/TheThingsNetwork/ttn/api/ratelimit"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestSetApplicationSettings(t *testing.T) {
+	a := New(t)
+
+	appID := "settings-app"
+	provider, token := testAppToken(t, appID, rights.AppSettings)
+	h := &handler{
+		Component:    &component.Component{Ctx: GetLogger(t, "TestSetApplicationSettings"), TokenKeyProvider: provider},
+		applications: application.NewRedisApplicationStore(GetRedisClient(), "handler-test-set-application-settings"),
+	}
+	m := &handlerManager{
+		handler:         h,
+		applicationRate: ratelimit.NewRegistry(5000, time.Hour),
+		clientRate:      ratelimit.NewRegistry(5000, time.Hour),
+	}
+
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	set := func(token string, pairs ...string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(append([]string{"token", token}, pairs...)...))
+		_, err := m.SetApplication(ctx, &pb.Application{AppID: appID})
+		return err
+	}
+
+	// Without the settings right
+	_, otherToken := testAppToken(t, appID, rights.Devices)
+	err := set(otherToken, AlertRulesKey, "")
+	a.So(err, ShouldNotBeNil)
+
+	// Invalid settings
+	a.So(set(token, AlertRulesKey, `[{"id":"invalid"}]`), ShouldNotBeNil)
+	a.So(set(token, UplinkFiltersKey, `{`), ShouldNotBeNil)
+	a.So(set(token, JoinAcceptSettingsKey, `{"rx_delay":16}`), ShouldNotBeNil)
+
+	err = set(token,
+		AlertRulesKey, `[{"id":"offline","metric":"device_offline","notify":["event"]}]`,
+		UplinkFiltersKey, `[{"id":"port","action":"drop","f_ports":[10]}]`,
+		JoinAcceptSettingsKey, `{"rx_delay":5}`,
+		DownlinkQuotaKey, `{"per_device":10}`,
+	)
+	a.So(err, ShouldBeNil)
+
+	app, _ := h.applications.Get(appID)
+	a.So(app.AlertRules, ShouldHaveLength, 1)
+	a.So(app.UplinkFilters, ShouldHaveLength, 1)
+	a.So(*app.JoinAccept.RXDelay, ShouldEqual, 5)
+	a.So(app.DownlinkQuota.PerDevice, ShouldEqual, 10)
+
+	header := applicationSettingsHeader(app)
+	a.So(header, ShouldContainKey, AlertRulesKey)
+	a.So(header, ShouldContainKey, UplinkFiltersKey)
+	a.So(header[JoinAcceptSettingsKey], ShouldResemble, []string{`{"rx_delay":5}`})
+	a.So(header[DownlinkQuotaKey], ShouldResemble, []string{`{"per_device":10}`})
+
+	// Empty settings are removed, settings that are not in the metadata are kept
+	err = set(token, AlertRulesKey, "")
+	a.So(err, ShouldBeNil)
+
+	app, _ = h.applications.Get(appID)
+	a.So(app.AlertRules, ShouldBeEmpty)
+	a.So(app.UplinkFilters, ShouldHaveLength, 1)
+	a.So(app.JoinAccept, ShouldNotBeNil)
+	a.So(app.DownlinkQuota, ShouldNotBeNil)
+}
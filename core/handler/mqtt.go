@@ -41,6 +41,31 @@ func (h *handler) HandleMQTT(username, password string, mqttBrokers ...string) e
 
 	ctx := h.Ctx.WithField("Protocol", "MQTT")
 
+	token = h.mqttClient.SubscribeDownlinkCommands(func(client mqtt.Client, appID string, devID string, command mqtt.DownlinkCommand) {
+		go func() {
+			var err error
+			switch command.Type {
+			case mqtt.DownlinkQueueCommand:
+				err = h.publishDownlinkQueue(appID, devID)
+			case mqtt.DownlinkCancelCommand:
+				err = h.CancelDownlink(appID, devID, command.ID)
+			case mqtt.DownlinkFlushCommand:
+				err = h.FlushDownlinkQueue(appID, devID)
+			}
+			if err != nil {
+				ctx.WithFields(ttnlog.Fields{
+					"AppID":   appID,
+					"DevID":   devID,
+					"Command": command.Type,
+				}).WithError(err).Warn("Could not handle downlink command")
+			}
+		}()
+	})
+	token.Wait()
+	if token.Error() != nil {
+		return token.Error()
+	}
+
 	go func() {
 		for up := range h.mqttUp {
 			ctx := ctx.WithFields(ttnlog.Fields{
@@ -0,0 +1,120 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"fmt"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// SetupMulticastGroup enqueues a McGroupSetupReq for the device and stores the (unconfirmed) group
+func (h *handler) SetupMulticastGroup(appID, devID string, group multicast.Group) error {
+	if err := group.Validate(); err != nil {
+		return err
+	}
+	dev, err := h.devices.Get(appID, devID)
+	if err != nil {
+		return err
+	}
+	if dev.AppKey.IsEmpty() {
+		return errors.NewErrInvalidArgument("Device", "has no AppKey to derive the McKEKey from")
+	}
+	dev.StartUpdate()
+
+	mcKEKey := multicast.DeriveMcKEKey(multicast.DeriveMcRootKey(types.AES128Key(dev.AppKey)))
+	if err := h.enqueueMulticastCommand(appID, devID, multicast.McGroupSetupReq(group, mcKEKey)); err != nil {
+		return err
+	}
+
+	group.Confirmed = false
+	dev.SetMulticastGroup(group)
+	return h.devices.Set(dev)
+}
+
+// DeleteMulticastGroup enqueues a McGroupDeleteReq for the device
+func (h *handler) DeleteMulticastGroup(appID, devID string, id uint8) error {
+	dev, err := h.devices.Get(appID, devID)
+	if err != nil {
+		return err
+	}
+	if _, ok := dev.GetMulticastGroup(id); !ok {
+		return errors.NewErrNotFound(fmt.Sprintf("Multicast group %d", id))
+	}
+	return h.enqueueMulticastCommand(appID, devID, multicast.McGroupDeleteReq(id))
+}
+
+func (h *handler) enqueueMulticastCommand(appID, devID string, payload []byte) error {
+	queue, err := h.devices.DownlinkQueue(appID, devID)
+	if err != nil {
+		return err
+	}
+	return queue.PushLast(&types.DownlinkMessage{
+		FPort:      multicast.Port,
+		PayloadRaw: payload,
+	})
+}
+
+// HandleMulticastSetup processes the answers of the device on the Remote Multicast Setup port
+func (h *handler) HandleMulticastSetup(ctx ttnlog.Interface, ttnUp *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) error {
+	if appUp.FPort != multicast.Port || appUp.IsRetry {
+		return nil
+	}
+	answers, err := multicast.ParseAnswers(appUp.PayloadRaw)
+	if err != nil {
+		ctx.WithError(err).Warn("Could not parse multicast answers")
+		return nil
+	}
+	for _, answer := range answers {
+		switch answer.Command {
+		case multicast.McGroupSetup:
+			group, ok := dev.GetMulticastGroup(answer.GroupID)
+			if !ok {
+				continue
+			}
+			if answer.Error {
+				dev.DeleteMulticastGroup(answer.GroupID)
+			} else {
+				group.Confirmed = true
+				dev.SetMulticastGroup(group)
+			}
+		case multicast.McGroupDelete:
+			if !answer.Error {
+				dev.DeleteMulticastGroup(answer.GroupID)
+			}
+		default:
+			continue
+		}
+		ctx.WithFields(ttnlog.Fields{
+			"GroupID": answer.GroupID,
+			"Error":   answer.Error,
+		}).Debug("Handled multicast answer")
+		h.qEvent <- &types.DeviceEvent{
+			AppID: appUp.AppID,
+			DevID: appUp.DevID,
+			Event: types.MulticastEvent,
+			Data: types.MulticastEventData{
+				Command: multicastCommandName(answer.Command),
+				GroupID: answer.GroupID,
+				Error:   answer.Error,
+			},
+		}
+	}
+	return nil
+}
+
+func multicastCommandName(cmd byte) string {
+	switch cmd {
+	case multicast.McGroupSetup:
+		return "setup"
+	case multicast.McGroupDelete:
+		return "delete"
+	}
+	return fmt.Sprintf("0x%02X", cmd)
+}
@@ -0,0 +1,50 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package multicast
+
+import (
+	"crypto/aes"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+func encrypt(key types.AES128Key, block [16]byte) (out [16]byte) {
+	cipher, _ := aes.NewCipher(key[:])
+	cipher.Encrypt(out[:], block[:])
+	return
+}
+
+func decrypt(key types.AES128Key, block [16]byte) (out [16]byte) {
+	cipher, _ := aes.NewCipher(key[:])
+	cipher.Decrypt(out[:], block[:])
+	return
+}
+
+// DeriveMcRootKey derives the McRootKey of a LoRaWAN 1.0.x device from its GenAppKey. TTN
+// devices do not have a separate GenAppKey, so the Handler uses the AppKey of the device.
+func DeriveMcRootKey(genAppKey types.AES128Key) types.AES128Key {
+	return encrypt(genAppKey, [16]byte{0x00})
+}
+
+// DeriveMcKEKey derives the McKEKey that encrypts the McKeys of the multicast groups from the McRootKey
+func DeriveMcKEKey(mcRootKey types.AES128Key) types.AES128Key {
+	return encrypt(mcRootKey, [16]byte{0x00})
+}
+
+// EncryptMcKey encrypts the McKey for the McGroupSetupReq. The device decrypts
+// it with aes128_encrypt(McKEKey, McKey_encrypted), so we use the AES decryption.
+func EncryptMcKey(mcKEKey, mcKey types.AES128Key) types.AES128Key {
+	return decrypt(mcKEKey, mcKey)
+}
+
+// DeriveSessionKeys derives the McAppSKey and McNwkSKey of a multicast group
+func DeriveSessionKeys(mcKey types.AES128Key, mcAddr types.DevAddr) (mcAppSKey types.AppSKey, mcNwkSKey types.NwkSKey) {
+	var block [16]byte
+	block[1], block[2], block[3], block[4] = mcAddr[3], mcAddr[2], mcAddr[1], mcAddr[0]
+	block[0] = 0x01
+	mcAppSKey = types.AppSKey(encrypt(mcKey, block))
+	block[0] = 0x02
+	mcNwkSKey = types.NwkSKey(encrypt(mcKey, block))
+	return
+}
@@ -0,0 +1,81 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package multicast
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// McGroupSetupReq returns the payload of a McGroupSetupReq that provisions the group on the device
+func McGroupSetupReq(group Group, mcKEKey types.AES128Key) []byte {
+	payload := make([]byte, 30)
+	payload[0] = McGroupSetup
+	payload[1] = group.ID & 0x03
+	payload[2], payload[3], payload[4], payload[5] = group.McAddr[3], group.McAddr[2], group.McAddr[1], group.McAddr[0]
+	encrypted := EncryptMcKey(mcKEKey, group.McKey)
+	copy(payload[6:22], encrypted[:])
+	binary.LittleEndian.PutUint32(payload[22:26], group.MinFCnt)
+	binary.LittleEndian.PutUint32(payload[26:30], group.MaxFCnt)
+	return payload
+}
+
+// McGroupDeleteReq returns the payload of a McGroupDeleteReq that removes the group from the device
+func McGroupDeleteReq(id uint8) []byte {
+	return []byte{McGroupDelete, id & 0x03}
+}
+
+// Answer is an answer of the device in the Remote Multicast Setup protocol
+type Answer struct {
+	Command byte
+	GroupID uint8
+	Error   bool
+}
+
+// ParseAnswers parses the answers in the payload of an uplink on the Remote Multicast Setup port
+func ParseAnswers(payload []byte) (answers []Answer, err error) {
+	for len(payload) > 0 {
+		var length int
+		switch payload[0] {
+		case PackageVersion:
+			length = 3
+		case McGroupStatus:
+			if len(payload) < 2 {
+				return nil, errors.NewErrInvalidArgument("McGroupStatusAns", "too short")
+			}
+			var groups int
+			for mask := payload[1] & 0x0F; mask != 0; mask >>= 1 {
+				groups += int(mask & 1)
+			}
+			length = 2 + 5*groups
+		case McGroupSetup, McGroupDelete:
+			length = 2
+		case McClassCSession, McClassBSession:
+			length = 2
+			if len(payload) >= 2 && payload[1]&0x1C == 0 {
+				length = 5 // includes TimeToStart
+			}
+		default:
+			return nil, errors.NewErrInvalidArgument("Multicast Command", fmt.Sprintf("unknown command 0x%02X", payload[0]))
+		}
+		if len(payload) < length {
+			return nil, errors.NewErrInvalidArgument("Multicast Command", fmt.Sprintf("answer 0x%02X is too short", payload[0]))
+		}
+		answer := Answer{Command: payload[0]}
+		switch payload[0] {
+		case McGroupSetup, McGroupDelete:
+			answer.GroupID = payload[1] & 0x03
+			answer.Error = payload[1]&0x04 != 0
+		case McClassCSession, McClassBSession:
+			answer.GroupID = payload[1] & 0x03
+			answer.Error = payload[1]&0x1C != 0
+		}
+		answers = append(answers, answer)
+		payload = payload[length:]
+	}
+	return answers, nil
+}
@@ -0,0 +1,62 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package multicast implements the Remote Multicast Setup protocol, which provisions multicast groups on devices
+package multicast
+
+import (
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Port is the FPort of the Remote Multicast Setup protocol
+const Port = 200
+
+// MaxGroups is the number of multicast groups that a device can have
+const MaxGroups = 4
+
+// Command identifiers
+const (
+	PackageVersion  byte = 0x00
+	McGroupStatus   byte = 0x01
+	McGroupSetup    byte = 0x02
+	McGroupDelete   byte = 0x03
+	McClassCSession byte = 0x04
+	McClassBSession byte = 0x05
+)
+
+// Group is a multicast group of a device
+type Group struct {
+	ID      uint8           `json:"id"`
+	McAddr  types.DevAddr   `json:"mc_addr"`
+	McKey   types.AES128Key `json:"mc_key"`
+	MinFCnt uint32          `json:"min_fcnt"`
+	MaxFCnt uint32          `json:"max_fcnt"`
+
+	// Confirmed is true if the device answered the McGroupSetupReq without errors
+	Confirmed bool `json:"confirmed"`
+}
+
+// Validate the group
+func (g Group) Validate() error {
+	if g.ID >= MaxGroups {
+		return errors.NewErrInvalidArgument("Multicast Group ID", fmt.Sprintf("must be lower than %d", MaxGroups))
+	}
+	if g.McAddr.IsEmpty() {
+		return errors.NewErrInvalidArgument("Multicast Group McAddr", "can not be empty")
+	}
+	if g.McKey.IsEmpty() {
+		return errors.NewErrInvalidArgument("Multicast Group McKey", "can not be empty")
+	}
+	if g.MaxFCnt != 0 && g.MaxFCnt < g.MinFCnt {
+		return errors.NewErrInvalidArgument("Multicast Group FCnt", "maximum can not be lower than minimum")
+	}
+	return nil
+}
+
+// SessionKeys returns the McAppSKey and McNwkSKey of the group
+func (g Group) SessionKeys() (types.AppSKey, types.NwkSKey) {
+	return DeriveSessionKeys(g.McKey, g.McAddr)
+}
@@ -0,0 +1,94 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package multicast
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGroupValidate(t *testing.T) {
+	a := New(t)
+
+	group := Group{ID: 1, McAddr: types.DevAddr{1, 2, 3, 4}, McKey: types.AES128Key{1}}
+	a.So(group.Validate(), ShouldBeNil)
+
+	group.ID = MaxGroups
+	a.So(group.Validate(), ShouldNotBeNil)
+	group.ID = 1
+
+	group.MinFCnt, group.MaxFCnt = 10, 5
+	a.So(group.Validate(), ShouldNotBeNil)
+
+	a.So(Group{McKey: types.AES128Key{1}}.Validate(), ShouldNotBeNil)
+	a.So(Group{McAddr: types.DevAddr{1, 2, 3, 4}}.Validate(), ShouldNotBeNil)
+}
+
+func TestKeys(t *testing.T) {
+	a := New(t)
+
+	appKey := types.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	mcRootKey := DeriveMcRootKey(appKey)
+	a.So(mcRootKey, ShouldNotResemble, appKey)
+	a.So(DeriveMcRootKey(appKey), ShouldResemble, mcRootKey)
+
+	mcKEKey := DeriveMcKEKey(mcRootKey)
+	a.So(mcKEKey, ShouldNotResemble, mcRootKey)
+
+	// The device decrypts the McKey with the AES encryption
+	mcKey := types.AES128Key{0xff, 0xee}
+	a.So(encrypt(mcKEKey, EncryptMcKey(mcKEKey, mcKey)), ShouldResemble, mcKey)
+
+	mcAppSKey, mcNwkSKey := DeriveSessionKeys(mcKey, types.DevAddr{1, 2, 3, 4})
+	a.So(types.AES128Key(mcAppSKey), ShouldNotResemble, types.AES128Key(mcNwkSKey))
+	otherAppSKey, _ := DeriveSessionKeys(mcKey, types.DevAddr{1, 2, 3, 5})
+	a.So(otherAppSKey, ShouldNotResemble, mcAppSKey)
+}
+
+func TestMcGroupSetupReq(t *testing.T) {
+	a := New(t)
+
+	mcKEKey := types.AES128Key{1}
+	group := Group{ID: 2, McAddr: types.DevAddr{1, 2, 3, 4}, McKey: types.AES128Key{2}, MinFCnt: 1, MaxFCnt: 0x0100}
+	payload := McGroupSetupReq(group, mcKEKey)
+	a.So(payload, ShouldHaveLength, 30)
+	a.So(payload[0:6], ShouldResemble, []byte{McGroupSetup, 2, 4, 3, 2, 1})
+	encrypted := EncryptMcKey(mcKEKey, group.McKey)
+	a.So(payload[6:22], ShouldResemble, encrypted[:])
+	a.So(payload[22:30], ShouldResemble, []byte{1, 0, 0, 0, 0, 1, 0, 0})
+
+	a.So(McGroupDeleteReq(3), ShouldResemble, []byte{McGroupDelete, 3})
+}
+
+func TestParseAnswers(t *testing.T) {
+	a := New(t)
+
+	answers, err := ParseAnswers([]byte{
+		PackageVersion, 2, 1,
+		McGroupStatus, 0x12, 1, 1, 2, 3, 4, // one group active
+		McGroupSetup, 0x01,
+		McGroupSetup, 0x06, // ID error
+		McGroupDelete, 0x01,
+		McClassCSession, 0x00, 1, 0, 0, // with TimeToStart
+		McClassBSession, 0x10, // McGroup undefined
+	})
+	a.So(err, ShouldBeNil)
+	a.So(answers, ShouldResemble, []Answer{
+		{Command: PackageVersion},
+		{Command: McGroupStatus},
+		{Command: McGroupSetup, GroupID: 1},
+		{Command: McGroupSetup, GroupID: 2, Error: true},
+		{Command: McGroupDelete, GroupID: 1},
+		{Command: McClassCSession, GroupID: 0},
+		{Command: McClassBSession, GroupID: 0, Error: true},
+	})
+
+	_, err = ParseAnswers([]byte{McGroupSetup})
+	a.So(err, ShouldNotBeNil)
+
+	_, err = ParseAnswers([]byte{0x42, 0x00})
+	a.So(err, ShouldNotBeNil)
+}
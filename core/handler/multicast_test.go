@@ -0,0 +1,78 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestMulticastSetup(t *testing.T) {
+	a := New(t)
+	appID := "app1"
+	devID := "dev1"
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestMulticastSetup")},
+		devices:   device.NewRedisDeviceStore(GetRedisClient(), "handler-test-multicast-setup"),
+		qEvent:    make(chan *types.DeviceEvent, 10),
+	}
+	group := multicast.Group{ID: 1, McAddr: types.DevAddr{1, 2, 3, 4}, McKey: types.AES128Key{1}}
+
+	err := h.SetupMulticastGroup(appID, devID, group)
+	a.So(err, ShouldNotBeNil)
+
+	h.devices.Set(&device.Device{AppID: appID, DevID: devID})
+	defer func() {
+		h.devices.Delete(appID, devID)
+	}()
+
+	// No AppKey to derive the McKEKey from
+	err = h.SetupMulticastGroup(appID, devID, group)
+	a.So(err, ShouldNotBeNil)
+
+	h.devices.Set(&device.Device{AppID: appID, DevID: devID, AppKey: types.AppKey{1}})
+	err = h.SetupMulticastGroup(appID, devID, group)
+	a.So(err, ShouldBeNil)
+
+	queue, _ := h.devices.DownlinkQueue(appID, devID)
+	downlink, _ := queue.Next()
+	a.So(downlink, ShouldNotBeNil)
+	a.So(downlink.FPort, ShouldEqual, multicast.Port)
+	a.So(downlink.PayloadRaw, ShouldHaveLength, 30)
+
+	dev, _ := h.devices.Get(appID, devID)
+	a.So(dev.MulticastGroups, ShouldHaveLength, 1)
+	a.So(dev.MulticastGroups[0].Confirmed, ShouldBeFalse)
+
+	appUp := &types.UplinkMessage{AppID: appID, DevID: devID, FPort: multicast.Port, PayloadRaw: []byte{multicast.McGroupSetup, 0x01}}
+	err = h.HandleMulticastSetup(h.Ctx, nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.MulticastGroups[0].Confirmed, ShouldBeTrue)
+	evt := <-h.qEvent
+	a.So(evt.Event, ShouldEqual, types.MulticastEvent)
+	a.So(evt.Data, ShouldResemble, types.MulticastEventData{Command: "setup", GroupID: 1})
+
+	err = h.DeleteMulticastGroup(appID, devID, 2)
+	a.So(err, ShouldNotBeNil)
+	err = h.DeleteMulticastGroup(appID, devID, 1)
+	a.So(err, ShouldBeNil)
+
+	appUp.PayloadRaw = []byte{multicast.McGroupDelete, 0x01}
+	err = h.HandleMulticastSetup(h.Ctx, nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.MulticastGroups, ShouldBeEmpty)
+	<-h.qEvent
+
+	// Other ports are ignored
+	appUp.FPort = 1
+	err = h.HandleMulticastSetup(h.Ctx, nil, appUp, dev)
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+}
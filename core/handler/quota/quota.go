@@ -0,0 +1,39 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package quota limits the number of downlinks that are enqueued per day
+package quota
+
+import (
+	"fmt"
+	"time"
+)
+
+// Counter counts the downlinks of subjects (devices or applications) per day (in UTC)
+type Counter interface {
+	// Take one downlink from the quota of the subject on the day of now. If the limit is reached, an *ErrExceeded is returned
+	Take(subject string, limit uint, now time.Time) error
+	// Release one downlink that was taken on the day of now, for example when the downlink was rejected by another quota
+	Release(subject string, now time.Time) error
+}
+
+// ErrExceeded is returned when the quota of a subject is reached
+type ErrExceeded struct {
+	Subject string
+	Limit   uint
+	Next    time.Time // The time when the next downlink is allowed
+}
+
+func (err *ErrExceeded) Error() string {
+	return fmt.Sprintf("Downlink quota of %d per day for %s exceeded, next downlink allowed at %s", err.Limit, err.Subject, err.Next.Format(time.RFC3339))
+}
+
+// NextDay returns the start of the day after now (in UTC)
+func NextDay(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+func dayKey(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
@@ -0,0 +1,41 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestNextDay(t *testing.T) {
+	a := New(t)
+	a.So(NextDay(time.Date(2017, 12, 31, 23, 59, 0, 0, time.UTC)), ShouldResemble, time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestRedisCounter(t *testing.T) {
+	a := New(t)
+
+	client := GetRedisClient()
+	c := NewRedisCounter(client, "test-downlink-quota")
+	now := time.Date(2017, 7, 1, 12, 0, 0, 0, time.UTC)
+	defer client.Del(c.(*redisCounter).key("dev", now))
+
+	a.So(c.Take("dev", 2, now), ShouldBeNil)
+	a.So(c.Take("dev", 2, now), ShouldBeNil)
+
+	err := c.Take("dev", 2, now)
+	a.So(err, ShouldNotBeNil)
+	a.So(err.(*ErrExceeded).Next, ShouldResemble, time.Date(2017, 7, 2, 0, 0, 0, 0, time.UTC))
+
+	a.So(c.Release("dev", now), ShouldBeNil)
+	a.So(c.Take("dev", 2, now), ShouldBeNil)
+
+	// The next day has a new quota
+	tomorrow := now.Add(24 * time.Hour)
+	defer client.Del(c.(*redisCounter).key("dev", tomorrow))
+	a.So(c.Take("dev", 2, tomorrow), ShouldBeNil)
+}
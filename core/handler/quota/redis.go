@@ -0,0 +1,49 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package quota
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+// NewRedisCounter creates a new Redis-based quota Counter
+func NewRedisCounter(client *redis.Client, prefix string) Counter {
+	return &redisCounter{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+type redisCounter struct {
+	client *redis.Client
+	prefix string
+}
+
+func (c *redisCounter) key(subject string, now time.Time) string {
+	return fmt.Sprintf("%s:downlink-quota:%s:%s", c.prefix, subject, dayKey(now))
+}
+
+func (c *redisCounter) Take(subject string, limit uint, now time.Time) error {
+	key := c.key(subject, now)
+	count, err := c.client.Incr(key).Result()
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		// Keep the counter a bit longer than the day, so that clock differences between Handlers do not reset it
+		c.client.ExpireAt(key, NextDay(now).Add(time.Hour))
+	}
+	if uint(count) > limit {
+		c.client.Decr(key)
+		return &ErrExceeded{Subject: subject, Limit: limit, Next: NextDay(now)}
+	}
+	return nil
+}
+
+func (c *redisCounter) Release(subject string, now time.Time) error {
+	return c.client.Decr(c.key(subject, now)).Err()
+}
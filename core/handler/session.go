@@ -0,0 +1,65 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Session is the state of the session of a device in the Handler, for inspection by operators. It contains
+// fingerprints of the keys instead of the keys. The frame counters, ADR state and MAC commands of the session are
+// in the NetworkServer.
+type Session struct {
+	AppID   string        `json:"app_id"`
+	DevID   string        `json:"dev_id"`
+	AppEUI  types.AppEUI  `json:"app_eui"`
+	DevEUI  types.DevEUI  `json:"dev_eui"`
+	DevAddr types.DevAddr `json:"dev_addr"`
+
+	AppKey  string `json:"app_key_fingerprint,omitempty"`
+	NwkSKey string `json:"nwk_s_key_fingerprint,omitempty"`
+	AppSKey string `json:"app_s_key_fingerprint,omitempty"`
+
+	ActivationConstraints string `json:"activation_constraints,omitempty"`
+
+	FCntUp uint32 `json:"f_cnt_up"` // of the last uplink
+	device.Connectivity
+
+	// IDs of the multicast groups that the device is in
+	MulticastGroups []uint8 `json:"multicast_groups,omitempty"`
+
+	// The downlink that was sent but not yet acknowledged, followed by the queued downlinks
+	Downlinks []types.DownlinkQueueItem `json:"downlinks"`
+}
+
+// GetSession returns the state of the session of the device
+func (h *handler) GetSession(appID, devID string) (*Session, error) {
+	dev, err := h.devices.Get(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	queue, err := h.DownlinkQueue(appID, devID)
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{
+		AppID:                 dev.AppID,
+		DevID:                 dev.DevID,
+		AppEUI:                dev.AppEUI,
+		DevEUI:                dev.DevEUI,
+		DevAddr:               dev.DevAddr,
+		AppKey:                dev.AppKey.Fingerprint(),
+		NwkSKey:               dev.NwkSKey.Fingerprint(),
+		AppSKey:               dev.AppSKey.Fingerprint(),
+		ActivationConstraints: dev.Options.ActivationConstraints,
+		FCntUp:                dev.FCntUp,
+		Connectivity:          dev.Connectivity(),
+		Downlinks:             queue,
+	}
+	for _, group := range dev.MulticastGroups {
+		session.MulticastGroups = append(session.MulticastGroups, group.ID)
+	}
+	return session, nil
+}
@@ -0,0 +1,46 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// DetectSessionReset emits a session reset event if the device sent a ResetInd or RekeyInd
+func (h *handler) DetectSessionReset(ctx ttnlog.Interface, ttnUp *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) error {
+	macPayload := ttnUp.GetMessage().GetLoRaWAN().GetMACPayload()
+	if macPayload == nil || appUp.IsRetry {
+		return nil
+	}
+	for _, cmd := range macPayload.FOpts {
+		var command string
+		switch cmd.CID {
+		case types.ResetInd:
+			command = "reset"
+		case types.RekeyInd:
+			command = "rekey"
+		default:
+			continue
+		}
+		var minor uint8
+		if len(cmd.Payload) > 0 {
+			minor = cmd.Payload[0] & 0x0F
+		}
+		ctx.WithField("Command", command).Info("Device session was reset")
+		h.qEvent <- &types.DeviceEvent{
+			AppID: appUp.AppID,
+			DevID: appUp.DevID,
+			Event: types.SessionResetEvent,
+			Data: types.SessionResetEventData{
+				Command: command,
+				Minor:   minor,
+				FCnt:    appUp.FCnt,
+			},
+		}
+	}
+	return nil
+}
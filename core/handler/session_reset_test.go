@@ -0,0 +1,49 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDetectSessionReset(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestDetectSessionReset")},
+		qEvent:    make(chan *types.DeviceEvent, 10),
+	}
+
+	ttnUp := &pb_broker.DeduplicatedUplinkMessage{Message: new(pb_protocol.Message)}
+	ttnUp.Message.InitLoRaWAN().InitUplink()
+	appUp := &types.UplinkMessage{AppID: "app", DevID: "dev", FCnt: 0}
+
+	err := h.DetectSessionReset(h.Ctx, ttnUp, appUp, &device.Device{})
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	ttnUp.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{
+		pb_lorawan.MACCommand{CID: types.ResetInd, Payload: []byte{0x01}},
+	}
+	err = h.DetectSessionReset(h.Ctx, ttnUp, appUp, &device.Device{})
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	evt := <-h.qEvent
+	a.So(evt.Event, ShouldEqual, types.SessionResetEvent)
+	a.So(evt.Data, ShouldResemble, types.SessionResetEventData{Command: "reset", Minor: 1})
+
+	// Retries are not reported again
+	appUp.IsRetry = true
+	err = h.DetectSessionReset(h.Ctx, ttnUp, appUp, &device.Device{})
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/multicast"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGetSession(t *testing.T) {
+	a := New(t)
+	appID := "app1"
+	devID := "dev1"
+	h := &handler{
+		Component: &component.Component{Ctx: GetLogger(t, "TestGetSession")},
+		devices:   device.NewRedisDeviceStore(GetRedisClient(), "handler-test-get-session"),
+	}
+
+	_, err := h.GetSession(appID, devID)
+	a.So(err, ShouldNotBeNil)
+
+	h.devices.Set(&device.Device{
+		AppID:           appID,
+		DevID:           devID,
+		DevAddr:         types.DevAddr{1, 2, 3, 4},
+		NwkSKey:         types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 249, 250, 251, 252, 253, 254, 255, 0},
+		FCntUp:          42,
+		MulticastGroups: []multicast.Group{{ID: 1, McKey: types.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}}},
+		CurrentDownlink: &types.DownlinkMessage{ID: "sent", FPort: 1, PayloadRaw: []byte{1, 2, 3, 4}},
+	})
+	defer func() {
+		h.devices.Delete(appID, devID)
+	}()
+
+	session, err := h.GetSession(appID, devID)
+	a.So(err, ShouldBeNil)
+	a.So(session.DevAddr, ShouldEqual, types.DevAddr{1, 2, 3, 4})
+	a.So(session.NwkSKey, ShouldEqual, "D28DFCBF")
+	a.So(session.AppSKey, ShouldBeEmpty)
+	a.So(session.FCntUp, ShouldEqual, 42)
+	a.So(session.MulticastGroups, ShouldResemble, []uint8{1})
+	a.So(session.Downlinks, ShouldHaveLength, 1)
+	a.So(session.Downlinks[0].ID, ShouldEqual, "sent")
+}
@@ -0,0 +1,99 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// SilenceCheckInterval is the interval in which devices are checked for silence
+var SilenceCheckInterval = time.Minute
+
+func (h *handler) WithSilentEvents(factor float64) Handler {
+	h.silenceFactor = factor
+	return h
+}
+
+// silent returns true if a silent event should be emitted for the device. The first event is emitted when the
+// device was not seen within the silence factor times its expected uplink period, and the event is repeated every
+// uplink period while the device stays silent.
+func (h *handler) silent(dev *device.Device, now time.Time) (period time.Duration, learned bool, silent bool) {
+	period, learned = dev.ExpectedUplinkPeriod()
+	if period == 0 || dev.LastSeen.IsZero() {
+		return period, learned, false
+	}
+	if now.Sub(dev.LastSeen) < time.Duration(h.silenceFactor*float64(period)) {
+		return period, learned, false
+	}
+	if dev.LastSilentEvent.After(dev.LastSeen) && now.Sub(dev.LastSilentEvent) < period {
+		return period, learned, false
+	}
+	return period, learned, true
+}
+
+// scheduleSilenceCheck schedules the check of the device for the time at which it becomes silent, or the time at which
+// the next silent event is due
+func (h *handler) scheduleSilenceCheck(dev *device.Device, now time.Time) error {
+	period, _ := dev.ExpectedUplinkPeriod()
+	if period == 0 || dev.LastSeen.IsZero() {
+		return nil // Scheduled again after the next uplink
+	}
+	at := dev.LastSeen.Add(time.Duration(h.silenceFactor * float64(period)))
+	if !at.After(now) {
+		at = now.Add(period)
+	}
+	return h.devices.ScheduleSilenceCheck(dev.AppID, dev.DevID, at)
+}
+
+// checkSilence emits silent events for the devices of which the silence check is due and that have not transmitted
+// within their expected uplink period
+func (h *handler) checkSilence() error {
+	now := time.Now()
+	devices, err := h.devices.ClaimSilenceChecks(now)
+	if err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		ctx := h.Ctx.WithField("AppID", dev.AppID).WithField("DevID", dev.DevID)
+		period, learned, silent := h.silent(dev, now)
+		if !silent {
+			if err := h.scheduleSilenceCheck(dev, now); err != nil {
+				ctx.WithError(err).Warn("Could not schedule silence check")
+			}
+			continue
+		}
+		if err := h.devices.ScheduleSilenceCheck(dev.AppID, dev.DevID, now.Add(period)); err != nil {
+			ctx.WithError(err).Warn("Could not schedule silence check")
+		}
+		dev.StartUpdate()
+		dev.LastSilentEvent = now
+		if err := h.devices.Set(dev); err != nil {
+			ctx.WithError(err).Warn("Could not update silent device")
+			continue
+		}
+		h.qEvent <- &types.DeviceEvent{
+			AppID: dev.AppID,
+			DevID: dev.DevID,
+			Event: types.SilentEvent,
+			Data: types.SilentEventData{
+				LastSeen:     types.JSONTime(dev.LastSeen),
+				UplinkPeriod: period.String(),
+				Learned:      learned,
+				SilentFor:    (now.Sub(dev.LastSeen) / time.Second * time.Second).String(),
+			},
+		}
+	}
+	return nil
+}
+
+func (h *handler) monitorSilence() {
+	for range time.Tick(SilenceCheckInterval) {
+		if err := h.checkSilence(); err != nil {
+			h.Ctx.WithError(err).Warn("Could not check device silence")
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestCheckSilence(t *testing.T) {
+	a := New(t)
+	h := &handler{
+		Component:     &component.Component{Ctx: GetLogger(t, "TestCheckSilence")},
+		devices:       device.NewRedisDeviceStore(GetRedisClient(), "handler-test-check-silence"),
+		qEvent:        make(chan *types.DeviceEvent, 10),
+		silenceFactor: 2,
+	}
+
+	appID := "app"
+	now := time.Now()
+	h.devices.Set(&device.Device{AppID: appID, DevID: "unknown-period", LastSeen: now.Add(-24 * time.Hour)})
+	defer h.devices.Delete(appID, "unknown-period")
+	h.devices.Set(&device.Device{AppID: appID, DevID: "learned", LastSeen: now.Add(-15 * time.Minute), UplinkPeriod: 10 * time.Minute, UplinkPeriodSamples: 5})
+	defer h.devices.Delete(appID, "learned")
+	h.devices.Set(&device.Device{AppID: appID, DevID: "configured", LastSeen: now.Add(-3 * time.Hour), Attributes: map[string]string{device.UplinkPeriodAttribute: "1h"}})
+	defer h.devices.Delete(appID, "configured")
+	for _, devID := range []string{"unknown-period", "learned", "configured"} {
+		h.devices.ScheduleSilenceCheck(appID, devID, now)
+	}
+
+	err := h.checkSilence()
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 1)
+	event := <-h.qEvent
+	a.So(event.DevID, ShouldEqual, "configured")
+	a.So(event.Event, ShouldEqual, types.SilentEvent)
+	a.So(event.Data.(types.SilentEventData).UplinkPeriod, ShouldEqual, "1h0m0s")
+	a.So(event.Data.(types.SilentEventData).Learned, ShouldBeFalse)
+
+	// The event is repeated every uplink period, and the devices that are not silent yet are checked again later
+	err = h.checkSilence()
+	a.So(err, ShouldBeNil)
+	a.So(h.qEvent, ShouldHaveLength, 0)
+
+	dev, _ := h.devices.Get(appID, "configured")
+	_, _, silent := h.silent(dev, now.Add(30*time.Minute))
+	a.So(silent, ShouldBeFalse)
+	_, _, silent = h.silent(dev, now.Add(90*time.Minute))
+	a.So(silent, ShouldBeTrue)
+
+	dev, _ = h.devices.Get(appID, "learned")
+	_, learned, silent := h.silent(dev, now.Add(10*time.Minute))
+	a.So(learned, ShouldBeTrue)
+	a.So(silent, ShouldBeTrue)
+}
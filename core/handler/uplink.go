@@ -10,6 +10,7 @@ import (
 	"github.com/TheThingsNetwork/api/logfields"
 	"github.com/TheThingsNetwork/api/trace"
 	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/budget"
 )
 
 // ResponseDeadline indicates how long
@@ -58,8 +59,13 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 	// Get Uplink Processors
 	processors := []UplinkProcessor{
 		h.ConvertFromLoRaWAN,
+		h.DetectSessionReset,
+		h.HandleMulticastSetup,
 		h.ConvertMetadata,
+		h.DetectAnomalies,
+		h.UpdateConnectivity,
 		h.ConvertFieldsUp,
+		h.EvaluateAlerts,
 	}
 
 	ctx.WithField("NumProcessors", len(processors)).Debug("Running Uplink Processors")
@@ -76,6 +82,8 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 		}
 	}
 
+	publish := h.filterUplink(ctx, uplink, appUplink, dev)
+
 	err = h.devices.Set(dev)
 	if err != nil {
 		return err
@@ -83,7 +91,9 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 	dev.StartUpdate()
 
 	// Publish Uplink
-	h.qUp <- appUplink
+	if publish {
+		h.qUp <- appUplink
+	}
 
 	noDownlinkErrEvent := &types.DeviceEvent{
 		AppID: appID,
@@ -92,8 +102,19 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 		Data:  types.ErrorEventData{Error: "No gateways available for downlink"},
 	}
 
+	deadlineExceededErrEvent := &types.DeviceEvent{
+		AppID: appID,
+		DevID: devID,
+		Event: types.DownlinkErrorEvent,
+		Data:  types.ErrorEventData{Error: "Deadline exceeded for downlink"},
+	}
+
+	// The application downlink is kept for the next uplink if it can not make it in time. The response of the
+	// Network Server (ACKs and MAC commands) is still sent, as the Broker can fall back to RX2.
+	var late bool
+	deadline := responseDeadline(uplink)
 	if dev.CurrentDownlink == nil {
-		<-time.After(ResponseDeadline)
+		<-time.After(budget.Remaining(deadline, ResponseDeadline))
 
 		queue, err := h.devices.DownlinkQueue(appID, devID)
 		if err != nil {
@@ -101,17 +122,47 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 		}
 
 		if len, _ := queue.Length(); len > 0 {
-			if uplink.ResponseTemplate != nil {
-				next, err := queue.Next()
+			if uplink.ResponseTemplate != nil && budget.Expired(deadline) {
+				ctx.Warn("Deadline exceeded for downlink")
+				h.qEvent <- deadlineExceededErrEvent
+			} else if uplink.ResponseTemplate != nil {
+				expired, err := h.devices.SetNextDownlink(dev)
 				if err != nil {
 					return err
 				}
-				dev.CurrentDownlink = next
+				for _, msg := range expired {
+					msg.AppID, msg.DevID = appID, devID
+					h.qEvent <- &types.DeviceEvent{
+						AppID: appID,
+						DevID: devID,
+						Event: types.DownlinkErrorEvent,
+						Data: types.DownlinkEventData{
+							ErrorEventData: types.ErrorEventData{Error: "Downlink expired"},
+							Message:        msg,
+						},
+					}
+					h.publishDownlinkStatus(appID, devID, types.DownlinkStatusEventData{
+						ErrorEventData: types.ErrorEventData{Error: "Downlink expired"},
+						ID:             msg.ID,
+						Status:         types.DownlinkStatusFailed,
+					})
+				}
+				if dev.CurrentDownlink != nil {
+					h.publishDownlinkStatus(appID, devID, types.DownlinkStatusEventData{
+						ID:     dev.CurrentDownlink.ID,
+						Status: types.DownlinkStatusScheduled,
+					})
+				}
+				dev.StartUpdate()
 			} else {
 				h.qEvent <- noDownlinkErrEvent
 				return nil
 			}
 		}
+	} else if uplink.ResponseTemplate != nil && budget.Expired(deadline) {
+		ctx.Warn("Deadline exceeded for downlink")
+		h.qEvent <- deadlineExceededErrEvent
+		late = true
 	}
 
 	if uplink.ResponseTemplate == nil {
@@ -129,11 +180,12 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 
 	// Prepare Downlink
 	var appDownlink types.DownlinkMessage
-	if dev.CurrentDownlink != nil {
+	if dev.CurrentDownlink != nil && !late {
 		appDownlink = *dev.CurrentDownlink
 	}
 	appDownlink.AppID = uplink.AppID
 	appDownlink.DevID = uplink.DevID
+
 	downlink := uplink.ResponseTemplate
 	downlink.Trace = uplink.Trace.WithEvent("prepare downlink")
 
@@ -145,3 +197,17 @@ func (h *handler) HandleUplink(uplink *pb_broker.DeduplicatedUplinkMessage) (err
 
 	return nil
 }
+
+// responseDeadline returns the time at which the response to the uplink must be ready
+func responseDeadline(uplink *pb_broker.DeduplicatedUplinkMessage) time.Time {
+	option := uplink.GetResponseTemplate().GetDownlinkOption()
+	if option == nil || uplink.ServerTime == 0 {
+		return time.Time{}
+	}
+	for _, gtw := range uplink.GatewayMetadata {
+		if gtw.GatewayID == option.GatewayID {
+			return budget.Deadline(time.Unix(0, uplink.ServerTime), gtw.Timestamp, option.GatewayConfiguration.Timestamp)
+		}
+	}
+	return time.Time{}
+}
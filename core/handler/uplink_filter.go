@@ -0,0 +1,44 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// SetUplinkFilters replaces the uplink filter rules of the application
+func (h *handler) SetUplinkFilters(appID string, rules []filter.Rule) error {
+	if err := filter.ValidateRules(rules); err != nil {
+		return err
+	}
+	app, err := h.applications.Get(appID)
+	if err != nil {
+		return err
+	}
+	app.StartUpdate()
+	app.UplinkFilters = rules
+	return h.applications.Set(app)
+}
+
+// filterUplink evaluates the uplink filter rules of the application and returns false if the uplink should not be
+// published. Filtered uplinks are still handled, so that the device state is updated and downlinks are sent.
+func (h *handler) filterUplink(ctx ttnlog.Interface, uplink *pb_broker.DeduplicatedUplinkMessage, appUp *types.UplinkMessage, dev *device.Device) bool {
+	app, err := h.applications.Get(appUp.AppID)
+	if err != nil || len(app.UplinkFilters) == 0 {
+		return true
+	}
+	rule := filter.Evaluate(app.UplinkFilters, &dev.UplinkFilter, appUp.FPort, appUp.PayloadRaw, time.Now())
+	if rule == nil {
+		return true
+	}
+	ctx.WithField("RuleID", rule.ID).WithField("Action", rule.Action).Debug("Filtered uplink")
+	uplink.Trace = uplink.Trace.WithEvent("filter uplink", "rule", rule.ID, "action", rule.Action)
+	return false
+}
@@ -0,0 +1,55 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handler
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/handler/application"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/handler/filter"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestFilterUplink(t *testing.T) {
+	a := New(t)
+	ctx := GetLogger(t, "TestFilterUplink")
+	h := &handler{
+		Component:    &component.Component{Ctx: ctx},
+		applications: application.NewRedisApplicationStore(GetRedisClient(), "handler-test-filter-uplink"),
+	}
+
+	appID, devID := "app", "dev"
+	h.applications.Set(&application.Application{AppID: appID})
+	defer h.applications.Delete(appID)
+
+	dev := &device.Device{AppID: appID, DevID: devID}
+	appUp := &types.UplinkMessage{AppID: appID, DevID: devID, FPort: 1, PayloadRaw: []byte{1, 2}}
+
+	// No rules
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeTrue)
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeTrue)
+
+	err := h.SetUplinkFilters(appID, []filter.Rule{{ID: "invalid"}})
+	a.So(err, ShouldNotBeNil)
+
+	err = h.SetUplinkFilters(appID, []filter.Rule{
+		{ID: "debug", Action: filter.ActionDrop, FPorts: []uint8{100}},
+		{ID: "collapse", Action: filter.ActionCollapse},
+	})
+	a.So(err, ShouldBeNil)
+
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeTrue)
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeFalse)
+
+	appUp.PayloadRaw = []byte{1, 3}
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeTrue)
+
+	appUp.FPort = 100
+	a.So(h.filterUplink(ctx, &pb_broker.DeduplicatedUplinkMessage{}, appUp, dev), ShouldBeFalse)
+}
@@ -0,0 +1,143 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package handshake lets components exchange their protocol version, packet formats and features in gRPC metadata,
+// so that incompatible components refuse to talk to each other instead of exchanging packets that they can not
+// interpret.
+package handshake
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+// ProtocolVersion is the version of the protocol between components, formatted as major.minor. Components with a
+// different major version are incompatible.
+const ProtocolVersion = "2.0"
+
+// PacketFormats are the versions of the binary packet format that this build can read and write
+var PacketFormats = []int{1}
+
+// Features
+const (
+	FeatureMulticast = "multicast"
+	FeatureLoRaWAN11 = "lorawan-1.1"
+)
+
+// Metadata keys
+const (
+	protocolVersionKey = "ttn-protocol-version"
+	packetFormatsKey   = "ttn-packet-formats"
+	featuresKey        = "ttn-features"
+)
+
+// Hello is what a component tells about itself
+type Hello struct {
+	ProtocolVersion string
+	PacketFormats   []int
+	Features        []string
+}
+
+// Local returns the Hello of this build with the features
+func Local(features ...string) Hello {
+	return Hello{
+		ProtocolVersion: ProtocolVersion,
+		PacketFormats:   PacketFormats,
+		Features:        features,
+	}
+}
+
+// Metadata returns the Hello as gRPC metadata
+func (h Hello) Metadata() metadata.MD {
+	formats := make([]string, len(h.PacketFormats))
+	for i, format := range h.PacketFormats {
+		formats[i] = strconv.Itoa(format)
+	}
+	return metadata.Pairs(
+		protocolVersionKey, h.ProtocolVersion,
+		packetFormatsKey, strings.Join(formats, ","),
+		featuresKey, strings.Join(h.Features, ","),
+	)
+}
+
+// FromMetadata reads the Hello from gRPC metadata. It returns false if the metadata contains no Hello, which is
+// the case for components that were built before the handshake was introduced.
+func FromMetadata(md metadata.MD) (hello Hello, ok bool) {
+	get := func(key string) string {
+		if values := md[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	hello.ProtocolVersion = get(protocolVersionKey)
+	if hello.ProtocolVersion == "" {
+		return hello, false
+	}
+	for _, format := range strings.Split(get(packetFormatsKey), ",") {
+		if format, err := strconv.Atoi(format); err == nil {
+			hello.PacketFormats = append(hello.PacketFormats, format)
+		}
+	}
+	for _, feature := range strings.Split(get(featuresKey), ",") {
+		if feature != "" {
+			hello.Features = append(hello.Features, feature)
+		}
+	}
+	return hello, true
+}
+
+// Agreement is the result of a handshake
+type Agreement struct {
+	PacketFormat int      // the newest packet format that both components support
+	Features     []string // the features that both components support
+}
+
+// Supports returns true if both components support the feature
+func (a Agreement) Supports(feature string) bool {
+	for _, f := range a.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+func major(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
+}
+
+// Negotiate checks that the remote component is compatible with the local component and returns what they agree on
+func Negotiate(local, remote Hello) (*Agreement, error) {
+	if major(local.ProtocolVersion) != major(remote.ProtocolVersion) {
+		return nil, errors.NewErrInvalidArgument("Protocol version", fmt.Sprintf("%s is incompatible with %s", remote.ProtocolVersion, local.ProtocolVersion))
+	}
+
+	agreement := &Agreement{}
+	for _, format := range local.PacketFormats {
+		for _, remoteFormat := range remote.PacketFormats {
+			if format == remoteFormat && format > agreement.PacketFormat {
+				agreement.PacketFormat = format
+			}
+		}
+	}
+	if agreement.PacketFormat == 0 {
+		return nil, errors.NewErrInvalidArgument("Packet formats", fmt.Sprintf("%v have nothing in common with %v", remote.PacketFormats, local.PacketFormats))
+	}
+
+	remoteFeatures := make(map[string]bool, len(remote.Features))
+	for _, feature := range remote.Features {
+		remoteFeatures[feature] = true
+	}
+	for _, feature := range local.Features {
+		if remoteFeatures[feature] {
+			agreement.Features = append(agreement.Features, feature)
+		}
+	}
+	sort.Strings(agreement.Features)
+	return agreement, nil
+}
@@ -0,0 +1,50 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package handshake
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHelloMetadata(t *testing.T) {
+	a := New(t)
+
+	_, ok := FromMetadata(metadata.Pairs("id", "handler"))
+	a.So(ok, ShouldBeFalse)
+
+	hello, ok := FromMetadata(Local(FeatureMulticast).Metadata())
+	a.So(ok, ShouldBeTrue)
+	a.So(hello.ProtocolVersion, ShouldEqual, ProtocolVersion)
+	a.So(hello.PacketFormats, ShouldResemble, PacketFormats)
+	a.So(hello.Features, ShouldResemble, []string{FeatureMulticast})
+
+	hello, ok = FromMetadata(Local().Metadata())
+	a.So(ok, ShouldBeTrue)
+	a.So(hello.Features, ShouldBeEmpty)
+}
+
+func TestNegotiate(t *testing.T) {
+	a := New(t)
+
+	local := Hello{ProtocolVersion: "2.1", PacketFormats: []int{1, 2}, Features: []string{FeatureMulticast, FeatureLoRaWAN11}}
+
+	agreement, err := Negotiate(local, Hello{ProtocolVersion: "2.0", PacketFormats: []int{1}, Features: []string{FeatureMulticast}})
+	a.So(err, ShouldBeNil)
+	a.So(agreement.PacketFormat, ShouldEqual, 1)
+	a.So(agreement.Supports(FeatureMulticast), ShouldBeTrue)
+	a.So(agreement.Supports(FeatureLoRaWAN11), ShouldBeFalse)
+
+	agreement, err = Negotiate(local, Hello{ProtocolVersion: "2.3", PacketFormats: []int{3, 2, 1}})
+	a.So(err, ShouldBeNil)
+	a.So(agreement.PacketFormat, ShouldEqual, 2)
+
+	_, err = Negotiate(local, Hello{ProtocolVersion: "3.0", PacketFormats: []int{1}})
+	a.So(err, ShouldNotBeNil)
+
+	_, err = Negotiate(local, Hello{ProtocolVersion: "2.0", PacketFormats: []int{3}})
+	a.So(err, ShouldNotBeNil)
+}
@@ -0,0 +1,170 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package invariant checks the structural invariants of the packets that components exchange, such as the
+// presence of the payload, non-zero EUIs where they are required and the completeness of the metadata
+package invariant
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_router "github.com/TheThingsNetwork/api/router"
+)
+
+// Types of violations
+const (
+	Invalid         = "invalid" // the Validate method of the packet returned an error
+	MissingPayload  = "missing_payload"
+	MissingEUI      = "missing_eui"
+	MissingID       = "missing_id"
+	MissingMetadata = "missing_metadata"
+)
+
+// Validator is implemented by packets that check their own structure
+type Validator interface {
+	Validate() error
+}
+
+// Violation is a violated invariant of a packet
+type Violation struct {
+	Packet string
+	Type   string
+	Field  string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s violates %s of %s", v.Packet, v.Type, v.Field)
+}
+
+// PacketName returns the name of the type of the packet, such as broker.DeduplicatedUplinkMessage
+func PacketName(packet interface{}) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", packet), "*")
+}
+
+type checker struct {
+	packet     string
+	violations []Violation
+}
+
+func (c *checker) violate(violation, field string) {
+	c.violations = append(c.violations, Violation{Packet: c.packet, Type: violation, Field: field})
+}
+
+func (c *checker) payload(payload []byte) {
+	if len(payload) == 0 {
+		c.violate(MissingPayload, "payload")
+	}
+}
+
+// isEmpty returns true if the EUI (or a pointer to it) is nil or zero
+func isEmpty(eui interface{}) bool {
+	if v := reflect.ValueOf(eui); v.Kind() == reflect.Ptr && v.IsNil() {
+		return true
+	}
+	if eui, ok := eui.(interface {
+		IsEmpty() bool
+	}); ok {
+		return eui.IsEmpty()
+	}
+	return false
+}
+
+func (c *checker) euis(appEUI, devEUI interface{}) {
+	if isEmpty(appEUI) {
+		c.violate(MissingEUI, "app_eui")
+	}
+	if isEmpty(devEUI) {
+		c.violate(MissingEUI, "dev_eui")
+	}
+}
+
+func (c *checker) ids(appID, devID string) {
+	if appID == "" {
+		c.violate(MissingID, "app_id")
+	}
+	if devID == "" {
+		c.violate(MissingID, "dev_id")
+	}
+}
+
+func (c *checker) gatewayMetadata(md *pb_gateway.RxMetadata) {
+	if md == nil {
+		c.violate(MissingMetadata, "gateway_metadata")
+		return
+	}
+	if md.GatewayID == "" {
+		c.violate(MissingMetadata, "gateway_metadata.gateway_id")
+	}
+	if md.Frequency == 0 {
+		c.violate(MissingMetadata, "gateway_metadata.frequency")
+	}
+}
+
+func (c *checker) protocolMetadata(md *pb_protocol.RxMetadata) {
+	lorawan := md.GetLoRaWAN()
+	if lorawan == nil {
+		c.violate(MissingMetadata, "protocol_metadata")
+		return
+	}
+	if lorawan.DataRate == "" {
+		c.violate(MissingMetadata, "protocol_metadata.data_rate")
+	}
+}
+
+// Check returns the violated invariants of the packet. Packets that implement Validator are also validated.
+func Check(packet interface{}) []Violation {
+	c := &checker{packet: PacketName(packet)}
+
+	if v, ok := packet.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			c.violate(Invalid, err.Error())
+		}
+	}
+
+	switch p := packet.(type) {
+	case *pb_router.UplinkMessage:
+		c.payload(p.Payload)
+		c.gatewayMetadata(&p.GatewayMetadata)
+		c.protocolMetadata(&p.ProtocolMetadata)
+	case *pb_router.DeviceActivationRequest:
+		c.payload(p.Payload)
+		c.gatewayMetadata(&p.GatewayMetadata)
+		c.protocolMetadata(&p.ProtocolMetadata)
+	case *pb_broker.UplinkMessage:
+		c.payload(p.Payload)
+		c.gatewayMetadata(&p.GatewayMetadata)
+		c.protocolMetadata(&p.ProtocolMetadata)
+	case *pb_broker.DeviceActivationRequest:
+		c.payload(p.Payload)
+		c.euis(p.AppEUI, p.DevEUI)
+		c.gatewayMetadata(&p.GatewayMetadata)
+		c.protocolMetadata(&p.ProtocolMetadata)
+	case *pb_broker.DeduplicatedUplinkMessage:
+		c.payload(p.Payload)
+		c.euis(p.AppEUI, p.DevEUI)
+		c.ids(p.AppID, p.DevID)
+		if len(p.GatewayMetadata) == 0 {
+			c.violate(MissingMetadata, "gateway_metadata")
+		}
+		c.protocolMetadata(&p.ProtocolMetadata)
+	case *pb_broker.DeduplicatedDeviceActivationRequest:
+		c.payload(p.Payload)
+		c.euis(p.AppEUI, p.DevEUI)
+		if len(p.GatewayMetadata) == 0 {
+			c.violate(MissingMetadata, "gateway_metadata")
+		}
+	case *pb_broker.DownlinkMessage:
+		c.payload(p.Payload)
+		c.euis(p.AppEUI, p.DevEUI)
+		if p.DownlinkOption == nil {
+			c.violate(MissingMetadata, "downlink_option")
+		}
+	}
+
+	return c.violations
+}
@@ -0,0 +1,64 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package invariant
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb_router "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func violationTypes(violations []Violation) (types []string) {
+	for _, violation := range violations {
+		if violation.Type != Invalid {
+			types = append(types, violation.Type+":"+violation.Field)
+		}
+	}
+	return
+}
+
+func TestCheck(t *testing.T) {
+	a := New(t)
+
+	protocolMetadata := pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{
+		DataRate: "SF7BW125",
+	}}}
+	gatewayMetadata := pb_gateway.RxMetadata{GatewayID: "test", Frequency: 868100000}
+
+	a.So(violationTypes(Check(&pb_router.UplinkMessage{
+		Payload:          []byte{1, 2, 3},
+		GatewayMetadata:  gatewayMetadata,
+		ProtocolMetadata: protocolMetadata,
+	})), ShouldBeEmpty)
+
+	a.So(violationTypes(Check(&pb_router.UplinkMessage{})), ShouldResemble, []string{
+		"missing_payload:payload",
+		"missing_metadata:gateway_metadata.gateway_id",
+		"missing_metadata:gateway_metadata.frequency",
+		"missing_metadata:protocol_metadata",
+	})
+
+	appEUI := types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8}
+	a.So(violationTypes(Check(&pb_broker.DeduplicatedUplinkMessage{
+		Payload:          []byte{1, 2, 3},
+		AppEUI:           &appEUI,
+		AppID:            "app",
+		ProtocolMetadata: protocolMetadata,
+	})), ShouldResemble, []string{
+		"missing_eui:dev_eui",
+		"missing_id:dev_id",
+		"missing_metadata:gateway_metadata",
+	})
+
+	a.So(violationTypes(Check(&pb_broker.DownlinkMessage{Payload: []byte{1}})), ShouldContain, "missing_metadata:downlink_option")
+
+	a.So(PacketName(&pb_broker.DeduplicatedUplinkMessage{}), ShouldEqual, "broker.DeduplicatedUplinkMessage")
+	a.So(Check(struct{}{}), ShouldBeEmpty)
+}
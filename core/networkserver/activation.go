@@ -11,7 +11,7 @@ import (
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	pb_handler "github.com/TheThingsNetwork/api/handler"
 	"github.com/TheThingsNetwork/api/trace"
-	"github.com/TheThingsNetwork/go-utils/pseudorandom"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
@@ -21,23 +21,66 @@ import (
 var emptyDevEUI = types.DevEUI{}
 
 func (n *networkServer) getDevAddr(constraints ...string) (types.DevAddr, error) {
-	// Generate random DevAddr bytes
-	var devAddr types.DevAddr
-	pseudorandom.FillBytes(devAddr[:])
-
-	// Get a random prefix that matches the constraints
+	// Get the prefixes that match the constraints
 	prefixes := n.GetPrefixesFor(constraints...)
 	if len(prefixes) == 0 {
 		return types.DevAddr{}, errors.NewErrNotFound(fmt.Sprintf("DevAddr prefix with constraints %v", constraints))
 	}
 
-	// Select a prefix
-	prefix := prefixes[pseudorandom.Intn(len(prefixes))]
+	return n.getAllocator().Allocate(prefixes)
+}
 
-	// Apply the prefix
-	devAddr = devAddr.WithPrefix(prefix)
+func (n *networkServer) getAllocator() devaddr.Allocator {
+	if n.allocator == nil {
+		n.allocator = devaddr.NewRandomAllocator(n.countForAddress)
+	}
+	return n.allocator
+}
+
+// activationConstraints returns the constraints for the DevAddr prefixes of a device that joins
+func activationConstraints(dev *device.Device) []string {
+	constraints := strings.Split(dev.Options.ActivationConstraints, ",")
+	if len(constraints) == 1 && constraints[0] == "" {
+		constraints = []string{}
+	}
+	return append(constraints, "otaa")
+}
 
-	return devAddr, nil
+// reserveDevAddr checks the DevAddr of an activation before it is assigned to the device. The Handler may have
+// replaced the allocated DevAddr with the DevAddr of the join webhook of the application, so it must be in the
+// prefixes for the device, and it must not be used by other devices. A static DevAddr of the device is always valid.
+func (n *networkServer) reserveDevAddr(dev *device.Device, devAddr types.DevAddr) error {
+	reserved, ok, err := n.getReservedDevAddr(dev.AppEUI, dev.DevEUI)
+	if err != nil {
+		return err
+	}
+	if ok && reserved == devAddr {
+		return nil
+	}
+	prefixes := n.GetPrefixesFor(activationConstraints(dev)...)
+	if devAddr == dev.DevAddr {
+		// The DevAddr is used by the device itself
+		if !devaddr.InPrefixes(prefixes, devAddr) {
+			return errors.NewErrInvalidArgument("DevAddr", fmt.Sprintf("%s is not in prefixes %v", devAddr, prefixes))
+		}
+		return nil
+	}
+	return n.getAllocator().Reserve(prefixes, devAddr)
+}
+
+// getReservedDevAddr returns the static DevAddr of the device, if it has one
+func (n *networkServer) getReservedDevAddr(appEUI types.AppEUI, devEUI types.DevEUI) (devAddr types.DevAddr, ok bool, err error) {
+	if n.reservations == nil {
+		return devAddr, false, nil
+	}
+	devAddr, err = n.reservations.Get(appEUI, devEUI)
+	if errors.IsNotFound(err) {
+		return devAddr, false, nil
+	}
+	if err != nil {
+		return devAddr, false, err
+	}
+	return devAddr, true, nil
 }
 
 func (n *networkServer) HandlePrepareActivation(activation *pb_broker.DeduplicatedDeviceActivationRequest) (*pb_broker.DeduplicatedDeviceActivationRequest, error) {
@@ -63,11 +106,7 @@ func (n *networkServer) HandlePrepareActivation(activation *pb_broker.Deduplicat
 	}
 
 	// Get activation constraints (for DevAddr prefix selection)
-	activationConstraints := strings.Split(dev.Options.ActivationConstraints, ",")
-	if len(activationConstraints) == 1 && activationConstraints[0] == "" {
-		activationConstraints = []string{}
-	}
-	activationConstraints = append(activationConstraints, "otaa")
+	constraints := activationConstraints(dev)
 
 	// We can only activate LoRaWAN devices
 	lorawanMeta := activation.GetActivationMetadata().GetLoRaWAN()
@@ -76,11 +115,19 @@ func (n *networkServer) HandlePrepareActivation(activation *pb_broker.Deduplicat
 	}
 
 	// Allocate a  device address
-	activation.Trace = activation.Trace.WithEvent("allocate devaddr")
-	devAddr, err := n.getDevAddr(activationConstraints...)
+	devAddr, reserved, err := n.getReservedDevAddr(activation.AppEUI, activation.DevEUI)
 	if err != nil {
 		return nil, err
 	}
+	if reserved {
+		activation.Trace = activation.Trace.WithEvent("use reserved devaddr")
+	} else {
+		activation.Trace = activation.Trace.WithEvent("allocate devaddr")
+		devAddr, err = n.getDevAddr(constraints...)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Set the DevAddr in the Activation Metadata
 	lorawanMeta.DevAddr = &devAddr
@@ -124,11 +171,19 @@ func (n *networkServer) HandleActivate(activation *pb_handler.DeviceActivationRe
 	}
 	n.status.activations.Mark(1)
 
+	if lorawan.DevAddr == nil || lorawan.NwkSKey == nil {
+		return nil, errors.NewErrInvalidArgument("Activation", "missing DevAddr or NwkSKey")
+	}
+
 	dev, err := n.devices.Get(lorawan.AppEUI, lorawan.DevEUI)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := n.reserveDevAddr(dev, *lorawan.DevAddr); err != nil {
+		return nil, err
+	}
+
 	activation.Trace = activation.Trace.WithEvent(trace.UpdateStateEvent)
 	dev.StartUpdate()
 
@@ -138,7 +193,7 @@ func (n *networkServer) HandleActivate(activation *pb_handler.DeviceActivationRe
 	dev.NwkSKey = *lorawan.NwkSKey
 	dev.FCntUp = 0
 	dev.FCntDown = 0
-	dev.ADR = device.ADRSettings{Band: dev.ADR.Band, Margin: dev.ADR.Margin}
+	dev.ResetMACState()
 
 	if band := md.GetLoRaWAN().GetFrequencyPlan().String(); band != "" {
 		dev.ADR.Band = band
@@ -10,6 +10,7 @@ import (
 	pb_handler "github.com/TheThingsNetwork/api/handler"
 	pb_protocol "github.com/TheThingsNetwork/api/protocol"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	. "github.com/TheThingsNetwork/ttn/utils/testing"
@@ -113,12 +114,31 @@ func TestHandlePrepareActivation(t *testing.T) {
 
 	a.So(joinAccept.DevAddr[0]&254, ShouldEqual, 19<<1)
 	a.So(*joinAccept.CFList, ShouldEqual, lorawan.CFList{867100000, 867300000, 867500000, 867700000, 867900000})
+
+	// Device with reserved DevAddr
+	ns.reservations = devaddr.NewRedisReservationStore(GetRedisClient(), "test-handle-prepare-activation")
+	reserved := types.DevAddr{0x26, 0x01, 0x02, 0x03}
+	a.So(ns.reservations.Set(devaddr.Reservation{AppEUI: appEUI, DevEUI: devEUI, DevAddr: reserved}), ShouldBeNil)
+	defer ns.reservations.Delete(appEUI, devEUI)
+	resp, err = ns.HandlePrepareActivation(&pb_broker.DeduplicatedDeviceActivationRequest{
+		DevEUI: devEUI,
+		AppEUI: appEUI,
+		ActivationMetadata: &pb_protocol.ActivationMetadata{Protocol: &pb_protocol.ActivationMetadata_LoRaWAN{
+			LoRaWAN: &pb_lorawan.ActivationMetadata{},
+		}},
+		ResponseTemplate: &pb_broker.DeviceActivationResponse{},
+	})
+	a.So(err, ShouldBeNil)
+	a.So(*resp.ActivationMetadata.GetLoRaWAN().DevAddr, ShouldEqual, reserved)
 }
 
 func TestHandleActivate(t *testing.T) {
 	a := New(t)
 	ns := &networkServer{
 		devices: device.NewRedisDeviceStore(GetRedisClient(), "test-handle-activate"),
+		prefixes: map[types.DevAddrPrefix][]string{
+			types.DevAddrPrefix{DevAddr: [4]byte{0x00, 0x00, 0x00, 0x00}, Length: 16}: []string{"otaa"},
+		},
 	}
 	ns.InitStatus()
 
@@ -155,4 +175,41 @@ func TestHandleActivate(t *testing.T) {
 		}},
 	})
 	a.So(err, ShouldBeNil)
+
+	activate := func(devAddr types.DevAddr) error {
+		_, err := ns.HandleActivate(&pb_handler.DeviceActivationResponse{
+			ActivationMetadata: pb_protocol.ActivationMetadata{Protocol: &pb_protocol.ActivationMetadata_LoRaWAN{
+				LoRaWAN: &pb_lorawan.ActivationMetadata{
+					AppEUI:  appEUI,
+					DevEUI:  devEUI,
+					DevAddr: &devAddr,
+					NwkSKey: &nwkSKey,
+				},
+			}},
+		})
+		return err
+	}
+
+	// The DevAddr of the device itself
+	a.So(activate(devAddr), ShouldBeNil)
+
+	// DevAddrs outside the prefixes are rejected
+	a.So(activate(getDevAddr(0x26, 0, 3, 2)), ShouldNotBeNil)
+	stored, _ := ns.devices.Get(appEUI, devEUI)
+	a.So(stored.DevAddr, ShouldEqual, devAddr)
+
+	// DevAddrs of other devices are rejected
+	other := &device.Device{
+		AppEUI:  types.AppEUI(getEUI(0, 0, 0, 0, 0, 0, 3, 2)),
+		DevEUI:  types.DevEUI(getEUI(0, 0, 0, 0, 0, 0, 3, 2)),
+		DevAddr: getDevAddr(0, 0, 3, 2),
+	}
+	a.So(ns.devices.Set(other), ShouldBeNil)
+	defer ns.devices.Delete(other.AppEUI, other.DevEUI)
+	a.So(activate(other.DevAddr), ShouldNotBeNil)
+
+	// Free DevAddrs in the prefixes are reserved for the device
+	a.So(activate(getDevAddr(0, 0, 3, 3)), ShouldBeNil)
+	stored, _ = ns.devices.Get(appEUI, devEUI)
+	a.So(stored.DevAddr, ShouldEqual, getDevAddr(0, 0, 3, 3))
 }
@@ -89,6 +89,12 @@ func (n *networkServer) handleUplinkADR(message *pb_broker.DeduplicatedUplinkMes
 		}
 	}
 
+	if n.steerChannelMask(message, dev) {
+		scheduleADR = true
+		forceADR = true
+		message.Trace = message.Trace.WithEvent(ScheduleMACEvent, macCMD, "link-adr", "reason", "channel mask")
+	}
+
 	dataRate := md.GetLoRaWAN().GetDataRate()
 	if dev.ADR.DataRate != dataRate {
 		dev.ADR.DataRate = dataRate
@@ -219,7 +225,7 @@ func (n *networkServer) setADR(mac *pb_lorawan.MACPayload, dev *device.Device) e
 		}
 	}
 
-	if dev.ADR.SentInitial && dev.ADR.DataRate == dataRate && dev.ADR.TxPower == txPower && dev.ADR.NbTrans == nbTrans {
+	if dev.ADR.SentInitial && dev.ADR.DataRate == dataRate && dev.ADR.TxPower == txPower && dev.ADR.NbTrans == nbTrans && len(dev.ADR.PendingChannelMask) == 0 {
 		return nil // Nothing to do
 	}
 	dev.ADR.DataRate, dev.ADR.TxPower, dev.ADR.NbTrans = dataRate, txPower, nbTrans
@@ -301,6 +307,9 @@ func getAdrReqPayloads(dev *device.Device, frequencyPlan *band.FrequencyPlan, dr
 					}
 				}
 			}
+			for _, ch := range dev.Channels.Provisioned {
+				payloads[0].ChMask[ch.Index] = ch.Frequency != 0 && int(ch.MinDataRate) <= drIdx && drIdx <= int(ch.MaxDataRate)
+			}
 		}
 	case pb_lorawan.FrequencyPlan_US_902_928.String(), pb_lorawan.FrequencyPlan_AU_915_928.String():
 		// Adapted from https://github.com/brocaar/lorawan/blob/master/band/band_us902_928.go
@@ -314,8 +323,7 @@ func getAdrReqPayloads(dev *device.Device, frequencyPlan *band.FrequencyPlan, dr
 				},
 			}, // All 125 kHz OFF ChMask applies to channels 64 to 71
 		}
-		channels := frequencyPlan.GetEnabledUplinkChannels()
-		sort.Ints(channels)
+		channels := getChannelMask(dev, frequencyPlan)
 
 		chMaskCntl := -1
 		for _, c := range channels {
@@ -350,3 +358,69 @@ func getAdrReqPayloads(dev *device.Device, frequencyPlan *band.FrequencyPlan, dr
 	}
 	return payloads
 }
+
+// getChannelMask returns the channels that should be enabled on a device in a frequency plan with fixed channels
+func getChannelMask(dev *device.Device, frequencyPlan *band.FrequencyPlan) []int {
+	var channels []int
+	switch {
+	case len(dev.ADR.PendingChannelMask) > 0:
+		channels = append(channels, dev.ADR.PendingChannelMask...)
+	case len(dev.ADR.ChannelMask) > 0:
+		channels = append(channels, dev.ADR.ChannelMask...)
+	default:
+		channels = frequencyPlan.GetEnabledUplinkChannels()
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// steerChannelMask sets a pending channel mask if the device should be steered to the sub-band of the gateways
+// that received the uplink. It returns true if a LinkADRReq should be sent.
+func (n *networkServer) steerChannelMask(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device) bool {
+	switch dev.ADR.Band {
+	case pb_lorawan.FrequencyPlan_US_902_928.String(), pb_lorawan.FrequencyPlan_AU_915_928.String():
+	default:
+		return false
+	}
+	if len(dev.ADR.PendingChannelMask) > 0 || len(message.GetGatewayMetadata()) == 0 {
+		return false
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil {
+		return false
+	}
+	desired, err := fp.GetSubBandChannels(int(message.GetGatewayMetadata()[0].Frequency))
+	if err != nil {
+		return false
+	}
+	if sameChannels(desired, getChannelMask(dev, &fp)) || sameChannels(desired, dev.ADR.RejectedChannelMask) {
+		return false
+	}
+	dev.ADR.PendingChannelMask = desired
+	return true
+}
+
+// handleChannelMaskAns confirms or rolls back the pending channel mask
+func handleChannelMaskAns(dev *device.Device, ack bool) {
+	if len(dev.ADR.PendingChannelMask) == 0 {
+		return
+	}
+	if ack {
+		dev.ADR.ChannelMask, dev.ADR.RejectedChannelMask = dev.ADR.PendingChannelMask, nil
+	} else {
+		dev.ADR.RejectedChannelMask = dev.ADR.PendingChannelMask
+	}
+	dev.ADR.PendingChannelMask = nil
+}
+
+func sameChannels(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
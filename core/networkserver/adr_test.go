@@ -13,6 +13,7 @@ import (
 	pb_gateway "github.com/TheThingsNetwork/api/gateway"
 	pb_protocol "github.com/TheThingsNetwork/api/protocol"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
 	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 	"github.com/TheThingsNetwork/ttn/core/types"
@@ -341,3 +342,48 @@ func TestHandleDownlinkADR(t *testing.T) {
 	dev.ADR.DataRate = "INVALID"
 	shouldReturnError()
 }
+
+func TestSteerChannelMask(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestSteerChannelMask"),
+		},
+	}
+	fp, _ := band.Get("US_902_928")
+
+	dev := &device.Device{}
+	dev.ADR.Band = "US_902_928"
+	a.So(getChannelMask(dev, &fp), ShouldResemble, []int{8, 9, 10, 11, 12, 13, 14, 15, 65})
+
+	// Uplink in the default sub-band
+	message := adrInitUplinkMessage()
+	message.GatewayMetadata[0].Frequency = 903900000
+	a.So(ns.steerChannelMask(message, dev), ShouldBeFalse)
+
+	// Uplink in the first sub-band
+	message.GatewayMetadata[0].Frequency = 902300000
+	a.So(ns.steerChannelMask(message, dev), ShouldBeTrue)
+	a.So(dev.ADR.PendingChannelMask, ShouldResemble, []int{0, 1, 2, 3, 4, 5, 6, 7, 64})
+	a.So(getChannelMask(dev, &fp), ShouldResemble, []int{0, 1, 2, 3, 4, 5, 6, 7, 64})
+	a.So(ns.steerChannelMask(message, dev), ShouldBeFalse) // already pending
+
+	// Rollback on NACK
+	handleChannelMaskAns(dev, false)
+	a.So(dev.ADR.PendingChannelMask, ShouldBeEmpty)
+	a.So(getChannelMask(dev, &fp), ShouldResemble, []int{8, 9, 10, 11, 12, 13, 14, 15, 65})
+	a.So(ns.steerChannelMask(message, dev), ShouldBeFalse) // rejected before
+
+	// Confirm on ACK
+	message.GatewayMetadata[0].Frequency = 905500000
+	a.So(ns.steerChannelMask(message, dev), ShouldBeTrue)
+	handleChannelMaskAns(dev, true)
+	a.So(dev.ADR.ChannelMask, ShouldResemble, []int{16, 17, 18, 19, 20, 21, 22, 23, 66})
+	a.So(dev.ADR.RejectedChannelMask, ShouldBeEmpty)
+
+	// Not for frequency plans without sub-bands
+	dev = &device.Device{}
+	dev.ADR.Band = "EU_863_870"
+	message.GatewayMetadata[0].Frequency = 868100000
+	a.So(ns.steerChannelMask(message, dev), ShouldBeFalse)
+}
@@ -0,0 +1,135 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package channelplan contains the extra channels that the Network Server provisions on the devices of an application
+package channelplan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// MinIndex is the lowest index of a channel that can be provisioned; the
+// lower channels are the default channels of the frequency plan
+const MinIndex = 3
+
+// MaxIndex is the highest index of a channel that can be provisioned
+const MaxIndex = 15
+
+// Channel is an uplink channel of a device
+type Channel struct {
+	Index       uint8  `json:"index"`
+	Frequency   uint32 `json:"frequency"` // a frequency of 0 disables the channel
+	MinDataRate uint8  `json:"min_data_rate"`
+	MaxDataRate uint8  `json:"max_data_rate"`
+
+	// DownlinkFrequency is the RX1 frequency of the channel, if it differs from the uplink frequency
+	DownlinkFrequency uint32 `json:"downlink_frequency,omitempty"`
+}
+
+// String implements the fmt.Stringer interface
+func (c Channel) String() string {
+	str := fmt.Sprintf("%d:%d:%d-%d", c.Index, c.Frequency, c.MinDataRate, c.MaxDataRate)
+	if c.DownlinkFrequency != 0 {
+		str += fmt.Sprintf(":%d", c.DownlinkFrequency)
+	}
+	return str
+}
+
+// ParseChannel parses a channel formatted as index:frequency:minDR-maxDR[:downlinkFrequency]
+func ParseChannel(str string) (channel Channel, err error) {
+	parts := strings.Split(str, ":")
+	if len(parts) != 3 && len(parts) != 4 {
+		return channel, errors.NewErrInvalidArgument("Channel", "must be formatted as index:frequency:minDR-maxDR[:downlinkFrequency]")
+	}
+	dataRates := strings.SplitN(parts[2], "-", 2)
+	if len(dataRates) != 2 {
+		return channel, errors.NewErrInvalidArgument("Channel", "data rates must be formatted as minDR-maxDR")
+	}
+	values := append([]string{parts[0], parts[1]}, dataRates...)
+	if len(parts) == 4 {
+		values = append(values, parts[3])
+	}
+	numbers := make([]uint64, len(values))
+	for i, value := range values {
+		if numbers[i], err = strconv.ParseUint(value, 10, 32); err != nil {
+			return channel, errors.NewErrInvalidArgument("Channel", fmt.Sprintf("%s is not a number", value))
+		}
+	}
+	channel.Index, channel.Frequency = uint8(numbers[0]), uint32(numbers[1])
+	channel.MinDataRate, channel.MaxDataRate = uint8(numbers[2]), uint8(numbers[3])
+	if len(numbers) == 5 {
+		channel.DownlinkFrequency = uint32(numbers[4])
+	}
+	return channel, nil
+}
+
+// Validate the channel in the frequency plan
+func (c Channel) Validate(fp *band.FrequencyPlan) error {
+	if len(fp.UplinkChannels) == 72 {
+		return errors.NewErrInvalidArgument("Frequency Plan", "has fixed channels")
+	}
+	if c.Index < MinIndex || c.Index > MaxIndex {
+		return errors.NewErrInvalidArgument("Channel Index", fmt.Sprintf("must be between %d and %d", MinIndex, MaxIndex))
+	}
+	if c.Frequency == 0 {
+		return errors.NewErrInvalidArgument("Channel Frequency", "can not be empty")
+	}
+	if int(c.MaxDataRate) >= len(fp.DataRates) {
+		return errors.NewErrInvalidArgument("Channel Data Rate", fmt.Sprintf("%d is not valid in this frequency plan", c.MaxDataRate))
+	}
+	if c.MinDataRate > c.MaxDataRate {
+		return errors.NewErrInvalidArgument("Channel Data Rate", "minimum can not be higher than maximum")
+	}
+	return nil
+}
+
+// Plan contains the extra channels of the devices of an application
+type Plan []Channel
+
+// Validate the channel plan in the frequency plan
+func (p Plan) Validate(fp *band.FrequencyPlan) error {
+	indices := make(map[uint8]bool, len(p))
+	for _, channel := range p {
+		if err := channel.Validate(fp); err != nil {
+			return err
+		}
+		if indices[channel.Index] {
+			return errors.NewErrInvalidArgument("Channel Index", fmt.Sprintf("%d is not unique", channel.Index))
+		}
+		indices[channel.Index] = true
+	}
+	return nil
+}
+
+// Diff returns the channels that should be provisioned on a device that has the given channels. Channels
+// that the device has but are not in the plan are returned with a zero frequency, so that they are disabled.
+func (p Plan) Diff(provisioned []Channel) (diff []Channel) {
+	inPlan := make(map[uint8]bool, len(p))
+	for _, channel := range p {
+		inPlan[channel.Index] = true
+		if !Contains(provisioned, channel) {
+			diff = append(diff, channel)
+		}
+	}
+	for _, channel := range provisioned {
+		if !inPlan[channel.Index] && channel.Frequency != 0 {
+			diff = append(diff, Channel{Index: channel.Index})
+		}
+	}
+	return diff
+}
+
+// Contains returns true if the channels contain the channel
+func Contains(channels []Channel, channel Channel) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
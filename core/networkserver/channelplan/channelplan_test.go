@@ -0,0 +1,84 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package channelplan
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/band"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestParseChannel(t *testing.T) {
+	a := New(t)
+
+	channel, err := ParseChannel("3:867100000:0-5")
+	a.So(err, ShouldBeNil)
+	a.So(channel, ShouldResemble, Channel{Index: 3, Frequency: 867100000, MinDataRate: 0, MaxDataRate: 5})
+	a.So(channel.String(), ShouldEqual, "3:867100000:0-5")
+
+	channel, err = ParseChannel("4:867300000:0-5:869525000")
+	a.So(err, ShouldBeNil)
+	a.So(channel.DownlinkFrequency, ShouldEqual, 869525000)
+	a.So(channel.String(), ShouldEqual, "4:867300000:0-5:869525000")
+
+	for _, invalid := range []string{"", "3:867100000", "3:867100000:5", "x:867100000:0-5", "3:867100000:0-5:1:2"} {
+		_, err = ParseChannel(invalid)
+		a.So(err, ShouldNotBeNil)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	a := New(t)
+
+	eu, _ := band.Get("EU_863_870")
+	a.So(Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5}.Validate(&eu), ShouldBeNil)
+	a.So(Channel{Index: 2, Frequency: 867100000, MaxDataRate: 5}.Validate(&eu), ShouldNotBeNil)
+	a.So(Channel{Index: 16, Frequency: 867100000, MaxDataRate: 5}.Validate(&eu), ShouldNotBeNil)
+	a.So(Channel{Index: 3, MaxDataRate: 5}.Validate(&eu), ShouldNotBeNil)
+	a.So(Channel{Index: 3, Frequency: 867100000, MinDataRate: 5, MaxDataRate: 3}.Validate(&eu), ShouldNotBeNil)
+	a.So(Channel{Index: 3, Frequency: 867100000, MaxDataRate: 16}.Validate(&eu), ShouldNotBeNil)
+
+	us, _ := band.Get("US_902_928")
+	a.So(Channel{Index: 3, Frequency: 903900000, MaxDataRate: 3}.Validate(&us), ShouldNotBeNil)
+
+	channel := Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5}
+	a.So(Plan{channel}.Validate(&eu), ShouldBeNil)
+	a.So(Plan{channel, channel}.Validate(&eu), ShouldNotBeNil)
+}
+
+func TestDiff(t *testing.T) {
+	a := New(t)
+
+	ch3 := Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5}
+	ch4 := Channel{Index: 4, Frequency: 867300000, MaxDataRate: 5}
+	ch5 := Channel{Index: 5, Frequency: 867500000, MaxDataRate: 5}
+
+	a.So(Plan{}.Diff(nil), ShouldBeEmpty)
+	a.So(Plan{ch3, ch4}.Diff(nil), ShouldResemble, []Channel{ch3, ch4})
+	a.So(Plan{ch3, ch4}.Diff([]Channel{ch3}), ShouldResemble, []Channel{ch4})
+	a.So(Plan{ch3}.Diff([]Channel{ch3, ch5}), ShouldResemble, []Channel{{Index: 5}})
+	a.So(Plan{ch3}.Diff([]Channel{ch3, {Index: 5}}), ShouldBeEmpty)
+}
+
+func TestRedisStore(t *testing.T) {
+	a := New(t)
+
+	store := NewRedisStore(GetRedisClient(), "channel-plan-test")
+	defer store.Delete("app")
+
+	_, err := store.Get("app")
+	a.So(err, ShouldNotBeNil)
+
+	plan := Plan{{Index: 3, Frequency: 867100000, MaxDataRate: 5}}
+	a.So(store.Set("app", plan), ShouldBeNil)
+	res, err := store.Get("app")
+	a.So(err, ShouldBeNil)
+	a.So(res, ShouldResemble, plan)
+
+	a.So(store.Delete("app"), ShouldBeNil)
+	_, err = store.Get("app")
+	a.So(err, ShouldNotBeNil)
+}
@@ -0,0 +1,61 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package channelplan
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"gopkg.in/redis.v5"
+)
+
+// Store stores the channel plans of applications
+type Store interface {
+	Get(appID string) (Plan, error)
+	Set(appID string, plan Plan) error
+	Delete(appID string) error
+}
+
+// NewRedisStore creates a new Redis-based Store
+func NewRedisStore(client *redis.Client, prefix string) Store {
+	if !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return &RedisStore{
+		store: storage.NewRedisKVStore(client, prefix+"channel-plan"),
+	}
+}
+
+// RedisStore stores the channel plans in Redis
+type RedisStore struct {
+	store *storage.RedisKVStore
+}
+
+// Get the channel plan of an application
+func (s *RedisStore) Get(appID string) (Plan, error) {
+	value, err := s.store.Get(appID)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal([]byte(value), &plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Set the channel plan of an application
+func (s *RedisStore) Set(appID string, plan Plan) error {
+	value, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+	return s.store.Set(appID, string(value))
+}
+
+// Delete the channel plan of an application
+func (s *RedisStore) Delete(appID string) error {
+	return s.store.Delete(appID)
+}
@@ -0,0 +1,134 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"encoding/binary"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+)
+
+// maxFOptsLen is the maximum length of the MAC commands in the FOpts of a frame
+const maxFOptsLen = 15
+
+// SetChannelPlan sets the extra channels that are provisioned on the devices of the application
+func (n *networkServer) SetChannelPlan(appID string, plan channelplan.Plan) error {
+	if len(plan) == 0 {
+		return n.channelPlans.Delete(appID)
+	}
+	return n.channelPlans.Set(appID, plan)
+}
+
+// GetChannelPlan returns the extra channels that are provisioned on the devices of the application
+func (n *networkServer) GetChannelPlan(appID string) (channelplan.Plan, error) {
+	plan, err := n.channelPlans.Get(appID)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return plan, err
+}
+
+func fOptsLen(fOpts []pb_lorawan.MACCommand) (length int) {
+	for _, cmd := range fOpts {
+		length += 1 + len(cmd.Payload)
+	}
+	return
+}
+
+// channelCommands returns the NewChannelReq and DlChannelReq that provision the channel
+func channelCommands(channel channelplan.Channel) []pb_lorawan.MACCommand {
+	newChannel := lorawan.NewChannelReqPayload{
+		ChIndex: channel.Index,
+		Freq:    channel.Frequency,
+		MinDR:   channel.MinDataRate,
+		MaxDR:   channel.MaxDataRate,
+	}
+	payload, _ := newChannel.MarshalBinary()
+	cmds := []pb_lorawan.MACCommand{{CID: uint32(lorawan.NewChannelReq), Payload: payload}}
+	if channel.Frequency != 0 && channel.DownlinkFrequency != 0 {
+		payload := make([]byte, 5)
+		payload[0] = channel.Index
+		binary.LittleEndian.PutUint32(payload[1:], channel.DownlinkFrequency/100)
+		cmds = append(cmds, pb_lorawan.MACCommand{CID: types.DlChannelReq, Payload: payload[:4]})
+	}
+	return cmds
+}
+
+// handleChannelAns processes the answers to the NewChannelReq and DlChannelReq of the pending channels
+func handleChannelAns(dev *device.Device, newChannelAns, dlChannelAns []bool) {
+	var dl int
+	for i, channel := range dev.Channels.Pending {
+		if i >= len(newChannelAns) {
+			break // not answered; the channel will be sent again
+		}
+		ok := newChannelAns[i]
+		if channel.Frequency != 0 && channel.DownlinkFrequency != 0 {
+			ok = ok && dl < len(dlChannelAns) && dlChannelAns[dl]
+			dl++
+		}
+		if !ok {
+			dev.Channels.Rejected = append(dev.Channels.Rejected, channel)
+			continue
+		}
+		provisioned := make([]channelplan.Channel, 0, len(dev.Channels.Provisioned)+1)
+		for _, existing := range dev.Channels.Provisioned {
+			if existing.Index != channel.Index {
+				provisioned = append(provisioned, existing)
+			}
+		}
+		if channel.Frequency != 0 {
+			provisioned = append(provisioned, channel)
+		}
+		dev.Channels.Provisioned = provisioned
+	}
+	dev.Channels.Pending = nil
+}
+
+// setChannels adds the NewChannelReq and DlChannelReq for the channels that are not yet provisioned on the device
+func (n *networkServer) setChannels(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device) error {
+	if n.channelPlans == nil {
+		return nil
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil || len(fp.UplinkChannels) == 72 {
+		return nil // Only for frequency plans with dynamic channels
+	}
+
+	channels := dev.Channels.Pending
+	if len(channels) == 0 {
+		plan, err := n.GetChannelPlan(dev.AppID)
+		if err != nil {
+			return err
+		}
+		for _, channel := range plan.Diff(dev.Channels.Provisioned) {
+			if !channelplan.Contains(dev.Channels.Rejected, channel) {
+				channels = append(channels, channel)
+			}
+		}
+	}
+
+	mac := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	length := fOptsLen(mac.FOpts)
+	var pending []channelplan.Channel
+	for _, channel := range channels {
+		cmds := channelCommands(channel)
+		if length+fOptsLen(cmds) > maxFOptsLen {
+			break // The remaining channels are sent in a next downlink
+		}
+		mac.FOpts = append(mac.FOpts, cmds...)
+		length += fOptsLen(cmds)
+		pending = append(pending, channel)
+		message.Trace = message.Trace.WithEvent(ScheduleMACEvent, macCMD, "new-channel", "channel", channel.String())
+	}
+	dev.Channels.Pending = pending
+
+	return nil
+}
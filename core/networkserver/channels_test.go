@@ -0,0 +1,109 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestChannelCommands(t *testing.T) {
+	a := New(t)
+
+	cmds := channelCommands(channelplan.Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5})
+	a.So(cmds, ShouldHaveLength, 1)
+	a.So(cmds[0].CID, ShouldEqual, lorawan.NewChannelReq)
+	a.So(fOptsLen(cmds), ShouldEqual, 6)
+
+	cmds = channelCommands(channelplan.Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5, DownlinkFrequency: 869525000})
+	a.So(cmds, ShouldHaveLength, 2)
+	a.So(cmds[1].CID, ShouldEqual, types.DlChannelReq)
+	a.So(cmds[1].Payload, ShouldResemble, []byte{3, 0xd2, 0xad, 0x84})
+	a.So(fOptsLen(cmds), ShouldEqual, 11)
+}
+
+func TestSetChannels(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestSetChannels"),
+		},
+		channelPlans: channelplan.NewRedisStore(GetRedisClient(), "ns-test-set-channels"),
+	}
+
+	ch3 := channelplan.Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5}
+	ch4 := channelplan.Channel{Index: 4, Frequency: 867300000, MaxDataRate: 5}
+	ch5 := channelplan.Channel{Index: 5, Frequency: 867500000, MaxDataRate: 5}
+	a.So(ns.SetChannelPlan("app", channelplan.Plan{ch3, ch4, ch5}), ShouldBeNil)
+	defer ns.SetChannelPlan("app", nil)
+
+	dev := &device.Device{AppID: "app"}
+	dev.ADR.Band = "EU_863_870"
+
+	// Only two NewChannelReq fit in the FOpts
+	message := adrInitUplinkMessage()
+	err := ns.setChannels(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldHaveLength, 2)
+	a.So(dev.Channels.Pending, ShouldResemble, []channelplan.Channel{ch3, ch4})
+
+	// The device accepts channel 3 and rejects channel 4
+	handleChannelAns(dev, []bool{true, false}, nil)
+	a.So(dev.Channels.Pending, ShouldBeEmpty)
+	a.So(dev.Channels.Provisioned, ShouldResemble, []channelplan.Channel{ch3})
+	a.So(dev.Channels.Rejected, ShouldResemble, []channelplan.Channel{ch4})
+
+	message = adrInitUplinkMessage()
+	err = ns.setChannels(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.Channels.Pending, ShouldResemble, []channelplan.Channel{ch5})
+
+	// Pending channels are sent again if they were not answered
+	message = adrInitUplinkMessage()
+	err = ns.setChannels(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.Channels.Pending, ShouldResemble, []channelplan.Channel{ch5})
+	handleChannelAns(dev, []bool{true}, nil)
+	a.So(dev.Channels.Provisioned, ShouldResemble, []channelplan.Channel{ch3, ch5})
+
+	// Channels that are removed from the plan are disabled
+	a.So(ns.SetChannelPlan("app", channelplan.Plan{ch3}), ShouldBeNil)
+	message = adrInitUplinkMessage()
+	err = ns.setChannels(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.Channels.Pending, ShouldResemble, []channelplan.Channel{{Index: 5}})
+	handleChannelAns(dev, []bool{true}, nil)
+	a.So(dev.Channels.Provisioned, ShouldResemble, []channelplan.Channel{ch3})
+
+	// Not for frequency plans with fixed channels
+	dev = &device.Device{AppID: "app"}
+	dev.ADR.Band = "US_902_928"
+	message = adrInitUplinkMessage()
+	err = ns.setChannels(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+}
+
+func TestHandleChannelAnsDownlinkFrequency(t *testing.T) {
+	a := New(t)
+
+	ch3 := channelplan.Channel{Index: 3, Frequency: 867100000, MaxDataRate: 5, DownlinkFrequency: 869525000}
+	dev := &device.Device{}
+	dev.Channels.Pending = []channelplan.Channel{ch3}
+	handleChannelAns(dev, []bool{true}, []bool{false})
+	a.So(dev.Channels.Provisioned, ShouldBeEmpty)
+	a.So(dev.Channels.Rejected, ShouldResemble, []channelplan.Channel{ch3})
+
+	dev = &device.Device{}
+	dev.Channels.Pending = []channelplan.Channel{ch3}
+	handleChannelAns(dev, []bool{true}, []bool{true})
+	a.So(dev.Channels.Provisioned, ShouldResemble, []channelplan.Channel{ch3})
+}
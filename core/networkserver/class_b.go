@@ -0,0 +1,82 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"encoding/binary"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// maxPingSlotChannelAttempts is the number of PingSlotChannelReq that are sent to a device that does not answer
+const maxPingSlotChannelAttempts = 3
+
+// getPingSlotChannel returns the ping slot channel for Class B devices in the frequency plan. TTN uses the RX2
+// channel, except in frequency plans with fixed channels, where the ping slots hop over the downlink channels.
+func getPingSlotChannel(fp *band.FrequencyPlan) (frequency uint32, dataRate uint8, ok bool) {
+	if len(fp.UplinkChannels) == 72 {
+		return 0, 0, false
+	}
+	return uint32(fp.RX2Frequency), uint8(fp.RX2DataRate), true
+}
+
+func pingSlotChannelPayload(frequency uint32, dataRate uint8) []byte {
+	payload := make([]byte, 5)
+	binary.LittleEndian.PutUint32(payload, frequency/100)
+	payload[3] = dataRate & 0x0F
+	return payload[:4]
+}
+
+// handlePingSlotInfoReq stores the ping slot periodicity of the device and answers with a PingSlotInfoAns
+func handlePingSlotInfoReq(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device, payload []byte) {
+	if len(payload) != 1 {
+		return
+	}
+	dev.ClassB.PingSlotInfo = true
+	dev.ClassB.Periodicity = payload[0] & 0x07
+	mac := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	mac.FOpts = append(mac.FOpts, pb_lorawan.MACCommand{CID: types.PingSlotInfoAns})
+}
+
+// handlePingSlotChannelAns confirms the ping slot channel of the frequency plan
+func handlePingSlotChannelAns(dev *device.Device, payload []byte) bool {
+	if len(payload) != 1 || payload[0]&0x03 != 0x03 {
+		return false
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil {
+		return false
+	}
+	if frequency, dataRate, ok := getPingSlotChannel(&fp); ok {
+		dev.ClassB.PingSlotFrequency, dev.ClassB.PingSlotDataRate = frequency, dataRate
+	}
+	return true
+}
+
+// setPingSlotChannel adds a PingSlotChannelReq for Class B devices that do not use the ping slot channel of the frequency plan
+func (n *networkServer) setPingSlotChannel(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device) {
+	if !dev.ClassB.PingSlotInfo || dev.ClassB.PingSlotFrequency != 0 || dev.ClassB.PingSlotChannelAttempts >= maxPingSlotChannelAttempts {
+		return
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil {
+		return
+	}
+	frequency, dataRate, ok := getPingSlotChannel(&fp)
+	if !ok {
+		return
+	}
+	mac := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	cmd := pb_lorawan.MACCommand{CID: types.PingSlotChannelReq, Payload: pingSlotChannelPayload(frequency, dataRate)}
+	if fOptsLen(mac.FOpts)+fOptsLen([]pb_lorawan.MACCommand{cmd}) > maxFOptsLen {
+		return
+	}
+	mac.FOpts = append(mac.FOpts, cmd)
+	dev.ClassB.PingSlotChannelAttempts++
+	message.Trace = message.Trace.WithEvent(ScheduleMACEvent, macCMD, "ping-slot-channel")
+}
@@ -0,0 +1,65 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestPingSlotChannelPayload(t *testing.T) {
+	a := New(t)
+	a.So(pingSlotChannelPayload(869525000, 3), ShouldResemble, []byte{0xd2, 0xad, 0x84, 0x03})
+}
+
+func TestHandleClassB(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestHandleClassB"),
+		},
+		devices: device.NewRedisDeviceStore(GetRedisClient(), "ns-test-handle-class-b"),
+	}
+
+	dev := &device.Device{}
+	dev.ADR.Band = "EU_863_870"
+	dev.TxParams.Acked = true
+
+	// PingSlotInfoReq is answered and a PingSlotChannelReq is sent
+	message := adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{{CID: types.PingSlotInfoReq, Payload: []byte{0x05}}}
+	err := ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.ClassB.PingSlotInfo, ShouldBeTrue)
+	a.So(dev.ClassB.PingSlotPeriod(), ShouldEqual, 32*time.Second)
+	fOpts := message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts
+	a.So(fOpts, ShouldHaveLength, 2)
+	a.So(fOpts[0].CID, ShouldEqual, types.PingSlotInfoAns)
+	a.So(fOpts[1].CID, ShouldEqual, types.PingSlotChannelReq)
+	a.So(fOpts[1].Payload, ShouldResemble, []byte{0xd2, 0xad, 0x84, 0x03})
+
+	// PingSlotChannelAns confirms the channel
+	message = adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{{CID: types.PingSlotChannelAns, Payload: []byte{0x03}}}
+	err = ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.ClassB.PingSlotFrequency, ShouldEqual, 869525000)
+	a.So(dev.ClassB.PingSlotDataRate, ShouldEqual, 3)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+
+	// No PingSlotChannelReq in frequency plans with fixed channels
+	dev = &device.Device{}
+	dev.ADR.Band = "US_902_928"
+	dev.ClassB.PingSlotInfo = true
+	message = adrInitUplinkMessage()
+	ns.setPingSlotChannel(message, dev)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+}
@@ -0,0 +1,168 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package devaddr contains the strategies that the Network Server uses to allocate device addresses
+package devaddr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/TheThingsNetwork/go-utils/pseudorandom"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Strategies
+const (
+	// StrategyRandom allocates random addresses in the prefixes
+	StrategyRandom = "random"
+	// StrategySequential allocates the addresses in the prefixes one after another
+	StrategySequential = "sequential"
+)
+
+// MaxAttempts is the number of addresses that an Allocator tries before it gives up
+var MaxAttempts = 16
+
+// UsageFunc returns the number of devices that use the DevAddr. Allocators use it to prevent collisions.
+type UsageFunc func(devAddr types.DevAddr) (int, error)
+
+// Allocator allocates a DevAddr in one of the prefixes
+type Allocator interface {
+	Allocate(prefixes []types.DevAddrPrefix) (types.DevAddr, error)
+
+	// Reserve checks a DevAddr that was not allocated by the Allocator, such as a DevAddr that was chosen by a join
+	// webhook. It must be in one of the prefixes and it must not be used.
+	Reserve(prefixes []types.DevAddrPrefix, devAddr types.DevAddr) error
+}
+
+// Size returns the number of addresses in the prefix
+func Size(prefix types.DevAddrPrefix) uint64 {
+	return 1 << uint(32-prefix.Length)
+}
+
+// addressInPrefix returns the n-th address in the prefix
+func addressInPrefix(prefix types.DevAddrPrefix, n uint64) (devAddr types.DevAddr) {
+	binary.BigEndian.PutUint32(devAddr[:], uint32(n%Size(prefix)))
+	return devAddr.WithPrefix(prefix)
+}
+
+func isUsed(used UsageFunc, devAddr types.DevAddr) (bool, error) {
+	if used == nil {
+		return false, nil
+	}
+	count, err := used(devAddr)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// InPrefixes returns true if the DevAddr is in one of the prefixes
+func InPrefixes(prefixes []types.DevAddrPrefix, devAddr types.DevAddr) bool {
+	for _, prefix := range prefixes {
+		if devAddr.HasPrefix(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func reserve(used UsageFunc, prefixes []types.DevAddrPrefix, devAddr types.DevAddr) error {
+	if !InPrefixes(prefixes, devAddr) {
+		return errors.NewErrInvalidArgument("DevAddr", fmt.Sprintf("%s is not in prefixes %v", devAddr, prefixes))
+	}
+	inUse, err := isUsed(used, devAddr)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.NewErrAlreadyExists(fmt.Sprintf("DevAddr %s", devAddr))
+	}
+	return nil
+}
+
+func errNoAddress(prefixes []types.DevAddrPrefix) error {
+	if len(prefixes) == 0 {
+		return errors.NewErrNotFound("DevAddr prefix")
+	}
+	return errors.NewErrNotFound(fmt.Sprintf("free DevAddr in prefixes %v", prefixes))
+}
+
+// NewRandomAllocator returns an Allocator that allocates random addresses in a random prefix, and tries
+// another address if the address is already used
+func NewRandomAllocator(used UsageFunc) Allocator {
+	return &randomAllocator{used: used}
+}
+
+type randomAllocator struct {
+	used UsageFunc
+}
+
+func (a *randomAllocator) Allocate(prefixes []types.DevAddrPrefix) (types.DevAddr, error) {
+	if len(prefixes) == 0 {
+		return types.DevAddr{}, errNoAddress(prefixes)
+	}
+	for i := 0; i < MaxAttempts; i++ {
+		var devAddr types.DevAddr
+		pseudorandom.FillBytes(devAddr[:])
+		devAddr = devAddr.WithPrefix(prefixes[pseudorandom.Intn(len(prefixes))])
+		used, err := isUsed(a.used, devAddr)
+		if err != nil {
+			return types.DevAddr{}, err
+		}
+		if !used {
+			return devAddr, nil
+		}
+	}
+	return types.DevAddr{}, errNoAddress(prefixes)
+}
+
+func (a *randomAllocator) Reserve(prefixes []types.DevAddrPrefix, devAddr types.DevAddr) error {
+	return reserve(a.used, prefixes, devAddr)
+}
+
+// Counter returns the next number for a prefix
+type Counter interface {
+	Next(prefix types.DevAddrPrefix) (uint64, error)
+}
+
+// NewSequentialAllocator returns an Allocator that allocates the addresses in the prefixes one after another.
+// The counter keeps track of the next address in each prefix. Addresses that are still used are skipped.
+func NewSequentialAllocator(counter Counter, used UsageFunc) Allocator {
+	return &sequentialAllocator{counter: counter, used: used}
+}
+
+type sequentialAllocator struct {
+	counter Counter
+	used    UsageFunc
+}
+
+func (a *sequentialAllocator) Allocate(prefixes []types.DevAddrPrefix) (types.DevAddr, error) {
+	sorted := make([]types.DevAddrPrefix, len(prefixes))
+	copy(sorted, prefixes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+
+	for _, prefix := range sorted {
+		for i := 0; i < MaxAttempts; i++ {
+			n, err := a.counter.Next(prefix)
+			if err != nil {
+				return types.DevAddr{}, err
+			}
+			devAddr := addressInPrefix(prefix, n)
+			used, err := isUsed(a.used, devAddr)
+			if err != nil {
+				return types.DevAddr{}, err
+			}
+			if !used {
+				return devAddr, nil
+			}
+		}
+	}
+	return types.DevAddr{}, errNoAddress(prefixes)
+}
+
+func (a *sequentialAllocator) Reserve(prefixes []types.DevAddrPrefix, devAddr types.DevAddr) error {
+	return reserve(a.used, prefixes, devAddr)
+}
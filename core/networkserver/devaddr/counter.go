@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package devaddr
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"gopkg.in/redis.v5"
+)
+
+// NewMemoryCounter returns a Counter that keeps the counters in memory
+func NewMemoryCounter() Counter {
+	return &memoryCounter{counters: make(map[types.DevAddrPrefix]uint64)}
+}
+
+type memoryCounter struct {
+	mu       sync.Mutex
+	counters map[types.DevAddrPrefix]uint64
+}
+
+func (c *memoryCounter) Next(prefix types.DevAddrPrefix) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.counters[prefix]
+	c.counters[prefix]++
+	return n, nil
+}
+
+// NewRedisCounter returns a Counter that keeps the counters in Redis, so that they can be shared by multiple Network Servers
+func NewRedisCounter(client *redis.Client, prefix string) Counter {
+	if !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return &redisCounter{client: client, prefix: prefix}
+}
+
+type redisCounter struct {
+	client *redis.Client
+	prefix string
+}
+
+func (c *redisCounter) Next(prefix types.DevAddrPrefix) (uint64, error) {
+	n, err := c.client.Incr(c.prefix + prefix.String()).Result()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(n - 1), nil
+}
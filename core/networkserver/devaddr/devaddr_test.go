@@ -0,0 +1,148 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package devaddr
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+var testPrefix = types.DevAddrPrefix{DevAddr: types.DevAddr{0x26, 0x01, 0x02, 0x00}, Length: 30}
+
+func TestRandomAllocator(t *testing.T) {
+	a := New(t)
+
+	used := map[types.DevAddr]bool{}
+	allocator := NewRandomAllocator(func(devAddr types.DevAddr) (int, error) {
+		if used[devAddr] {
+			return 1, nil
+		}
+		return 0, nil
+	})
+
+	_, err := allocator.Allocate(nil)
+	a.So(err, ShouldNotBeNil)
+
+	for i := 0; i < 3; i++ {
+		devAddr, err := allocator.Allocate([]types.DevAddrPrefix{testPrefix})
+		a.So(err, ShouldBeNil)
+		a.So(devAddr.HasPrefix(testPrefix), ShouldBeTrue)
+		a.So(used[devAddr], ShouldBeFalse)
+		used[devAddr] = true
+	}
+}
+
+func TestSequentialAllocator(t *testing.T) {
+	a := New(t)
+
+	used := map[types.DevAddr]bool{
+		{0x26, 0x01, 0x02, 0x01}: true,
+	}
+	allocator := NewSequentialAllocator(NewMemoryCounter(), func(devAddr types.DevAddr) (int, error) {
+		if used[devAddr] {
+			return 1, nil
+		}
+		return 0, nil
+	})
+
+	for _, expected := range []types.DevAddr{{0x26, 0x01, 0x02, 0x00}, {0x26, 0x01, 0x02, 0x02}, {0x26, 0x01, 0x02, 0x03}} {
+		devAddr, err := allocator.Allocate([]types.DevAddrPrefix{testPrefix})
+		a.So(err, ShouldBeNil)
+		a.So(devAddr, ShouldEqual, expected)
+		used[devAddr] = true
+	}
+
+	// Prefix is full
+	_, err := allocator.Allocate([]types.DevAddrPrefix{testPrefix})
+	a.So(err, ShouldNotBeNil)
+
+	// Address is freed again
+	delete(used, types.DevAddr{0x26, 0x01, 0x02, 0x02})
+	devAddr, err := allocator.Allocate([]types.DevAddrPrefix{testPrefix})
+	a.So(err, ShouldBeNil)
+	a.So(devAddr, ShouldEqual, types.DevAddr{0x26, 0x01, 0x02, 0x02})
+}
+
+func TestRedisCounter(t *testing.T) {
+	a := New(t)
+
+	client := GetRedisClient()
+	counter := NewRedisCounter(client, "test-devaddr-counter")
+	defer client.Del("test-devaddr-counter:" + testPrefix.String())
+
+	n, err := counter.Next(testPrefix)
+	a.So(err, ShouldBeNil)
+	a.So(n, ShouldEqual, 0)
+	n, err = counter.Next(testPrefix)
+	a.So(err, ShouldBeNil)
+	a.So(n, ShouldEqual, 1)
+}
+
+func TestRedisReservationStore(t *testing.T) {
+	a := New(t)
+
+	s := NewRedisReservationStore(GetRedisClient(), "test-devaddr-reservation")
+	appEUI, devEUI := types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8}, types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}
+
+	_, err := s.Get(appEUI, devEUI)
+	a.So(err, ShouldNotBeNil)
+
+	err = s.Set(Reservation{AppEUI: appEUI, DevEUI: devEUI, DevAddr: types.DevAddr{0x26, 0x01, 0x02, 0x03}})
+	a.So(err, ShouldBeNil)
+	defer s.Delete(appEUI, devEUI)
+
+	devAddr, err := s.Get(appEUI, devEUI)
+	a.So(err, ShouldBeNil)
+	a.So(devAddr, ShouldEqual, types.DevAddr{0x26, 0x01, 0x02, 0x03})
+
+	reservations, err := s.List()
+	a.So(err, ShouldBeNil)
+	a.So(reservations, ShouldHaveLength, 1)
+	a.So(reservations[0].DevEUI, ShouldEqual, devEUI)
+
+	a.So(s.Delete(appEUI, devEUI), ShouldBeNil)
+	_, err = s.Get(appEUI, devEUI)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestGetUtilization(t *testing.T) {
+	a := New(t)
+
+	other := types.DevAddrPrefix{DevAddr: types.DevAddr{0x26, 0x00, 0x00, 0x00}, Length: 20}
+	utilization := GetUtilization([]types.DevAddrPrefix{testPrefix, other}, []types.DevAddr{
+		{0x26, 0x01, 0x02, 0x00},
+		{0x26, 0x01, 0x02, 0x00},
+		{0x26, 0x01, 0x02, 0x03},
+		{0x26, 0x00, 0x01, 0x00},
+		{},
+	})
+	a.So(utilization, ShouldHaveLength, 2)
+	a.So(utilization[0].Prefix, ShouldEqual, other)
+	a.So(utilization[0].Size, ShouldEqual, 4096)
+	a.So(utilization[0].Used, ShouldEqual, 1)
+	a.So(utilization[1].Size, ShouldEqual, 4)
+	a.So(utilization[1].Used, ShouldEqual, 2)
+	a.So(utilization[1].Ratio(), ShouldEqual, 0.5)
+}
+
+func TestReserve(t *testing.T) {
+	a := New(t)
+
+	used := map[types.DevAddr]bool{
+		{0x26, 0x01, 0x02, 0x01}: true,
+	}
+	allocator := NewRandomAllocator(func(devAddr types.DevAddr) (int, error) {
+		if used[devAddr] {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	prefixes := []types.DevAddrPrefix{testPrefix}
+	a.So(allocator.Reserve(prefixes, types.DevAddr{0x26, 0x01, 0x02, 0x02}), ShouldBeNil)
+	a.So(allocator.Reserve(prefixes, types.DevAddr{0x26, 0x01, 0x02, 0x01}), ShouldNotBeNil)
+	a.So(allocator.Reserve(prefixes, types.DevAddr{0x26, 0x01, 0x03, 0x00}), ShouldNotBeNil)
+}
@@ -0,0 +1,95 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package devaddr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"gopkg.in/redis.v5"
+)
+
+// Reservation is a static DevAddr for a device
+type Reservation struct {
+	AppEUI  types.AppEUI  `json:"app_eui"`
+	DevEUI  types.DevEUI  `json:"dev_eui"`
+	DevAddr types.DevAddr `json:"dev_addr"`
+}
+
+// ReservationStore stores the static DevAddrs of devices
+type ReservationStore interface {
+	List() ([]Reservation, error)
+	Get(appEUI types.AppEUI, devEUI types.DevEUI) (types.DevAddr, error)
+	Set(reservation Reservation) error
+	Delete(appEUI types.AppEUI, devEUI types.DevEUI) error
+}
+
+// NewRedisReservationStore creates a new Redis-based ReservationStore
+func NewRedisReservationStore(client *redis.Client, prefix string) ReservationStore {
+	if !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return &RedisReservationStore{
+		store: storage.NewRedisKVStore(client, prefix+"devaddr-reservation"),
+	}
+}
+
+// RedisReservationStore stores the reservations in Redis
+type RedisReservationStore struct {
+	store *storage.RedisKVStore
+}
+
+func (s *RedisReservationStore) key(appEUI types.AppEUI, devEUI types.DevEUI) string {
+	return fmt.Sprintf("%s:%s", appEUI, devEUI)
+}
+
+// List all reservations
+func (s *RedisReservationStore) List() ([]Reservation, error) {
+	data, err := s.store.List("", nil)
+	if err != nil {
+		return nil, err
+	}
+	reservations := make([]Reservation, 0, len(data))
+	for key, value := range data {
+		parts := strings.Split(key, ":")
+		if len(parts) != 2 {
+			continue
+		}
+		var reservation Reservation
+		if reservation.AppEUI, err = types.ParseAppEUI(parts[0]); err != nil {
+			continue
+		}
+		if reservation.DevEUI, err = types.ParseDevEUI(parts[1]); err != nil {
+			continue
+		}
+		if reservation.DevAddr, err = types.ParseDevAddr(value); err != nil {
+			continue
+		}
+		reservations = append(reservations, reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].DevAddr.String() < reservations[j].DevAddr.String() })
+	return reservations, nil
+}
+
+// Get the reserved DevAddr of a device
+func (s *RedisReservationStore) Get(appEUI types.AppEUI, devEUI types.DevEUI) (types.DevAddr, error) {
+	value, err := s.store.Get(s.key(appEUI, devEUI))
+	if err != nil {
+		return types.DevAddr{}, err
+	}
+	return types.ParseDevAddr(value)
+}
+
+// Set the reserved DevAddr of a device
+func (s *RedisReservationStore) Set(reservation Reservation) error {
+	return s.store.Set(s.key(reservation.AppEUI, reservation.DevEUI), reservation.DevAddr.String())
+}
+
+// Delete the reservation of a device
+func (s *RedisReservationStore) Delete(appEUI types.AppEUI, devEUI types.DevEUI) error {
+	return s.store.Delete(s.key(appEUI, devEUI))
+}
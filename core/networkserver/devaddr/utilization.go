@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package devaddr
+
+import (
+	"sort"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Utilization of a prefix
+type Utilization struct {
+	Prefix types.DevAddrPrefix `json:"prefix"`
+	Size   uint64              `json:"size"`
+	Used   uint64              `json:"used"`
+}
+
+// Ratio returns the fraction of the addresses in the prefix that is used
+func (u Utilization) Ratio() float64 {
+	if u.Size == 0 {
+		return 0
+	}
+	return float64(u.Used) / float64(u.Size)
+}
+
+// GetUtilization counts the distinct addresses in each of the prefixes
+func GetUtilization(prefixes []types.DevAddrPrefix, devAddrs []types.DevAddr) []Utilization {
+	used := make(map[types.DevAddr]bool, len(devAddrs))
+	for _, devAddr := range devAddrs {
+		if !devAddr.IsEmpty() {
+			used[devAddr] = true
+		}
+	}
+	utilization := make([]Utilization, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		u := Utilization{Prefix: prefix, Size: Size(prefix)}
+		for devAddr := range used {
+			if devAddr.HasPrefix(prefix) {
+				u.Used++
+			}
+		}
+		utilization = append(utilization, u)
+	}
+	sort.Slice(utilization, func(i, j int) bool { return utilization[i].Prefix.String() < utilization[j].Prefix.String() })
+	return utilization
+}
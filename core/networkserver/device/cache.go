@@ -0,0 +1,199 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/bluele/gcache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var cacheRequestsCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "networkserver",
+		Name:      "device_cache_requests_total",
+		Help:      "Total number of requests to the device cache.",
+	}, []string{"cache"},
+)
+
+var cacheMissesCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "networkserver",
+		Name:      "device_cache_misses_total",
+		Help:      "Total number of device cache misses.",
+	}, []string{"cache"},
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequestsCounter)
+	prometheus.MustRegister(cacheMissesCounter)
+}
+
+// CacheOptions used for the cache
+type CacheOptions struct {
+	DeviceCacheSize        int
+	DeviceCacheExpiration  time.Duration
+	AddressCacheSize       int
+	AddressCacheExpiration time.Duration
+}
+
+// DefaultCacheOptions are the default CacheOptions
+var DefaultCacheOptions = CacheOptions{
+	DeviceCacheSize:        10000,
+	DeviceCacheExpiration:  time.Minute,
+	AddressCacheSize:       10000,
+	AddressCacheExpiration: time.Minute,
+}
+
+type deviceCacheKey struct {
+	AppEUI types.AppEUI
+	DevEUI types.DevEUI
+}
+
+type cachedDeviceStore struct {
+	backingStore Store
+	deviceCache  gcache.Cache
+	addressCache gcache.Cache
+}
+
+// NewCachedDeviceStore returns a read-through cache wrapper around the existing store.
+// Devices are updated in the cache when they are written through the cache, so the cache
+// should only be used if no other process writes to the backing store.
+func NewCachedDeviceStore(store Store, options CacheOptions) Store {
+	deviceCache := gcache.New(options.DeviceCacheSize).Expiration(options.DeviceCacheExpiration).LRU().
+		LoaderFunc(func(k interface{}) (interface{}, error) {
+			cacheMissesCounter.WithLabelValues("device").Inc()
+			key := k.(deviceCacheKey)
+			dev, err := store.Get(key.AppEUI, key.DevEUI)
+			if err != nil {
+				return nil, err
+			}
+			return *dev, nil
+		}).Build()
+
+	addressCache := gcache.New(options.AddressCacheSize).Expiration(options.AddressCacheExpiration).LRU().
+		LoaderFunc(func(k interface{}) (interface{}, error) {
+			cacheMissesCounter.WithLabelValues("address").Inc()
+			devices, err := store.ListForAddress(k.(types.DevAddr))
+			if err != nil {
+				return nil, err
+			}
+			keys := make([]deviceCacheKey, 0, len(devices))
+			for _, dev := range devices {
+				if dev == nil {
+					continue
+				}
+				key := deviceCacheKey{dev.AppEUI, dev.DevEUI}
+				deviceCache.Set(key, *dev)
+				keys = append(keys, key)
+			}
+			return keys, nil
+		}).Build()
+
+	return &cachedDeviceStore{
+		backingStore: store,
+		deviceCache:  deviceCache,
+		addressCache: addressCache,
+	}
+}
+
+// clone returns a copy of the device that does not share the slices of the device
+func (d Device) clone() Device {
+	d.ADR.ChannelMask = append(d.ADR.ChannelMask[:0:0], d.ADR.ChannelMask...)
+	d.ADR.PendingChannelMask = append(d.ADR.PendingChannelMask[:0:0], d.ADR.PendingChannelMask...)
+	d.ADR.RejectedChannelMask = append(d.ADR.RejectedChannelMask[:0:0], d.ADR.RejectedChannelMask...)
+	d.Channels.Provisioned = append(d.Channels.Provisioned[:0:0], d.Channels.Provisioned...)
+	d.Channels.Pending = append(d.Channels.Pending[:0:0], d.Channels.Pending...)
+	d.Channels.Rejected = append(d.Channels.Rejected[:0:0], d.Channels.Rejected...)
+	d.MAC.Pending = append(d.MAC.Pending[:0:0], d.MAC.Pending...)
+	for i, cmd := range d.MAC.Pending {
+		d.MAC.Pending[i].Payload = append(cmd.Payload[:0:0], cmd.Payload...)
+	}
+	d.MAC.Unanswered = append(d.MAC.Unanswered[:0:0], d.MAC.Unanswered...)
+	return d
+}
+
+func (s *cachedDeviceStore) Count() (int, error) {
+	return s.backingStore.Count()
+}
+
+func (s *cachedDeviceStore) List(opts *storage.ListOptions) ([]*Device, error) {
+	return s.backingStore.List(opts)
+}
+
+func (s *cachedDeviceStore) CountForAddress(devAddr types.DevAddr) (int, error) {
+	return s.backingStore.CountForAddress(devAddr)
+}
+
+func (s *cachedDeviceStore) ListForAddress(devAddr types.DevAddr) ([]*Device, error) {
+	cacheRequestsCounter.WithLabelValues("address").Inc()
+	k, err := s.addressCache.Get(devAddr)
+	if err != nil {
+		return nil, err
+	}
+	keys := k.([]deviceCacheKey)
+	devices := make([]*Device, 0, len(keys))
+	for _, key := range keys {
+		dev, err := s.Get(key.AppEUI, key.DevEUI)
+		if err != nil {
+			continue // Deleted since the address was cached
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+func (s *cachedDeviceStore) Get(appEUI types.AppEUI, devEUI types.DevEUI) (*Device, error) {
+	cacheRequestsCounter.WithLabelValues("device").Inc()
+	d, err := s.deviceCache.Get(deviceCacheKey{appEUI, devEUI})
+	if err != nil {
+		return nil, err
+	}
+	dev := d.(Device).clone() // Return a copy, so that the cached device is not changed by the caller
+	return &dev, nil
+}
+
+func (s *cachedDeviceStore) Set(new *Device, properties ...string) error {
+	old := new.old
+	if err := s.backingStore.Set(new, properties...); err != nil {
+		return err
+	}
+	key := deviceCacheKey{new.AppEUI, new.DevEUI}
+	if len(properties) == 0 {
+		cached := new.clone()
+		cached.old = nil
+		s.deviceCache.Set(key, cached)
+	} else {
+		s.deviceCache.Remove(key)
+	}
+	if old == nil || old.DevAddr != new.DevAddr || old.AppEUI != new.AppEUI || old.DevEUI != new.DevEUI {
+		if old != nil {
+			s.deviceCache.Remove(deviceCacheKey{old.AppEUI, old.DevEUI})
+			s.addressCache.Remove(old.DevAddr)
+		}
+		s.addressCache.Remove(new.DevAddr)
+	}
+	return nil
+}
+
+func (s *cachedDeviceStore) Delete(appEUI types.AppEUI, devEUI types.DevEUI) error {
+	dev, _ := s.Get(appEUI, devEUI)
+	if err := s.backingStore.Delete(appEUI, devEUI); err != nil {
+		return err
+	}
+	s.deviceCache.Remove(deviceCacheKey{appEUI, devEUI})
+	if dev != nil {
+		s.addressCache.Remove(dev.DevAddr)
+	}
+	return nil
+}
+
+func (s *cachedDeviceStore) Frames(appEUI types.AppEUI, devEUI types.DevEUI) (FrameHistory, error) {
+	return s.backingStore.Frames(appEUI, devEUI)
+}
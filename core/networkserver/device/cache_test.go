@@ -0,0 +1,99 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestCachedDeviceStore(t *testing.T) {
+	a := New(t)
+
+	backing := NewRedisDeviceStore(GetRedisClient(), "networkserver-test-cached-device-store")
+	s := NewCachedDeviceStore(backing, DefaultCacheOptions)
+
+	appEUI := types.AppEUI{0, 0, 0, 0, 0, 0, 0, 1}
+	devEUI := types.DevEUI{0, 0, 0, 0, 0, 0, 0, 1}
+
+	// Get non-existing
+	dev, err := s.Get(appEUI, devEUI)
+	a.So(err, ShouldNotBeNil)
+	a.So(dev, ShouldBeNil)
+
+	// Create
+	err = s.Set(&Device{
+		DevAddr: types.DevAddr{0, 0, 0, 1},
+		AppEUI:  appEUI,
+		DevEUI:  devEUI,
+	})
+	a.So(err, ShouldBeNil)
+
+	defer func() {
+		s.Delete(appEUI, devEUI)
+	}()
+
+	// Get existing
+	dev, err = s.Get(appEUI, devEUI)
+	a.So(err, ShouldBeNil)
+	a.So(dev.DevAddr, ShouldEqual, types.DevAddr{0, 0, 0, 1})
+
+	// Changes to the returned device do not change the cache
+	dev.FCntUp = 42
+	dev, _ = s.Get(appEUI, devEUI)
+	a.So(dev.FCntUp, ShouldEqual, 0)
+
+	// Changes to the slices of the written device do not change the cache
+	dev.StartUpdate()
+	dev.ADR.ChannelMask = []int{0, 1}
+	dev.MAC.Pending = []PendingMACCommand{{CID: 3, Payload: []byte{1}}}
+	err = s.Set(dev)
+	a.So(err, ShouldBeNil)
+	dev.ADR.ChannelMask[0] = 8
+	dev.MAC.Pending[0].Payload[0] = 2
+	dev, _ = s.Get(appEUI, devEUI)
+	a.So(dev.ADR.ChannelMask, ShouldResemble, []int{0, 1})
+	a.So(dev.MAC.Pending[0].Payload, ShouldResemble, []byte{1})
+
+	// Changes to the slices of the returned device do not change the cache
+	dev.ADR.ChannelMask[1] = 9
+	dev, _ = s.Get(appEUI, devEUI)
+	a.So(dev.ADR.ChannelMask, ShouldResemble, []int{0, 1})
+
+	// List for address
+	devices, err := s.ListForAddress(types.DevAddr{0, 0, 0, 1})
+	a.So(err, ShouldBeNil)
+	a.So(devices, ShouldHaveLength, 1)
+
+	// Update is written through
+	dev.StartUpdate()
+	dev.FCntUp = 42
+	err = s.Set(dev)
+	a.So(err, ShouldBeNil)
+	dev, _ = s.Get(appEUI, devEUI)
+	a.So(dev.FCntUp, ShouldEqual, 42)
+	stored, _ := backing.Get(appEUI, devEUI)
+	a.So(stored.FCntUp, ShouldEqual, 42)
+
+	// Changing the address invalidates the address cache
+	dev.StartUpdate()
+	dev.DevAddr = types.DevAddr{0, 0, 0, 2}
+	err = s.Set(dev)
+	a.So(err, ShouldBeNil)
+	devices, _ = s.ListForAddress(types.DevAddr{0, 0, 0, 1})
+	a.So(devices, ShouldBeEmpty)
+	devices, _ = s.ListForAddress(types.DevAddr{0, 0, 0, 2})
+	a.So(devices, ShouldHaveLength, 1)
+
+	// Delete
+	err = s.Delete(appEUI, devEUI)
+	a.So(err, ShouldBeNil)
+	_, err = s.Get(appEUI, devEUI)
+	a.So(err, ShouldNotBeNil)
+	devices, _ = s.ListForAddress(types.DevAddr{0, 0, 0, 2})
+	a.So(devices, ShouldBeEmpty)
+}
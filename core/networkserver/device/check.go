@@ -0,0 +1,114 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/storage"
+)
+
+// Check the consistency of the devices and the DevAddr index. With repair, the DevAddr index is repaired; devices
+// without a NwkSKey and devices with the same DevAddr and NwkSKey can only be repaired by their owner.
+func (s *RedisDeviceStore) Check(repair bool) (inconsistencies []storage.Inconsistency, err error) {
+	keys, err := s.store.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		exists[strings.TrimPrefix(key, s.prefix+":"+redisDevicePrefix+":")] = true
+	}
+
+	devicesI, err := s.store.List("", nil)
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]*Device, len(devicesI))
+	for _, deviceI := range devicesI {
+		if device, ok := deviceI.(Device); ok {
+			devices[s.key(device.AppEUI, device.DevEUI)] = &device
+		}
+	}
+
+	// Orphaned sessions: DevAddrs that are indexed for devices that do not exist or do not have that DevAddr
+	index, err := s.devAddrIndex.List("", nil)
+	if err != nil {
+		return nil, err
+	}
+	for devAddr, members := range index {
+		for _, key := range members {
+			inconsistency := storage.Inconsistency{Key: key}
+			if device, ok := devices[key]; ok && device.DevAddr.String() != devAddr {
+				inconsistency.Problem = fmt.Sprintf("DevAddr %s is indexed, but the device has DevAddr %s", devAddr, device.DevAddr)
+			} else if !exists[key] {
+				inconsistency.Problem = fmt.Sprintf("DevAddr %s is indexed, but the device does not exist", devAddr)
+			} else {
+				continue
+			}
+			if repair {
+				if err := s.devAddrIndex.Remove(devAddr, key); err != nil {
+					return inconsistencies, err
+				}
+				inconsistency.Repaired = true
+			}
+			inconsistencies = append(inconsistencies, inconsistency)
+		}
+	}
+
+	sessions := make(map[string][]string)
+	for key, device := range devices {
+		if device.DevAddr.IsEmpty() {
+			continue
+		}
+		if device.NwkSKey.IsEmpty() {
+			inconsistencies = append(inconsistencies, storage.Inconsistency{
+				Key:     key,
+				Problem: fmt.Sprintf("Device has DevAddr %s, but no NwkSKey", device.DevAddr),
+			})
+		} else {
+			session := device.DevAddr.String() + ":" + device.NwkSKey.String()
+			sessions[session] = append(sessions[session], key)
+		}
+		if !stringInSlice(key, index[device.DevAddr.String()]) {
+			inconsistency := storage.Inconsistency{
+				Key:     key,
+				Problem: fmt.Sprintf("Device has DevAddr %s, but is not indexed", device.DevAddr),
+			}
+			if repair {
+				if err := s.devAddrIndex.Add(device.DevAddr.String(), key); err != nil {
+					return inconsistencies, err
+				}
+				inconsistency.Repaired = true
+			}
+			inconsistencies = append(inconsistencies, inconsistency)
+		}
+	}
+
+	// Duplicate DevAddr assignments: the Network Server can not tell devices with the same DevAddr and NwkSKey apart
+	for _, keys := range sessions {
+		if len(keys) < 2 {
+			continue
+		}
+		for _, key := range keys {
+			inconsistencies = append(inconsistencies, storage.Inconsistency{
+				Key:     key,
+				Problem: fmt.Sprintf("Device has the same DevAddr and NwkSKey as %d other devices", len(keys)-1),
+			})
+		}
+	}
+
+	storage.SortInconsistencies(inconsistencies)
+	return inconsistencies, nil
+}
+
+func stringInSlice(search string, slice []string) bool {
+	for _, i := range slice {
+		if i == search {
+			return true
+		}
+	}
+	return false
+}
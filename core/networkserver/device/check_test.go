@@ -0,0 +1,54 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeviceStoreCheck(t *testing.T) {
+	a := New(t)
+
+	s := NewRedisDeviceStore(GetRedisClient(), "networkserver-test-device-check").(*RedisDeviceStore)
+
+	appEUI := types.AppEUI{0, 0, 0, 0, 0, 0, 0, 1}
+	nwkSKey := types.NwkSKey{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 1}
+	for _, dev := range []*Device{
+		{AppEUI: appEUI, DevEUI: types.DevEUI{0, 0, 0, 0, 0, 0, 0, 1}, DevAddr: types.DevAddr{0, 0, 0, 1}, NwkSKey: nwkSKey},
+		{AppEUI: appEUI, DevEUI: types.DevEUI{0, 0, 0, 0, 0, 0, 0, 2}, DevAddr: types.DevAddr{0, 0, 0, 1}, NwkSKey: nwkSKey},
+		{AppEUI: appEUI, DevEUI: types.DevEUI{0, 0, 0, 0, 0, 0, 0, 3}, DevAddr: types.DevAddr{0, 0, 0, 3}},
+	} {
+		a.So(s.Set(dev), ShouldBeNil)
+		defer s.Delete(dev.AppEUI, dev.DevEUI)
+	}
+	orphan := s.key(appEUI, types.DevEUI{0, 0, 0, 0, 0, 0, 0, 4})
+	s.devAddrIndex.Add(types.DevAddr{0, 0, 0, 4}.String(), orphan)
+	defer s.devAddrIndex.Delete(types.DevAddr{0, 0, 0, 4}.String())
+
+	inconsistencies, err := s.Check(false)
+	a.So(err, ShouldBeNil)
+	a.So(inconsistencies, ShouldHaveLength, 4)
+	a.So(inconsistencies[0].Key, ShouldEqual, s.key(appEUI, types.DevEUI{0, 0, 0, 0, 0, 0, 0, 1}))
+	a.So(inconsistencies[0].Problem, ShouldContainSubstring, "same DevAddr and NwkSKey")
+	a.So(inconsistencies[1].Key, ShouldEqual, s.key(appEUI, types.DevEUI{0, 0, 0, 0, 0, 0, 0, 2}))
+	a.So(inconsistencies[2].Key, ShouldEqual, s.key(appEUI, types.DevEUI{0, 0, 0, 0, 0, 0, 0, 3}))
+	a.So(inconsistencies[2].Problem, ShouldContainSubstring, "no NwkSKey")
+	a.So(inconsistencies[3].Key, ShouldEqual, orphan)
+	a.So(inconsistencies[3].Problem, ShouldContainSubstring, "does not exist")
+	a.So(inconsistencies[3].Repaired, ShouldBeFalse)
+
+	inconsistencies, err = s.Check(true)
+	a.So(err, ShouldBeNil)
+	a.So(inconsistencies, ShouldHaveLength, 4)
+	a.So(inconsistencies[2].Repaired, ShouldBeFalse)
+	a.So(inconsistencies[3].Repaired, ShouldBeTrue)
+
+	inconsistencies, err = s.Check(false)
+	a.So(err, ShouldBeNil)
+	a.So(inconsistencies, ShouldHaveLength, 3)
+}
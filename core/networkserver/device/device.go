@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/fatih/structs"
 )
@@ -18,6 +19,7 @@ type Options struct {
 	ActivationConstraints string `json:"activation_constraints,omitempty"` // Activation Constraints (public/local/private)
 	DisableFCntCheck      bool   `json:"disable_fcnt_check,omitemtpy"`     // Disable Frame counter check (insecure)
 	Uses32BitFCnt         bool   `json:"uses_32_bit_fcnt,omitemtpy"`       // Use 32-bit Frame counters
+	ABP                   bool   `json:"abp,omitempty"`                    // The device has no AppKey
 }
 
 // Device contains the state of a device
@@ -35,6 +37,14 @@ type Device struct {
 	LastSeen time.Time     `redis:"last_seen"`
 	Options  Options       `redis:"options"`
 	ADR      ADRSettings   `redis:"adr,include"`
+	Channels Channels      `redis:"channels,include"`
+	TxParams TxParams      `redis:"tx_params,include"`
+	ClassB   ClassB        `redis:"class_b,include"`
+	MAC      MACCommands   `redis:"mac,include"`
+
+	// ResetMICs are the MICs of the last uplinks with a ResetInd that reset the session. They are
+	// not accepted again, so that a replayed ResetInd can not reset the session.
+	ResetMICs []uint32 `redis:"reset_mics"`
 
 	CreatedAt time.Time `redis:"created_at"`
 	UpdatedAt time.Time `redis:"updated_at"`
@@ -55,6 +65,87 @@ type ADRSettings struct {
 	DataRate string `redis:"data_rate"`
 	TxPower  int    `redis:"tx_power"`
 	NbTrans  int    `redis:"nb_trans"`
+
+	// Channel masks of frequency plans with fixed channels. An empty ChannelMask
+	// means that the device uses the enabled channels of the frequency plan.
+	ChannelMask         []int `redis:"channel_mask"`          // confirmed by the device
+	PendingChannelMask  []int `redis:"pending_channel_mask"`  // sent, but not yet confirmed
+	RejectedChannelMask []int `redis:"rejected_channel_mask"` // rejected by the device, not sent again
+}
+
+// Channels contains the state of the extra channels of the device that are provisioned by the NetworkServer
+type Channels struct {
+	Provisioned []channelplan.Channel `redis:"provisioned"` // confirmed by the device
+	Pending     []channelplan.Channel `redis:"pending"`     // sent, but not yet confirmed
+	Rejected    []channelplan.Channel `redis:"rejected"`    // rejected by the device, not sent again
+}
+
+// TxParams contains the state of the TxParamSetupReq of the device
+type TxParams struct {
+	Attempts int  `redis:"attempts"` // number of TxParamSetupReq that were sent
+	Acked    bool `redis:"acked"`
+}
+
+// ClassB contains the Class B settings that were negotiated with the device
+type ClassB struct {
+	PingSlotInfo bool  `redis:"ping_slot_info"` // the device sent a PingSlotInfoReq
+	Periodicity  uint8 `redis:"periodicity"`    // the ping slot period is 2^Periodicity seconds
+
+	// Ping slot channel that was confirmed with a PingSlotChannelAns. A zero frequency
+	// means that the device uses the default ping slot channel of the frequency plan.
+	PingSlotFrequency       uint32 `redis:"ping_slot_frequency"`
+	PingSlotDataRate        uint8  `redis:"ping_slot_data_rate"`
+	PingSlotChannelAttempts int    `redis:"ping_slot_channel_attempts"`
+}
+
+// PingSlotPeriod returns the interval between the ping slots of the device
+func (c ClassB) PingSlotPeriod() time.Duration {
+	return time.Duration(1<<c.Periodicity) * time.Second
+}
+
+// MACCommands contains the state of the MAC commands that the NetworkServer sent to the device
+type MACCommands struct {
+	Pending    []PendingMACCommand `redis:"pending"`    // sent, but not yet answered
+	Unanswered []uint32            `redis:"unanswered"` // CIDs of commands that expired without an answer
+}
+
+// PendingMACCommand is a MAC command that was sent to the device, but not yet answered
+type PendingMACCommand struct {
+	CID       uint32    `json:"cid"`
+	Payload   []byte    `json:"payload,omitempty"`
+	Attempts  int       `json:"attempts"` // number of downlinks that contained the command
+	FirstSent time.Time `json:"first_sent"`
+	LastSent  time.Time `json:"last_sent"`
+}
+
+// ResetMACState resets the state of the device that was negotiated with MAC commands
+func (d *Device) ResetMACState() {
+	d.ADR = ADRSettings{Band: d.ADR.Band, Margin: d.ADR.Margin}
+	d.Channels = Channels{}
+	d.TxParams = TxParams{}
+	d.ClassB = ClassB{}
+	d.MAC = MACCommands{}
+}
+
+// maxResetMICs is the number of ResetMICs that are remembered
+const maxResetMICs = 16
+
+// ResetAccepted returns true if the uplink with a ResetInd and the MIC already reset the session
+func (d *Device) ResetAccepted(mic uint32) bool {
+	for _, accepted := range d.ResetMICs {
+		if accepted == mic {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptReset remembers the MIC of an uplink with a ResetInd that reset the session
+func (d *Device) AcceptReset(mic uint32) {
+	d.ResetMICs = append(d.ResetMICs, mic)
+	if len(d.ResetMICs) > maxResetMICs {
+		d.ResetMICs = d.ResetMICs[len(d.ResetMICs)-maxResetMICs:]
+	}
 }
 
 // StartUpdate stores the state of the device
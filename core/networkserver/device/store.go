@@ -70,6 +70,12 @@ func (s *RedisDeviceStore) key(appEUI types.AppEUI, devEUI types.DevEUI) string
 	return fmt.Sprintf("%s:%s", appEUI, devEUI)
 }
 
+// SetSalvage makes the store quarantine corrupted devices and frames instead of failing on them
+func (s *RedisDeviceStore) SetSalvage(salvage bool) {
+	s.store.SetSalvage(salvage)
+	s.frameStore.SetSalvage(salvage)
+}
+
 // Count all Devices
 func (s *RedisDeviceStore) Count() (int, error) {
 	return s.store.Count("")
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"encoding/binary"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+)
+
+// gpsEpoch is the start of the GPS time
+var gpsEpoch = time.Date(1980, time.January, 6, 0, 0, 0, 0, time.UTC)
+
+// LeapSeconds is the number of leap seconds that were added to UTC since the GPS epoch
+var LeapSeconds = 18 * time.Second
+
+const (
+	timeSourceGateway = "gateway"
+	timeSourceServer  = "server"
+)
+
+// getUplinkTime returns the time at which the uplink was received. The time of the gateways
+// is preferred, because it is only set by gateways that are synchronized with GPS. Otherwise
+// the NTP-synchronized time of the server is used, which includes the backhaul latency.
+func getUplinkTime(message *pb_broker.DeduplicatedUplinkMessage) (t time.Time, source string) {
+	for _, md := range message.GetGatewayMetadata() {
+		if md.Time != 0 {
+			return time.Unix(0, md.Time), timeSourceGateway
+		}
+	}
+	if message.ServerTime != 0 {
+		return time.Unix(0, message.ServerTime), timeSourceServer
+	}
+	return time.Now(), timeSourceServer
+}
+
+// gpsTime returns the time since the GPS epoch
+func gpsTime(t time.Time) time.Duration {
+	return t.Sub(gpsEpoch) + LeapSeconds
+}
+
+// deviceTimeAnsPayload returns the payload of a DeviceTimeAns: the seconds since the GPS epoch and the fractional second in 1/256 s
+func deviceTimeAnsPayload(t time.Time) []byte {
+	gps := gpsTime(t)
+	payload := make([]byte, 5)
+	binary.LittleEndian.PutUint32(payload, uint32(gps/time.Second))
+	payload[4] = uint8((gps % time.Second) * 256 / time.Second)
+	return payload
+}
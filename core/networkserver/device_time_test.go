@@ -0,0 +1,41 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeviceTimeAnsPayload(t *testing.T) {
+	a := New(t)
+
+	// 2017-01-01 00:00:00 UTC is GPS time 1167264018
+	a.So(gpsTime(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)), ShouldEqual, 1167264018*time.Second)
+	a.So(deviceTimeAnsPayload(time.Date(2017, 1, 1, 0, 0, 0, 500000000, time.UTC)), ShouldResemble, []byte{0x12, 0x09, 0x93, 0x45, 0x80})
+}
+
+func TestGetUplinkTime(t *testing.T) {
+	a := New(t)
+
+	serverTime := time.Date(2017, 1, 1, 0, 0, 1, 0, time.UTC)
+	gatewayTime := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	message := &pb_broker.DeduplicatedUplinkMessage{
+		ServerTime:      serverTime.UnixNano(),
+		GatewayMetadata: []*pb_gateway.RxMetadata{{}},
+	}
+	tm, source := getUplinkTime(message)
+	a.So(tm.Equal(serverTime), ShouldBeTrue)
+	a.So(source, ShouldEqual, timeSourceServer)
+
+	message.GatewayMetadata = append(message.GatewayMetadata, &pb_gateway.RxMetadata{Time: gatewayTime.UnixNano()})
+	tm, source = getUplinkTime(message)
+	a.So(tm.Equal(gatewayTime), ShouldBeTrue)
+	a.So(source, ShouldEqual, timeSourceGateway)
+}
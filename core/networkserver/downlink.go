@@ -6,6 +6,7 @@ package networkserver
 import (
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	"github.com/TheThingsNetwork/api/trace"
+	"github.com/TheThingsNetwork/ttn/core/secureelement"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/brocaar/lorawan"
 )
@@ -54,11 +55,23 @@ func (n *networkServer) HandleDownlink(message *pb_broker.DownlinkMessage) (*pb_
 		return nil, err
 	}
 
+	err = validateDownlinkSize(message, dev)
+	if err != nil {
+		return nil, err
+	}
+
 	lorawanDownlinkMAC.FCnt = dev.FCntDown // Use full 32-bit FCnt for setting MIC
 	dev.FCntDown++                         // TODO: For confirmed downlink, FCntDown should be incremented AFTER ACK
 
 	phyPayload := message.Message.GetLoRaWAN().PHYPayload()
-	phyPayload.SetMIC(lorawan.AES128Key(dev.NwkSKey))
+	if dev.NwkSKey.IsEmpty() && n.secureElement != nil {
+		// The NwkSKey of the device is kept in a secure element
+		if err = secureelement.SetMIC(n.secureElement, dev.AppEUI, dev.DevEUI, &phyPayload); err != nil {
+			return nil, err
+		}
+	} else {
+		phyPayload.SetMIC(lorawan.AES128Key(dev.NwkSKey))
+	}
 	bytes, err := phyPayload.MarshalBinary()
 	if err != nil {
 		return nil, err
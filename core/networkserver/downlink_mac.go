@@ -4,6 +4,8 @@
 package networkserver
 
 import (
+	"time"
+
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 )
@@ -12,5 +14,8 @@ func (n *networkServer) handleDownlinkMAC(message *pb_broker.DownlinkMessage, de
 	if err := n.handleDownlinkADR(message, dev); err != nil {
 		return err
 	}
+	if mac := message.GetMessage().GetLoRaWAN().GetMACPayload(); mac != nil {
+		trackMACCommands(dev, mac.FOpts, time.Now())
+	}
 	return nil
 }
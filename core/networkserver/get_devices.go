@@ -4,12 +4,20 @@
 package networkserver
 
 import (
+	"encoding/binary"
+
 	pb "github.com/TheThingsNetwork/api/networkserver"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/fcnt"
 )
 
-func (n *networkServer) HandleGetDevices(req *pb.DevicesRequest) (*pb.DevicesResponse, error) {
+// HandleGetDevices returns the devices with the DevAddr of which the frame counter is not higher than the FCnt of the
+// uplink. If the uplink contains a ResetInd, ABP devices start counting at zero again, so ABP devices with a higher
+// frame counter are also returned if the FCnt of the uplink is low, and the uplink did not reset the session before.
+// The Broker checks the MIC of the uplink with their session keys.
+func (n *networkServer) HandleGetDevices(req *pb.DevicesRequest, resetIndMIC []byte) (*pb.DevicesResponse, error) {
 	devices, err := n.devices.ListForAddress(req.DevAddr)
 	if err != nil {
 		return nil, err
@@ -36,7 +44,7 @@ func (n *networkServer) HandleGetDevices(req *pb.DevicesRequest) (*pb.DevicesRes
 			Uses32BitFCnt:    device.Options.Uses32BitFCnt,
 			DisableFCntCheck: device.Options.DisableFCntCheck,
 		}
-		if device.Options.DisableFCntCheck {
+		if device.Options.DisableFCntCheck || canReset(device, req.FCnt, resetIndMIC) {
 			res.Results = append(res.Results, dev)
 			continue
 		}
@@ -51,3 +59,11 @@ func (n *networkServer) HandleGetDevices(req *pb.DevicesRequest) (*pb.DevicesRes
 
 	return res, nil
 }
+
+// canReset returns true if the uplink with a ResetInd and the FCnt may reset the session of the device
+func canReset(dev *device.Device, fCnt uint32, resetIndMIC []byte) bool {
+	if len(resetIndMIC) != 4 || !dev.Options.ABP || fCnt > types.MaxResetIndFCnt {
+		return false
+	}
+	return !dev.ResetAccepted(binary.BigEndian.Uint32(resetIndMIC))
+}
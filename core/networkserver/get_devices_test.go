@@ -27,7 +27,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err := ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr1,
 		FCnt:    5,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldBeEmpty)
 
@@ -46,7 +46,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr1,
 		FCnt:    5,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 1)
 
@@ -55,7 +55,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr2,
 		FCnt:    5,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 0)
 
@@ -63,10 +63,56 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr1,
 		FCnt:    4,
-	})
+	}, nil)
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldHaveLength, 0)
+
+	// Non-Matching FCnt, and the uplink contains a ResetInd, but the device is not an ABP device
+	mic := []byte{1, 2, 3, 4}
+	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr1,
+		FCnt:    0,
+	}, mic)
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldHaveLength, 0)
+
+	// Non-Matching FCnt, but the uplink of the ABP device contains a ResetInd
+	abp, _ := ns.devices.Get(types.AppEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8)), types.DevEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8)))
+	abp.StartUpdate()
+	abp.FCntUp = 100
+	abp.Options.ABP = true
+	ns.devices.Set(abp)
+	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr1,
+		FCnt:    0,
+	}, mic)
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldHaveLength, 1)
+
+	// The FCnt of an uplink with a ResetInd must be low
+	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr1,
+		FCnt:    types.MaxResetIndFCnt + 1,
+	}, mic)
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldHaveLength, 0)
+
+	// An uplink with a ResetInd that already reset the session is replayed
+	abp.StartUpdate()
+	abp.AcceptReset(0x01020304)
+	ns.devices.Set(abp)
+	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr1,
+		FCnt:    0,
+	}, mic)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 0)
 
+	abp.StartUpdate()
+	abp.FCntUp = 5
+	abp.Options.ABP = false
+	ns.devices.Set(abp)
+
 	// Non-Matching FCnt, but FCnt Check Disabled
 	ns.devices.Set(&device.Device{
 		DevAddr: getDevAddr(5, 6, 7, 8),
@@ -84,7 +130,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr2,
 		FCnt:    4,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 1)
 
@@ -106,7 +152,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr3,
 		FCnt:    5,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 1)
 
@@ -128,7 +174,7 @@ func TestHandleGetDevices(t *testing.T) {
 	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
 		DevAddr: devAddr4,
 		FCnt:    5,
-	})
+	}, nil)
 	a.So(err, ShouldBeNil)
 	a.So(res.Results, ShouldHaveLength, 1)
 
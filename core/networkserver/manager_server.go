@@ -5,13 +5,16 @@ package networkserver
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	pb "github.com/TheThingsNetwork/api/networkserver"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/go-account-lib/claims"
 	"github.com/TheThingsNetwork/go-account-lib/rights"
+	"github.com/TheThingsNetwork/ttn/api"
 	"github.com/TheThingsNetwork/ttn/api/ratelimit"
+	"github.com/TheThingsNetwork/ttn/core/band"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
@@ -95,6 +98,16 @@ func (n *networkServerManager) SetDevice(ctx context.Context, in *pb_lorawan.Dev
 		return nil, err
 	}
 
+	settings := api.DeviceSettingsFromIncomingContext(ctx)
+	if settings.FrequencyPlan != "" {
+		if _, err := band.Get(settings.FrequencyPlan); err != nil {
+			return nil, errors.NewErrInvalidArgument("Frequency Plan", err.Error())
+		}
+	}
+	if settings.ADRMargin < 0 {
+		return nil, errors.NewErrInvalidArgument("ADR Margin", "can not be negative")
+	}
+
 	dev, err := n.getDevice(ctx, &pb_lorawan.DeviceIdentifier{AppEUI: in.AppEUI, DevEUI: in.DevEUI})
 	if err != nil && errors.GetErrType(err) != errors.NotFound {
 		return nil, err
@@ -112,12 +125,21 @@ func (n *networkServerManager) SetDevice(ctx context.Context, in *pb_lorawan.Dev
 	dev.DevEUI = in.DevEUI
 	dev.FCntUp = in.FCntUp
 	dev.FCntDown = in.FCntDown
-	dev.ADR = device.ADRSettings{Band: dev.ADR.Band, Margin: dev.ADR.Margin}
+	dev.ResetMACState()
 
 	dev.Options = device.Options{
 		DisableFCntCheck:      in.DisableFCntCheck,
 		Uses32BitFCnt:         in.Uses32BitFCnt,
 		ActivationConstraints: in.ActivationConstraints,
+		ABP:                   settings.ABP || strings.Contains(in.ActivationConstraints, "abp"),
+	}
+
+	// The frequency plan and ADR margin are kept when they are not configured, so that a learned frequency plan is not lost
+	if settings.FrequencyPlan != "" {
+		dev.ADR.Band = settings.FrequencyPlan
+	}
+	if settings.ADRMargin != 0 {
+		dev.ADR.Margin = settings.ADRMargin
 	}
 
 	if in.NwkSKey != nil && in.DevAddr != nil {
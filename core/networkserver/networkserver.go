@@ -12,7 +12,10 @@ import (
 	pb "github.com/TheThingsNetwork/api/networkserver"
 	"github.com/TheThingsNetwork/go-utils/grpc/auth"
 	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/secureelement"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"google.golang.org/grpc"
@@ -24,10 +27,18 @@ type NetworkServer interface {
 	component.Interface
 	component.ManagementInterface
 
+	WithCache(options device.CacheOptions)
+	WithSalvageStorage()
+	WithSecureElement(service secureelement.Service)
 	UsePrefix(prefix types.DevAddrPrefix, usage []string) error
 	GetPrefixesFor(requiredUsages ...string) []types.DevAddrPrefix
+	UseDevAddrStrategy(strategy string) error
+	GetDevAddrUtilization() ([]devaddr.Utilization, error)
+	SetChannelPlan(appID string, plan channelplan.Plan) error
+	GetChannelPlan(appID string) (channelplan.Plan, error)
+	GetSession(appEUI types.AppEUI, devEUI types.DevEUI) (*Session, error)
 
-	HandleGetDevices(*pb.DevicesRequest) (*pb.DevicesResponse, error)
+	HandleGetDevices(req *pb.DevicesRequest, resetIndMIC []byte) (*pb.DevicesResponse, error)
 	HandlePrepareActivation(*pb_broker.DeduplicatedDeviceActivationRequest) (*pb_broker.DeduplicatedDeviceActivationRequest, error)
 	HandleActivate(*pb_handler.DeviceActivationResponse) (*pb_handler.DeviceActivationResponse, error)
 	HandleUplink(*pb_broker.DeduplicatedUplinkMessage) (*pb_broker.DeduplicatedUplinkMessage, error)
@@ -37,8 +48,11 @@ type NetworkServer interface {
 // NewRedisNetworkServer creates a new Redis-backed NetworkServer
 func NewRedisNetworkServer(client *redis.Client, netID int) NetworkServer {
 	ns := &networkServer{
-		devices:  device.NewRedisDeviceStore(client, "ns"),
-		prefixes: map[types.DevAddrPrefix][]string{},
+		devices:        device.NewRedisDeviceStore(client, "ns"),
+		prefixes:       map[types.DevAddrPrefix][]string{},
+		devAddrCounter: devaddr.NewRedisCounter(client, "ns:devaddr-counter"),
+		reservations:   devaddr.NewRedisReservationStore(client, "ns"),
+		channelPlans:   channelplan.NewRedisStore(client, "ns"),
 	}
 	ns.netID = [3]byte{byte(netID >> 16), byte(netID >> 8), byte(netID)}
 	return ns
@@ -46,11 +60,32 @@ func NewRedisNetworkServer(client *redis.Client, netID int) NetworkServer {
 
 type networkServer struct {
 	*component.Component
-	devices       device.Store
-	netID         [3]byte
-	prefixes      map[types.DevAddrPrefix][]string
-	status        *status
-	monitorStream monitorclient.Stream
+	devices        device.Store
+	netID          [3]byte
+	prefixes       map[types.DevAddrPrefix][]string
+	allocator      devaddr.Allocator
+	devAddrCounter devaddr.Counter
+	reservations   devaddr.ReservationStore
+	channelPlans   channelplan.Store
+	status         *status
+	monitorStream  monitorclient.Stream
+	secureElement  secureelement.Service
+}
+
+func (n *networkServer) WithCache(options device.CacheOptions) {
+	n.devices = device.NewCachedDeviceStore(n.devices, options)
+}
+
+// WithSalvageStorage quarantines corrupted devices instead of failing on them. It must be called before WithCache.
+func (n *networkServer) WithSalvageStorage() {
+	if devices, ok := n.devices.(*device.RedisDeviceStore); ok {
+		devices.SetSalvage(true)
+	}
+}
+
+// WithSecureElement sets the MIC of downlinks to devices without NwkSKey with the secure element service
+func (n *networkServer) WithSecureElement(service secureelement.Service) {
+	n.secureElement = service
 }
 
 func (n *networkServer) UsePrefix(prefix types.DevAddrPrefix, usage []string) error {
@@ -82,6 +117,43 @@ func (n *networkServer) GetPrefixesFor(requiredUsages ...string) []types.DevAddr
 	return suitablePrefixes
 }
 
+// UseDevAddrStrategy sets the strategy that is used to allocate DevAddrs in the prefixes
+func (n *networkServer) UseDevAddrStrategy(strategy string) error {
+	switch strategy {
+	case devaddr.StrategyRandom:
+		n.allocator = devaddr.NewRandomAllocator(n.countForAddress)
+	case devaddr.StrategySequential:
+		if n.devAddrCounter == nil {
+			n.devAddrCounter = devaddr.NewMemoryCounter()
+		}
+		n.allocator = devaddr.NewSequentialAllocator(n.devAddrCounter, n.countForAddress)
+	default:
+		return errors.NewErrInvalidArgument("DevAddr strategy", "unknown")
+	}
+	return nil
+}
+
+// GetDevAddrUtilization returns the utilization of the prefixes
+func (n *networkServer) GetDevAddrUtilization() ([]devaddr.Utilization, error) {
+	devices, err := n.devices.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	devAddrs := make([]types.DevAddr, 0, len(devices))
+	for _, dev := range devices {
+		devAddrs = append(devAddrs, dev.DevAddr)
+	}
+	prefixes := make([]types.DevAddrPrefix, 0, len(n.prefixes))
+	for prefix := range n.prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	return devaddr.GetUtilization(prefixes, devAddrs), nil
+}
+
+func (n *networkServer) countForAddress(devAddr types.DevAddr) (int, error) {
+	return n.devices.CountForAddress(devAddr)
+}
+
 func (n *networkServer) Init(c *component.Component) error {
 	n.Component = c
 	n.InitStatus()
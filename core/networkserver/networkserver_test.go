@@ -6,6 +6,7 @@ package networkserver
 import (
 	"testing"
 
+	"github.com/TheThingsNetwork/ttn/core/networkserver/devaddr"
 	"github.com/TheThingsNetwork/ttn/core/types"
 	. "github.com/smartystreets/assertions"
 	"gopkg.in/redis.v5"
@@ -46,3 +47,14 @@ func TestUsePrefix(t *testing.T) {
 	a.So(ns.UsePrefix(types.DevAddrPrefix{DevAddr: types.DevAddr([4]byte{0x26, 0, 0, 0}), Length: 7}, []string{"otaa"}), ShouldBeNil)
 	a.So(ns.(*networkServer).prefixes, ShouldHaveLength, 1)
 }
+
+func TestUseDevAddrStrategy(t *testing.T) {
+	a := New(t)
+	var client redis.Client
+	ns := NewRedisNetworkServer(&client, 19)
+
+	a.So(ns.UseDevAddrStrategy("unknown"), ShouldNotBeNil)
+	a.So(ns.UseDevAddrStrategy(devaddr.StrategyRandom), ShouldBeNil)
+	a.So(ns.UseDevAddrStrategy(devaddr.StrategySequential), ShouldBeNil)
+	a.So(ns.(*networkServer).allocator, ShouldNotBeNil)
+}
@@ -0,0 +1,164 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"bytes"
+	"time"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/brocaar/lorawan"
+)
+
+// maxMACCommandAttempts is the number of downlinks that contain a MAC command before it expires without an answer
+const maxMACCommandAttempts = 3
+
+// macCommandExpiry is the time after which a MAC command that was first sent expires without an answer
+const macCommandExpiry = 24 * time.Hour
+
+// macRequests are the MAC commands that the NetworkServer sends to devices. Devices answer them with a MAC command
+// with the same CID.
+var macRequests = map[uint32]string{
+	uint32(lorawan.LinkADRReq):       "LinkADRReq",
+	uint32(lorawan.DutyCycleReq):     "DutyCycleReq",
+	uint32(lorawan.RXParamSetupReq):  "RXParamSetupReq",
+	uint32(lorawan.DevStatusReq):     "DevStatusReq",
+	uint32(lorawan.NewChannelReq):    "NewChannelReq",
+	uint32(lorawan.RXTimingSetupReq): "RXTimingSetupReq",
+	types.TxParamSetupReq:            "TxParamSetupReq",
+	types.DlChannelReq:               "DlChannelReq",
+	types.PingSlotChannelReq:         "PingSlotChannelReq",
+}
+
+// trackMACCommands marks the MAC requests in the FOpts of a downlink as pending until the device answers them. A
+// request replaces the pending requests with the same CID that are not in the downlink.
+func trackMACCommands(dev *device.Device, fOpts []pb_lorawan.MACCommand, now time.Time) {
+	sent := make(map[uint32]bool)
+	for _, cmd := range fOpts {
+		if _, ok := macRequests[cmd.CID]; ok {
+			sent[cmd.CID] = true
+		}
+	}
+	if len(sent) == 0 {
+		return
+	}
+
+	pending := make([]device.PendingMACCommand, 0, len(dev.MAC.Pending)+len(fOpts))
+	for _, cmd := range fOpts {
+		if !sent[cmd.CID] {
+			continue
+		}
+		tracked := device.PendingMACCommand{CID: cmd.CID, Payload: cmd.Payload, FirstSent: now}
+		for _, existing := range dev.MAC.Pending {
+			if existing.CID == cmd.CID && bytes.Equal(existing.Payload, cmd.Payload) {
+				tracked = existing
+				break
+			}
+		}
+		tracked.Attempts++
+		tracked.LastSent = now
+		pending = append(pending, tracked)
+	}
+	for _, existing := range dev.MAC.Pending {
+		if !sent[existing.CID] {
+			pending = append(pending, existing)
+		}
+	}
+	dev.MAC.Pending = pending
+}
+
+// handleMACAnswers removes the MAC requests that the device answered in the FOpts of an uplink from the pending and
+// unanswered commands
+func handleMACAnswers(dev *device.Device, fOpts []pb_lorawan.MACCommand) {
+	answered := make(map[uint32]bool)
+	for _, cmd := range fOpts {
+		if _, ok := macRequests[cmd.CID]; ok {
+			answered[cmd.CID] = true
+		}
+	}
+	if len(answered) == 0 {
+		return
+	}
+
+	var pending []device.PendingMACCommand
+	for _, cmd := range dev.MAC.Pending {
+		if !answered[cmd.CID] {
+			pending = append(pending, cmd)
+		}
+	}
+	dev.MAC.Pending = pending
+
+	var unanswered []uint32
+	for _, cid := range dev.MAC.Unanswered {
+		if !answered[cid] {
+			unanswered = append(unanswered, cid)
+		}
+	}
+	dev.MAC.Unanswered = unanswered
+}
+
+// retryMACCommands expires the pending MAC commands that were sent too often or too long ago and adds the other
+// pending commands to the downlink again. Commands with a CID that is already in the downlink are not added, as the
+// downlink already contains a newer request. The commands with the same CID are added together or not at all, as the
+// LinkADRReq commands of a block must be sent in the same downlink.
+func retryMACCommands(ctx log.Interface, message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device, now time.Time) {
+	if len(dev.MAC.Pending) == 0 {
+		return
+	}
+
+	mac := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	scheduled := make(map[uint32]bool)
+	for _, cmd := range mac.FOpts {
+		scheduled[cmd.CID] = true
+	}
+	length := fOptsLen(mac.FOpts)
+
+	pending := make([]device.PendingMACCommand, 0, len(dev.MAC.Pending))
+	var order []uint32
+	retries := make(map[uint32][]device.PendingMACCommand)
+	for _, cmd := range dev.MAC.Pending {
+		if cmd.Attempts >= maxMACCommandAttempts || now.Sub(cmd.FirstSent) > macCommandExpiry {
+			ctx.WithField("MACCommand", macRequests[cmd.CID]).WithField("Attempts", cmd.Attempts).Warn("MAC command expired without answer")
+			if !containsCID(dev.MAC.Unanswered, cmd.CID) {
+				dev.MAC.Unanswered = append(dev.MAC.Unanswered, cmd.CID)
+			}
+			continue
+		}
+		pending = append(pending, cmd)
+		if scheduled[cmd.CID] {
+			continue
+		}
+		if _, ok := retries[cmd.CID]; !ok {
+			order = append(order, cmd.CID)
+		}
+		retries[cmd.CID] = append(retries[cmd.CID], cmd)
+	}
+	dev.MAC.Pending = pending
+
+	for _, cid := range order {
+		block := make([]pb_lorawan.MACCommand, 0, len(retries[cid]))
+		for _, cmd := range retries[cid] {
+			block = append(block, pb_lorawan.MACCommand{CID: cmd.CID, Payload: cmd.Payload})
+		}
+		if length+fOptsLen(block) > maxFOptsLen {
+			continue // The commands are sent again in a next downlink
+		}
+		mac.FOpts = append(mac.FOpts, block...)
+		length += fOptsLen(block)
+		message.Trace = message.Trace.WithEvent(ScheduleMACEvent, macCMD, macRequests[cid], "reason", "retry", "attempts", retries[cid][0].Attempts)
+	}
+}
+
+func containsCID(cids []uint32, cid uint32) bool {
+	for _, c := range cids {
+		if c == cid {
+			return true
+		}
+	}
+	return false
+}
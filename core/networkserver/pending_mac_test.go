@@ -0,0 +1,103 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestPendingMACCommands(t *testing.T) {
+	a := New(t)
+	ctx := GetLogger(t, "TestPendingMACCommands")
+	dev := &device.Device{}
+	now := time.Now()
+
+	rxParamSetup := pb_lorawan.MACCommand{CID: uint32(lorawan.RXParamSetupReq), Payload: []byte{1, 2, 3, 4}}
+	txParamSetup := pb_lorawan.MACCommand{CID: types.TxParamSetupReq, Payload: []byte{0x2D}}
+
+	// Answers are not tracked
+	trackMACCommands(dev, []pb_lorawan.MACCommand{{CID: types.DeviceTimeAns}}, now)
+	a.So(dev.MAC.Pending, ShouldBeEmpty)
+
+	trackMACCommands(dev, []pb_lorawan.MACCommand{rxParamSetup, txParamSetup}, now)
+	a.So(dev.MAC.Pending, ShouldHaveLength, 2)
+	a.So(dev.MAC.Pending[0].Attempts, ShouldEqual, 1)
+
+	// The device only answers the TxParamSetupReq; the RXParamSetupReq is sent again
+	handleMACAnswers(dev, []pb_lorawan.MACCommand{{CID: types.TxParamSetupAns}})
+	a.So(dev.MAC.Pending, ShouldHaveLength, 1)
+	message := adrInitUplinkMessage()
+	retryMACCommands(ctx, message, dev, now)
+	fOpts := message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts
+	a.So(fOpts, ShouldResemble, []pb_lorawan.MACCommand{rxParamSetup})
+	trackMACCommands(dev, fOpts, now)
+	a.So(dev.MAC.Pending[0].Attempts, ShouldEqual, 2)
+
+	// A newer request with the same CID replaces the pending request
+	newer := pb_lorawan.MACCommand{CID: uint32(lorawan.RXParamSetupReq), Payload: []byte{4, 3, 2, 1}}
+	message = adrInitUplinkMessage()
+	message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{newer}
+	retryMACCommands(ctx, message, dev, now)
+	fOpts = message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts
+	a.So(fOpts, ShouldResemble, []pb_lorawan.MACCommand{newer})
+	trackMACCommands(dev, fOpts, now)
+	a.So(dev.MAC.Pending, ShouldHaveLength, 1)
+	a.So(dev.MAC.Pending[0].Payload, ShouldResemble, newer.Payload)
+	a.So(dev.MAC.Pending[0].Attempts, ShouldEqual, 1)
+
+	// The request expires after too many attempts
+	for i := 1; i < maxMACCommandAttempts; i++ {
+		trackMACCommands(dev, fOpts, now)
+	}
+	message = adrInitUplinkMessage()
+	retryMACCommands(ctx, message, dev, now)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+	a.So(dev.MAC.Pending, ShouldBeEmpty)
+	a.So(dev.MAC.Unanswered, ShouldResemble, []uint32{uint32(lorawan.RXParamSetupReq)})
+
+	// The request expires when it was first sent too long ago
+	trackMACCommands(dev, []pb_lorawan.MACCommand{txParamSetup}, now.Add(-2*macCommandExpiry))
+	retryMACCommands(ctx, adrInitUplinkMessage(), dev, now)
+	a.So(dev.MAC.Pending, ShouldBeEmpty)
+	a.So(dev.MAC.Unanswered, ShouldHaveLength, 2)
+
+	// A late answer removes the command from the unanswered commands
+	handleMACAnswers(dev, []pb_lorawan.MACCommand{{CID: uint32(lorawan.RXParamSetupAns)}})
+	a.So(dev.MAC.Unanswered, ShouldResemble, []uint32{types.TxParamSetupReq})
+}
+
+func TestPendingLinkADRReqBlock(t *testing.T) {
+	a := New(t)
+	ctx := GetLogger(t, "TestPendingLinkADRReqBlock")
+	dev := &device.Device{}
+	now := time.Now()
+
+	block := []pb_lorawan.MACCommand{
+		{CID: uint32(lorawan.LinkADRReq), Payload: []byte{0x50, 0xFF, 0x00, 0x01}},
+		{CID: uint32(lorawan.LinkADRReq), Payload: []byte{0x50, 0x00, 0xFF, 0x11}},
+		{CID: uint32(lorawan.LinkADRReq), Payload: []byte{0x50, 0x00, 0x00, 0x21}},
+	}
+	trackMACCommands(dev, block, now)
+	a.So(dev.MAC.Pending, ShouldHaveLength, 3)
+
+	// The block does not fit next to another command, so none of it is sent again
+	message := adrInitUplinkMessage()
+	message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{{CID: uint32(lorawan.DevStatusReq)}}
+	retryMACCommands(ctx, message, dev, now)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldHaveLength, 1)
+	a.So(dev.MAC.Pending, ShouldHaveLength, 3)
+
+	// The whole block is sent again
+	message = adrInitUplinkMessage()
+	retryMACCommands(ctx, message, dev, now)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldResemble, block)
+}
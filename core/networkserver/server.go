@@ -7,6 +7,7 @@ import (
 	"github.com/TheThingsNetwork/api/broker"
 	"github.com/TheThingsNetwork/api/handler"
 	pb "github.com/TheThingsNetwork/api/networkserver"
+	"github.com/TheThingsNetwork/ttn/api"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/TheThingsNetwork/ttn/utils/security"
 	"github.com/dgrijalva/jwt-go"
@@ -55,7 +56,7 @@ func (s *networkServerRPC) GetDevices(ctx context.Context, req *pb.DevicesReques
 	if err := req.Validate(); err != nil {
 		return nil, errors.Wrap(err, "Invalid Devices Request")
 	}
-	res, err := s.networkServer.HandleGetDevices(req)
+	res, err := s.networkServer.HandleGetDevices(req, api.ResetIndFromIncomingContext(ctx))
 	if err != nil {
 		return nil, err
 	}
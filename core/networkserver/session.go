@@ -0,0 +1,130 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/channelplan"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// Session is the state of the session of a device in the NetworkServer, for inspection by operators. It contains
+// fingerprints of the keys instead of the keys.
+type Session struct {
+	AppEUI   types.AppEUI   `json:"app_eui"`
+	DevEUI   types.DevEUI   `json:"dev_eui"`
+	AppID    string         `json:"app_id"`
+	DevID    string         `json:"dev_id"`
+	DevAddr  types.DevAddr  `json:"dev_addr"`
+	NwkSKey  string         `json:"nwk_s_key_fingerprint,omitempty"`
+	FCntUp   uint32         `json:"f_cnt_up"`
+	FCntDown uint32         `json:"f_cnt_down"`
+	LastSeen time.Time      `json:"last_seen"`
+	Options  device.Options `json:"options"`
+
+	ADR      SessionADR            `json:"adr"`
+	RX       SessionRX             `json:"rx"`
+	Channels []channelplan.Channel `json:"channels,omitempty"` // extra channels that the device confirmed
+
+	// MAC commands that were sent to the device, but not yet answered, and MAC commands that expired without answer
+	PendingMAC    []SessionMACCommand `json:"pending_mac,omitempty"`
+	UnansweredMAC []string            `json:"unanswered_mac,omitempty"`
+
+	// The recent uplinks that are used for ADR
+	Frames []*device.Frame `json:"frames,omitempty"`
+}
+
+// SessionADR is the ADR state of a session
+type SessionADR struct {
+	Band        string `json:"band,omitempty"`
+	Margin      int    `json:"margin"`
+	DataRate    string `json:"data_rate,omitempty"`
+	TxPower     int    `json:"tx_power,omitempty"`
+	NbTrans     int    `json:"nb_trans,omitempty"`
+	Failed      int    `json:"failed,omitempty"`
+	ChannelMask []int  `json:"channel_mask,omitempty"`
+}
+
+// SessionMACCommand is a MAC command of a session that was sent to the device, but not yet answered
+type SessionMACCommand struct {
+	Command   string    `json:"command"`
+	Attempts  int       `json:"attempts"`
+	FirstSent time.Time `json:"first_sent"`
+	LastSent  time.Time `json:"last_sent"`
+}
+
+// SessionRX contains the receive windows of a session
+type SessionRX struct {
+	RX1Delay     int    `json:"rx1_delay,omitempty"` // seconds
+	RX2Frequency int    `json:"rx2_frequency,omitempty"`
+	RX2DataRate  string `json:"rx2_data_rate,omitempty"`
+
+	// Class B ping slots
+	PingSlotPeriod    int    `json:"ping_slot_period,omitempty"` // seconds
+	PingSlotFrequency uint32 `json:"ping_slot_frequency,omitempty"`
+	PingSlotDataRate  uint8  `json:"ping_slot_data_rate,omitempty"`
+}
+
+// GetSession returns the state of the session of the device
+func (n *networkServer) GetSession(appEUI types.AppEUI, devEUI types.DevEUI) (*Session, error) {
+	dev, err := n.devices.Get(appEUI, devEUI)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{
+		AppEUI:   dev.AppEUI,
+		DevEUI:   dev.DevEUI,
+		AppID:    dev.AppID,
+		DevID:    dev.DevID,
+		DevAddr:  dev.DevAddr,
+		NwkSKey:  dev.NwkSKey.Fingerprint(),
+		FCntUp:   dev.FCntUp,
+		FCntDown: dev.FCntDown,
+		LastSeen: dev.LastSeen,
+		Options:  dev.Options,
+		ADR: SessionADR{
+			Band:        dev.ADR.Band,
+			Margin:      dev.ADR.Margin,
+			DataRate:    dev.ADR.DataRate,
+			TxPower:     dev.ADR.TxPower,
+			NbTrans:     dev.ADR.NbTrans,
+			Failed:      dev.ADR.Failed,
+			ChannelMask: dev.ADR.ChannelMask,
+		},
+		Channels: dev.Channels.Provisioned,
+	}
+
+	for _, cmd := range dev.MAC.Pending {
+		session.PendingMAC = append(session.PendingMAC, SessionMACCommand{
+			Command:   macRequests[cmd.CID],
+			Attempts:  cmd.Attempts,
+			FirstSent: cmd.FirstSent,
+			LastSent:  cmd.LastSent,
+		})
+	}
+	for _, cid := range dev.MAC.Unanswered {
+		session.UnansweredMAC = append(session.UnansweredMAC, macRequests[cid])
+	}
+
+	if fp, err := band.Get(dev.ADR.Band); err == nil {
+		session.RX.RX1Delay = int(fp.ReceiveDelay1 / time.Second)
+		session.RX.RX2Frequency = fp.RX2Frequency
+		session.RX.RX2DataRate, _ = fp.GetDataRateStringForIndex(fp.RX2DataRate)
+	}
+	if dev.ClassB.PingSlotInfo {
+		session.RX.PingSlotPeriod = int(dev.ClassB.PingSlotPeriod() / time.Second)
+		session.RX.PingSlotFrequency = dev.ClassB.PingSlotFrequency
+		session.RX.PingSlotDataRate = dev.ClassB.PingSlotDataRate
+	}
+
+	if history, err := n.devices.Frames(appEUI, devEUI); err == nil {
+		session.Frames, _ = history.Get()
+	}
+
+	return session, nil
+}
@@ -0,0 +1,63 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGetSession(t *testing.T) {
+	a := New(t)
+
+	ns := &networkServer{
+		devices: device.NewRedisDeviceStore(GetRedisClient(), "ns-test-get-session"),
+	}
+
+	appEUI := types.AppEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+	devEUI := types.DevEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+
+	_, err := ns.GetSession(appEUI, devEUI)
+	a.So(err, ShouldNotBeNil)
+
+	ns.devices.Set(&device.Device{
+		AppEUI:   appEUI,
+		DevEUI:   devEUI,
+		DevAddr:  getDevAddr(1, 2, 3, 4),
+		NwkSKey:  types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 249, 250, 251, 252, 253, 254, 255, 0},
+		FCntUp:   42,
+		FCntDown: 3,
+		ADR: device.ADRSettings{
+			Band:     "EU_863_870",
+			DataRate: "SF8BW125",
+		},
+		MAC: device.MACCommands{
+			Pending:    []device.PendingMACCommand{{CID: uint32(lorawan.LinkADRReq), Attempts: 2}},
+			Unanswered: []uint32{types.TxParamSetupReq},
+		},
+	})
+	defer func() {
+		ns.devices.Delete(appEUI, devEUI)
+	}()
+
+	session, err := ns.GetSession(appEUI, devEUI)
+	a.So(err, ShouldBeNil)
+	a.So(session.DevAddr, ShouldEqual, getDevAddr(1, 2, 3, 4))
+	a.So(session.NwkSKey, ShouldEqual, "D28DFCBF")
+	a.So(session.FCntUp, ShouldEqual, 42)
+	a.So(session.FCntDown, ShouldEqual, 3)
+	a.So(session.ADR.DataRate, ShouldEqual, "SF8BW125")
+	a.So(session.RX.RX1Delay, ShouldEqual, 1)
+	a.So(session.RX.RX2Frequency, ShouldEqual, 869525000)
+	a.So(session.RX.RX2DataRate, ShouldEqual, "SF9BW125")
+	a.So(session.PendingMAC, ShouldHaveLength, 1)
+	a.So(session.PendingMAC[0].Command, ShouldEqual, "LinkADRReq")
+	a.So(session.PendingMAC[0].Attempts, ShouldEqual, 2)
+	a.So(session.UnansweredMAC, ShouldResemble, []string{"TxParamSetupReq"})
+}
@@ -0,0 +1,77 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"fmt"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// maxTxParamAttempts is the number of TxParamSetupReq that are sent before we assume that the device does not support it
+const maxTxParamAttempts = 3
+
+// maxEIRPTable contains the values (in dBm) of the MaxEIRP field of the TxParamSetupReq
+var maxEIRPTable = []int{8, 10, 12, 13, 14, 16, 18, 20, 21, 24, 26, 27, 29, 30, 33, 36}
+
+func txParamSetupPayload(params *band.TxParams) []byte {
+	var payload byte
+	for i, eirp := range maxEIRPTable {
+		if eirp <= params.MaxEIRP {
+			payload = byte(i)
+		}
+	}
+	if params.UplinkDwellTime {
+		payload |= 1 << 4
+	}
+	if params.DownlinkDwellTime {
+		payload |= 1 << 5
+	}
+	return []byte{payload}
+}
+
+// setTxParams adds a TxParamSetupReq if the frequency plan of the device has TxParams that were not yet acknowledged
+func (n *networkServer) setTxParams(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device) {
+	if dev.TxParams.Acked || dev.TxParams.Attempts >= maxTxParamAttempts {
+		return
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil || fp.TxParams == nil {
+		return
+	}
+	mac := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	cmd := pb_lorawan.MACCommand{CID: types.TxParamSetupReq, Payload: txParamSetupPayload(fp.TxParams)}
+	if fOptsLen(mac.FOpts)+fOptsLen([]pb_lorawan.MACCommand{cmd}) > maxFOptsLen {
+		return
+	}
+	mac.FOpts = append(mac.FOpts, cmd)
+	dev.TxParams.Attempts++
+	message.Trace = message.Trace.WithEvent(ScheduleMACEvent, macCMD, "tx-param-setup")
+}
+
+// validateDownlinkSize returns an error if the FRMPayload and FOpts of the downlink do not fit in the data rate
+func validateDownlinkSize(message *pb_broker.DownlinkMessage, dev *device.Device) error {
+	dataRate := message.GetDownlinkOption().GetProtocolConfiguration().GetLoRaWAN().GetDataRate()
+	if dataRate == "" || dev.ADR.Band == "" {
+		return nil
+	}
+	fp, err := band.Get(dev.ADR.Band)
+	if err != nil {
+		return nil
+	}
+	maxSize, err := fp.GetMaxPayloadSizeFor(dataRate)
+	if err != nil {
+		return nil
+	}
+	mac := message.GetMessage().GetLoRaWAN().GetMACPayload()
+	if size := len(mac.FRMPayload) + fOptsLen(mac.FOpts); size > maxSize {
+		return errors.NewErrInvalidArgument("Downlink", fmt.Sprintf("payload of %d bytes exceeds the maximum of %d bytes for %s", size, maxSize, dataRate))
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestTxParamSetupPayload(t *testing.T) {
+	a := New(t)
+	a.So(txParamSetupPayload(&band.TxParams{MaxEIRP: 16}), ShouldResemble, []byte{0x05})
+	a.So(txParamSetupPayload(&band.TxParams{UplinkDwellTime: true, MaxEIRP: 30}), ShouldResemble, []byte{0x1D})
+	a.So(txParamSetupPayload(&band.TxParams{UplinkDwellTime: true, DownlinkDwellTime: true, MaxEIRP: 16}), ShouldResemble, []byte{0x35})
+}
+
+func TestSetTxParams(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestSetTxParams"),
+		},
+		devices: device.NewRedisDeviceStore(GetRedisClient(), "ns-test-set-tx-params"),
+	}
+
+	dev := &device.Device{}
+	dev.ADR.Band = "EU_863_870"
+	message := adrInitUplinkMessage()
+	ns.setTxParams(message, dev)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+
+	dev.ADR.Band = "AS_923"
+	for i := 0; i < maxTxParamAttempts; i++ {
+		message = adrInitUplinkMessage()
+		ns.setTxParams(message, dev)
+		fOpts := message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts
+		a.So(fOpts, ShouldHaveLength, 1)
+		a.So(fOpts[0].CID, ShouldEqual, types.TxParamSetupReq)
+	}
+
+	// Stop after the maximum number of attempts
+	message = adrInitUplinkMessage()
+	ns.setTxParams(message, dev)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+
+	// Stop after the answer
+	dev.TxParams = device.TxParams{}
+	message = adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{{CID: types.TxParamSetupAns}}
+	err := ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.TxParams.Acked, ShouldBeTrue)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+}
+
+func TestValidateDownlinkSize(t *testing.T) {
+	a := New(t)
+
+	dev := &device.Device{}
+	dev.ADR.Band = "AS_923"
+
+	message := &pb_broker.DownlinkMessage{
+		Message: new(pb_protocol.Message),
+		DownlinkOption: &pb_broker.DownlinkOption{
+			ProtocolConfiguration: pb_protocol.TxConfiguration{Protocol: &pb_protocol.TxConfiguration_LoRaWAN{
+				LoRaWAN: &pb_lorawan.TxConfiguration{DataRate: "SF10BW125"},
+			}},
+		},
+	}
+	mac := message.Message.InitLoRaWAN().InitDownlink()
+	mac.FRMPayload = make([]byte, 11)
+	a.So(validateDownlinkSize(message, dev), ShouldBeNil)
+
+	mac.FRMPayload = make([]byte, 12)
+	a.So(validateDownlinkSize(message, dev), ShouldNotBeNil)
+
+	dev.ADR.Band = "EU_863_870"
+	a.So(validateDownlinkSize(message, dev), ShouldBeNil)
+}
@@ -4,7 +4,9 @@
 package networkserver
 
 import (
+	"encoding/binary"
 	"fmt"
+	"time"
 
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
@@ -12,6 +14,7 @@ import (
 	"github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/core/band"
 	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/brocaar/lorawan"
 )
 
@@ -35,6 +38,9 @@ func (n *networkServer) handleUplinkMAC(message *pb_broker.DeduplicatedUplinkMes
 	}
 
 	// MAC Commands
+	var linkADRAns bool
+	channelMaskAck := true
+	var newChannelAns, dlChannelAns []bool
 	for _, cmd := range lorawanUplinkMAC.FOpts {
 		md := message.GetProtocolMetadata()
 		switch cmd.CID {
@@ -55,6 +61,8 @@ func (n *networkServer) handleUplinkMAC(message *pb_broker.DeduplicatedUplinkMes
 				break
 			}
 			dev.ADR.ExpectRes = false
+			linkADRAns = true
+			channelMaskAck = channelMaskAck && answer.ChannelMaskACK
 			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "link-adr",
 				"data-rate-ack", answer.DataRateACK,
 				"power-ack", answer.PowerACK,
@@ -69,10 +77,80 @@ func (n *networkServer) handleUplinkMAC(message *pb_broker.DeduplicatedUplinkMes
 					WithField("Answer", fmt.Sprintf("%v/%v/%v", answer.DataRateACK, answer.PowerACK, answer.ChannelMaskACK)).
 					Warn("Negative LinkADRAns")
 			}
+		case uint32(lorawan.NewChannelAns):
+			var answer lorawan.NewChannelAnsPayload
+			if err := answer.UnmarshalBinary(cmd.Payload); err != nil {
+				break
+			}
+			newChannelAns = append(newChannelAns, answer.ChannelFrequencyOK && answer.DataRateRangeOK)
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "new-channel",
+				"frequency-ack", answer.ChannelFrequencyOK,
+				"data-rate-ack", answer.DataRateRangeOK,
+			)
+		case types.DlChannelAns:
+			if len(cmd.Payload) != 1 {
+				break
+			}
+			dlChannelAns = append(dlChannelAns, cmd.Payload[0]&0x03 == 0x03)
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "dl-channel",
+				"frequency-ack", cmd.Payload[0]&0x01 != 0,
+				"uplink-frequency-ack", cmd.Payload[0]&0x02 != 0,
+			)
+		case types.TxParamSetupAns:
+			dev.TxParams.Acked = true
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "tx-param-setup")
+		case types.DeviceTimeReq:
+			t, source := getUplinkTime(message)
+			lorawanDownlinkMAC.FOpts = append(lorawanDownlinkMAC.FOpts, pb_lorawan.MACCommand{
+				CID:     types.DeviceTimeAns,
+				Payload: deviceTimeAnsPayload(t),
+			})
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "device-time", "source", source)
+		case types.PingSlotInfoReq:
+			handlePingSlotInfoReq(message, dev, cmd.Payload)
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "ping-slot-info", "periodicity", dev.ClassB.Periodicity)
+		case types.PingSlotChannelAns:
+			ack := handlePingSlotChannelAns(dev, cmd.Payload)
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "ping-slot-channel", "ack", ack)
+		case types.ResetInd:
+			if !dev.Options.ABP {
+				// Only ABP devices can be reset, OTAA devices join again
+				message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "reset", "ignored", true)
+				break
+			}
+			minor := lorawanMinor(cmd.Payload)
+			ctx.WithField("Minor", minor).Info("Device was reset")
+			if err := n.resetSession(message, dev); err != nil {
+				return err
+			}
+			lorawanDownlinkMAC.FOpts = append(lorawanDownlinkMAC.FOpts, pb_lorawan.MACCommand{
+				CID:     types.ResetConf,
+				Payload: []byte{minor},
+			})
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "reset", "minor", minor)
+		case types.RekeyInd:
+			minor := lorawanMinor(cmd.Payload)
+			lorawanDownlinkMAC.FOpts = append(lorawanDownlinkMAC.FOpts, pb_lorawan.MACCommand{
+				CID:     types.RekeyConf,
+				Payload: []byte{minor},
+			})
+			message.Trace = message.Trace.WithEvent(trace.HandleMACEvent, macCMD, "rekey", "minor", minor)
 		default:
 		}
 	}
 
+	// Answered MAC commands are no longer pending
+	handleMACAnswers(dev, lorawanUplinkMAC.FOpts)
+
+	// The LinkADRReq blocks are applied or rejected by the device as a whole
+	if linkADRAns {
+		handleChannelMaskAns(dev, channelMaskAck)
+	}
+
+	if len(newChannelAns) > 0 {
+		handleChannelAns(dev, newChannelAns, dlChannelAns)
+	}
+
 	// We did not receive an ADR response, the device may have the wrong RX2 settings
 	if dev.ADR.ExpectRes && dev.ADR.Band == "EU_863_870" {
 		ctx.Warn("No LinkADRAns received")
@@ -108,6 +186,20 @@ func (n *networkServer) handleUplinkMAC(message *pb_broker.DeduplicatedUplinkMes
 		return err
 	}
 
+	// Dwell time and EIRP of the frequency plan
+	n.setTxParams(message, dev)
+
+	// Ping slot channel of Class B devices
+	n.setPingSlotChannel(message, dev)
+
+	// Extra channels of the application
+	if err := n.setChannels(message, dev); err != nil {
+		return err
+	}
+
+	// MAC commands that were not answered
+	retryMACCommands(ctx, message, dev, time.Now())
+
 	// We can't send MAC on port 0; send them on port 1
 	if len(lorawanDownlinkMAC.FOpts) != 0 && lorawanDownlinkMAC.FPort == 0 {
 		lorawanDownlinkMAC.FPort = 1
@@ -115,3 +207,37 @@ func (n *networkServer) handleUplinkMAC(message *pb_broker.DeduplicatedUplinkMes
 
 	return nil
 }
+
+// lorawanMinor returns the LoRaWAN minor version of a ResetInd or RekeyInd,
+// limited to the highest version that we support
+func lorawanMinor(payload []byte) uint8 {
+	if len(payload) == 0 {
+		return types.MaxLoRaWANMinor
+	}
+	if minor := payload[0] & 0x0F; minor < types.MaxLoRaWANMinor {
+		return minor
+	}
+	return types.MaxLoRaWANMinor
+}
+
+// resetSession resets the state of a device that indicated that it was reset. The MIC of the uplink is remembered,
+// so that the uplink can not reset the session again if it is replayed.
+func (n *networkServer) resetSession(message *pb_broker.DeduplicatedUplinkMessage, dev *device.Device) error {
+	dev.FCntDown = 0
+	dev.ResetMACState()
+	if len(message.Payload) >= 4 {
+		dev.AcceptReset(binary.BigEndian.Uint32(message.Payload[len(message.Payload)-4:]))
+	}
+
+	lorawanDownlinkMAC := message.GetResponseTemplate().GetMessage().GetLoRaWAN().GetMACPayload()
+	lorawanDownlinkMAC.FCnt = 0
+	if lorawan := message.GetResponseTemplate().GetDownlinkOption().GetProtocolConfiguration().GetLoRaWAN(); lorawan != nil {
+		lorawan.FCnt = 0
+	}
+
+	frames, err := n.devices.Frames(dev.AppEUI, dev.DevEUI)
+	if err != nil {
+		return err
+	}
+	return frames.Clear()
+}
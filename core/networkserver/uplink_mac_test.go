@@ -0,0 +1,100 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package networkserver
+
+import (
+	"testing"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/networkserver/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestLoRaWANMinor(t *testing.T) {
+	a := New(t)
+	a.So(lorawanMinor(nil), ShouldEqual, 1)
+	a.So(lorawanMinor([]byte{0x00}), ShouldEqual, 0)
+	a.So(lorawanMinor([]byte{0x01}), ShouldEqual, 1)
+	a.So(lorawanMinor([]byte{0x02}), ShouldEqual, 1)
+}
+
+func TestHandleUplinkMACReset(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestHandleUplinkMACReset"),
+		},
+		devices: device.NewRedisDeviceStore(GetRedisClient(), "ns-test-handle-uplink-mac-reset"),
+	}
+
+	appEUI := types.AppEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+	devEUI := types.DevEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+	dev := &device.Device{
+		AppEUI:   appEUI,
+		DevEUI:   devEUI,
+		FCntDown: 42,
+		Options:  device.Options{ABP: true},
+		ADR: device.ADRSettings{
+			Band:     "EU_863_870",
+			Margin:   10,
+			DataRate: "SF7BW125",
+			SendReq:  true,
+		},
+	}
+	frames, _ := ns.devices.Frames(appEUI, devEUI)
+	frames.Push(&device.Frame{FCnt: 100})
+	defer frames.Clear()
+
+	message := adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{
+		pb_lorawan.MACCommand{CID: types.ResetInd, Payload: []byte{0x01}},
+	}
+	message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FCnt = 42
+	message.Payload = []byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x02, 0x01, 0x00, types.ResetInd, 0x01, 0x0A, 0x0B, 0x0C, 0x0D}
+
+	err := ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.FCntDown, ShouldEqual, 0)
+	a.So(dev.ADR.Band, ShouldEqual, "EU_863_870")
+	a.So(dev.ADR.Margin, ShouldEqual, 10)
+	a.So(dev.ADR.DataRate, ShouldBeEmpty)
+
+	downlinkMAC := message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload()
+	a.So(downlinkMAC.FCnt, ShouldEqual, 0)
+	a.So(downlinkMAC.FOpts, ShouldHaveLength, 1)
+	a.So(downlinkMAC.FOpts[0].CID, ShouldEqual, types.ResetConf)
+	a.So(downlinkMAC.FOpts[0].Payload, ShouldResemble, []byte{0x01})
+
+	history, _ := frames.Get()
+	a.So(history, ShouldBeEmpty)
+	a.So(dev.ResetAccepted(0x0A0B0C0D), ShouldBeTrue)
+
+	// OTAA devices are not reset
+	dev.Options.ABP = false
+	dev.FCntDown = 5
+	message = adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{
+		pb_lorawan.MACCommand{CID: types.ResetInd, Payload: []byte{0x01}},
+	}
+	err = ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.FCntDown, ShouldEqual, 5)
+	a.So(message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload().FOpts, ShouldBeEmpty)
+
+	// RekeyInd does not reset the session
+	dev.FCntDown = 5
+	message = adrInitUplinkMessage()
+	message.Message.GetLoRaWAN().GetMACPayload().FOpts = []pb_lorawan.MACCommand{
+		pb_lorawan.MACCommand{CID: types.RekeyInd, Payload: []byte{0x01}},
+	}
+	err = ns.handleUplinkMAC(message, dev)
+	a.So(err, ShouldBeNil)
+	a.So(dev.FCntDown, ShouldEqual, 5)
+	downlinkMAC = message.ResponseTemplate.Message.GetLoRaWAN().GetMACPayload()
+	a.So(downlinkMAC.FOpts, ShouldHaveLength, 1)
+	a.So(downlinkMAC.FOpts[0].CID, ShouldEqual, types.RekeyConf)
+}
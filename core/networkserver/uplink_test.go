@@ -9,6 +9,7 @@ import (
 
 	pb_broker "github.com/TheThingsNetwork/api/broker"
 	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb "github.com/TheThingsNetwork/api/networkserver"
 	pb_protocol "github.com/TheThingsNetwork/api/protocol"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/ttn/core/component"
@@ -120,3 +121,85 @@ func TestHandleUplink(t *testing.T) {
 	a.So(dev.FCntUp, ShouldEqual, 1)
 	a.So(time.Now().Sub(dev.LastSeen), ShouldBeLessThan, 1*time.Second)
 }
+
+func TestHandleUplinkResetInd(t *testing.T) {
+	a := New(t)
+	ns := &networkServer{
+		Component: &component.Component{
+			Ctx: GetLogger(t, "TestHandleUplinkResetInd"),
+		},
+		devices: device.NewRedisDeviceStore(GetRedisClient(), "ns-test-handle-uplink-reset-ind"),
+	}
+	ns.InitStatus()
+
+	appEUI := types.AppEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+	devEUI := types.DevEUI(getEUI(1, 2, 3, 4, 5, 6, 7, 8))
+	devAddr := getDevAddr(1, 2, 3, 4)
+
+	ns.devices.Set(&device.Device{
+		DevAddr:  devAddr,
+		AppEUI:   appEUI,
+		DevEUI:   devEUI,
+		FCntUp:   100,
+		FCntDown: 42,
+		Options:  device.Options{ABP: true},
+	})
+	defer func() {
+		ns.devices.Delete(appEUI, devEUI)
+	}()
+
+	// MHDR, DevAddr, FCtrl with FOptsLen 2, FCnt 1, ResetInd with LoRaWAN 1.1, MIC
+	payload := []byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x02, 0x01, 0x00, types.ResetInd, 0x01, 0x01, 0x02, 0x03, 0x04}
+	a.So(types.HasResetInd(payload), ShouldBeTrue)
+
+	// The Broker finds the device with GetDevices, although its FCntUp is higher
+	res, err := ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr,
+		FCnt:    1,
+	}, payload[len(payload)-4:])
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldHaveLength, 1)
+
+	message := &pb_broker.DeduplicatedUplinkMessage{
+		AppEUI:           &appEUI,
+		DevEUI:           &devEUI,
+		Payload:          payload,
+		ResponseTemplate: &pb_broker.DownlinkMessage{DownlinkOption: &pb_broker.DownlinkOption{}},
+		GatewayMetadata: []*pb_gateway.RxMetadata{
+			&pb_gateway.RxMetadata{},
+		},
+		ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{
+			LoRaWAN: &pb_lorawan.Metadata{
+				DataRate: "SF7BW125",
+			},
+		}},
+	}
+	uplink, err := ns.HandleUplink(message)
+	a.So(err, ShouldBeNil)
+	a.So(uplink.ResponseTemplate, ShouldNotBeNil)
+
+	// The session is reset and the ResetInd is answered
+	var phyPayload lorawan.PHYPayload
+	phyPayload.UnmarshalBinary(uplink.ResponseTemplate.Payload)
+	macPayload, _ := phyPayload.MACPayload.(*lorawan.MACPayload)
+	a.So(macPayload.FHDR.FCnt, ShouldEqual, 0)
+	a.So(macPayload.FHDR.FOpts, ShouldNotBeEmpty)
+	a.So(macPayload.FHDR.FOpts[0].CID, ShouldEqual, types.ResetConf)
+
+	dev, _ := ns.devices.Get(appEUI, devEUI)
+	a.So(dev.FCntUp, ShouldEqual, 1)
+	a.So(dev.FCntDown, ShouldEqual, 0)
+
+	// The device continues to send uplinks
+	dev.StartUpdate()
+	dev.FCntUp = 100
+	ns.devices.Set(dev)
+
+	// A replay of the uplink does not reset the session again
+	res, err = ns.HandleGetDevices(&pb.DevicesRequest{
+		DevAddr: devAddr,
+		FCnt:    1,
+	}, payload[len(payload)-4:])
+	a.So(err, ShouldBeNil)
+	a.So(res.Results, ShouldBeEmpty)
+}
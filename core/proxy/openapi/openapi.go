@@ -0,0 +1,248 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package openapi generates OpenAPI 3 documents for the HTTP APIs that are served by the gRPC proxies
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Path is the path where the document is served
+const Path = "/openapi.json"
+
+// Route is an HTTP route of the gRPC proxy
+type Route struct {
+	Method    string
+	Path      string
+	Summary   string
+	Request   interface{} // The message that is sent as body; nil if there is no body
+	Response  interface{} // The message that is returned
+	Paginated bool        // The route accepts the offset and limit query parameters
+}
+
+var pathParameter = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Document is an OpenAPI 3 document
+type Document map[string]interface{}
+
+// NewDocument builds the OpenAPI 3 document for the routes. The schemas of the
+// request and response messages are derived from their (protobuf) Go types.
+func NewDocument(title, version string, routes []Route) Document {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+	for _, route := range routes {
+		operation := map[string]interface{}{
+			"summary":  route.Summary,
+			"security": []map[string][]string{{"bearer": {}}, {"key": {}}},
+		}
+		var parameters []map[string]interface{}
+		for _, match := range pathParameter.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]string{"type": "string"},
+			})
+		}
+		if route.Paginated {
+			for _, name := range []string{"offset", "limit"} {
+				parameters = append(parameters, map[string]interface{}{
+					"name":   name,
+					"in":     "query",
+					"schema": map[string]string{"type": "integer"},
+				})
+			}
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(route.Request), schemas)},
+				},
+			}
+		}
+		response := map[string]interface{}{"description": "OK"}
+		if route.Response != nil {
+			response["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(route.Response), schemas)},
+			}
+		}
+		operation["responses"] = map[string]interface{}{
+			"200":     response,
+			"default": map[string]interface{}{"description": "Error", "content": map[string]interface{}{"application/json": map[string]interface{}{"schema": errorSchema}}},
+		}
+		if paths[route.Path] == nil {
+			paths[route.Path] = make(map[string]interface{})
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+	return Document{
+		"openapi": "3.0.0",
+		"info":    map[string]string{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]string{"type": "http", "scheme": "bearer"},
+				"key":    map[string]string{"type": "apiKey", "in": "header", "name": "Authorization", "description": "Key <access key>"},
+			},
+		},
+	}
+}
+
+var errorSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"error": map[string]string{"type": "string"},
+		"code":  map[string]string{"type": "integer"},
+	},
+}
+
+var (
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringer      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// schemaName returns the name of the schema of a named struct, for example handler.Device
+func schemaName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// schemaFor returns the schema of the type as it is marshaled by the gRPC proxy. Structs are added to schemas and referenced.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(textMarshaler) {
+			return map[string]interface{}{"type": "string"}
+		}
+		t = t.Elem()
+	}
+	if t.Implements(textMarshaler) || reflect.PtrTo(t).Implements(textMarshaler) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int32, reflect.Int16, reflect.Int8, reflect.Int, reflect.Uint32, reflect.Uint16, reflect.Uint8, reflect.Uint:
+		if t.Kind() == reflect.Int32 && t.Name() != "int32" && t.Implements(stringer) {
+			return map[string]interface{}{"type": "string"} // Enums are marshaled by name
+		}
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "string", "format": "int64"} // 64 bit integers are marshaled as strings
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = nil // Placeholder for recursive messages
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || strings.HasPrefix(field.Name, "XXX_") {
+			continue
+		}
+		if field.Tag.Get("protobuf_oneof") != "" {
+			continue // Added below
+		}
+		name := fieldName(field)
+		if name == "" {
+			continue
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+	}
+	for _, wrapper := range oneofWrappers(t) {
+		wrapperType := reflect.TypeOf(wrapper)
+		for wrapperType.Kind() == reflect.Ptr {
+			wrapperType = wrapperType.Elem()
+		}
+		for i := 0; i < wrapperType.NumField(); i++ {
+			field := wrapperType.Field(i)
+			if name := fieldName(field); name != "" {
+				properties[name] = schemaFor(field.Type, schemas)
+			}
+		}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// fieldName returns the name of the field in JSON, using the original protobuf name if available
+func fieldName(field reflect.StructField) string {
+	if tag := field.Tag.Get("protobuf"); tag != "" {
+		for _, part := range strings.Split(tag, ",") {
+			if strings.HasPrefix(part, "name=") {
+				return strings.TrimPrefix(part, "name=")
+			}
+		}
+	}
+	if tag := field.Tag.Get("json"); tag != "" {
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// oneofWrappers returns the wrapper types of the oneof fields of a protobuf message
+func oneofWrappers(t reflect.Type) []interface{} {
+	method, ok := reflect.PtrTo(t).MethodByName("XXX_OneofFuncs")
+	if !ok {
+		return nil
+	}
+	out := method.Func.Call([]reflect.Value{reflect.New(t)})
+	if len(out) != 4 {
+		return nil
+	}
+	wrappers, _ := out[3].Interface().([]interface{})
+	return wrappers
+}
+
+// WithDocument wraps the handler of the HTTP API so that the document is served on Path
+func WithDocument(api http.Handler, doc Document) http.Handler {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == Path && req.Method == "GET" {
+			res.Header().Set("Content-Type", "application/json")
+			res.Write(data)
+			return
+		}
+		api.ServeHTTP(res, req)
+	})
+}
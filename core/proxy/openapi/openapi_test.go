@@ -0,0 +1,87 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+type testMessage struct {
+	AppID    string            `protobuf:"bytes,1,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+	DevEUI   *types.DevEUI     `protobuf:"bytes,2,opt,name=dev_eui,json=devEui,proto3" json:"dev_eui,omitempty"`
+	FCnt     uint32            `protobuf:"varint,3,opt,name=f_cnt,json=fCnt,proto3" json:"f_cnt,omitempty"`
+	LastSeen int64             `protobuf:"varint,4,opt,name=last_seen,json=lastSeen,proto3" json:"last_seen,omitempty"`
+	Payload  []byte            `protobuf:"bytes,5,opt,name=payload,proto3" json:"payload,omitempty"`
+	Children []*testMessage    `protobuf:"bytes,6,rep,name=children" json:"children,omitempty"`
+	Labels   map[string]string `protobuf:"bytes,7,rep,name=labels" json:"labels,omitempty"`
+	Device   isTestOneof       `protobuf_oneof:"device"`
+}
+
+type isTestOneof interface{ isTestOneof() }
+
+type testMessage_LoRaWAN struct {
+	LoRaWAN *testMessage `protobuf:"bytes,8,opt,name=lorawan_device,oneof"`
+}
+
+func (*testMessage_LoRaWAN) isTestOneof() {}
+
+func (*testMessage) XXX_OneofFuncs() (func(), func(), func(), []interface{}) {
+	return nil, nil, nil, []interface{}{(*testMessage_LoRaWAN)(nil)}
+}
+
+func TestDocument(t *testing.T) {
+	a := New(t)
+
+	doc := NewDocument("Test API", "v1", []Route{
+		{Method: "GET", Path: "/things/{app_id}", Summary: "Get", Response: testMessage{}, Paginated: true},
+		{Method: "POST", Path: "/things/{app_id}", Summary: "Set", Request: testMessage{}},
+	})
+
+	paths := doc["paths"].(map[string]map[string]interface{})
+	a.So(paths["/things/{app_id}"], ShouldContainKey, "get")
+	a.So(paths["/things/{app_id}"], ShouldContainKey, "post")
+	a.So(paths["/things/{app_id}"]["get"].(map[string]interface{})["parameters"], ShouldHaveLength, 3)
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	a.So(schemas, ShouldContainKey, "openapi.testMessage")
+	properties := schemas["openapi.testMessage"].(map[string]interface{})["properties"].(map[string]interface{})
+	a.So(properties["app_id"], ShouldResemble, map[string]interface{}{"type": "string"})
+	a.So(properties["dev_eui"], ShouldResemble, map[string]interface{}{"type": "string"})
+	a.So(properties["f_cnt"], ShouldResemble, map[string]interface{}{"type": "integer"})
+	a.So(properties["last_seen"], ShouldResemble, map[string]interface{}{"type": "string", "format": "int64"})
+	a.So(properties["payload"], ShouldResemble, map[string]interface{}{"type": "string", "format": "byte"})
+	a.So(properties["children"], ShouldResemble, map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/openapi.testMessage"}})
+	a.So(properties["labels"], ShouldResemble, map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}})
+	a.So(properties["lorawan_device"], ShouldResemble, map[string]interface{}{"$ref": "#/components/schemas/openapi.testMessage"})
+	a.So(properties, ShouldNotContainKey, "device")
+
+	_, err := json.Marshal(doc)
+	a.So(err, ShouldBeNil)
+}
+
+func TestWithDocument(t *testing.T) {
+	a := New(t)
+
+	api := http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusTeapot)
+	})
+	h := WithDocument(api, NewDocument("Test API", "v1", HandlerRoutes))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", Path, nil))
+	a.So(rec.Code, ShouldEqual, http.StatusOK)
+	var doc map[string]interface{}
+	a.So(json.Unmarshal(rec.Body.Bytes(), &doc), ShouldBeNil)
+	a.So(doc["openapi"], ShouldEqual, "3.0.0")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/applications/test", nil))
+	a.So(rec.Code, ShouldEqual, http.StatusTeapot)
+}
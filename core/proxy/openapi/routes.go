@@ -0,0 +1,34 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	pb_handler "github.com/TheThingsNetwork/api/handler"
+	gogo "github.com/gogo/protobuf/types"
+)
+
+// The routes are kept in sync with the gRPC gateways by TestRoutes, which fails if a route is not registered.
+
+// HandlerRoutes are the routes of the ApplicationManager API of the Handler
+var HandlerRoutes = []Route{
+	{Method: "POST", Path: "/applications", Summary: "Register an application with the Handler", Request: pb_handler.ApplicationIdentifier{}, Response: gogo.Empty{}},
+	{Method: "GET", Path: "/applications/{app_id}", Summary: "Get the application", Response: pb_handler.Application{}},
+	{Method: "POST", Path: "/applications/{app_id}", Summary: "Set the application", Request: pb_handler.Application{}, Response: gogo.Empty{}},
+	{Method: "DELETE", Path: "/applications/{app_id}", Summary: "Delete the application", Response: gogo.Empty{}},
+	{Method: "GET", Path: "/applications/{app_id}/devices", Summary: "List the devices of the application", Response: pb_handler.DeviceList{}, Paginated: true},
+	{Method: "POST", Path: "/applications/{app_id}/devices", Summary: "Register a device", Request: pb_handler.Device{}, Response: gogo.Empty{}},
+	{Method: "GET", Path: "/applications/{app_id}/devices/{dev_id}", Summary: "Get the device", Response: pb_handler.Device{}},
+	{Method: "POST", Path: "/applications/{app_id}/devices/{dev_id}", Summary: "Set the device", Request: pb_handler.Device{}, Response: gogo.Empty{}},
+	{Method: "DELETE", Path: "/applications/{app_id}/devices/{dev_id}", Summary: "Delete the device", Response: gogo.Empty{}},
+	{Method: "POST", Path: "/applications/{app_id}/devices/{dev_id}/simulate-uplink", Summary: "Simulate an uplink of the device", Request: pb_handler.SimulatedUplinkMessage{}, Response: gogo.Empty{}},
+	{Method: "POST", Path: "/applications/{app_id}/dry-uplink", Summary: "Test the payload functions with an uplink payload", Request: pb_handler.DryUplinkMessage{}, Response: pb_handler.DryUplinkResult{}},
+	{Method: "POST", Path: "/applications/{app_id}/dry-downlink", Summary: "Test the payload functions with downlink fields", Request: pb_handler.DryDownlinkMessage{}, Response: pb_handler.DryDownlinkResult{}},
+}
+
+// DiscoveryRoutes are the routes of the Discovery API
+var DiscoveryRoutes = []Route{
+	{Method: "GET", Path: "/announcements/{service_name}", Summary: "List the announcements of a service", Response: pb_discovery.AnnouncementsResponse{}},
+	{Method: "GET", Path: "/announcements/{service_name}/{id}", Summary: "Get the announcement of a component", Response: pb_discovery.Announcement{}},
+}
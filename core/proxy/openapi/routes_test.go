@@ -0,0 +1,59 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package openapi
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	pb_handler "github.com/TheThingsNetwork/api/handler"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	. "github.com/smartystreets/assertions"
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// TestRoutes checks that every route in the documents is registered by the gRPC gateway, and that no two routes
+// lead to the same gRPC method
+func TestRoutes(t *testing.T) {
+	a := New(t)
+
+	for _, api := range []struct {
+		routes   []Route
+		register func(context.Context, *runtime.ServeMux, *grpc.ClientConn) error
+	}{
+		{HandlerRoutes, pb_handler.RegisterApplicationManagerHandler},
+		{DiscoveryRoutes, pb_discovery.RegisterDiscoveryHandler},
+	} {
+		var called string
+		conn, err := grpc.Dial("localhost:0", grpc.WithInsecure(), grpc.WithUnaryInterceptor(
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+				called = method
+				return grpc.Errorf(codes.Unimplemented, "not implemented")
+			},
+		))
+		a.So(err, ShouldBeNil)
+		mux := runtime.NewServeMux()
+		a.So(api.register(context.Background(), mux, conn), ShouldBeNil)
+
+		routes := make(map[string]string)
+		for _, route := range api.routes {
+			called = ""
+			path := pathParameter.ReplaceAllString(route.Path, "test")
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(route.Method, path, strings.NewReader("{}")))
+			if called == "" {
+				t.Errorf("%s %s is not registered by the gRPC gateway", route.Method, route.Path)
+				continue
+			}
+			if other, ok := routes[called]; ok {
+				t.Errorf("%s %s and %s both lead to %s", route.Method, route.Path, other, called)
+			}
+			routes[called] = route.Method + " " + route.Path
+		}
+		conn.Close()
+	}
+}
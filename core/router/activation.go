@@ -15,6 +15,7 @@ import (
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	pb "github.com/TheThingsNetwork/api/router"
 	"github.com/TheThingsNetwork/api/trace"
+	"github.com/TheThingsNetwork/ttn/api/idempotency"
 	"github.com/TheThingsNetwork/ttn/core/band"
 	"github.com/TheThingsNetwork/ttn/core/router/gateway"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
@@ -55,10 +56,22 @@ func (r *router) HandleActivation(gatewayID string, activation *pb.DeviceActivat
 		Trace:            activation.Trace,
 	}
 
+	if err = r.validateMetadata(gateway, uplink); err != nil {
+		return nil, err
+	}
+
 	if err = gateway.HandleUplink(uplink); err != nil {
 		return nil, err
 	}
 
+	if !r.filter.allowsGateway(gateway, uplink.GatewayMetadata.Frequency) {
+		return nil, errors.New("Activation not forwarded by the forwarding filter of this Router")
+	}
+
+	if reason := r.filterSignal(gateway, uplink); reason != "" {
+		return nil, errors.New(fmt.Sprintf("Activation not forwarded by the signal filter of this Router (%s)", reason))
+	}
+
 	if !gateway.Schedule.IsActive() {
 		return nil, errors.NewErrInternal(fmt.Sprintf("Gateway %s not available for downlink", gatewayID))
 	}
@@ -69,10 +82,11 @@ func (r *router) HandleActivation(gatewayID string, activation *pb.DeviceActivat
 	)
 
 	// Find Broker
-	brokers, err := r.Discovery.GetAll("broker")
+	brokers, err := r.brokersForJoinEUI(activation.AppEUI)
 	if err != nil {
 		return nil, err
 	}
+	brokers = r.filter.filterBrokers(brokers)
 
 	// Prepare request
 	request := &pb_broker.DeviceActivationRequest{
@@ -128,7 +142,7 @@ func (r *router) HandleActivation(gatewayID string, activation *pb.DeviceActivat
 		gateway.MonitorStream.Send(activation)
 	}
 
-	// Forward to all brokers and collect responses
+	// Forward to all brokers and collect responses. Brokers that receive a request again handle it only once.
 	var wg sync.WaitGroup
 	responses := make(chan *pb_broker.DeviceActivationResponse, len(brokers))
 	for _, broker := range brokers {
@@ -140,9 +154,13 @@ func (r *router) HandleActivation(gatewayID string, activation *pb.DeviceActivat
 		// Do async request
 		wg.Add(1)
 		go func() {
-			ctx, cancel := context.WithTimeout(r.Component.GetContext(""), 5*time.Second)
-			defer cancel()
-			res, err := broker.client.Activate(ctx, request)
+			var res *pb_broker.DeviceActivationResponse
+			err := idempotency.Retry(func(key string) (err error) {
+				ctx, cancel := context.WithTimeout(idempotency.OutgoingContextWithKey(r.Component.GetContext(""), key), 5*time.Second)
+				defer cancel()
+				res, err = broker.client.Activate(ctx, request)
+				return err
+			})
 			if err == nil && res != nil {
 				responses <- res
 			}
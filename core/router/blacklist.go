@@ -0,0 +1,114 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// BrokerBlacklists are the blacklists of Brokers that the Router follows, so that the uplinks of blacklisted DevAddrs
+// are dropped at the edge instead of in the Broker
+type BrokerBlacklists struct {
+	URLs     []string      // the /blacklist endpoints on the health ports of the Brokers
+	Interval time.Duration // the interval at which the blacklists are fetched
+}
+
+type brokerBlacklists struct {
+	BrokerBlacklists
+	client *http.Client
+	done   chan struct{}
+
+	mu      sync.RWMutex
+	blocked map[types.DevAddr]time.Time // until
+}
+
+func (r *router) WithBrokerBlacklists(blacklists BrokerBlacklists) Router {
+	r.blacklists = &brokerBlacklists{
+		BrokerBlacklists: blacklists,
+		client:           &http.Client{Timeout: 10 * time.Second},
+		done:             make(chan struct{}),
+		blocked:          make(map[types.DevAddr]time.Time),
+	}
+	return r
+}
+
+// startBrokerBlacklists fetches the blacklists until the Router shuts down
+func (r *router) startBrokerBlacklists() {
+	go func() {
+		for {
+			if err := r.blacklists.update(); err != nil {
+				r.Ctx.WithError(err).Warn("Could not update broker blacklists")
+			}
+			select {
+			case <-time.After(r.blacklists.Interval):
+			case <-r.blacklists.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops fetching the blacklists
+func (b *brokerBlacklists) stop() {
+	close(b.done)
+}
+
+// update replaces the blocked DevAddrs with the entries of the blacklists. If a blacklist can not be fetched, the
+// entries that were blocked before are kept until they expire.
+func (b *brokerBlacklists) update() (err error) {
+	blocked := make(map[types.DevAddr]time.Time)
+	for _, url := range b.URLs {
+		entries, fetchErr := b.fetch(url)
+		if fetchErr != nil {
+			err = fetchErr
+			b.mu.RLock()
+			for devAddr, until := range b.blocked {
+				blocked[devAddr] = until
+			}
+			b.mu.RUnlock()
+			continue
+		}
+		for _, entry := range entries {
+			if entry.Until.After(blocked[entry.DevAddr]) {
+				blocked[entry.DevAddr] = entry.Until
+			}
+		}
+	}
+	b.mu.Lock()
+	b.blocked = blocked
+	b.mu.Unlock()
+	return err
+}
+
+func (b *brokerBlacklists) fetch(url string) ([]blacklist.Entry, error) {
+	res, err := b.client.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not fetch broker blacklist")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.NewErrInternal(fmt.Sprintf("Broker blacklist %s returned %s", url, res.Status))
+	}
+	var entries []blacklist.Entry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, errors.Wrap(err, "Could not decode broker blacklist")
+	}
+	return entries, nil
+}
+
+// Blocked returns true if the DevAddr is on one of the blacklists
+func (b *brokerBlacklists) Blocked(devAddr types.DevAddr) bool {
+	b.mu.RLock()
+	until, ok := b.blocked[devAddr]
+	b.mu.RUnlock()
+	return ok && time.Now().Before(until)
+}
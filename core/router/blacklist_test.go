@@ -0,0 +1,40 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/broker/blacklist"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestBrokerBlacklists(t *testing.T) {
+	a := New(t)
+
+	bl := blacklist.NewBlacklist(1, time.Minute, time.Minute)
+	bl.Fail(types.DevAddr{1, 2, 3, 4}, "unknown_dev_addr")
+	server := httptest.NewServer(bl)
+
+	r := &router{}
+	r.WithBrokerBlacklists(BrokerBlacklists{URLs: []string{server.URL + blacklist.Path}, Interval: time.Minute})
+	a.So(r.blacklists.update(), ShouldBeNil)
+	a.So(r.blacklists.Blocked(types.DevAddr{1, 2, 3, 4}), ShouldBeTrue)
+	a.So(r.blacklists.Blocked(types.DevAddr{5, 6, 7, 8}), ShouldBeFalse)
+
+	// Unblocked DevAddrs are removed
+	bl.Unblock(types.DevAddr{1, 2, 3, 4})
+	a.So(r.blacklists.update(), ShouldBeNil)
+	a.So(r.blacklists.Blocked(types.DevAddr{1, 2, 3, 4}), ShouldBeFalse)
+
+	// Blocked DevAddrs are kept if the blacklist can not be fetched
+	bl.Fail(types.DevAddr{5, 6, 7, 8}, "unknown_dev_addr")
+	a.So(r.blacklists.update(), ShouldBeNil)
+	server.Close()
+	a.So(r.blacklists.update(), ShouldNotBeNil)
+	a.So(r.blacklists.Blocked(types.DevAddr{5, 6, 7, 8}), ShouldBeTrue)
+}
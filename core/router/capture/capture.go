@@ -0,0 +1,114 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package capture records the uplink messages that gateways send to the Router, so that they can be replayed later
+package capture
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/router"
+)
+
+// Record is a captured uplink message
+type Record struct {
+	Time      int64  `json:"time"`
+	GatewayID string `json:"gateway_id"`
+	Uplink    []byte `json:"uplink"`
+}
+
+// UplinkMessage unmarshals the captured uplink message
+func (r *Record) UplinkMessage() (*pb.UplinkMessage, error) {
+	uplink := new(pb.UplinkMessage)
+	if err := uplink.Unmarshal(r.Uplink); err != nil {
+		return nil, err
+	}
+	return uplink, nil
+}
+
+// Writer writes records as JSON lines
+type Writer struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+// NewWriter returns a new Writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write captures the uplink message as it was received from the gateway
+func (w *Writer) Write(gatewayID string, uplink *pb.UplinkMessage) error {
+	data, err := uplink.Marshal()
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(Record{
+		Time:      time.Now().UnixNano(),
+		GatewayID: gatewayID,
+		Uplink:    data,
+	})
+}
+
+// Close closes the underlying writer if it is an io.Closer
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if closer, ok := w.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Reader reads records that were written by a Writer
+type Reader struct {
+	dec *json.Decoder
+}
+
+// NewReader returns a new Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record, or io.EOF at the end of the capture
+func (r *Reader) Next() (*Record, error) {
+	record := new(Record)
+	if err := r.dec.Decode(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Replay calls fn for every record, with the original timing between the records divided by speed.
+// A speed of 0 replays the records without waiting.
+func Replay(r *Reader, speed float64, fn func(record *Record) error) error {
+	var first int64
+	start := time.Now()
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first == 0 {
+			first = record.Time
+		}
+		if speed > 0 {
+			offset := time.Duration(float64(record.Time-first) / speed)
+			if wait := offset - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package capture
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb "github.com/TheThingsNetwork/api/router"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	a := New(t)
+
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf)
+	a.So(w.Write("gtw-1", &pb.UplinkMessage{Payload: []byte{1, 2, 3}, GatewayMetadata: pb_gateway.RxMetadata{GatewayID: "gtw-1", Timestamp: 1000}}), ShouldBeNil)
+	time.Sleep(50 * time.Millisecond)
+	a.So(w.Write("gtw-2", &pb.UplinkMessage{Payload: []byte{4, 5, 6}}), ShouldBeNil)
+	a.So(w.Close(), ShouldBeNil)
+
+	var gateways []string
+	var payloads [][]byte
+	start := time.Now()
+	err := Replay(NewReader(bytes.NewReader(buf.Bytes())), 1, func(record *Record) error {
+		uplink, err := record.UplinkMessage()
+		if err != nil {
+			return err
+		}
+		gateways = append(gateways, record.GatewayID)
+		payloads = append(payloads, uplink.Payload)
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	a.So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 40*time.Millisecond)
+	a.So(gateways, ShouldResemble, []string{"gtw-1", "gtw-2"})
+	a.So(payloads, ShouldResemble, [][]byte{{1, 2, 3}, {4, 5, 6}})
+
+	// Without timing
+	var count int
+	start = time.Now()
+	err = Replay(NewReader(bytes.NewReader(buf.Bytes())), 0, func(record *Record) error {
+		count++
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	a.So(count, ShouldEqual, 2)
+	a.So(time.Since(start), ShouldBeLessThan, 40*time.Millisecond)
+
+	// Corrupt capture
+	err = Replay(NewReader(bytes.NewReader([]byte("{nope"))), 0, func(record *Record) error { return nil })
+	a.So(err, ShouldNotBeNil)
+}
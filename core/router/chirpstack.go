@@ -0,0 +1,208 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/router/chirpstack"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/TheThingsNetwork/ttn/utils/random"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ChirpStackBridge is the MQTT server where ChirpStack gateway bridges publish the traffic of their gateways
+type ChirpStackBridge struct {
+	Server   string
+	Username string
+	Password string
+}
+
+// chirpStackGatewayTimeout is the time after which the downlink subscription of a gateway that did not publish any
+// events is removed. The gateway bridges publish stats every 30 seconds by default.
+const chirpStackGatewayTimeout = 5 * time.Minute
+
+type chirpStackBridge struct {
+	client MQTT.Client
+	token  uint32
+	done   chan struct{}
+
+	mu       sync.Mutex
+	gateways map[types.EUI64]*chirpStackGateway // gateways with a downlink subscription
+}
+
+type chirpStackGateway struct {
+	format   chirpstack.Format
+	lastSeen time.Time
+}
+
+// WithChirpStackBridge connects gateways that use the ChirpStack gateway bridge. Gateways are identified by the
+// "eui-" prefix and their EUI; the MQTT server is responsible for their authentication.
+func (r *router) WithChirpStackBridge(bridge ChirpStackBridge) Router {
+	opts := MQTT.NewClientOptions()
+	opts.AddBroker(bridge.Server)
+	opts.SetClientID(fmt.Sprintf("ttn-router-%s", random.String(16)))
+	opts.SetUsername(bridge.Username)
+	opts.SetPassword(bridge.Password)
+	opts.SetCleanSession(true)
+	opts.SetOnConnectHandler(func(client MQTT.Client) {
+		// Also subscribes again after a reconnect
+		if token := client.Subscribe(chirpstack.EventTopic, 0, r.handleChirpStackEvent); token.Wait() && token.Error() != nil {
+			r.Ctx.WithError(token.Error()).Warn("Could not subscribe to ChirpStack gateway events")
+		}
+	})
+	r.chirpstack = &chirpStackBridge{
+		client:   MQTT.NewClient(opts),
+		done:     make(chan struct{}),
+		gateways: make(map[types.EUI64]*chirpStackGateway),
+	}
+	return r
+}
+
+// connectChirpStackBridge connects to the MQTT server of the ChirpStack gateway bridges and removes the downlink
+// subscriptions of idle gateways until the Router shuts down
+func (r *router) connectChirpStackBridge() error {
+	if token := r.chirpstack.client.Connect(); token.Wait() && token.Error() != nil {
+		return errors.Wrap(token.Error(), "Could not connect to ChirpStack gateway bridge")
+	}
+	go func() {
+		ticker := time.NewTicker(chirpStackGatewayTimeout / 5)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				r.unsubscribeIdleChirpStackGateways(now.Add(-1 * chirpStackGatewayTimeout))
+			case <-r.chirpstack.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stop stops removing idle gateways and disconnects from the MQTT server
+func (b *chirpStackBridge) stop() {
+	close(b.done)
+	b.client.Disconnect(250)
+}
+
+// hasGatewayToken returns true if the gateway connected to the Router with a token. The traffic of such a gateway
+// must not be accepted from the gateway bridges, where the MQTT server is responsible for the authentication.
+func (r *router) hasGatewayToken(gatewayID string) bool {
+	r.gatewaysLock.RLock()
+	gtw, ok := r.gateways[gatewayID]
+	r.gatewaysLock.RUnlock()
+	return ok && gtw.Token() != ""
+}
+
+func (r *router) handleChirpStackEvent(_ MQTT.Client, msg MQTT.Message) {
+	eui, event, err := chirpstack.ParseEventTopic(msg.Topic())
+	if err != nil {
+		r.Ctx.WithError(err).Warn("Could not handle ChirpStack gateway event")
+		return
+	}
+	gatewayID := chirpstack.GatewayID(eui)
+	ctx := r.Ctx.WithField("GatewayID", gatewayID)
+
+	if r.hasGatewayToken(gatewayID) {
+		ctx.Warn("Gateway is registered with a token, ignoring ChirpStack gateway event")
+		r.unsubscribeChirpStackDownlink(eui)
+		return
+	}
+
+	format := chirpstack.DetectFormat(msg.Payload())
+	switch event {
+	case chirpstack.UplinkEvent:
+		frame, err := chirpstack.UnmarshalUplinkFrame(format, msg.Payload())
+		if err != nil {
+			ctx.WithError(err).WithField("Format", format).Warn("Could not decode ChirpStack uplink")
+			return
+		}
+		uplink, err := frame.ToUplink(eui)
+		if err != nil {
+			ctx.WithError(err).Warn("Could not convert ChirpStack uplink")
+			return
+		}
+		r.subscribeChirpStackDownlink(eui, format)
+		go r.ingestUplink(gatewayID, uplink)
+	case chirpstack.StatsEvent:
+		stats, err := chirpstack.UnmarshalGatewayStats(format, msg.Payload())
+		if err != nil {
+			ctx.WithError(err).WithField("Format", format).Warn("Could not decode ChirpStack gateway stats")
+			return
+		}
+		r.subscribeChirpStackDownlink(eui, format)
+		go r.HandleGatewayStatus(gatewayID, stats.ToStatus())
+	}
+}
+
+// subscribeChirpStackDownlink publishes the downlink of the gateway to its ChirpStack gateway bridge, in the format
+// of its last event, until the gateway is idle or the Router stops
+func (r *router) subscribeChirpStackDownlink(eui types.EUI64, format chirpstack.Format) {
+	r.chirpstack.mu.Lock()
+	defer r.chirpstack.mu.Unlock()
+	if gtw, ok := r.chirpstack.gateways[eui]; ok {
+		gtw.format = format
+		gtw.lastSeen = time.Now()
+		return
+	}
+	gatewayID := chirpstack.GatewayID(eui)
+	ctx := r.Ctx.WithField("GatewayID", gatewayID)
+	downlink, err := r.SubscribeDownlink(gatewayID, "chirpstack")
+	if err != nil {
+		ctx.WithError(err).Warn("Could not subscribe to downlink for ChirpStack gateway")
+		return
+	}
+	gtw := &chirpStackGateway{format: format, lastSeen: time.Now()}
+	r.chirpstack.gateways[eui] = gtw
+	go func() {
+		for message := range downlink {
+			frame, err := chirpstack.FromDownlink(eui, atomic.AddUint32(&r.chirpstack.token, 1), message)
+			if err != nil {
+				ctx.WithError(err).Warn("Could not convert downlink for ChirpStack gateway")
+				continue
+			}
+			r.chirpstack.mu.Lock()
+			format := gtw.format
+			r.chirpstack.mu.Unlock()
+			data, err := chirpstack.MarshalDownlinkFrame(format, frame)
+			if err != nil {
+				ctx.WithError(err).Warn("Could not encode downlink for ChirpStack gateway")
+				continue
+			}
+			if token := r.chirpstack.client.Publish(chirpstack.DownlinkTopic(eui), 0, false, data); token.Wait() && token.Error() != nil {
+				ctx.WithError(token.Error()).Warn("Could not publish downlink for ChirpStack gateway")
+			}
+		}
+	}()
+}
+
+// unsubscribeChirpStackDownlink stops publishing the downlink of the gateway to its ChirpStack gateway bridge
+func (r *router) unsubscribeChirpStackDownlink(eui types.EUI64) {
+	r.chirpstack.mu.Lock()
+	defer r.chirpstack.mu.Unlock()
+	if _, ok := r.chirpstack.gateways[eui]; !ok {
+		return
+	}
+	delete(r.chirpstack.gateways, eui)
+	r.UnsubscribeDownlink(chirpstack.GatewayID(eui), "chirpstack")
+}
+
+// unsubscribeIdleChirpStackGateways removes the downlink subscriptions of the gateways that did not publish any
+// events since the given time
+func (r *router) unsubscribeIdleChirpStackGateways(since time.Time) {
+	r.chirpstack.mu.Lock()
+	defer r.chirpstack.mu.Unlock()
+	for eui, gtw := range r.chirpstack.gateways {
+		if gtw.lastSeen.Before(since) {
+			delete(r.chirpstack.gateways, eui)
+			r.UnsubscribeDownlink(chirpstack.GatewayID(eui), "chirpstack")
+			r.Ctx.WithField("GatewayID", chirpstack.GatewayID(eui)).Debug("Removed downlink subscription of idle ChirpStack gateway")
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chirpstack
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/gogo/protobuf/proto"
+	. "github.com/smartystreets/assertions"
+)
+
+var eui = types.EUI64{1, 2, 3, 4, 5, 6, 7, 8}
+
+func TestTopics(t *testing.T) {
+	a := New(t)
+
+	a.So(GatewayID(eui), ShouldEqual, "eui-0102030405060708")
+	parsed, event, err := ParseEventTopic("gateway/0102030405060708/event/up")
+	a.So(err, ShouldBeNil)
+	a.So(parsed, ShouldEqual, eui)
+	a.So(event, ShouldEqual, UplinkEvent)
+	_, _, err = ParseEventTopic("gateway/0102030405060708/command/down")
+	a.So(err, ShouldNotBeNil)
+	_, _, err = ParseEventTopic("gateway/not-an-eui/event/up")
+	a.So(err, ShouldNotBeNil)
+
+	a.So(DownlinkTopic(eui), ShouldEqual, "gateway/0102030405060708/command/down")
+}
+
+func TestUplink(t *testing.T) {
+	a := New(t)
+
+	var frame UplinkFrame
+	err := json.Unmarshal([]byte(`{
+		"phyPayload": "QAQDAgGAAQABppRkJhXWw7WC",
+		"txInfo": {
+			"frequency": 868100000,
+			"modulation": "LORA",
+			"loRaModulationInfo": {"bandwidth": 125, "spreadingFactor": 7, "codeRate": "4/5"}
+		},
+		"rxInfo": {
+			"gatewayID": "AQIDBAUGBwg=",
+			"time": "2017-06-13T15:28:56Z",
+			"rssi": -42,
+			"loRaSNR": 7.5,
+			"channel": 2,
+			"rfChain": 1,
+			"location": {"latitude": 52.37, "longitude": 4.89, "altitude": 10, "source": "GPS"},
+			"context": "AAAD6A=="
+		}
+	}`), &frame)
+	a.So(err, ShouldBeNil)
+
+	uplink, err := frame.ToUplink(eui)
+	a.So(err, ShouldBeNil)
+	a.So(uplink.Payload, ShouldNotBeEmpty)
+	a.So(uplink.ProtocolMetadata.GetLoRaWAN().DataRate, ShouldEqual, "SF7BW125")
+	a.So(uplink.ProtocolMetadata.GetLoRaWAN().CodingRate, ShouldEqual, "4/5")
+	a.So(uplink.GatewayMetadata.GatewayID, ShouldEqual, "eui-0102030405060708")
+	a.So(uplink.GatewayMetadata.Timestamp, ShouldEqual, 1000)
+	a.So(uplink.GatewayMetadata.Time, ShouldEqual, 1497367736000000000)
+	a.So(uplink.GatewayMetadata.Frequency, ShouldEqual, 868100000)
+	a.So(uplink.GatewayMetadata.RSSI, ShouldEqual, -42)
+	a.So(uplink.GatewayMetadata.SNR, ShouldEqual, 7.5)
+	a.So(uplink.GatewayMetadata.Channel, ShouldEqual, 2)
+	a.So(uplink.GatewayMetadata.RfChain, ShouldEqual, 1)
+	a.So(uplink.GatewayMetadata.Location.Source, ShouldEqual, pb_gateway.LocationMetadata_GPS)
+
+	frame.TXInfo = UplinkTXInfo{Frequency: 868300000, Modulation: FSK, FSKModulationInfo: &FSKModulationInfo{DataRate: 50000}}
+	uplink, err = frame.ToUplink(eui)
+	a.So(err, ShouldBeNil)
+	a.So(uplink.ProtocolMetadata.GetLoRaWAN().Modulation, ShouldEqual, pb_lorawan.Modulation_FSK)
+	a.So(uplink.ProtocolMetadata.GetLoRaWAN().BitRate, ShouldEqual, 50000)
+
+	frame.TXInfo = UplinkTXInfo{Modulation: LoRa}
+	_, err = frame.ToUplink(eui)
+	a.So(err, ShouldNotBeNil)
+
+	_, err = UplinkFrame{}.ToUplink(eui)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestStatus(t *testing.T) {
+	a := New(t)
+
+	var stats GatewayStats
+	err := json.Unmarshal([]byte(`{"rxPacketsReceived": 10, "rxPacketsReceivedOK": 8, "txPacketsReceived": 2, "txPacketsEmitted": 1}`), &stats)
+	a.So(err, ShouldBeNil)
+	status := stats.ToStatus()
+	a.So(status.RxIn, ShouldEqual, 10)
+	a.So(status.RxOk, ShouldEqual, 8)
+	a.So(status.TxIn, ShouldEqual, 2)
+	a.So(status.TxOk, ShouldEqual, 1)
+	a.So(status.Location, ShouldBeNil)
+}
+
+func TestDownlink(t *testing.T) {
+	a := New(t)
+
+	downlink := &pb.DownlinkMessage{
+		Payload: []byte{1, 2, 3},
+		ProtocolConfiguration: pb_protocol.TxConfiguration{Protocol: &pb_protocol.TxConfiguration_LoRaWAN{LoRaWAN: &pb_lorawan.TxConfiguration{
+			Modulation: pb_lorawan.Modulation_LORA,
+			DataRate:   "SF9BW125",
+			CodingRate: "4/5",
+		}}},
+		GatewayConfiguration: pb_gateway.TxConfiguration{
+			Timestamp:             1001000,
+			Frequency:             869525000,
+			Power:                 27,
+			PolarizationInversion: true,
+		},
+	}
+
+	frame, err := FromDownlink(eui, 42, downlink)
+	a.So(err, ShouldBeNil)
+	a.So(frame.Token, ShouldEqual, 42)
+	a.So(frame.PHYPayload, ShouldResemble, []byte{1, 2, 3})
+	a.So(frame.TXInfo.Frequency, ShouldEqual, 869525000)
+	a.So(frame.TXInfo.Power, ShouldEqual, 27)
+	a.So(frame.TXInfo.Timing, ShouldEqual, "DELAY")
+	a.So(timestamp(frame.TXInfo.Context), ShouldEqual, 1001000)
+	a.So(*frame.TXInfo.LoRaModulationInfo, ShouldResemble, LoRaModulationInfo{
+		Bandwidth:             125,
+		SpreadingFactor:       9,
+		CodeRate:              "4/5",
+		PolarizationInversion: true,
+	})
+	a.So(frame.Items, ShouldHaveLength, 1)
+	a.So(frame.Items[0].TXInfo, ShouldResemble, frame.TXInfo)
+
+	downlink.ProtocolConfiguration.GetLoRaWAN().DataRate = "SF13BW125"
+	_, err = FromDownlink(eui, 43, downlink)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestProtobuf(t *testing.T) {
+	a := New(t)
+
+	data, err := proto.Marshal(&protoUplinkFrame{
+		PHYPayload: []byte{1, 2, 3},
+		TXInfo: &protoUplinkTXInfo{
+			Frequency:          868100000,
+			LoRaModulationInfo: &protoLoRaModulationInfo{Bandwidth: 125, SpreadingFactor: 7, CodeRate: "4/5"},
+		},
+		RXInfo: &protoUplinkRXInfo{
+			GatewayID: eui.Bytes(),
+			Time:      &protoTimestamp{Seconds: 1497367736},
+			RSSI:      -42,
+			LoRaSNR:   7.5,
+			Channel:   2,
+			Location:  &protoLocation{Latitude: 52.37, Longitude: 4.89, Source: 1},
+			Context:   []byte{0, 0, 3, 232},
+		},
+	})
+	a.So(err, ShouldBeNil)
+	a.So(DetectFormat(data), ShouldEqual, Protobuf)
+	a.So(DetectFormat([]byte(`{"phyPayload": "AQID"}`)), ShouldEqual, JSON)
+
+	frame, err := UnmarshalUplinkFrame(Protobuf, data)
+	a.So(err, ShouldBeNil)
+	uplink, err := frame.ToUplink(eui)
+	a.So(err, ShouldBeNil)
+	a.So(uplink.Payload, ShouldResemble, []byte{1, 2, 3})
+	a.So(uplink.ProtocolMetadata.GetLoRaWAN().DataRate, ShouldEqual, "SF7BW125")
+	a.So(uplink.GatewayMetadata.Timestamp, ShouldEqual, 1000)
+	a.So(uplink.GatewayMetadata.Time, ShouldEqual, 1497367736000000000)
+	a.So(uplink.GatewayMetadata.RSSI, ShouldEqual, -42)
+	a.So(uplink.GatewayMetadata.SNR, ShouldEqual, 7.5)
+	a.So(uplink.GatewayMetadata.Location.Source, ShouldEqual, pb_gateway.LocationMetadata_GPS)
+
+	data, err = proto.Marshal(&protoGatewayStats{RXPacketsReceived: 10, TXPacketsEmitted: 1})
+	a.So(err, ShouldBeNil)
+	stats, err := UnmarshalGatewayStats(Protobuf, data)
+	a.So(err, ShouldBeNil)
+	a.So(stats.RXPacketsReceived, ShouldEqual, 10)
+	a.So(stats.TXPacketsEmitted, ShouldEqual, 1)
+
+	downlink, err := FromDownlink(eui, 42, &pb.DownlinkMessage{
+		Payload: []byte{1, 2, 3},
+		ProtocolConfiguration: pb_protocol.TxConfiguration{Protocol: &pb_protocol.TxConfiguration_LoRaWAN{LoRaWAN: &pb_lorawan.TxConfiguration{
+			Modulation: pb_lorawan.Modulation_LORA,
+			DataRate:   "SF9BW125",
+			CodingRate: "4/5",
+		}}},
+		GatewayConfiguration: pb_gateway.TxConfiguration{Timestamp: 1001000, Frequency: 869525000, Power: 27},
+	})
+	a.So(err, ShouldBeNil)
+	data, err = MarshalDownlinkFrame(Protobuf, downlink)
+	a.So(err, ShouldBeNil)
+	var msg protoDownlinkFrame
+	a.So(proto.Unmarshal(data, &msg), ShouldBeNil)
+	a.So(msg.Token, ShouldEqual, 42)
+	a.So(msg.Items, ShouldHaveLength, 1)
+	a.So(msg.Items[0].PHYPayload, ShouldResemble, []byte{1, 2, 3})
+	a.So(msg.Items[0].TXInfo.Frequency, ShouldEqual, 869525000)
+	a.So(msg.Items[0].TXInfo.Timing, ShouldEqual, 1) // DELAY
+	a.So(msg.Items[0].TXInfo.DelayTimingInfo.Delay.Seconds, ShouldEqual, 0)
+	a.So(msg.Items[0].TXInfo.LoRaModulationInfo.SpreadingFactor, ShouldEqual, 9)
+	a.So(timestamp(msg.Items[0].TXInfo.Context), ShouldEqual, 1001000)
+
+	a.So(newProtoDuration(1500*time.Millisecond), ShouldResemble, &protoDuration{Seconds: 1, Nanos: 500000000})
+}
@@ -0,0 +1,330 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chirpstack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Format is the marshaler that a ChirpStack gateway bridge is configured with
+type Format int
+
+// The formats of the ChirpStack gateway bridge
+const (
+	JSON Format = iota
+	Protobuf
+)
+
+func (f Format) String() string {
+	if f == Protobuf {
+		return "protobuf"
+	}
+	return "json"
+}
+
+// DetectFormat returns the format of a message of the ChirpStack gateway bridge. JSON messages are objects, and
+// protobuf messages never start with a "{".
+func DetectFormat(data []byte) Format {
+	if len(data) > 0 && data[0] == '{' {
+		return JSON
+	}
+	return Protobuf
+}
+
+// The modulations of the ChirpStack gateway bridge
+const (
+	LoRa = "LORA"
+	FSK  = "FSK"
+)
+
+// LoRaModulationInfo contains the LoRa modulation of a frame
+type LoRaModulationInfo struct {
+	Bandwidth             uint32 `json:"bandwidth"` // kHz
+	SpreadingFactor       uint32 `json:"spreadingFactor"`
+	CodeRate              string `json:"codeRate"`
+	PolarizationInversion bool   `json:"polarizationInversion"`
+}
+
+// FSKModulationInfo contains the FSK modulation of a frame
+type FSKModulationInfo struct {
+	FrequencyDeviation uint32 `json:"frequencyDeviation"`
+	DataRate           uint32 `json:"datarate"`
+}
+
+// Location is the location of a gateway
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+	Source    string  `json:"source,omitempty"`
+}
+
+// UplinkTXInfo contains the transmission of an uplink frame
+type UplinkTXInfo struct {
+	Frequency          uint32              `json:"frequency"`
+	Modulation         string              `json:"modulation"`
+	LoRaModulationInfo *LoRaModulationInfo `json:"loRaModulationInfo,omitempty"`
+	FSKModulationInfo  *FSKModulationInfo  `json:"fskModulationInfo,omitempty"`
+}
+
+// UplinkRXInfo contains the reception of an uplink frame by a gateway
+type UplinkRXInfo struct {
+	GatewayID []byte     `json:"gatewayID"`
+	Time      *time.Time `json:"time,omitempty"`
+	RSSI      int32      `json:"rssi"`
+	LoRaSNR   float64    `json:"loRaSNR"`
+	Channel   uint32     `json:"channel"`
+	RFChain   uint32     `json:"rfChain"`
+	Board     uint32     `json:"board"`
+	Antenna   uint32     `json:"antenna"`
+	Location  *Location  `json:"location,omitempty"`
+	Context   []byte     `json:"context,omitempty"` // the concentrator timestamp
+}
+
+// UplinkFrame is the "up" event of the ChirpStack gateway bridge
+type UplinkFrame struct {
+	PHYPayload []byte       `json:"phyPayload"`
+	TXInfo     UplinkTXInfo `json:"txInfo"`
+	RXInfo     UplinkRXInfo `json:"rxInfo"`
+}
+
+// GatewayStats is the "stats" event of the ChirpStack gateway bridge
+type GatewayStats struct {
+	GatewayID           []byte     `json:"gatewayID"`
+	Time                *time.Time `json:"time,omitempty"`
+	Location            *Location  `json:"location,omitempty"`
+	RXPacketsReceived   uint32     `json:"rxPacketsReceived"`
+	RXPacketsReceivedOK uint32     `json:"rxPacketsReceivedOK"`
+	TXPacketsReceived   uint32     `json:"txPacketsReceived"`
+	TXPacketsEmitted    uint32     `json:"txPacketsEmitted"`
+}
+
+// DelayTimingInfo contains the delay of a downlink after its context
+type DelayTimingInfo struct {
+	Delay string `json:"delay"`
+}
+
+// DownlinkTXInfo contains the transmission of a downlink frame
+type DownlinkTXInfo struct {
+	GatewayID          []byte              `json:"gatewayID"`
+	Frequency          uint32              `json:"frequency"`
+	Power              int32               `json:"power"`
+	Modulation         string              `json:"modulation"`
+	LoRaModulationInfo *LoRaModulationInfo `json:"loRaModulationInfo,omitempty"`
+	FSKModulationInfo  *FSKModulationInfo  `json:"fskModulationInfo,omitempty"`
+	Board              uint32              `json:"board"`
+	Antenna            uint32              `json:"antenna"`
+	Timing             string              `json:"timing"`
+	DelayTimingInfo    *DelayTimingInfo    `json:"delayTimingInfo,omitempty"`
+	Context            []byte              `json:"context,omitempty"`
+}
+
+// DownlinkFrameItem is a downlink frame with its transmission
+type DownlinkFrameItem struct {
+	PHYPayload []byte         `json:"phyPayload"`
+	TXInfo     DownlinkTXInfo `json:"txInfo"`
+}
+
+// DownlinkFrame is the "down" command of the ChirpStack gateway bridge. It contains the frame both in the fields of
+// older versions of the bridge and in the items of newer versions.
+type DownlinkFrame struct {
+	PHYPayload []byte              `json:"phyPayload"`
+	TXInfo     DownlinkTXInfo      `json:"txInfo"`
+	Token      uint32              `json:"token"`
+	GatewayID  []byte              `json:"gatewayID"`
+	Items      []DownlinkFrameItem `json:"items"`
+}
+
+// timestamp returns the concentrator timestamp in a context
+func timestamp(context []byte) uint32 {
+	if len(context) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(context)
+}
+
+func locationSource(source string) pb_gateway.LocationMetadata_LocationSource {
+	switch source {
+	case "GPS":
+		return pb_gateway.LocationMetadata_GPS
+	case "CONFIG":
+		return pb_gateway.LocationMetadata_CONFIG
+	}
+	return pb_gateway.LocationMetadata_UNKNOWN
+}
+
+func (l *Location) metadata() *pb_gateway.LocationMetadata {
+	if l == nil || (l.Latitude == 0 && l.Longitude == 0) {
+		return nil
+	}
+	return &pb_gateway.LocationMetadata{
+		Latitude:  float32(l.Latitude),
+		Longitude: float32(l.Longitude),
+		Altitude:  int32(l.Altitude),
+		Source:    locationSource(l.Source),
+	}
+}
+
+// ToUplink converts the uplink frame that the gateway with the EUI received to an uplink message
+func (f UplinkFrame) ToUplink(eui types.EUI64) (*pb.UplinkMessage, error) {
+	if len(f.PHYPayload) == 0 {
+		return nil, errors.NewErrInvalidArgument("Uplink", "does not contain a PHYPayload")
+	}
+
+	lorawan := &pb_lorawan.Metadata{}
+	switch f.TXInfo.Modulation {
+	case LoRa:
+		if f.TXInfo.LoRaModulationInfo == nil {
+			return nil, errors.NewErrInvalidArgument("Uplink", "does not contain LoRa modulation info")
+		}
+		lorawan.Modulation = pb_lorawan.Modulation_LORA
+		lorawan.DataRate = fmt.Sprintf("SF%dBW%d", f.TXInfo.LoRaModulationInfo.SpreadingFactor, f.TXInfo.LoRaModulationInfo.Bandwidth)
+		lorawan.CodingRate = f.TXInfo.LoRaModulationInfo.CodeRate
+	case FSK:
+		if f.TXInfo.FSKModulationInfo == nil {
+			return nil, errors.NewErrInvalidArgument("Uplink", "does not contain FSK modulation info")
+		}
+		lorawan.Modulation = pb_lorawan.Modulation_FSK
+		lorawan.BitRate = f.TXInfo.FSKModulationInfo.DataRate
+	default:
+		return nil, errors.NewErrInvalidArgument("Uplink", fmt.Sprintf("has unknown modulation %s", f.TXInfo.Modulation))
+	}
+
+	gatewayMetadata := pb_gateway.RxMetadata{
+		GatewayID: GatewayID(eui),
+		Timestamp: timestamp(f.RXInfo.Context),
+		RfChain:   f.RXInfo.RFChain,
+		Channel:   f.RXInfo.Channel,
+		Frequency: uint64(f.TXInfo.Frequency),
+		RSSI:      float32(f.RXInfo.RSSI),
+		SNR:       float32(f.RXInfo.LoRaSNR),
+		Location:  f.RXInfo.Location.metadata(),
+	}
+	if f.RXInfo.Time != nil {
+		gatewayMetadata.Time = f.RXInfo.Time.UnixNano()
+	}
+
+	return &pb.UplinkMessage{
+		Payload:          f.PHYPayload,
+		ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: lorawan}},
+		GatewayMetadata:  gatewayMetadata,
+	}, nil
+}
+
+// ToStatus converts the stats of a gateway to a gateway status
+func (s GatewayStats) ToStatus() *pb_gateway.Status {
+	status := &pb_gateway.Status{
+		RxIn:     s.RXPacketsReceived,
+		RxOk:     s.RXPacketsReceivedOK,
+		TxIn:     s.TXPacketsReceived,
+		TxOk:     s.TXPacketsEmitted,
+		Location: s.Location.metadata(),
+		Bridge:   "ChirpStack Gateway Bridge",
+	}
+	if s.Time != nil {
+		status.Time = s.Time.UnixNano()
+	}
+	return status
+}
+
+// FromDownlink converts the downlink message for the gateway with the EUI to a downlink frame. The downlink is
+// scheduled with a delay of zero after a context with its concentrator timestamp.
+func FromDownlink(eui types.EUI64, token uint32, downlink *pb.DownlinkMessage) (*DownlinkFrame, error) {
+	lorawan := downlink.GetProtocolConfiguration().GetLoRaWAN()
+	if lorawan == nil {
+		return nil, errors.NewErrInvalidArgument("Downlink", "does not contain a LoRaWAN configuration")
+	}
+	gateway := downlink.GatewayConfiguration
+
+	context := make([]byte, 4)
+	binary.BigEndian.PutUint32(context, gateway.Timestamp)
+	txInfo := DownlinkTXInfo{
+		GatewayID:       eui.Bytes(),
+		Frequency:       uint32(gateway.Frequency),
+		Power:           gateway.Power,
+		Timing:          "DELAY",
+		DelayTimingInfo: &DelayTimingInfo{Delay: "0s"},
+		Context:         context,
+	}
+
+	switch lorawan.Modulation {
+	case pb_lorawan.Modulation_LORA:
+		dataRate, err := types.ParseDataRate(lorawan.DataRate)
+		if err != nil {
+			return nil, errors.NewErrInvalidArgument("Downlink", fmt.Sprintf("has invalid data rate %s", lorawan.DataRate))
+		}
+		txInfo.Modulation = LoRa
+		txInfo.LoRaModulationInfo = &LoRaModulationInfo{
+			Bandwidth:             uint32(dataRate.Bandwidth),
+			SpreadingFactor:       uint32(dataRate.SpreadingFactor),
+			CodeRate:              lorawan.CodingRate,
+			PolarizationInversion: gateway.PolarizationInversion,
+		}
+	case pb_lorawan.Modulation_FSK:
+		txInfo.Modulation = FSK
+		txInfo.FSKModulationInfo = &FSKModulationInfo{
+			FrequencyDeviation: gateway.FrequencyDeviation,
+			DataRate:           lorawan.BitRate,
+		}
+	default:
+		return nil, errors.NewErrInvalidArgument("Downlink", fmt.Sprintf("has unknown modulation %s", lorawan.Modulation))
+	}
+
+	return &DownlinkFrame{
+		PHYPayload: downlink.Payload,
+		TXInfo:     txInfo,
+		Token:      token,
+		GatewayID:  eui.Bytes(),
+		Items:      []DownlinkFrameItem{{PHYPayload: downlink.Payload, TXInfo: txInfo}},
+	}, nil
+}
+
+// UnmarshalUplinkFrame decodes an "up" event in the format of the gateway bridge
+func UnmarshalUplinkFrame(format Format, data []byte) (frame UplinkFrame, err error) {
+	if format == JSON {
+		err = json.Unmarshal(data, &frame)
+		return
+	}
+	var msg protoUplinkFrame
+	if err = proto.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	return msg.frame(), nil
+}
+
+// UnmarshalGatewayStats decodes a "stats" event in the format of the gateway bridge
+func UnmarshalGatewayStats(format Format, data []byte) (stats GatewayStats, err error) {
+	if format == JSON {
+		err = json.Unmarshal(data, &stats)
+		return
+	}
+	var msg protoGatewayStats
+	if err = proto.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	return msg.stats(), nil
+}
+
+// MarshalDownlinkFrame encodes a "down" command in the format of the gateway bridge
+func MarshalDownlinkFrame(format Format, frame *DownlinkFrame) ([]byte, error) {
+	if format == JSON {
+		return json.Marshal(frame)
+	}
+	msg, err := newProtoDownlinkFrame(frame)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
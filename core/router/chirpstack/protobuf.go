@@ -0,0 +1,306 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chirpstack
+
+import (
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// The messages below are the subset of the gw and common packages of the ChirpStack API that the gateway bridge
+// publishes when it is configured with the protobuf marshaler. Fields that are not used are left out, and the
+// oneofs are flattened, which does not change the encoding.
+
+// The values of the common.Modulation enum
+var protoModulations = map[int32]string{0: LoRa, 1: FSK}
+
+// The values of the common.LocationSource enum
+var protoLocationSources = map[int32]string{0: "UNKNOWN", 1: "GPS", 2: "CONFIG"}
+
+// The values of the gw.DownlinkTiming enum
+var protoTimings = map[string]int32{"IMMEDIATELY": 0, "DELAY": 1, "GPS_EPOCH": 2}
+
+type protoTimestamp struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3" json:"nanos,omitempty"`
+}
+
+func (m *protoTimestamp) Reset()         { *m = protoTimestamp{} }
+func (m *protoTimestamp) String() string { return proto.CompactTextString(m) }
+func (*protoTimestamp) ProtoMessage()    {}
+
+func (m *protoTimestamp) time() *time.Time {
+	if m == nil {
+		return nil
+	}
+	t := time.Unix(m.Seconds, int64(m.Nanos)).UTC()
+	return &t
+}
+
+type protoDuration struct {
+	Seconds int64 `protobuf:"varint,1,opt,name=seconds,proto3" json:"seconds,omitempty"`
+	Nanos   int32 `protobuf:"varint,2,opt,name=nanos,proto3" json:"nanos,omitempty"`
+}
+
+func (m *protoDuration) Reset()         { *m = protoDuration{} }
+func (m *protoDuration) String() string { return proto.CompactTextString(m) }
+func (*protoDuration) ProtoMessage()    {}
+
+func newProtoDuration(d time.Duration) *protoDuration {
+	return &protoDuration{Seconds: int64(d / time.Second), Nanos: int32(d % time.Second)}
+}
+
+type protoLoRaModulationInfo struct {
+	Bandwidth             uint32 `protobuf:"varint,1,opt,name=bandwidth,proto3" json:"bandwidth,omitempty"`
+	SpreadingFactor       uint32 `protobuf:"varint,2,opt,name=spreading_factor,json=spreadingFactor,proto3" json:"spreading_factor,omitempty"`
+	CodeRate              string `protobuf:"bytes,3,opt,name=code_rate,json=codeRate,proto3" json:"code_rate,omitempty"`
+	PolarizationInversion bool   `protobuf:"varint,4,opt,name=polarization_inversion,json=polarizationInversion,proto3" json:"polarization_inversion,omitempty"`
+}
+
+func (m *protoLoRaModulationInfo) Reset()         { *m = protoLoRaModulationInfo{} }
+func (m *protoLoRaModulationInfo) String() string { return proto.CompactTextString(m) }
+func (*protoLoRaModulationInfo) ProtoMessage()    {}
+
+type protoFSKModulationInfo struct {
+	FrequencyDeviation uint32 `protobuf:"varint,1,opt,name=frequency_deviation,json=frequencyDeviation,proto3" json:"frequency_deviation,omitempty"`
+	DataRate           uint32 `protobuf:"varint,2,opt,name=datarate,proto3" json:"datarate,omitempty"`
+}
+
+func (m *protoFSKModulationInfo) Reset()         { *m = protoFSKModulationInfo{} }
+func (m *protoFSKModulationInfo) String() string { return proto.CompactTextString(m) }
+func (*protoFSKModulationInfo) ProtoMessage()    {}
+
+type protoLocation struct {
+	Latitude  float64 `protobuf:"fixed64,1,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude float64 `protobuf:"fixed64,2,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	Altitude  float64 `protobuf:"fixed64,3,opt,name=altitude,proto3" json:"altitude,omitempty"`
+	Source    int32   `protobuf:"varint,4,opt,name=source,proto3" json:"source,omitempty"`
+}
+
+func (m *protoLocation) Reset()         { *m = protoLocation{} }
+func (m *protoLocation) String() string { return proto.CompactTextString(m) }
+func (*protoLocation) ProtoMessage()    {}
+
+func (m *protoLocation) location() *Location {
+	if m == nil {
+		return nil
+	}
+	return &Location{
+		Latitude:  m.Latitude,
+		Longitude: m.Longitude,
+		Altitude:  m.Altitude,
+		Source:    protoLocationSources[m.Source],
+	}
+}
+
+type protoUplinkTXInfo struct {
+	Frequency          uint32                   `protobuf:"varint,1,opt,name=frequency,proto3" json:"frequency,omitempty"`
+	Modulation         int32                    `protobuf:"varint,2,opt,name=modulation,proto3" json:"modulation,omitempty"`
+	LoRaModulationInfo *protoLoRaModulationInfo `protobuf:"bytes,3,opt,name=lora_modulation_info,json=loraModulationInfo" json:"lora_modulation_info,omitempty"`
+	FSKModulationInfo  *protoFSKModulationInfo  `protobuf:"bytes,4,opt,name=fsk_modulation_info,json=fskModulationInfo" json:"fsk_modulation_info,omitempty"`
+}
+
+func (m *protoUplinkTXInfo) Reset()         { *m = protoUplinkTXInfo{} }
+func (m *protoUplinkTXInfo) String() string { return proto.CompactTextString(m) }
+func (*protoUplinkTXInfo) ProtoMessage()    {}
+
+type protoUplinkRXInfo struct {
+	GatewayID []byte          `protobuf:"bytes,1,opt,name=gateway_id,json=gatewayId,proto3" json:"gateway_id,omitempty"`
+	Time      *protoTimestamp `protobuf:"bytes,2,opt,name=time" json:"time,omitempty"`
+	RSSI      int32           `protobuf:"varint,5,opt,name=rssi,proto3" json:"rssi,omitempty"`
+	LoRaSNR   float64         `protobuf:"fixed64,6,opt,name=lora_snr,json=loraSnr,proto3" json:"lora_snr,omitempty"`
+	Channel   uint32          `protobuf:"varint,7,opt,name=channel,proto3" json:"channel,omitempty"`
+	RFChain   uint32          `protobuf:"varint,8,opt,name=rf_chain,json=rfChain,proto3" json:"rf_chain,omitempty"`
+	Board     uint32          `protobuf:"varint,9,opt,name=board,proto3" json:"board,omitempty"`
+	Antenna   uint32          `protobuf:"varint,10,opt,name=antenna,proto3" json:"antenna,omitempty"`
+	Location  *protoLocation  `protobuf:"bytes,11,opt,name=location" json:"location,omitempty"`
+	Context   []byte          `protobuf:"bytes,15,opt,name=context,proto3" json:"context,omitempty"`
+}
+
+func (m *protoUplinkRXInfo) Reset()         { *m = protoUplinkRXInfo{} }
+func (m *protoUplinkRXInfo) String() string { return proto.CompactTextString(m) }
+func (*protoUplinkRXInfo) ProtoMessage()    {}
+
+type protoUplinkFrame struct {
+	PHYPayload []byte             `protobuf:"bytes,1,opt,name=phy_payload,json=phyPayload,proto3" json:"phy_payload,omitempty"`
+	TXInfo     *protoUplinkTXInfo `protobuf:"bytes,2,opt,name=tx_info,json=txInfo" json:"tx_info,omitempty"`
+	RXInfo     *protoUplinkRXInfo `protobuf:"bytes,3,opt,name=rx_info,json=rxInfo" json:"rx_info,omitempty"`
+}
+
+func (m *protoUplinkFrame) Reset()         { *m = protoUplinkFrame{} }
+func (m *protoUplinkFrame) String() string { return proto.CompactTextString(m) }
+func (*protoUplinkFrame) ProtoMessage()    {}
+
+func (m *protoUplinkFrame) frame() UplinkFrame {
+	frame := UplinkFrame{PHYPayload: m.PHYPayload}
+	if tx := m.TXInfo; tx != nil {
+		frame.TXInfo = UplinkTXInfo{
+			Frequency:  tx.Frequency,
+			Modulation: protoModulations[tx.Modulation],
+		}
+		if lora := tx.LoRaModulationInfo; lora != nil {
+			frame.TXInfo.LoRaModulationInfo = &LoRaModulationInfo{
+				Bandwidth:             lora.Bandwidth,
+				SpreadingFactor:       lora.SpreadingFactor,
+				CodeRate:              lora.CodeRate,
+				PolarizationInversion: lora.PolarizationInversion,
+			}
+		}
+		if fsk := tx.FSKModulationInfo; fsk != nil {
+			frame.TXInfo.FSKModulationInfo = &FSKModulationInfo{
+				FrequencyDeviation: fsk.FrequencyDeviation,
+				DataRate:           fsk.DataRate,
+			}
+		}
+	}
+	if rx := m.RXInfo; rx != nil {
+		frame.RXInfo = UplinkRXInfo{
+			GatewayID: rx.GatewayID,
+			Time:      rx.Time.time(),
+			RSSI:      rx.RSSI,
+			LoRaSNR:   rx.LoRaSNR,
+			Channel:   rx.Channel,
+			RFChain:   rx.RFChain,
+			Board:     rx.Board,
+			Antenna:   rx.Antenna,
+			Location:  rx.Location.location(),
+			Context:   rx.Context,
+		}
+	}
+	return frame
+}
+
+type protoGatewayStats struct {
+	GatewayID           []byte          `protobuf:"bytes,1,opt,name=gateway_id,json=gatewayId,proto3" json:"gateway_id,omitempty"`
+	Time                *protoTimestamp `protobuf:"bytes,2,opt,name=time" json:"time,omitempty"`
+	Location            *protoLocation  `protobuf:"bytes,3,opt,name=location" json:"location,omitempty"`
+	RXPacketsReceived   uint32          `protobuf:"varint,5,opt,name=rx_packets_received,json=rxPacketsReceived,proto3" json:"rx_packets_received,omitempty"`
+	RXPacketsReceivedOK uint32          `protobuf:"varint,6,opt,name=rx_packets_received_ok,json=rxPacketsReceivedOk,proto3" json:"rx_packets_received_ok,omitempty"`
+	TXPacketsReceived   uint32          `protobuf:"varint,7,opt,name=tx_packets_received,json=txPacketsReceived,proto3" json:"tx_packets_received,omitempty"`
+	TXPacketsEmitted    uint32          `protobuf:"varint,8,opt,name=tx_packets_emitted,json=txPacketsEmitted,proto3" json:"tx_packets_emitted,omitempty"`
+}
+
+func (m *protoGatewayStats) Reset()         { *m = protoGatewayStats{} }
+func (m *protoGatewayStats) String() string { return proto.CompactTextString(m) }
+func (*protoGatewayStats) ProtoMessage()    {}
+
+func (m *protoGatewayStats) stats() GatewayStats {
+	return GatewayStats{
+		GatewayID:           m.GatewayID,
+		Time:                m.Time.time(),
+		Location:            m.Location.location(),
+		RXPacketsReceived:   m.RXPacketsReceived,
+		RXPacketsReceivedOK: m.RXPacketsReceivedOK,
+		TXPacketsReceived:   m.TXPacketsReceived,
+		TXPacketsEmitted:    m.TXPacketsEmitted,
+	}
+}
+
+type protoDelayTimingInfo struct {
+	Delay *protoDuration `protobuf:"bytes,1,opt,name=delay" json:"delay,omitempty"`
+}
+
+func (m *protoDelayTimingInfo) Reset()         { *m = protoDelayTimingInfo{} }
+func (m *protoDelayTimingInfo) String() string { return proto.CompactTextString(m) }
+func (*protoDelayTimingInfo) ProtoMessage()    {}
+
+type protoDownlinkTXInfo struct {
+	GatewayID          []byte                   `protobuf:"bytes,1,opt,name=gateway_id,json=gatewayId,proto3" json:"gateway_id,omitempty"`
+	Frequency          uint32                   `protobuf:"varint,5,opt,name=frequency,proto3" json:"frequency,omitempty"`
+	Power              int32                    `protobuf:"varint,6,opt,name=power,proto3" json:"power,omitempty"`
+	Modulation         int32                    `protobuf:"varint,7,opt,name=modulation,proto3" json:"modulation,omitempty"`
+	LoRaModulationInfo *protoLoRaModulationInfo `protobuf:"bytes,8,opt,name=lora_modulation_info,json=loraModulationInfo" json:"lora_modulation_info,omitempty"`
+	FSKModulationInfo  *protoFSKModulationInfo  `protobuf:"bytes,9,opt,name=fsk_modulation_info,json=fskModulationInfo" json:"fsk_modulation_info,omitempty"`
+	Board              uint32                   `protobuf:"varint,10,opt,name=board,proto3" json:"board,omitempty"`
+	Antenna            uint32                   `protobuf:"varint,11,opt,name=antenna,proto3" json:"antenna,omitempty"`
+	Timing             int32                    `protobuf:"varint,12,opt,name=timing,proto3" json:"timing,omitempty"`
+	DelayTimingInfo    *protoDelayTimingInfo    `protobuf:"bytes,14,opt,name=delay_timing_info,json=delayTimingInfo" json:"delay_timing_info,omitempty"`
+	Context            []byte                   `protobuf:"bytes,16,opt,name=context,proto3" json:"context,omitempty"`
+}
+
+func (m *protoDownlinkTXInfo) Reset()         { *m = protoDownlinkTXInfo{} }
+func (m *protoDownlinkTXInfo) String() string { return proto.CompactTextString(m) }
+func (*protoDownlinkTXInfo) ProtoMessage()    {}
+
+func newProtoDownlinkTXInfo(tx DownlinkTXInfo) (*protoDownlinkTXInfo, error) {
+	m := &protoDownlinkTXInfo{
+		GatewayID: tx.GatewayID,
+		Frequency: tx.Frequency,
+		Power:     tx.Power,
+		Board:     tx.Board,
+		Antenna:   tx.Antenna,
+		Timing:    protoTimings[tx.Timing],
+		Context:   tx.Context,
+	}
+	for modulation, name := range protoModulations {
+		if name == tx.Modulation {
+			m.Modulation = modulation
+		}
+	}
+	if lora := tx.LoRaModulationInfo; lora != nil {
+		m.LoRaModulationInfo = &protoLoRaModulationInfo{
+			Bandwidth:             lora.Bandwidth,
+			SpreadingFactor:       lora.SpreadingFactor,
+			CodeRate:              lora.CodeRate,
+			PolarizationInversion: lora.PolarizationInversion,
+		}
+	}
+	if fsk := tx.FSKModulationInfo; fsk != nil {
+		m.FSKModulationInfo = &protoFSKModulationInfo{
+			FrequencyDeviation: fsk.FrequencyDeviation,
+			DataRate:           fsk.DataRate,
+		}
+	}
+	if tx.DelayTimingInfo != nil {
+		delay, err := time.ParseDuration(tx.DelayTimingInfo.Delay)
+		if err != nil {
+			return nil, err
+		}
+		m.DelayTimingInfo = &protoDelayTimingInfo{Delay: newProtoDuration(delay)}
+	}
+	return m, nil
+}
+
+type protoDownlinkFrameItem struct {
+	PHYPayload []byte               `protobuf:"bytes,1,opt,name=phy_payload,json=phyPayload,proto3" json:"phy_payload,omitempty"`
+	TXInfo     *protoDownlinkTXInfo `protobuf:"bytes,2,opt,name=tx_info,json=txInfo" json:"tx_info,omitempty"`
+}
+
+func (m *protoDownlinkFrameItem) Reset()         { *m = protoDownlinkFrameItem{} }
+func (m *protoDownlinkFrameItem) String() string { return proto.CompactTextString(m) }
+func (*protoDownlinkFrameItem) ProtoMessage()    {}
+
+type protoDownlinkFrame struct {
+	PHYPayload []byte                    `protobuf:"bytes,1,opt,name=phy_payload,json=phyPayload,proto3" json:"phy_payload,omitempty"`
+	TXInfo     *protoDownlinkTXInfo      `protobuf:"bytes,2,opt,name=tx_info,json=txInfo" json:"tx_info,omitempty"`
+	Token      uint32                    `protobuf:"varint,3,opt,name=token,proto3" json:"token,omitempty"`
+	Items      []*protoDownlinkFrameItem `protobuf:"bytes,5,rep,name=items" json:"items,omitempty"`
+	GatewayID  []byte                    `protobuf:"bytes,6,opt,name=gateway_id,json=gatewayId,proto3" json:"gateway_id,omitempty"`
+}
+
+func (m *protoDownlinkFrame) Reset()         { *m = protoDownlinkFrame{} }
+func (m *protoDownlinkFrame) String() string { return proto.CompactTextString(m) }
+func (*protoDownlinkFrame) ProtoMessage()    {}
+
+func newProtoDownlinkFrame(f *DownlinkFrame) (*protoDownlinkFrame, error) {
+	txInfo, err := newProtoDownlinkTXInfo(f.TXInfo)
+	if err != nil {
+		return nil, err
+	}
+	m := &protoDownlinkFrame{
+		PHYPayload: f.PHYPayload,
+		TXInfo:     txInfo,
+		Token:      f.Token,
+		GatewayID:  f.GatewayID,
+	}
+	for _, item := range f.Items {
+		txInfo, err := newProtoDownlinkTXInfo(item.TXInfo)
+		if err != nil {
+			return nil, err
+		}
+		m.Items = append(m.Items, &protoDownlinkFrameItem{PHYPayload: item.PHYPayload, TXInfo: txInfo})
+	}
+	return m, nil
+}
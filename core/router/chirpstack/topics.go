@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chirpstack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// The events that the ChirpStack gateway bridge publishes
+const (
+	UplinkEvent = "up"
+	StatsEvent  = "stats"
+)
+
+// EventTopic is the topic that subscribes to all events of all gateways
+const EventTopic = "gateway/+/event/+"
+
+// gatewayIDPrefix is the prefix of the IDs of gateways that are identified by their EUI
+const gatewayIDPrefix = "eui-"
+
+// GatewayID returns the ID of the gateway with the EUI
+func GatewayID(eui types.EUI64) string {
+	return gatewayIDPrefix + strings.ToLower(eui.String())
+}
+
+// ParseEventTopic returns the gateway EUI and the event of an event topic
+func ParseEventTopic(topic string) (eui types.EUI64, event string, err error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "gateway" || parts[2] != "event" {
+		return eui, "", errors.NewErrInvalidArgument("Topic", fmt.Sprintf("%s is not a gateway event topic", topic))
+	}
+	eui, err = types.ParseEUI64(parts[1])
+	if err != nil {
+		return eui, "", errors.NewErrInvalidArgument("Topic", fmt.Sprintf("%s does not contain a gateway EUI", topic))
+	}
+	return eui, parts[3], nil
+}
+
+// DownlinkTopic returns the topic of the downlink commands of the gateway with the EUI
+func DownlinkTopic(eui types.EUI64) string {
+	return fmt.Sprintf("gateway/%s/command/down", strings.ToLower(eui.String()))
+}
@@ -91,8 +91,21 @@ func (r *router) HandleDownlink(downlink *pb_broker.DownlinkMessage) (err error)
 		identifier = strings.TrimPrefix(option.Identifier, fmt.Sprintf("%s:", r.Component.Identity.ID))
 	}
 
+	if err = r.checkPrivateGateway(downlink); err != nil {
+		return err
+	}
+
 	gateway = r.getGateway(downlink.DownlinkOption.GatewayID)
-	return gateway.HandleDownlink(identifier, downlinkMessage)
+	return gateway.HandleDownlink(identifier, downlinkMessage, downlink.AppID, r.downlinkPriority(downlink))
+}
+
+// downlinkPriority returns the priority of the downlink, limited by the priority cap of its application
+func (r *router) downlinkPriority(downlink *pb_broker.DownlinkMessage) gateway.Priority {
+	priority := gateway.GetPriority(downlink.Payload)
+	if limit, ok := r.priorityCaps[downlink.AppID]; ok && priority > limit {
+		priority = limit
+	}
+	return priority
 }
 
 // buildDownlinkOption builds a DownlinkOption with default values
@@ -114,20 +127,45 @@ func (r *router) buildDownlinkOption(gatewayID string, band band.FrequencyPlan)
 	}
 }
 
+// gatewayFrequencyPlan returns the frequency plan of the gateway, or the guessed frequency plan of the uplink
+// if the gateway did not send it in its status
+func gatewayFrequencyPlan(gateway *gateway.Gateway, uplink *pb.UplinkMessage) string {
+	gatewayStatus, _ := gateway.Status.Get() // This just returns empty if non-existing
+	if gatewayStatus.FrequencyPlan != "" {
+		return gatewayStatus.FrequencyPlan
+	}
+	return band.Guess(uplink.GatewayMetadata.Frequency)
+}
+
 func (r *router) buildDownlinkOptions(uplink *pb.UplinkMessage, isActivation bool, gateway *gateway.Gateway) (downlinkOptions []*pb_broker.DownlinkOption) {
-	var options []*pb_broker.DownlinkOption
+	options := r.buildDownlinkCandidates(uplink, isActivation, gateway)
 
-	gatewayStatus, _ := gateway.Status.Get() // This just returns empty if non-existing
+	computeDownlinkScores(gateway, uplink, options, true)
+
+	for _, option := range options {
+		// Add router ID to downlink option
+		if r.Component != nil && r.Component.Identity != nil {
+			option.Identifier = fmt.Sprintf("%s:%s", r.Component.Identity.ID, option.Identifier)
+		}
+
+		// Filter all illegal options
+		if option.Score < 1000 {
+			downlinkOptions = append(downlinkOptions, option)
+		}
+	}
+
+	return
+}
 
+// buildDownlinkCandidates builds the downlink options in the receive windows of the uplink, without scoring
+// them. The first option is in RX2, the second in RX1 if the uplink has an RX1 channel and data rate.
+func (r *router) buildDownlinkCandidates(uplink *pb.UplinkMessage, isActivation bool, gateway *gateway.Gateway) (options []*pb_broker.DownlinkOption) {
 	lorawanMetadata := uplink.ProtocolMetadata.GetLoRaWAN()
 	if lorawanMetadata == nil {
 		return // We can't handle any other protocols than LoRaWAN yet
 	}
 
-	frequencyPlan := gatewayStatus.FrequencyPlan
-	if frequencyPlan == "" {
-		frequencyPlan = band.Guess(uplink.GatewayMetadata.Frequency)
-	}
+	frequencyPlan := gatewayFrequencyPlan(gateway, uplink)
 	band, err := band.Get(frequencyPlan)
 	if err != nil {
 		return // We can't handle this frequency plan
@@ -135,6 +173,9 @@ func (r *router) buildDownlinkOptions(uplink *pb.UplinkMessage, isActivation boo
 	if frequencyPlan == "EU_863_870" && isActivation {
 		band.RX2DataRate = 0
 	}
+	if band.LBT != nil && r.lbtGateways != nil && !r.lbtGateways[gateway.ID] {
+		return // The gateway may not transmit without listen-before-talk
+	}
 
 	dataRate, err := lorawanMetadata.GetLoRaWANDataRate()
 	if err != nil {
@@ -153,6 +194,7 @@ func (r *router) buildDownlinkOptions(uplink *pb.UplinkMessage, isActivation boo
 			option.GatewayConfiguration.Timestamp = uplink.GatewayMetadata.Timestamp + uint32(band.ReceiveDelay2/1000)
 		}
 		option.ProtocolConfiguration.GetLoRaWAN().CodingRate = lorawanMetadata.CodingRate
+		limitPower(option, band)
 		return option, nil
 	}
 
@@ -175,6 +217,7 @@ func (r *router) buildDownlinkOptions(uplink *pb.UplinkMessage, isActivation boo
 			return nil, err
 		}
 		option.GatewayConfiguration.Frequency = uint64(freq)
+		limitPower(option, band)
 
 		upDR, err := band.GetDataRate(dataRate)
 		if err != nil {
@@ -197,33 +240,57 @@ func (r *router) buildDownlinkOptions(uplink *pb.UplinkMessage, isActivation boo
 		options = append(options, option)
 	}
 
-	computeDownlinkScores(gateway, uplink, options)
-
-	for _, option := range options {
-		// Add router ID to downlink option
-		if r.Component != nil && r.Component.Identity != nil {
-			option.Identifier = fmt.Sprintf("%s:%s", r.Component.Identity.ID, option.Identifier)
-		}
+	return
+}
 
-		// Filter all illegal options
-		if option.Score < 1000 {
-			downlinkOptions = append(downlinkOptions, option)
-		}
+// limitPower lowers the power of the downlink option to the maximum EIRP of the frequency plan on its frequency
+func limitPower(option *pb_broker.DownlinkOption, band band.FrequencyPlan) {
+	if max := int32(band.GetMaxEIRP(option.GatewayConfiguration.Frequency)); max > 0 && option.GatewayConfiguration.Power > max {
+		option.GatewayConfiguration.Power = max
 	}
+}
 
+// downlinkAirtime returns the time on air of a downlink of the given size (in bytes) with the configuration, or
+// zero if the configuration is not valid
+func downlinkAirtime(lorawan *pb_lorawan.TxConfiguration, size uint) (airtime time.Duration) {
+	switch lorawan.Modulation {
+	case pb_lorawan.Modulation_LORA:
+		airtime, _ = toa.ComputeLoRa(size, lorawan.DataRate, lorawan.CodingRate)
+	case pb_lorawan.Modulation_FSK:
+		airtime, _ = toa.ComputeFSK(size, int(lorawan.BitRate))
+	}
 	return
 }
 
+// euDutyCycle returns the duty-cycle limit of the European sub-band of the frequency, or zero if transmissions
+// on this frequency are forbidden
+func euDutyCycle(freq uint64) float64 {
+	switch {
+	case freq >= 863000000 && freq < 868000000:
+		return 0.01 // g 863.0 – 868.0 MHz 1%
+	case freq >= 868000000 && freq < 868600000:
+		return 0.01 // g1 868.0 – 868.6 MHz 1%
+	case freq >= 868700000 && freq < 869200000:
+		return 0.001 // g2 868.7 – 869.2 MHz 0.1%
+	case freq >= 869400000 && freq < 869650000:
+		return 0.1 // g3 869.4 – 869.65 MHz 10%
+	case freq >= 869700000 && freq < 870000000:
+		return 0.01 // g4 869.7 – 870.0 MHz 1%
+	default:
+		return 0
+	}
+}
+
 // Calculating the score for each downlink option; lower is better, 0 is best
 // If a score is over 1000, it may should not be used as feasible option.
 // TODO: The weights of these parameters should be optimized. I'm sure someone
 // can do some computer simulations to find the right values.
-func computeDownlinkScores(gateway *gateway.Gateway, uplink *pb.UplinkMessage, options []*pb_broker.DownlinkOption) {
-	gatewayStatus, _ := gateway.Status.Get() // This just returns empty if non-existing
-
-	frequencyPlan := gatewayStatus.FrequencyPlan
-	if frequencyPlan == "" {
-		frequencyPlan = band.Guess(uplink.GatewayMetadata.Frequency)
+// If reserve is false, the options are scored without taking an option on the schedule of the gateway.
+func computeDownlinkScores(gateway *gateway.Gateway, uplink *pb.UplinkMessage, options []*pb_broker.DownlinkOption, reserve bool) {
+	frequencyPlan := gatewayFrequencyPlan(gateway, uplink)
+	var lbt *band.LBTConfig
+	if fp, err := band.Get(frequencyPlan); err == nil {
+		lbt = fp.LBT
 	}
 
 	gatewayRx, _ := gateway.Utilization.Get()
@@ -237,24 +304,8 @@ func computeDownlinkScores(gateway *gateway.Gateway, uplink *pb.UplinkMessage, o
 			continue
 		}
 
-		var time time.Duration
-
-		if lorawan.Modulation == pb_lorawan.Modulation_LORA {
-			// Calculate max ToA
-			time, _ = toa.ComputeLoRa(
-				51+13, // Max MACPayload plus LoRaWAN header, TODO: What is the length we should use?
-				lorawan.DataRate,
-				lorawan.CodingRate,
-			)
-		}
-
-		if lorawan.Modulation == pb_lorawan.Modulation_FSK {
-			// Calculate max ToA
-			time, _ = toa.ComputeFSK(
-				51+13, // Max MACPayload plus LoRaWAN header, TODO: What is the length we should use?
-				int(lorawan.BitRate),
-			)
-		}
+		// Calculate max ToA
+		time := downlinkAirtime(lorawan, 51+13) // Max MACPayload plus LoRaWAN header, TODO: What is the length we should use?
 
 		// Invalid if time is zero
 		if time == 0 {
@@ -284,21 +335,15 @@ func computeDownlinkScores(gateway *gateway.Gateway, uplink *pb.UplinkMessage, o
 			channelRx, channelTx := gateway.Utilization.GetChannel(freq)
 			utilizationScore += math.Min((channelTx+channelRx)*200, 20) / 2 // 10% utilization = 10 (max)
 
+			// Listen-before-talk cancels downlinks on busy channels
+			if lbt != nil {
+				utilizationScore += math.Min(channelRx*200, 20) / 2
+			}
+
 			// European Duty Cycle
 			if frequencyPlan == "EU_863_870" {
-				var duty float64
-				switch {
-				case freq >= 863000000 && freq < 868000000:
-					duty = 0.01 // g 863.0 – 868.0 MHz 1%
-				case freq >= 868000000 && freq < 868600000:
-					duty = 0.01 // g1 868.0 – 868.6 MHz 1%
-				case freq >= 868700000 && freq < 869200000:
-					duty = 0.001 // g2 868.7 – 869.2 MHz 0.1%
-				case freq >= 869400000 && freq < 869650000:
-					duty = 0.1 // g3 869.4 – 869.65 MHz 10%
-				case freq >= 869700000 && freq < 870000000:
-					duty = 0.01 // g4 869.7 – 870.0 MHz 1%
-				default:
+				duty := euDutyCycle(freq)
+				if duty == 0 {
 					utilizationScore += 100 // Transmissions on this frequency are forbidden
 				}
 				if channelTx > duty {
@@ -312,8 +357,18 @@ func computeDownlinkScores(gateway *gateway.Gateway, uplink *pb.UplinkMessage, o
 
 		scheduleScore := 0.0 // Between 0 and 30 (lower is better) will be over 100 if forbidden
 		{
-			id, conflicts := gateway.Schedule.GetOption(option.GatewayConfiguration.Timestamp, uint32(time/1000))
-			option.Identifier = id
+			// Listen-before-talk occupies the gateway during the scan before the downlink
+			timestamp, duration := option.GatewayConfiguration.Timestamp, uint32(time/1000)
+			if lbt != nil {
+				scan := uint32(lbt.ScanTime / 1000)
+				timestamp, duration = timestamp-scan, duration+scan
+			}
+			var conflicts uint
+			if reserve {
+				option.Identifier, conflicts = gateway.Schedule.GetOption(timestamp, duration)
+			} else {
+				conflicts = gateway.Schedule.GetConflicts(timestamp, duration)
+			}
 			if conflicts >= 100 {
 				scheduleScore += 100
 			} else {
@@ -15,6 +15,7 @@ import (
 	pb_protocol "github.com/TheThingsNetwork/api/protocol"
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/band"
 	"github.com/TheThingsNetwork/ttn/core/component"
 	"github.com/TheThingsNetwork/ttn/core/router/gateway"
 	. "github.com/TheThingsNetwork/ttn/utils/testing"
@@ -172,6 +173,13 @@ func TestUplinkBuildDownlinkOptions(t *testing.T) {
 	a.So(options[1].GatewayConfiguration.Timestamp, ShouldEqual, 5000100)
 	a.So(options[0].GatewayConfiguration.Timestamp, ShouldEqual, 6000100)
 	a.So(options[0].ProtocolConfiguration.GetLoRaWAN().DataRate, ShouldEqual, "SF12BW125")
+
+	// The timestamps roll over with the 32-bit counter of the gateway
+	gtw, up = newReferenceGateway(t, "EU_863_870"), newReferenceUplink()
+	up.GatewayMetadata.Timestamp = 1<<32 - 500000
+	options = r.buildDownlinkOptions(up, false, gtw)
+	a.So(options[1].GatewayConfiguration.Timestamp, ShouldEqual, 500000)
+	a.So(options[0].GatewayConfiguration.Timestamp, ShouldEqual, 1500000)
 }
 
 func TestUplinkBuildDownlinkOptionsFrequencies(t *testing.T) {
@@ -255,6 +263,43 @@ func TestUplinkBuildDownlinkOptionsFrequencies(t *testing.T) {
 	}
 }
 
+func TestUplinkBuildDownlinkOptionsLBT(t *testing.T) {
+	a := New(t)
+
+	r := &router{}
+
+	gtw, up := newReferenceGateway(t, "KR_920_923"), newReferenceUplink()
+	up.GatewayMetadata.Frequency = 922100000
+	a.So(r.buildDownlinkOptions(up, false, gtw), ShouldNotBeEmpty)
+
+	// Only the LBT gateways get downlinks in frequency plans that require listen-before-talk
+	r.WithLBTGateways("other-gateway")
+	a.So(r.buildDownlinkOptions(up, false, gtw), ShouldBeEmpty)
+	r.WithLBTGateways(gtw.ID)
+	a.So(r.buildDownlinkOptions(up, false, gtw), ShouldNotBeEmpty)
+
+	// Other frequency plans are not affected
+	r.WithLBTGateways("other-gateway")
+	a.So(r.buildDownlinkOptions(newReferenceUplink(), false, newReferenceGateway(t, "EU_863_870")), ShouldHaveLength, 2)
+}
+
+func TestLimitPower(t *testing.T) {
+	a := New(t)
+
+	eu, _ := band.Get("EU_863_870")
+	option := &pb_broker.DownlinkOption{GatewayConfiguration: pb_gateway.TxConfiguration{Frequency: 868100000, Power: 30}}
+	limitPower(option, eu)
+	a.So(option.GatewayConfiguration.Power, ShouldEqual, 16)
+
+	option = &pb_broker.DownlinkOption{GatewayConfiguration: pb_gateway.TxConfiguration{Frequency: 869525000, Power: 30}}
+	limitPower(option, eu)
+	a.So(option.GatewayConfiguration.Power, ShouldEqual, 27)
+
+	option = &pb_broker.DownlinkOption{GatewayConfiguration: pb_gateway.TxConfiguration{Frequency: 868100000, Power: 14}}
+	limitPower(option, eu)
+	a.So(option.GatewayConfiguration.Power, ShouldEqual, 14)
+}
+
 func TestUplinkBuildDownlinkOptionsDataRate(t *testing.T) {
 	a := New(t)
 
@@ -0,0 +1,249 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// DryRunPath is the path on the health port where downlinks can be tried without sending them
+const DryRunPath = "/downlink-dry-run"
+
+// Receive windows of downlink estimates
+const (
+	WindowRX1 = "RX1"
+	WindowRX2 = "RX2"
+)
+
+// Reasons why a downlink can not be sent in a receive window
+const (
+	InfeasibleDataRate    = "data_rate"
+	InfeasiblePayloadSize = "payload_size"
+	InfeasibleFrequency   = "frequency"
+	InfeasibleDutyCycle   = "duty_cycle"
+	InfeasibleConflict    = "conflict"
+	InfeasibleScore       = "score"
+)
+
+// frameOverhead is the size of the MHDR, FHDR, FPort and MIC of a downlink without FOpts
+const frameOverhead = 1 + 7 + 1 + 4
+
+// DownlinkEstimate reports whether and how a downlink could be sent to a device in a receive window of one of its
+// uplinks. The utilization of the gateway is a moving average over the last minute.
+type DownlinkEstimate struct {
+	Window    string  `json:"window"`
+	GatewayID string  `json:"gateway_id"`
+	Timestamp uint32  `json:"timestamp"`
+	Frequency uint64  `json:"frequency"`
+	DataRate  string  `json:"data_rate"`
+	Power     int32   `json:"power"`
+	Airtime   float64 `json:"airtime_ms"`
+
+	// The duty-cycle limit of the sub-band, the utilization of the channel by downlinks of the gateway, and
+	// what is left of the limit. The limit is zero if no duty-cycle applies.
+	DutyCycle         float64 `json:"duty_cycle,omitempty"`
+	ChannelTx         float64 `json:"channel_tx"`
+	DutyCycleHeadroom float64 `json:"duty_cycle_headroom,omitempty"`
+
+	// Downlinks that are already scheduled in this slot. A downlink of a higher priority preempts them.
+	Conflicts uint `json:"conflicts"`
+
+	// The score of the option; the Broker sends the downlink in the feasible option with the lowest score
+	Score     uint32 `json:"score"`
+	Feasible  bool   `json:"feasible"`
+	Reason    string `json:"reason,omitempty"`
+	Preferred bool   `json:"preferred,omitempty"`
+}
+
+func (r *router) DryRunDownlink(uplink *pb.UplinkMessage, isActivation bool, payloadSize int) ([]DownlinkEstimate, error) {
+	gatewayID := uplink.GatewayMetadata.GatewayID
+	r.gatewaysLock.RLock()
+	gateway, ok := r.gateways[gatewayID]
+	r.gatewaysLock.RUnlock()
+	if !ok {
+		return nil, errors.NewErrNotFound(gatewayID)
+	}
+
+	if _, err := uplink.ProtocolMetadata.GetLoRaWAN().GetLoRaWANDataRate(); err != nil {
+		return nil, errors.NewErrInvalidArgument("Data Rate", err.Error())
+	}
+
+	options := r.buildDownlinkCandidates(uplink, isActivation, gateway)
+	if len(options) == 0 {
+		return nil, errors.NewErrInvalidArgument("Uplink", "has no receive windows in the frequency plan of the gateway")
+	}
+	computeDownlinkScores(gateway, uplink, options, false)
+
+	frequencyPlan := gatewayFrequencyPlan(gateway, uplink)
+	fp, _ := band.Get(frequencyPlan)
+
+	estimates := make([]DownlinkEstimate, 0, len(options))
+	preferred := -1
+	for i, option := range options {
+		lorawan := option.ProtocolConfiguration.GetLoRaWAN()
+		estimate := DownlinkEstimate{
+			Window:    WindowRX2,
+			GatewayID: gatewayID,
+			Timestamp: option.GatewayConfiguration.Timestamp,
+			Frequency: option.GatewayConfiguration.Frequency,
+			DataRate:  lorawan.DataRate,
+			Power:     option.GatewayConfiguration.Power,
+			Score:     option.Score,
+		}
+		if i == 1 {
+			estimate.Window = WindowRX1
+		}
+
+		airtime := downlinkAirtime(lorawan, uint(frameOverhead+payloadSize))
+		estimate.Airtime = float64(airtime) / 1e6
+		_, estimate.ChannelTx = gateway.Utilization.GetChannel(estimate.Frequency)
+		scheduled := gateway.Schedule.GetConflicts(estimate.Timestamp, uint32(airtime/1000))
+		estimate.Conflicts = scheduled / 100
+
+		if frequencyPlan == "EU_863_870" {
+			estimate.DutyCycle = euDutyCycle(estimate.Frequency)
+			if estimate.DutyCycle > estimate.ChannelTx {
+				estimate.DutyCycleHeadroom = estimate.DutyCycle - estimate.ChannelTx
+			}
+		}
+
+		maxSize, err := fp.GetMaxPayloadSizeFor(estimate.DataRate)
+		switch {
+		case airtime == 0:
+			estimate.Reason = InfeasibleDataRate
+		case err == nil && payloadSize > maxSize:
+			estimate.Reason = InfeasiblePayloadSize
+		case frequencyPlan == "EU_863_870" && estimate.DutyCycle == 0:
+			estimate.Reason = InfeasibleFrequency
+		case frequencyPlan == "EU_863_870" && estimate.DutyCycleHeadroom == 0:
+			estimate.Reason = InfeasibleDutyCycle
+		case estimate.Conflicts > 0:
+			estimate.Reason = InfeasibleConflict
+		case option.Score >= 1000:
+			estimate.Reason = InfeasibleScore
+		default:
+			estimate.Feasible = true
+			if preferred < 0 || estimate.Score < estimates[preferred].Score {
+				preferred = i
+			}
+		}
+		estimates = append(estimates, estimate)
+	}
+	if preferred >= 0 {
+		estimates[preferred].Preferred = true
+	}
+
+	return estimates, nil
+}
+
+type dryRunHandler struct {
+	router Router
+}
+
+// NewDryRunHandler returns a handler that reports the downlink estimates of the router for the uplink in the
+// query parameters. The gateway_id, frequency, data_rate and timestamp are those of the uplink as the gateway
+// received it; optionally, the coding_rate, rssi and snr can be given. The payload_size is the size of the
+// FRMPayload of the downlink, and join=true gives the estimates for a join-accept. It must be protected with the
+// admin token.
+func NewDryRunHandler(router Router) http.Handler {
+	return &dryRunHandler{router: router}
+}
+
+func (h *dryRunHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	uplink, isActivation, payloadSize, err := parseDryRunQuery(req)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	estimates, err := h.router.DryRunDownlink(uplink, isActivation, payloadSize)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.GetErrType(err) == errors.NotFound {
+			status = http.StatusNotFound
+		}
+		http.Error(res, err.Error(), status)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(estimates)
+}
+
+// parseDryRunQuery parses the uplink, whether it is a join request, and the size of the downlink payload from
+// the query parameters of the request
+func parseDryRunQuery(req *http.Request) (uplink *pb.UplinkMessage, isActivation bool, payloadSize int, err error) {
+	query := req.URL.Query()
+	for _, required := range []string{"gateway_id", "frequency", "data_rate", "timestamp"} {
+		if query.Get(required) == "" {
+			return nil, false, 0, errors.NewErrInvalidArgument(required, "is required")
+		}
+	}
+
+	lorawan := &pb_lorawan.Metadata{
+		Modulation: pb_lorawan.Modulation_LORA,
+		DataRate:   query.Get("data_rate"),
+		CodingRate: "4/5",
+	}
+	if codingRate := query.Get("coding_rate"); codingRate != "" {
+		lorawan.CodingRate = codingRate
+	}
+	uplink = &pb.UplinkMessage{
+		ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: lorawan}},
+		GatewayMetadata: pb_gateway.RxMetadata{
+			GatewayID: query.Get("gateway_id"),
+		},
+	}
+
+	if uplink.GatewayMetadata.Frequency, err = strconv.ParseUint(query.Get("frequency"), 10, 64); err != nil {
+		return nil, false, 0, errors.NewErrInvalidArgument("frequency", "must be in Hz")
+	}
+	timestamp, err := strconv.ParseUint(query.Get("timestamp"), 10, 32)
+	if err != nil {
+		return nil, false, 0, errors.NewErrInvalidArgument("timestamp", "must be the concentrator timestamp of the uplink")
+	}
+	uplink.GatewayMetadata.Timestamp = uint32(timestamp)
+	if rssi := query.Get("rssi"); rssi != "" {
+		value, err := strconv.ParseFloat(rssi, 32)
+		if err != nil {
+			return nil, false, 0, errors.NewErrInvalidArgument("rssi", "must be a number")
+		}
+		uplink.GatewayMetadata.RSSI = float32(value)
+	}
+	if snr := query.Get("snr"); snr != "" {
+		value, err := strconv.ParseFloat(snr, 32)
+		if err != nil {
+			return nil, false, 0, errors.NewErrInvalidArgument("snr", "must be a number")
+		}
+		uplink.GatewayMetadata.SNR = float32(value)
+	}
+
+	if size := query.Get("payload_size"); size != "" {
+		if payloadSize, err = strconv.Atoi(size); err != nil || payloadSize < 0 {
+			return nil, false, 0, errors.NewErrInvalidArgument("payload_size", fmt.Sprintf("%s is not a size in bytes", size))
+		}
+	}
+	if join := query.Get("join"); join != "" {
+		if isActivation, err = strconv.ParseBool(join); err != nil {
+			return nil, false, 0, errors.NewErrInvalidArgument("join", "must be true or false")
+		}
+	}
+
+	return uplink, isActivation, payloadSize, nil
+}
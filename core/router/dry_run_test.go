@@ -0,0 +1,104 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/smartystreets/assertions"
+	"github.com/spf13/viper"
+)
+
+func TestDryRunDownlink(t *testing.T) {
+	a := New(t)
+
+	gtw := newReferenceGateway(t, "EU_863_870")
+	r := &router{gateways: map[string]*gateway.Gateway{gtw.ID: gtw}}
+
+	up := newReferenceUplink()
+	up.GatewayMetadata.GatewayID = "unknown"
+	_, err := r.DryRunDownlink(up, false, 10)
+	a.So(errors.GetErrType(err), ShouldEqual, errors.NotFound)
+
+	estimates, err := r.DryRunDownlink(newReferenceUplink(), false, 10)
+	a.So(err, ShouldBeNil)
+	a.So(estimates, ShouldHaveLength, 2)
+	a.So(estimates[0].Window, ShouldEqual, WindowRX2)
+	a.So(estimates[0].DataRate, ShouldEqual, "SF9BW125")
+	a.So(estimates[0].DutyCycle, ShouldEqual, 0.1)
+	a.So(estimates[0].Feasible, ShouldBeTrue)
+	a.So(estimates[1].Window, ShouldEqual, WindowRX1)
+	a.So(estimates[1].Timestamp, ShouldEqual, 1000100)
+	a.So(estimates[1].DutyCycle, ShouldEqual, 0.01)
+	a.So(estimates[1].Airtime, ShouldBeLessThan, estimates[0].Airtime)
+	a.So(estimates[1].Feasible, ShouldBeTrue)
+	a.So(estimates[1].Preferred, ShouldBeTrue)
+
+	// The dry run does not take options on the schedule
+	a.So(gtw.Schedule.GetConflicts(1000100, 100000), ShouldEqual, 0)
+
+	// The payload does not fit in RX2
+	estimates, _ = r.DryRunDownlink(newReferenceUplink(), false, 150)
+	a.So(estimates[0].Feasible, ShouldBeFalse)
+	a.So(estimates[0].Reason, ShouldEqual, InfeasiblePayloadSize)
+	a.So(estimates[1].Feasible, ShouldBeTrue)
+
+	// Another downlink is already scheduled in RX1
+	id, _ := gtw.Schedule.GetOption(1000100, 50000)
+	gtw.Schedule.Schedule(id, newReferenceDownlink(), "app", gateway.PriorityUnconfirmed)
+	estimates, _ = r.DryRunDownlink(newReferenceUplink(), false, 10)
+	a.So(estimates[1].Conflicts, ShouldEqual, 1)
+	a.So(estimates[1].Reason, ShouldEqual, InfeasibleConflict)
+	a.So(estimates[0].Preferred, ShouldBeTrue)
+}
+
+func TestDryRunHandler(t *testing.T) {
+	a := New(t)
+
+	gtw := newReferenceGateway(t, "EU_863_870")
+	r := &router{gateways: map[string]*gateway.Gateway{gtw.ID: gtw}}
+	handler := NewDryRunHandler(r)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", DryRunPath+"?gateway_id=eui-0102030405060708&frequency=868100000", nil))
+	a.So(res.Code, ShouldEqual, http.StatusBadRequest)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", DryRunPath+"?gateway_id=eui-0807060504030201&frequency=868100000&data_rate=SF7BW125&timestamp=100", nil))
+	a.So(res.Code, ShouldEqual, http.StatusNotFound)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", DryRunPath+"?gateway_id=eui-0102030405060708&frequency=868100000&data_rate=SF7BW125&timestamp=100&payload_size=10&join=true", nil))
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+	var estimates []DownlinkEstimate
+	a.So(json.NewDecoder(res.Body).Decode(&estimates), ShouldBeNil)
+	a.So(estimates, ShouldHaveLength, 2)
+	a.So(estimates[0].DataRate, ShouldEqual, "SF12BW125")
+	a.So(estimates[1].Timestamp, ShouldEqual, 5000100)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", DryRunPath, nil))
+	a.So(res.Code, ShouldEqual, http.StatusMethodNotAllowed)
+
+	// On the health port, the dry run is only served with the admin token
+	query := DryRunPath + "?gateway_id=eui-0102030405060708&frequency=868100000&data_rate=SF7BW125&timestamp=100"
+	protected := component.RequireAdmin(handler, "GET", "HEAD")
+	res = httptest.NewRecorder()
+	protected.ServeHTTP(res, httptest.NewRequest("GET", query, nil))
+	a.So(res.Code, ShouldEqual, http.StatusForbidden)
+
+	viper.Set("admin-token", "secret")
+	defer viper.Set("admin-token", "")
+	req := httptest.NewRequest("GET", query, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res = httptest.NewRecorder()
+	protected.ServeHTTP(res, req)
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+}
@@ -0,0 +1,74 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/ttn/core/band"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// ForwardingFilter limits the traffic that the Router forwards to Brokers. Empty fields do not filter.
+type ForwardingFilter struct {
+	// HomeBrokers are the IDs of the Brokers that traffic is forwarded to
+	HomeBrokers []string
+	// FrequencyPlans are the frequency plans of the gateways whose traffic is forwarded
+	FrequencyPlans []string
+	// NetIDs are the networks whose uplink traffic is forwarded, based on the prefix and NwkID of the DevAddr
+	NetIDs []types.NetID
+}
+
+func (r *router) WithForwardingFilter(filter ForwardingFilter) Router {
+	r.filter = filter
+	return r
+}
+
+// allowsGateway returns true if the traffic of the gateway should be forwarded
+func (f ForwardingFilter) allowsGateway(gtw *gateway.Gateway, frequency uint64) bool {
+	if len(f.FrequencyPlans) == 0 {
+		return true
+	}
+	status, _ := gtw.Status.Get()
+	frequencyPlan := status.FrequencyPlan
+	if frequencyPlan == "" {
+		frequencyPlan = band.Guess(frequency)
+	}
+	for _, allowed := range f.FrequencyPlans {
+		if frequencyPlan == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsDevAddr returns true if uplink traffic for the DevAddr should be forwarded
+func (f ForwardingFilter) allowsDevAddr(devAddr types.DevAddr) bool {
+	if len(f.NetIDs) == 0 {
+		return true
+	}
+	for _, netID := range f.NetIDs {
+		if devAddr.HasNetID(netID) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterBrokers returns the Brokers that traffic should be forwarded to
+func (f ForwardingFilter) filterBrokers(brokers []*pb_discovery.Announcement) []*pb_discovery.Announcement {
+	if len(f.HomeBrokers) == 0 {
+		return brokers
+	}
+	filtered := make([]*pb_discovery.Announcement, 0, len(brokers))
+	for _, broker := range brokers {
+		for _, id := range f.HomeBrokers {
+			if broker.ID == id {
+				filtered = append(filtered, broker)
+				break
+			}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/api/trace"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestForwardingFilter(t *testing.T) {
+	a := New(t)
+
+	var empty ForwardingFilter
+	a.So(empty.allowsGateway(newReferenceGateway(t, "US_902_928"), 902300000), ShouldBeTrue)
+	a.So(empty.allowsDevAddr(types.DevAddr{0x26, 1, 2, 3}), ShouldBeTrue)
+	a.So(empty.filterBrokers([]*discovery.Announcement{{ID: "a"}, {ID: "b"}}), ShouldHaveLength, 2)
+
+	filter := ForwardingFilter{
+		HomeBrokers:    []string{"b"},
+		FrequencyPlans: []string{"EU_863_870"},
+		NetIDs:         []types.NetID{{0, 0, 0x13}, {0x60, 0x01, 0x23}},
+	}
+
+	a.So(filter.allowsGateway(newReferenceGateway(t, "EU_863_870"), 868100000), ShouldBeTrue)
+	a.So(filter.allowsGateway(newReferenceGateway(t, "US_902_928"), 902300000), ShouldBeFalse)
+	a.So(filter.allowsGateway(newReferenceGateway(t, ""), 868100000), ShouldBeTrue) // guessed
+
+	a.So(filter.allowsDevAddr(types.DevAddr{0x26, 1, 2, 3}), ShouldBeTrue)
+	a.So(filter.allowsDevAddr(types.DevAddr{0x27, 1, 2, 3}), ShouldBeTrue)
+	a.So(filter.allowsDevAddr(types.DevAddr{0x01, 2, 3, 4}), ShouldBeFalse)
+	a.So(filter.allowsDevAddr(types.DevAddr{0xE2, 0x46, 3, 4}), ShouldBeTrue)  // type 3, NwkID 123
+	a.So(filter.allowsDevAddr(types.DevAddr{0xE2, 0x48, 3, 4}), ShouldBeFalse) // type 3, NwkID 124
+	a.So(filter.allowsDevAddr(types.DevAddr{0xA6, 1, 2, 3}), ShouldBeFalse)    // type 1, NwkID 26
+
+	brokers := filter.filterBrokers([]*discovery.Announcement{{ID: "a"}, {ID: "b"}})
+	a.So(brokers, ShouldHaveLength, 1)
+	a.So(brokers[0].ID, ShouldEqual, "b")
+}
+
+func TestHandleUplinkWithForwardingFilter(t *testing.T) {
+	a := New(t)
+
+	r := getTestRouter(t)
+	r.WithForwardingFilter(ForwardingFilter{NetIDs: []types.NetID{{0, 0, 0x13}}})
+
+	// The reference uplink has DevAddr 01020304, which is not in NetID 000013, so discovery is not called
+	uplink := newReferenceUplink()
+	err := r.HandleUplink("eui-0102030405060708", uplink)
+	a.So(err, ShouldBeNil)
+	a.So(uplink.Trace, ShouldNotBeNil)
+	a.So(uplink.Trace.Event, ShouldEqual, trace.DropEvent)
+	a.So(uplink.Trace.Metadata["code"], ShouldEqual, RejectForwardingFilter)
+}
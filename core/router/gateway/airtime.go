@@ -0,0 +1,112 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb_router "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/utils/toa"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rcrowley/go-metrics"
+)
+
+var airtimeCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "downlink_airtime_seconds_total",
+		Help:      "Total downlink airtime on gateways per application.",
+	}, []string{"app_id"},
+)
+
+func init() {
+	prometheus.MustRegister(airtimeCounter)
+}
+
+// Airtime keeps track of the downlink airtime that applications use on a gateway, so that it can be shared
+// fairly when downlinks of different applications compete for the same slot
+// It is based on an exponentially weighted moving average over fifteen minutes
+type Airtime struct {
+	mu      sync.RWMutex
+	apps    map[string]metrics.EWMA
+	weights map[string]float64
+}
+
+// NewAirtime creates a new Airtime
+func NewAirtime() *Airtime {
+	return &Airtime{
+		apps: make(map[string]metrics.EWMA),
+	}
+}
+
+// SetWeights sets the weights of applications. An application with weight 2 gets twice the airtime of an
+// application with weight 1, which is the default
+func (a *Airtime) SetWeights(weights map[string]float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.weights = weights
+}
+
+// AddTx updates the airtime of the application for transmitting a downlink message
+func (a *Airtime) AddTx(appID string, downlink *pb_router.DownlinkMessage) error {
+	t, err := downlinkAirtime(downlink)
+	if err != nil || t == 0 {
+		return err
+	}
+	airtimeCounter.WithLabelValues(appID).Add(t.Seconds())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.apps[appID]; !ok {
+		a.apps[appID] = metrics.NewEWMA15()
+	}
+	a.apps[appID].Update(int64(t) / 1000)
+	return nil
+}
+
+// Get returns the downlink airtime utilization of the application. The value will be 0 <= value < 1
+func (a *Airtime) Get(appID string) float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if app, ok := a.apps[appID]; ok {
+		return app.Snapshot().Rate() * 1000.0 / float64(time.Second)
+	}
+	return 0
+}
+
+// Share returns the airtime utilization of the application divided by its weight. When downlinks of the same
+// priority compete for a slot, the application with the lowest share gets the slot
+func (a *Airtime) Share(appID string) float64 {
+	weight := 1.0
+	a.mu.RLock()
+	if w, ok := a.weights[appID]; ok && w > 0 {
+		weight = w
+	}
+	a.mu.RUnlock()
+	return a.Get(appID) / weight
+}
+
+// Tick the clock to update the moving average. It should be called every 5 seconds
+func (a *Airtime) Tick() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, app := range a.apps {
+		app.Tick()
+	}
+}
+
+// downlinkAirtime returns the time on air of a downlink message
+func downlinkAirtime(downlink *pb_router.DownlinkMessage) (t time.Duration, err error) {
+	if lorawan := downlink.ProtocolConfiguration.GetLoRaWAN(); lorawan != nil {
+		if lorawan.Modulation == pb_lorawan.Modulation_LORA {
+			return toa.ComputeLoRa(uint(len(downlink.Payload)), lorawan.DataRate, lorawan.CodingRate)
+		}
+		if lorawan.Modulation == pb_lorawan.Modulation_FSK {
+			return toa.ComputeFSK(uint(len(downlink.Payload)), int(lorawan.BitRate))
+		}
+	}
+	return 0, nil
+}
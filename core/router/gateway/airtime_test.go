@@ -0,0 +1,64 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+
+	router_pb "github.com/TheThingsNetwork/api/router"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestAirtime(t *testing.T) {
+	a := New(t)
+	at := NewAirtime()
+	err := at.AddTx("app1", buildDownlink(8680000000))
+	a.So(err, ShouldBeNil)
+	err = at.AddTx("app2", buildDownlink(8680000000))
+	a.So(err, ShouldBeNil)
+	at.AddTx("app2", buildDownlink(8682000000))
+
+	a.So(at.Get("app1"), ShouldAlmostEqual, 0)
+
+	at.Tick() // 5 seconds later
+
+	a.So(at.Get("app1"), ShouldAlmostEqual, 0.041216/5.0) // 41 ms per second
+	a.So(at.Get("app2"), ShouldAlmostEqual, 0.082432/5.0) // two times 41 ms per second
+	a.So(at.Get("app3"), ShouldEqual, 0)
+	a.So(at.Share("app2"), ShouldBeGreaterThan, at.Share("app1"))
+
+	at.SetWeights(map[string]float64{"app2": 4})
+	a.So(at.Share("app2"), ShouldBeLessThan, at.Share("app1"))
+}
+
+func TestScheduleFairness(t *testing.T) {
+	a := New(t)
+	gtw := NewGateway(GetLogger(t, "TestScheduleFairness"), "test")
+	s := gtw.Schedule.(*schedule)
+	s.Sync(0)
+
+	gtw.Airtime.AddTx("app1", buildDownlink(8680000000))
+	gtw.Airtime.Tick()
+
+	first, _ := s.GetOption(5000000, 100)
+	err := s.Schedule(first, &router_pb.DownlinkMessage{}, "app1", PriorityUnconfirmed)
+	a.So(err, ShouldBeNil)
+
+	// The application that used less airtime gets the slot
+	second, _ := s.GetOption(5000050, 100)
+	err = s.Schedule(second, &router_pb.DownlinkMessage{}, "app2", PriorityUnconfirmed)
+	a.So(err, ShouldBeNil)
+	a.So(s.items[first].payload, ShouldBeNil)
+
+	// The application that used more airtime does not
+	third, _ := s.GetOption(5000050, 100)
+	err = s.Schedule(third, &router_pb.DownlinkMessage{}, "app1", PriorityUnconfirmed)
+	a.So(err, ShouldNotBeNil)
+
+	// Unless its downlink has a higher priority
+	err = s.Schedule(third, &router_pb.DownlinkMessage{}, "app1", PriorityConfirmed)
+	a.So(err, ShouldBeNil)
+	a.So(s.items[second].payload, ShouldBeNil)
+}
@@ -0,0 +1,167 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// ClockSamples is the number of synchronizations that is used to estimate the offset and drift of a gateway clock
+var ClockSamples = 32
+
+// MinDriftSpan is the time that the samples need to span before the drift of a gateway clock is estimated
+var MinDriftSpan = 5 * time.Minute
+
+// MaxClockJump is the maximum difference between a timestamp and the timestamp that is expected from the
+// time since the previous synchronization. If the difference is larger, the gateway has restarted and the
+// model of its clock is reset.
+var MaxClockJump = 10 * time.Second
+
+type clockSample struct {
+	timestamp int64 // concentrator time in microseconds, including rollovers
+	server    int64 // server time in nanoseconds
+}
+
+// Clock models the concentrator clock of a gateway, which is a 32-bit microsecond counter (tmst) that rolls
+// over every ~71 minutes. It counts the rollovers and estimates the offset and the drift of the gateway
+// clock relative to the server time from the uplinks of the gateway.
+//
+// The server time of an uplink includes the latency of the backhaul, which varies. The model is therefore
+// fitted to the samples with the lowest latency. The zero value is a clock that is not synchronized.
+type Clock struct {
+	mu        sync.RWMutex
+	samples   []clockSample // ring buffer of ClockSamples samples
+	next      int
+	last      clockSample
+	synced    bool
+	rollovers uint
+	resets    uint
+
+	// The model: server = base.server + (timestamp-base.timestamp)*1000 + offset + drift*(timestamp-base.timestamp)
+	base   clockSample
+	offset float64 // nanoseconds
+	drift  float64 // nanoseconds per microsecond
+}
+
+// NewClock returns a new Clock
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Sync adds a sample of a timestamp (in microseconds) that was received at the server time
+func (c *Clock) Sync(timestamp uint32, serverTime time.Time) {
+	server := serverTime.UnixNano()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.synced {
+		c.reset(clockSample{int64(timestamp), server})
+		return
+	}
+
+	// The timestamp that is closest to the time since the previous sample, which accounts for rollovers
+	expected := c.last.timestamp + (server-c.last.server)/1000
+	unwrapped := expected + int64(int32(timestamp-uint32(expected)))
+	if jump := unwrapped - expected; jump > int64(MaxClockJump/time.Microsecond) || jump < -int64(MaxClockJump/time.Microsecond) {
+		c.resets++
+		c.reset(clockSample{int64(timestamp), server})
+		return
+	}
+	if unwrapped < c.last.timestamp {
+		return // Uplinks can be handled out of order
+	}
+	c.rollovers += uint(unwrapped>>32 - c.last.timestamp>>32)
+
+	c.last = clockSample{unwrapped, server}
+	if len(c.samples) < ClockSamples {
+		c.samples = append(c.samples, c.last)
+	} else {
+		c.samples[c.next] = c.last
+		c.next = (c.next + 1) % len(c.samples)
+	}
+	c.fit()
+}
+
+func (c *Clock) reset(sample clockSample) {
+	c.samples = append(c.samples[:0], sample)
+	c.next = 0
+	c.last = sample
+	c.synced = true
+	c.base = sample
+	c.offset, c.drift = 0, 0
+}
+
+// fit the model to the samples. The mutex must be held.
+func (c *Clock) fit() {
+	n := len(c.samples)
+	c.base = c.samples[c.next%n] // the oldest sample
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for k := 0; k < n; k++ {
+		sample := c.samples[(c.next+k)%n]
+		x[k] = float64(sample.timestamp - c.base.timestamp)
+		y[k] = float64(sample.server-c.base.server) - x[k]*1000
+	}
+
+	// The drift is the slope between the samples with the lowest latency in the oldest and the newest half
+	c.drift = 0
+	if span := c.last.timestamp - c.base.timestamp; span >= int64(MinDriftSpan/time.Microsecond) {
+		oldest, newest := 0, n/2
+		for k := 1; k < n; k++ {
+			if k < n/2 && y[k] < y[oldest] {
+				oldest = k
+			}
+			if k > n/2 && y[k] < y[newest] {
+				newest = k
+			}
+		}
+		if x[newest] > x[oldest] {
+			c.drift = (y[newest] - y[oldest]) / (x[newest] - x[oldest])
+		}
+	}
+
+	c.offset = math.Inf(1)
+	for k := range x {
+		c.offset = math.Min(c.offset, y[k]-c.drift*x[k])
+	}
+}
+
+// ServerTime returns the server time of a timestamp (in microseconds). The timestamp is taken to be within
+// half a rollover (~35 minutes) of the last synchronization, so that timestamps just after a rollover are
+// in the near future, and timestamps just before the last synchronization are in the near past. If the
+// clock is not synchronized, the timestamp is returned as time since the Unix epoch.
+func (c *Clock) ServerTime(timestamp uint32) time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.synced {
+		return time.Unix(0, int64(timestamp)*1000)
+	}
+	unwrapped := c.last.timestamp + int64(int32(timestamp-uint32(c.last.timestamp)))
+	x := float64(unwrapped - c.base.timestamp)
+	return time.Unix(0, c.base.server+int64(x*1000+c.offset+c.drift*x))
+}
+
+// Drift returns the estimated drift of the gateway clock relative to the server time, in parts per million.
+// A positive drift means that the gateway clock is slower than the server clock.
+func (c *Clock) Drift() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.drift * 1000
+}
+
+// Rollovers returns the number of rollovers of the timestamp counter since the first synchronization
+func (c *Clock) Rollovers() uint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.rollovers
+}
+
+// Resets returns the number of times that the model was reset because the gateway restarted
+func (c *Clock) Resets() uint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resets
+}
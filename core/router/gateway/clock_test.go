@@ -0,0 +1,99 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestClock(t *testing.T) {
+	a := New(t)
+
+	start := time.Unix(1500000000, 0)
+	at := func(timestamp int64, latency time.Duration) time.Time {
+		return start.Add(time.Duration(timestamp)*time.Microsecond + latency)
+	}
+
+	// Not synchronized
+	c := NewClock()
+	a.So(c.ServerTime(10).UnixNano(), ShouldEqual, 10000)
+
+	// The offset is the sample with the lowest latency
+	for i, latency := range []time.Duration{80, 20, 100, 50} {
+		timestamp := int64(i) * 1000000
+		c.Sync(uint32(timestamp), at(timestamp, latency*time.Millisecond))
+	}
+	a.So(c.ServerTime(5000000), ShouldResemble, at(5000000, 20*time.Millisecond))
+	a.So(c.Drift(), ShouldEqual, 0)
+
+	// Timestamps before the last synchronization are in the past
+	a.So(c.ServerTime(0), ShouldResemble, at(0, 20*time.Millisecond))
+}
+
+func TestClockDrift(t *testing.T) {
+	a := New(t)
+
+	start := time.Unix(1500000000, 0)
+	c := NewClock()
+
+	// The gateway clock is 40 ppm slower than the server clock
+	var timestamp int64
+	for i := 0; i < 40; i++ {
+		timestamp = int64(i) * 20000000
+		latency := time.Duration(i%4) * 10 * time.Millisecond
+		c.Sync(uint32(timestamp), start.Add(time.Duration(float64(timestamp)*1.00004)*time.Microsecond+latency))
+	}
+	a.So(c.Drift(), ShouldAlmostEqual, 40, 1)
+
+	// Without compensation, the prediction would be about 30ms off
+	timestamp += 20000000
+	predicted := c.ServerTime(uint32(timestamp))
+	actual := start.Add(time.Duration(float64(timestamp) * 1.00004 * float64(time.Microsecond)))
+	a.So(predicted.Sub(actual), ShouldBeBetween, -2*time.Millisecond, 2*time.Millisecond)
+}
+
+func TestClockRollover(t *testing.T) {
+	a := New(t)
+
+	start := time.Unix(1500000000, 0)
+	c := NewClock()
+
+	for timestamp := int64(1<<32 - 3000000); timestamp < 1<<32+3000000; timestamp += 1000000 {
+		c.Sync(uint32(timestamp), start.Add(time.Duration(timestamp)*time.Microsecond))
+	}
+	a.So(c.Rollovers(), ShouldEqual, 1)
+	a.So(c.Resets(), ShouldEqual, 0)
+	a.So(c.ServerTime(4000000), ShouldResemble, start.Add(time.Duration(1<<32+4000000)*time.Microsecond))
+
+	// Timestamps just before the rollover are not 71 minutes in the future
+	a.So(c.ServerTime(1<<32-1000000), ShouldResemble, start.Add(time.Duration(1<<32-1000000)*time.Microsecond))
+
+	// Rollovers are counted when the gateway was silent for a while
+	timestamp := int64(1<<32+3000000) + int64(2*time.Hour/time.Microsecond)
+	c.Sync(uint32(timestamp), start.Add(time.Duration(timestamp)*time.Microsecond))
+	a.So(c.Rollovers(), ShouldEqual, 2)
+	a.So(c.ServerTime(uint32(timestamp+1000000)), ShouldResemble, start.Add(time.Duration(timestamp+1000000)*time.Microsecond))
+}
+
+func TestClockReset(t *testing.T) {
+	a := New(t)
+
+	start := time.Unix(1500000000, 0)
+	c := NewClock()
+	c.Sync(600000000, start)
+	c.Sync(610000000, start.Add(10*time.Second))
+
+	// Uplinks that are handled out of order are ignored
+	c.Sync(605000000, start.Add(11*time.Second))
+	a.So(c.ServerTime(620000000), ShouldResemble, start.Add(20*time.Second))
+
+	// The gateway restarted, and its counter started at zero again
+	c.Sync(1000000, start.Add(60*time.Second))
+	a.So(c.Resets(), ShouldEqual, 1)
+	a.So(c.Rollovers(), ShouldEqual, 0)
+	a.So(c.ServerTime(2000000), ShouldResemble, start.Add(61*time.Second))
+}
@@ -22,6 +22,9 @@ func NewGateway(ctx ttnlog.Interface, id string) *Gateway {
 		ID:          id,
 		Status:      NewStatusStore(),
 		Utilization: NewUtilization(),
+		Airtime:     NewAirtime(),
+		NoiseFloor:  NewNoiseFloor(),
+		Logs:        NewLogs(),
 		Schedule:    NewSchedule(ctx),
 		Ctx:         ctx,
 	}
@@ -34,6 +37,9 @@ type Gateway struct {
 	ID          string
 	Status      StatusStore
 	Utilization Utilization
+	Airtime     *Airtime
+	NoiseFloor  *NoiseFloor
+	Logs        *Logs
 	Schedule    Schedule
 	LastSeen    time.Time
 
@@ -41,6 +47,9 @@ type Gateway struct {
 	token         string
 	authenticated bool
 
+	softwareMu sync.RWMutex
+	software   Software
+
 	MonitorStream monitorclient.Stream
 
 	Ctx ttnlog.Interface
@@ -75,6 +84,7 @@ func (g *Gateway) HandleStatus(status *pb.Status) (err error) {
 	if err = g.Status.Update(status); err != nil {
 		return err
 	}
+	g.updateSoftware(status)
 	g.updateLastSeen()
 	return nil
 }
@@ -109,9 +119,9 @@ func (g *Gateway) HandleUplink(uplink *pb_router.UplinkMessage) (err error) {
 	return nil
 }
 
-func (g *Gateway) HandleDownlink(identifier string, downlink *pb_router.DownlinkMessage) (err error) {
+func (g *Gateway) HandleDownlink(identifier string, downlink *pb_router.DownlinkMessage, appID string, priority Priority) (err error) {
 	ctx := g.Ctx.WithField("Identifier", identifier).WithFields(logfields.ForMessage(downlink))
-	if err = g.Schedule.Schedule(identifier, downlink); err != nil {
+	if err = g.Schedule.Schedule(identifier, downlink, appID, priority); err != nil {
 		ctx.WithError(err).Warn("Could not schedule downlink")
 		return err
 	}
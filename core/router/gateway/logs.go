@@ -0,0 +1,108 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/random"
+)
+
+// LogRetention limits the diagnostic uploads that are kept per gateway. The oldest uploads are removed first.
+type LogRetention struct {
+	MaxUploads   int           // number of uploads
+	MaxSize      int           // total size of the uploads in bytes
+	MaxAge       time.Duration // zero keeps uploads until they exceed the other limits
+	MaxTotalSize int           // total size of the uploads of all gateways in bytes, new uploads are refused above it
+}
+
+// LogUpload is a diagnostic bundle or log excerpt that a gateway uploaded
+type LogUpload struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int       `json:"size"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+	Data        []byte    `json:"-"`
+}
+
+// Logs contains the diagnostic uploads of a gateway
+type Logs struct {
+	mu      sync.RWMutex
+	uploads []*LogUpload // oldest first
+}
+
+// NewLogs creates a new in-memory store for diagnostic uploads
+func NewLogs() *Logs {
+	return &Logs{}
+}
+
+// Add stores the upload and removes older uploads that exceed the retention limits
+func (l *Logs) Add(upload *LogUpload, retention LogRetention) {
+	upload.ID = random.String(16)
+	upload.Size = len(upload.Data)
+	if upload.UploadedAt.IsZero() {
+		upload.UploadedAt = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.uploads = append(l.uploads, upload)
+	l.expire(retention, upload.UploadedAt)
+}
+
+func (l *Logs) expire(retention LogRetention, now time.Time) {
+	var size int
+	for _, upload := range l.uploads {
+		size += upload.Size
+	}
+	for len(l.uploads) > 0 {
+		oldest := l.uploads[0]
+		switch {
+		case retention.MaxUploads > 0 && len(l.uploads) > retention.MaxUploads:
+		case retention.MaxSize > 0 && size > retention.MaxSize:
+		case retention.MaxAge > 0 && now.Sub(oldest.UploadedAt) > retention.MaxAge:
+		default:
+			return
+		}
+		size -= oldest.Size
+		l.uploads = l.uploads[1:]
+	}
+}
+
+// List returns the uploads that are within the retention limits, oldest first
+func (l *Logs) List(retention LogRetention) []LogUpload {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire(retention, time.Now())
+	uploads := make([]LogUpload, 0, len(l.uploads))
+	for _, upload := range l.uploads {
+		uploads = append(uploads, *upload)
+	}
+	return uploads
+}
+
+// Size returns the total size of the uploads that are within the retention limits
+func (l *Logs) Size(retention LogRetention) (size int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expire(retention, time.Now())
+	for _, upload := range l.uploads {
+		size += upload.Size
+	}
+	return size
+}
+
+// Get returns the upload with the ID
+func (l *Logs) Get(id string) (LogUpload, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, upload := range l.uploads {
+		if upload.ID == id {
+			return *upload, true
+		}
+	}
+	return LogUpload{}, false
+}
@@ -0,0 +1,52 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestLogs(t *testing.T) {
+	a := New(t)
+	logs := NewLogs()
+
+	retention := LogRetention{MaxUploads: 2, MaxSize: 10}
+
+	first := &LogUpload{Name: "first", Data: []byte{1, 2, 3}}
+	logs.Add(first, retention)
+	a.So(first.ID, ShouldNotBeEmpty)
+	a.So(first.Size, ShouldEqual, 3)
+	a.So(first.UploadedAt.IsZero(), ShouldBeFalse)
+
+	upload, ok := logs.Get(first.ID)
+	a.So(ok, ShouldBeTrue)
+	a.So(upload.Data, ShouldResemble, []byte{1, 2, 3})
+	_, ok = logs.Get("unknown")
+	a.So(ok, ShouldBeFalse)
+
+	// Too many uploads
+	logs.Add(&LogUpload{Name: "second", Data: []byte{1, 2, 3}}, retention)
+	logs.Add(&LogUpload{Name: "third", Data: []byte{1, 2, 3}}, retention)
+	uploads := logs.List(retention)
+	a.So(uploads, ShouldHaveLength, 2)
+	a.So(uploads[0].Name, ShouldEqual, "second")
+	a.So(uploads[1].Name, ShouldEqual, "third")
+
+	// Too large
+	logs.Add(&LogUpload{Name: "fourth", Data: make([]byte, 8)}, retention)
+	uploads = logs.List(retention)
+	a.So(uploads, ShouldHaveLength, 1)
+	a.So(uploads[0].Name, ShouldEqual, "fourth")
+
+	// Too old
+	retention.MaxAge = time.Hour
+	logs.Add(&LogUpload{Name: "old", UploadedAt: time.Now().Add(-2 * time.Hour)}, LogRetention{})
+	a.So(logs.List(LogRetention{}), ShouldHaveLength, 2)
+	uploads = logs.List(retention)
+	a.So(uploads, ShouldHaveLength, 1)
+	a.So(uploads[0].Name, ShouldEqual, "fourth")
+}
@@ -0,0 +1,45 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import "sync"
+
+// MinNoiseFloorSamples is the number of uplinks that is needed before the noise floor of a gateway is known
+var MinNoiseFloorSamples uint = 20
+
+// noiseFloorWeight is the weight of a new uplink in the moving average of the noise floor
+const noiseFloorWeight = 0.05
+
+// NoiseFloor estimates the noise floor of a gateway from the RSSI and SNR of the uplinks it receives
+// It is a cumulative average over the first uplinks, and an exponentially weighted moving average after that
+type NoiseFloor struct {
+	mu      sync.RWMutex
+	value   float64
+	samples uint
+}
+
+// NewNoiseFloor creates a new NoiseFloor
+func NewNoiseFloor() *NoiseFloor {
+	return &NoiseFloor{}
+}
+
+// Update the noise floor with the RSSI and SNR (both in dB) of an uplink
+func (n *NoiseFloor) Update(rssi, snr float32) {
+	noise := float64(rssi) - float64(snr)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.samples++
+	if n.samples <= MinNoiseFloorSamples {
+		n.value += (noise - n.value) / float64(n.samples)
+		return
+	}
+	n.value += (noise - n.value) * noiseFloorWeight
+}
+
+// Get returns the noise floor (in dBm) and whether enough uplinks were received to estimate it
+func (n *NoiseFloor) Get() (noiseFloor float64, ok bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.value, n.samples >= MinNoiseFloorSamples
+}
@@ -0,0 +1,30 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestNoiseFloor(t *testing.T) {
+	a := New(t)
+	n := NewNoiseFloor()
+
+	_, ok := n.Get()
+	a.So(ok, ShouldBeFalse)
+
+	for i := uint(0); i < MinNoiseFloorSamples; i++ {
+		n.Update(-100, 10)
+	}
+	noiseFloor, ok := n.Get()
+	a.So(ok, ShouldBeTrue)
+	a.So(noiseFloor, ShouldAlmostEqual, -110)
+
+	// One outlier does not move the noise floor much
+	n.Update(-60, 10)
+	noiseFloor, _ = n.Get()
+	a.So(noiseFloor, ShouldAlmostEqual, -108)
+}
@@ -0,0 +1,70 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Priority of a downlink in the schedule of a gateway. If downlinks overlap, only the downlink with the highest priority is sent.
+type Priority uint8
+
+// Priorities
+const (
+	PriorityUnconfirmed Priority = iota // Unconfirmed downlinks without MAC commands
+	PriorityConfirmed                   // Confirmed downlinks without MAC commands
+	PriorityMAC                         // Downlinks with MAC commands and join accepts
+)
+
+var priorityNames = map[Priority]string{
+	PriorityUnconfirmed: "unconfirmed",
+	PriorityConfirmed:   "confirmed",
+	PriorityMAC:         "mac",
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Priority(%d)", p)
+}
+
+// ParsePriority parses the name of a priority
+func ParsePriority(name string) (Priority, error) {
+	for priority, priorityName := range priorityNames {
+		if strings.EqualFold(name, priorityName) {
+			return priority, nil
+		}
+	}
+	return 0, errors.NewErrInvalidArgument("Priority", fmt.Sprintf("unknown priority %s", name))
+}
+
+// GetPriority returns the priority of a LoRaWAN downlink payload
+func GetPriority(payload []byte) Priority {
+	if len(payload) == 0 {
+		return PriorityUnconfirmed
+	}
+	switch payload[0] >> 5 {
+	case 1: // JoinAccept
+		return PriorityMAC
+	case 3, 5: // UnconfirmedDataDown, ConfirmedDataDown
+		if len(payload) < 12 {
+			return PriorityUnconfirmed
+		}
+		fOptsLen := int(payload[5] & 0x0F)
+		if fOptsLen > 0 {
+			return PriorityMAC
+		}
+		if portIdx := 8; portIdx < len(payload)-4 && payload[portIdx] == 0 {
+			return PriorityMAC // MAC commands on FPort 0
+		}
+		if payload[0]>>5 == 5 {
+			return PriorityConfirmed
+		}
+	}
+	return PriorityUnconfirmed
+}
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGetPriority(t *testing.T) {
+	a := New(t)
+
+	a.So(GetPriority(nil), ShouldEqual, PriorityUnconfirmed)
+
+	// JoinAccept
+	a.So(GetPriority([]byte{0x20, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}), ShouldEqual, PriorityMAC)
+
+	// UnconfirmedDataDown with FPort 1
+	a.So(GetPriority([]byte{0x60, 1, 2, 3, 4, 0x00, 1, 0, 1, 0xAA, 1, 2, 3, 4}), ShouldEqual, PriorityUnconfirmed)
+
+	// ConfirmedDataDown with FPort 1
+	a.So(GetPriority([]byte{0xA0, 1, 2, 3, 4, 0x00, 1, 0, 1, 0xAA, 1, 2, 3, 4}), ShouldEqual, PriorityConfirmed)
+
+	// UnconfirmedDataDown with FOpts
+	a.So(GetPriority([]byte{0x60, 1, 2, 3, 4, 0x01, 1, 0, 0x02, 1, 2, 3, 4}), ShouldEqual, PriorityMAC)
+
+	// UnconfirmedDataDown with FPort 0
+	a.So(GetPriority([]byte{0x60, 1, 2, 3, 4, 0x00, 1, 0, 0, 0xAA, 1, 2, 3, 4}), ShouldEqual, PriorityMAC)
+
+	// UnconfirmedDataDown without FPort
+	a.So(GetPriority([]byte{0x60, 1, 2, 3, 4, 0x00, 1, 0, 1, 2, 3, 4}), ShouldEqual, PriorityUnconfirmed)
+}
+
+func TestParsePriority(t *testing.T) {
+	a := New(t)
+
+	for _, priority := range []Priority{PriorityUnconfirmed, PriorityConfirmed, PriorityMAC} {
+		parsed, err := ParsePriority(priority.String())
+		a.So(err, ShouldBeNil)
+		a.So(parsed, ShouldEqual, priority)
+	}
+
+	parsed, err := ParsePriority("MAC")
+	a.So(err, ShouldBeNil)
+	a.So(parsed, ShouldEqual, PriorityMAC)
+
+	_, err = ParsePriority("urgent")
+	a.So(err, ShouldNotBeNil)
+}
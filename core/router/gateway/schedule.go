@@ -6,26 +6,45 @@ package gateway
 import (
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	router_pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/api/trace"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/TheThingsNetwork/ttn/utils/random"
 	"github.com/TheThingsNetwork/ttn/utils/toa"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+var preemptedDownlinksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "preempted_downlinks_total",
+		Help:      "Number of scheduled downlinks that were not sent because a downlink with a higher priority preempted them.",
+	}, []string{"priority"},
+)
+
+func init() {
+	prometheus.MustRegister(preemptedDownlinksCounter)
+}
+
 // Schedule is used to schedule downlink transmissions
 type Schedule interface {
 	fmt.GoStringer
 	// Synchronize the schedule with the gateway timestamp (in microseconds)
 	Sync(timestamp uint32)
+	// Clock returns the model of the gateway clock that the schedule is synchronized with
+	Clock() *Clock
 	// Get an "option" on a transmission slot at timestamp for the maximum duration of length (both in microseconds)
 	GetOption(timestamp uint32, length uint32) (id string, score uint)
-	// Schedule a transmission on a slot
-	Schedule(id string, downlink *router_pb.DownlinkMessage) error
+	// Get the score of a transmission slot like GetOption, without taking an option on it
+	GetConflicts(timestamp uint32, length uint32) (score uint)
+	// Schedule a transmission of an application on a slot. If it overlaps with a downlink of a lower priority, or a
+	// downlink of the same priority of an application that used more airtime, that downlink is not sent.
+	Schedule(id string, downlink *router_pb.DownlinkMessage, appID string, priority Priority) error
 	// Subscribe to downlink messages
 	Subscribe(subscriptionID string) <-chan *router_pb.DownlinkMessage
 	// Whether the gateway has active downlink
@@ -68,13 +87,14 @@ type scheduledItem struct {
 	timestamp  uint32
 	length     uint32
 	score      uint
+	appID      string
+	priority   Priority
 	payload    *router_pb.DownlinkMessage
 }
 
 type schedule struct {
-	offset int64 // should be on top to ensure memory alignment needed for sync/atomic
-
 	sync.RWMutex
+	clock                     Clock
 	ctx                       ttnlog.Interface
 	items                     map[string]*scheduledItem
 	downlink                  chan *router_pb.DownlinkMessage
@@ -104,19 +124,9 @@ func (s *schedule) getConflicts(timestamp uint32, length uint32) (conflicts uint
 	s.RLock()
 	defer s.RUnlock()
 	for _, item := range s.items {
-		scheduledFrom := uint64(item.timestamp) % uintmax
-		scheduledTo := scheduledFrom + uint64(item.length)
-		from := uint64(timestamp)
-		to := from + uint64(length)
-
-		if scheduledTo > uintmax || to > uintmax {
-			if scheduledTo-uintmax <= from || scheduledFrom >= to-uintmax {
-				continue
-			}
-		} else if scheduledTo <= from || scheduledFrom >= to {
+		if !item.overlaps(timestamp, length) {
 			continue
 		}
-
 		if item.payload == nil {
 			conflicts++
 		} else {
@@ -126,21 +136,64 @@ func (s *schedule) getConflicts(timestamp uint32, length uint32) (conflicts uint
 	return
 }
 
+// overlaps returns true if the item overlaps with the slot at timestamp for length (both in microseconds)
+func (item *scheduledItem) overlaps(timestamp uint32, length uint32) bool {
+	scheduledFrom := uint64(item.timestamp) % uintmax
+	scheduledTo := scheduledFrom + uint64(item.length)
+	from := uint64(timestamp)
+	to := from + uint64(length)
+
+	if scheduledTo > uintmax || to > uintmax {
+		if scheduledTo-uintmax <= from || scheduledFrom >= to-uintmax {
+			return false
+		}
+	} else if scheduledTo <= from || scheduledFrom >= to {
+		return false
+	}
+	return true
+}
+
+// fairer returns true if the application used less (weighted) airtime on the gateway than the other application
+func (s *schedule) fairer(appID, otherAppID string) bool {
+	if appID == otherAppID || s.gateway == nil || s.gateway.Airtime == nil {
+		return false
+	}
+	return s.gateway.Airtime.Share(appID) < s.gateway.Airtime.Share(otherAppID)
+}
+
+// addAirtime adds the airtime of the downlink to the application of the item
+func (s *schedule) addAirtime(item *scheduledItem) {
+	if s.gateway != nil && s.gateway.Airtime != nil {
+		s.gateway.Airtime.AddTx(item.appID, item.payload)
+	}
+}
+
+// drop removes the payload of a scheduled item that will not be sent. The Broker was already told that the downlink
+// was scheduled, so the drop is counted and reported to the monitor of the gateway.
+func (s *schedule) drop(item *scheduledItem, reason string) {
+	preemptedDownlinksCounter.WithLabelValues(item.priority.String()).Inc()
+	downlink := item.payload
+	item.payload = nil
+	downlink.Trace = downlink.Trace.WithEvent(trace.DropEvent, "reason", reason)
+	if s.gateway != nil && s.gateway.MonitorStream != nil {
+		s.gateway.MonitorStream.Send(downlink)
+	}
+}
+
 // realtime gets the synchronized time for a timestamp (in microseconds). Time
 // should first be syncronized using func Sync()
 func (s *schedule) realtime(timestamp uint32) (t time.Time) {
-	offset := atomic.LoadInt64(&s.offset)
-	t = time.Unix(0, 0)
-	t = t.Add(time.Duration(int64(timestamp)*1000 + offset))
-	if t.Before(time.Now()) {
-		t = t.Add(time.Duration(int64(1<<32) * 1000))
-	}
-	return
+	return s.clock.ServerTime(timestamp)
 }
 
 // see interface
 func (s *schedule) Sync(timestamp uint32) {
-	atomic.StoreInt64(&s.offset, time.Now().UnixNano()-int64(timestamp)*1000)
+	s.clock.Sync(timestamp, time.Now())
+}
+
+// see interface
+func (s *schedule) Clock() *Clock {
+	return &s.clock
 }
 
 // see interface
@@ -161,13 +214,19 @@ func (s *schedule) GetOption(timestamp uint32, length uint32) (id string, score
 }
 
 // see interface
-func (s *schedule) Schedule(id string, downlink *router_pb.DownlinkMessage) error {
+func (s *schedule) GetConflicts(timestamp uint32, length uint32) (score uint) {
+	return s.getConflicts(timestamp, length)
+}
+
+// see interface
+func (s *schedule) Schedule(id string, downlink *router_pb.DownlinkMessage, appID string, priority Priority) error {
 	ctx := s.ctx.WithField("Identifier", id)
 
 	s.Lock()
 	defer s.Unlock()
 	if item, ok := s.items[id]; ok {
-		item.payload = downlink
+		item.appID = appID
+		item.priority = priority
 
 		conf := downlink.GetProtocolConfiguration()
 		if lorawan := conf.GetLoRaWAN(); lorawan != nil {
@@ -190,17 +249,43 @@ func (s *schedule) Schedule(id string, downlink *router_pb.DownlinkMessage) erro
 			item.length = uint32(time / 1000)
 		}
 
+		// Only the downlink with the highest priority is sent, downlinks that are already sent can not be preempted
+		var preempt []*scheduledItem
+		for _, other := range s.items {
+			if other == item || other.payload == nil || !other.overlaps(item.timestamp, item.length) {
+				continue
+			}
+			if other.priority > priority || (other.priority == priority && !s.fairer(appID, other.appID)) || !time.Now().Before(other.deadlineAt) {
+				return errors.NewErrAlreadyExists(fmt.Sprintf("Downlink with priority %s in the slot", other.priority))
+			}
+			preempt = append(preempt, other)
+		}
+		for _, other := range preempt {
+			ctx.WithFields(ttnlog.Fields{
+				"Preempted": other.id,
+				"Priority":  priority,
+			}).Warn("Preempted downlink for downlink with higher priority")
+			s.drop(other, "preempted by downlink with higher priority")
+		}
+
+		item.payload = downlink
+
 		if time.Now().Before(item.deadlineAt) {
-			// Schedule transmission before the Deadline
+			// Schedule transmission before the Deadline. The trace is written under the lock, as in drop()
+			waitTime := item.deadlineAt.Sub(time.Now())
+			downlink.Trace = downlink.Trace.WithEvent("schedule", "duration", waitTime)
 			go func() {
-				waitTime := item.deadlineAt.Sub(time.Now())
 				ctx.WithField("Remaining", waitTime).Info("Scheduled downlink")
-				downlink.Trace = downlink.Trace.WithEvent("schedule", "duration", waitTime)
 				<-time.After(waitTime)
 				s.RLock()
 				defer s.RUnlock()
+				if item.payload == nil {
+					ctx.Debug("Downlink was preempted")
+					return
+				}
 				if s.downlink != nil {
 					ctx.Debug("Send Downlink")
+					s.addAirtime(item)
 					s.downlink <- item.payload
 				}
 			}()
@@ -212,6 +297,7 @@ func (s *schedule) Schedule(id string, downlink *router_pb.DownlinkMessage) erro
 					overdue := time.Now().Sub(item.deadlineAt)
 					if overdue < Deadline {
 						ctx.WithField("Overdue", overdue).Debug("Send Downlink")
+						s.addAirtime(item)
 						s.downlink <- item.payload
 					} else {
 						ctx.WithField("Overdue", overdue).Warn("Discard Late Downlink")
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	router_pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/api/trace"
 	. "github.com/TheThingsNetwork/ttn/utils/testing"
 	. "github.com/smartystreets/assertions"
 )
@@ -19,10 +20,10 @@ func TestScheduleSync(t *testing.T) {
 	a := New(t)
 	s := &schedule{}
 	s.Sync(0)
-	a.So(s.offset, ShouldAlmostEqual, time.Now().UnixNano(), almostEqual)
+	a.So(s.Clock().ServerTime(0).UnixNano(), ShouldAlmostEqual, time.Now().UnixNano(), almostEqual)
 
 	s.Sync(1000)
-	a.So(s.offset, ShouldAlmostEqual, time.Now().UnixNano()-1000*1000, almostEqual)
+	a.So(s.Clock().ServerTime(1000).UnixNano(), ShouldAlmostEqual, time.Now().UnixNano(), almostEqual)
 }
 
 func TestScheduleRealtime(t *testing.T) {
@@ -37,9 +38,26 @@ func TestScheduleRealtime(t *testing.T) {
 	a.So(tm.UnixNano(), ShouldAlmostEqual, time.Now().UnixNano()+10*1000, almostEqual)
 
 	// Don't go back in time when uint32 overflows
+	s = &schedule{}
 	s.Sync(uintmax - 1)
 	tm = s.realtime(10)
-	a.So(tm.UnixNano(), ShouldAlmostEqual, time.Now().UnixNano()+9*1000, almostEqual)
+	a.So(tm.UnixNano(), ShouldAlmostEqual, time.Now().UnixNano()+11*1000, almostEqual)
+
+	// Don't go forward in time when the timestamp is before the overflow
+	s = &schedule{}
+	s.Sync(10)
+	tm = s.realtime(uintmax - 1000)
+	a.So(tm.UnixNano(), ShouldAlmostEqual, time.Now().UnixNano()-1010*1000, almostEqual)
+}
+
+func TestScheduleRollover(t *testing.T) {
+	a := New(t)
+	s := &schedule{items: make(map[string]*scheduledItem)}
+
+	// RX1 of an uplink that was received half a second before the rollover
+	s.Sync(uintmax - 500000)
+	id, _ := s.GetOption(500000, 100000)
+	a.So(s.items[id].deadlineAt.UnixNano(), ShouldAlmostEqual, time.Now().Add(time.Second-Deadline).UnixNano(), almostEqual)
 }
 
 func buildItems(items ...*scheduledItem) map[string]*scheduledItem {
@@ -107,17 +125,50 @@ func TestScheduleSchedule(t *testing.T) {
 
 	s.Sync(0)
 
-	err := s.Schedule("random", &router_pb.DownlinkMessage{})
+	err := s.Schedule("random", &router_pb.DownlinkMessage{}, "app", PriorityUnconfirmed)
 	a.So(err, ShouldNotBeNil)
 
 	id, conflicts := s.GetOption(100, 100)
-	err = s.Schedule(id, &router_pb.DownlinkMessage{})
+	err = s.Schedule(id, &router_pb.DownlinkMessage{}, "app", PriorityUnconfirmed)
 	a.So(err, ShouldBeNil)
 
 	_, conflicts = s.GetOption(50, 100)
 	a.So(conflicts, ShouldEqual, 100)
 }
 
+func TestSchedulePriority(t *testing.T) {
+	a := New(t)
+	s := NewSchedule(GetLogger(t, "TestSchedulePriority")).(*schedule)
+
+	s.Sync(0)
+
+	low, _ := s.GetOption(5000000, 100)
+	lowDownlink := &router_pb.DownlinkMessage{}
+	err := s.Schedule(low, lowDownlink, "app", PriorityConfirmed)
+	a.So(err, ShouldBeNil)
+
+	// Same priority in the same slot
+	same, _ := s.GetOption(5000050, 100)
+	err = s.Schedule(same, &router_pb.DownlinkMessage{}, "app", PriorityConfirmed)
+	a.So(err, ShouldNotBeNil)
+
+	// Lower priority in the same slot
+	lower, _ := s.GetOption(5000050, 100)
+	err = s.Schedule(lower, &router_pb.DownlinkMessage{}, "app", PriorityUnconfirmed)
+	a.So(err, ShouldNotBeNil)
+
+	// Higher priority preempts the scheduled downlink
+	high, _ := s.GetOption(5000050, 100)
+	err = s.Schedule(high, &router_pb.DownlinkMessage{}, "app", PriorityMAC)
+	a.So(err, ShouldBeNil)
+	a.So(s.items[low].payload, ShouldBeNil)
+	a.So(s.items[high].payload, ShouldNotBeNil)
+
+	// The preempted downlink is reported as dropped
+	a.So(lowDownlink.Trace, ShouldNotBeNil)
+	a.So(lowDownlink.Trace.Event, ShouldEqual, trace.DropEvent)
+}
+
 func TestScheduleSubscribe(t *testing.T) {
 	a := New(t)
 	s := NewSchedule(GetLogger(t, "TestScheduleSubscribe")).(*schedule)
@@ -144,11 +195,11 @@ func TestScheduleSubscribe(t *testing.T) {
 	}()
 
 	id, _ := s.GetOption(30000, 50)
-	s.Schedule(id, downlink1)
+	s.Schedule(id, downlink1, "app", PriorityUnconfirmed)
 	id, _ = s.GetOption(20000, 50)
-	s.Schedule(id, downlink2)
+	s.Schedule(id, downlink2, "app", PriorityUnconfirmed)
 	id, _ = s.GetOption(40000, 50)
-	s.Schedule(id, downlink3)
+	s.Schedule(id, downlink3, "app", PriorityUnconfirmed)
 
 	go func() {
 		<-time.After(400 * time.Millisecond)
@@ -0,0 +1,42 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"time"
+
+	pb "github.com/TheThingsNetwork/api/gateway"
+)
+
+// Software is the packet forwarder that a gateway reported in its status messages
+type Software struct {
+	Platform string    `json:"platform,omitempty"`
+	Bridge   string    `json:"bridge,omitempty"`
+	Since    time.Time `json:"since"` // when the gateway first reported this platform and bridge
+}
+
+// updateSoftware remembers the platform and bridge of the status. Status messages that do not report them keep the
+// ones that were reported earlier.
+func (g *Gateway) updateSoftware(status *pb.Status) {
+	g.softwareMu.Lock()
+	defer g.softwareMu.Unlock()
+	platform, bridge := status.Platform, status.Bridge
+	if platform == "" {
+		platform = g.software.Platform
+	}
+	if bridge == "" {
+		bridge = g.software.Bridge
+	}
+	if platform == g.software.Platform && bridge == g.software.Bridge {
+		return
+	}
+	g.software = Software{Platform: platform, Bridge: bridge, Since: time.Now()}
+}
+
+// Software returns the packet forwarder that the gateway reported last
+func (g *Gateway) Software() Software {
+	g.softwareMu.RLock()
+	defer g.softwareMu.RUnlock()
+	return g.software
+}
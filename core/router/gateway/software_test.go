@@ -0,0 +1,32 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package gateway
+
+import (
+	"testing"
+
+	pb "github.com/TheThingsNetwork/api/gateway"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGatewaySoftware(t *testing.T) {
+	a := New(t)
+	gtw := NewGateway(GetLogger(t, "TestGatewaySoftware"), "eui-0102030405060708")
+	a.So(gtw.Software().Platform, ShouldBeEmpty)
+
+	gtw.HandleStatus(&pb.Status{Platform: "IMST + Rpi", Bridge: "semtech"})
+	software := gtw.Software()
+	a.So(software.Platform, ShouldEqual, "IMST + Rpi")
+	a.So(software.Bridge, ShouldEqual, "semtech")
+	a.So(software.Since.IsZero(), ShouldBeFalse)
+
+	// Status messages without platform keep the earlier platform
+	gtw.HandleStatus(&pb.Status{})
+	a.So(gtw.Software(), ShouldResemble, software)
+
+	gtw.HandleStatus(&pb.Status{Platform: "IMST + Rpi v2"})
+	a.So(gtw.Software().Platform, ShouldEqual, "IMST + Rpi v2")
+	a.So(gtw.Software().Bridge, ShouldEqual, "semtech")
+}
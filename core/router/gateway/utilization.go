@@ -95,24 +95,9 @@ func (u *utilization) AddRx(uplink *pb_router.UplinkMessage) error {
 }
 
 func (u *utilization) AddTx(downlink *pb_router.DownlinkMessage) error {
-	var t time.Duration
-	var err error
-	if lorawan := downlink.ProtocolConfiguration.GetLoRaWAN(); lorawan != nil {
-		if lorawan.Modulation == pb_lorawan.Modulation_LORA {
-			t, err = toa.ComputeLoRa(uint(len(downlink.Payload)), lorawan.DataRate, lorawan.CodingRate)
-			if err != nil {
-				return err
-			}
-		}
-		if lorawan.Modulation == pb_lorawan.Modulation_FSK {
-			t, err = toa.ComputeFSK(uint(len(downlink.Payload)), int(lorawan.BitRate))
-			if err != nil {
-				return err
-			}
-		}
-	}
-	if t == 0 {
-		return nil
+	t, err := downlinkAirtime(downlink)
+	if err != nil || t == 0 {
+		return err
 	}
 	u.overallTx.Update(int64(t) / 1000)
 	frequency := downlink.GatewayConfiguration.Frequency
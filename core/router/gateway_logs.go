@@ -0,0 +1,198 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/spf13/viper"
+)
+
+// GatewayLogsPath is the path on the health port where gateways upload diagnostic bundles and log excerpts
+const GatewayLogsPath = "/gateway-logs"
+
+// maxGatewayLogUpload is the largest upload that is read, regardless of the retention limits
+const maxGatewayLogUpload = 10 << 20
+
+// WithGatewayLogs accepts diagnostic uploads of gateways and keeps them within the retention limits
+func (r *router) WithGatewayLogs(retention gateway.LogRetention) Router {
+	r.logRetention = &retention
+	return r
+}
+
+func (r *router) UploadGatewayLog(gatewayID, token string, upload *gateway.LogUpload) error {
+	if r.logRetention == nil {
+		return errors.NewErrNotFound("Gateway logs")
+	}
+	var gtw *gateway.Gateway
+	if err := r.authenticateGateway(gatewayID, token); err == nil {
+		gtw = r.getGateway(gatewayID)
+	} else if errors.IsInternal(err) || !viper.GetBool("router.skip-verify-gateway-token") {
+		return err
+	} else if gtw, err = r.existingGateway(gatewayID); err != nil {
+		// Without verification, only connected gateways can upload, so that uploads can not create gateways
+		return err
+	}
+	if r.logRetention.MaxSize > 0 && len(upload.Data) > r.logRetention.MaxSize {
+		return errors.NewErrInvalidArgument("Upload", fmt.Sprintf("is larger than %d bytes", r.logRetention.MaxSize))
+	}
+
+	r.logUploadLock.Lock()
+	defer r.logUploadLock.Unlock()
+	if r.logRetention.MaxTotalSize > 0 && r.gatewayLogsSize()+len(upload.Data) > r.logRetention.MaxTotalSize {
+		return errors.NewErrFailedPrecondition("The storage for gateway logs is full")
+	}
+	gtw.Logs.Add(upload, *r.logRetention)
+	r.Ctx.WithField("GatewayID", gatewayID).WithField("Size", upload.Size).Info("Received gateway logs")
+	return nil
+}
+
+// gatewayLogsSize returns the total size of the uploads of all gateways
+func (r *router) gatewayLogsSize() (size int) {
+	r.gatewaysLock.RLock()
+	gateways := make([]*gateway.Gateway, 0, len(r.gateways))
+	for _, gtw := range r.gateways {
+		gateways = append(gateways, gtw)
+	}
+	r.gatewaysLock.RUnlock()
+	for _, gtw := range gateways {
+		size += gtw.Logs.Size(*r.logRetention)
+	}
+	return size
+}
+
+func (r *router) existingGateway(gatewayID string) (*gateway.Gateway, error) {
+	if r.logRetention == nil {
+		return nil, errors.NewErrNotFound("Gateway logs")
+	}
+	r.gatewaysLock.RLock()
+	gtw, ok := r.gateways[gatewayID]
+	r.gatewaysLock.RUnlock()
+	if !ok {
+		return nil, errors.NewErrNotFound(gatewayID)
+	}
+	return gtw, nil
+}
+
+func (r *router) GatewayLogs(gatewayID string) ([]gateway.LogUpload, error) {
+	gtw, err := r.existingGateway(gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	return gtw.Logs.List(*r.logRetention), nil
+}
+
+func (r *router) GatewayLog(gatewayID, uploadID string) (*gateway.LogUpload, error) {
+	gtw, err := r.existingGateway(gatewayID)
+	if err != nil {
+		return nil, err
+	}
+	upload, ok := gtw.Logs.Get(uploadID)
+	if !ok {
+		return nil, errors.NewErrNotFound(uploadID)
+	}
+	return &upload, nil
+}
+
+type gatewayLogsHandler struct {
+	router Router
+}
+
+// NewGatewayLogsHandler returns a handler for the diagnostic uploads of gateways. Gateways POST a bundle or log
+// excerpt with their gateway_id and an optional name in the query, and their gateway token as bearer token in the
+// Authorization header. Operators GET the list of uploads of a gateway_id, or a single upload with its id, with
+// the admin token or the token of the gateway as bearer token.
+func NewGatewayLogsHandler(router Router) http.Handler {
+	return &gatewayLogsHandler{router: router}
+}
+
+func (h *gatewayLogsHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+
+	query := req.URL.Query()
+	gatewayID := query.Get("gateway_id")
+	if gatewayID == "" {
+		http.Error(res, "gateway_id is required", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Method {
+	case "POST":
+		data, err := ioutil.ReadAll(http.MaxBytesReader(res, req.Body, maxGatewayLogUpload))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		upload := &gateway.LogUpload{
+			Name:        query.Get("name"),
+			ContentType: req.Header.Get("Content-Type"),
+			Data:        data,
+		}
+		if err := h.router.UploadGatewayLog(gatewayID, token, upload); err != nil {
+			http.Error(res, err.Error(), gatewayLogsStatus(err))
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusCreated)
+		json.NewEncoder(res).Encode(upload)
+	case "GET", "HEAD":
+		if !component.IsAdmin(req) {
+			token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if err := h.router.authenticateGateway(gatewayID, token); err != nil {
+				http.Error(res, err.Error(), gatewayLogsStatus(err))
+				return
+			}
+		}
+		if id := query.Get("id"); id != "" {
+			upload, err := h.router.GatewayLog(gatewayID, id)
+			if err != nil {
+				http.Error(res, err.Error(), gatewayLogsStatus(err))
+				return
+			}
+			if upload.ContentType != "" {
+				res.Header().Set("Content-Type", upload.ContentType)
+			}
+			// The upload is not trusted, so browsers must download it instead of rendering it
+			disposition := mime.FormatMediaType("attachment", map[string]string{"filename": upload.Name})
+			if upload.Name == "" || disposition == "" {
+				disposition = "attachment"
+			}
+			res.Header().Set("Content-Disposition", disposition)
+			res.Write(upload.Data)
+			return
+		}
+		uploads, err := h.router.GatewayLogs(gatewayID)
+		if err != nil {
+			http.Error(res, err.Error(), gatewayLogsStatus(err))
+			return
+		}
+		res.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(res).Encode(uploads)
+	default:
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func gatewayLogsStatus(err error) int {
+	switch errors.GetErrType(err) {
+	case errors.NotFound:
+		return http.StatusNotFound
+	case errors.PermissionDenied:
+		return http.StatusForbidden
+	case errors.InvalidArgument:
+		return http.StatusRequestEntityTooLarge
+	case errors.FailedPrecondition:
+		return http.StatusInsufficientStorage
+	}
+	return http.StatusInternalServerError
+}
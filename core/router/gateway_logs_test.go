@@ -0,0 +1,117 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+	"github.com/spf13/viper"
+)
+
+func TestGatewayLogs(t *testing.T) {
+	a := New(t)
+
+	ctx := GetLogger(t, "TestGatewayLogs")
+	gtw := gateway.NewGateway(ctx, "gtw1")
+	r := &router{
+		Component: &component.Component{Ctx: ctx},
+		gateways:  map[string]*gateway.Gateway{gtw.ID: gtw},
+	}
+	handler := NewGatewayLogsHandler(r)
+
+	viper.Set("admin-token", "secret")
+	defer viper.Set("admin-token", "")
+	get := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	// Not enabled
+	res := get(GatewayLogsPath + "?gateway_id=gtw1")
+	a.So(res.Code, ShouldEqual, http.StatusNotFound)
+
+	r.WithGatewayLogs(gateway.LogRetention{MaxUploads: 10, MaxSize: 4, MaxTotalSize: 6})
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", GatewayLogsPath, nil))
+	a.So(res.Code, ShouldEqual, http.StatusBadRequest)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("DELETE", GatewayLogsPath+"?gateway_id=gtw1", nil))
+	a.So(res.Code, ShouldEqual, http.StatusMethodNotAllowed)
+
+	// Without token
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw1", bytes.NewBufferString("log")))
+	a.So(res.Code, ShouldEqual, http.StatusForbidden)
+
+	viper.Set("router.skip-verify-gateway-token", true)
+	defer viper.Set("router.skip-verify-gateway-token", false)
+
+	// Too large
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw1", bytes.NewBufferString("large log")))
+	a.So(res.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+
+	req := httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw1&name=station.log", bytes.NewBufferString("log"))
+	req.Header.Set("Content-Type", "text/plain")
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	a.So(res.Code, ShouldEqual, http.StatusCreated)
+	var upload gateway.LogUpload
+	a.So(json.Unmarshal(res.Body.Bytes(), &upload), ShouldBeNil)
+	a.So(upload.ID, ShouldNotBeEmpty)
+	a.So(upload.Name, ShouldEqual, "station.log")
+	a.So(upload.Size, ShouldEqual, 3)
+
+	// Without verification, unknown gateways can not upload
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw3", bytes.NewBufferString("log")))
+	a.So(res.Code, ShouldEqual, http.StatusNotFound)
+
+	// Reading requires the admin token or the token of the gateway, also without verification of uploads
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", GatewayLogsPath+"?gateway_id=gtw1", nil))
+	a.So(res.Code, ShouldEqual, http.StatusForbidden)
+
+	res = get(GatewayLogsPath + "?gateway_id=gtw1")
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+	var uploads []gateway.LogUpload
+	a.So(json.Unmarshal(res.Body.Bytes(), &uploads), ShouldBeNil)
+	a.So(uploads, ShouldHaveLength, 1)
+
+	res = get(GatewayLogsPath + "?gateway_id=gtw1&id=" + upload.ID)
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+	a.So(res.Header().Get("Content-Type"), ShouldEqual, "text/plain")
+	a.So(res.Header().Get("X-Content-Type-Options"), ShouldEqual, "nosniff")
+	a.So(res.Header().Get("Content-Disposition"), ShouldEqual, "attachment; filename=station.log")
+	a.So(res.Body.String(), ShouldEqual, "log")
+
+	// The uploads of all gateways are limited
+	gtw2 := gateway.NewGateway(ctx, "gtw2")
+	r.gateways[gtw2.ID] = gtw2
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw2", bytes.NewBufferString("logs")))
+	a.So(res.Code, ShouldEqual, http.StatusInsufficientStorage)
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", GatewayLogsPath+"?gateway_id=gtw2", bytes.NewBufferString("log")))
+	a.So(res.Code, ShouldEqual, http.StatusCreated)
+
+	res = get(GatewayLogsPath + "?gateway_id=gtw1&id=unknown")
+	a.So(res.Code, ShouldEqual, http.StatusNotFound)
+
+	res = get(GatewayLogsPath + "?gateway_id=gtw4")
+	a.So(res.Code, ShouldEqual, http.StatusNotFound)
+}
@@ -0,0 +1,124 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"sync"
+
+	pb "github.com/TheThingsNetwork/api/router"
+)
+
+type ingestItem struct {
+	gatewayID string
+	uplink    *pb.UplinkMessage
+}
+
+// ingestBuffer is a preallocated ring buffer of uplinks that are waiting to be handled. It absorbs
+// bursts of uplinks without allocating, and drops the oldest uplink when it is full.
+type ingestBuffer struct {
+	mu     sync.Mutex
+	ready  *sync.Cond
+	items  []ingestItem
+	head   int
+	length int
+	closed bool
+}
+
+func newIngestBuffer(size int) *ingestBuffer {
+	b := &ingestBuffer{items: make([]ingestItem, size)}
+	b.ready = sync.NewCond(&b.mu)
+	return b
+}
+
+// Push adds an uplink to the buffer and returns true if the oldest uplink was dropped to make room for it
+func (b *ingestBuffer) Push(gatewayID string, uplink *pb.UplinkMessage) (dropped bool) {
+	b.mu.Lock()
+	if b.length == len(b.items) {
+		b.items[b.head] = ingestItem{}
+		b.head = (b.head + 1) % len(b.items)
+		b.length--
+		dropped = true
+	}
+	b.items[(b.head+b.length)%len(b.items)] = ingestItem{gatewayID, uplink}
+	b.length++
+	length := b.length
+	b.mu.Unlock()
+	b.ready.Signal()
+	ingestBufferGauge.Set(float64(length))
+	if dropped {
+		ingestDroppedCounter.Inc()
+	}
+	return
+}
+
+// Pop removes the oldest uplink from the buffer, waiting for one if the buffer is empty. It returns false when the
+// buffer is closed.
+func (b *ingestBuffer) Pop() (gatewayID string, uplink *pb.UplinkMessage, ok bool) {
+	b.mu.Lock()
+	for b.length == 0 && !b.closed {
+		b.ready.Wait()
+	}
+	if b.closed {
+		b.mu.Unlock()
+		return "", nil, false
+	}
+	item := b.items[b.head]
+	b.items[b.head] = ingestItem{} // Do not keep a reference to the uplink
+	b.head = (b.head + 1) % len(b.items)
+	b.length--
+	length := b.length
+	b.mu.Unlock()
+	ingestBufferGauge.Set(float64(length))
+	return item.gatewayID, item.uplink, true
+}
+
+// Close wakes up the workers that are waiting in Pop and makes them stop
+func (b *ingestBuffer) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.ready.Broadcast()
+}
+
+// Len returns the number of uplinks in the buffer
+func (b *ingestBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.length
+}
+
+func (r *router) WithIngestBuffer(size, workers int) Router {
+	if size > 0 && workers > 0 {
+		r.ingest = newIngestBuffer(size)
+		r.ingestWorkers = workers
+		ingestCapacityGauge.Set(float64(size))
+	}
+	return r
+}
+
+// startIngest starts the workers that handle the uplinks in the ingest buffer until the Router shuts down
+func (r *router) startIngest() {
+	for i := 0; i < r.ingestWorkers; i++ {
+		go func() {
+			for {
+				gatewayID, uplink, ok := r.ingest.Pop()
+				if !ok {
+					return
+				}
+				r.HandleUplink(gatewayID, uplink)
+			}
+		}()
+	}
+}
+
+// ingestUplink passes the uplink through the ingest buffer if it is enabled
+func (r *router) ingestUplink(gatewayID string, uplink *pb.UplinkMessage) {
+	if r.ingest == nil {
+		r.HandleUplink(gatewayID, uplink)
+		return
+	}
+	if r.ingest.Push(gatewayID, uplink) {
+		r.Ctx.WithField("GatewayID", gatewayID).Debug("Ingest buffer full, dropped oldest uplink")
+	}
+}
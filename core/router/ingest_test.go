@@ -0,0 +1,53 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+
+	pb "github.com/TheThingsNetwork/api/router"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestIngestBuffer(t *testing.T) {
+	a := New(t)
+
+	b := newIngestBuffer(2)
+	uplink1, uplink2, uplink3 := &pb.UplinkMessage{}, &pb.UplinkMessage{}, &pb.UplinkMessage{}
+
+	a.So(b.Push("gtw-1", uplink1), ShouldBeFalse)
+	a.So(b.Push("gtw-2", uplink2), ShouldBeFalse)
+	a.So(b.Len(), ShouldEqual, 2)
+
+	// The oldest uplink is dropped
+	a.So(b.Push("gtw-3", uplink3), ShouldBeTrue)
+	a.So(b.Len(), ShouldEqual, 2)
+
+	gatewayID, uplink, ok := b.Pop()
+	a.So(ok, ShouldBeTrue)
+	a.So(gatewayID, ShouldEqual, "gtw-2")
+	a.So(uplink, ShouldEqual, uplink2)
+	gatewayID, uplink, _ = b.Pop()
+	a.So(gatewayID, ShouldEqual, "gtw-3")
+	a.So(uplink, ShouldEqual, uplink3)
+	a.So(b.Len(), ShouldEqual, 0)
+
+	// Pop waits for an uplink
+	popped := make(chan string)
+	go func() {
+		gatewayID, _, _ := b.Pop()
+		popped <- gatewayID
+	}()
+	b.Push("gtw-4", uplink1)
+	a.So(<-popped, ShouldEqual, "gtw-4")
+
+	// Close stops the workers that are waiting
+	stopped := make(chan bool)
+	go func() {
+		_, _, ok := b.Pop()
+		stopped <- ok
+	}()
+	b.Close()
+	a.So(<-stopped, ShouldBeFalse)
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+)
+
+// InventoryPath is the path on the health port where the packet forwarders of the gateways are listed
+const InventoryPath = "/gateway-inventory"
+
+// GatewaySoftware is the packet forwarder of a gateway in the inventory
+type GatewaySoftware struct {
+	GatewayID string    `json:"gateway_id"`
+	LastSeen  time.Time `json:"last_seen"`
+	gateway.Software
+}
+
+// Inventory lists the packet forwarders of the gateways of the router, and how many gateways run each platform
+type Inventory struct {
+	Platforms map[string]int    `json:"platforms"`
+	Gateways  []GatewaySoftware `json:"gateways"`
+}
+
+func (r *router) GatewayInventory() []GatewaySoftware {
+	r.gatewaysLock.RLock()
+	defer r.gatewaysLock.RUnlock()
+	inventory := make([]GatewaySoftware, 0, len(r.gateways))
+	for id, gtw := range r.gateways {
+		inventory = append(inventory, GatewaySoftware{
+			GatewayID: id,
+			LastSeen:  gtw.LastSeen,
+			Software:  gtw.Software(),
+		})
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].GatewayID < inventory[j].GatewayID })
+	return inventory
+}
+
+type inventoryHandler struct {
+	router Router
+}
+
+// NewInventoryHandler returns a handler that serves the inventory of the packet forwarders of the gateways of the
+// router. The platform and bridge query parameters only list the gateways with a platform or bridge that contains
+// the given text, so that operators can find the gateways that run an outdated packet forwarder. It must be protected
+// with the admin token.
+func NewInventoryHandler(router Router) http.Handler {
+	return &inventoryHandler{router: router}
+}
+
+func (h *inventoryHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" && req.Method != "HEAD" {
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	query := req.URL.Query()
+	platform, bridge := strings.ToLower(query.Get("platform")), strings.ToLower(query.Get("bridge"))
+
+	inventory := Inventory{Platforms: make(map[string]int), Gateways: []GatewaySoftware{}}
+	for _, software := range h.router.GatewayInventory() {
+		if !strings.Contains(strings.ToLower(software.Platform), platform) || !strings.Contains(strings.ToLower(software.Bridge), bridge) {
+			continue
+		}
+		inventory.Platforms[software.Platform]++
+		inventory.Gateways = append(inventory.Gateways, software)
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(inventory)
+}
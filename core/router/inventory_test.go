@@ -0,0 +1,71 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+	"github.com/spf13/viper"
+)
+
+func TestGatewayInventory(t *testing.T) {
+	a := New(t)
+
+	ctx := GetLogger(t, "TestGatewayInventory")
+	gtw1 := gateway.NewGateway(ctx, "gtw1")
+	gtw1.HandleStatus(&pb_gateway.Status{Platform: "IMST + Rpi", Bridge: "semtech"})
+	gtw2 := gateway.NewGateway(ctx, "gtw2")
+	gtw2.HandleStatus(&pb_gateway.Status{Platform: "The Things Gateway v1 - Firmware v1.0.4"})
+	gtw3 := gateway.NewGateway(ctx, "gtw3")
+	gtw3.HandleStatus(&pb_gateway.Status{Platform: "IMST + Rpi", Bridge: "semtech"})
+	r := &router{gateways: map[string]*gateway.Gateway{gtw1.ID: gtw1, gtw2.ID: gtw2, gtw3.ID: gtw3}}
+
+	inventory := r.GatewayInventory()
+	a.So(inventory, ShouldHaveLength, 3)
+	a.So(inventory[0].GatewayID, ShouldEqual, "gtw1")
+	a.So(inventory[1].Platform, ShouldEqual, "The Things Gateway v1 - Firmware v1.0.4")
+
+	handler := NewInventoryHandler(r)
+
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", InventoryPath, nil))
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+	var all Inventory
+	a.So(json.NewDecoder(res.Body).Decode(&all), ShouldBeNil)
+	a.So(all.Gateways, ShouldHaveLength, 3)
+	a.So(all.Platforms["IMST + Rpi"], ShouldEqual, 2)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("GET", InventoryPath+"?platform=firmware+v1.0", nil))
+	var filtered Inventory
+	a.So(json.NewDecoder(res.Body).Decode(&filtered), ShouldBeNil)
+	a.So(filtered.Gateways, ShouldHaveLength, 1)
+	a.So(filtered.Gateways[0].GatewayID, ShouldEqual, "gtw2")
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest("POST", InventoryPath, nil))
+	a.So(res.Code, ShouldEqual, http.StatusMethodNotAllowed)
+
+	// On the health port, the inventory is only served with the admin token
+	protected := component.RequireAdmin(handler, "GET", "HEAD")
+	res = httptest.NewRecorder()
+	protected.ServeHTTP(res, httptest.NewRequest("GET", InventoryPath, nil))
+	a.So(res.Code, ShouldEqual, http.StatusForbidden)
+
+	viper.Set("admin-token", "secret")
+	defer viper.Set("admin-token", "")
+	req := httptest.NewRequest("GET", InventoryPath, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	res = httptest.NewRecorder()
+	protected.ServeHTTP(res, req)
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+}
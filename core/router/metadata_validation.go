@@ -0,0 +1,153 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// MetadataValidation rejects uplinks with gateway metadata that can not be right, so that it does not end up
+// in the ADR and scheduling logic of other components.
+type MetadataValidation struct {
+	// MaxTimeOffset is the maximum difference between the time that the gateway reports and the time of the
+	// Router. Zero disables the check of the gateway time.
+	MaxTimeOffset time.Duration
+}
+
+// Plausible ranges of the signal metadata
+const (
+	minRSSI = -150.0 // dBm
+	maxRSSI = 0.0    // dBm
+	minSNR  = -30.0  // dB
+	maxSNR  = 20.0   // dB
+
+	maxBitRate = 300000 // bps
+)
+
+var codingRates = map[string]bool{"4/5": true, "4/6": true, "4/7": true, "4/8": true}
+
+// frequencyRanges contains the frequency range (in Hz) of each frequency plan
+var frequencyRanges = map[pb_lorawan.FrequencyPlan][2]uint64{
+	pb_lorawan.FrequencyPlan_EU_863_870: {863000000, 870000000},
+	pb_lorawan.FrequencyPlan_US_902_928: {902000000, 928000000},
+	pb_lorawan.FrequencyPlan_CN_779_787: {779000000, 787000000},
+	pb_lorawan.FrequencyPlan_EU_433:     {433050000, 434790000},
+	pb_lorawan.FrequencyPlan_AU_915_928: {915000000, 928000000},
+	pb_lorawan.FrequencyPlan_CN_470_510: {470000000, 510000000},
+	pb_lorawan.FrequencyPlan_AS_923:     {915000000, 928000000},
+	pb_lorawan.FrequencyPlan_AS_920_923: {920000000, 923500000},
+	pb_lorawan.FrequencyPlan_AS_923_925: {923000000, 925000000},
+	pb_lorawan.FrequencyPlan_KR_920_923: {920000000, 923500000},
+	pb_lorawan.FrequencyPlan_IN_865_867: {865000000, 867000000},
+}
+
+// Frequencies outside all frequency plans
+const (
+	minFrequency = 433050000
+	maxFrequency = 928000000
+)
+
+// Metadata fields that are validated. They are counted in the invalid metadata metric.
+const (
+	MetadataFrequency  = "frequency"
+	MetadataDataRate   = "data_rate"
+	MetadataBitRate    = "bit_rate"
+	MetadataCodingRate = "coding_rate"
+	MetadataRSSI       = "rssi"
+	MetadataSNR        = "snr"
+	MetadataTime       = "time"
+)
+
+// MetadataError is an invalid field of the metadata of an uplink
+type MetadataError struct {
+	Field  string
+	Reason string
+}
+
+func (err MetadataError) Error() string {
+	return fmt.Sprintf("%s %s", err.Field, err.Reason)
+}
+
+func (r *router) WithMetadataValidation(validation MetadataValidation) Router {
+	r.metadataValidation = &validation
+	return r
+}
+
+// validateMetadata returns an error if the metadata of the uplink is not valid. The frequency is checked
+// against the frequency plan in the gateway status, or against all frequency plans if there is no status.
+func (r *router) validateMetadata(gtw *gateway.Gateway, uplink *pb.UplinkMessage) error {
+	if r.metadataValidation == nil {
+		return nil
+	}
+	var frequencyPlan *pb_lorawan.FrequencyPlan
+	if status, err := gtw.Status.Get(); err == nil {
+		if plan, ok := pb_lorawan.FrequencyPlan_value[status.FrequencyPlan]; ok {
+			fp := pb_lorawan.FrequencyPlan(plan)
+			frequencyPlan = &fp
+		}
+	}
+	invalid := r.metadataValidation.check(uplink, frequencyPlan, time.Now())
+	if len(invalid) == 0 {
+		return nil
+	}
+	reasons := make([]string, len(invalid))
+	for i, err := range invalid {
+		invalidMetadataCounter.WithLabelValues(err.Field).Inc()
+		reasons[i] = err.Error()
+	}
+	return errors.NewErrInvalidArgument("Metadata", strings.Join(reasons, ", "))
+}
+
+func (v *MetadataValidation) check(uplink *pb.UplinkMessage, frequencyPlan *pb_lorawan.FrequencyPlan, now time.Time) (invalid []MetadataError) {
+	md := uplink.GatewayMetadata
+	lorawan := uplink.ProtocolMetadata.GetLoRaWAN()
+
+	if frequencyPlan != nil {
+		if frequencyRange, ok := frequencyRanges[*frequencyPlan]; ok && (md.Frequency < frequencyRange[0] || md.Frequency > frequencyRange[1]) {
+			invalid = append(invalid, MetadataError{MetadataFrequency, fmt.Sprintf("%d is outside %s", md.Frequency, *frequencyPlan)})
+		}
+	} else if md.Frequency < minFrequency || md.Frequency > maxFrequency {
+		invalid = append(invalid, MetadataError{MetadataFrequency, fmt.Sprintf("%d is outside all frequency plans", md.Frequency)})
+	}
+
+	if lorawan != nil {
+		switch lorawan.Modulation {
+		case pb_lorawan.Modulation_LORA:
+			if datr, err := types.ParseDataRate(lorawan.DataRate); err != nil || datr.String() != lorawan.DataRate {
+				invalid = append(invalid, MetadataError{MetadataDataRate, fmt.Sprintf("\"%s\" is not a LoRa data rate", lorawan.DataRate)})
+			}
+			if !codingRates[lorawan.CodingRate] {
+				invalid = append(invalid, MetadataError{MetadataCodingRate, fmt.Sprintf("\"%s\" is not a LoRa coding rate", lorawan.CodingRate)})
+			}
+		case pb_lorawan.Modulation_FSK:
+			if lorawan.BitRate == 0 || lorawan.BitRate > maxBitRate {
+				invalid = append(invalid, MetadataError{MetadataBitRate, fmt.Sprintf("%d is not a FSK bit rate", lorawan.BitRate)})
+			}
+		}
+	}
+
+	if md.RSSI < minRSSI || md.RSSI > maxRSSI {
+		invalid = append(invalid, MetadataError{MetadataRSSI, fmt.Sprintf("%.1f is outside [%.0f, %.0f]", md.RSSI, minRSSI, maxRSSI)})
+	}
+	if md.SNR < minSNR || md.SNR > maxSNR {
+		invalid = append(invalid, MetadataError{MetadataSNR, fmt.Sprintf("%.1f is outside [%.0f, %.0f]", md.SNR, minSNR, maxSNR)})
+	}
+
+	if v.MaxTimeOffset > 0 && md.Time != 0 {
+		offset := now.Sub(time.Unix(0, md.Time))
+		if offset > v.MaxTimeOffset || offset < -v.MaxTimeOffset {
+			invalid = append(invalid, MetadataError{MetadataTime, fmt.Sprintf("is %s away from the server time", offset)})
+		}
+	}
+
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestValidateMetadata(t *testing.T) {
+	a := New(t)
+
+	now := time.Now()
+	uplink := func() *pb.UplinkMessage {
+		return &pb.UplinkMessage{
+			ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{
+				Modulation: pb_lorawan.Modulation_LORA,
+				DataRate:   "SF7BW125",
+				CodingRate: "4/5",
+			}}},
+			GatewayMetadata: pb_gateway.RxMetadata{Frequency: 868100000, RSSI: -100, SNR: 5, Time: now.UnixNano()},
+		}
+	}
+	fields := func(invalid []MetadataError) (fields []string) {
+		for _, err := range invalid {
+			fields = append(fields, err.Field)
+		}
+		return
+	}
+
+	eu := pb_lorawan.FrequencyPlan_EU_863_870
+	us := pb_lorawan.FrequencyPlan_US_902_928
+	validation := &MetadataValidation{MaxTimeOffset: time.Minute}
+
+	a.So(validation.check(uplink(), &eu, now), ShouldBeEmpty)
+	a.So(validation.check(uplink(), nil, now), ShouldBeEmpty)
+
+	// Frequency of the gateway's frequency plan, or of any frequency plan
+	a.So(fields(validation.check(uplink(), &us, now)), ShouldResemble, []string{MetadataFrequency})
+	up := uplink()
+	up.GatewayMetadata.Frequency = 0
+	a.So(fields(validation.check(up, nil, now)), ShouldResemble, []string{MetadataFrequency})
+
+	// All invalid fields are reported
+	up = uplink()
+	lorawan := up.ProtocolMetadata.GetLoRaWAN()
+	lorawan.DataRate = "SF7BW125 "
+	lorawan.CodingRate = "4/9"
+	up.GatewayMetadata.RSSI = 10
+	up.GatewayMetadata.SNR = -100
+	up.GatewayMetadata.Time = now.Add(-time.Hour).UnixNano()
+	invalid := validation.check(up, &eu, now)
+	a.So(fields(invalid), ShouldResemble, []string{MetadataDataRate, MetadataCodingRate, MetadataRSSI, MetadataSNR, MetadataTime})
+	a.So(invalid[2].Error(), ShouldEqual, "rssi 10.0 is outside [-150, 0]")
+
+	// The time is not checked without a maximum offset, or if the gateway does not report it
+	a.So(fields((&MetadataValidation{}).check(up, &eu, now)), ShouldNotContain, MetadataTime)
+	up.GatewayMetadata.Time = 0
+	a.So(fields(validation.check(up, &eu, now)), ShouldNotContain, MetadataTime)
+
+	// FSK
+	up = uplink()
+	up.ProtocolMetadata.GetLoRaWAN().Modulation = pb_lorawan.Modulation_FSK
+	a.So(fields(validation.check(up, &eu, now)), ShouldResemble, []string{MetadataBitRate})
+	up.ProtocolMetadata.GetLoRaWAN().BitRate = 50000
+	a.So(validation.check(up, &eu, now), ShouldBeEmpty)
+}
+
+func TestRouterValidateMetadata(t *testing.T) {
+	a := New(t)
+
+	r := &router{}
+	gtw := gateway.NewGateway(GetLogger(t, "TestRouterValidateMetadata"), "eui-0102030405060708")
+	gtw.Status.Update(&pb_gateway.Status{FrequencyPlan: "US_902_928"})
+	up := &pb.UplinkMessage{GatewayMetadata: pb_gateway.RxMetadata{Frequency: 868100000, RSSI: -100, SNR: 5}}
+
+	// Without validation
+	a.So(r.validateMetadata(gtw, up), ShouldBeNil)
+
+	r.WithMetadataValidation(MetadataValidation{})
+	err := r.validateMetadata(gtw, up)
+	a.So(err, ShouldNotBeNil)
+	a.So(err.Error(), ShouldEqual, "Metadata not valid: frequency 868100000 is outside US_902_928")
+}
@@ -0,0 +1,71 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var filteredUplinksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "filtered_uplinks_total",
+		Help:      "Number of uplinks that were dropped by the signal filter.",
+	}, []string{"reason"},
+)
+
+var rejectedUplinksCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "rejected_uplinks_total",
+		Help:      "Number of uplinks that were not forwarded to a broker, by reason.",
+	}, []string{"reason"},
+)
+
+var invalidMetadataCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "invalid_metadata_total",
+		Help:      "Number of invalid metadata fields of uplinks, by field.",
+	}, []string{"field"},
+)
+
+var ingestBufferGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "ingest_buffer_uplinks",
+		Help:      "Number of uplinks in the ingest buffer.",
+	},
+)
+
+var ingestCapacityGauge = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "ingest_buffer_capacity",
+		Help:      "Number of uplinks that fit in the ingest buffer.",
+	},
+)
+
+var ingestDroppedCounter = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "router",
+		Name:      "ingest_dropped_uplinks_total",
+		Help:      "Number of uplinks that were dropped because the ingest buffer was full.",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(filteredUplinksCounter)
+	prometheus.MustRegister(ingestBufferGauge)
+	prometheus.MustRegister(ingestCapacityGauge)
+	prometheus.MustRegister(ingestDroppedCounter)
+	prometheus.MustRegister(invalidMetadataCounter)
+	prometheus.MustRegister(rejectedUplinksCounter)
+}
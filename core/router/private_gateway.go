@@ -0,0 +1,29 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// WithPrivateGateways only sends the downlinks of the applications of their owner through private gateways. The
+// Router does not know the application of uplinks and join requests, so the Broker filters those after it found
+// the device.
+func (r *router) WithPrivateGateways(gateways types.PrivateGateways) Router {
+	r.privateGateways = gateways
+	return r
+}
+
+// checkPrivateGateway returns an error if the downlink is sent through a private gateway of another owner
+func (r *router) checkPrivateGateway(downlink *pb_broker.DownlinkMessage) error {
+	gatewayID := downlink.DownlinkOption.GatewayID
+	if !r.privateGateways.Allows(gatewayID, downlink.AppID) {
+		return errors.NewErrPermissionDenied(fmt.Sprintf("Private gateway %s does not send downlinks of application %s", gatewayID, downlink.AppID))
+	}
+	return nil
+}
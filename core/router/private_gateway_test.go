@@ -0,0 +1,31 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+
+	pb_broker "github.com/TheThingsNetwork/api/broker"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestCheckPrivateGateway(t *testing.T) {
+	a := New(t)
+
+	downlink := func(gatewayID, appID string) *pb_broker.DownlinkMessage {
+		return &pb_broker.DownlinkMessage{AppID: appID, DownlinkOption: &pb_broker.DownlinkOption{GatewayID: gatewayID}}
+	}
+
+	r := &router{}
+	a.So(r.checkPrivateGateway(downlink("eui-0102030405060708", "other-app")), ShouldBeNil)
+
+	gateways, _ := types.ParsePrivateGateways("eui-0102030405060708=owner-app")
+	r.WithPrivateGateways(gateways)
+	a.So(r.checkPrivateGateway(downlink("eui-0102030405060708", "owner-app")), ShouldBeNil)
+	err := r.checkPrivateGateway(downlink("eui-0102030405060708", "other-app"))
+	a.So(errors.GetErrType(err), ShouldEqual, errors.PermissionDenied)
+	a.So(r.checkPrivateGateway(downlink("eui-0807060504030201", "other-app")), ShouldBeNil)
+}
@@ -0,0 +1,17 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+// Reasons for not forwarding uplinks to a broker. They are counted in the rejected uplinks metric
+// and added as "code" to the drop event of the trace.
+const (
+	RejectInvalidFrame     = "invalid_frame"
+	RejectWrongDirection   = "wrong_direction"
+	RejectGateway          = "gateway"
+	RejectInvalidMetadata  = "invalid_metadata"
+	RejectSignalFilter     = "signal_filter"
+	RejectForwardingFilter = "forwarding_filter"
+	RejectBlacklisted      = "blacklisted"
+	RejectNoBrokers        = "no_brokers"
+)
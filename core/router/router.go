@@ -16,7 +16,9 @@ import (
 	"github.com/TheThingsNetwork/go-utils/grpc/auth"
 	"github.com/TheThingsNetwork/go-utils/grpc/ttnctx"
 	"github.com/TheThingsNetwork/ttn/core/component"
+	"github.com/TheThingsNetwork/ttn/core/router/capture"
 	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 )
@@ -26,6 +28,33 @@ type Router interface {
 	component.Interface
 	component.ManagementInterface
 
+	// Limit the traffic that is forwarded to Brokers
+	WithForwardingFilter(filter ForwardingFilter) Router
+	// Only send the downlinks of the applications of their owners through private gateways
+	WithPrivateGateways(gateways types.PrivateGateways) Router
+	// Drop uplinks with a signal that is too weak to have been demodulated
+	WithSignalFilter(filter SignalFilter) Router
+	// Reject uplinks with gateway metadata that can not be right
+	WithMetadataValidation(validation MetadataValidation) Router
+	// Capture the uplink messages that are received from gateways
+	WithCapture(w *capture.Writer) Router
+	// Limit the downlink priority of applications
+	WithDownlinkPriorityCaps(caps map[string]gateway.Priority) Router
+	// Share the downlink airtime of gateways between applications according to their weights
+	WithAirtimeWeights(weights map[string]float64) Router
+	// Route join requests with a JoinEUI in the given ranges to the given Brokers
+	WithJoinEUIRoutes(routes ...JoinEUIRoute) Router
+	// Absorb bursts of uplinks in a buffer of the given size that is handled by the given number of workers
+	WithIngestBuffer(size, workers int) Router
+	// Accept diagnostic uploads of gateways and keep them within the retention limits
+	WithGatewayLogs(retention gateway.LogRetention) Router
+	// Connect the gateways that publish their traffic through ChirpStack gateway bridges to the MQTT server
+	WithChirpStackBridge(bridge ChirpStackBridge) Router
+	// Only send downlinks in frequency plans that require listen-before-talk through these gateways
+	WithLBTGateways(gatewayIDs ...string) Router
+	// Drop the uplinks of DevAddrs that are on the blacklists of Brokers
+	WithBrokerBlacklists(blacklists BrokerBlacklists) Router
+
 	// Handle a status message from a gateway
 	HandleGatewayStatus(gatewayID string, status *pb_gateway.Status) error
 	// Handle an uplink message from a gateway
@@ -38,8 +67,20 @@ type Router interface {
 	UnsubscribeDownlink(gatewayID string, subscriptionID string) error
 	// Handle a device activation
 	HandleActivation(gatewayID string, activation *pb.DeviceActivationRequest) (*pb.DeviceActivationResponse, error)
+	// Report whether and how a downlink of the payload size (in bytes) could be sent in the receive windows of the
+	// uplink, without sending it
+	DryRunDownlink(uplink *pb.UplinkMessage, isActivation bool, payloadSize int) ([]DownlinkEstimate, error)
+	// List the packet forwarders that the gateways reported in their status messages
+	GatewayInventory() []GatewaySoftware
+	// Store a diagnostic bundle or log excerpt that the gateway uploaded with its token
+	UploadGatewayLog(gatewayID, token string, upload *gateway.LogUpload) error
+	// List the diagnostic uploads of a gateway
+	GatewayLogs(gatewayID string) ([]gateway.LogUpload, error)
+	// Get a diagnostic upload of a gateway
+	GatewayLog(gatewayID, uploadID string) (*gateway.LogUpload, error)
 
 	getGateway(gatewayID string) *gateway.Gateway
+	authenticateGateway(gatewayID, token string) error
 }
 
 type broker struct {
@@ -60,12 +101,27 @@ func NewRouter() Router {
 
 type router struct {
 	*component.Component
-	gateways      map[string]*gateway.Gateway
-	gatewaysLock  sync.RWMutex
-	brokers       map[string]*broker
-	brokersLock   sync.RWMutex
-	status        *status
-	monitorStream monitorclient.Stream
+	gateways           map[string]*gateway.Gateway
+	gatewaysLock       sync.RWMutex
+	brokers            map[string]*broker
+	brokersLock        sync.RWMutex
+	routes             routingTable
+	filter             ForwardingFilter
+	privateGateways    types.PrivateGateways
+	signalFilter       *SignalFilter
+	metadataValidation *MetadataValidation
+	capture            *capture.Writer
+	priorityCaps       map[string]gateway.Priority
+	airtimeWeights     map[string]float64
+	lbtGateways        map[string]bool
+	ingest             *ingestBuffer
+	ingestWorkers      int
+	logRetention       *gateway.LogRetention
+	logUploadLock      sync.Mutex
+	chirpstack         *chirpStackBridge
+	blacklists         *brokerBlacklists
+	status             *status
+	monitorStream      monitorclient.Stream
 }
 
 func (r *router) tickGateways() {
@@ -73,6 +129,7 @@ func (r *router) tickGateways() {
 	defer r.gatewaysLock.RUnlock()
 	for _, gtw := range r.gateways {
 		gtw.Utilization.Tick()
+		gtw.Airtime.Tick()
 	}
 }
 
@@ -89,6 +146,20 @@ func (r *router) Init(c *component.Component) error {
 	}
 	r.Discovery.GetAll("broker") // Update cache
 
+	if r.ingest != nil {
+		r.startIngest()
+	}
+
+	if r.chirpstack != nil {
+		if err := r.connectChirpStackBridge(); err != nil {
+			return err
+		}
+	}
+
+	if r.blacklists != nil {
+		r.startBrokerBlacklists()
+	}
+
 	go func() {
 		for range time.Tick(5 * time.Second) {
 			r.tickGateways()
@@ -106,7 +177,42 @@ func (r *router) Init(c *component.Component) error {
 	return nil
 }
 
+func (r *router) WithCapture(w *capture.Writer) Router {
+	r.capture = w
+	return r
+}
+
+func (r *router) WithDownlinkPriorityCaps(caps map[string]gateway.Priority) Router {
+	r.priorityCaps = caps
+	return r
+}
+
+func (r *router) WithAirtimeWeights(weights map[string]float64) Router {
+	r.airtimeWeights = weights
+	return r
+}
+
+func (r *router) WithLBTGateways(gatewayIDs ...string) Router {
+	r.lbtGateways = make(map[string]bool, len(gatewayIDs))
+	for _, id := range gatewayIDs {
+		r.lbtGateways[id] = true
+	}
+	return r
+}
+
 func (r *router) Shutdown() {
+	if r.capture != nil {
+		r.capture.Close()
+	}
+	if r.chirpstack != nil {
+		r.chirpstack.stop()
+	}
+	if r.blacklists != nil {
+		r.blacklists.stop()
+	}
+	if r.ingest != nil {
+		r.ingest.Close()
+	}
 	r.brokersLock.Lock()
 	defer r.brokersLock.Unlock()
 	for _, broker := range r.brokers {
@@ -131,6 +237,7 @@ func (r *router) getGateway(id string) *gateway.Gateway {
 	gtw, ok = r.gateways[id]
 	if !ok {
 		gtw = gateway.NewGateway(r.Ctx, id)
+		gtw.Airtime.SetWeights(r.airtimeWeights)
 		ctx := context.Background()
 		ctx = ttnctx.OutgoingContextWithID(ctx, id)
 		if r.Identity != nil {
@@ -0,0 +1,191 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// RoutingTableRefreshInterval is the interval in which the routing table is rebuilt from the Broker announcements
+var RoutingTableRefreshInterval = 30 * time.Second
+
+// JoinEUIRoute routes the join requests with a JoinEUI (AppEUI) in the range [First, Last] to a Broker
+type JoinEUIRoute struct {
+	First    types.AppEUI
+	Last     types.AppEUI
+	BrokerID string
+}
+
+// ParseJoinEUIRoute parses a JoinEUI route in the format <first>-<last>=<broker-id>
+func ParseJoinEUIRoute(str string) (route JoinEUIRoute, err error) {
+	parts := strings.SplitN(str, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return route, errors.NewErrInvalidArgument("JoinEUI route", "must be in the format <first>-<last>=<broker-id>")
+	}
+	route.BrokerID = parts[1]
+	euis := strings.SplitN(parts[0], "-", 2)
+	if len(euis) != 2 {
+		return route, errors.NewErrInvalidArgument("JoinEUI route", "must be in the format <first>-<last>=<broker-id>")
+	}
+	if route.First, err = types.ParseAppEUI(euis[0]); err != nil {
+		return route, errors.NewErrInvalidArgument("JoinEUI route", err.Error())
+	}
+	if route.Last, err = types.ParseAppEUI(euis[1]); err != nil {
+		return route, errors.NewErrInvalidArgument("JoinEUI route", err.Error())
+	}
+	if binary.BigEndian.Uint64(route.Last[:]) < binary.BigEndian.Uint64(route.First[:]) {
+		return route, errors.NewErrInvalidArgument("JoinEUI route", "last JoinEUI is before first JoinEUI")
+	}
+	return route, nil
+}
+
+// String implements the fmt.Stringer interface
+func (r JoinEUIRoute) String() string {
+	return fmt.Sprintf("%s-%s=%s", r.First, r.Last, r.BrokerID)
+}
+
+// Contains returns true if the JoinEUI is in the range of the route
+func (r JoinEUIRoute) Contains(joinEUI types.AppEUI) bool {
+	eui := binary.BigEndian.Uint64(joinEUI[:])
+	return eui >= binary.BigEndian.Uint64(r.First[:]) && eui <= binary.BigEndian.Uint64(r.Last[:])
+}
+
+func (r *router) WithJoinEUIRoutes(routes ...JoinEUIRoute) Router {
+	r.routes.mu.Lock()
+	defer r.routes.mu.Unlock()
+	r.routes.joinEUIRoutes = routes
+	r.routes.builtAt = time.Time{}
+	return r
+}
+
+type devAddrRoute struct {
+	prefix types.DevAddrPrefix
+	broker *pb_discovery.Announcement
+}
+
+type joinEUIRoute struct {
+	JoinEUIRoute
+	broker *pb_discovery.Announcement
+}
+
+// routingTable maps DevAddr prefixes and JoinEUI ranges to Brokers. The DevAddr prefixes and the
+// AppEUIs that Brokers announce are combined with the configured JoinEUI routes. The zero value
+// is an empty table that is built on first use.
+type routingTable struct {
+	mu            sync.RWMutex
+	builtAt       time.Time
+	joinEUIRoutes []JoinEUIRoute
+
+	brokers  []*pb_discovery.Announcement
+	devAddrs []devAddrRoute
+	joinEUIs []joinEUIRoute
+}
+
+func (t *routingTable) build(brokers []*pb_discovery.Announcement) {
+	byID := make(map[string]*pb_discovery.Announcement, len(brokers))
+	t.brokers, t.devAddrs, t.joinEUIs = brokers, nil, nil
+	for _, broker := range brokers {
+		byID[broker.ID] = broker
+		for _, meta := range broker.Metadata {
+			if prefixBytes := meta.GetDevAddrPrefix(); prefixBytes != nil {
+				var prefix types.DevAddrPrefix
+				if err := prefix.Unmarshal(prefixBytes); err == nil {
+					t.devAddrs = append(t.devAddrs, devAddrRoute{prefix, broker})
+				}
+			}
+			if euiBytes := meta.GetAppEUI(); euiBytes != nil {
+				var eui types.AppEUI
+				if err := eui.Unmarshal(euiBytes); err == nil {
+					t.joinEUIs = append(t.joinEUIs, joinEUIRoute{JoinEUIRoute{eui, eui, broker.ID}, broker})
+				}
+			}
+		}
+	}
+	for _, route := range t.joinEUIRoutes {
+		if broker, ok := byID[route.BrokerID]; ok {
+			t.joinEUIs = append(t.joinEUIs, joinEUIRoute{route, broker})
+		}
+	}
+}
+
+// refreshRoutes rebuilds the routing table if it is older than the RoutingTableRefreshInterval. If the
+// Broker announcements can not be retrieved, the previous table is used.
+func (r *router) refreshRoutes() error {
+	r.routes.mu.RLock()
+	fresh := time.Since(r.routes.builtAt) < RoutingTableRefreshInterval
+	r.routes.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	brokers, err := r.Discovery.GetAll("broker")
+	r.routes.mu.Lock()
+	defer r.routes.mu.Unlock()
+	if err != nil {
+		if r.routes.builtAt.IsZero() {
+			return err
+		}
+		r.Ctx.WithError(err).Warn("Could not refresh routing table, using previous table")
+		return nil
+	}
+	r.routes.build(brokers)
+	r.routes.builtAt = time.Now()
+	return nil
+}
+
+// brokersForDevAddr returns the Brokers that announced a prefix of the DevAddr, or all Brokers if
+// no Broker announced a prefix of the DevAddr
+func (r *router) brokersForDevAddr(devAddr types.DevAddr) ([]*pb_discovery.Announcement, error) {
+	if err := r.refreshRoutes(); err != nil {
+		return nil, err
+	}
+	r.routes.mu.RLock()
+	defer r.routes.mu.RUnlock()
+	var brokers []*pb_discovery.Announcement
+	for _, route := range r.routes.devAddrs {
+		if devAddr.HasPrefix(route.prefix) {
+			brokers = appendBroker(brokers, route.broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return r.routes.brokers, nil
+	}
+	return brokers, nil
+}
+
+// brokersForJoinEUI returns the Brokers that have a route for the JoinEUI, or all Brokers if no
+// Broker has a route for the JoinEUI
+func (r *router) brokersForJoinEUI(joinEUI types.AppEUI) ([]*pb_discovery.Announcement, error) {
+	if err := r.refreshRoutes(); err != nil {
+		return nil, err
+	}
+	r.routes.mu.RLock()
+	defer r.routes.mu.RUnlock()
+	var brokers []*pb_discovery.Announcement
+	for _, route := range r.routes.joinEUIs {
+		if route.Contains(joinEUI) {
+			brokers = appendBroker(brokers, route.broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return r.routes.brokers, nil
+	}
+	return brokers, nil
+}
+
+func appendBroker(brokers []*pb_discovery.Announcement, broker *pb_discovery.Announcement) []*pb_discovery.Announcement {
+	for _, existing := range brokers {
+		if existing.ID == broker.ID {
+			return brokers
+		}
+	}
+	return append(brokers, broker)
+}
@@ -0,0 +1,83 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"testing"
+
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestParseJoinEUIRoute(t *testing.T) {
+	a := New(t)
+
+	route, err := ParseJoinEUIRoute("70B3D57ED0000000-70B3D57ED000FFFF=dev")
+	a.So(err, ShouldBeNil)
+	a.So(route.BrokerID, ShouldEqual, "dev")
+	a.So(route.String(), ShouldEqual, "70B3D57ED0000000-70B3D57ED000FFFF=dev")
+	a.So(route.Contains(types.AppEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x12, 0x34}), ShouldBeTrue)
+	a.So(route.Contains(types.AppEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x01, 0x00, 0x00}), ShouldBeFalse)
+
+	for _, invalid := range []string{
+		"70B3D57ED0000000-70B3D57ED000FFFF",
+		"70B3D57ED0000000=dev",
+		"70B3D57ED000FFFF-70B3D57ED0000000=dev",
+		"70B3D57ED0000000-foo=dev",
+	} {
+		_, err := ParseJoinEUIRoute(invalid)
+		a.So(err, ShouldNotBeNil)
+	}
+}
+
+func TestRoutingTable(t *testing.T) {
+	a := New(t)
+
+	r := getTestRouter(t)
+	defer r.ctrl.Finish()
+
+	prefix := types.DevAddrPrefix{DevAddr: types.DevAddr{0x26, 0x00, 0x00, 0x00}, Length: 16}
+	brokers := []*pb_discovery.Announcement{
+		{ID: "ttn", Metadata: []*pb_discovery.Metadata{
+			{Metadata: &pb_discovery.Metadata_DevAddrPrefix{DevAddrPrefix: prefix.Bytes()}},
+		}},
+		{ID: "private", Metadata: []*pb_discovery.Metadata{
+			{Metadata: &pb_discovery.Metadata_AppEUI{AppEUI: []byte{1, 2, 3, 4, 5, 6, 7, 8}}},
+		}},
+		{ID: "other"},
+	}
+	r.discovery.EXPECT().GetAll("broker").Return(brokers, nil)
+
+	route, _ := ParseJoinEUIRoute("70B3D57ED0000000-70B3D57ED000FFFF=other")
+	r.WithJoinEUIRoutes(route)
+
+	found, err := r.brokersForDevAddr(types.DevAddr{0x26, 0x00, 0x12, 0x34})
+	a.So(err, ShouldBeNil)
+	a.So(found, ShouldHaveLength, 1)
+	a.So(found[0].ID, ShouldEqual, "ttn")
+
+	// Broadcast to all Brokers for unknown prefixes
+	found, _ = r.brokersForDevAddr(types.DevAddr{0x01, 0x02, 0x03, 0x04})
+	a.So(found, ShouldHaveLength, 3)
+
+	found, _ = r.brokersForJoinEUI(types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8})
+	a.So(found, ShouldHaveLength, 1)
+	a.So(found[0].ID, ShouldEqual, "private")
+
+	found, _ = r.brokersForJoinEUI(types.AppEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x00, 0x01})
+	a.So(found, ShouldHaveLength, 1)
+	a.So(found[0].ID, ShouldEqual, "other")
+
+	found, _ = r.brokersForJoinEUI(types.AppEUI{8, 7, 6, 5, 4, 3, 2, 1})
+	a.So(found, ShouldHaveLength, 3)
+
+	// The previous table is used if the Discovery server is not available
+	r.routes.builtAt = r.routes.builtAt.Add(-RoutingTableRefreshInterval)
+	r.discovery.EXPECT().GetAll("broker").Return(nil, errors.New("unavailable"))
+	found, err = r.brokersForDevAddr(types.DevAddr{0x26, 0x00, 0x12, 0x34})
+	a.So(err, ShouldBeNil)
+	a.So(found, ShouldHaveLength, 1)
+}
@@ -31,32 +31,36 @@ type routerRPC struct {
 	statusRate *ratelimit.Registry
 }
 
+// authenticateGateway returns an error if the token is not a valid token of the gateway
+func (r *router) authenticateGateway(gatewayID, token string) error {
+	if token == "" {
+		return errors.NewErrPermissionDenied("Gateway not authenticated")
+	}
+	if r.TokenKeyProvider == nil {
+		return errors.NewErrInternal("No token provider configured")
+	}
+	claims, err := claims.FromGatewayToken(r.TokenKeyProvider, token)
+	if err != nil {
+		return errors.NewErrPermissionDenied(fmt.Sprintf("Gateway token invalid: %s", err))
+	}
+	if claims.Subject != gatewayID {
+		return errors.NewErrPermissionDenied(fmt.Sprintf("Token subject \"%s\" not consistent with gateway ID \"%s\"", claims.Subject, gatewayID))
+	}
+	return nil
+}
+
 func (r *routerRPC) gatewayFromMetadata(md metadata.MD) (gtw *gateway.Gateway, err error) {
 	gatewayID, err := ttnctx.IDFromMetadata(md)
 	if err != nil {
 		return nil, err
 	}
 
-	authErr := errors.NewErrPermissionDenied("Gateway not authenticated")
-	authenticated := false
 	token, _ := ttnctx.TokenFromMetadata(md)
-
-	if token != "" {
-		if r.router.TokenKeyProvider == nil {
-			return nil, errors.NewErrInternal("No token provider configured")
-		}
-		claims, err := claims.FromGatewayToken(r.router.TokenKeyProvider, token)
-		if err != nil {
-			authErr = errors.NewErrPermissionDenied(fmt.Sprintf("Gateway token invalid: %s", err))
-		} else {
-			if claims.Subject != gatewayID {
-				authErr = errors.NewErrPermissionDenied(fmt.Sprintf("Token subject \"%s\" not consistent with gateway ID \"%s\"", claims.Subject, gatewayID))
-			} else {
-				authErr = nil
-				authenticated = true
-			}
-		}
+	authErr := r.router.authenticateGateway(gatewayID, token)
+	if errors.IsInternal(authErr) {
+		return nil, authErr
 	}
+	authenticated := authErr == nil
 
 	if authErr != nil && !viper.GetBool("router.skip-verify-gateway-token") {
 		return nil, authErr
@@ -87,8 +91,7 @@ func (r *routerRPC) getUplink(md metadata.MD) (ch chan *pb.UplinkMessage, err er
 				r.router.Ctx.WithField("GatewayID", gateway.ID).WithField("Wait", waitTime).Warn("Gateway reached uplink rate limit")
 				time.Sleep(waitTime)
 			}
-			r.router.HandleUplink(gateway.ID, uplink)
-
+			r.router.ingestUplink(gateway.ID, uplink)
 		}
 	}()
 	return
@@ -0,0 +1,83 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// SignalFilter drops uplinks with a signal that is too weak to have been demodulated. These are mostly
+// ghost frames of gateways that behave badly, and would otherwise be forwarded to the Brokers.
+type SignalFilter struct {
+	// MinSNR is the minimum SNR (in dB) of uplinks, regardless of their spreading factor
+	MinSNR float64
+	// Margin (in dB) below the demodulation floor of the spreading factor that is still accepted. The same
+	// margin is used for the RSSI above the estimated noise floor of the gateway.
+	Margin float64
+}
+
+// Reasons for dropping uplinks in the signal filter
+const (
+	FilterMinSNR            = "min_snr"
+	FilterDemodulationFloor = "demodulation_floor"
+	FilterNoiseFloor        = "noise_floor"
+)
+
+// demodulationFloors contains the minimum SNR (in dB) that is needed to demodulate LoRa per spreading factor
+var demodulationFloors = map[uint]float64{
+	7:  -7.5,
+	8:  -10,
+	9:  -12.5,
+	10: -15,
+	11: -17.5,
+	12: -20,
+}
+
+func (r *router) WithSignalFilter(filter SignalFilter) Router {
+	r.signalFilter = &filter
+	return r
+}
+
+// filterSignal returns the reason for dropping the uplink, or an empty string if the uplink is accepted. The
+// noise floor of the gateway is updated with the uplinks that are accepted.
+func (r *router) filterSignal(gtw *gateway.Gateway, uplink *pb.UplinkMessage) (reason string) {
+	lorawan := uplink.ProtocolMetadata.GetLoRaWAN()
+	if lorawan == nil || lorawan.Modulation != pb_lorawan.Modulation_LORA {
+		return "" // The SNR of FSK is not meaningful
+	}
+	rssi, snr := uplink.GatewayMetadata.RSSI, uplink.GatewayMetadata.SNR
+	if r.signalFilter != nil {
+		reason = r.signalFilter.check(gtw, lorawan.DataRate, float64(rssi), float64(snr))
+	}
+	if reason != "" {
+		filteredUplinksCounter.WithLabelValues(reason).Inc()
+		return reason
+	}
+	gtw.NoiseFloor.Update(rssi, snr)
+	return ""
+}
+
+func (f *SignalFilter) check(gtw *gateway.Gateway, dataRate string, rssi, snr float64) string {
+	if snr < f.MinSNR {
+		return FilterMinSNR
+	}
+	datr, err := types.ParseDataRate(dataRate)
+	if err != nil {
+		return ""
+	}
+	floor, ok := demodulationFloors[datr.SpreadingFactor]
+	if !ok {
+		return ""
+	}
+	if snr < floor-f.Margin {
+		return FilterDemodulationFloor
+	}
+	if noiseFloor, ok := gtw.NoiseFloor.Get(); ok && rssi-noiseFloor < floor-f.Margin {
+		return FilterNoiseFloor
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+
+	pb_gateway "github.com/TheThingsNetwork/api/gateway"
+	pb_protocol "github.com/TheThingsNetwork/api/protocol"
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	pb "github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/ttn/core/router/gateway"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestSignalFilter(t *testing.T) {
+	a := New(t)
+
+	r := &router{}
+	gtw := newReferenceGateway(t, "EU_863_870")
+	uplink := func(dataRate string, rssi, snr float32) *pb.UplinkMessage {
+		return &pb.UplinkMessage{
+			ProtocolMetadata: pb_protocol.RxMetadata{Protocol: &pb_protocol.RxMetadata_LoRaWAN{LoRaWAN: &pb_lorawan.Metadata{
+				Modulation: pb_lorawan.Modulation_LORA,
+				DataRate:   dataRate,
+			}}},
+			GatewayMetadata: pb_gateway.RxMetadata{RSSI: rssi, SNR: snr},
+		}
+	}
+
+	// Without filter
+	a.So(r.filterSignal(gtw, uplink("SF7BW125", -130, -20)), ShouldBeEmpty)
+
+	r.WithSignalFilter(SignalFilter{MinSNR: -25, Margin: 2.5})
+	a.So(r.filterSignal(gtw, uplink("SF12BW125", -130, -26)), ShouldEqual, FilterMinSNR)
+	a.So(r.filterSignal(gtw, uplink("SF7BW125", -120, -12)), ShouldEqual, FilterDemodulationFloor)
+	a.So(r.filterSignal(gtw, uplink("SF12BW125", -120, -12)), ShouldBeEmpty)
+
+	// Calibrate the noise floor of the gateway at -110 dBm
+	gtw = newReferenceGateway(t, "EU_863_870")
+	for i := uint(0); i < gateway.MinNoiseFloorSamples; i++ {
+		a.So(r.filterSignal(gtw, uplink("SF7BW125", -100, 10)), ShouldBeEmpty)
+	}
+	noiseFloor, ok := gtw.NoiseFloor.Get()
+	a.So(ok, ShouldBeTrue)
+	a.So(noiseFloor, ShouldAlmostEqual, -110)
+
+	// The SNR is fine, but the RSSI is too far below the noise floor of the gateway
+	a.So(r.filterSignal(gtw, uplink("SF7BW125", -125, 5)), ShouldEqual, FilterNoiseFloor)
+	a.So(r.filterSignal(gtw, uplink("SF7BW125", -115, -5)), ShouldBeEmpty)
+}
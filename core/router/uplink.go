@@ -22,11 +22,16 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 	ctx := r.Ctx.WithField("GatewayID", gatewayID).WithFields(logfields.ForMessage(uplink))
 	start := time.Now()
 	var gateway *gateway.Gateway
+	var rejectReason string
 
 	r.RegisterReceived(uplink)
 	defer func() {
+		if rejectReason != "" {
+			rejectedUplinksCounter.WithLabelValues(rejectReason).Inc()
+			ctx = ctx.WithField("RejectReason", rejectReason)
+		}
 		if err != nil {
-			uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", err)
+			uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", err, "code", rejectReason)
 			ctx.WithError(err).Warn("Could not handle uplink")
 		} else {
 			r.RegisterHandled(uplink)
@@ -37,18 +42,26 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 	}()
 	r.status.uplink.Mark(1)
 
+	if r.capture != nil {
+		if err := r.capture.Write(gatewayID, uplink); err != nil {
+			ctx.WithError(err).Warn("Could not capture uplink")
+		}
+	}
+
 	uplink.Trace = uplink.Trace.WithEvent(trace.ReceiveEvent, "gateway", gatewayID)
 
 	// LoRaWAN: Unmarshal
 	var phyPayload lorawan.PHYPayload
 	err = phyPayload.UnmarshalBinary(uplink.Payload)
 	if err != nil {
+		rejectReason = RejectInvalidFrame
 		return err
 	}
 
 	if phyPayload.MHDR.MType == lorawan.JoinRequest {
 		joinRequestPayload, ok := phyPayload.MACPayload.(*lorawan.JoinRequestPayload)
 		if !ok {
+			rejectReason = RejectInvalidFrame
 			return errors.NewErrInvalidArgument("Join Request", "does not contain a JoinRequest payload")
 		}
 		devEUI := types.DevEUI(joinRequestPayload.DevEUI)
@@ -70,6 +83,8 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 
 	if phyPayload.MHDR.MType != lorawan.UnconfirmedDataUp && phyPayload.MHDR.MType != lorawan.ConfirmedDataUp {
 		ctx.Warn("Accidentally received non-uplink message")
+		rejectReason = RejectWrongDirection
+		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "not an uplink", "code", rejectReason)
 		return nil
 	}
 
@@ -90,6 +105,7 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 
 	macPayload, ok := phyPayload.MACPayload.(*lorawan.MACPayload)
 	if !ok {
+		rejectReason = RejectInvalidFrame
 		return errors.NewErrInvalidArgument("Uplink", "does not contain a MAC payload")
 	}
 	devAddr := types.DevAddr(macPayload.FHDR.DevAddr)
@@ -101,10 +117,37 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 
 	gateway = r.getGateway(gatewayID)
 
+	if err = r.validateMetadata(gateway, uplink); err != nil {
+		rejectReason = RejectInvalidMetadata
+		return err
+	}
+
 	if err = gateway.HandleUplink(uplink); err != nil {
+		rejectReason = RejectGateway
 		return err
 	}
 
+	if reason := r.filterSignal(gateway, uplink); reason != "" {
+		ctx.WithField("Reason", reason).Debug("Uplink not forwarded by signal filter")
+		rejectReason = RejectSignalFilter
+		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "signal filter", "code", rejectReason, "filter", reason)
+		return nil
+	}
+
+	if !r.filter.allowsGateway(gateway, uplink.GatewayMetadata.Frequency) || !r.filter.allowsDevAddr(devAddr) {
+		ctx.Debug("Uplink not forwarded by forwarding filter")
+		rejectReason = RejectForwardingFilter
+		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "forwarding filter", "code", rejectReason)
+		return nil
+	}
+
+	if r.blacklists != nil && r.blacklists.Blocked(devAddr) {
+		ctx.Debug("Uplink not forwarded because DevAddr is blacklisted")
+		rejectReason = RejectBlacklisted
+		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "blacklisted", "code", rejectReason)
+		return nil
+	}
+
 	var downlinkOptions []*pb_broker.DownlinkOption
 	if gateway.Schedule.IsActive() {
 		downlinkOptions = r.buildDownlinkOptions(uplink, false, gateway)
@@ -116,14 +159,16 @@ func (r *router) HandleUplink(gatewayID string, uplink *pb.UplinkMessage) (err e
 	ctx = ctx.WithField("DownlinkOptions", len(downlinkOptions))
 
 	// Find Broker
-	brokers, err := r.Discovery.GetAllBrokersForDevAddr(devAddr)
+	brokers, err := r.brokersForDevAddr(devAddr)
 	if err != nil {
 		return err
 	}
+	brokers = r.filter.filterBrokers(brokers)
 
 	if len(brokers) == 0 {
 		ctx.Debug("No brokers to forward message to")
-		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "no brokers")
+		rejectReason = RejectNoBrokers
+		uplink.Trace = uplink.Trace.WithEvent(trace.DropEvent, "reason", "no brokers", "code", rejectReason)
 		return nil
 	}
 
@@ -12,7 +12,6 @@ import (
 	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
 	pb "github.com/TheThingsNetwork/api/router"
 	"github.com/TheThingsNetwork/ttn/core/router/gateway"
-	"github.com/TheThingsNetwork/ttn/core/types"
 	. "github.com/TheThingsNetwork/ttn/utils/testing"
 	"github.com/brocaar/lorawan"
 	. "github.com/smartystreets/assertions"
@@ -66,7 +65,7 @@ func TestHandleUplink(t *testing.T) {
 	a := New(t)
 
 	r := getTestRouter(t)
-	r.discovery.EXPECT().GetAllBrokersForDevAddr(types.DevAddr([4]byte{1, 2, 3, 4})).Return([]*discovery.Announcement{}, nil)
+	r.discovery.EXPECT().GetAll("broker").Return([]*discovery.Announcement{}, nil)
 
 	uplink := newReferenceUplink()
 	gtwID := "eui-0102030405060708"
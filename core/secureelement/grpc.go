@@ -0,0 +1,126 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package secureelement
+
+import (
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// The crypto service implements the following gRPC service:
+//
+//   package secureelement;
+//
+//   service SecureElement {
+//     rpc MIC(MICRequest) returns (MICResponse);
+//   }
+//
+//   message MICRequest {
+//     bytes app_eui = 1;
+//     bytes dev_eui = 2;
+//     bytes data    = 3; // B0 block followed by the message
+//   }
+//
+//   message MICResponse {
+//     bytes mic = 1;
+//   }
+
+// MICRequest is the request of the MIC RPC
+type MICRequest struct {
+	AppEUI []byte `protobuf:"bytes,1,opt,name=app_eui,json=appEui,proto3" json:"app_eui,omitempty"`
+	DevEUI []byte `protobuf:"bytes,2,opt,name=dev_eui,json=devEui,proto3" json:"dev_eui,omitempty"`
+	Data   []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *MICRequest) Reset()         { *m = MICRequest{} }
+func (m *MICRequest) String() string { return proto.CompactTextString(m) }
+func (*MICRequest) ProtoMessage()    {}
+
+// MICResponse is the response of the MIC RPC
+type MICResponse struct {
+	MIC []byte `protobuf:"bytes,1,opt,name=mic,proto3" json:"mic,omitempty"`
+}
+
+func (m *MICResponse) Reset()         { *m = MICResponse{} }
+func (m *MICResponse) String() string { return proto.CompactTextString(m) }
+func (*MICResponse) ProtoMessage()    {}
+
+const micMethod = "/secureelement.SecureElement/MIC"
+
+// DefaultTimeout is the default timeout of calls to the crypto service
+var DefaultTimeout = 2 * time.Second
+
+// Client is a Service that calls the crypto service over gRPC
+type Client struct {
+	conn    *grpc.ClientConn
+	Timeout time.Duration
+}
+
+// NewClient returns a new Client on the connection to the crypto service
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn, Timeout: DefaultTimeout}
+}
+
+// MIC implements the Service interface
+func (c *Client) MIC(appEUI types.AppEUI, devEUI types.DevEUI, data []byte) (mic [4]byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	res := new(MICResponse)
+	err = grpc.Invoke(ctx, micMethod, &MICRequest{AppEUI: appEUI.Bytes(), DevEUI: devEUI.Bytes(), Data: data}, res, c.conn)
+	if err != nil {
+		return mic, errors.Wrap(errors.FromGRPCError(err), "Secure element did not return MIC")
+	}
+	if len(res.MIC) != len(mic) {
+		return mic, errors.NewErrInternal("Secure element returned an invalid MIC")
+	}
+	copy(mic[:], res.MIC)
+	return mic, nil
+}
+
+// RegisterServer registers the service as the SecureElement gRPC service on the server
+func RegisterServer(s *grpc.Server, service Service) {
+	s.RegisterService(&serviceDesc, service)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "secureelement.SecureElement",
+	HandlerType: (*Service)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "MIC", Handler: micHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "secureelement.proto",
+}
+
+func micHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(MICRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	handle := func(ctx context.Context, req interface{}) (interface{}, error) {
+		in := req.(*MICRequest)
+		var appEUI types.AppEUI
+		var devEUI types.DevEUI
+		if err := appEUI.Unmarshal(in.AppEUI); err != nil {
+			return nil, errors.BuildGRPCError(err)
+		}
+		if err := devEUI.Unmarshal(in.DevEUI); err != nil {
+			return nil, errors.BuildGRPCError(err)
+		}
+		mic, err := srv.(Service).MIC(appEUI, devEUI, in.Data)
+		if err != nil {
+			return nil, errors.BuildGRPCError(err)
+		}
+		return &MICResponse{MIC: mic[:]}, nil
+	}
+	if interceptor == nil {
+		return handle(ctx, req)
+	}
+	return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: micMethod}, handle)
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package secureelement delegates the MIC calculation of devices to an external crypto service,
+// so that their NwkSKey never has to be loaded in the Broker or NetworkServer.
+package secureelement
+
+import (
+	"encoding/binary"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+)
+
+// Service calculates MICs with the NwkSKey of a device that it keeps in a secure element
+type Service interface {
+	// MIC returns the first 4 bytes of the AES-CMAC of the data with the NwkSKey of the device
+	MIC(appEUI types.AppEUI, devEUI types.DevEUI, data []byte) ([4]byte, error)
+}
+
+// MICData returns the B0 block followed by the message that the MIC of a LoRaWAN 1.0 data message is calculated over.
+// The full 32-bit FCnt must be set in the MACPayload.
+func MICData(phyPayload lorawan.PHYPayload) ([]byte, error) {
+	macPayload, ok := phyPayload.MACPayload.(*lorawan.MACPayload)
+	if !ok {
+		return nil, errors.NewErrInvalidArgument("PHYPayload", "does not contain a MAC payload")
+	}
+	bytes, err := phyPayload.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	msg := bytes[:len(bytes)-4] // without MIC
+
+	data := make([]byte, 16, 16+len(msg))
+	data[0] = 0x49
+	switch phyPayload.MHDR.MType {
+	case lorawan.UnconfirmedDataUp, lorawan.ConfirmedDataUp:
+	case lorawan.UnconfirmedDataDown, lorawan.ConfirmedDataDown:
+		data[5] = 0x01
+	default:
+		return nil, errors.NewErrInvalidArgument("PHYPayload", "is not a data message")
+	}
+	devAddr := macPayload.FHDR.DevAddr
+	data[6], data[7], data[8], data[9] = devAddr[3], devAddr[2], devAddr[1], devAddr[0]
+	binary.LittleEndian.PutUint32(data[10:14], macPayload.FHDR.FCnt)
+	data[15] = byte(len(msg))
+	return append(data, msg...), nil
+}
+
+// ValidateMIC validates the MIC of the data message with the service
+func ValidateMIC(service Service, appEUI types.AppEUI, devEUI types.DevEUI, phyPayload lorawan.PHYPayload) (bool, error) {
+	data, err := MICData(phyPayload)
+	if err != nil {
+		return false, err
+	}
+	mic, err := service.MIC(appEUI, devEUI, data)
+	if err != nil {
+		return false, err
+	}
+	return mic == phyPayload.MIC, nil
+}
+
+// SetMIC sets the MIC of the data message with the service
+func SetMIC(service Service, appEUI types.AppEUI, devEUI types.DevEUI, phyPayload *lorawan.PHYPayload) error {
+	data, err := MICData(*phyPayload)
+	if err != nil {
+		return err
+	}
+	mic, err := service.MIC(appEUI, devEUI, data)
+	if err != nil {
+		return err
+	}
+	phyPayload.MIC = mic
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package secureelement
+
+import (
+	"net"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/brocaar/lorawan"
+	"github.com/jacobsa/crypto/cmac"
+	. "github.com/smartystreets/assertions"
+	"google.golang.org/grpc"
+)
+
+// softwareService keeps the keys in memory
+type softwareService map[types.DevEUI]types.NwkSKey
+
+func (s softwareService) MIC(appEUI types.AppEUI, devEUI types.DevEUI, data []byte) (mic [4]byte, err error) {
+	key, ok := s[devEUI]
+	if !ok {
+		return mic, errors.NewErrNotFound(devEUI.String())
+	}
+	hash, err := cmac.New(key[:])
+	if err != nil {
+		return mic, err
+	}
+	hash.Write(data)
+	copy(mic[:], hash.Sum(nil))
+	return mic, nil
+}
+
+func testPHYPayload(mType lorawan.MType, fCnt uint32) lorawan.PHYPayload {
+	fPort := uint8(1)
+	return lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: mType, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.MACPayload{
+			FHDR: lorawan.FHDR{
+				DevAddr: lorawan.DevAddr{0x26, 0x01, 0x02, 0x03},
+				FCnt:    fCnt,
+			},
+			FPort:      &fPort,
+			FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: []byte{0x01, 0x02, 0x03}}},
+		},
+	}
+}
+
+func TestMIC(t *testing.T) {
+	a := New(t)
+
+	devEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}
+	nwkSKey := types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	service := softwareService{devEUI: nwkSKey}
+
+	for _, mType := range []lorawan.MType{lorawan.UnconfirmedDataUp, lorawan.ConfirmedDataDown} {
+		for _, fCnt := range []uint32{1, 0x10001} {
+			expected := testPHYPayload(mType, fCnt)
+			expected.SetMIC(lorawan.AES128Key(nwkSKey))
+
+			phy := testPHYPayload(mType, fCnt)
+			err := SetMIC(service, types.AppEUI{}, devEUI, &phy)
+			a.So(err, ShouldBeNil)
+			a.So(phy.MIC, ShouldEqual, expected.MIC)
+
+			ok, err := ValidateMIC(service, types.AppEUI{}, devEUI, expected)
+			a.So(err, ShouldBeNil)
+			a.So(ok, ShouldBeTrue)
+		}
+	}
+
+	_, err := ValidateMIC(service, types.AppEUI{}, types.DevEUI{1}, testPHYPayload(lorawan.UnconfirmedDataUp, 1))
+	a.So(err, ShouldNotBeNil)
+
+	_, err = MICData(lorawan.PHYPayload{MHDR: lorawan.MHDR{MType: lorawan.JoinRequest}, MACPayload: &lorawan.JoinRequestPayload{}})
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestClient(t *testing.T) {
+	a := New(t)
+
+	devEUI := types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8}
+	nwkSKey := types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	a.So(err, ShouldBeNil)
+	s := grpc.NewServer()
+	RegisterServer(s, softwareService{devEUI: nwkSKey})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	a.So(err, ShouldBeNil)
+	defer conn.Close()
+	client := NewClient(conn)
+
+	phy := testPHYPayload(lorawan.UnconfirmedDataUp, 42)
+	phy.SetMIC(lorawan.AES128Key(nwkSKey))
+	ok, err := ValidateMIC(client, types.AppEUI{}, devEUI, phy)
+	a.So(err, ShouldBeNil)
+	a.So(ok, ShouldBeTrue)
+
+	_, err = ValidateMIC(client, types.AppEUI{}, types.DevEUI{1}, phy)
+	a.So(err, ShouldNotBeNil)
+}
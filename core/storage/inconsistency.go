@@ -0,0 +1,18 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import "sort"
+
+// Inconsistency is a problem that a consistency check found in the data of a store
+type Inconsistency struct {
+	Key      string // the key of the record, without the prefix of the store
+	Problem  string
+	Repaired bool
+}
+
+// SortInconsistencies sorts the inconsistencies by key
+func SortInconsistencies(inconsistencies []Inconsistency) {
+	sort.SliceStable(inconsistencies, func(i, j int) bool { return inconsistencies[i].Key < inconsistencies[j].Key })
+}
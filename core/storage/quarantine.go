@@ -0,0 +1,164 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/redis.v5"
+)
+
+// QuarantinePrefix is the prefix of the keys where corrupted entries are quarantined
+const QuarantinePrefix = "quarantine"
+
+var quarantinedCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "ttn",
+		Subsystem: "storage",
+		Name:      "quarantined_entries_total",
+		Help:      "Number of corrupted entries that were moved to the quarantine, by store.",
+	}, []string{"store"},
+)
+
+func init() {
+	prometheus.MustRegister(quarantinedCounter)
+}
+
+// SetSalvage makes the store quarantine corrupted entries instead of failing on them. Entries get corrupted by partial
+// writes, crashes or other processes that write to the same database. When salvaging, lists skip the corrupted
+// entries and getting a corrupted entry returns a NotFound error, so that components keep booting.
+func (s *RedisStore) SetSalvage(salvage bool) {
+	s.salvage = salvage
+}
+
+// quarantine moves the corrupted entry with the key to the quarantine, and logs and counts it. It returns false if the
+// entry could not be quarantined, in which case the caller should fail on the corrupted entry.
+func (s *RedisStore) quarantine(key string, reason error) bool {
+	ctx := ttnlog.Get().WithField("Key", key)
+	err := Quarantine(s.client, key, reason)
+	if errors.GetErrType(err) == errors.NotFound {
+		return true // Deleted in the meantime
+	}
+	if err != nil {
+		ctx.WithError(err).Warn("Could not quarantine corrupted entry")
+		return false
+	}
+	quarantinedCounter.WithLabelValues(strings.TrimSuffix(s.prefix, ":")).Inc()
+	ctx.WithError(reason).Warn("Quarantined corrupted entry")
+	return true
+}
+
+// QuarantinedEntry is a corrupted entry with the diagnostics of its quarantine
+type QuarantinedEntry struct {
+	RedisEntry
+	Error         string    `json:"error"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// corruptedError is the error of an entry that can not be decoded
+type corruptedError struct {
+	error
+}
+
+// isWrongType returns true if the error is returned for a key that holds another type than the store uses
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
+func quarantineKey(key string) string {
+	return QuarantinePrefix + ":" + key
+}
+
+// Quarantine moves the corrupted entry with the key to the quarantine, together with the reason that it is corrupted.
+// The entry is watched, so that an entry that is written again while it is quarantined is not lost.
+func Quarantine(client *redis.Client, key string, reason error) error {
+	return client.Watch(func(tx *redis.Tx) error {
+		value, err := tx.Dump(key).Result()
+		if err == redis.Nil {
+			return errors.NewErrNotFound(key)
+		}
+		if err != nil {
+			return err
+		}
+		ttl, err := tx.PTTL(key).Result()
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+		_, err = tx.Pipelined(func(pipe *redis.Pipeline) error {
+			pipe.HMSet(quarantineKey(key), map[string]string{
+				"key":            key,
+				"ttl":            strconv.FormatInt(int64(ttl), 10),
+				"value":          value,
+				"error":          reason.Error(),
+				"quarantined_at": time.Now().UTC().Format(time.RFC3339Nano),
+			})
+			pipe.Del(key)
+			return nil
+		})
+		return err
+	}, key)
+}
+
+// ListQuarantine returns the quarantined entries
+func ListQuarantine(client *redis.Client) (entries []*QuarantinedEntry, err error) {
+	store := NewRedisStore(client, QuarantinePrefix)
+	keys, err := store.Keys("")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		entry, err := GetQuarantine(client, strings.TrimPrefix(key, QuarantinePrefix+":"))
+		if errors.GetErrType(err) == errors.NotFound {
+			continue // Released since Keys
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetQuarantine returns the quarantined entry with the key
+func GetQuarantine(client *redis.Client, key string) (*QuarantinedEntry, error) {
+	res, err := client.HGetAll(quarantineKey(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, errors.NewErrNotFound(quarantineKey(key))
+	}
+	ttl, _ := strconv.ParseInt(res["ttl"], 10, 64)
+	quarantinedAt, _ := time.Parse(time.RFC3339Nano, res["quarantined_at"])
+	return &QuarantinedEntry{
+		RedisEntry: RedisEntry{
+			Key:   res["key"],
+			TTL:   time.Duration(ttl),
+			Value: []byte(res["value"]),
+		},
+		Error:         res["error"],
+		QuarantinedAt: quarantinedAt,
+	}, nil
+}
+
+// ReleaseQuarantine restores the quarantined entry with the key, for example after it was repaired in a copy of the
+// database. It replaces the existing entry if replace is true.
+func ReleaseQuarantine(client *redis.Client, key string, replace bool) error {
+	entry, err := GetQuarantine(client, key)
+	if err != nil {
+		return err
+	}
+	if err := RestoreRedis(client, &entry.RedisEntry, replace); err != nil {
+		return err
+	}
+	return client.Del(quarantineKey(key)).Err()
+}
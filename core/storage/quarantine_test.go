@@ -0,0 +1,90 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestQuarantine(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+	s := NewRedisMapStore(c, "test-quarantine")
+	s.SetBase(testRedisStruct{}, "")
+
+	defer func() {
+		c.Del("test-quarantine:ok", "test-quarantine:corrupt", "test-quarantine:wrongtype")
+		c.Del(quarantineKey("test-quarantine:corrupt"), quarantineKey("test-quarantine:wrongtype"))
+	}()
+	c.HMSet("test-quarantine:ok", map[string]string{"name": "ok"})
+	c.HMSet("test-quarantine:corrupt", map[string]string{"name": "corrupt", "updated_at": "not a time"})
+	c.Set("test-quarantine:wrongtype", "value", 0)
+
+	// Without salvaging, the corrupted entries are errors
+	{
+		_, err := s.List("", nil)
+		a.So(err, ShouldNotBeNil)
+		_, err = s.Get("corrupt")
+		a.So(err, ShouldNotBeNil)
+		a.So(errors.GetErrType(err), ShouldNotEqual, errors.NotFound)
+	}
+
+	s.SetSalvage(true)
+
+	// List skips and quarantines the corrupted entries
+	{
+		res, err := s.List("", nil)
+		a.So(err, ShouldBeNil)
+		a.So(res, ShouldHaveLength, 1)
+		a.So(res[0].(testRedisStruct).Name, ShouldEqual, "ok")
+
+		exists, _ := c.Exists("test-quarantine:corrupt").Result()
+		a.So(exists, ShouldBeFalse)
+	}
+
+	// The quarantine contains the diagnostics
+	{
+		entry, err := GetQuarantine(c, "test-quarantine:corrupt")
+		a.So(err, ShouldBeNil)
+		a.So(entry.Key, ShouldEqual, "test-quarantine:corrupt")
+		a.So(entry.Error, ShouldNotBeEmpty)
+		a.So(entry.QuarantinedAt.IsZero(), ShouldBeFalse)
+
+		entry, err = GetQuarantine(c, "test-quarantine:wrongtype")
+		a.So(err, ShouldBeNil)
+		a.So(entry.Error, ShouldContainSubstring, "WRONGTYPE")
+
+		entries, err := ListQuarantine(c)
+		a.So(err, ShouldBeNil)
+		a.So(len(entries), ShouldBeGreaterThanOrEqualTo, 2)
+	}
+
+	// Entries that no longer exist can not be quarantined
+	{
+		err := Quarantine(c, "test-quarantine:missing", errors.New("corrupted"))
+		a.So(errors.GetErrType(err), ShouldEqual, errors.NotFound)
+	}
+
+	// Release
+	{
+		err := ReleaseQuarantine(c, "test-quarantine:corrupt", false)
+		a.So(err, ShouldBeNil)
+		name, _ := c.HGet("test-quarantine:corrupt", "name").Result()
+		a.So(name, ShouldEqual, "corrupt")
+
+		_, err = GetQuarantine(c, "test-quarantine:corrupt")
+		a.So(errors.GetErrType(err), ShouldEqual, errors.NotFound)
+	}
+
+	// Get quarantines the corrupted entry again
+	{
+		_, err := s.Get("corrupt")
+		a.So(errors.GetErrType(err), ShouldEqual, errors.NotFound)
+		_, err = GetQuarantine(c, "test-quarantine:corrupt")
+		a.So(err, ShouldBeNil)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+// RedisEntry is a key that is serialized with the Redis DUMP command
+type RedisEntry struct {
+	Key   string        `json:"key"`
+	TTL   time.Duration `json:"ttl,omitempty"`
+	Value []byte        `json:"value"`
+}
+
+// DumpRedis calls the function for every key that matches the selector
+func DumpRedis(client *redis.Client, selector string, fn func(entry *RedisEntry) error) error {
+	if selector == "" {
+		selector = "*"
+	}
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, selector, 0).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			entry, err := DumpRedisKey(client, key)
+			if err == redis.Nil {
+				continue // Deleted since Scan started
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// DumpRedisKey serializes a single key
+func DumpRedisKey(client *redis.Client, key string) (*RedisEntry, error) {
+	pipe := client.Pipeline()
+	defer pipe.Close()
+	dump := pipe.Dump(key)
+	ttl := pipe.PTTL(key)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	entry := &RedisEntry{Key: key}
+	value, err := dump.Result()
+	if err != nil {
+		return nil, err
+	}
+	entry.Value = []byte(value)
+	if ttl := ttl.Val(); ttl > 0 {
+		entry.TTL = ttl
+	}
+	return entry, nil
+}
+
+// RestoreRedis restores a serialized key, replacing the existing key if replace is true
+func RestoreRedis(client *redis.Client, entry *RedisEntry, replace bool) error {
+	if replace {
+		return client.RestoreReplace(entry.Key, entry.TTL, string(entry.Value)).Err()
+	}
+	return client.Restore(entry.Key, entry.TTL, string(entry.Value)).Err()
+}
+
+// VerifyRedis returns an error if the key in the client does not have the same value as the entry
+func VerifyRedis(client *redis.Client, entry *RedisEntry) error {
+	dump, err := client.Dump(entry.Key).Result()
+	if err == redis.Nil {
+		return fmt.Errorf("Key %s does not exist", entry.Key)
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal([]byte(dump), entry.Value) {
+		return fmt.Errorf("Key %s has a different value", entry.Key)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRedisDump(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+
+	defer c.Del("test-redis-dump:hash", "test-redis-dump:string", "test-redis-dump:list")
+	c.HMSet("test-redis-dump:hash", map[string]string{"foo": "bar"})
+	c.Set("test-redis-dump:string", "value", time.Hour)
+	c.RPush("test-redis-dump:list", "a", "b")
+
+	var entries []*RedisEntry
+	err := DumpRedis(c, "test-redis-dump:*", func(entry *RedisEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	a.So(entries, ShouldHaveLength, 3)
+
+	for _, entry := range entries {
+		a.So(VerifyRedis(c, entry), ShouldBeNil)
+		if entry.Key == "test-redis-dump:string" {
+			a.So(entry.TTL, ShouldBeGreaterThan, 0)
+		}
+	}
+
+	// Restore existing
+	a.So(RestoreRedis(c, entries[0], false), ShouldNotBeNil)
+	a.So(RestoreRedis(c, entries[0], true), ShouldBeNil)
+
+	// Restore deleted
+	c.Del("test-redis-dump:list")
+	for _, entry := range entries {
+		if entry.Key == "test-redis-dump:list" {
+			a.So(VerifyRedis(c, entry), ShouldNotBeNil)
+			a.So(RestoreRedis(c, entry, false), ShouldBeNil)
+			a.So(VerifyRedis(c, entry), ShouldBeNil)
+		}
+	}
+	list, err := c.LRange("test-redis-dump:list", 0, -1).Result()
+	a.So(err, ShouldBeNil)
+	a.So(list, ShouldResemble, []string{"a", "b"})
+}
@@ -56,7 +56,7 @@ func (s *RedisKVStore) GetAll(keys []string, options *ListOptions) (map[string]s
 
 	// Execute pipeline
 	_, err := pipe.Exec()
-	if err != nil {
+	if err != nil && !(s.salvage && isWrongType(err)) {
 		return nil, err
 	}
 
@@ -64,6 +64,12 @@ func (s *RedisKVStore) GetAll(keys []string, options *ListOptions) (map[string]s
 	data := make(map[string]string)
 	for key, cmd := range cmds {
 		res, err := cmd.Result()
+		if s.salvage && isWrongType(err) {
+			if !s.quarantine(key, err) {
+				return nil, err
+			}
+			continue
+		}
 		if err == nil {
 			data[strings.TrimPrefix(key, s.prefix)] = res
 		}
@@ -13,6 +13,11 @@ import (
 )
 
 // RedisMapStore stores structs as HMaps in Redis
+//
+// Every struct field is stored in its own hash field, so fields can be added to a struct without
+// breaking existing records: missing fields decode to their zero value and unknown fields are
+// ignored (and preserved when only the changed fields are set). Changes that are not backwards
+// compatible should increase the DBVersion of the struct and add a migration with AddMigration.
 type RedisMapStore struct {
 	*RedisStore
 	encoder    func(input interface{}, properties ...string) (map[string]string, error)
@@ -85,24 +90,43 @@ func (s *RedisMapStore) GetAll(keys []string, options *ListOptions) ([]interface
 
 	// Execute pipeline
 	_, err := pipe.Exec()
-	if err != nil {
+	if err != nil && !(s.salvage && isWrongType(err)) {
 		return nil, err
 	}
 
 	// Get all results from pipeline
-	results := make([]interface{}, len(selectedKeys))
-	for i, key := range selectedKeys {
-		if result, err := cmds[key].Result(); err == nil {
-			result, _ = s.migrate(key, result)
-			if result, err := s.decoder(result); err == nil {
-				results[i] = result
+	results := make([]interface{}, 0, len(selectedKeys))
+	for _, key := range selectedKeys {
+		result, err := s.result(key, cmds[key])
+		if _, corrupted := err.(corruptedError); corrupted && s.salvage {
+			if !s.quarantine(key, err) {
+				return nil, err
 			}
+			continue
 		}
+		results = append(results, result)
 	}
 
 	return results, nil
 }
 
+// result migrates and decodes the result of HGetAll on the key
+func (s *RedisMapStore) result(key string, cmd *redis.StringStringMapCmd) (interface{}, error) {
+	result, err := cmd.Result()
+	if isWrongType(err) {
+		return nil, corruptedError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result, _ = s.migrate(key, result)
+	i, err := s.decoder(result)
+	if err != nil {
+		return nil, corruptedError{err}
+	}
+	return i, nil
+}
+
 // List all results matching the selector, prepending the prefix to the selector if necessary
 func (s *RedisMapStore) List(selector string, options *ListOptions) ([]interface{}, error) {
 	allKeys, err := s.Keys(selector)
@@ -118,15 +142,16 @@ func (s *RedisMapStore) Get(key string) (interface{}, error) {
 	if !strings.HasPrefix(key, s.prefix) {
 		key = s.prefix + key
 	}
-	result, err := s.client.HGetAll(key).Result()
-	if err == redis.Nil || len(result) == 0 {
+	cmd := s.client.HGetAll(key)
+	if err := cmd.Err(); err == redis.Nil || (err == nil && len(cmd.Val()) == 0) {
 		return nil, errors.NewErrNotFound(key)
 	}
-	if err != nil {
-		return nil, err
+	i, err := s.result(key, cmd)
+	if _, corrupted := err.(corruptedError); corrupted && s.salvage {
+		if s.quarantine(key, err) {
+			return nil, errors.NewErrNotFound(key)
+		}
 	}
-	result, _ = s.migrate(key, result)
-	i, err := s.decoder(result)
 	if err != nil {
 		return nil, err
 	}
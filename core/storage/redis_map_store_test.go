@@ -203,3 +203,35 @@ func TestRedisMapStore(t *testing.T) {
 	}
 
 }
+
+func TestRedisMapStoreSchemaEvolution(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+	s := NewRedisMapStore(c, "test-redis-map-store-evolution")
+	s.SetBase(testRedisStruct{}, "")
+
+	defer c.Del("test-redis-map-store-evolution:old", "test-redis-map-store-evolution:new")
+
+	// Written by an older version that did not have the EmptyStr field
+	c.HMSet("test-redis-map-store-evolution:old", map[string]string{"name": "Old"})
+
+	// Written by a newer version that has a field that this version does not know
+	c.HMSet("test-redis-map-store-evolution:new", map[string]string{"name": "New", "EmptyStr": "value", "added_later": "value"})
+
+	res, err := s.Get("old")
+	a.So(err, ShouldBeNil)
+	a.So(res.(testRedisStruct).Name, ShouldEqual, "Old")
+	a.So(res.(testRedisStruct).EmptyStr, ShouldBeEmpty)
+
+	res, err = s.Get("new")
+	a.So(err, ShouldBeNil)
+	a.So(res.(testRedisStruct).Name, ShouldEqual, "New")
+	a.So(res.(testRedisStruct).EmptyStr, ShouldEqual, "value")
+
+	// Setting only the changed fields keeps the unknown field
+	err = s.Set("new", testRedisStruct{Name: "Newer"}, "Name")
+	a.So(err, ShouldBeNil)
+	added, err := c.HGet("test-redis-map-store-evolution:new", "added_later").Result()
+	a.So(err, ShouldBeNil)
+	a.So(added, ShouldEqual, "value")
+}
@@ -55,7 +55,7 @@ func (s *RedisQueueStore) GetAll(keys []string, options *ListOptions) (map[strin
 
 	// Execute pipeline
 	_, err := pipe.Exec()
-	if err != nil {
+	if err != nil && !(s.salvage && isWrongType(err)) {
 		return nil, err
 	}
 
@@ -63,6 +63,12 @@ func (s *RedisQueueStore) GetAll(keys []string, options *ListOptions) (map[strin
 	data := make(map[string][]string)
 	for key, cmd := range cmds {
 		res, err := cmd.Result()
+		if s.salvage && isWrongType(err) {
+			if !s.quarantine(key, err) {
+				return nil, err
+			}
+			continue
+		}
 		if err == nil {
 			data[strings.TrimPrefix(key, s.prefix)] = res
 		}
@@ -169,6 +175,18 @@ func (s *RedisQueueStore) Next(key string) (string, error) {
 	return res, err
 }
 
+// Remove the first occurrence of the value from the queue, prepending the prefix to the key if necessary
+func (s *RedisQueueStore) Remove(key string, value string) (removed bool, err error) {
+	if !strings.HasPrefix(key, s.prefix) {
+		key = s.prefix + key
+	}
+	res, err := s.client.LRem(key, 1, value).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return res > 0, err
+}
+
 // Trim the length of the queue
 func (s *RedisQueueStore) Trim(key string, length int) error {
 	if !strings.HasPrefix(key, s.prefix) {
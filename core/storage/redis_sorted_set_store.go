@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/redis.v5"
+)
+
+// RedisSortedSetStore stores sorted sets in Redis
+type RedisSortedSetStore struct {
+	*RedisStore
+}
+
+// NewRedisSortedSetStore creates a new RedisSortedSetStore
+func NewRedisSortedSetStore(client *redis.Client, prefix string) *RedisSortedSetStore {
+	if !strings.HasSuffix(prefix, ":") {
+		prefix += ":"
+	}
+	return &RedisSortedSetStore{
+		RedisStore: NewRedisStore(client, prefix),
+	}
+}
+
+// Add adds the value to the sorted set or updates its score, prepending the prefix to the key if necessary
+func (s *RedisSortedSetStore) Add(key string, score float64, value string) error {
+	return s.client.ZAdd(s.Key(key), redis.Z{Score: score, Member: value}).Err()
+}
+
+// GetUntil returns the values with a score up to and including max, lowest score first, prepending the prefix to
+// the key if necessary
+func (s *RedisSortedSetStore) GetUntil(key string, max float64) ([]string, error) {
+	res, err := s.client.ZRangeByScore(s.Key(key), redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatFloat(max, 'f', -1, 64),
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return res, err
+}
+
+// Remove removes the value from the sorted set and returns whether it was in the set, prepending the prefix to the
+// key if necessary. Of concurrent processes that remove the same value, only one gets true.
+func (s *RedisSortedSetStore) Remove(key string, value string) (bool, error) {
+	res, err := s.client.ZRem(s.Key(key), value).Result()
+	return res > 0, err
+}
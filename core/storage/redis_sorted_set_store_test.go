@@ -0,0 +1,60 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRedisSortedSetStore(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+	s := NewRedisSortedSetStore(c, "test-redis-sorted-set-store")
+	a.So(s, ShouldNotBeNil)
+
+	defer func() {
+		c.Del("test-redis-sorted-set-store:test").Result()
+	}()
+
+	// Get non-existing
+	{
+		res, err := s.GetUntil("test", 100)
+		a.So(err, ShouldBeNil)
+		a.So(res, ShouldBeEmpty)
+	}
+
+	// Add
+	{
+		a.So(s.Add("test", 30, "late"), ShouldBeNil)
+		a.So(s.Add("test", 20, "early"), ShouldBeNil)
+		a.So(s.Add("test", 10, "earliest"), ShouldBeNil)
+	}
+
+	// Get until a score, lowest first
+	{
+		res, err := s.GetUntil("test", 20)
+		a.So(err, ShouldBeNil)
+		a.So(res, ShouldResemble, []string{"earliest", "early"})
+	}
+
+	// Update the score
+	{
+		a.So(s.Add("test", 40, "earliest"), ShouldBeNil)
+		res, err := s.GetUntil("test", 20)
+		a.So(err, ShouldBeNil)
+		a.So(res, ShouldResemble, []string{"early"})
+	}
+
+	// Remove
+	{
+		removed, err := s.Remove("test", "early")
+		a.So(err, ShouldBeNil)
+		a.So(removed, ShouldBeTrue)
+		removed, err = s.Remove("test", "early")
+		a.So(err, ShouldBeNil)
+		a.So(removed, ShouldBeFalse)
+	}
+}
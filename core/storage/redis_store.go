@@ -11,8 +11,9 @@ import (
 
 // RedisStore is the base of more specialized stores
 type RedisStore struct {
-	prefix string
-	client *redis.Client
+	prefix  string
+	client  *redis.Client
+	salvage bool
 }
 
 // NewRedisStore creates a new RedisStore
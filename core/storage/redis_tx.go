@@ -0,0 +1,183 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"gopkg.in/redis.v5"
+)
+
+// MaxTransactionRetries is the number of times a transaction is retried if a watched key was changed
+var MaxTransactionRetries = 10
+
+// RedisTx is a transaction over one or more Redis stores. Reads are executed immediately,
+// writes are queued and committed atomically at the end of the transaction.
+type RedisTx struct {
+	tx     *redis.Tx
+	writes []func(pipe *redis.Pipeline)
+}
+
+func (t *RedisTx) queue(write func(pipe *redis.Pipeline)) {
+	t.writes = append(t.writes, write)
+}
+
+// Key returns the key with the prefix of the store
+func (s *RedisStore) Key(key string) string {
+	if !strings.HasPrefix(key, s.prefix) {
+		key = s.prefix + key
+	}
+	return key
+}
+
+// Transaction runs fn in a transaction that watches the given (full) keys. If one of the keys
+// is changed by a concurrent process before the transaction is committed, fn is called again.
+func (s *RedisStore) Transaction(fn func(tx *RedisTx) error, keys ...string) (err error) {
+	for i := 0; i < MaxTransactionRetries; i++ {
+		err = s.client.Watch(func(tx *redis.Tx) error {
+			t := &RedisTx{tx: tx}
+			if err := fn(t); err != nil {
+				return err
+			}
+			if len(t.writes) == 0 {
+				return nil
+			}
+			_, err := tx.Pipelined(func(pipe *redis.Pipeline) error {
+				for _, write := range t.writes {
+					write(pipe)
+				}
+				return nil
+			})
+			return err
+		}, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+// ExistsTx returns whether the key exists in the transaction, prepending the prefix to the key if necessary
+func (s *RedisStore) ExistsTx(tx *RedisTx, key string) (bool, error) {
+	return tx.tx.Exists(s.Key(key)).Result()
+}
+
+// DeleteTx deletes the key in the transaction, prepending the prefix to the key if necessary
+func (s *RedisStore) DeleteTx(tx *RedisTx, key string) {
+	key = s.Key(key)
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.Del(key)
+	})
+}
+
+// GetTx returns a record in the transaction, prepending the prefix to the key if necessary
+func (s *RedisMapStore) GetTx(tx *RedisTx, key string) (interface{}, error) {
+	key = s.Key(key)
+	cmd := tx.tx.HGetAll(key)
+	if err := cmd.Err(); err == redis.Nil || (err == nil && len(cmd.Val()) == 0) {
+		return nil, errors.NewErrNotFound(key)
+	}
+	return s.result(key, cmd)
+}
+
+// GetFieldsTx returns a record with only the given fields in the transaction, prepending the prefix to the key if necessary
+func (s *RedisMapStore) GetFieldsTx(tx *RedisTx, key string, fields ...string) (interface{}, error) {
+	key = s.Key(key)
+	result, err := tx.tx.HMGet(key, fields...).Result()
+	if err == redis.Nil {
+		return nil, errors.NewErrNotFound(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for i, field := range fields {
+		if str, ok := result[i].(string); ok {
+			res[field] = str
+		}
+	}
+	return s.decoder(res)
+}
+
+// SetTx sets a record in the transaction, prepending the prefix to the key if necessary, optionally setting only the given properties
+func (s *RedisMapStore) SetTx(tx *RedisTx, key string, value interface{}, properties ...string) error {
+	_, vmap, err := s.prepare(key, value, properties...)
+	if err != nil {
+		return err
+	}
+	if len(vmap) == 0 {
+		return nil
+	}
+	key = s.Key(key)
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.HMSet(key, vmap)
+	})
+	return nil
+}
+
+// NextTx returns the first element of the queue and removes it in the transaction, prepending the prefix to the key if necessary
+func (s *RedisQueueStore) NextTx(tx *RedisTx, key string) (string, error) {
+	key = s.Key(key)
+	res, err := tx.tx.LIndex(key, 0).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.LPop(key)
+	})
+	return res, nil
+}
+
+// GetTx returns all elements of the queue in the transaction, prepending the prefix to the key if necessary
+func (s *RedisQueueStore) GetTx(tx *RedisTx, key string) ([]string, error) {
+	res, err := tx.tx.LRange(s.Key(key), 0, -1).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return res, err
+}
+
+// RemoveTx removes the first occurrence of the value from the queue in the transaction, prepending the prefix to the key if necessary
+func (s *RedisQueueStore) RemoveTx(tx *RedisTx, key string, value string) {
+	key = s.Key(key)
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.LRem(key, 1, value)
+	})
+}
+
+// AddTx adds one or more values to the set in the transaction, prepending the prefix to the key if necessary
+func (s *RedisSetStore) AddTx(tx *RedisTx, key string, values ...string) {
+	key = s.Key(key)
+	valuesI := make([]interface{}, len(values))
+	for i, v := range values {
+		valuesI[i] = v
+	}
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.SAdd(key, valuesI...)
+	})
+}
+
+// RemoveTx removes one or more values from the set in the transaction, prepending the prefix to the key if necessary
+func (s *RedisSetStore) RemoveTx(tx *RedisTx, key string, values ...string) {
+	key = s.Key(key)
+	valuesI := make([]interface{}, len(values))
+	for i, v := range values {
+		valuesI[i] = v
+	}
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.SRem(key, valuesI...)
+	})
+}
+
+// RemoveTx removes the value from the sorted set in the transaction, prepending the prefix to the key if necessary
+func (s *RedisSortedSetStore) RemoveTx(tx *RedisTx, key string, value string) {
+	key = s.Key(key)
+	tx.queue(func(pipe *redis.Pipeline) {
+		pipe.ZRem(key, value)
+	})
+}
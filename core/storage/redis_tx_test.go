@@ -0,0 +1,61 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestRedisTransaction(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+
+	maps := NewRedisMapStore(c, "test-redis-tx-map")
+	maps.SetBase(testRedisStruct{}, "")
+	queues := NewRedisQueueStore(c, "test-redis-tx-queue")
+
+	defer c.Del("test-redis-tx-map:test", "test-redis-tx-queue:test")
+	queues.AddEnd("test", "first", "second")
+
+	a.So(maps.Key("test"), ShouldEqual, "test-redis-tx-map:test")
+	a.So(maps.Key("test-redis-tx-map:test"), ShouldEqual, "test-redis-tx-map:test")
+
+	// Failing transaction does not commit anything
+	err := maps.Transaction(func(tx *RedisTx) error {
+		next, err := queues.NextTx(tx, "test")
+		a.So(err, ShouldBeNil)
+		a.So(next, ShouldEqual, "first")
+		return errors.New("failed")
+	}, maps.Key("test"), queues.Key("test"))
+	a.So(err, ShouldNotBeNil)
+	length, _ := queues.Length("test")
+	a.So(length, ShouldEqual, 2)
+
+	// Successful transaction commits all writes
+	err = maps.Transaction(func(tx *RedisTx) error {
+		next, err := queues.NextTx(tx, "test")
+		if err != nil {
+			return err
+		}
+		return maps.SetTx(tx, "test", testRedisStruct{Name: next})
+	}, maps.Key("test"), queues.Key("test"))
+	a.So(err, ShouldBeNil)
+	length, _ = queues.Length("test")
+	a.So(length, ShouldEqual, 1)
+	res, err := maps.Get("test")
+	a.So(err, ShouldBeNil)
+	a.So(res.(testRedisStruct).Name, ShouldEqual, "first")
+
+	// Empty queue
+	c.Del("test-redis-tx-queue:test")
+	err = maps.Transaction(func(tx *RedisTx) error {
+		next, err := queues.NextTx(tx, "test")
+		a.So(next, ShouldBeEmpty)
+		return err
+	}, queues.Key("test"))
+	a.So(err, ShouldBeNil)
+}
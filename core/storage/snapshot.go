@@ -0,0 +1,112 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+// SnapshotVersion is the version of the snapshot format
+const SnapshotVersion = 1
+
+// SnapshotHeader is the first record in a snapshot
+type SnapshotHeader struct {
+	Version    int       `json:"version"`
+	TTNVersion string    `json:"ttn_version,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Prefixes   []string  `json:"prefixes"`
+}
+
+// Validate the header
+func (h *SnapshotHeader) Validate() error {
+	if h.Version != SnapshotVersion {
+		return fmt.Errorf("Unsupported snapshot version %d (expected %d)", h.Version, SnapshotVersion)
+	}
+	return nil
+}
+
+// A snapshot is a gzipped stream of JSON records: one header, the entries and an end record with the number of entries
+type snapshotRecord struct {
+	Header *SnapshotHeader `json:"header,omitempty"`
+	Entry  *RedisEntry     `json:"entry,omitempty"`
+	End    *snapshotEnd    `json:"end,omitempty"`
+}
+
+type snapshotEnd struct {
+	Keys int `json:"keys"`
+}
+
+// WriteSnapshot writes all keys with the prefixes in the header to the snapshot
+func WriteSnapshot(w io.Writer, client *redis.Client, header *SnapshotHeader) (keys int, err error) {
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	if err := enc.Encode(snapshotRecord{Header: header}); err != nil {
+		return 0, err
+	}
+	for _, prefix := range header.Prefixes {
+		err := DumpRedis(client, prefix+":*", func(entry *RedisEntry) error {
+			keys++
+			return enc.Encode(snapshotRecord{Entry: entry})
+		})
+		if err != nil {
+			return keys, err
+		}
+	}
+	if err := enc.Encode(snapshotRecord{End: &snapshotEnd{Keys: keys}}); err != nil {
+		return keys, err
+	}
+	return keys, gz.Close()
+}
+
+// ReadSnapshot validates the header of the snapshot and calls the function for every entry.
+// It returns an error if the snapshot is incomplete.
+func ReadSnapshot(r io.Reader, fn func(entry *RedisEntry) error) (*SnapshotHeader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	dec := json.NewDecoder(gz)
+
+	var record snapshotRecord
+	if err := dec.Decode(&record); err != nil {
+		return nil, err
+	}
+	header := record.Header
+	if header == nil {
+		return nil, fmt.Errorf("Snapshot has no header")
+	}
+	if err := header.Validate(); err != nil {
+		return header, err
+	}
+
+	var keys int
+	for {
+		var record snapshotRecord
+		if err := dec.Decode(&record); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return header, fmt.Errorf("Snapshot is incomplete after %d keys", keys)
+		} else if err != nil {
+			return header, err
+		}
+		if record.End != nil {
+			if record.End.Keys != keys {
+				return header, fmt.Errorf("Snapshot contains %d keys, expected %d", keys, record.End.Keys)
+			}
+			return header, nil
+		}
+		if record.Entry == nil {
+			continue
+		}
+		keys++
+		if err := fn(record.Entry); err != nil {
+			return header, err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestSnapshot(t *testing.T) {
+	a := New(t)
+	c := getRedisClient()
+
+	defer c.Del("test-snapshot:hash", "test-snapshot:string")
+	c.HMSet("test-snapshot:hash", map[string]string{"foo": "bar"})
+	c.Set("test-snapshot:string", "value", 0)
+
+	var buf bytes.Buffer
+	keys, err := WriteSnapshot(&buf, c, &SnapshotHeader{
+		Version:   SnapshotVersion,
+		CreatedAt: time.Now(),
+		Prefixes:  []string{"test-snapshot"},
+	})
+	a.So(err, ShouldBeNil)
+	a.So(keys, ShouldEqual, 2)
+
+	snapshot := buf.Bytes()
+
+	var entries []*RedisEntry
+	header, err := ReadSnapshot(bytes.NewReader(snapshot), func(entry *RedisEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	a.So(err, ShouldBeNil)
+	a.So(header.Prefixes, ShouldResemble, []string{"test-snapshot"})
+	a.So(entries, ShouldHaveLength, 2)
+
+	c.Del("test-snapshot:hash", "test-snapshot:string")
+	for _, entry := range entries {
+		a.So(RestoreRedis(c, entry, false), ShouldBeNil)
+	}
+	hash, _ := c.HGetAll("test-snapshot:hash").Result()
+	a.So(hash, ShouldResemble, map[string]string{"foo": "bar"})
+
+	// Truncated snapshot
+	_, err = ReadSnapshot(bytes.NewReader(snapshot[:len(snapshot)-20]), func(entry *RedisEntry) error { return nil })
+	a.So(err, ShouldNotBeNil)
+
+	// Unsupported version
+	buf.Reset()
+	WriteSnapshot(&buf, c, &SnapshotHeader{Version: SnapshotVersion + 1})
+	_, err = ReadSnapshot(&buf, func(entry *RedisEntry) error { return nil })
+	a.So(err, ShouldNotBeNil)
+}
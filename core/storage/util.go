@@ -17,23 +17,29 @@ func (o ListOptions) GetTotalAndSelected() (total, selected uint64) {
 	return o.total, o.selected
 }
 
-func selectKeys(keys []string, options *ListOptions) []string {
-	var start uint64
-	end := uint64(len(keys))
-	if options != nil {
-		options.total = end
-		if options.Offset >= options.total {
-			return []string{}
+// Select returns the range of a list with the given length that is selected by the options. It also sets
+// the numbers that are returned by GetTotalAndSelected.
+func (o *ListOptions) Select(length int) (start, end int) {
+	end = length
+	if o != nil {
+		o.total = uint64(length)
+		if o.Offset >= o.total {
+			return 0, 0
 		}
-		start = options.Offset
-		if options.Limit > 0 {
-			if options.Offset+options.Limit > options.total {
-				options.Limit = options.total - options.Offset
+		start = int(o.Offset)
+		if o.Limit > 0 {
+			if o.Offset+o.Limit > o.total {
+				o.Limit = o.total - o.Offset
 			}
-			end = options.Offset + options.Limit
+			end = int(o.Offset + o.Limit)
 		}
-		options.selected = end - start
+		o.selected = uint64(end - start)
 	}
+	return
+}
+
+func selectKeys(keys []string, options *ListOptions) []string {
+	start, end := options.Select(len(keys))
 	return keys[start:end]
 }
 
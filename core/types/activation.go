@@ -192,6 +192,20 @@ func (n NetID) IsEmpty() bool {
 	return n == emptyNetID
 }
 
+// netIDNwkIDBits is the number of bits of the NwkID for each NetID type, as defined by the LoRaWAN Backend Interfaces
+var netIDNwkIDBits = [8]uint{6, 6, 9, 11, 12, 13, 15, 17}
+
+// Type returns the type of the NetID (0-7), which determines the DevAddr prefix and the length of the NwkID
+func (n NetID) Type() int {
+	return int(n[0] >> 5)
+}
+
+// NwkID returns the NwkID of the NetID, which is the part of the NetID that is used in DevAddrs
+func (n NetID) NwkID() uint32 {
+	id := uint32(n[0])<<16 | uint32(n[1])<<8 | uint32(n[2])
+	return id & (1<<netIDNwkIDBits[n.Type()] - 1)
+}
+
 // GoString implements the GoStringer interface.
 func (n NetID) GoString() string {
 	return n.String()
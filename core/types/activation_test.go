@@ -182,3 +182,24 @@ func TestNetID(t *testing.T) {
 	a.So(err, ShouldBeNil)
 	a.So(uOut, ShouldResemble, &nid)
 }
+
+func TestNetIDType(t *testing.T) {
+	a := New(t)
+
+	for _, tt := range []struct {
+		netID NetID
+		typ   int
+		nwkID uint32
+	}{
+		{NetID{0x00, 0x00, 0x13}, 0, 0x13},
+		{NetID{0x20, 0x00, 0x05}, 1, 0x05},
+		{NetID{0x40, 0x01, 0x23}, 2, 0x123},
+		{NetID{0x60, 0x00, 0x00}, 3, 0x0},
+		{NetID{0x60, 0x0F, 0xFF}, 3, 0x7FF},
+		{NetID{0x80, 0x1F, 0xFF}, 4, 0xFFF},
+		{NetID{0xE0, 0x00, 0x01}, 7, 0x1},
+	} {
+		a.So(tt.netID.Type(), ShouldEqual, tt.typ)
+		a.So(tt.netID.NwkID(), ShouldEqual, tt.nwkID)
+	}
+}
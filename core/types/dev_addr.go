@@ -4,6 +4,7 @@
 package types
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"regexp"
@@ -106,6 +107,18 @@ func (addr DevAddr) IsEmpty() bool {
 	return addr == empty
 }
 
+// HasNetID returns true if the DevAddr is in the address space of the NetID. The DevAddr starts with a prefix of as
+// many 1-bits as the type of the NetID followed by a 0-bit, and continues with the NwkID of the NetID.
+func (addr DevAddr) HasNetID(netID NetID) bool {
+	prefixBits := uint(netID.Type() + 1)
+	nwkIDBits := netIDNwkIDBits[netID.Type()]
+	value := binary.BigEndian.Uint32(addr[:])
+	if value>>(32-prefixBits) != 1<<prefixBits-2 {
+		return false
+	}
+	return value>>(32-prefixBits-nwkIDBits)&(1<<nwkIDBits-1) == netID.NwkID()
+}
+
 // DevAddrPrefix is a DevAddr with a prefix length
 type DevAddrPrefix struct {
 	DevAddr DevAddr
@@ -114,6 +114,19 @@ func TestDevAddrHasPrefix(t *testing.T) {
 	a.So(addr.HasPrefix(DevAddrPrefix{DevAddr{1, 1, 1, 1}, 15}), ShouldBeFalse)
 }
 
+func TestDevAddrHasNetID(t *testing.T) {
+	a := New(t)
+	a.So(DevAddr{0x26, 0x01, 0x1B, 0xDA}.HasNetID(NetID{0x00, 0x00, 0x13}), ShouldBeTrue)
+	a.So(DevAddr{0x01, 0x02, 0x03, 0x04}.HasNetID(NetID{0x00, 0x00, 0x13}), ShouldBeFalse)
+	a.So(DevAddr{0x85, 0x01, 0x02, 0x03}.HasNetID(NetID{0x20, 0x00, 0x05}), ShouldBeTrue)
+	a.So(DevAddr{0x05, 0x01, 0x02, 0x03}.HasNetID(NetID{0x20, 0x00, 0x05}), ShouldBeFalse) // type 0 prefix
+	a.So(DevAddr{0xD2, 0x30, 0x00, 0x01}.HasNetID(NetID{0x40, 0x01, 0x23}), ShouldBeTrue)
+	a.So(DevAddr{0xE0, 0x00, 0x00, 0x01}.HasNetID(NetID{0x60, 0x00, 0x00}), ShouldBeTrue)
+	a.So(DevAddr{0xE0, 0x20, 0x00, 0x00}.HasNetID(NetID{0x60, 0x00, 0x00}), ShouldBeFalse)
+	a.So(DevAddr{0xFE, 0x00, 0x00, 0x80}.HasNetID(NetID{0xE0, 0x00, 0x01}), ShouldBeTrue)
+	a.So(DevAddr{0xFE, 0x00, 0x01, 0x00}.HasNetID(NetID{0xE0, 0x00, 0x01}), ShouldBeFalse)
+}
+
 func TestParseDevAddrPrefix(t *testing.T) {
 	a := New(t)
 	prefix, err := ParseDevAddrPrefix("XYZ")
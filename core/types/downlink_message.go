@@ -3,6 +3,8 @@
 
 package types
 
+import "time"
+
 // ScheduleType can be "replace" (default), "first", "last"
 type ScheduleType string
 
@@ -13,13 +15,105 @@ const (
 	ScheduleLast    ScheduleType = "last"
 )
 
+// DownlinkPriority can be "low", "normal" (default), "high"
+type DownlinkPriority string
+
+// DownlinkPriorities
+const (
+	DownlinkPriorityLow    DownlinkPriority = "low"
+	DownlinkPriorityNormal DownlinkPriority = "normal"
+	DownlinkPriorityHigh   DownlinkPriority = "high"
+)
+
+// Level of the priority, or -1 if the priority is unknown. Downlinks with a higher level are sent first.
+func (p DownlinkPriority) Level() int {
+	switch p {
+	case DownlinkPriorityLow:
+		return 0
+	case DownlinkPriorityNormal, "": // Empty string for default
+		return 1
+	case DownlinkPriorityHigh:
+		return 2
+	}
+	return -1
+}
+
 // DownlinkMessage represents an application-layer downlink message
 type DownlinkMessage struct {
 	AppID         string                 `json:"app_id,omitempty"`
 	DevID         string                 `json:"dev_id,omitempty"`
+	ID            string                 `json:"id,omitempty"` // set by the handler when the downlink is enqueued
 	FPort         uint8                  `json:"port"`
 	Confirmed     bool                   `json:"confirmed,omitempty"`
 	Schedule      ScheduleType           `json:"schedule,omitempty"` // allowed values: "replace" (default), "first", "last"
+	Priority      DownlinkPriority       `json:"priority,omitempty"` // allowed values: "low", "normal" (default), "high"
+	EarliestAt    *JSONTime              `json:"earliest_at,omitempty"`
+	LatestAt      *JSONTime              `json:"latest_at,omitempty"`
+	EnqueuedAt    *JSONTime              `json:"enqueued_at,omitempty"` // set by the handler when the downlink is enqueued
 	PayloadRaw    []byte                 `json:"payload_raw,omitempty"`
 	PayloadFields map[string]interface{} `json:"payload_fields,omitempty"`
 }
+
+// Clone returns a deep copy of the downlink message that shares no slices, maps or times with the original
+func (m *DownlinkMessage) Clone() *DownlinkMessage {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	for _, t := range []**JSONTime{&clone.EarliestAt, &clone.LatestAt, &clone.EnqueuedAt} {
+		if *t != nil {
+			copied := **t
+			*t = &copied
+		}
+	}
+	if m.PayloadRaw != nil {
+		clone.PayloadRaw = append([]byte{}, m.PayloadRaw...)
+	}
+	clone.PayloadFields = cloneFields(m.PayloadFields)
+	return &clone
+}
+
+// Pending returns true if the downlink should not be sent before the given time
+func (m *DownlinkMessage) Pending(at time.Time) bool {
+	return m.EarliestAt != nil && at.Before(time.Time(*m.EarliestAt))
+}
+
+// Expired returns true if the downlink can no longer be sent at the given time
+func (m *DownlinkMessage) Expired(at time.Time) bool {
+	return m.LatestAt != nil && at.After(time.Time(*m.LatestAt))
+}
+
+// DownlinkState is the state of a downlink in the queue of a device
+type DownlinkState string
+
+// DownlinkStates
+const (
+	DownlinkStateQueued  DownlinkState = "queued"  // waiting for the next uplink
+	DownlinkStatePending DownlinkState = "pending" // waiting for EarliestAt
+	DownlinkStateExpired DownlinkState = "expired" // past LatestAt, removed on the next uplink
+	DownlinkStateSent    DownlinkState = "sent"    // sent, waiting for an ack or the next uplink
+)
+
+// DownlinkQueueItem describes a downlink in the queue of a device
+type DownlinkQueueItem struct {
+	ID         string           `json:"id"`
+	FPort      uint8            `json:"port"`
+	Confirmed  bool             `json:"confirmed,omitempty"`
+	Priority   DownlinkPriority `json:"priority,omitempty"`
+	Size       int              `json:"size"` // size of the raw payload, 0 if the payload fields are not encoded yet
+	EnqueuedAt *JSONTime        `json:"enqueued_at,omitempty"`
+	State      DownlinkState    `json:"state"`
+}
+
+// QueueItem returns the DownlinkQueueItem of the downlink in the given state
+func (m *DownlinkMessage) QueueItem(state DownlinkState) DownlinkQueueItem {
+	return DownlinkQueueItem{
+		ID:         m.ID,
+		FPort:      m.FPort,
+		Confirmed:  m.Confirmed,
+		Priority:   m.Priority,
+		Size:       len(m.PayloadRaw),
+		EnqueuedAt: m.EnqueuedAt,
+		State:      state,
+	}
+}
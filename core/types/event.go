@@ -14,6 +14,9 @@ const (
 	DownlinkSentEvent      EventType = "down/sent"
 	DownlinkErrorEvent     EventType = "down/errors"
 	DownlinkAckEvent       EventType = "down/acks"
+	DownlinkCancelledEvent EventType = "down/cancelled"
+	DownlinkQueueEvent     EventType = "down/queue"
+	DownlinkStatusEvent    EventType = "down/status"
 
 	ActivationEvent      EventType = "activations"
 	ActivationErrorEvent EventType = "activations/errors"
@@ -21,6 +24,17 @@ const (
 	CreateEvent EventType = "create"
 	UpdateEvent EventType = "update"
 	DeleteEvent EventType = "delete"
+
+	OfflineEvent EventType = "offline"
+	SilentEvent  EventType = "silent"
+
+	AlertEvent   EventType = "alerts"
+	AnomalyEvent EventType = "anomalies"
+
+	SessionResetEvent EventType = "resets"
+	FCntResetEvent    EventType = "resets/fcnt"
+
+	MulticastEvent EventType = "multicast"
 )
 
 // Data type of the event payload, returns nil if no payload
@@ -28,12 +42,30 @@ func (e EventType) Data() interface{} {
 	switch e {
 	case UplinkErrorEvent:
 		return new(ErrorEventData)
-	case DownlinkScheduledEvent, DownlinkSentEvent, DownlinkErrorEvent, DownlinkAckEvent:
+	case DownlinkScheduledEvent, DownlinkSentEvent, DownlinkErrorEvent, DownlinkAckEvent, DownlinkCancelledEvent:
 		return new(DownlinkEventData)
+	case DownlinkQueueEvent:
+		return new(DownlinkQueueEventData)
+	case DownlinkStatusEvent:
+		return new(DownlinkStatusEventData)
 	case ActivationEvent, ActivationErrorEvent:
 		return new(ActivationEventData)
 	case CreateEvent, UpdateEvent, DeleteEvent:
 		return nil
+	case OfflineEvent:
+		return new(OfflineEventData)
+	case SilentEvent:
+		return new(SilentEventData)
+	case AlertEvent:
+		return new(AlertEventData)
+	case AnomalyEvent:
+		return new(AnomalyEventData)
+	case SessionResetEvent:
+		return new(SessionResetEventData)
+	case FCntResetEvent:
+		return new(FCntResetEventData)
+	case MulticastEvent:
+		return new(MulticastEventData)
 	}
 	return nil
 }
@@ -77,4 +109,95 @@ type DownlinkEventData struct {
 	Message   *DownlinkMessage        `json:"message,omitempty"`
 	GatewayID string                  `json:"gateway_id,omitempty"`
 	Config    DownlinkEventConfigInfo `json:"config,omitempty"`
+
+	// NextDownlinkAt is set when the downlink was rejected because the downlink quota was exceeded
+	NextDownlinkAt *JSONTime `json:"next_downlink_at,omitempty"`
+}
+
+// DownlinkQueueEventData is added to downlink queue events
+type DownlinkQueueEventData struct {
+	Queue []DownlinkQueueItem `json:"queue"`
+}
+
+// DownlinkStatus is the status of a downlink in its lifecycle
+type DownlinkStatus string
+
+// DownlinkStatuses
+const (
+	DownlinkStatusQueued    DownlinkStatus = "queued"    // enqueued by the application
+	DownlinkStatusScheduled DownlinkStatus = "scheduled" // taken from the queue for the response to an uplink
+	DownlinkStatusSent      DownlinkStatus = "sent"      // sent to the broker for transmission by a gateway
+	DownlinkStatusAcked     DownlinkStatus = "acked"     // acknowledged by the device
+	DownlinkStatusCancelled DownlinkStatus = "cancelled" // cancelled by the application
+	DownlinkStatusFailed    DownlinkStatus = "failed"    // not sent, see the error
+)
+
+// DownlinkStatusEventData is added to downlink status events
+type DownlinkStatusEventData struct {
+	ErrorEventData
+	ID        string         `json:"id"`
+	Status    DownlinkStatus `json:"status"`
+	GatewayID string         `json:"gateway_id,omitempty"`
+	Time      JSONTime       `json:"time"`
+}
+
+// OfflineEventData is added to offline events
+type OfflineEventData struct {
+	LastSeen          JSONTime `json:"last_seen"`
+	LastGatewayID     string   `json:"last_gateway_id,omitempty"`
+	HeartbeatInterval string   `json:"heartbeat_interval"`
+}
+
+// SilentEventData is added to silent events
+type SilentEventData struct {
+	LastSeen     JSONTime `json:"last_seen"`
+	UplinkPeriod string   `json:"uplink_period"`
+	Learned      bool     `json:"learned,omitempty"` // the uplink period was learned from the uplinks of the device
+	SilentFor    string   `json:"silent_for"`
+}
+
+// AlertEventData is added to alert events
+type AlertEventData struct {
+	RuleID    string   `json:"rule_id"`
+	Metric    string   `json:"metric"`
+	Field     string   `json:"field,omitempty"`
+	GtwID     string   `json:"gtw_id,omitempty"`
+	Value     float64  `json:"value"`
+	Threshold float64  `json:"threshold,omitempty"`
+	Severity  string   `json:"severity"`
+	Message   string   `json:"message"`
+	Time      JSONTime `json:"time"`
+}
+
+// AnomalyEventData is added to anomaly events
+type AnomalyEventData struct {
+	Metric string  `json:"metric"` // interval, rssi, snr or payload_size
+	Value  float64 `json:"value"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Score  float64 `json:"score"` // number of standard deviations from the mean
+}
+
+// SessionResetEventData is added to session reset events
+type SessionResetEventData struct {
+	Command string `json:"command"` // reset or rekey
+	Minor   uint8  `json:"minor"`   // LoRaWAN minor version of the device
+	FCnt    uint32 `json:"counter"`
+}
+
+// FCntResetEventData is added to frame counter reset events
+type FCntResetEventData struct {
+	FCntUp      uint32   `json:"fcnt_up"`   // before the reset
+	FCntDown    uint32   `json:"fcnt_down"` // before the reset
+	NewFCntUp   uint32   `json:"new_fcnt_up"`
+	NewFCntDown uint32   `json:"new_fcnt_down"`
+	By          string   `json:"by,omitempty"` // subject of the token that reset the counters
+	Time        JSONTime `json:"time"`
+}
+
+// MulticastEventData is added to multicast events
+type MulticastEventData struct {
+	Command string `json:"command"` // setup or delete
+	GroupID uint8  `json:"group_id"`
+	Error   bool   `json:"error,omitempty"`
 }
@@ -0,0 +1,113 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// GatewayEUI is a unique identifier for gateways.
+type GatewayEUI EUI64
+
+// gatewayIDPrefix is the prefix of the Gateway IDs of gateways that connect with their EUI
+const gatewayIDPrefix = "eui-"
+
+// ParseGatewayEUI parses a 64-bit hex-encoded string or a Gateway ID in the form "eui-0102030405060708" to a
+// GatewayEUI
+func ParseGatewayEUI(input string) (eui GatewayEUI, err error) {
+	eui64, err := ParseEUI64(strings.TrimPrefix(strings.ToLower(input), gatewayIDPrefix))
+	if err != nil {
+		return
+	}
+	eui = GatewayEUI(eui64)
+	return
+}
+
+// GatewayID returns the Gateway ID of a gateway that connects with its EUI, in the form "eui-0102030405060708"
+func (eui GatewayEUI) GatewayID() string {
+	if eui.IsEmpty() {
+		return ""
+	}
+	return gatewayIDPrefix + hex.EncodeToString(eui.Bytes())
+}
+
+// Bytes returns the GatewayEUI as a byte slice
+func (eui GatewayEUI) Bytes() []byte {
+	return EUI64(eui).Bytes()
+}
+
+// String implements the Stringer interface.
+func (eui GatewayEUI) String() string {
+	return EUI64(eui).String()
+}
+
+// GoString implements the GoStringer interface.
+func (eui GatewayEUI) GoString() string {
+	return eui.String()
+}
+
+// MarshalText implements the TextMarshaler interface.
+func (eui GatewayEUI) MarshalText() ([]byte, error) {
+	return EUI64(eui).MarshalText()
+}
+
+// UnmarshalText implements the TextUnmarshaler interface. It also accepts Gateway IDs in the form
+// "eui-0102030405060708".
+func (eui *GatewayEUI) UnmarshalText(data []byte) error {
+	parsed, err := ParseGatewayEUI(string(data))
+	if err != nil {
+		return err
+	}
+	*eui = parsed
+	return nil
+}
+
+// MarshalBinary implements the BinaryMarshaler interface.
+func (eui GatewayEUI) MarshalBinary() ([]byte, error) {
+	return EUI64(eui).MarshalBinary()
+}
+
+// UnmarshalBinary implements the BinaryUnmarshaler interface.
+func (eui *GatewayEUI) UnmarshalBinary(data []byte) error {
+	e := EUI64(*eui)
+	err := e.UnmarshalBinary(data)
+	if err != nil {
+		return err
+	}
+	*eui = GatewayEUI(e)
+	return nil
+}
+
+// MarshalTo is used by Protobuf
+func (eui *GatewayEUI) MarshalTo(b []byte) (int, error) {
+	copy(b, eui.Bytes())
+	return 8, nil
+}
+
+// Size is used by Protobuf
+func (eui *GatewayEUI) Size() int {
+	return 8
+}
+
+// Marshal implements the Marshaler interface.
+func (eui GatewayEUI) Marshal() ([]byte, error) {
+	return eui.MarshalBinary()
+}
+
+// Unmarshal implements the Unmarshaler interface.
+func (eui *GatewayEUI) Unmarshal(data []byte) error {
+	*eui = [8]byte{} // Reset the receiver
+	return eui.UnmarshalBinary(data)
+}
+
+// Equal returns whether eui is equal to other
+func (eui GatewayEUI) Equal(other GatewayEUI) bool {
+	return eui == other
+}
+
+// IsEmpty returns whether the GatewayEUI is zero
+func (eui GatewayEUI) IsEmpty() bool {
+	return EUI64(eui).IsEmpty()
+}
@@ -0,0 +1,81 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestGatewayEUI(t *testing.T) {
+	a := New(t)
+
+	// Setup
+	eui := GatewayEUI{1, 2, 3, 4, 252, 253, 254, 255}
+	str := "01020304FCFDFEFF"
+	id := "eui-01020304fcfdfeff"
+	bin := []byte{0x01, 0x02, 0x03, 0x04, 0xfc, 0xfd, 0xfe, 0xff}
+
+	// Bytes
+	a.So(eui.Bytes(), ShouldResemble, bin)
+
+	// String
+	a.So(eui.String(), ShouldEqual, str)
+
+	// GatewayID
+	a.So(eui.GatewayID(), ShouldEqual, id)
+	a.So(GatewayEUI{}.GatewayID(), ShouldEqual, "")
+
+	// MarshalText
+	mtOut, err := eui.MarshalText()
+	a.So(err, ShouldBeNil)
+	a.So(mtOut, ShouldResemble, []byte(str))
+
+	// Marshal
+	mOut, err := eui.Marshal()
+	a.So(err, ShouldBeNil)
+	a.So(mOut, ShouldResemble, bin)
+
+	// Size
+	s := eui.Size()
+	a.So(s, ShouldEqual, 8)
+
+	// Parse
+	for _, input := range []string{str, id, "EUI-01020304FCFDFEFF", "01:02:03:04:fc:fd:fe:ff", "01-02-03-04-FC-FD-FE-FF"} {
+		pOut, err := ParseGatewayEUI(input)
+		a.So(err, ShouldBeNil)
+		a.So(pOut, ShouldEqual, eui)
+	}
+	_, err = ParseGatewayEUI("eui-0102")
+	a.So(err, ShouldNotBeNil)
+	_, err = ParseGatewayEUI("my-gateway")
+	a.So(err, ShouldNotBeNil)
+
+	// UnmarshalText
+	utOut := &GatewayEUI{}
+	err = utOut.UnmarshalText([]byte(id))
+	a.So(err, ShouldBeNil)
+	a.So(*utOut, ShouldEqual, eui)
+
+	// Unmarshal
+	uOut := &GatewayEUI{}
+	err = uOut.Unmarshal(bin)
+	a.So(err, ShouldBeNil)
+	a.So(*uOut, ShouldEqual, eui)
+
+	// JSON
+	var jsonOut struct {
+		EUI GatewayEUI `json:"eui"`
+	}
+	err = json.Unmarshal([]byte(`{"eui":"01:02:03:04:FC:FD:FE:FF"}`), &jsonOut)
+	a.So(err, ShouldBeNil)
+	a.So(jsonOut.EUI, ShouldEqual, eui)
+
+	// IsEmpty
+	var empty GatewayEUI
+	a.So(empty.IsEmpty(), ShouldEqual, true)
+	a.So(eui.IsEmpty(), ShouldEqual, false)
+}
@@ -18,3 +18,11 @@ type GatewayMetadata struct {
 	RFChain                uint32   `json:"rf_chain"`
 	LocationMetadata
 }
+
+// Clone returns a deep copy of the gateway metadata
+func (m GatewayMetadata) Clone() GatewayMetadata {
+	if m.FineTimestampEncrypted != nil {
+		m.FineTimestampEncrypted = append([]byte{}, m.FineTimestampEncrypted...)
+	}
+	return m
+}
@@ -4,6 +4,7 @@
 package types
 
 import (
+	"crypto/sha256"
 	"encoding/hex"
 	"strings"
 
@@ -373,3 +374,28 @@ func (key AppSKey) IsEmpty() bool {
 func (key NwkSKey) IsEmpty() bool {
 	return AES128Key(key).IsEmpty()
 }
+
+// Fingerprint returns the first 4 bytes of the SHA-256 hash of the key, hex-encoded. It identifies the key
+// without revealing it. The fingerprint of an empty key is empty.
+func (key AES128Key) Fingerprint() string {
+	if key.IsEmpty() {
+		return ""
+	}
+	sum := sha256.Sum256(key.Bytes())
+	return strings.ToUpper(hex.EncodeToString(sum[:4]))
+}
+
+// Fingerprint returns the fingerprint of the AppKey
+func (key AppKey) Fingerprint() string {
+	return AES128Key(key).Fingerprint()
+}
+
+// Fingerprint returns the fingerprint of the AppSKey
+func (key AppSKey) Fingerprint() string {
+	return AES128Key(key).Fingerprint()
+}
+
+// Fingerprint returns the fingerprint of the NwkSKey
+func (key NwkSKey) Fingerprint() string {
+	return AES128Key(key).Fingerprint()
+}
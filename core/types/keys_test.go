@@ -280,3 +280,16 @@ func TestAppSKey(t *testing.T) {
 	a.So(empty.IsEmpty(), ShouldBeTrue)
 	a.So(key.IsEmpty(), ShouldBeFalse)
 }
+
+func TestKeyFingerprint(t *testing.T) {
+	a := New(t)
+
+	key := AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 249, 250, 251, 252, 253, 254, 255, 0}
+	a.So(key.Fingerprint(), ShouldEqual, "D28DFCBF")
+	a.So(NwkSKey(key).Fingerprint(), ShouldEqual, key.Fingerprint())
+	a.So(AppSKey(key).Fingerprint(), ShouldEqual, key.Fingerprint())
+	a.So(AppKey(key).Fingerprint(), ShouldEqual, key.Fingerprint())
+
+	var empty AES128Key
+	a.So(empty.Fingerprint(), ShouldBeEmpty)
+}
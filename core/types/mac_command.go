@@ -0,0 +1,79 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+// LoRaWAN 1.0.2 and 1.1 MAC commands that are not (yet) defined by github.com/brocaar/lorawan
+const (
+	TxParamSetupReq = 0x09 // Downlink, sets the dwell time and maximum EIRP
+	TxParamSetupAns = 0x09 // Uplink, answer to TxParamSetupReq
+
+	DlChannelReq = 0x0A // Downlink, sets the RX1 frequency of a channel
+	DlChannelAns = 0x0A // Uplink, answer to DlChannelReq
+
+	DeviceTimeReq = 0x0D // Uplink, requests the network time
+	DeviceTimeAns = 0x0D // Downlink, answer to DeviceTimeReq with the GPS time
+
+	PingSlotInfoReq    = 0x10 // Uplink, sent by Class B devices to indicate the ping slot periodicity
+	PingSlotInfoAns    = 0x10 // Downlink, answer to PingSlotInfoReq
+	PingSlotChannelReq = 0x11 // Downlink, sets the ping slot channel of Class B devices
+	PingSlotChannelAns = 0x11 // Uplink, answer to PingSlotChannelReq
+
+	ResetInd  = 0x01 // Uplink, sent by ABP devices after a reset
+	ResetConf = 0x01 // Downlink, answer to ResetInd
+	RekeyInd  = 0x0B // Uplink, sent by OTAA devices after a join
+	RekeyConf = 0x0B // Downlink, answer to RekeyInd
+)
+
+// MaxLoRaWANMinor is the highest LoRaWAN minor version that is supported in ResetConf and RekeyConf
+const MaxLoRaWANMinor = 1
+
+// MaxResetIndFCnt is the highest FCnt of an uplink with a ResetInd that resets the session of an ABP device. Devices
+// start counting at zero after a reset, and send the ResetInd in their uplinks until they receive a ResetConf.
+const MaxResetIndFCnt = 16
+
+// uplinkMACCommandLength is the length of the payload of the uplink MAC commands
+var uplinkMACCommandLength = map[byte]int{
+	ResetInd:           1,
+	0x02:               0, // LinkCheckReq
+	0x03:               1, // LinkADRAns
+	0x04:               0, // DutyCycleAns
+	0x05:               1, // RXParamSetupAns
+	0x06:               2, // DevStatusAns
+	0x07:               1, // NewChannelAns
+	0x08:               0, // RXTimingSetupAns
+	TxParamSetupAns:    0,
+	DlChannelAns:       1,
+	RekeyInd:           1,
+	0x0C:               0, // ADRParamSetupAns
+	DeviceTimeReq:      0,
+	0x0F:               1, // RejoinParamSetupAns
+	PingSlotInfoReq:    1,
+	PingSlotChannelAns: 1,
+}
+
+// HasResetInd returns true if the FOpts of the uplink PHYPayload contain a ResetInd. MAC commands on FPort 0 are
+// encrypted and are not checked.
+func HasResetInd(phyPayload []byte) bool {
+	const fOptsStart = 1 + 4 + 1 + 2 // MHDR, DevAddr, FCtrl, FCnt
+	if len(phyPayload) < fOptsStart+4 {
+		return false
+	}
+	fOptsLen := int(phyPayload[5] & 0x0F)
+	if fOptsStart+fOptsLen+4 > len(phyPayload) {
+		return false
+	}
+	fOpts := phyPayload[fOptsStart : fOptsStart+fOptsLen]
+	for len(fOpts) > 0 {
+		cid := fOpts[0]
+		if cid == ResetInd {
+			return true
+		}
+		length, ok := uplinkMACCommandLength[cid]
+		if !ok || 1+length > len(fOpts) {
+			return false
+		}
+		fOpts = fOpts[1+length:]
+	}
+	return false
+}
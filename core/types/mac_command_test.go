@@ -0,0 +1,30 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestHasResetInd(t *testing.T) {
+	a := New(t)
+
+	// MHDR, DevAddr, FCtrl, FCnt, FOpts, MIC
+	a.So(HasResetInd([]byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x02, 0x01, 0x00, ResetInd, 0x01, 0x00, 0x00, 0x00, 0x00}), ShouldBeTrue)
+
+	// After other MAC commands
+	a.So(HasResetInd([]byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x04, 0x01, 0x00, 0x03, 0x07, ResetInd, 0x01, 0x00, 0x00, 0x00, 0x00}), ShouldBeTrue)
+
+	// The payload of another MAC command is not a ResetInd
+	a.So(HasResetInd([]byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x02, 0x01, 0x00, 0x03, ResetInd, 0x00, 0x00, 0x00, 0x00}), ShouldBeFalse)
+
+	// No FOpts
+	a.So(HasResetInd([]byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x00, 0x01, 0x00, 0x01, ResetInd, 0x00, 0x00, 0x00, 0x00}), ShouldBeFalse)
+
+	// Too short
+	a.So(HasResetInd([]byte{0x40, 0x04, 0x03, 0x02, 0x01, 0x02, 0x01, 0x00, ResetInd}), ShouldBeFalse)
+	a.So(HasResetInd(nil), ShouldBeFalse)
+}
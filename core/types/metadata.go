@@ -14,3 +14,15 @@ type Metadata struct {
 	Gateways   []GatewayMetadata `json:"gateways,omitempty"`
 	LocationMetadata
 }
+
+// Clone returns a deep copy of the metadata that shares no slices with the original
+func (m Metadata) Clone() Metadata {
+	if m.Gateways != nil {
+		gateways := make([]GatewayMetadata, len(m.Gateways))
+		for i, gateway := range m.Gateways {
+			gateways[i] = gateway.Clone()
+		}
+		m.Gateways = gateways
+	}
+	return m
+}
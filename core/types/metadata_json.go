@@ -0,0 +1,538 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// MQTT and AMQP clients decode the metadata of every uplink they receive, so instead of using
+// reflection, it is decoded by a parser for the known fields. Unknown fields are skipped. Like
+// encoding/json, the keys of the fields are matched case-insensitively.
+
+var errInvalidJSON = errors.New("ttn/core: Invalid JSON")
+
+// knownStrings contains string values that most uplinks have in common, so that they are not allocated for each uplink
+var knownStrings = make(map[string]string)
+
+func init() {
+	for _, known := range []string{"LORA", "FSK", "4/5", "4/6", "4/7", "4/8", "gps", "config", "registry", "ip_geolocation", "unknown"} {
+		knownStrings[known] = known
+	}
+	for sf := 7; sf <= 12; sf++ {
+		for _, bw := range []int{125, 250, 500} {
+			dataRate := fmt.Sprintf("SF%dBW%d", sf, bw)
+			knownStrings[dataRate] = dataRate
+		}
+	}
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	d := &jsonDecoder{data: data}
+	if err := d.metadata(m); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *GatewayMetadata) UnmarshalJSON(data []byte) error {
+	d := &jsonDecoder{data: data}
+	if err := d.gatewayMetadata(m); err != nil {
+		return err
+	}
+	return d.end()
+}
+
+type jsonDecoder struct {
+	data   []byte
+	pos    int
+	keyBuf [32]byte // holds the lowercase key of the current field, so that the data is not modified
+}
+
+func (d *jsonDecoder) metadata(m *Metadata) (err error) {
+	if d.null() {
+		return nil
+	}
+	if !d.consume('{') {
+		return errInvalidJSON
+	}
+	for first := true; ; first = false {
+		key, ok, err := d.field(first)
+		if err != nil || !ok {
+			return err
+		}
+		switch string(key) {
+		case "time":
+			err = d.time(&m.Time)
+		case "frequency":
+			err = d.float32(&m.Frequency)
+		case "modulation":
+			err = d.string(&m.Modulation)
+		case "data_rate":
+			err = d.string(&m.DataRate)
+		case "bit_rate":
+			err = d.uint32(&m.Bitrate)
+		case "coding_rate":
+			err = d.string(&m.CodingRate)
+		case "gateways":
+			err = d.gateways(&m.Gateways)
+		default:
+			err = d.locationMetadata(&m.LocationMetadata, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *jsonDecoder) gateways(gateways *[]GatewayMetadata) error {
+	if d.null() {
+		*gateways = nil
+		return nil
+	}
+	if !d.consume('[') {
+		return errInvalidJSON
+	}
+	*gateways = (*gateways)[:0]
+	for first := true; ; first = false {
+		ok, err := d.element(first)
+		if err != nil || !ok {
+			return err
+		}
+		*gateways = append(*gateways, GatewayMetadata{})
+		if err := d.gatewayMetadata(&(*gateways)[len(*gateways)-1]); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *jsonDecoder) gatewayMetadata(m *GatewayMetadata) (err error) {
+	if d.null() {
+		return nil
+	}
+	if !d.consume('{') {
+		return errInvalidJSON
+	}
+	for first := true; ; first = false {
+		key, ok, err := d.field(first)
+		if err != nil || !ok {
+			return err
+		}
+		switch string(key) {
+		case "gtw_id":
+			err = d.string(&m.GtwID)
+		case "gtw_trusted":
+			err = d.bool(&m.GtwTrusted)
+		case "timestamp":
+			err = d.uint32(&m.Timestamp)
+		case "fine_timestamp":
+			err = d.uint(&m.FineTimestamp, 64)
+		case "fine_timestamp_encrypted":
+			err = d.bytes(&m.FineTimestampEncrypted)
+		case "time":
+			err = d.time(&m.Time)
+		case "antenna":
+			antenna := uint64(m.Antenna)
+			err = d.uint(&antenna, 8)
+			m.Antenna = uint8(antenna)
+		case "channel":
+			err = d.uint32(&m.Channel)
+		case "rssi":
+			err = d.float32(&m.RSSI)
+		case "snr":
+			err = d.float32(&m.SNR)
+		case "rf_chain":
+			err = d.uint32(&m.RFChain)
+		default:
+			err = d.locationMetadata(&m.LocationMetadata, key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *jsonDecoder) locationMetadata(m *LocationMetadata, key []byte) error {
+	switch string(key) {
+	case "latitude":
+		return d.float32(&m.Latitude)
+	case "longitude":
+		return d.float32(&m.Longitude)
+	case "altitude":
+		return d.int32(&m.Altitude)
+	case "location_accuracy":
+		return d.int32(&m.Accuracy)
+	case "location_source":
+		return d.string(&m.Source)
+	}
+	return d.skip()
+}
+
+func (d *jsonDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\n', '\r':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (d *jsonDecoder) consume(c byte) bool {
+	d.skipSpace()
+	if d.pos < len(d.data) && d.data[d.pos] == c {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *jsonDecoder) literal(lit string) bool {
+	d.skipSpace()
+	if d.pos < len(d.data) && d.data[d.pos] == lit[0] && len(d.data)-d.pos >= len(lit) && string(d.data[d.pos:d.pos+len(lit)]) == lit {
+		d.pos += len(lit)
+		return true
+	}
+	return false
+}
+
+func (d *jsonDecoder) null() bool {
+	return d.literal("null")
+}
+
+func (d *jsonDecoder) end() error {
+	d.skipSpace()
+	if d.pos != len(d.data) {
+		return errInvalidJSON
+	}
+	return nil
+}
+
+// field returns the key of the next field of an object, or false at the end of the object
+func (d *jsonDecoder) field(first bool) (key []byte, ok bool, err error) {
+	if d.consume('}') {
+		return nil, false, nil
+	}
+	if !first && !d.consume(',') {
+		return nil, false, errInvalidJSON
+	}
+	key, escaped, err := d.rawString()
+	if err != nil {
+		return nil, false, err
+	}
+	if escaped {
+		var unquoted string
+		if err := json.Unmarshal(key, &unquoted); err != nil {
+			return nil, false, err
+		}
+		key = []byte(unquoted)
+	} else {
+		key = key[1 : len(key)-1]
+	}
+	if !d.consume(':') {
+		return nil, false, errInvalidJSON
+	}
+	return d.lower(key, escaped), true, nil
+}
+
+// lower returns the key in lowercase. Keys that alias the data are copied to the key buffer first, unless they are
+// already lowercase, which is the case for almost all keys.
+func (d *jsonDecoder) lower(key []byte, owned bool) []byte {
+	upper := -1
+	for i, c := range key {
+		if c >= 'A' && c <= 'Z' {
+			upper = i
+			break
+		}
+	}
+	if upper < 0 {
+		return key
+	}
+	if !owned {
+		if len(key) <= len(d.keyBuf) {
+			key = append(d.keyBuf[:0], key...)
+		} else {
+			key = append([]byte(nil), key...)
+		}
+	}
+	for i := upper; i < len(key); i++ {
+		if c := key[i]; c >= 'A' && c <= 'Z' {
+			key[i] = c + 'a' - 'A'
+		}
+	}
+	return key
+}
+
+// element returns true if there is a next element in an array, or false at the end of the array
+func (d *jsonDecoder) element(first bool) (ok bool, err error) {
+	if d.consume(']') {
+		return false, nil
+	}
+	if !first && !d.consume(',') {
+		return false, errInvalidJSON
+	}
+	return true, nil
+}
+
+// rawString returns the quoted string at the current position and whether it contains escape sequences
+func (d *jsonDecoder) rawString() (raw []byte, escaped bool, err error) {
+	if !d.consume('"') {
+		return nil, false, errInvalidJSON
+	}
+	data, start := d.data, d.pos-1
+	for i := d.pos; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			escaped = true
+			i++
+		case '"':
+			d.pos = i + 1
+			return data[start:d.pos], escaped, nil
+		}
+	}
+	return nil, false, errInvalidJSON
+}
+
+func (d *jsonDecoder) string(v *string) error {
+	if d.null() {
+		return nil
+	}
+	raw, escaped, err := d.rawString()
+	if err != nil {
+		return err
+	}
+	if escaped {
+		return json.Unmarshal(raw, v)
+	}
+	raw = raw[1 : len(raw)-1]
+	if known, ok := knownStrings[string(raw)]; ok {
+		*v = known
+		return nil
+	}
+	*v = string(raw)
+	return nil
+}
+
+func (d *jsonDecoder) number() ([]byte, error) {
+	d.skipSpace()
+	start := d.pos
+	for d.pos < len(d.data) && isNumberChar(d.data[d.pos]) {
+		d.pos++
+	}
+	if d.pos == start {
+		return nil, errInvalidJSON
+	}
+	return d.data[start:d.pos], nil
+}
+
+func isNumberChar(c byte) bool {
+	return c >= '0' && c <= '9' || c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E'
+}
+
+// float32Pow10 contains the powers of ten that are exact in a float32
+var float32Pow10 = []float32{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10}
+
+// parseFloat32 parses numbers with few digits, such as RSSI, SNR and frequency, without strconv. The
+// result is correctly rounded because both the digits and the divisor are exact in a float32.
+func parseFloat32(num []byte) (f float32, ok bool) {
+	var mantissa uint32
+	var digits, decimals int
+	negative, point := false, false
+	for i, c := range num {
+		switch {
+		case c == '-' && i == 0:
+			negative = true
+		case c == '.' && !point:
+			point = true
+		case c >= '0' && c <= '9':
+			mantissa = mantissa*10 + uint32(c-'0')
+			digits++
+			if point {
+				decimals++
+			}
+		default:
+			return 0, false
+		}
+	}
+	if digits == 0 || digits > 7 || decimals >= len(float32Pow10) {
+		return 0, false
+	}
+	f = float32(mantissa) / float32Pow10[decimals]
+	if negative {
+		f = -f
+	}
+	return f, true
+}
+
+func (d *jsonDecoder) float32(v *float32) error {
+	if d.null() {
+		return nil
+	}
+	num, err := d.number()
+	if err != nil {
+		return err
+	}
+	if f, ok := parseFloat32(num); ok {
+		*v = f
+		return nil
+	}
+	f, err := strconv.ParseFloat(string(num), 32)
+	if err != nil {
+		return err
+	}
+	*v = float32(f)
+	return nil
+}
+
+func (d *jsonDecoder) uint(v *uint64, bitSize int) error {
+	if d.null() {
+		return nil
+	}
+	num, err := d.number()
+	if err != nil {
+		return err
+	}
+	max := uint64(1)<<uint(bitSize) - 1 // 1<<64 overflows to 0, so max is the largest uint64
+	var u uint64
+	for _, c := range num {
+		if c < '0' || c > '9' || u > (max-uint64(c-'0'))/10 {
+			// Let strconv return the error
+			_, err := strconv.ParseUint(string(num), 10, bitSize)
+			if err == nil {
+				err = errInvalidJSON
+			}
+			return err
+		}
+		u = u*10 + uint64(c-'0')
+	}
+	*v = u
+	return nil
+}
+
+func (d *jsonDecoder) uint32(v *uint32) error {
+	u := uint64(*v)
+	err := d.uint(&u, 32)
+	*v = uint32(u)
+	return err
+}
+
+func (d *jsonDecoder) int32(v *int32) error {
+	if d.null() {
+		return nil
+	}
+	num, err := d.number()
+	if err != nil {
+		return err
+	}
+	i, err := strconv.ParseInt(string(num), 10, 32)
+	if err != nil {
+		return err
+	}
+	*v = int32(i)
+	return nil
+}
+
+func (d *jsonDecoder) bool(v *bool) error {
+	switch {
+	case d.literal("true"):
+		*v = true
+	case d.literal("false"):
+		*v = false
+	case d.null():
+	default:
+		return errInvalidJSON
+	}
+	return nil
+}
+
+func (d *jsonDecoder) time(v *JSONTime) error {
+	if d.null() {
+		return nil
+	}
+	raw, escaped, err := d.rawString()
+	if err != nil {
+		return err
+	}
+	if escaped {
+		return json.Unmarshal(raw, v)
+	}
+	return v.UnmarshalText(raw[1 : len(raw)-1])
+}
+
+func (d *jsonDecoder) bytes(v *[]byte) error {
+	if d.null() {
+		*v = nil
+		return nil
+	}
+	raw, _, err := d.rawString()
+	if err != nil {
+		return err
+	}
+	raw = raw[1 : len(raw)-1]
+	b := make([]byte, base64.StdEncoding.DecodedLen(len(raw)))
+	n, err := base64.StdEncoding.Decode(b, raw)
+	if err != nil {
+		return err
+	}
+	*v = b[:n]
+	return nil
+}
+
+// skip skips the value at the current position
+func (d *jsonDecoder) skip() error {
+	d.skipSpace()
+	if d.pos == len(d.data) {
+		return errInvalidJSON
+	}
+	switch d.data[d.pos] {
+	case '"':
+		_, _, err := d.rawString()
+		return err
+	case '{':
+		d.pos++
+		for first := true; ; first = false {
+			_, ok, err := d.field(first)
+			if err != nil || !ok {
+				return err
+			}
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	case '[':
+		d.pos++
+		for first := true; ; first = false {
+			ok, err := d.element(first)
+			if err != nil || !ok {
+				return err
+			}
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	case 't':
+		if d.literal("true") {
+			return nil
+		}
+	case 'f':
+		if d.literal("false") {
+			return nil
+		}
+	case 'n':
+		if d.null() {
+			return nil
+		}
+	default:
+		_, err := d.number()
+		return err
+	}
+	return errInvalidJSON
+}
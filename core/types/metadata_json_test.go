@@ -0,0 +1,161 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+// reflectMetadata has the same fields as Metadata, but is decoded by reflection
+type reflectMetadata struct {
+	Time       JSONTime                 `json:"time,omitempty,omitempty"`
+	Frequency  float32                  `json:"frequency,omitempty"`
+	Modulation string                   `json:"modulation,omitempty"`
+	DataRate   string                   `json:"data_rate,omitempty"`
+	Bitrate    uint32                   `json:"bit_rate,omitempty"`
+	CodingRate string                   `json:"coding_rate,omitempty"`
+	Gateways   []reflectGatewayMetadata `json:"gateways,omitempty"`
+	LocationMetadata
+}
+
+type reflectGatewayMetadata struct {
+	GtwID                  string   `json:"gtw_id,omitempty"`
+	GtwTrusted             bool     `json:"gtw_trusted,omitempty"`
+	Timestamp              uint32   `json:"timestamp,omitempty"`
+	FineTimestamp          uint64   `json:"fine_timestamp,omitempty"`
+	FineTimestampEncrypted []byte   `json:"fine_timestamp_encrypted,omitempty"`
+	Time                   JSONTime `json:"time,omitempty"`
+	Antenna                uint8    `json:"antenna,omitempty"`
+	Channel                uint32   `json:"channel"`
+	RSSI                   float32  `json:"rssi"`
+	SNR                    float32  `json:"snr"`
+	RFChain                uint32   `json:"rf_chain"`
+	LocationMetadata
+}
+
+func buildMetadataJSON() []byte {
+	metadata := Metadata{
+		Time:       BuildTime(1465831736000000000),
+		Frequency:  868.1,
+		Modulation: "LORA",
+		DataRate:   "SF7BW125",
+		CodingRate: "4/5",
+		LocationMetadata: LocationMetadata{
+			Latitude:  52.3736,
+			Longitude: 4.8865,
+			Source:    "registry",
+		},
+	}
+	for _, gtwID := range []string{"gtw-1", "gtw-2", "gtw-3"} {
+		metadata.Gateways = append(metadata.Gateways, GatewayMetadata{
+			GtwID:                  gtwID,
+			GtwTrusted:             true,
+			Timestamp:              123456789,
+			FineTimestamp:          987654321,
+			FineTimestampEncrypted: []byte{1, 2, 3, 4},
+			Time:                   BuildTime(1465831736000000000),
+			Antenna:                1,
+			Channel:                2,
+			RSSI:                   -118.5,
+			SNR:                    -7.25,
+			RFChain:                1,
+			LocationMetadata: LocationMetadata{
+				Latitude:  52.3736,
+				Longitude: 4.8865,
+				Altitude:  -2,
+				Accuracy:  10,
+				Source:    "gps",
+			},
+		})
+	}
+	data, _ := json.Marshal(metadata)
+	return data
+}
+
+func TestMetadataUnmarshalJSON(t *testing.T) {
+	a := New(t)
+
+	data := buildMetadataJSON()
+
+	var expected reflectMetadata
+	a.So(json.Unmarshal(data, &expected), ShouldBeNil)
+	var metadata Metadata
+	a.So(json.Unmarshal(data, &metadata), ShouldBeNil)
+
+	expectedData, _ := json.Marshal(expected)
+	actualData, _ := json.Marshal(metadata)
+	a.So(string(actualData), ShouldEqual, string(expectedData))
+	a.So(metadata.Gateways, ShouldHaveLength, 3)
+	a.So(metadata.Gateways[2].FineTimestampEncrypted, ShouldResemble, []byte{1, 2, 3, 4})
+
+	// Unknown fields, escaped strings and null values
+	metadata = Metadata{DataRate: "SF12BW125"}
+	err := json.Unmarshal([]byte(`{"unknown":{"nested":[1,"two",true,null]},"modulation":"LORA","data_rate":null,"coding_rate":"4\/5","gateways":[{"gtw_id":"gtw-1","rssi":-42,"extra":false}]}`), &metadata)
+	a.So(err, ShouldBeNil)
+	a.So(metadata.Modulation, ShouldEqual, "LORA")
+	a.So(metadata.DataRate, ShouldEqual, "SF12BW125")
+	a.So(metadata.CodingRate, ShouldEqual, "4/5")
+	a.So(metadata.Gateways, ShouldResemble, []GatewayMetadata{{GtwID: "gtw-1", RSSI: -42}})
+
+	// Keys are matched case-insensitively, like encoding/json does
+	metadata, expected = Metadata{}, reflectMetadata{}
+	data = []byte(`{"Modulation":"LORA","DATA_RATE":"SF7BW125","Gateways":[{"GTW_ID":"gtw-1","Rssi":-42}]}`)
+	a.So(json.Unmarshal(data, &expected), ShouldBeNil)
+	a.So(json.Unmarshal(data, &metadata), ShouldBeNil)
+	a.So(metadata.Modulation, ShouldEqual, expected.Modulation)
+	a.So(metadata.DataRate, ShouldEqual, expected.DataRate)
+	a.So(metadata.Gateways, ShouldResemble, []GatewayMetadata{{GtwID: "gtw-1", RSSI: -42}})
+	a.So(string(data), ShouldStartWith, `{"Modulation"`) // The data is not modified
+
+	// Invalid values
+	a.So(metadata.UnmarshalJSON([]byte(`{"bit_rate":-1}`)), ShouldNotBeNil)
+	a.So(metadata.UnmarshalJSON([]byte(`{"frequency":"868.1"}`)), ShouldNotBeNil)
+	a.So(metadata.UnmarshalJSON([]byte(`{"modulation":"LORA"`)), ShouldNotBeNil)
+	a.So(metadata.UnmarshalJSON([]byte(`{"modulation":"LORA"} {}`)), ShouldNotBeNil)
+	a.So(metadata.UnmarshalJSON([]byte(`{"gateways":[{"antenna":256}]}`)), ShouldNotBeNil)
+}
+
+func TestMetadataUnmarshalJSONSpeedup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping benchmark comparison in short mode")
+	}
+	a := New(t)
+
+	data := buildMetadataJSON()
+	reflect := testing.Benchmark(func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			var metadata reflectMetadata
+			json.Unmarshal(data, &metadata)
+		}
+	})
+	parser := testing.Benchmark(func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			var metadata Metadata
+			metadata.UnmarshalJSON(data)
+		}
+	})
+	t.Logf("Reflect: %s, Parser: %s", reflect, parser)
+	a.So(parser.NsPerOp()*3, ShouldBeLessThanOrEqualTo, reflect.NsPerOp())
+}
+
+func BenchmarkMetadataUnmarshalJSON(b *testing.B) {
+	data := buildMetadataJSON()
+	b.Run("Reflect", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var metadata reflectMetadata
+			json.Unmarshal(data, &metadata)
+		}
+	})
+	b.Run("Parser", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			var metadata Metadata
+			metadata.UnmarshalJSON(data)
+		}
+	})
+}
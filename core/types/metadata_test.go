@@ -0,0 +1,31 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestMetadataClone(t *testing.T) {
+	a := New(t)
+
+	md := Metadata{
+		DataRate: "SF7BW125",
+		Gateways: []GatewayMetadata{
+			{GtwID: "gtw-1", FineTimestampEncrypted: []byte{1, 2, 3}},
+			{GtwID: "gtw-2"},
+		},
+	}
+	clone := md.Clone()
+	a.So(clone, ShouldResemble, md)
+
+	clone.Gateways[0].GtwID = "changed"
+	clone.Gateways[0].FineTimestampEncrypted[0] = 4
+	a.So(md.Gateways[0].GtwID, ShouldEqual, "gtw-1")
+	a.So(md.Gateways[0].FineTimestampEncrypted, ShouldResemble, []byte{1, 2, 3})
+
+	a.So(Metadata{}.Clone().Gateways, ShouldBeNil)
+}
@@ -7,14 +7,25 @@ import (
 	"encoding/hex"
 	"fmt"
 	"regexp"
+	"strings"
 )
 
-// ParseHEX parses a string "input" to a byteslice with length "length".
+// separatedHEX matches bytes that are separated by colons, dashes or spaces, such as "01:02:03"
+var separatedHEX = regexp.MustCompile("^[[:xdigit:]]{2}([:\\- ][[:xdigit:]]{2})+$")
+
+var hexSeparators = strings.NewReplacer(":", "", "-", "", " ", "")
+
+// ParseHEX parses a string "input" to a byteslice with length "length". The bytes in the input may be
+// separated by colons, dashes or spaces.
 func ParseHEX(input string, length int) ([]byte, error) {
 	if input == "" {
 		return make([]byte, length), nil
 	}
 
+	if separatedHEX.MatchString(input) {
+		input = hexSeparators.Replace(input)
+	}
+
 	pattern := regexp.MustCompile(fmt.Sprintf("^[[:xdigit:]]{%d}$", length*2))
 
 	valid := pattern.MatchString(input)
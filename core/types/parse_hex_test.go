@@ -22,3 +22,18 @@ func TestParseHex(t *testing.T) {
 	_, err = ParseHEX("ab", 2)
 	a.So(err, ShouldNotBeNil)
 }
+
+func TestParseHexSeparators(t *testing.T) {
+	a := New(t)
+
+	for _, input := range []string{"AA:BC:01", "aa-bc-01", "AA BC 01"} {
+		b, err := ParseHEX(input, 3)
+		a.So(err, ShouldBeNil)
+		a.So(b, ShouldResemble, []byte{0xaa, 0xbc, 0x01})
+	}
+
+	for _, input := range []string{"AA:BC", "AA:BC:0", "AABC:01", ":AA:BC:01", "AA::BC:01"} {
+		_, err := ParseHEX(input, 3)
+		a.So(err, ShouldNotBeNil)
+	}
+}
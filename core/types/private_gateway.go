@@ -0,0 +1,43 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"strings"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// PrivateGateways are the gateways that only forward the traffic of the applications of their owner, by gateway ID
+type PrivateGateways map[string]map[string]bool
+
+// ParsePrivateGateways parses private gateways in the format <gateway-id>=<app-id>. A gateway can be given multiple
+// times for each application of its owner.
+func ParsePrivateGateways(strs ...string) (PrivateGateways, error) {
+	gateways := make(PrivateGateways)
+	for _, str := range strs {
+		parts := strings.SplitN(str, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.NewErrInvalidArgument("Private gateway", "must be in the format <gateway-id>=<app-id>")
+		}
+		if gateways[parts[0]] == nil {
+			gateways[parts[0]] = make(map[string]bool)
+		}
+		gateways[parts[0]][parts[1]] = true
+	}
+	return gateways, nil
+}
+
+// IsPrivate returns true if the gateway is private
+func (g PrivateGateways) IsPrivate(gatewayID string) bool {
+	_, ok := g[gatewayID]
+	return ok
+}
+
+// Allows returns true if the gateway forwards the traffic of the application, which is the case for all
+// applications if the gateway is not private
+func (g PrivateGateways) Allows(gatewayID, appID string) bool {
+	apps, ok := g[gatewayID]
+	return !ok || apps[appID]
+}
@@ -0,0 +1,33 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestPrivateGateways(t *testing.T) {
+	a := New(t)
+
+	gateways, err := ParsePrivateGateways("eui-0102030405060708=app-1", "other=app-2", "eui-0102030405060708=app-3")
+	a.So(err, ShouldBeNil)
+	a.So(gateways, ShouldHaveLength, 2)
+
+	a.So(gateways.IsPrivate("eui-0102030405060708"), ShouldBeTrue)
+	a.So(gateways.IsPrivate("public"), ShouldBeFalse)
+	a.So(gateways.Allows("eui-0102030405060708", "app-1"), ShouldBeTrue)
+	a.So(gateways.Allows("eui-0102030405060708", "app-3"), ShouldBeTrue)
+	a.So(gateways.Allows("eui-0102030405060708", "app-2"), ShouldBeFalse)
+	a.So(gateways.Allows("public", "app-2"), ShouldBeTrue)
+
+	for _, invalid := range []string{"eui-0102030405060708", "=app-1", "eui-0102030405060708="} {
+		_, err := ParsePrivateGateways(invalid)
+		a.So(err, ShouldNotBeNil)
+	}
+
+	var none PrivateGateways
+	a.So(none.Allows("eui-0102030405060708", "app-1"), ShouldBeTrue)
+}
@@ -17,3 +17,51 @@ type UplinkMessage struct {
 	Metadata       Metadata               `json:"metadata,omitempty"`
 	Attributes     map[string]string      `json:"attributes,omitempty"`
 }
+
+// Clone returns a deep copy of the uplink message that shares no slices or maps with the original
+func (m *UplinkMessage) Clone() *UplinkMessage {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	if m.PayloadRaw != nil {
+		clone.PayloadRaw = append([]byte{}, m.PayloadRaw...)
+	}
+	clone.PayloadFields = cloneFields(m.PayloadFields)
+	clone.Metadata = m.Metadata.Clone()
+	if m.Attributes != nil {
+		clone.Attributes = make(map[string]string, len(m.Attributes))
+		for k, v := range m.Attributes {
+			clone.Attributes[k] = v
+		}
+	}
+	return &clone
+}
+
+// cloneFields returns a deep copy of the decoded payload fields
+func cloneFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	clone := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		clone[k] = cloneField(v)
+	}
+	return clone
+}
+
+func cloneField(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return cloneFields(v)
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, elem := range v {
+			clone[i] = cloneField(elem)
+		}
+		return clone
+	case []byte:
+		return append([]byte{}, v...)
+	}
+	return v
+}
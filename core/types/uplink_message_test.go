@@ -0,0 +1,67 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestUplinkMessageClone(t *testing.T) {
+	a := New(t)
+
+	up := &UplinkMessage{
+		AppID:      "app",
+		DevID:      "dev",
+		PayloadRaw: []byte{1, 2},
+		PayloadFields: map[string]interface{}{
+			"temperature": 21.5,
+			"nested":      map[string]interface{}{"list": []interface{}{1, "two"}},
+		},
+		Metadata:   Metadata{Gateways: []GatewayMetadata{{GtwID: "gtw"}}},
+		Attributes: map[string]string{"room": "kitchen"},
+	}
+	clone := up.Clone()
+	a.So(clone, ShouldResemble, up)
+
+	clone.PayloadRaw[0] = 3
+	clone.PayloadFields["temperature"] = 0
+	clone.PayloadFields["nested"].(map[string]interface{})["list"].([]interface{})[0] = 0
+	clone.Metadata.Gateways[0].GtwID = "other"
+	clone.Attributes["room"] = "garden"
+
+	a.So(up.PayloadRaw, ShouldResemble, []byte{1, 2})
+	a.So(up.PayloadFields["temperature"], ShouldEqual, 21.5)
+	a.So(up.PayloadFields["nested"].(map[string]interface{})["list"].([]interface{})[0], ShouldEqual, 1)
+	a.So(up.Metadata.Gateways[0].GtwID, ShouldEqual, "gtw")
+	a.So(up.Attributes["room"], ShouldEqual, "kitchen")
+
+	var nilUplink *UplinkMessage
+	a.So(nilUplink.Clone(), ShouldBeNil)
+}
+
+func TestDownlinkMessageClone(t *testing.T) {
+	a := New(t)
+
+	earliest := BuildTime(time.Now().UnixNano())
+	down := &DownlinkMessage{
+		AppID:         "app",
+		DevID:         "dev",
+		EarliestAt:    &earliest,
+		PayloadRaw:    []byte{1, 2},
+		PayloadFields: map[string]interface{}{"led": true},
+	}
+	clone := down.Clone()
+	a.So(clone, ShouldResemble, down)
+
+	*clone.EarliestAt = BuildTime(0)
+	clone.PayloadRaw[0] = 3
+	clone.PayloadFields["led"] = false
+
+	a.So(*down.EarliestAt, ShouldResemble, earliest)
+	a.So(down.PayloadRaw, ShouldResemble, []byte{1, 2})
+	a.So(down.PayloadFields["led"], ShouldBeTrue)
+}
@@ -48,6 +48,9 @@ type Client interface {
 	UnsubscribeDeviceDownlink(appID string, devID string) Token
 	UnsubscribeAppDownlink(appID string) Token
 	UnsubscribeDownlink() Token
+	PublishDownlinkCommand(appID string, devID string, command DownlinkCommand) Token
+	SubscribeDownlinkCommands(handler DownlinkCommandHandler) Token
+	UnsubscribeDownlinkCommands() Token
 
 	// Event pub/sub
 	PublishAppEvent(appID string, eventType types.EventType, payload interface{}) Token
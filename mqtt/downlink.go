@@ -14,6 +14,25 @@ import (
 // DownlinkHandler is called for downlink messages
 type DownlinkHandler func(client Client, appID string, devID string, req types.DownlinkMessage)
 
+// DownlinkCommandType is the type of a command on the downlink queue of a device
+type DownlinkCommandType string
+
+// DownlinkCommandTypes
+const (
+	DownlinkQueueCommand  DownlinkCommandType = "queue"  // publish the downlink queue in a down/queue event
+	DownlinkCancelCommand DownlinkCommandType = "cancel" // cancel the downlink with the given ID
+	DownlinkFlushCommand  DownlinkCommandType = "flush"  // cancel all downlinks
+)
+
+// DownlinkCommand is a command on the downlink queue of a device
+type DownlinkCommand struct {
+	Type DownlinkCommandType `json:"-"`
+	ID   string              `json:"id,omitempty"`
+}
+
+// DownlinkCommandHandler is called for commands on downlink queues
+type DownlinkCommandHandler func(client Client, appID string, devID string, command DownlinkCommand)
+
 // PublishDownlink publishes a downlink message
 func (c *DefaultClient) PublishDownlink(dataDown types.DownlinkMessage) Token {
 	topic := DeviceTopic{dataDown.AppID, dataDown.DevID, DeviceDownlink, ""}
@@ -77,3 +96,52 @@ func (c *DefaultClient) UnsubscribeAppDownlink(appID string) Token {
 func (c *DefaultClient) UnsubscribeDownlink() Token {
 	return c.UnsubscribeDeviceDownlink("", "")
 }
+
+// PublishDownlinkCommand publishes a command on the downlink queue of a device
+func (c *DefaultClient) PublishDownlinkCommand(appID string, devID string, command DownlinkCommand) Token {
+	topic := DeviceTopic{appID, devID, DeviceDownlink, string(command.Type)}
+	msg, err := json.Marshal(command)
+	if err != nil {
+		return &simpleToken{fmt.Errorf("Unable to marshal the message payload: %s", err)}
+	}
+	return c.publish(topic.String(), msg)
+}
+
+// SubscribeDownlinkCommands subscribes to all commands on downlink queues that the current user has access to
+func (c *DefaultClient) SubscribeDownlinkCommands(handler DownlinkCommandHandler) Token {
+	topic := DeviceTopic{"", "", DeviceDownlink, simpleWildcard}
+	return c.subscribe(topic.String(), func(mqtt MQTT.Client, msg MQTT.Message) {
+		// Determine the actual topic
+		topic, err := ParseDeviceTopic(msg.Topic())
+		if err != nil {
+			c.ctx.Warnf("mqtt: received message on invalid downlink command topic: %s", msg.Topic())
+			return
+		}
+
+		command := DownlinkCommand{}
+		switch DownlinkCommandType(topic.Field) {
+		case DownlinkQueueCommand, DownlinkCancelCommand, DownlinkFlushCommand:
+		default:
+			c.ctx.Warnf("mqtt: received unknown downlink command: %s", topic.Field)
+			return
+		}
+
+		// Unmarshal the payload, which is optional
+		if len(msg.Payload()) > 0 {
+			if err := json.Unmarshal(msg.Payload(), &command); err != nil {
+				c.ctx.Warnf("mqtt: could not unmarshal downlink command: %s", err)
+				return
+			}
+		}
+		command.Type = DownlinkCommandType(topic.Field)
+
+		// Call the DownlinkCommand handler
+		handler(c, topic.AppID, topic.DevID, command)
+	})
+}
+
+// UnsubscribeDownlinkCommands unsubscribes from the commands on downlink queues
+func (c *DefaultClient) UnsubscribeDownlinkCommands() Token {
+	topic := DeviceTopic{"", "", DeviceDownlink, simpleWildcard}
+	return c.unsubscribe(topic.String())
+}
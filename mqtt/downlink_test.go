@@ -150,3 +150,37 @@ func TestPubSubAppDownlink(t *testing.T) {
 	unsubToken := c.UnsubscribeAppDownlink("app3")
 	waitForOK(unsubToken, a)
 }
+
+func TestPubSubDownlinkCommands(t *testing.T) {
+	a := New(t)
+	c := NewClient(getLogger(t, "Test"), "test", "", "", fmt.Sprintf("tcp://%s", host))
+	c.Connect()
+	defer c.Disconnect()
+
+	var wg WaitGroup
+
+	wg.Add(2)
+
+	subToken := c.SubscribeDownlinkCommands(func(client Client, appID string, devID string, command DownlinkCommand) {
+		a.So(appID, ShouldResemble, "app5")
+		a.So(devID, ShouldResemble, "dev5")
+		switch command.Type {
+		case DownlinkCancelCommand:
+			a.So(command.ID, ShouldEqual, "downlink-id")
+		case DownlinkFlushCommand:
+			a.So(command.ID, ShouldBeEmpty)
+		}
+		wg.Done()
+	})
+	waitForOK(subToken, a)
+
+	pubToken := c.PublishDownlinkCommand("app5", "dev5", DownlinkCommand{Type: DownlinkCancelCommand, ID: "downlink-id"})
+	waitForOK(pubToken, a)
+	pubToken = c.PublishDownlinkCommand("app5", "dev5", DownlinkCommand{Type: DownlinkFlushCommand})
+	waitForOK(pubToken, a)
+
+	a.So(wg.WaitFor(200*time.Millisecond), ShouldBeNil)
+
+	unsubToken := c.UnsubscribeDownlinkCommands()
+	waitForOK(unsubToken, a)
+}
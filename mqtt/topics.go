@@ -47,7 +47,7 @@ func ParseDeviceTopic(topic string) (*DeviceTopic, error) {
 	}
 	topicType := DeviceTopicType(matches[4])
 	deviceTopic := &DeviceTopic{appID, devID, topicType, ""}
-	if (topicType == DeviceUplink || topicType == DeviceEvents) && len(matches) > 5 {
+	if (topicType == DeviceUplink || topicType == DeviceDownlink || topicType == DeviceEvents) && len(matches) > 5 {
 		deviceTopic.Field = strings.Trim(matches[5], "/")
 	}
 	return deviceTopic, nil
@@ -67,7 +67,7 @@ func (t DeviceTopic) String() string {
 		t.Field = simpleWildcard
 	}
 	topic := fmt.Sprintf("%s/%s/%s/%s", appID, "devices", devID, t.Type)
-	if (t.Type == DeviceUplink || t.Type == DeviceEvents) && t.Field != "" {
+	if (t.Type == DeviceUplink || t.Type == DeviceDownlink || t.Type == DeviceEvents) && t.Field != "" {
 		topic += "/" + t.Field
 	}
 	return topic
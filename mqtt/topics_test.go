@@ -66,6 +66,7 @@ func TestDeviceTopicParseAndString(t *testing.T) {
 		"0102030405060708/devices/abcdabcd12345678/up",
 		"0102030405060708/devices/abcdabcd12345678/up/value",
 		"0102030405060708/devices/abcdabcd12345678/down",
+		"0102030405060708/devices/abcdabcd12345678/down/cancel",
 		"0102030405060708/devices/abcdabcd12345678/events/activations",
 		// Numbers
 		"0102030405060708/devices/0000000012345678/up",
@@ -80,6 +81,7 @@ func TestDeviceTopicParseAndString(t *testing.T) {
 		"0102030405060708/devices/0100000000000000/up",
 		"0102030405060708/devices/0100000000000000/up/value",
 		"0102030405060708/devices/0100000000000000/down",
+		"0102030405060708/devices/0100000000000000/down/cancel",
 		"0102030405060708/devices/0100000000000000/events/activations",
 	}
 
@@ -0,0 +1,233 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package sdk is a client for applications on The Things Network. It manages
+// the devices of an application and publishes and subscribes to its messages.
+package sdk
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	pb_handler "github.com/TheThingsNetwork/api/handler"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/api"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/mqtt"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// ClientConfig contains the configuration of the Client
+type ClientConfig struct {
+	// ClientName is used in the MQTT client ID
+	ClientName string
+	// DiscoveryAddress is the address of the Discovery server
+	DiscoveryAddress string
+	// HandlerID is the ID of the Handler of the application
+	HandlerID string
+	// MQTTAddress overrides the MQTT address that is announced by the Handler
+	MQTTAddress string
+	// Timeout of requests to the Handler
+	Timeout time.Duration
+	// Logger of the client
+	Logger ttnlog.Interface
+}
+
+// DefaultClientConfig is the default configuration of the Client
+var DefaultClientConfig = ClientConfig{
+	ClientName:       "ttn-sdk",
+	DiscoveryAddress: "discover.thethingsnetwork.org:1900",
+	HandlerID:        "ttn-handler-eu",
+	Timeout:          10 * time.Second,
+}
+
+// Client of an application
+type Client struct {
+	config    ClientConfig
+	appID     string
+	accessKey string
+
+	handler     *pb_discovery.Announcement
+	handlerConn *grpc.ClientConn
+	manager     pb_handler.ApplicationManagerClient
+
+	mqttLock sync.Mutex
+	mqtt     mqtt.Client
+}
+
+// NewClient returns a new Client for the application. It discovers and connects to the Handler of the application.
+func (c ClientConfig) NewClient(appID, accessKey string) (*Client, error) {
+	if c.Logger == nil {
+		c.Logger = ttnlog.Get()
+	}
+	client := &Client{
+		config:    c,
+		appID:     appID,
+		accessKey: accessKey,
+	}
+
+	discoveryConn, err := api.Dial(c.DiscoveryAddress)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not connect to Discovery server")
+	}
+	defer discoveryConn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	client.handler, err = pb_discovery.NewDiscoveryClient(discoveryConn).Get(ctx, &pb_discovery.GetRequest{
+		ServiceName: "handler",
+		ID:          c.HandlerID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(errors.FromGRPCError(err), "Could not find Handler")
+	}
+
+	client.handlerConn, err = client.handler.Dial(nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not connect to Handler")
+	}
+	client.manager = pb_handler.NewApplicationManagerClient(client.handlerConn)
+
+	return client, nil
+}
+
+func (c *Client) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.Timeout)
+	return metadata.NewOutgoingContext(ctx, metadata.Pairs("key", c.accessKey)), cancel
+}
+
+// ListDevices returns the devices of the application
+func (c *Client) ListDevices() ([]*pb_handler.Device, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	res, err := c.manager.GetDevicesForApplication(ctx, &pb_handler.ApplicationIdentifier{AppID: c.appID})
+	if err != nil {
+		return nil, errors.FromGRPCError(err)
+	}
+	return res.Devices, nil
+}
+
+// GetDevice returns the device
+func (c *Client) GetDevice(devID string) (*pb_handler.Device, error) {
+	ctx, cancel := c.context()
+	defer cancel()
+	dev, err := c.manager.GetDevice(ctx, &pb_handler.DeviceIdentifier{AppID: c.appID, DevID: devID})
+	if err != nil {
+		return nil, errors.FromGRPCError(err)
+	}
+	return dev, nil
+}
+
+// SetDevice registers or updates the device
+func (c *Client) SetDevice(dev *pb_handler.Device) error {
+	dev.AppID = c.appID
+	if lorawan := dev.GetLoRaWANDevice(); lorawan != nil {
+		lorawan.AppID = c.appID
+		lorawan.DevID = dev.DevID
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err := c.manager.SetDevice(ctx, dev)
+	return errors.FromGRPCError(err)
+}
+
+// DeleteDevice deletes the device
+func (c *Client) DeleteDevice(devID string) error {
+	ctx, cancel := c.context()
+	defer cancel()
+	_, err := c.manager.DeleteDevice(ctx, &pb_handler.DeviceIdentifier{AppID: c.appID, DevID: devID})
+	return errors.FromGRPCError(err)
+}
+
+// getMQTT returns the MQTT client, connecting it if needed
+func (c *Client) getMQTT() (mqtt.Client, error) {
+	c.mqttLock.Lock()
+	defer c.mqttLock.Unlock()
+	if c.mqtt != nil {
+		return c.mqtt, nil
+	}
+	address := c.config.MQTTAddress
+	if address == "" {
+		address = c.handler.MqttAddress
+	}
+	if address == "" {
+		return nil, errors.NewErrNotFound("MQTT address of the Handler")
+	}
+	mqttProto := "tcp"
+	if strings.HasSuffix(address, ":8883") {
+		mqttProto = "ssl"
+	}
+	client := mqtt.NewClient(c.config.Logger, c.config.ClientName, c.appID, c.accessKey, fmt.Sprintf("%s://%s", mqttProto, address))
+	if err := client.Connect(); err != nil {
+		return nil, errors.Wrap(err, "Could not connect to MQTT")
+	}
+	c.mqtt = client
+	return client, nil
+}
+
+// UplinkHandler is called for uplink messages of devices
+type UplinkHandler func(devID string, msg types.UplinkMessage)
+
+// SubscribeUplinks calls the handler for uplink messages of the device, or of all devices if devID is empty
+func (c *Client) SubscribeUplinks(devID string, handler UplinkHandler) error {
+	client, err := c.getMQTT()
+	if err != nil {
+		return err
+	}
+	handle := func(_ mqtt.Client, _ string, devID string, msg types.UplinkMessage) {
+		handler(devID, msg)
+	}
+	var token mqtt.Token
+	if devID == "" {
+		token = client.SubscribeAppUplink(c.appID, handle)
+	} else {
+		token = client.SubscribeDeviceUplink(c.appID, devID, handle)
+	}
+	token.Wait()
+	return token.Error()
+}
+
+// UnsubscribeUplinks stops the subscription of SubscribeUplinks
+func (c *Client) UnsubscribeUplinks(devID string) error {
+	client, err := c.getMQTT()
+	if err != nil {
+		return err
+	}
+	var token mqtt.Token
+	if devID == "" {
+		token = client.UnsubscribeAppUplink(c.appID)
+	} else {
+		token = client.UnsubscribeDeviceUplink(c.appID, devID)
+	}
+	token.Wait()
+	return token.Error()
+}
+
+// ScheduleDownlink schedules a downlink message for the device
+func (c *Client) ScheduleDownlink(devID string, msg types.DownlinkMessage) error {
+	client, err := c.getMQTT()
+	if err != nil {
+		return err
+	}
+	msg.AppID = c.appID
+	msg.DevID = devID
+	token := client.PublishDownlink(msg)
+	token.Wait()
+	return token.Error()
+}
+
+// Close the client
+func (c *Client) Close() error {
+	c.mqttLock.Lock()
+	if c.mqtt != nil {
+		c.mqtt.Disconnect()
+		c.mqtt = nil
+	}
+	c.mqttLock.Unlock()
+	return c.handlerConn.Close()
+}
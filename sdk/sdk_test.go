@@ -0,0 +1,36 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package sdk
+
+import (
+	"testing"
+
+	pb_discovery "github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestClientContext(t *testing.T) {
+	a := New(t)
+
+	c := &Client{config: DefaultClientConfig, appID: "app", accessKey: "ttn-account-v2.secret"}
+	ctx, cancel := c.context()
+	defer cancel()
+
+	_, hasDeadline := ctx.Deadline()
+	a.So(hasDeadline, ShouldBeTrue)
+}
+
+func TestClientMQTTAddress(t *testing.T) {
+	a := New(t)
+
+	config := DefaultClientConfig
+	config.Logger = GetLogger(t, "TestClientMQTTAddress")
+	c := &Client{config: config, appID: "app", handler: &pb_discovery.Announcement{}}
+
+	_, err := c.getMQTT()
+	a.So(err, ShouldNotBeNil)
+	a.So(c.SubscribeUplinks("", func(string, types.UplinkMessage) {}), ShouldNotBeNil)
+}
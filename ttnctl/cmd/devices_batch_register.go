@@ -0,0 +1,157 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/binary"
+	"os"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var devicesBatchRegisterCmd = &cobra.Command{
+	Use:   "batch-register [Template File] [DevEUI ...]",
+	Short: "Register a batch of devices from a template",
+	Long: `ttnctl devices batch-register can be used to register many devices that share the same settings.
+
+The settings of the devices are read from a JSON template. The AppKey of every device is derived
+from the root key and the DevEUI of the device as aes128_encrypt(RootKey, DevEUI | 0x00 * 8).
+Use the --count flag to register a range of consecutive DevEUIs, starting at the given DevEUI.
+
+The frequency_plan, class and adr_margin of the template are set as the ttn-frequency-plan, ttn-class
+and ttn-adr-margin attributes of the devices. The payload_format (custom or cayennelpp) of the template
+is set on the application.`,
+	Example: `$ ttnctl devices batch-register template.json 70B3D57ED0000001 --count 2 --root-key 01020304050607080102030405060708
+  INFO Using Application                        AppEUI=70B3D57EF0000024 AppID=test
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+  INFO Registered devices                       Devices=2 Failed=0
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 2, 0)
+
+		appID := util.GetAppID(ctx)
+		appEUI := util.GetAppEUI(ctx)
+
+		rootKeyStr, _ := cmd.Flags().GetString("root-key")
+		if rootKeyStr == "" {
+			ctx.Fatal("The root key is required")
+		}
+		rootKey, err := types.ParseAES128Key(rootKeyStr)
+		if err != nil {
+			ctx.Fatalf("Invalid root key: %s", err)
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open template")
+		}
+		template, err := util.ReadDeviceTemplate(file)
+		file.Close()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read template")
+		}
+		if err := template.Validate(); err != nil {
+			ctx.WithError(err).Fatal("Invalid template")
+		}
+
+		var devEUIs []types.DevEUI
+		for _, arg := range args[1:] {
+			devEUI, err := types.ParseDevEUI(arg)
+			if err != nil {
+				ctx.Fatalf("Invalid DevEUI: %s", err)
+			}
+			devEUIs = append(devEUIs, devEUI)
+		}
+		if count, _ := cmd.Flags().GetInt("count"); count > 0 {
+			if len(devEUIs) != 1 {
+				ctx.Fatal("The --count flag requires exactly one DevEUI")
+			}
+			start := binary.BigEndian.Uint64(devEUIs[0][:])
+			devEUIs = devEUIs[:0]
+			for i := 0; i < count; i++ {
+				var devEUI types.DevEUI
+				binary.BigEndian.PutUint64(devEUI[:], start+uint64(i))
+				devEUIs = append(devEUIs, devEUI)
+			}
+		}
+
+		records := make([]*util.DeviceRecord, 0, len(devEUIs))
+		for _, devEUI := range devEUIs {
+			record := template.NewDeviceRecord(rootKey, devEUI)
+			if err := record.Validate(); err != nil {
+				ctx.WithError(err).Fatal("Invalid device")
+			}
+			records = append(records, record)
+		}
+
+		if output, _ := cmd.Flags().GetString("output"); output != "" {
+			out, err := os.Create(output)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not create output file")
+			}
+			err = util.WriteDeviceRecords(out, util.DeviceRecordsCSV, records)
+			out.Close()
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not write output file")
+			}
+			ctx.WithField("File", output).Info("Wrote derived keys to file")
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			ctx.WithField("Devices", len(records)).Info("Dry run: not registering devices")
+			return
+		}
+
+		conn, manager := util.GetHandlerManager(ctx, appID)
+		defer conn.Close()
+
+		if template.PayloadFormat != "" {
+			app, err := manager.GetApplication(appID)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not get application")
+			}
+			if app.PayloadFormat != template.PayloadFormat {
+				app.PayloadFormat = template.PayloadFormat
+				if err := manager.SetApplication(app); err != nil {
+					ctx.WithError(err).Fatal("Could not set payload format of application")
+				}
+				ctx.WithField("PayloadFormat", app.PayloadFormat).Info("Set payload format of application")
+			}
+		}
+
+		var failed int
+		for i, record := range records {
+			dev := record.ToDevice(appID, appEUI)
+			template.Apply(dev)
+			if err := manager.SetDevice(dev); err != nil {
+				ctx.WithError(err).WithField("DevID", record.DevID).Warn("Could not register device")
+				failed++
+			}
+			if done := i + 1; done%100 == 0 && done < len(records) {
+				ctx.Infof("Registered %d/%d devices", done, len(records))
+			}
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"Devices": len(records) - failed,
+			"Failed":  failed,
+		}).Info("Registered devices")
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesBatchRegisterCmd)
+	devicesBatchRegisterCmd.Flags().String("root-key", "", "The root key that is used to derive the AppKeys")
+	devicesBatchRegisterCmd.Flags().Int("count", 0, "The number of consecutive DevEUIs to register")
+	devicesBatchRegisterCmd.Flags().String("output", "", "Write the derived keys to a CSV file")
+	devicesBatchRegisterCmd.Flags().Bool("dry-run", false, "Derive the keys without registering devices")
+}
@@ -0,0 +1,62 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/TheThingsNetwork/api"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var devicesClaimCmd = &cobra.Command{
+	Use:   "claim [Device ID] [DevEUI] [Claim Code]",
+	Short: "Claim a factory-provisioned device",
+	Long: `ttnctl devices claim can be used to claim a factory-provisioned device.
+
+The keys of the device were provisioned on the Handler by the manufacturer, and
+the device is registered with them when the claim code is correct.`,
+	Example: `$ ttnctl devices claim test 0004A30B001B7AD2 4AB8-21C0
+  INFO Using Application                        AppID=test
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+  INFO Claimed device                           AppID=test DevEUI=0004A30B001B7AD2 DevID=test
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 3, 3)
+
+		devID := strings.ToLower(args[0])
+		if err := api.NotEmptyAndValidID(devID, "Device ID"); err != nil {
+			ctx.Fatal(err.Error())
+		}
+
+		devEUI, err := types.ParseDevEUI(args[1])
+		if err != nil {
+			ctx.Fatalf("Invalid DevEUI: %s", err)
+		}
+
+		appID := util.GetAppID(ctx)
+
+		conn, _ := util.GetHandlerManager(ctx, appID)
+		defer conn.Close()
+
+		err = util.ClaimDevice(ctx, conn, appID, devID, devEUI, args[2])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not claim device")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"AppID":  appID,
+			"DevID":  devID,
+			"DevEUI": devEUI,
+		}).Info("Claimed device")
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesClaimCmd)
+}
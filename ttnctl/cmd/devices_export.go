@@ -0,0 +1,71 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var devicesExportCmd = &cobra.Command{
+	Use:   "export [File]",
+	Short: "Export all devices to a CSV or JSON file",
+	Long: `ttnctl devices export can be used to export all devices of the current application.
+
+The exported file contains the keys of the devices and can be imported with ttnctl devices import.`,
+	Example: `$ ttnctl devices export devices.json
+  INFO Using Application                        AppEUI=70B3D57EF0000024 AppID=test
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+  INFO Exported devices                         Devices=2 File=devices.json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 1, 1)
+
+		appID := util.GetAppID(ctx)
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+		}
+
+		conn, manager := util.GetHandlerManager(ctx, appID)
+		defer conn.Close()
+
+		devices, err := manager.GetDevicesForApplication(appID, 0, 0)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get devices.")
+		}
+
+		records := make([]*util.DeviceRecord, 0, len(devices))
+		for _, dev := range devices {
+			records = append(records, util.DeviceRecordFromDevice(dev))
+		}
+
+		file, err := os.Create(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not create file")
+		}
+		defer file.Close()
+
+		if err := util.WriteDeviceRecords(file, format, records); err != nil {
+			ctx.WithError(err).Fatal("Could not write devices")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"File":    args[0],
+			"Devices": len(records),
+		}).Info("Exported devices")
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesExportCmd)
+	devicesExportCmd.Flags().String("format", "", "Format of the file (csv or json). Defaults to the file extension")
+}
@@ -0,0 +1,126 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/go-utils/random"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/otaa"
+	"github.com/spf13/cobra"
+)
+
+var devicesGenerateCmd = &cobra.Command{
+	Use:   "generate [DevEUIBlock] [Count] [File]",
+	Short: "Generate identifiers and keys for new devices",
+	Long: `ttnctl devices generate can be used by device makers to generate DevEUIs from
+a DevEUI block, with random (or derived) AppKeys and TR005 onboarding QR code payloads.
+
+The generated CSV file has the columns dev_eui, app_eui, app_key, claim_code and
+qr_code, and can be used with ttn handler provision. If a root key is given, the
+AppKeys are derived from the root key and the DevEUI instead of generated.`,
+	Example: `$ ttnctl devices generate 70B3D57ED0001000/52 100 devices.csv --app-eui 70B3D57ED0000001 --vendor-id 000A --model-id 0001
+  INFO Generated devices                        Devices=100 File=devices.csv
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 3, 3)
+
+		block, err := otaa.ParseDevEUIBlock(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid DevEUI block")
+		}
+
+		count, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid count")
+		}
+
+		offset, _ := cmd.Flags().GetUint64("offset")
+
+		appEUIStr, _ := cmd.Flags().GetString("app-eui")
+		appEUI, err := types.ParseAppEUI(appEUIStr)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid AppEUI")
+		}
+
+		var rootKey *types.AES128Key
+		if str, _ := cmd.Flags().GetString("root-key"); str != "" {
+			key, err := types.ParseAES128Key(str)
+			if err != nil {
+				ctx.WithError(err).Fatal("Invalid root key")
+			}
+			rootKey = &key
+		}
+
+		vendorIDStr, _ := cmd.Flags().GetString("vendor-id")
+		vendorID, err := strconv.ParseUint(vendorIDStr, 16, 16)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid vendor ID")
+		}
+		modelIDStr, _ := cmd.Flags().GetString("model-id")
+		modelID, err := strconv.ParseUint(modelIDStr, 16, 16)
+		if err != nil {
+			ctx.WithError(err).Fatal("Invalid model ID")
+		}
+
+		claimCodes, _ := cmd.Flags().GetBool("claim-codes")
+
+		file, err := os.Create(args[2])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not create file")
+		}
+		defer file.Close()
+
+		w := csv.NewWriter(file)
+		w.Write([]string{"dev_eui", "app_eui", "app_key", "claim_code", "qr_code"})
+		for i := uint64(0); i < count; i++ {
+			devEUI, err := block.DevEUI(offset + i)
+			if err != nil {
+				ctx.WithError(err).Fatal("Could not generate DevEUI")
+			}
+			appKey := otaa.GenerateAppKey()
+			if rootKey != nil {
+				appKey = otaa.DeriveAppKey(*rootKey, devEUI)
+			}
+			var claimCode string
+			if claimCodes {
+				code := make([]byte, 4)
+				random.FillBytes(code)
+				claimCode = fmt.Sprintf("%X", code)
+			}
+			qrCode := otaa.QRCode{
+				JoinEUI:    appEUI,
+				DevEUI:     devEUI,
+				VendorID:   uint16(vendorID),
+				ModelID:    uint16(modelID),
+				OwnerToken: claimCode,
+			}
+			w.Write([]string{devEUI.String(), appEUI.String(), appKey.String(), claimCode, qrCode.String()})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			ctx.WithError(err).Fatal("Could not write devices")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"File":    args[2],
+			"Devices": count,
+		}).Info("Generated devices")
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesGenerateCmd)
+	devicesGenerateCmd.Flags().String("app-eui", "", "The AppEUI (JoinEUI) of the devices")
+	devicesGenerateCmd.Flags().Uint64("offset", 0, "The index of the first DevEUI in the block")
+	devicesGenerateCmd.Flags().String("root-key", "", "Derive the AppKeys from this root key")
+	devicesGenerateCmd.Flags().String("vendor-id", "0000", "The LoRa Alliance vendor ID (hex) for the QR codes")
+	devicesGenerateCmd.Flags().String("model-id", "0000", "The model ID (hex) for the QR codes")
+	devicesGenerateCmd.Flags().Bool("claim-codes", true, "Generate claim codes, which are used as owner token in the QR codes")
+}
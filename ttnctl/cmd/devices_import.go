@@ -0,0 +1,103 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var devicesImportCmd = &cobra.Command{
+	Use:   "import [File]",
+	Short: "Import devices from a CSV or JSON file",
+	Long: `ttnctl devices import can be used to register many devices at once.
+
+The file should contain a dev_id column or field for every device. OTAA devices
+need a dev_eui and app_key, ABP devices need a dev_addr, nwk_s_key and app_s_key.
+In CSV files, attributes are formatted as key=value pairs separated by semicolons.`,
+	Example: `$ ttnctl devices import devices.csv
+  INFO Using Application                        AppEUI=70B3D57EF0000024 AppID=test
+  INFO Read devices from file                   Devices=2 File=devices.csv
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+  INFO Imported devices                         Devices=2 Failed=0
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 1, 1)
+
+		appID := util.GetAppID(ctx)
+		appEUI := util.GetAppEUI(ctx)
+
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(args[0]), ".")
+		}
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open file")
+		}
+		defer file.Close()
+
+		records, err := util.ReadDeviceRecords(file, format)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read devices")
+		}
+
+		seen := make(map[string]bool, len(records))
+		for _, record := range records {
+			if err := record.Validate(); err != nil {
+				ctx.WithError(err).Fatal("Invalid device")
+			}
+			if seen[record.DevID] {
+				ctx.WithField("DevID", record.DevID).Fatal("Duplicate device")
+			}
+			seen[record.DevID] = true
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"File":    args[0],
+			"Devices": len(records),
+		}).Info("Read devices from file")
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			ctx.Info("Dry run: not importing devices")
+			return
+		}
+
+		conn, manager := util.GetHandlerManager(ctx, appID)
+		defer conn.Close()
+
+		var failed int
+		for i, record := range records {
+			if err := manager.SetDevice(record.ToDevice(appID, appEUI)); err != nil {
+				ctx.WithError(err).WithField("DevID", record.DevID).Warn("Could not import device")
+				failed++
+			}
+			if done := i + 1; done%100 == 0 && done < len(records) {
+				ctx.Infof("Imported %d/%d devices", done, len(records))
+			}
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"Devices": len(records) - failed,
+			"Failed":  failed,
+		}).Info("Imported devices")
+
+		if failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesImportCmd)
+	devicesImportCmd.Flags().String("format", "", "Format of the file (csv or json). Defaults to the file extension")
+	devicesImportCmd.Flags().Bool("dry-run", false, "Validate the file without importing devices")
+}
@@ -90,7 +90,8 @@ var devicesPersonalizeCmd = &cobra.Command{
 		dev.GetLoRaWANDevice().FCntUp = 0
 		dev.GetLoRaWANDevice().FCntDown = 0
 
-		err = manager.SetDevice(dev)
+		// The new session starts counting at zero, also if it has the same DevAddr and keys
+		err = util.SetDeviceResettingFCnt(ctx, conn, appID, dev)
 		if err != nil {
 			ctx.WithError(err).Fatal("Could not update Device")
 		}
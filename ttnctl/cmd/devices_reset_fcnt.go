@@ -0,0 +1,83 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/TheThingsNetwork/api"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var devicesResetFCntCmd = &cobra.Command{
+	Use:   "reset-fcnt [Device ID]",
+	Short: "Reset the frame counters of a device",
+	Long: `ttnctl devices reset-fcnt can be used to reset the frame counters of a device,
+for example after an ABP device was reflashed and starts counting at zero again.
+
+Resetting the frame counters allows replays of earlier messages of the session,
+so the Handler reports every reset in a resets/fcnt event of the device.`,
+	Example: `$ ttnctl devices reset-fcnt test
+  INFO Using Application                        AppID=test
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+Are you sure you want to reset the frame counters of device test (FCntUp 1234, FCntDown 56)?
+> yes
+  INFO Reset frame counters                     AppID=test DevID=test
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 1, 1)
+
+		devID := strings.ToLower(args[0])
+		if err := api.NotEmptyAndValidID(devID, "Device ID"); err != nil {
+			ctx.Fatal(err.Error())
+		}
+
+		appID := util.GetAppID(ctx)
+
+		conn, manager := util.GetHandlerManager(ctx, appID)
+		defer conn.Close()
+
+		dev, err := manager.GetDevice(appID, devID)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get existing device.")
+		}
+		lorawan := dev.GetLoRaWANDevice()
+
+		fCntUp, _ := cmd.Flags().GetUint32("fcnt-up")
+		fCntDown, _ := cmd.Flags().GetUint32("fcnt-down")
+		if fCntUp >= lorawan.FCntUp && fCntDown >= lorawan.FCntDown {
+			ctx.Info("Frame counters are not lowered, use ttnctl devices set to change them")
+			return
+		}
+
+		if confirmed, _ := cmd.Flags().GetBool("confirm"); !confirmed {
+			if !confirm(fmt.Sprintf("Are you sure you want to reset the frame counters of device %s (FCntUp %d, FCntDown %d)?", devID, lorawan.FCntUp, lorawan.FCntDown)) {
+				ctx.Info("Not doing anything")
+				return
+			}
+		}
+
+		lorawan.FCntUp, lorawan.FCntDown = fCntUp, fCntDown
+		err = util.SetDeviceResettingFCnt(ctx, conn, appID, dev)
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not reset frame counters")
+		}
+
+		ctx.WithFields(ttnlog.Fields{
+			"AppID": appID,
+			"DevID": devID,
+		}).Info("Reset frame counters")
+	},
+}
+
+func init() {
+	devicesCmd.AddCommand(devicesResetFCntCmd)
+	devicesResetFCntCmd.Flags().Uint32("fcnt-up", 0, "The FCnt Up to reset to")
+	devicesResetFCntCmd.Flags().Uint32("fcnt-down", 0, "The FCnt Down to reset to")
+	devicesResetFCntCmd.Flags().Bool("confirm", false, "Reset without asking for confirmation")
+}
@@ -19,10 +19,11 @@ var devicesSetCmd = &cobra.Command{
 	Use:   "set [Device ID]",
 	Short: "Set properties of a device",
 	Long:  `ttnctl devices set can be used to set properties of a device.`,
-	Example: `$ ttnctl devices set test --fcnt-up 0 --fcnt-down 0
+	Example: `$ ttnctl devices set test --fcnt-up 0 --fcnt-down 0 --override
   INFO Using Application                        AppID=test
   INFO Discovering Handler...
   INFO Connecting with Handler...
+  WARN Lowering the frame counters of a device allows replays of its earlier messages
   INFO Updated device                           AppID=test DevID=test
 `,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -114,14 +115,26 @@ var devicesSetCmd = &cobra.Command{
 			dev.GetLoRaWANDevice().AppKey = &key
 		}
 
+		var resetFCnt bool
+
 		if in, err := cmd.Flags().GetInt("fcnt-up"); err == nil && in != -1 {
+			resetFCnt = resetFCnt || uint32(in) < dev.GetLoRaWANDevice().FCntUp
 			dev.GetLoRaWANDevice().FCntUp = uint32(in)
 		}
 
 		if in, err := cmd.Flags().GetInt("fcnt-down"); err == nil && in != -1 {
+			resetFCnt = resetFCnt || uint32(in) < dev.GetLoRaWANDevice().FCntDown
 			dev.GetLoRaWANDevice().FCntDown = uint32(in)
 		}
 
+		if resetFCnt {
+			ctx.Warn("Lowering the frame counters of a device allows replays of its earlier messages")
+			if override, _ := cmd.Flags().GetBool("override"); !override {
+				ctx.Warnf("Use the --override flag if you're really sure you want to do this")
+				os.Exit(0)
+			}
+		}
+
 		if in, err := cmd.Flags().GetBool("enable-fcnt-check"); err == nil && in {
 			dev.GetLoRaWANDevice().DisableFCntCheck = false
 		}
@@ -174,7 +187,11 @@ var devicesSetCmd = &cobra.Command{
 			}
 		}
 
-		err = manager.SetDevice(dev)
+		if resetFCnt {
+			err = util.SetDeviceResettingFCnt(ctx, conn, appID, dev)
+		} else {
+			err = manager.SetDevice(dev)
+		}
 		if err != nil {
 			ctx.WithError(err).Fatal("Could not update Device")
 		}
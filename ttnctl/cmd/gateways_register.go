@@ -8,6 +8,7 @@ import (
 
 	"github.com/TheThingsNetwork/api"
 	"github.com/TheThingsNetwork/go-account-lib/account"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/ttnctl/util"
 	"github.com/spf13/cobra"
 )
@@ -18,11 +19,17 @@ var gatewaysRegisterCmd = &cobra.Command{
 	Long:  `ttnctl gateways register can be used to register a gateway`,
 	Example: `$ ttnctl gateways register test US 52.37403,4.88968
   INFO Registered gateway                          Gateway ID=test
+
+$ ttnctl gateways register B8:27:EB:FF:FE:87:BD:22 EU
+  INFO Registered gateway                          Gateway ID=eui-b827ebfffe87bd22
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		assertArgsLength(cmd, args, 2, 3)
 
 		gatewayID := strings.ToLower(args[0])
+		if eui, err := types.ParseGatewayEUI(gatewayID); err == nil && !api.ValidID(gatewayID) {
+			gatewayID = eui.GatewayID() // For example 01:02:03:04:05:06:07:08
+		}
 		if err := api.NotEmptyAndValidID(gatewayID, "Gateway ID"); err != nil {
 			ctx.Fatal(err.Error())
 		}
@@ -0,0 +1,107 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/TheThingsNetwork/api/router/routerclient"
+	"github.com/TheThingsNetwork/go-account-lib/account"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/router/capture"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/spf13/cobra"
+)
+
+var gatewaysReplayCmd = &cobra.Command{
+	Use:   "replay [File]",
+	Short: "Replay captured gateway traffic",
+	Long: `ttnctl gateways replay sends the uplink messages that were captured by a
+Router (with ttn router --capture) to the Router again, with the original timing.
+You can only replay the traffic of gateways that you own.`,
+	Example: `$ ttnctl gateways replay capture.json
+  INFO Replayed uplink                             GatewayID=test
+  INFO Replayed capture                            Uplinks=1
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 1, 1)
+
+		file, err := os.Open(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not open capture")
+		}
+		defer file.Close()
+
+		speed, _ := cmd.Flags().GetFloat64("speed")
+		gatewayID, _ := cmd.Flags().GetString("gateway-id")
+
+		rtrConn, rtrClient := util.GetRouter(ctx)
+		defer rtrConn.Close()
+		defer rtrClient.Close()
+
+		streams := make(map[string]routerclient.GenericStream)
+		defer func() {
+			for _, stream := range streams {
+				stream.Close()
+			}
+		}()
+
+		var acc *account.Account
+		getStream := func(gatewayID string) routerclient.GenericStream {
+			if stream, ok := streams[gatewayID]; ok {
+				return stream
+			}
+			var gatewayToken string
+			if gatewayID != "dev" {
+				if acc == nil {
+					acc = util.GetAccount(ctx)
+				}
+				token, err := acc.GetGatewayToken(gatewayID)
+				if err != nil {
+					ctx.WithError(err).WithField("GatewayID", gatewayID).Warn("Could not get gateway token")
+				} else if token != nil {
+					gatewayToken = token.AccessToken
+				}
+			}
+			stream := rtrClient.NewGatewayStreams(gatewayID, gatewayToken, false)
+			streams[gatewayID] = stream
+			time.Sleep(100 * time.Millisecond)
+			return stream
+		}
+
+		var uplinks int
+		err = capture.Replay(capture.NewReader(file), speed, func(record *capture.Record) error {
+			uplink, err := record.UplinkMessage()
+			if err != nil {
+				return err
+			}
+			id := record.GatewayID
+			if gatewayID != "" {
+				id = gatewayID
+				uplink.GatewayMetadata.GatewayID = gatewayID
+			}
+			uplink.Trace = nil
+			getStream(id).Uplink(uplink)
+			uplinks++
+			ctx.WithField("GatewayID", id).Info("Replayed uplink")
+			return nil
+		})
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not replay capture")
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		ctx.WithFields(ttnlog.Fields{
+			"Uplinks": uplinks,
+		}).Info("Replayed capture")
+	},
+}
+
+func init() {
+	gatewaysCmd.AddCommand(gatewaysReplayCmd)
+	gatewaysReplayCmd.Flags().Float64("speed", 1, "Replay speed relative to the original timing (0 to replay without waiting)")
+	gatewaysReplayCmd.Flags().String("gateway-id", "", "Replay all uplinks as this gateway")
+}
@@ -0,0 +1,315 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	pb_lorawan "github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/api/router"
+	"github.com/TheThingsNetwork/api/router/routerclient"
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/mqtt"
+	"github.com/TheThingsNetwork/ttn/ttnctl/util"
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+	"github.com/TheThingsNetwork/ttn/utils/soak"
+	"github.com/brocaar/lorawan"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var soakCmd = &cobra.Command{
+	Use:   "soak [Scenario]",
+	Short: "Run a soak test scenario against the network",
+	Long: `ttnctl soak runs the traffic profile of a scenario against the network with
+a simulated gateway, and writes a JSON report with the loss rate and latency
+percentiles of the uplinks.
+
+ABP devices of the current application send the uplinks, which are received
+from the MQTT broker of the application. OTAA devices send the join requests of
+the join storms; their sessions change when the joins are accepted.
+
+The scenario is a JSON file:
+
+  {
+    "name": "diurnal",
+    "duration": "24h",
+    "uplinks": {"rate": 5, "diurnal": {"amplitude": 0.8, "peak": "14h"}},
+    "join_storms": [{"at": "6h", "joins": 500, "within": "5m"}],
+    "gateway_flapping": {"every": "1h", "down": "30s"},
+    "assertions": {"max_loss_rate": 0.01, "max_latency": {"p50": "500ms", "p99": "2s"}}
+  }
+
+The command exits with an error if one of the assertions fails.`,
+	Example: `$ ttnctl soak scenario.json --report report.json
+  INFO Using Application                        AppID=test
+  INFO Discovering Handler...
+  INFO Connecting with Handler...
+  INFO Running scenario                         Duration=24h0m0s JoinDevices=1 Scenario=diurnal UplinkDevices=10
+  INFO Passed scenario                          LossRate=0.0012 Received=431377 Sent=431895
+  INFO Wrote report                             File=report.json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assertArgsLength(cmd, args, 1, 1)
+
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not read scenario")
+		}
+		scenario := new(soak.Scenario)
+		if err := json.Unmarshal(data, scenario); err != nil {
+			ctx.WithError(err).Fatal("Could not parse scenario")
+		}
+		if err := scenario.Validate(); err != nil {
+			ctx.WithError(err).Fatal("Invalid scenario")
+		}
+
+		payloadSize := scenario.Uplinks.PayloadSize
+		if payloadSize == 0 {
+			payloadSize = soak.DefaultPayloadSize
+		}
+		accessKey, _ := cmd.Flags().GetString("access-key")
+		grace, _ := cmd.Flags().GetDuration("grace")
+		reportFile, _ := cmd.Flags().GetString("report")
+
+		appID := util.GetAppID(ctx)
+
+		conn, manager := util.GetHandlerManager(ctx, appID)
+		devices, err := manager.GetDevicesForApplication(appID, 0, 0)
+		conn.Close()
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not get devices")
+		}
+
+		target := &soakTarget{payloadSize: payloadSize}
+		for _, dev := range devices {
+			lorawan := dev.GetLoRaWANDevice()
+			if lorawan == nil {
+				continue
+			}
+			switch {
+			case lorawan.AppKey != nil && !lorawan.AppKey.IsEmpty() && lorawan.AppEUI != nil && lorawan.DevEUI != nil && !lorawan.DevEUI.IsEmpty():
+				target.joinDevices = append(target.joinDevices, &soakDevice{
+					appEUI: *lorawan.AppEUI,
+					devEUI: *lorawan.DevEUI,
+					appKey: *lorawan.AppKey,
+				})
+			case lorawan.DevAddr != nil && !lorawan.DevAddr.IsEmpty() && lorawan.NwkSKey != nil && lorawan.AppSKey != nil:
+				target.uplinkDevices = append(target.uplinkDevices, &soakDevice{
+					devID:   dev.DevID,
+					devAddr: *lorawan.DevAddr,
+					nwkSKey: *lorawan.NwkSKey,
+					appSKey: *lorawan.AppSKey,
+					fCnt:    lorawan.FCntUp,
+				})
+			}
+		}
+		if scenario.Uplinks.Rate > 0 && len(target.uplinkDevices) == 0 {
+			ctx.Fatal("The application has no ABP devices to send uplinks")
+		}
+		if len(scenario.JoinStorms) > 0 && len(target.joinDevices) == 0 {
+			ctx.Fatal("The application has no OTAA devices to send join requests")
+		}
+
+		results := soak.NewResults()
+
+		client := util.GetMQTT(ctx, accessKey)
+		defer client.Disconnect()
+		token := client.SubscribeAppUplink(appID, func(client mqtt.Client, appID string, devID string, req types.UplinkMessage) {
+			results.Received(fmt.Sprintf("%s:%d", devID, req.FCnt), time.Now())
+		})
+		token.Wait()
+		if err := token.Error(); err != nil {
+			ctx.WithError(err).Fatal("Could not subscribe to uplink")
+		}
+
+		target.gatewayID, _ = cmd.Flags().GetString("gateway-id")
+		if target.gatewayID == "" {
+			target.gatewayID = viper.GetString("gateway-id")
+		}
+		target.gatewayToken = viper.GetString("gateway-token")
+		if target.gatewayID != "dev" {
+			token, err := util.GetAccount(ctx).GetGatewayToken(target.gatewayID)
+			if err != nil {
+				ctx.WithError(err).Warn("Could not get gateway token")
+				target.gatewayToken = ""
+			} else if token != nil && token.AccessToken != "" {
+				target.gatewayToken = token.AccessToken
+			}
+		}
+
+		rtrConn, rtrClient := util.GetRouter(ctx)
+		defer rtrConn.Close()
+		defer rtrClient.Close()
+		target.router = rtrClient
+		target.SetGatewayUp(true)
+		defer target.SetGatewayUp(false)
+		time.Sleep(100 * time.Millisecond)
+
+		stop := make(chan struct{})
+		sigChan := make(chan os.Signal)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			ctx.WithField("signal", <-sigChan).Info("signal received, stopping scenario")
+			close(stop)
+		}()
+
+		ctx.WithFields(ttnlog.Fields{
+			"Scenario":      scenario.Name,
+			"Duration":      time.Duration(scenario.Duration),
+			"UplinkDevices": len(target.uplinkDevices),
+			"JoinDevices":   len(target.joinDevices),
+		}).Info("Running scenario")
+
+		runner := &soak.Runner{
+			Ctx:      ctx,
+			Scenario: scenario,
+			Target:   target,
+			Results:  results,
+			Grace:    grace,
+		}
+		report := runner.Run(stop)
+
+		data, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			ctx.WithError(err).Fatal("Could not marshal report")
+		}
+		if reportFile == "" {
+			fmt.Println(string(data))
+		} else {
+			if err := ioutil.WriteFile(reportFile, data, 0644); err != nil {
+				ctx.WithError(err).Fatal("Could not write report")
+			}
+			ctx.WithField("File", reportFile).Info("Wrote report")
+		}
+
+		summary := ctx.WithFields(ttnlog.Fields{
+			"Sent":     report.Sent,
+			"Received": report.Received,
+			"LossRate": report.LossRate,
+		})
+		if !report.Passed {
+			for _, failure := range report.Failures {
+				ctx.Warn(failure)
+			}
+			summary.Fatal("Failed scenario")
+		}
+		summary.Info("Passed scenario")
+	},
+}
+
+type soakDevice struct {
+	devID   string
+	devAddr types.DevAddr
+	nwkSKey types.NwkSKey
+	appSKey types.AppSKey
+	fCnt    uint32
+
+	appEUI types.AppEUI
+	devEUI types.DevEUI
+	appKey types.AppKey
+}
+
+// soakTarget sends the traffic of a soak test scenario with a simulated gateway
+type soakTarget struct {
+	router       *routerclient.Client
+	gatewayID    string
+	gatewayToken string
+	payloadSize  int
+
+	mu            sync.Mutex
+	stream        routerclient.GenericStream
+	uplinkDevices []*soakDevice
+	nextUplink    int
+	joinDevices   []*soakDevice
+	nextJoin      int
+}
+
+func (t *soakTarget) send(payload []byte) error {
+	if t.stream == nil {
+		return errors.New("Gateway is disconnected")
+	}
+	uplink := &router.UplinkMessage{
+		Payload:          payload,
+		GatewayMetadata:  *util.GetGatewayMetadata(t.gatewayID, 868100000),
+		ProtocolMetadata: *util.GetProtocolMetadata("SF7BW125"),
+	}
+	uplink.GatewayMetadata.Timestamp = uint32(time.Now().UnixNano() / 1000)
+	t.stream.Uplink(uplink)
+	return nil
+}
+
+func (t *soakTarget) Uplink() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dev := t.uplinkDevices[t.nextUplink%len(t.uplinkDevices)]
+	t.nextUplink++
+	dev.fCnt++
+
+	payload := make([]byte, t.payloadSize)
+	rand.Read(payload)
+	m := &util.Message{}
+	m.SetDevice(dev.devAddr, dev.nwkSKey, dev.appSKey)
+	m.SetMessage(false, false, int(dev.fCnt), payload)
+	if err := t.send(m.Bytes()); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", dev.devID, dev.fCnt), nil
+}
+
+func (t *soakTarget) Join() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dev := t.joinDevices[t.nextJoin%len(t.joinDevices)]
+	t.nextJoin++
+
+	var devNonce [2]byte
+	rand.Read(devNonce[:])
+	joinReq := &pb_lorawan.Message{
+		MHDR: pb_lorawan.MHDR{MType: pb_lorawan.MType_JOIN_REQUEST, Major: pb_lorawan.Major_LORAWAN_R1},
+		Payload: &pb_lorawan.Message_JoinRequestPayload{JoinRequestPayload: &pb_lorawan.JoinRequestPayload{
+			AppEUI:   dev.appEUI,
+			DevEUI:   dev.devEUI,
+			DevNonce: types.DevNonce(devNonce),
+		}}}
+	joinPhy := joinReq.PHYPayload()
+	joinPhy.SetMIC(lorawan.AES128Key(dev.appKey))
+	payload, err := joinPhy.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return t.send(payload)
+}
+
+func (t *soakTarget) SetGatewayUp(up bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case up && t.stream == nil:
+		t.stream = t.router.NewGatewayStreams(t.gatewayID, t.gatewayToken, false)
+	case !up && t.stream != nil:
+		t.stream.Close()
+		t.stream = nil
+	}
+	return nil
+}
+
+func init() {
+	RootCmd.AddCommand(soakCmd)
+	soakCmd.Flags().String("access-key", "", "The access key to use for MQTT")
+	soakCmd.Flags().Duration("grace", 10*time.Second, "Time to wait for uplinks that are underway when the scenario ends")
+	soakCmd.Flags().String("report", "", "File to write the JSON report to (default stdout)")
+	soakCmd.Flags().String("gateway-id", "", "The ID of the gateway that you are faking (you can only fake gateways that you own)")
+}
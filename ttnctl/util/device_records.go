@@ -0,0 +1,252 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/TheThingsNetwork/api"
+	"github.com/TheThingsNetwork/api/handler"
+	"github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// DeviceRecord is the representation of a device in an import or export file
+type DeviceRecord struct {
+	DevID       string            `json:"dev_id"`
+	Description string            `json:"description,omitempty"`
+	AppEUI      types.AppEUI      `json:"app_eui"`
+	DevEUI      types.DevEUI      `json:"dev_eui"`
+	AppKey      types.AppKey      `json:"app_key,omitempty"`
+	DevAddr     types.DevAddr     `json:"dev_addr,omitempty"`
+	NwkSKey     types.NwkSKey     `json:"nwk_s_key,omitempty"`
+	AppSKey     types.AppSKey     `json:"app_s_key,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+}
+
+// The supported formats for device records
+const (
+	DeviceRecordsCSV  = "csv"
+	DeviceRecordsJSON = "json"
+)
+
+var deviceRecordColumns = []string{"dev_id", "description", "app_eui", "dev_eui", "app_key", "dev_addr", "nwk_s_key", "app_s_key", "attributes"}
+
+// IsABP returns true if the record contains session keys, but no AppKey. Devices that joined over the air
+// also have session keys, but those are replaced on the next join.
+func (r *DeviceRecord) IsABP() bool {
+	return r.AppKey.IsEmpty() && !r.DevAddr.IsEmpty()
+}
+
+// Validate the device record
+func (r *DeviceRecord) Validate() error {
+	if err := api.NotEmptyAndValidID(r.DevID, "Device ID"); err != nil {
+		return err
+	}
+	if r.IsABP() {
+		if r.NwkSKey.IsEmpty() {
+			return fmt.Errorf("Device %s: NwkSKey is required for ABP", r.DevID)
+		}
+		if r.AppSKey.IsEmpty() {
+			return fmt.Errorf("Device %s: AppSKey is required for ABP", r.DevID)
+		}
+		return nil
+	}
+	if r.DevEUI.IsEmpty() {
+		return fmt.Errorf("Device %s: DevEUI is required for OTAA", r.DevID)
+	}
+	if r.AppKey.IsEmpty() {
+		return fmt.Errorf("Device %s: AppKey is required for OTAA", r.DevID)
+	}
+	return nil
+}
+
+// ToDevice converts the record to a device for the given application
+func (r *DeviceRecord) ToDevice(appID string, appEUI types.AppEUI) *handler.Device {
+	if !r.AppEUI.IsEmpty() {
+		appEUI = r.AppEUI
+	}
+	dev := &lorawan.Device{
+		AppID:         appID,
+		DevID:         r.DevID,
+		AppEUI:        appEUI,
+		DevEUI:        r.DevEUI,
+		Uses32BitFCnt: true,
+	}
+	if r.IsABP() {
+		var emptyAppKey types.AppKey
+		devAddr, nwkSKey, appSKey := r.DevAddr, r.NwkSKey, r.AppSKey
+		dev.AppKey = &emptyAppKey
+		dev.DevAddr = &devAddr
+		dev.NwkSKey = &nwkSKey
+		dev.AppSKey = &appSKey
+		dev.ActivationConstraints = "abp"
+	} else {
+		appKey := r.AppKey
+		dev.AppKey = &appKey
+	}
+	return &handler.Device{
+		AppID:       appID,
+		DevID:       r.DevID,
+		Description: r.Description,
+		Attributes:  r.Attributes,
+		Device:      &handler.Device_LoRaWANDevice{LoRaWANDevice: dev},
+	}
+}
+
+// DeviceRecordFromDevice converts a device to a record
+func DeviceRecordFromDevice(dev *handler.Device) *DeviceRecord {
+	r := &DeviceRecord{
+		DevID:       dev.DevID,
+		Description: dev.Description,
+		Attributes:  dev.Attributes,
+	}
+	if lorawan := dev.GetLoRaWANDevice(); lorawan != nil {
+		r.AppEUI = lorawan.AppEUI
+		r.DevEUI = lorawan.DevEUI
+		if lorawan.AppKey != nil {
+			r.AppKey = *lorawan.AppKey
+		}
+		if lorawan.DevAddr != nil {
+			r.DevAddr = *lorawan.DevAddr
+		}
+		if lorawan.NwkSKey != nil {
+			r.NwkSKey = *lorawan.NwkSKey
+		}
+		if lorawan.AppSKey != nil {
+			r.AppSKey = *lorawan.AppSKey
+		}
+	}
+	return r
+}
+
+// ReadDeviceRecords reads device records in the given format
+func ReadDeviceRecords(in io.Reader, format string) ([]*DeviceRecord, error) {
+	switch format {
+	case DeviceRecordsJSON:
+		var records []*DeviceRecord
+		if err := json.NewDecoder(in).Decode(&records); err != nil {
+			return nil, err
+		}
+		return records, nil
+	case DeviceRecordsCSV:
+		return readDeviceRecordsCSV(in)
+	}
+	return nil, fmt.Errorf("Unknown format: %s", format)
+}
+
+func readDeviceRecordsCSV(in io.Reader) (records []*DeviceRecord, err error) {
+	reader := csv.NewReader(in)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["dev_id"]; !ok {
+		return nil, fmt.Errorf("Missing dev_id column")
+	}
+	line := 1
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, err
+		}
+		get := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+		r := &DeviceRecord{
+			DevID:       strings.ToLower(get("dev_id")),
+			Description: get("description"),
+		}
+		if r.AppEUI, err = types.ParseAppEUI(get("app_eui")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid AppEUI: %s", line, err)
+		}
+		if r.DevEUI, err = types.ParseDevEUI(get("dev_eui")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid DevEUI: %s", line, err)
+		}
+		if r.AppKey, err = types.ParseAppKey(get("app_key")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid AppKey: %s", line, err)
+		}
+		if r.DevAddr, err = types.ParseDevAddr(get("dev_addr")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid DevAddr: %s", line, err)
+		}
+		if r.NwkSKey, err = types.ParseNwkSKey(get("nwk_s_key")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid NwkSKey: %s", line, err)
+		}
+		if r.AppSKey, err = types.ParseAppSKey(get("app_s_key")); err != nil {
+			return nil, fmt.Errorf("Line %d: invalid AppSKey: %s", line, err)
+		}
+		if attributes := get("attributes"); attributes != "" {
+			r.Attributes = make(map[string]string)
+			for _, pair := range strings.Split(attributes, ";") {
+				kv := strings.SplitN(pair, "=", 2)
+				if len(kv) != 2 {
+					return nil, fmt.Errorf("Line %d: invalid attribute: %s", line, pair)
+				}
+				r.Attributes[kv[0]] = kv[1]
+			}
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// WriteDeviceRecords writes device records in the given format
+func WriteDeviceRecords(out io.Writer, format string, records []*DeviceRecord) error {
+	switch format {
+	case DeviceRecordsJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case DeviceRecordsCSV:
+		return writeDeviceRecordsCSV(out, records)
+	}
+	return fmt.Errorf("Unknown format: %s", format)
+}
+
+func writeDeviceRecordsCSV(out io.Writer, records []*DeviceRecord) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(deviceRecordColumns); err != nil {
+		return err
+	}
+	for _, r := range records {
+		var attributes []string
+		for k, v := range r.Attributes {
+			attributes = append(attributes, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(attributes)
+		err := writer.Write([]string{
+			r.DevID,
+			r.Description,
+			r.AppEUI.String(),
+			r.DevEUI.String(),
+			r.AppKey.String(),
+			r.DevAddr.String(),
+			r.NwkSKey.String(),
+			r.AppSKey.String(),
+			strings.Join(attributes, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,77 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestReadDeviceRecordsCSV(t *testing.T) {
+	a := New(t)
+
+	in := `dev_id,dev_eui,app_key,attributes
+otaa-1,0102030405060708,01020304050607080102030405060708,ttn-brand=foo;ttn-model=bar
+otaa-2,0102030405060709,01020304050607080102030405060709,
+`
+	records, err := ReadDeviceRecords(strings.NewReader(in), DeviceRecordsCSV)
+	a.So(err, ShouldBeNil)
+	a.So(records, ShouldHaveLength, 2)
+	a.So(records[0].DevID, ShouldEqual, "otaa-1")
+	a.So(records[0].DevEUI, ShouldEqual, types.DevEUI{1, 2, 3, 4, 5, 6, 7, 8})
+	a.So(records[0].Attributes, ShouldResemble, map[string]string{"ttn-brand": "foo", "ttn-model": "bar"})
+	a.So(records[1].Attributes, ShouldBeNil)
+	for _, r := range records {
+		a.So(r.Validate(), ShouldBeNil)
+		a.So(r.IsABP(), ShouldBeFalse)
+	}
+
+	_, err = ReadDeviceRecords(strings.NewReader("dev_eui\n0102030405060708\n"), DeviceRecordsCSV)
+	a.So(err, ShouldNotBeNil)
+
+	_, err = ReadDeviceRecords(strings.NewReader("dev_id,dev_eui\ntest,nothex\n"), DeviceRecordsCSV)
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestDeviceRecordValidate(t *testing.T) {
+	a := New(t)
+
+	a.So((&DeviceRecord{}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceRecord{DevID: "test"}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceRecord{DevID: "test", DevEUI: types.DevEUI{1}}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceRecord{DevID: "test", DevEUI: types.DevEUI{1}, AppKey: types.AppKey{1}}).Validate(), ShouldBeNil)
+	a.So((&DeviceRecord{DevID: "test", DevAddr: types.DevAddr{1}, NwkSKey: types.NwkSKey{1}}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceRecord{DevID: "test", DevAddr: types.DevAddr{1}, NwkSKey: types.NwkSKey{1}, AppSKey: types.AppSKey{1}}).Validate(), ShouldBeNil)
+}
+
+func TestDeviceRecordsRoundTrip(t *testing.T) {
+	a := New(t)
+
+	records := []*DeviceRecord{
+		{DevID: "otaa", AppEUI: types.AppEUI{1}, DevEUI: types.DevEUI{2}, AppKey: types.AppKey{3}, Attributes: map[string]string{"ttn-brand": "foo"}},
+		{DevID: "abp", AppEUI: types.AppEUI{1}, DevAddr: types.DevAddr{4}, NwkSKey: types.NwkSKey{5}, AppSKey: types.AppSKey{6}, Description: "Some, description"},
+	}
+
+	for _, format := range []string{DeviceRecordsCSV, DeviceRecordsJSON} {
+		var buf bytes.Buffer
+		a.So(WriteDeviceRecords(&buf, format, records), ShouldBeNil)
+		read, err := ReadDeviceRecords(&buf, format)
+		a.So(err, ShouldBeNil)
+		a.So(read, ShouldResemble, records)
+	}
+
+	dev := records[1].ToDevice("app", types.AppEUI{})
+	a.So(dev.GetLoRaWANDevice().ActivationConstraints, ShouldEqual, "abp")
+	a.So(DeviceRecordFromDevice(dev), ShouldResemble, records[1])
+
+	joined := &DeviceRecord{DevID: "joined", DevEUI: types.DevEUI{2}, AppKey: types.AppKey{3}, DevAddr: types.DevAddr{4}, NwkSKey: types.NwkSKey{5}, AppSKey: types.AppSKey{6}}
+	a.So(joined.IsABP(), ShouldBeFalse)
+	dev = joined.ToDevice("app", types.AppEUI{1})
+	a.So(*dev.GetLoRaWANDevice().AppKey, ShouldEqual, types.AppKey{3})
+	a.So(dev.GetLoRaWANDevice().ActivationConstraints, ShouldBeEmpty)
+}
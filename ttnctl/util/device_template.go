@@ -0,0 +1,132 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/TheThingsNetwork/api/handler"
+	"github.com/TheThingsNetwork/api/protocol/lorawan"
+	"github.com/TheThingsNetwork/ttn/core/handler/device"
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/otaa"
+)
+
+// DeviceTemplate contains the settings that are shared by a batch of devices
+type DeviceTemplate struct {
+	DevIDPrefix           string            `json:"dev_id_prefix,omitempty"`
+	Description           string            `json:"description,omitempty"`
+	AppEUI                types.AppEUI      `json:"app_eui,omitempty"`
+	ActivationConstraints string            `json:"activation_constraints,omitempty"`
+	Uses16BitFCnt         bool              `json:"uses_16_bit_fcnt,omitempty"`
+	DisableFCntCheck      bool              `json:"disable_fcnt_check,omitempty"`
+	Latitude              float32           `json:"latitude,omitempty"`
+	Longitude             float32           `json:"longitude,omitempty"`
+	Altitude              int32             `json:"altitude,omitempty"`
+	FrequencyPlan         string            `json:"frequency_plan,omitempty"` // for example EU_863_870
+	Class                 string            `json:"class,omitempty"`          // A, B or C
+	ADRMargin             int               `json:"adr_margin,omitempty"`     // the SNR margin for ADR in dB
+	PayloadFormat         string            `json:"payload_format,omitempty"` // the payload format of the application
+	Attributes            map[string]string `json:"attributes,omitempty"`
+}
+
+// ReadDeviceTemplate reads a JSON-encoded device template
+func ReadDeviceTemplate(in io.Reader) (*DeviceTemplate, error) {
+	template := new(DeviceTemplate)
+	if err := json.NewDecoder(in).Decode(template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// Validate the settings of the template
+func (t *DeviceTemplate) Validate() error {
+	if t.FrequencyPlan != "" {
+		if _, ok := lorawan.FrequencyPlan_value[t.FrequencyPlan]; !ok {
+			return fmt.Errorf("Unknown frequency plan %s", t.FrequencyPlan)
+		}
+	}
+	switch t.Class {
+	case "", "A", "B", "C":
+	default:
+		return fmt.Errorf("Unknown class %s, must be A, B or C", t.Class)
+	}
+	if t.ADRMargin < 0 {
+		return fmt.Errorf("ADR margin can not be negative")
+	}
+	switch t.PayloadFormat {
+	case "", "custom", "cayennelpp":
+	default:
+		return fmt.Errorf("Unknown payload format %s, must be custom or cayennelpp", t.PayloadFormat)
+	}
+	return nil
+}
+
+// DevID returns the Device ID for the device with the given DevEUI
+func (t *DeviceTemplate) DevID(devEUI types.DevEUI) string {
+	prefix := t.DevIDPrefix
+	if prefix == "" {
+		prefix = "dev-"
+	}
+	return strings.ToLower(fmt.Sprintf("%s%s", prefix, devEUI))
+}
+
+// NewDeviceRecord creates a record for the device with the given DevEUI, deriving its AppKey from the root key
+func (t *DeviceTemplate) NewDeviceRecord(rootKey types.AES128Key, devEUI types.DevEUI) *DeviceRecord {
+	var attributes map[string]string
+	if len(t.Attributes) > 0 {
+		attributes = make(map[string]string, len(t.Attributes))
+		for k, v := range t.Attributes {
+			attributes[k] = v
+		}
+	}
+	return &DeviceRecord{
+		DevID:       t.DevID(devEUI),
+		Description: t.Description,
+		AppEUI:      t.AppEUI,
+		DevEUI:      devEUI,
+		AppKey:      otaa.DeriveAppKey(rootKey, devEUI),
+		Attributes:  attributes,
+	}
+}
+
+// Apply the template settings that are not part of a device record. The frequency plan, class and ADR margin are
+// set as attributes of the device. The payload format is a setting of the application and is not applied here.
+func (t *DeviceTemplate) Apply(dev *handler.Device) {
+	dev.Latitude = t.Latitude
+	dev.Longitude = t.Longitude
+	dev.Altitude = t.Altitude
+	settings := make(map[string]string)
+	if t.FrequencyPlan != "" {
+		settings[device.FrequencyPlanAttribute] = t.FrequencyPlan
+	}
+	if t.Class != "" {
+		settings[device.ClassAttribute] = t.Class
+	}
+	if t.ADRMargin != 0 {
+		settings[device.ADRMarginAttribute] = strconv.Itoa(t.ADRMargin)
+	}
+	if len(settings) > 0 {
+		// The attributes of the device may be shared with its record, so they are copied
+		attributes := make(map[string]string, len(dev.Attributes)+len(settings))
+		for k, v := range dev.Attributes {
+			attributes[k] = v
+		}
+		for k, v := range settings {
+			attributes[k] = v
+		}
+		dev.Attributes = attributes
+	}
+	if lorawan := dev.GetLoRaWANDevice(); lorawan != nil {
+		lorawan.Uses32BitFCnt = !t.Uses16BitFCnt
+		lorawan.DisableFCntCheck = t.DisableFCntCheck
+		if t.ActivationConstraints != "" {
+			lorawan.ActivationConstraints = t.ActivationConstraints
+		}
+	}
+}
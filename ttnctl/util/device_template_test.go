@@ -0,0 +1,60 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/otaa"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeviceTemplate(t *testing.T) {
+	a := New(t)
+
+	template, err := ReadDeviceTemplate(strings.NewReader(`{
+		"dev_id_prefix": "sensor-",
+		"description": "Sensor",
+		"uses_16_bit_fcnt": true,
+		"frequency_plan": "EU_863_870",
+		"class": "C",
+		"adr_margin": 10,
+		"payload_format": "cayennelpp",
+		"attributes": {"ttn-model": "sensor"}
+	}`))
+	a.So(err, ShouldBeNil)
+	a.So(template.Validate(), ShouldBeNil)
+	a.So(template.PayloadFormat, ShouldEqual, "cayennelpp")
+
+	rootKey := types.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	devEUI := types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x00, 0x01}
+
+	record := template.NewDeviceRecord(rootKey, devEUI)
+	a.So(record.DevID, ShouldEqual, "sensor-70b3d57ed0000001")
+	a.So(record.Description, ShouldEqual, "Sensor")
+	a.So(record.AppKey, ShouldEqual, otaa.DeriveAppKey(rootKey, devEUI))
+	a.So(record.Attributes, ShouldResemble, map[string]string{"ttn-model": "sensor"})
+	a.So(record.Validate(), ShouldBeNil)
+
+	dev := record.ToDevice("test", types.AppEUI{1})
+	template.Apply(dev)
+	a.So(dev.GetLoRaWANDevice().Uses32BitFCnt, ShouldBeFalse)
+	a.So(dev.GetLoRaWANDevice().AppEUI, ShouldEqual, types.AppEUI{1})
+	a.So(dev.Attributes, ShouldResemble, map[string]string{
+		"ttn-model":          "sensor",
+		"ttn-frequency-plan": "EU_863_870",
+		"ttn-class":          "C",
+		"ttn-adr-margin":     "10",
+	})
+	a.So(record.Attributes, ShouldNotContainKey, "ttn-class")
+
+	a.So((&DeviceTemplate{FrequencyPlan: "EU_868"}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceTemplate{Class: "D"}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceTemplate{ADRMargin: -1}).Validate(), ShouldNotBeNil)
+	a.So((&DeviceTemplate{PayloadFormat: "json"}).Validate(), ShouldNotBeNil)
+
+	a.So((&DeviceTemplate{}).DevID(devEUI), ShouldEqual, "dev-70b3d57ed0000001")
+}
@@ -5,12 +5,17 @@ package util
 
 import (
 	"github.com/TheThingsNetwork/api/discovery"
+	"github.com/TheThingsNetwork/api/handler"
 	"github.com/TheThingsNetwork/api/handler/handlerclient"
+	"github.com/TheThingsNetwork/api/protocol/lorawan"
 	"github.com/TheThingsNetwork/go-account-lib/scope"
 	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+	"github.com/TheThingsNetwork/ttn/core/types"
 	"github.com/TheThingsNetwork/ttn/utils/errors"
 	"github.com/spf13/viper"
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // GetHandlerManager gets a new HandlerManager for ttnctl
@@ -39,3 +44,34 @@ func GetHandlerManager(ctx ttnlog.Interface, appID string) (*grpc.ClientConn, *h
 	}
 	return hdlConn, managerClient
 }
+
+// SetDeviceResettingFCnt sets the device on the Handler, confirming that the frame counters of its session may be
+// lowered. Without this confirmation, the Handler refuses updates that lower the frame counters.
+func SetDeviceResettingFCnt(ctx ttnlog.Interface, conn *grpc.ClientConn, appID string, dev *handler.Device) error {
+	md := metadata.Pairs(
+		"token", TokenForScope(ctx, scope.App(appID)),
+		"confirm-fcnt-reset", "true",
+	)
+	_, err := handler.NewApplicationManagerClient(conn).SetDevice(metadata.NewOutgoingContext(context.Background(), md), dev)
+	return errors.FromGRPCError(err)
+}
+
+// ClaimDevice claims the factory-provisioned device with the DevEUI for the application on the Handler, with the
+// claim code of the device. The keys of the device are set by the Handler.
+func ClaimDevice(ctx ttnlog.Interface, conn *grpc.ClientConn, appID, devID string, devEUI types.DevEUI, claimCode string) error {
+	md := metadata.Pairs(
+		"token", TokenForScope(ctx, scope.App(appID)),
+		"claim-code", claimCode,
+	)
+	dev := &handler.Device{
+		AppID: appID,
+		DevID: devID,
+		Device: &handler.Device_LoRaWANDevice{LoRaWANDevice: &lorawan.Device{
+			AppID:  appID,
+			DevID:  devID,
+			DevEUI: devEUI,
+		}},
+	}
+	_, err := handler.NewApplicationManagerClient(conn).SetDevice(metadata.NewOutgoingContext(context.Background(), md), dev)
+	return errors.FromGRPCError(err)
+}
@@ -0,0 +1,45 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package budget calculates how much time is left to respond to an uplink
+package budget
+
+import "time"
+
+// Margin is the time before transmission at which a downlink must be ready to be sent to the gateway.
+// The router still sends downlinks that arrive after its own scheduling deadline, so the margin only
+// has to cover the delivery of the downlink to the gateway.
+var Margin = 50 * time.Millisecond
+
+// Deadline returns the time at which a downlink with the given gateway timestamp must be ready, for an
+// uplink with the given gateway timestamp that was received by the server at receivedAt. Timestamps
+// are in microseconds of the gateway's concentrator counter. A zero downlink timestamp means that the
+// downlink is not scheduled relative to the uplink, in which case the zero time is returned.
+func Deadline(receivedAt time.Time, uplinkTimestamp, downlinkTimestamp uint32) time.Time {
+	if downlinkTimestamp == 0 {
+		return time.Time{}
+	}
+	delay := time.Duration(downlinkTimestamp-uplinkTimestamp) * time.Microsecond
+	return receivedAt.Add(delay - Margin)
+}
+
+// Expired returns true if the deadline has passed. The zero deadline never expires.
+func Expired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+// Remaining returns the time that is left until the deadline, capped at max. The zero deadline
+// always has max remaining.
+func Remaining(deadline time.Time, max time.Duration) time.Duration {
+	if deadline.IsZero() {
+		return max
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		return 0
+	}
+	if remaining > max {
+		return max
+	}
+	return remaining
+}
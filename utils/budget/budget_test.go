@@ -0,0 +1,42 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package budget
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeadline(t *testing.T) {
+	a := New(t)
+
+	now := time.Now()
+	a.So(Deadline(now, 1000, 1001000), ShouldEqual, now.Add(time.Second-Margin))
+	a.So(Deadline(now, 1000, 2001000), ShouldEqual, now.Add(2*time.Second-Margin))
+	a.So(Deadline(now, 1000, 0).IsZero(), ShouldBeTrue)
+
+	// Counter rollover
+	a.So(Deadline(now, 4294967000, 999704), ShouldEqual, now.Add(time.Second-Margin))
+}
+
+func TestExpired(t *testing.T) {
+	a := New(t)
+
+	a.So(Expired(time.Time{}), ShouldBeFalse)
+	a.So(Expired(time.Now().Add(time.Second)), ShouldBeFalse)
+	a.So(Expired(time.Now().Add(-1*time.Millisecond)), ShouldBeTrue)
+}
+
+func TestRemaining(t *testing.T) {
+	a := New(t)
+
+	a.So(Remaining(time.Time{}, time.Second), ShouldEqual, time.Second)
+	a.So(Remaining(time.Now().Add(time.Hour), time.Second), ShouldEqual, time.Second)
+	a.So(Remaining(time.Now().Add(-1*time.Second), time.Second), ShouldEqual, time.Duration(0))
+	remaining := Remaining(time.Now().Add(500*time.Millisecond), time.Second)
+	a.So(remaining, ShouldBeGreaterThan, 400*time.Millisecond)
+	a.So(remaining, ShouldBeLessThan, 500*time.Millisecond+1)
+}
@@ -0,0 +1,159 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package chaos injects faults in the messages between components and in storage operations, so that operators can
+// validate that their deployments survive them. Faults are only injected in builds with the chaos build tag
+// (make TAGS=chaos), and are configured at runtime on the status server with the admin token.
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Path is the path where the faults are configured on the status server
+const Path = "/chaos"
+
+// ErrInjected is returned by operations that fail because of an injected fault
+var ErrInjected = errors.New("Injected fault")
+
+// Faults are the faults that are injected
+type Faults struct {
+	DropRate           float64       `json:"drop_rate"`            // fraction of the messages between components that is dropped
+	Latency            time.Duration `json:"-"`                    // latency that is added to the messages between components
+	StorageFailureRate float64       `json:"storage_failure_rate"` // fraction of the storage operations that fail
+}
+
+// faults has the fields of Faults without its JSON methods
+type faults Faults
+
+type faultsJSON struct {
+	faults
+	Latency string `json:"latency"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (f Faults) MarshalJSON() ([]byte, error) {
+	return json.Marshal(faultsJSON{faults: faults(f), Latency: f.Latency.String()})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *Faults) UnmarshalJSON(data []byte) error {
+	var out faultsJSON
+	if err := json.Unmarshal(data, &out); err != nil {
+		return err
+	}
+	*f = Faults(out.faults)
+	if out.Latency != "" {
+		latency, err := time.ParseDuration(out.Latency)
+		if err != nil {
+			return err
+		}
+		f.Latency = latency
+	}
+	return nil
+}
+
+// Validate the faults
+func (f Faults) Validate() error {
+	if f.DropRate < 0 || f.DropRate > 1 {
+		return errors.NewErrInvalidArgument("Drop rate", "must be between 0 and 1")
+	}
+	if f.Latency < 0 {
+		return errors.NewErrInvalidArgument("Latency", "can not be negative")
+	}
+	if f.StorageFailureRate < 0 || f.StorageFailureRate > 1 {
+		return errors.NewErrInvalidArgument("Storage failure rate", "must be between 0 and 1")
+	}
+	return nil
+}
+
+var (
+	mu      sync.Mutex
+	current Faults
+	random  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Set the faults that are injected
+func Set(f Faults) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if !Enabled {
+		return errors.NewErrInvalidArgument("Faults", "this build does not inject faults")
+	}
+	mu.Lock()
+	current = f
+	mu.Unlock()
+	return nil
+}
+
+// Get the faults that are injected
+func Get() Faults {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+func inject(rate func(Faults) float64) bool {
+	if !Enabled {
+		return false
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return random.Float64() < rate(current)
+}
+
+// Drop returns true if a message between components should be dropped
+func Drop() bool {
+	return inject(func(f Faults) float64 { return f.DropRate })
+}
+
+// Delay waits for the latency that is added to messages between components
+func Delay() {
+	if !Enabled {
+		return
+	}
+	if latency := Get().Latency; latency > 0 {
+		time.Sleep(latency)
+	}
+}
+
+// FailStorage returns ErrInjected if a storage operation should fail
+func FailStorage() error {
+	if inject(func(f Faults) float64 { return f.StorageFailureRate }) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// Handler returns the faults on GET, sets them to the faults in the JSON body on PUT and stops injecting faults on
+// DELETE
+var Handler http.Handler = http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET", "HEAD":
+	case "PUT":
+		var f Faults
+		if err := json.NewDecoder(req.Body).Decode(&f); err != nil {
+			http.Error(res, fmt.Sprintf("Invalid faults: %s", err), http.StatusBadRequest)
+			return
+		}
+		if err := Set(f); err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "DELETE":
+		Set(Faults{})
+	default:
+		http.Error(res, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(Get())
+})
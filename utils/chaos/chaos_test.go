@@ -0,0 +1,58 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/assertions"
+)
+
+func TestFaults(t *testing.T) {
+	a := New(t)
+
+	a.So(Faults{DropRate: 0.5, Latency: time.Second}.Validate(), ShouldBeNil)
+	a.So(Faults{DropRate: 2}.Validate(), ShouldNotBeNil)
+	a.So(Faults{Latency: -time.Second}.Validate(), ShouldNotBeNil)
+	a.So(Faults{StorageFailureRate: -1}.Validate(), ShouldNotBeNil)
+
+	data, err := json.Marshal(Faults{DropRate: 0.1, Latency: 50 * time.Millisecond})
+	a.So(err, ShouldBeNil)
+	a.So(string(data), ShouldContainSubstring, `"latency":"50ms"`)
+
+	var f Faults
+	a.So(json.Unmarshal(data, &f), ShouldBeNil)
+	a.So(f, ShouldResemble, Faults{DropRate: 0.1, Latency: 50 * time.Millisecond})
+}
+
+func TestHandler(t *testing.T) {
+	a := New(t)
+	defer Set(Faults{})
+
+	req := httptest.NewRequest("PUT", Path, strings.NewReader(`{"drop_rate":1,"latency":"1ms","storage_failure_rate":1}`))
+	res := httptest.NewRecorder()
+	Handler.ServeHTTP(res, req)
+
+	if !Enabled {
+		a.So(res.Code, ShouldEqual, http.StatusBadRequest)
+		a.So(Drop(), ShouldBeFalse)
+		a.So(FailStorage(), ShouldBeNil)
+		return
+	}
+
+	a.So(res.Code, ShouldEqual, http.StatusOK)
+	a.So(Get(), ShouldResemble, Faults{DropRate: 1, Latency: time.Millisecond, StorageFailureRate: 1})
+	a.So(Drop(), ShouldBeTrue)
+	a.So(FailStorage(), ShouldEqual, ErrInjected)
+
+	res = httptest.NewRecorder()
+	Handler.ServeHTTP(res, httptest.NewRequest("DELETE", Path, nil))
+	a.So(Get(), ShouldResemble, Faults{})
+	a.So(Drop(), ShouldBeFalse)
+}
@@ -0,0 +1,9 @@
+// +build chaos
+
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chaos
+
+// Enabled is true in builds with the chaos build tag
+const Enabled = true
@@ -0,0 +1,51 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"golang.org/x/net/context" // See https://github.com/grpc/grpc-go/issues/711"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// UnaryServerInterceptor delays requests and fails the requests that are dropped
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !Enabled {
+		return handler(ctx, req)
+	}
+	Delay()
+	if Drop() {
+		return nil, grpc.Errorf(codes.Unavailable, ErrInjected.Error())
+	}
+	return handler(ctx, req)
+}
+
+// StreamServerInterceptor delays the messages of streams and drops some of them
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !Enabled {
+		return handler(srv, ss)
+	}
+	return handler(srv, &stream{ss})
+}
+
+type stream struct {
+	grpc.ServerStream
+}
+
+func (s *stream) SendMsg(m interface{}) error {
+	Delay()
+	if Drop() {
+		return nil
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *stream) RecvMsg(m interface{}) error {
+	for {
+		Delay()
+		if err := s.ServerStream.RecvMsg(m); err != nil || !Drop() {
+			return err
+		}
+	}
+}
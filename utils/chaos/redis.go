@@ -0,0 +1,47 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package chaos
+
+import (
+	"net"
+	"time"
+
+	"gopkg.in/redis.v5"
+)
+
+// RedisOptions returns the options with a dialer for connections that fail some of the storage operations
+func RedisOptions(opt *redis.Options) *redis.Options {
+	if !Enabled {
+		return opt
+	}
+	dial := opt.Dialer
+	if dial == nil {
+		dial = func() (net.Conn, error) {
+			timeout := opt.DialTimeout
+			if timeout == 0 {
+				timeout = 5 * time.Second
+			}
+			return net.DialTimeout("tcp", opt.Addr, timeout)
+		}
+	}
+	opt.Dialer = func() (net.Conn, error) {
+		conn, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		return &faultyConn{conn}, nil
+	}
+	return opt
+}
+
+type faultyConn struct {
+	net.Conn
+}
+
+func (c *faultyConn) Write(b []byte) (int, error) {
+	if err := FailStorage(); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
@@ -18,13 +18,14 @@ type ErrType string
 
 // These constants represent error types
 const (
-	AlreadyExists    ErrType = "already exists"
-	Internal         ErrType = "internal"
-	InvalidArgument  ErrType = "invalid argument"
-	NotFound         ErrType = "not found"
-	OutOfRange       ErrType = "out of range"
-	PermissionDenied ErrType = "permission denied"
-	Unknown          ErrType = "unknown"
+	AlreadyExists      ErrType = "already exists"
+	FailedPrecondition ErrType = "failed precondition"
+	Internal           ErrType = "internal"
+	InvalidArgument    ErrType = "invalid argument"
+	NotFound           ErrType = "not found"
+	OutOfRange         ErrType = "out of range"
+	PermissionDenied   ErrType = "permission denied"
+	Unknown            ErrType = "unknown"
 )
 
 // GetErrType returns the type of err
@@ -32,6 +33,8 @@ func GetErrType(err error) ErrType {
 	switch errs.Cause(err).(type) {
 	case *ErrAlreadyExists:
 		return AlreadyExists
+	case *ErrFailedPrecondition:
+		return FailedPrecondition
 	case *ErrInternal:
 		return Internal
 	case *ErrInvalidArgument:
@@ -59,6 +62,11 @@ func IsInvalidArgument(err error) bool {
 	return GetErrType(err) == InvalidArgument
 }
 
+// IsFailedPrecondition returns whether error type is FailedPrecondition
+func IsFailedPrecondition(err error) bool {
+	return GetErrType(err) == FailedPrecondition
+}
+
 // IsInternal returns whether error type is Internal
 func IsInternal(err error) bool {
 	return GetErrType(err) == Internal
@@ -81,6 +89,8 @@ func BuildGRPCError(err error) error {
 	switch errs.Cause(err).(type) {
 	case *ErrAlreadyExists:
 		code = codes.AlreadyExists
+	case *ErrFailedPrecondition:
+		code = codes.FailedPrecondition
 	case *ErrInternal:
 		code = codes.Internal
 	case *ErrInvalidArgument:
@@ -114,6 +124,8 @@ func FromGRPCError(err error) error {
 	switch code {
 	case codes.AlreadyExists:
 		return NewErrAlreadyExists(strings.TrimSuffix(desc, " already exists"))
+	case codes.FailedPrecondition:
+		return NewErrFailedPrecondition(strings.TrimPrefix(desc, "failed precondition: "))
 	case codes.Internal:
 		return NewErrInternal(strings.TrimPrefix(desc, "Internal error: "))
 	case codes.InvalidArgument:
@@ -149,6 +161,21 @@ func (err ErrAlreadyExists) Error() string {
 	return fmt.Sprintf("%s already exists", err.entity)
 }
 
+// NewErrFailedPrecondition returns a new ErrFailedPrecondition with the given reason
+func NewErrFailedPrecondition(reason string) error {
+	return &ErrFailedPrecondition{reason: reason}
+}
+
+// ErrFailedPrecondition indicates that the system is not in the state that the operation requires
+type ErrFailedPrecondition struct {
+	reason string
+}
+
+// Error implements the error interface
+func (err ErrFailedPrecondition) Error() string {
+	return fmt.Sprintf("failed precondition: %s", err.reason)
+}
+
 // NewErrInternal returns a new ErrInternal with the given message
 func NewErrInternal(message string) error {
 	return &ErrInternal{message: message}
@@ -0,0 +1,126 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package conformance contains golden LoRaWAN frames and the fields that a decoder should find in them.
+//
+// The vectors are stored in testdata/vectors.json, so that implementations in other languages can
+// use them without this package. Each vector has a PHYPayload and the keys that apply to it, and
+// the expected fields after validating the MIC and decrypting with those keys. Byte fields are
+// uppercase hex, in the same order as they are displayed (and not in the little-endian wire order).
+package conformance
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+// Keys are the keys (in hex) that apply to a vector
+type Keys struct {
+	NwkSKey string `json:"nwk_s_key,omitempty"`
+	AppSKey string `json:"app_s_key,omitempty"`
+	AppKey  string `json:"app_key,omitempty"`
+}
+
+// Fields are the decoded fields of a frame. Fields that don't apply to the message type are left empty.
+type Fields struct {
+	MType  string `json:"m_type"`
+	Major  uint8  `json:"major"`
+	Uplink bool   `json:"uplink"`
+
+	// Data messages
+	DevAddr    string   `json:"dev_addr,omitempty"`
+	ADR        bool     `json:"adr,omitempty"`
+	ADRAckReq  bool     `json:"adr_ack_req,omitempty"`
+	Ack        bool     `json:"ack,omitempty"`
+	FPending   bool     `json:"f_pending,omitempty"`
+	FCnt       uint32   `json:"f_cnt,omitempty"`
+	FOpts      []string `json:"f_opts,omitempty"` // Name(PayloadHex) of the MAC commands
+	FPort      *uint8   `json:"f_port,omitempty"`
+	FRMPayload string   `json:"frm_payload,omitempty"` // after decryption
+
+	// FRMPayloadMAC contains the MAC commands of the FRMPayload on FPort 0, after decryption
+	FRMPayloadMAC []string `json:"frm_payload_mac,omitempty"`
+
+	// Join requests
+	AppEUI   string `json:"app_eui,omitempty"`
+	DevEUI   string `json:"dev_eui,omitempty"`
+	DevNonce string `json:"dev_nonce,omitempty"`
+
+	// Join accepts, after decryption (the DevAddr is in the DevAddr field)
+	AppNonce    string   `json:"app_nonce,omitempty"`
+	NetID       string   `json:"net_id,omitempty"`
+	RX1DROffset uint8    `json:"rx1_dr_offset,omitempty"`
+	RX2DataRate uint8    `json:"rx2_data_rate,omitempty"`
+	RXDelay     uint8    `json:"rx_delay,omitempty"`
+	CFList      []uint32 `json:"cf_list,omitempty"` // in Hz
+
+	MICValid  *bool `json:"mic_valid,omitempty"` // not set if there was no key to validate the MIC
+	Decrypted bool  `json:"decrypted,omitempty"`
+}
+
+// Vector is a golden frame
+type Vector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PHYPayload  string `json:"phy_payload"`
+	Keys        Keys   `json:"keys"`
+	Expected    Fields `json:"expected"`
+}
+
+// Payload returns the PHYPayload of the vector
+func (v Vector) Payload() ([]byte, error) {
+	return hex.DecodeString(v.PHYPayload)
+}
+
+// Load the vectors from a file
+func Load(filename string) ([]Vector, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var vectors []Vector
+	if err := json.NewDecoder(f).Decode(&vectors); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// Decoder decodes a PHYPayload with the keys of a vector. Slices that would be empty must be nil.
+type Decoder func(payload []byte, keys Keys) (*Fields, error)
+
+// Verify decodes the vectors and returns an error for every field that is not as expected
+func Verify(vectors []Vector, decode Decoder) (errs []error) {
+	for _, vector := range vectors {
+		payload, err := vector.Payload()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid PHYPayload: %s", vector.Name, err))
+			continue
+		}
+		decoded, err := decode(payload, vector.Keys)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not decode: %s", vector.Name, err))
+			continue
+		}
+		expected, actual := reflect.ValueOf(vector.Expected), reflect.ValueOf(*decoded)
+		for i := 0; i < expected.NumField(); i++ {
+			if !reflect.DeepEqual(expected.Field(i).Interface(), actual.Field(i).Interface()) {
+				errs = append(errs, fmt.Errorf("%s: %s is %s, expected %s", vector.Name, expected.Type().Field(i).Name, format(actual.Field(i)), format(expected.Field(i))))
+			}
+		}
+	}
+	return
+}
+
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "not set"
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
@@ -0,0 +1,149 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package conformance
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/TheThingsNetwork/ttn/utils/frame"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+const vectorsFile = "testdata/vectors.json"
+
+func decodeFrame(payload []byte, keys Keys) (*Fields, error) {
+	f, err := frame.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	var frameKeys frame.Keys
+	if keys.NwkSKey != "" {
+		key, err := types.ParseNwkSKey(keys.NwkSKey)
+		if err != nil {
+			return nil, err
+		}
+		frameKeys.NwkSKey = &key
+	}
+	if keys.AppSKey != "" {
+		key, err := types.ParseAppSKey(keys.AppSKey)
+		if err != nil {
+			return nil, err
+		}
+		frameKeys.AppSKey = &key
+	}
+	if keys.AppKey != "" {
+		key, err := types.ParseAppKey(keys.AppKey)
+		if err != nil {
+			return nil, err
+		}
+		frameKeys.AppKey = &key
+	}
+	if err := f.ApplyKeys(frameKeys); err != nil {
+		return nil, err
+	}
+	fields := &Fields{
+		MType:         f.MType,
+		Major:         f.Major,
+		Uplink:        f.Uplink,
+		DevAddr:       f.DevAddr.String(),
+		ADR:           f.ADR,
+		ADRAckReq:     f.ADRAckReq,
+		Ack:           f.Ack,
+		FPending:      f.FPending,
+		FCnt:          f.FCnt,
+		FOpts:         macCommands(f.FOpts),
+		FPort:         f.FPort,
+		FRMPayload:    strings.ToUpper(hex.EncodeToString(f.FRMPayload)),
+		FRMPayloadMAC: macCommands(f.FRMPayloadMAC),
+		AppEUI:        f.AppEUI.String(),
+		DevEUI:        f.DevEUI.String(),
+		DevNonce:      f.DevNonce.String(),
+		AppNonce:      f.AppNonce.String(),
+		NetID:         f.NetID.String(),
+		RX1DROffset:   f.RX1DROffset,
+		RX2DataRate:   f.RX2DataRate,
+		RXDelay:       f.RXDelay,
+		MICValid:      f.MICValid,
+		Decrypted:     f.Decrypted,
+	}
+	if len(f.CFList) > 0 {
+		fields.CFList = f.CFList
+	}
+	return fields, nil
+}
+
+func macCommands(commands []frame.MACCommand) (out []string) {
+	for _, command := range commands {
+		out = append(out, command.String())
+	}
+	return
+}
+
+func TestVectors(t *testing.T) {
+	a := New(t)
+
+	vectors, err := Load(vectorsFile)
+	a.So(err, ShouldBeNil)
+	a.So(vectors, ShouldNotBeEmpty)
+
+	for _, err := range Verify(vectors, decodeFrame) {
+		t.Error(err)
+	}
+}
+
+func TestVectorsMarshal(t *testing.T) {
+	a := New(t)
+
+	vectors, err := Load(vectorsFile)
+	a.So(err, ShouldBeNil)
+
+	// Marshaling a decoded frame must give the same bytes
+	for _, vector := range vectors {
+		payload, _ := vector.Payload()
+		var phy lorawan.PHYPayload
+		if err := phy.UnmarshalBinary(payload); err != nil {
+			t.Errorf("%s: could not unmarshal: %s", vector.Name, err)
+			continue
+		}
+		marshaled, err := phy.MarshalBinary()
+		if err != nil {
+			t.Errorf("%s: could not marshal: %s", vector.Name, err)
+			continue
+		}
+		if vector.PHYPayload != strings.ToUpper(hex.EncodeToString(marshaled)) {
+			t.Errorf("%s: marshaled to %X", vector.Name, marshaled)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	a := New(t)
+
+	valid := true
+	vectors := []Vector{
+		{Name: "ok", PHYPayload: "01", Expected: Fields{MType: "JoinRequest", MICValid: &valid}},
+		{Name: "mismatch", PHYPayload: "02", Expected: Fields{MType: "JoinRequest", FCnt: 2, MICValid: &valid}},
+		{Name: "error", PHYPayload: "03"},
+		{Name: "invalid", PHYPayload: "zz"},
+	}
+	errs := Verify(vectors, func(payload []byte, keys Keys) (*Fields, error) {
+		switch payload[0] {
+		case 1:
+			return &Fields{MType: "JoinRequest", MICValid: &valid}, nil
+		case 2:
+			return &Fields{MType: "JoinRequest", FCnt: 3}, nil
+		}
+		return nil, errors.New("invalid frame")
+	})
+	a.So(errs, ShouldHaveLength, 4)
+	a.So(errs[0].Error(), ShouldEqual, "mismatch: FCnt is 3, expected 2")
+	a.So(errs[1].Error(), ShouldEqual, "mismatch: MICValid is not set, expected true")
+	a.So(errs[2].Error(), ShouldStartWith, "error: could not decode")
+	a.So(errs[3].Error(), ShouldStartWith, "invalid: invalid PHYPayload")
+}
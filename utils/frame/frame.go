@@ -0,0 +1,266 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package frame decodes LoRaWAN frames for inspection and debugging
+package frame
+
+import (
+	"fmt"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/brocaar/lorawan"
+)
+
+// MType names
+var mTypes = map[lorawan.MType]string{
+	lorawan.JoinRequest:         "JoinRequest",
+	lorawan.JoinAccept:          "JoinAccept",
+	lorawan.UnconfirmedDataUp:   "UnconfirmedDataUp",
+	lorawan.UnconfirmedDataDown: "UnconfirmedDataDown",
+	lorawan.ConfirmedDataUp:     "ConfirmedDataUp",
+	lorawan.ConfirmedDataDown:   "ConfirmedDataDown",
+	lorawan.RFU:                 "RFU",
+	lorawan.Proprietary:         "Proprietary",
+}
+
+// MAC command names by CID, for commands that are sent by the device (uplink) and by the network (downlink)
+var (
+	uplinkMACCommands = map[lorawan.CID]string{
+		0x02: "LinkCheckReq",
+		0x03: "LinkADRAns",
+		0x04: "DutyCycleAns",
+		0x05: "RXParamSetupAns",
+		0x06: "DevStatusAns",
+		0x07: "NewChannelAns",
+		0x08: "RXTimingSetupAns",
+		0x09: "TXParamSetupAns",
+		0x0A: "DLChannelAns",
+	}
+	downlinkMACCommands = map[lorawan.CID]string{
+		0x02: "LinkCheckAns",
+		0x03: "LinkADRReq",
+		0x04: "DutyCycleReq",
+		0x05: "RXParamSetupReq",
+		0x06: "DevStatusReq",
+		0x07: "NewChannelReq",
+		0x08: "RXTimingSetupReq",
+		0x09: "TXParamSetupReq",
+		0x0A: "DLChannelReq",
+	}
+)
+
+// MACCommand is a decoded MAC command
+type MACCommand struct {
+	CID     byte
+	Name    string
+	Payload []byte
+}
+
+func (c MACCommand) String() string {
+	if len(c.Payload) == 0 {
+		return c.Name
+	}
+	return fmt.Sprintf("%s(%X)", c.Name, c.Payload)
+}
+
+// Frame is a decoded LoRaWAN frame
+type Frame struct {
+	MType  string
+	Major  byte
+	Uplink bool
+	MIC    [4]byte
+
+	// Data messages
+	DevAddr    types.DevAddr
+	ADR        bool
+	ADRAckReq  bool
+	Ack        bool
+	FPending   bool
+	FCnt       uint32
+	FOpts      []MACCommand
+	FPort      *uint8
+	FRMPayload []byte
+
+	// Join requests
+	AppEUI   types.AppEUI
+	DevEUI   types.DevEUI
+	DevNonce types.DevNonce
+
+	// Join accepts (after decryption)
+	AppNonce    types.AppNonce
+	NetID       types.NetID
+	RX1DROffset uint8
+	RX2DataRate uint8
+	RXDelay     uint8
+	CFList      []uint32
+
+	// MICValid is set when the MIC was checked with ApplyKeys
+	MICValid *bool
+	// Decrypted is true if the FRMPayload or the join accept was decrypted with ApplyKeys
+	Decrypted bool
+	// FRMPayloadMAC contains the MAC commands of a decrypted FRMPayload on FPort 0
+	FRMPayloadMAC []MACCommand
+
+	phyPayload lorawan.PHYPayload
+}
+
+// Keys are used to validate and decrypt frames. Keys that are not set are not used.
+type Keys struct {
+	NwkSKey *types.NwkSKey
+	AppSKey *types.AppSKey
+	AppKey  *types.AppKey
+}
+
+// IsData returns true if the frame is a data message
+func (f *Frame) IsData() bool {
+	_, ok := f.phyPayload.MACPayload.(*lorawan.MACPayload)
+	return ok
+}
+
+// IsJoinRequest returns true if the frame is a join request
+func (f *Frame) IsJoinRequest() bool {
+	return f.phyPayload.MHDR.MType == lorawan.JoinRequest
+}
+
+// Decode a LoRaWAN PHYPayload
+func Decode(payload []byte) (*Frame, error) {
+	f := new(Frame)
+	if err := f.phyPayload.UnmarshalBinary(payload); err != nil {
+		return nil, err
+	}
+	f.MType = mTypes[f.phyPayload.MHDR.MType]
+	f.Major = byte(f.phyPayload.MHDR.Major)
+	f.MIC = f.phyPayload.MIC
+	switch f.phyPayload.MHDR.MType {
+	case lorawan.JoinRequest, lorawan.UnconfirmedDataUp, lorawan.ConfirmedDataUp:
+		f.Uplink = true
+	}
+
+	switch macPayload := f.phyPayload.MACPayload.(type) {
+	case *lorawan.MACPayload:
+		f.DevAddr = types.DevAddr(macPayload.FHDR.DevAddr)
+		f.ADR = macPayload.FHDR.FCtrl.ADR
+		f.ADRAckReq = macPayload.FHDR.FCtrl.ADRACKReq
+		f.Ack = macPayload.FHDR.FCtrl.ACK
+		f.FPending = macPayload.FHDR.FCtrl.FPending
+		f.FCnt = macPayload.FHDR.FCnt
+		f.FPort = macPayload.FPort
+		f.FOpts = f.macCommands(macPayload.FHDR.FOpts)
+		f.FRMPayload = frmPayload(macPayload.FRMPayload)
+	case *lorawan.JoinRequestPayload:
+		f.AppEUI = types.AppEUI(macPayload.AppEUI)
+		f.DevEUI = types.DevEUI(macPayload.DevEUI)
+		f.DevNonce = types.DevNonce(macPayload.DevNonce)
+	}
+
+	return f, nil
+}
+
+// IsJoinAccept returns true if the frame is a join accept
+func (f *Frame) IsJoinAccept() bool {
+	return f.phyPayload.MHDR.MType == lorawan.JoinAccept
+}
+
+// ApplyKeys validates the MIC and decrypts the frame with the keys that apply to it
+func (f *Frame) ApplyKeys(keys Keys) error {
+	switch macPayload := f.phyPayload.MACPayload.(type) {
+	case *lorawan.MACPayload:
+		if keys.NwkSKey != nil {
+			if err := f.validateMIC(lorawan.AES128Key(*keys.NwkSKey)); err != nil {
+				return err
+			}
+		}
+		if macPayload.FPort == nil || len(macPayload.FRMPayload) == 0 {
+			return nil
+		}
+		if *macPayload.FPort == 0 {
+			if keys.NwkSKey == nil {
+				return nil
+			}
+			if err := f.phyPayload.DecryptFRMPayload(lorawan.AES128Key(*keys.NwkSKey)); err != nil {
+				return err
+			}
+			f.Decrypted = true
+			var commands []lorawan.MACCommand
+			for _, payload := range macPayload.FRMPayload {
+				if command, ok := payload.(*lorawan.MACCommand); ok {
+					commands = append(commands, *command)
+				}
+			}
+			f.FRMPayloadMAC = f.macCommands(commands)
+			f.FRMPayload = frmPayload(macPayload.FRMPayload)
+			return nil
+		}
+		if keys.AppSKey == nil {
+			return nil
+		}
+		if err := f.phyPayload.DecryptFRMPayload(lorawan.AES128Key(*keys.AppSKey)); err != nil {
+			return err
+		}
+		f.Decrypted = true
+		f.FRMPayload = frmPayload(macPayload.FRMPayload)
+	case *lorawan.JoinRequestPayload:
+		if keys.AppKey != nil {
+			return f.validateMIC(lorawan.AES128Key(*keys.AppKey))
+		}
+	default:
+		if !f.IsJoinAccept() || keys.AppKey == nil {
+			return nil
+		}
+		if err := f.phyPayload.DecryptJoinAcceptPayload(lorawan.AES128Key(*keys.AppKey)); err != nil {
+			return err
+		}
+		accept, ok := f.phyPayload.MACPayload.(*lorawan.JoinAcceptPayload)
+		if !ok {
+			return fmt.Errorf("Could not decrypt join accept")
+		}
+		f.Decrypted = true
+		f.AppNonce = types.AppNonce(accept.AppNonce)
+		f.NetID = types.NetID(accept.NetID)
+		f.DevAddr = types.DevAddr(accept.DevAddr)
+		f.RX1DROffset = accept.DLSettings.RX1DROffset
+		f.RX2DataRate = accept.DLSettings.RX2DataRate
+		f.RXDelay = accept.RXDelay
+		if accept.CFList != nil {
+			f.CFList = accept.CFList[:]
+		}
+		return f.validateMIC(lorawan.AES128Key(*keys.AppKey))
+	}
+	return nil
+}
+
+func (f *Frame) validateMIC(key lorawan.AES128Key) error {
+	valid, err := f.phyPayload.ValidateMIC(key)
+	if err != nil {
+		return err
+	}
+	f.MICValid = &valid
+	return nil
+}
+
+func (f *Frame) macCommands(commands []lorawan.MACCommand) []MACCommand {
+	names := downlinkMACCommands
+	if f.Uplink {
+		names = uplinkMACCommands
+	}
+	out := make([]MACCommand, 0, len(commands))
+	for _, command := range commands {
+		decoded := MACCommand{CID: byte(command.CID), Name: names[command.CID]}
+		if decoded.Name == "" {
+			decoded.Name = fmt.Sprintf("CID%02X", byte(command.CID))
+		}
+		if command.Payload != nil {
+			decoded.Payload, _ = command.Payload.MarshalBinary()
+		}
+		out = append(out, decoded)
+	}
+	return out
+}
+
+func frmPayload(payloads []lorawan.Payload) (out []byte) {
+	for _, payload := range payloads {
+		data, _ := payload.MarshalBinary()
+		out = append(out, data...)
+	}
+	return
+}
@@ -0,0 +1,230 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package frame
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	"github.com/brocaar/lorawan"
+	. "github.com/smartystreets/assertions"
+)
+
+func buildUplink() []byte {
+	fPort := uint8(1)
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.ConfirmedDataUp, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.MACPayload{
+			FHDR: lorawan.FHDR{
+				DevAddr: lorawan.DevAddr{1, 2, 3, 4},
+				FCtrl:   lorawan.FCtrl{ADR: true},
+				FCnt:    5,
+				FOpts:   []lorawan.MACCommand{{CID: lorawan.LinkCheckReq}},
+			},
+			FPort:      &fPort,
+			FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: []byte{0xaa, 0xbb}}},
+		},
+	}
+	bytes, _ := phy.MarshalBinary()
+	return bytes
+}
+
+func TestDecodeUplink(t *testing.T) {
+	a := New(t)
+
+	f, err := Decode(buildUplink())
+	a.So(err, ShouldBeNil)
+	a.So(f.IsData(), ShouldBeTrue)
+	a.So(f.Uplink, ShouldBeTrue)
+	a.So(f.MType, ShouldEqual, "ConfirmedDataUp")
+	a.So(f.DevAddr, ShouldEqual, types.DevAddr{1, 2, 3, 4})
+	a.So(f.ADR, ShouldBeTrue)
+	a.So(f.FCnt, ShouldEqual, 5)
+	a.So(*f.FPort, ShouldEqual, 1)
+	a.So(f.FRMPayload, ShouldResemble, []byte{0xaa, 0xbb})
+	a.So(f.FOpts, ShouldHaveLength, 1)
+	a.So(f.FOpts[0].String(), ShouldEqual, "LinkCheckReq")
+}
+
+func TestDecodeJoinRequest(t *testing.T) {
+	a := New(t)
+
+	phy := lorawan.PHYPayload{
+		MHDR: lorawan.MHDR{MType: lorawan.JoinRequest, Major: lorawan.LoRaWANR1},
+		MACPayload: &lorawan.JoinRequestPayload{
+			AppEUI:   lorawan.EUI64{1, 2, 3, 4, 5, 6, 7, 8},
+			DevEUI:   lorawan.EUI64{8, 7, 6, 5, 4, 3, 2, 1},
+			DevNonce: [2]byte{1, 2},
+		},
+	}
+	bytes, _ := phy.MarshalBinary()
+
+	f, err := Decode(bytes)
+	a.So(err, ShouldBeNil)
+	a.So(f.IsJoinRequest(), ShouldBeTrue)
+	a.So(f.IsData(), ShouldBeFalse)
+	a.So(f.AppEUI, ShouldEqual, types.AppEUI{1, 2, 3, 4, 5, 6, 7, 8})
+	a.So(f.DevEUI, ShouldEqual, types.DevEUI{8, 7, 6, 5, 4, 3, 2, 1})
+	a.So(f.DevNonce, ShouldEqual, types.DevNonce{1, 2})
+
+	_, err = Decode([]byte{1, 2})
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestApplyKeys(t *testing.T) {
+	a := New(t)
+
+	nwkSKey := types.NwkSKey{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	appSKey := types.AppSKey{8, 7, 6, 5, 4, 3, 2, 1, 8, 7, 6, 5, 4, 3, 2, 1}
+	appKey := types.AppKey{1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4}
+
+	// Data uplink
+	{
+		var phy lorawan.PHYPayload
+		phy.UnmarshalBinary(buildUplink())
+		phy.EncryptFRMPayload(lorawan.AES128Key(appSKey))
+		phy.SetMIC(lorawan.AES128Key(nwkSKey))
+		bytes, _ := phy.MarshalBinary()
+
+		f, _ := Decode(bytes)
+		a.So(f.FRMPayload, ShouldNotResemble, []byte{0xaa, 0xbb})
+		a.So(f.ApplyKeys(Keys{}), ShouldBeNil)
+		a.So(f.MICValid, ShouldBeNil)
+		a.So(f.Decrypted, ShouldBeFalse)
+
+		a.So(f.ApplyKeys(Keys{NwkSKey: &nwkSKey, AppSKey: &appSKey}), ShouldBeNil)
+		a.So(*f.MICValid, ShouldBeTrue)
+		a.So(f.Decrypted, ShouldBeTrue)
+		a.So(f.FRMPayload, ShouldResemble, []byte{0xaa, 0xbb})
+
+		f, _ = Decode(bytes)
+		wrongKey := types.NwkSKey{}
+		a.So(f.ApplyKeys(Keys{NwkSKey: &wrongKey}), ShouldBeNil)
+		a.So(*f.MICValid, ShouldBeFalse)
+	}
+
+	// Join accept
+	{
+		phy := lorawan.PHYPayload{
+			MHDR: lorawan.MHDR{MType: lorawan.JoinAccept, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.JoinAcceptPayload{
+				AppNonce:   [3]byte{1, 2, 3},
+				NetID:      lorawan.NetID{0, 0, 0x13},
+				DevAddr:    lorawan.DevAddr{0x26, 1, 2, 3},
+				DLSettings: lorawan.DLSettings{RX2DataRate: 3, RX1DROffset: 1},
+				RXDelay:    1,
+			},
+		}
+		phy.SetMIC(lorawan.AES128Key(appKey))
+		phy.EncryptJoinAcceptPayload(lorawan.AES128Key(appKey))
+		bytes, _ := phy.MarshalBinary()
+
+		f, err := Decode(bytes)
+		a.So(err, ShouldBeNil)
+		a.So(f.IsJoinAccept(), ShouldBeTrue)
+		a.So(f.ApplyKeys(Keys{AppKey: &appKey}), ShouldBeNil)
+		a.So(f.Decrypted, ShouldBeTrue)
+		a.So(*f.MICValid, ShouldBeTrue)
+		a.So(f.AppNonce, ShouldEqual, types.AppNonce{1, 2, 3})
+		a.So(f.DevAddr, ShouldEqual, types.DevAddr{0x26, 1, 2, 3})
+		a.So(f.RX2DataRate, ShouldEqual, 3)
+		a.So(f.RX1DROffset, ShouldEqual, 1)
+	}
+}
+
+// benchmarkFrames returns a frame of each message type, as sent over the air
+func benchmarkFrames() map[string][]byte {
+	key := lorawan.AES128Key{1, 2, 3, 4, 5, 6, 7, 8, 1, 2, 3, 4, 5, 6, 7, 8}
+	fPort := uint8(1)
+	frames := map[string]lorawan.PHYPayload{
+		"JoinRequest": {
+			MHDR: lorawan.MHDR{MType: lorawan.JoinRequest, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.JoinRequestPayload{
+				AppEUI:   lorawan.EUI64{1, 2, 3, 4, 5, 6, 7, 8},
+				DevEUI:   lorawan.EUI64{8, 7, 6, 5, 4, 3, 2, 1},
+				DevNonce: [2]byte{1, 2},
+			},
+		},
+		"JoinAccept": {
+			MHDR: lorawan.MHDR{MType: lorawan.JoinAccept, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.JoinAcceptPayload{
+				AppNonce: [3]byte{1, 2, 3},
+				NetID:    lorawan.NetID{0, 0, 0x13},
+				DevAddr:  lorawan.DevAddr{0x26, 1, 2, 3},
+				RXDelay:  1,
+			},
+		},
+		"UnconfirmedDataUp": {
+			MHDR: lorawan.MHDR{MType: lorawan.UnconfirmedDataUp, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.MACPayload{
+				FHDR:       lorawan.FHDR{DevAddr: lorawan.DevAddr{1, 2, 3, 4}, FCnt: 5},
+				FPort:      &fPort,
+				FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: make([]byte, 16)}},
+			},
+		},
+		"ConfirmedDataDown": {
+			MHDR: lorawan.MHDR{MType: lorawan.ConfirmedDataDown, Major: lorawan.LoRaWANR1},
+			MACPayload: &lorawan.MACPayload{
+				FHDR: lorawan.FHDR{
+					DevAddr: lorawan.DevAddr{1, 2, 3, 4},
+					FCtrl:   lorawan.FCtrl{ACK: true},
+					FCnt:    5,
+					FOpts:   []lorawan.MACCommand{{CID: lorawan.LinkCheckAns, Payload: &lorawan.LinkCheckAnsPayload{Margin: 7, GwCnt: 1}}},
+				},
+				FPort:      &fPort,
+				FRMPayload: []lorawan.Payload{&lorawan.DataPayload{Bytes: make([]byte, 16)}},
+			},
+		},
+	}
+	bytes := make(map[string][]byte, len(frames))
+	for name, phy := range frames {
+		phy.SetMIC(key)
+		if phy.MHDR.MType == lorawan.JoinAccept {
+			phy.EncryptJoinAcceptPayload(key)
+		}
+		bytes[name], _ = phy.MarshalBinary()
+	}
+	return bytes
+}
+
+var benchmarkResult interface{}
+
+func BenchmarkMarshal(b *testing.B) {
+	for name, bytes := range benchmarkFrames() {
+		var phy lorawan.PHYPayload
+		phy.UnmarshalBinary(bytes)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				benchmarkResult, _ = phy.MarshalBinary()
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	for name, bytes := range benchmarkFrames() {
+		bytes := bytes
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				var phy lorawan.PHYPayload
+				phy.UnmarshalBinary(bytes)
+				benchmarkResult = phy
+			}
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	for name, bytes := range benchmarkFrames() {
+		bytes := bytes
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				benchmarkResult, _ = Decode(bytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package otaa
+
+import (
+	"crypto/aes"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// DeriveAppKey derives the AppKey of a device from a root key and the DevEUI of the device
+//
+// The AppKey is calculated as aes128_encrypt(RootKey, DevEUI | 0x00 * 8), where the DevEUI is MSB-first.
+// This allows manufacturers to provision a large number of devices with unique AppKeys while only having
+// to store a single root key.
+func DeriveAppKey(rootKey types.AES128Key, devEUI types.DevEUI) (appKey types.AppKey) {
+	buf := make([]byte, 16)
+	copy(buf[0:8], devEUI[:])
+
+	block, _ := aes.NewCipher(rootKey[:])
+	block.Encrypt(appKey[:], buf)
+
+	return
+}
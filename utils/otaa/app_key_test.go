@@ -0,0 +1,24 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package otaa
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDeriveAppKey(t *testing.T) {
+	a := New(t)
+
+	rootKey := types.AES128Key{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	devEUI := types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x00, 0x01}
+
+	expected := types.AppKey{0xB3, 0xB8, 0xA3, 0xAA, 0xC7, 0x70, 0x0F, 0xA0, 0x4C, 0x18, 0x09, 0x82, 0x7F, 0x5E, 0x71, 0x4E}
+	a.So(DeriveAppKey(rootKey, devEUI), ShouldResemble, expected)
+
+	other := types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x00, 0x02}
+	a.So(DeriveAppKey(rootKey, other), ShouldNotResemble, expected)
+}
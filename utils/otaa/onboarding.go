@@ -0,0 +1,130 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package otaa
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/TheThingsNetwork/go-utils/random"
+	"github.com/TheThingsNetwork/ttn/core/types"
+)
+
+// DevEUIBlock is a block of DevEUIs that is assigned to a device maker, for example 70B3D57ED0001000/52
+type DevEUIBlock struct {
+	Start  types.DevEUI
+	Length int // Number of significant bits of the block
+}
+
+// ParseDevEUIBlock parses a DevEUI block formatted as DevEUI/length
+func ParseDevEUIBlock(input string) (block DevEUIBlock, err error) {
+	parts := strings.Split(input, "/")
+	if len(parts) != 2 {
+		return block, fmt.Errorf("Invalid DevEUI block %s", input)
+	}
+	if block.Start, err = types.ParseDevEUI(parts[0]); err != nil {
+		return block, err
+	}
+	if block.Length, err = strconv.Atoi(parts[1]); err != nil || block.Length < 0 || block.Length > 64 {
+		return block, fmt.Errorf("Invalid length of DevEUI block %s", input)
+	}
+	if block.start()&^block.mask() != 0 {
+		return block, fmt.Errorf("DevEUI %s is not the start of a /%d block", block.Start, block.Length)
+	}
+	return block, nil
+}
+
+func (b DevEUIBlock) start() uint64 {
+	return binary.BigEndian.Uint64(b.Start[:])
+}
+
+func (b DevEUIBlock) mask() uint64 {
+	if b.Length == 0 {
+		return 0
+	}
+	return ^uint64(0) << uint(64-b.Length)
+}
+
+// Size returns the number of DevEUIs in the block. The size of a /0 block is returned as 0.
+func (b DevEUIBlock) Size() uint64 {
+	return ^b.mask() + 1
+}
+
+// DevEUI returns the n'th DevEUI of the block
+func (b DevEUIBlock) DevEUI(n uint64) (devEUI types.DevEUI, err error) {
+	if n > ^b.mask() {
+		return devEUI, fmt.Errorf("DevEUI %d is outside the /%d block", n, b.Length)
+	}
+	binary.BigEndian.PutUint64(devEUI[:], b.start()+n)
+	return devEUI, nil
+}
+
+// String implements the Stringer interface
+func (b DevEUIBlock) String() string {
+	return fmt.Sprintf("%s/%d", b.Start, b.Length)
+}
+
+// GenerateAppKey generates a random AppKey
+func GenerateAppKey() (appKey types.AppKey) {
+	random.FillBytes(appKey[:])
+	return
+}
+
+// QRCode contains the fields of a LoRaWAN device onboarding QR code, as defined in LoRa Alliance TR005
+type QRCode struct {
+	JoinEUI      types.AppEUI
+	DevEUI       types.DevEUI
+	VendorID     uint16 // The LoRa Alliance vendor ID of the device maker
+	ModelID      uint16 // The model ID that is assigned by the device maker
+	OwnerToken   string
+	SerialNumber string
+}
+
+// qrCodeVersion is the only data format of TR005 (LoRaWAN, version 0)
+const qrCodeVersion = "LW:D0"
+
+// String returns the payload of the QR code
+func (q QRCode) String() string {
+	fields := []string{qrCodeVersion, q.JoinEUI.String(), q.DevEUI.String(), fmt.Sprintf("%04X%04X", q.VendorID, q.ModelID)}
+	if q.OwnerToken != "" {
+		fields = append(fields, "O"+q.OwnerToken)
+	}
+	if q.SerialNumber != "" {
+		fields = append(fields, "S"+q.SerialNumber)
+	}
+	return strings.Join(fields, ":")
+}
+
+// ParseQRCode parses the payload of a TR005 QR code. Unknown options are ignored.
+func ParseQRCode(input string) (q QRCode, err error) {
+	fields := strings.Split(input, ":")
+	if len(fields) < 5 || strings.Join(fields[:2], ":") != qrCodeVersion {
+		return q, fmt.Errorf("Invalid QR code %s", input)
+	}
+	if q.JoinEUI, err = types.ParseAppEUI(fields[2]); err != nil {
+		return q, err
+	}
+	if q.DevEUI, err = types.ParseDevEUI(fields[3]); err != nil {
+		return q, err
+	}
+	profileID, err := strconv.ParseUint(fields[4], 16, 32)
+	if err != nil || len(fields[4]) != 8 {
+		return q, fmt.Errorf("Invalid profile ID %s", fields[4])
+	}
+	q.VendorID, q.ModelID = uint16(profileID>>16), uint16(profileID)
+	for _, option := range fields[5:] {
+		if option == "" {
+			continue
+		}
+		switch option[0] {
+		case 'O':
+			q.OwnerToken = option[1:]
+		case 'S':
+			q.SerialNumber = option[1:]
+		}
+	}
+	return q, nil
+}
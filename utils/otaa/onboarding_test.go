@@ -0,0 +1,66 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package otaa
+
+import (
+	"testing"
+
+	"github.com/TheThingsNetwork/ttn/core/types"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestDevEUIBlock(t *testing.T) {
+	a := New(t)
+
+	block, err := ParseDevEUIBlock("70B3D57ED0001000/52")
+	a.So(err, ShouldBeNil)
+	a.So(block.Size(), ShouldEqual, 4096)
+	a.So(block.String(), ShouldEqual, "70B3D57ED0001000/52")
+
+	devEUI, err := block.DevEUI(0)
+	a.So(err, ShouldBeNil)
+	a.So(devEUI, ShouldEqual, types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x10, 0x00})
+
+	devEUI, err = block.DevEUI(4095)
+	a.So(err, ShouldBeNil)
+	a.So(devEUI, ShouldEqual, types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x1F, 0xFF})
+
+	_, err = block.DevEUI(4096)
+	a.So(err, ShouldNotBeNil)
+
+	_, err = ParseDevEUIBlock("70B3D57ED0001001/52")
+	a.So(err, ShouldNotBeNil)
+	_, err = ParseDevEUIBlock("70B3D57ED0001000/65")
+	a.So(err, ShouldNotBeNil)
+	_, err = ParseDevEUIBlock("70B3D57ED0001000")
+	a.So(err, ShouldNotBeNil)
+}
+
+func TestGenerateAppKey(t *testing.T) {
+	a := New(t)
+	a.So(GenerateAppKey(), ShouldNotEqual, types.AppKey{})
+	a.So(GenerateAppKey(), ShouldNotEqual, GenerateAppKey())
+}
+
+func TestQRCode(t *testing.T) {
+	a := New(t)
+
+	q := QRCode{
+		JoinEUI:      types.AppEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x00, 0x01},
+		DevEUI:       types.DevEUI{0x70, 0xB3, 0xD5, 0x7E, 0xD0, 0x00, 0x10, 0x00},
+		VendorID:     0x000A,
+		ModelID:      0x0001,
+		SerialNumber: "1234",
+	}
+	a.So(q.String(), ShouldEqual, "LW:D0:70B3D57ED0000001:70B3D57ED0001000:000A0001:S1234")
+
+	parsed, err := ParseQRCode(q.String())
+	a.So(err, ShouldBeNil)
+	a.So(parsed, ShouldResemble, q)
+
+	_, err = ParseQRCode("LW:D1:70B3D57ED0000001:70B3D57ED0001000:000A0001")
+	a.So(err, ShouldNotBeNil)
+	_, err = ParseQRCode("LW:D0:70B3D57ED0000001:70B3D57ED0001000:0A01")
+	a.So(err, ShouldNotBeNil)
+}
@@ -0,0 +1,158 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package soak
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Results collects the results of a scenario
+type Results struct {
+	mu         sync.Mutex
+	pending    map[string]time.Time
+	latencies  []time.Duration
+	sent       int
+	duplicates int
+	sendErrors int
+	skipped    int
+	joins      int
+	joinErrors int
+}
+
+// NewResults returns new Results
+func NewResults() *Results {
+	return &Results{pending: make(map[string]time.Time)}
+}
+
+// Sent records that the uplink with the key was sent
+func (r *Results) Sent(key string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent++
+	r.pending[key] = at
+}
+
+// Received records that the uplink with the key was received
+func (r *Results) Received(key string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent, ok := r.pending[key]
+	if !ok {
+		r.duplicates++
+		return
+	}
+	delete(r.pending, key)
+	r.latencies = append(r.latencies, at.Sub(sent))
+}
+
+func (r *Results) count(counter *int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*counter++
+}
+
+// SendError records that an uplink could not be sent
+func (r *Results) SendError() { r.count(&r.sendErrors) }
+
+// Skipped records that an uplink was not sent because the gateway was disconnected
+func (r *Results) Skipped() { r.count(&r.skipped) }
+
+// Join records that a join request was sent
+func (r *Results) Join() { r.count(&r.joins) }
+
+// JoinError records that a join request could not be sent
+func (r *Results) JoinError() { r.count(&r.joinErrors) }
+
+// Report is the result of a scenario
+type Report struct {
+	Scenario   string              `json:"scenario"`
+	Start      time.Time           `json:"start"`
+	End        time.Time           `json:"end"`
+	Sent       int                 `json:"sent"`
+	Received   int                 `json:"received"`
+	Lost       int                 `json:"lost"`
+	Duplicates int                 `json:"duplicates"`
+	SendErrors int                 `json:"send_errors"`
+	Skipped    int                 `json:"skipped"` // uplinks that were not sent because the gateway was disconnected
+	Joins      int                 `json:"joins"`
+	JoinErrors int                 `json:"join_errors"`
+	LossRate   float64             `json:"loss_rate"`
+	Latency    map[string]Duration `json:"latency,omitempty"`
+	Failures   []string            `json:"failures,omitempty"`
+	Passed     bool                `json:"passed"`
+}
+
+var reportedPercentiles = []string{"p50", "p90", "p95", "p99", "max"}
+
+// Report returns the report of the scenario and checks its assertions. Uplinks that were not received yet are lost.
+func (r *Results) Report(s *Scenario, start, end time.Time) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{
+		Scenario:   s.Name,
+		Start:      start,
+		End:        end,
+		Sent:       r.sent,
+		Received:   len(r.latencies),
+		Lost:       len(r.pending),
+		Duplicates: r.duplicates,
+		SendErrors: r.sendErrors,
+		Skipped:    r.skipped,
+		Joins:      r.joins,
+		JoinErrors: r.joinErrors,
+		Latency:    make(map[string]Duration),
+	}
+	if report.Sent > 0 {
+		report.LossRate = float64(report.Lost) / float64(report.Sent)
+	}
+
+	latencies := make([]time.Duration, len(r.latencies))
+	copy(latencies, r.latencies)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, name := range reportedPercentiles {
+		if len(latencies) > 0 {
+			p, _ := parsePercentile(name)
+			report.Latency[name] = Duration(percentile(latencies, p))
+		}
+	}
+
+	if max := s.Assertions.MaxLossRate; max != nil && report.LossRate > *max {
+		report.Failures = append(report.Failures, fmt.Sprintf("Loss rate %.4f is higher than %.4f", report.LossRate, *max))
+	}
+	names := make([]string, 0, len(s.Assertions.MaxLatency))
+	for name := range s.Assertions.MaxLatency {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		max := time.Duration(s.Assertions.MaxLatency[name])
+		if len(latencies) == 0 {
+			report.Failures = append(report.Failures, fmt.Sprintf("Latency %s is unknown, no uplinks were received", name))
+			continue
+		}
+		p, _ := parsePercentile(name)
+		latency := percentile(latencies, p)
+		report.Latency[name] = Duration(latency)
+		if latency > max {
+			report.Failures = append(report.Failures, fmt.Sprintf("Latency %s %s is higher than %s", name, latency, max))
+		}
+	}
+	report.Passed = len(report.Failures) == 0
+
+	return report
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,106 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package soak
+
+import (
+	"time"
+
+	ttnlog "github.com/TheThingsNetwork/go-utils/log"
+)
+
+// Target is the deployment that a scenario runs against
+type Target interface {
+	// Uplink sends an uplink and returns the key with which it is received
+	Uplink() (key string, err error)
+	// Join sends a join request
+	Join() error
+	// SetGatewayUp connects or disconnects the gateway
+	SetGatewayUp(up bool) error
+}
+
+// DefaultTick is the interval in which the runner sends traffic
+const DefaultTick = 100 * time.Millisecond
+
+// Runner runs a scenario against a target
+type Runner struct {
+	Ctx      ttnlog.Interface
+	Scenario *Scenario
+	Target   Target
+	Results  *Results
+
+	Tick  time.Duration // DefaultTick if zero
+	Grace time.Duration // time to wait for uplinks that are still underway when the scenario ends
+}
+
+// Run the scenario until its duration elapsed or until stop is closed, and return the report
+func (r *Runner) Run(stop <-chan struct{}) *Report {
+	tick := r.Tick
+	if tick == 0 {
+		tick = DefaultTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	start := time.Now()
+	duration := time.Duration(r.Scenario.Duration)
+	gatewayUp := true
+	var due float64
+	var joins int
+
+run:
+	for {
+		select {
+		case <-stop:
+			break run
+		case now := <-ticker.C:
+			elapsed := now.Sub(start)
+			if elapsed > duration {
+				break run
+			}
+
+			if up := r.Scenario.GatewayUp(elapsed); up != gatewayUp {
+				if err := r.Target.SetGatewayUp(up); err != nil {
+					r.Ctx.WithError(err).Warn("Could not change gateway connection")
+				} else {
+					gatewayUp = up
+					r.Ctx.WithField("Up", up).Debug("Changed gateway connection")
+				}
+			}
+
+			due += r.Scenario.UplinkRate(elapsed) * tick.Seconds()
+			for ; due >= 1; due-- {
+				if !gatewayUp {
+					r.Results.Skipped()
+					continue
+				}
+				key, err := r.Target.Uplink()
+				if err != nil {
+					r.Results.SendError()
+					continue
+				}
+				r.Results.Sent(key, time.Now())
+			}
+
+			for target := r.Scenario.Joins(elapsed); joins < target; joins++ {
+				if err := r.Target.Join(); err != nil {
+					r.Results.JoinError()
+					continue
+				}
+				r.Results.Join()
+			}
+		}
+	}
+
+	if !gatewayUp {
+		r.Target.SetGatewayUp(true)
+	}
+	end := time.Now()
+	if r.Grace > 0 {
+		select {
+		case <-stop:
+		case <-time.After(r.Grace):
+		}
+	}
+	return r.Results.Report(r.Scenario, start, end)
+}
@@ -0,0 +1,169 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+// Package soak runs long-running traffic scenarios against a deployment and checks their loss rate and latency
+package soak
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TheThingsNetwork/ttn/utils/errors"
+)
+
+// Duration is a time.Duration that is formatted as a string in JSON
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// Scenario is a traffic profile with the assertions on its results
+type Scenario struct {
+	Name            string           `json:"name"`
+	Duration        Duration         `json:"duration"`
+	Uplinks         Uplinks          `json:"uplinks"`
+	JoinStorms      []JoinStorm      `json:"join_storms,omitempty"`
+	GatewayFlapping *GatewayFlapping `json:"gateway_flapping,omitempty"`
+	Assertions      Assertions       `json:"assertions"`
+}
+
+// Uplinks is the uplink traffic of a scenario
+type Uplinks struct {
+	Rate        float64  `json:"rate"` // average number of uplinks per second
+	PayloadSize int      `json:"payload_size,omitempty"`
+	Diurnal     *Diurnal `json:"diurnal,omitempty"`
+}
+
+// Diurnal varies the uplink rate as a sine, for example to simulate the day and night pattern of sensors
+type Diurnal struct {
+	Amplitude float64  `json:"amplitude"`        // fraction of the average rate, between 0 and 1
+	Period    Duration `json:"period,omitempty"` // 24 hours by default; shorter periods compress the pattern
+	Peak      Duration `json:"peak,omitempty"`   // time after the start of the period at which the rate is the highest
+}
+
+// JoinStorm sends a number of join requests spread over a window
+type JoinStorm struct {
+	At     Duration `json:"at"` // time after the start of the scenario
+	Joins  int      `json:"joins"`
+	Within Duration `json:"within"`
+}
+
+// GatewayFlapping disconnects the gateway periodically
+type GatewayFlapping struct {
+	Every Duration `json:"every"`
+	Down  Duration `json:"down"`
+}
+
+// Assertions on the results of a scenario
+type Assertions struct {
+	MaxLossRate *float64            `json:"max_loss_rate,omitempty"` // fraction of the sent uplinks that was not received
+	MaxLatency  map[string]Duration `json:"max_latency,omitempty"`   // by percentile, such as p50, p99 or max
+}
+
+const defaultDiurnalPeriod = 24 * time.Hour
+
+// DefaultPayloadSize is the size of the uplink payloads if the scenario does not set it
+const DefaultPayloadSize = 10
+
+// Validate the scenario
+func (s *Scenario) Validate() error {
+	if s.Duration <= 0 {
+		return errors.NewErrInvalidArgument("Duration", "must be positive")
+	}
+	if s.Uplinks.Rate < 0 {
+		return errors.NewErrInvalidArgument("Uplink rate", "can not be negative")
+	}
+	if s.Uplinks.PayloadSize < 0 || s.Uplinks.PayloadSize > 222 {
+		return errors.NewErrInvalidArgument("Payload size", "must be between 0 and 222 bytes")
+	}
+	if d := s.Uplinks.Diurnal; d != nil && (d.Amplitude < 0 || d.Amplitude > 1 || d.Period < 0) {
+		return errors.NewErrInvalidArgument("Diurnal", "amplitude must be between 0 and 1 and period can not be negative")
+	}
+	for i, storm := range s.JoinStorms {
+		if storm.At < 0 || storm.Joins < 0 || storm.Within < 0 {
+			return errors.NewErrInvalidArgument(fmt.Sprintf("Join storm %d", i+1), "can not have negative values")
+		}
+	}
+	if f := s.GatewayFlapping; f != nil && (f.Every <= 0 || f.Down <= 0 || f.Down >= f.Every) {
+		return errors.NewErrInvalidArgument("Gateway flapping", "down must be positive and shorter than every")
+	}
+	if l := s.Assertions.MaxLossRate; l != nil && (*l < 0 || *l > 1) {
+		return errors.NewErrInvalidArgument("Max loss rate", "must be between 0 and 1")
+	}
+	for name := range s.Assertions.MaxLatency {
+		if _, err := parsePercentile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UplinkRate returns the number of uplinks per second at the elapsed time
+func (s *Scenario) UplinkRate(elapsed time.Duration) float64 {
+	rate := s.Uplinks.Rate
+	if d := s.Uplinks.Diurnal; d != nil {
+		period := time.Duration(d.Period)
+		if period == 0 {
+			period = defaultDiurnalPeriod
+		}
+		phase := float64(elapsed-time.Duration(d.Peak)) / float64(period)
+		rate *= 1 + d.Amplitude*math.Cos(2*math.Pi*phase)
+	}
+	return rate
+}
+
+// Joins returns the number of join requests that the join storms send until the elapsed time
+func (s *Scenario) Joins(elapsed time.Duration) (joins int) {
+	for _, storm := range s.JoinStorms {
+		since := elapsed - time.Duration(storm.At)
+		switch {
+		case since < 0:
+		case since >= time.Duration(storm.Within):
+			joins += storm.Joins
+		default:
+			joins += int(float64(storm.Joins) * float64(since) / float64(storm.Within))
+		}
+	}
+	return
+}
+
+// GatewayUp returns false if the gateway is disconnected at the elapsed time
+func (s *Scenario) GatewayUp(elapsed time.Duration) bool {
+	f := s.GatewayFlapping
+	if f == nil {
+		return true
+	}
+	return elapsed%time.Duration(f.Every) < time.Duration(f.Every-f.Down)
+}
+
+// parsePercentile parses percentiles such as p50, p99.9 and max
+func parsePercentile(name string) (float64, error) {
+	if name == "max" {
+		return 100, nil
+	}
+	p, err := strconv.ParseFloat(strings.TrimPrefix(name, "p"), 64)
+	if err != nil || !strings.HasPrefix(name, "p") || p <= 0 || p > 100 {
+		return 0, errors.NewErrInvalidArgument("Percentile", fmt.Sprintf("%s is not a percentile such as p50, p99 or max", name))
+	}
+	return p, nil
+}
@@ -0,0 +1,132 @@
+// Copyright © 2017 The Things Network
+// Use of this source code is governed by the MIT license that can be found in the LICENSE file.
+
+package soak
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/TheThingsNetwork/ttn/utils/testing"
+	. "github.com/smartystreets/assertions"
+)
+
+func TestScenario(t *testing.T) {
+	a := New(t)
+
+	var s Scenario
+	err := json.Unmarshal([]byte(`{
+		"name": "test",
+		"duration": "1h",
+		"uplinks": {"rate": 10, "diurnal": {"amplitude": 0.5, "period": "1h", "peak": "15m"}},
+		"join_storms": [{"at": "10m", "joins": 100, "within": "1m"}],
+		"gateway_flapping": {"every": "10m", "down": "1m"},
+		"assertions": {"max_loss_rate": 0.01, "max_latency": {"p99": "2s"}}
+	}`), &s)
+	a.So(err, ShouldBeNil)
+	a.So(s.Validate(), ShouldBeNil)
+
+	a.So(s.UplinkRate(15*time.Minute), ShouldAlmostEqual, 15)
+	a.So(s.UplinkRate(45*time.Minute), ShouldAlmostEqual, 5)
+
+	a.So(s.Joins(5*time.Minute), ShouldEqual, 0)
+	a.So(s.Joins(10*time.Minute+30*time.Second), ShouldEqual, 50)
+	a.So(s.Joins(20*time.Minute), ShouldEqual, 100)
+
+	a.So(s.GatewayUp(5*time.Minute), ShouldBeTrue)
+	a.So(s.GatewayUp(9*time.Minute+30*time.Second), ShouldBeFalse)
+	a.So(s.GatewayUp(10*time.Minute), ShouldBeTrue)
+
+	s.Assertions.MaxLatency["p0"] = Duration(time.Second)
+	a.So(s.Validate(), ShouldNotBeNil)
+	a.So((&Scenario{}).Validate(), ShouldNotBeNil)
+}
+
+func TestReport(t *testing.T) {
+	a := New(t)
+
+	maxLoss := 0.1
+	s := &Scenario{Name: "test", Assertions: Assertions{
+		MaxLossRate: &maxLoss,
+		MaxLatency:  map[string]Duration{"p50": Duration(50 * time.Millisecond), "max": Duration(50 * time.Millisecond)},
+	}}
+
+	results := NewResults()
+	now := time.Now()
+	for i := 1; i <= 10; i++ {
+		key := fmt.Sprint(i)
+		results.Sent(key, now)
+		if i < 10 {
+			results.Received(key, now.Add(time.Duration(i)*10*time.Millisecond))
+		}
+	}
+	results.Received("1", now)
+
+	report := results.Report(s, now, now)
+	a.So(report.Sent, ShouldEqual, 10)
+	a.So(report.Received, ShouldEqual, 9)
+	a.So(report.Lost, ShouldEqual, 1)
+	a.So(report.Duplicates, ShouldEqual, 1)
+	a.So(report.LossRate, ShouldAlmostEqual, 0.1)
+	a.So(report.Latency["p50"], ShouldEqual, Duration(50*time.Millisecond))
+	a.So(report.Latency["max"], ShouldEqual, Duration(90*time.Millisecond))
+	a.So(report.Failures, ShouldHaveLength, 1)
+	a.So(report.Passed, ShouldBeFalse)
+}
+
+type testTarget struct {
+	results *Results
+	uplinks int
+	joins   int
+	down    int
+}
+
+func (t *testTarget) Uplink() (string, error) {
+	t.uplinks++
+	key := fmt.Sprint(t.uplinks)
+	go func() {
+		time.Sleep(time.Millisecond)
+		t.results.Received(key, time.Now())
+	}()
+	return key, nil
+}
+
+func (t *testTarget) Join() error {
+	t.joins++
+	return nil
+}
+
+func (t *testTarget) SetGatewayUp(up bool) error {
+	if !up {
+		t.down++
+	}
+	return nil
+}
+
+func TestRunner(t *testing.T) {
+	a := New(t)
+
+	results := NewResults()
+	target := &testTarget{results: results}
+	runner := &Runner{
+		Ctx: GetLogger(t, "TestRunner"),
+		Scenario: &Scenario{
+			Duration:   Duration(500 * time.Millisecond),
+			Uplinks:    Uplinks{Rate: 100},
+			JoinStorms: []JoinStorm{{At: Duration(100 * time.Millisecond), Joins: 10, Within: Duration(100 * time.Millisecond)}},
+		},
+		Target:  target,
+		Results: results,
+		Tick:    10 * time.Millisecond,
+		Grace:   50 * time.Millisecond,
+	}
+
+	report := runner.Run(nil)
+	a.So(report.Sent, ShouldBeBetweenOrEqual, 40, 50)
+	a.So(report.Received, ShouldEqual, report.Sent)
+	a.So(report.Joins, ShouldEqual, 10)
+	a.So(report.Passed, ShouldBeTrue)
+	a.So(target.down, ShouldEqual, 0)
+}